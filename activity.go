@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources of worktree activity, as reported in worktreeInfo.Activity.
+const (
+	activityCommit = "commit"
+	activitySwitch = "switch"
+	activityEdit   = "edit"
+)
+
+// parseSince parses a --since window: anything time.ParseDuration accepts,
+// plus whole days and weeks such as "1d" or "2w".
+func parseSince(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid --since %q: expected e.g. 24h, 1d or 2w", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid --since %q: expected e.g. 24h, 1d or 2w", s)
+	}
+	return d, nil
+}
+
+// lastCommitTime returns the committer date of HEAD in the worktree at path.
+func lastCommitTime(path string) (time.Time, error) {
+	output, err := gitIn(path, "log", "-1", "--format=%cI", "HEAD").Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(output)))
+}
+
+// dirtyModTime returns the newest modification time of the changed files in
+// the worktree at path, or the zero time when it is clean.
+func dirtyModTime(path string) (time.Time, error) {
+	output, err := gitIn(path, "status", "--porcelain", "-z").Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+	var newest time.Time
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		// Renames and copies are followed by their source path.
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+		info, err := os.Stat(filepath.Join(path, filepath.FromSlash(entry[3:])))
+		if err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+// loadActivity fills in the last commit and dirty file times of every
+// worktree, querying them concurrently.
+func loadActivity(infos []worktreeInfo) {
+	var wg sync.WaitGroup
+	for i := range infos {
+		if infos[i].Bare || infos[i].Prunable || infos[i].Offline {
+			continue
+		}
+		wg.Add(1)
+		go func(info *worktreeInfo) {
+			defer wg.Done()
+			if ts, err := lastCommitTime(info.Path); err == nil {
+				info.LastCommitAt = &ts
+			}
+			if ts, err := dirtyModTime(info.Path); err == nil && !ts.IsZero() {
+				info.DirtyModifiedAt = &ts
+			}
+		}(&infos[i])
+	}
+	wg.Wait()
+}
+
+// latestActivity returns the newest of the commit, switch and edit times of
+// info, and which one it is.
+func latestActivity(info worktreeInfo) (time.Time, string) {
+	var latest time.Time
+	source := ""
+	for _, candidate := range []struct {
+		ts     *time.Time
+		source string
+	}{
+		{info.LastCommitAt, activityCommit},
+		{info.LastSwitchedAt, activitySwitch},
+		{info.DirtyModifiedAt, activityEdit},
+	} {
+		if candidate.ts != nil && candidate.ts.After(latest) {
+			latest, source = *candidate.ts, candidate.source
+		}
+	}
+	return latest, source
+}
+
+// filterActiveSince keeps the worktrees with activity at or after cutoff and
+// records their newest activity.
+func filterActiveSince(infos []worktreeInfo, cutoff time.Time) []worktreeInfo {
+	active := []worktreeInfo{}
+	for _, info := range infos {
+		latest, source := latestActivity(info)
+		if source == "" || latest.Before(cutoff) {
+			continue
+		}
+		info.LastActivityAt = &latest
+		info.Activity = source
+		active = append(active, info)
+	}
+	return active
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	tests := map[string]time.Duration{
+		"24h":   24 * time.Hour,
+		"90m":   90 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"1d":    24 * time.Hour,
+		"3d":    72 * time.Hour,
+		"2w":    14 * 24 * time.Hour,
+		"0s":    0,
+	}
+	for input, want := range tests {
+		got, err := parseSince(input)
+		if err != nil || got != want {
+			t.Errorf("parseSince(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "1x", "d", "-1d", "1.5d", "-2h", "yesterday"} {
+		if _, err := parseSince(input); err == nil {
+			t.Errorf("parseSince(%q) should fail", input)
+		}
+	}
+}
+
+func TestFilterActiveSince(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(-d)
+		return &ts
+	}
+	infos := []worktreeInfo{
+		{Path: "/w/old", LastCommitAt: at(72 * time.Hour), LastSwitchedAt: at(48 * time.Hour)},
+		{Path: "/w/committed", LastCommitAt: at(3 * time.Hour), LastSwitchedAt: at(30 * time.Hour)},
+		{Path: "/w/switched", LastCommitAt: at(90 * time.Hour), LastSwitchedAt: at(20 * time.Hour)},
+		{Path: "/w/edited", LastCommitAt: at(90 * time.Hour), DirtyModifiedAt: at(time.Hour)},
+		{Path: "/w/unknown"},
+	}
+
+	got := filterActiveSince(infos, now.Add(-24*time.Hour))
+	want := []struct {
+		path     string
+		activity string
+		at       time.Time
+	}{
+		{"/w/committed", activityCommit, *at(3 * time.Hour)},
+		{"/w/switched", activitySwitch, *at(20 * time.Hour)},
+		{"/w/edited", activityEdit, *at(time.Hour)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("filterActiveSince() returned %d worktrees, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Path != w.path || got[i].Activity != w.activity || !got[i].LastActivityAt.Equal(w.at) {
+			t.Errorf("worktree %d = %s %s %v, want %s %s %v", i, got[i].Path, got[i].Activity, got[i].LastActivityAt, w.path, w.activity, w.at)
+		}
+	}
+	if infos[1].LastActivityAt != nil {
+		t.Error("filterActiveSince() must not modify its input")
+	}
+
+	// The cutoff itself is still inside the window.
+	if got := filterActiveSince(infos[:1], now.Add(-48*time.Hour)); len(got) != 1 {
+		t.Errorf("activity exactly at the cutoff was dropped")
+	}
+}
+
+func TestDirtyModTime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	if ts, err := dirtyModTime(repoDir); err != nil || !ts.IsZero() {
+		t.Fatalf("dirtyModTime() of a clean worktree = %v, %v", ts, err)
+	}
+
+	file := filepath.Join(repoDir, "notes", "todo.txt")
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 3, 9, 17, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(file, want, want); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, repoDir, "add", "-N", "notes/todo.txt")
+	if ts, err := dirtyModTime(repoDir); err != nil || !ts.Equal(want) {
+		t.Errorf("dirtyModTime() = %v, %v; want %v", ts, err, want)
+	}
+}
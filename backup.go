@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Before removing a worktree, git-ignored files matching the preRemoveBackup
+// patterns of the config (IDE settings, local databases, scratch files) are
+// copied to WORKTREE_ROOT/.backups/<repo>/<branch>-<timestamp>/.
+
+// backupsDirName is the directory below WORKTREE_ROOT holding the backups.
+const backupsDirName = ".backups"
+
+// backupTimeLayout is the timestamp suffix of a backup directory.
+const backupTimeLayout = "20060102-150405"
+
+// matchesBackupPattern reports whether the slash-separated relative path
+// file matches pattern. Like in .gitignore, a pattern without a slash
+// matches any path component (*.sql, .idea) and one with a slash matches
+// from the top (data/*.db); either way a matching directory covers
+// everything below it.
+func matchesBackupPattern(file, pattern string) bool {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+	parts := strings.Split(file, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, part := range parts {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 1; i <= len(parts); i++ {
+		if ok, _ := path.Match(pattern, strings.Join(parts[:i], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectBackupFiles returns the files matching any of patterns, in order.
+func selectBackupFiles(files, patterns []string) []string {
+	var selected []string
+	for _, file := range files {
+		for _, pattern := range patterns {
+			if matchesBackupPattern(file, pattern) {
+				selected = append(selected, file)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// backupDirPrefix is the name of the backups of branch without the
+// timestamp; slashes are flattened so all backups of a branch are siblings.
+func backupDirPrefix(branch string) string {
+	return strings.ReplaceAll(branch, "/", "-")
+}
+
+// backupsToPrune returns which of the backup directories names of branch to
+// delete to keep the newest keep. Names of other branches are ignored; keep
+// 0 keeps everything.
+func backupsToPrune(names []string, branch string, keep int) []string {
+	if keep <= 0 {
+		return nil
+	}
+	prefix := backupDirPrefix(branch) + "-"
+	var own []string
+	for _, name := range names {
+		stamp, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		if _, err := time.Parse(backupTimeLayout, stamp); err != nil {
+			continue
+		}
+		own = append(own, name)
+	}
+	if len(own) <= keep {
+		return nil
+	}
+	// The timestamp layout sorts chronologically.
+	sort.Sort(sort.Reverse(sort.StringSlice(own)))
+	return own[keep:]
+}
+
+// ignoredFiles lists the git-ignored files of the worktree at dir, relative
+// and slash-separated.
+func ignoredFiles(dir string) ([]string, error) {
+	output, err := gitIn(dir, "ls-files", "-z", "--others", "--ignored", "--exclude-standard").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ignored files: %w", err)
+	}
+	var files []string
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// copyBackupFile copies src to dst, creating its directory. Symlinks are
+// copied as links.
+func copyBackupFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// backupIgnoredFiles copies the ignored files of the worktree at dir that
+// match patterns into a new backup directory of branch. It returns the
+// directory and the number of files, or "" when nothing matched.
+func backupIgnoredFiles(dir, repo, branch string, patterns []string, now time.Time) (string, int, error) {
+	files, err := backupFiles(dir, patterns)
+	if err != nil || len(files) == 0 {
+		return "", 0, err
+	}
+	backupDir := backupDirFor(repo, branch, now)
+	if err := copyBackup(dir, backupDir, files); err != nil {
+		return backupDir, 0, err
+	}
+	return backupDir, len(files), nil
+}
+
+// backupFiles lists the ignored files of the worktree at dir that match
+// patterns, relative and slash-separated.
+func backupFiles(dir string, patterns []string) ([]string, error) {
+	files, err := ignoredFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return selectBackupFiles(files, patterns), nil
+}
+
+// backupDirFor returns the directory of a backup of branch taken at now.
+func backupDirFor(repo, branch string, now time.Time) string {
+	return filepath.Join(worktreeRoot, backupsDirName, repo, backupDirPrefix(branch)+"-"+now.Format(backupTimeLayout))
+}
+
+// copyBackup copies files of the worktree at dir into backupDir.
+func copyBackup(dir, backupDir string, files []string) error {
+	for _, file := range files {
+		if err := copyBackupFile(filepath.Join(dir, filepath.FromSlash(file)), filepath.Join(backupDir, filepath.FromSlash(file))); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// pruneBackups deletes the oldest backups of branch beyond keep.
+func pruneBackups(repo, branch string, keep int) error {
+	repoDir := filepath.Join(worktreeRoot, backupsDirName, repo)
+	entries, err := os.ReadDir(repoDir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	for _, name := range backupsToPrune(names, branch, keep) {
+		if err := os.RemoveAll(filepath.Join(repoDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
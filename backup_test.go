@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectBackupFiles(t *testing.T) {
+	files := []string{
+		".idea/workspace.xml",
+		".idea/runConfigurations/app.xml",
+		"local.sqlite",
+		"db/dev.sqlite",
+		"scratch/queries.sql",
+		"node_modules/left-pad/index.js",
+		"build/app",
+	}
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{".idea"}, []string{".idea/workspace.xml", ".idea/runConfigurations/app.xml"}},
+		{[]string{".idea/"}, []string{".idea/workspace.xml", ".idea/runConfigurations/app.xml"}},
+		{[]string{"*.sqlite"}, []string{"local.sqlite", "db/dev.sqlite"}},
+		{[]string{"db/*.sqlite"}, []string{"db/dev.sqlite"}},
+		{[]string{"scratch", "*.sqlite"}, []string{"local.sqlite", "db/dev.sqlite", "scratch/queries.sql"}},
+		{[]string{"*.txt", ""}, nil},
+	}
+	for _, tt := range tests {
+		if got := selectBackupFiles(files, tt.patterns); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectBackupFiles(%q) = %q, want %q", tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestBackupsToPrune(t *testing.T) {
+	names := []string{
+		"feature-x-20260301-090000",
+		"feature-x-20260303-090000",
+		"feature-x-20260302-090000",
+		"feature-x-y-20260101-090000", // branch feature/x-y
+		"feature-x-notes",
+		"main-20250101-090000",
+	}
+	if got, want := backupsToPrune(names, "feature/x", 1), []string{"feature-x-20260302-090000", "feature-x-20260301-090000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backupsToPrune(keep 1) = %q, want %q", got, want)
+	}
+	if got := backupsToPrune(names, "feature/x", 3); got != nil {
+		t.Errorf("backupsToPrune(keep 3) = %q, want nothing", got)
+	}
+	if got := backupsToPrune(names, "feature/x", 0); got != nil {
+		t.Errorf("backupsToPrune(keep 0) = %q, want nothing", got)
+	}
+}
+
+func TestBackupIgnoredFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	files := map[string]string{
+		".gitignore":          ".idea/\n*.log\n",
+		".idea/workspace.xml": "<project/>\n",
+		"debug.log":           "noise\n",
+		"notes.txt":           "untracked, not ignored\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(repoDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	originalRoot := worktreeRoot
+	t.Cleanup(func() {
+		worktreeRoot = originalRoot
+	})
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	dir, count, err := backupIgnoredFiles(repoDir, "repo", "feature/x", []string{".idea", "notes.txt"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(worktreeRoot, ".backups", "repo", "feature-x-20260301-090000"); dir != want || count != 1 {
+		t.Errorf("backupIgnoredFiles() = %q, %d; want %q, 1", dir, count, want)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, ".idea", "workspace.xml")); err != nil || string(content) != "<project/>\n" {
+		t.Errorf("backed up .idea/workspace.xml = %q, %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); !os.IsNotExist(err) {
+		t.Error("files that are not ignored should not be backed up")
+	}
+
+	if dir, count, err := backupIgnoredFiles(repoDir, "repo", "feature/x", []string{"*.sqlite"}, now); err != nil || dir != "" || count != 0 {
+		t.Errorf("backupIgnoredFiles() without matches = %q, %d, %v", dir, count, err)
+	}
+}
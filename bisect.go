@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// `wt bisect` runs git bisect in a detached worktree of its own, so the
+// working state of the others is left alone. The active bisect is recorded in
+// the wt-bisect section of the repository config, so `wt bisect --finish`
+// works from any worktree.
+const (
+	bisectPathKey = "wt-bisect.path"
+	bisectGoodKey = "wt-bisect.good"
+	bisectBadKey  = "wt-bisect.bad"
+)
+
+// bisectName is the directory name of the bisect worktree of bad.
+func bisectName(shortBad string) string {
+	return "bisect-" + shortBad
+}
+
+// parseBisectCulprit finds the first bad commit in the output of
+// `git bisect log`, which records it as "# first bad commit: [<sha>] <subject>"
+// once the bisect is done.
+func parseBisectCulprit(log string) (sha, subject string, ok bool) {
+	for _, line := range strings.Split(log, "\n") {
+		rest, found := strings.CutPrefix(strings.TrimSpace(line), "# first bad commit: [")
+		if !found {
+			continue
+		}
+		sha, subject, _ = strings.Cut(rest, "] ")
+		return strings.TrimSuffix(sha, "]"), subject, true
+	}
+	return "", "", false
+}
+
+// activeBisect returns the path of the bisect worktree, or "" when no bisect
+// is running.
+func activeBisect() string {
+	output, err := repoGit("config", "--get", bisectPathKey).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// resolveCommit returns the full hash and the short hash of rev. It is
+// resolved in the current worktree, so HEAD and HEAD~n mean what they mean
+// to git there, not in the main clone.
+func resolveCommit(rev string) (string, string, error) {
+	output, err := gitIn("", "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("'%s' is not a commit", rev)
+	}
+	commit := strings.TrimSpace(string(output))
+	short, _ := gitIn("", "rev-parse", "--short", commit).Output()
+	return commit, strings.TrimSpace(string(short)), nil
+}
+
+// bisectRunCommand is the `git bisect run` command line running command
+// through the shell. The shell exits with the status of command, so git sees
+// the exit codes of the bisect run conventions: 0 good, 125 skip, 1-127 bad,
+// anything else aborts.
+func bisectRunCommand(goos, command string) []string {
+	if goos == "windows" {
+		return []string{"bisect", "run", "cmd", "/C", command}
+	}
+	return []string{"bisect", "run", "sh", "-c", command}
+}
+
+// startBisect creates the bisect worktree at bad, starts git bisect in it
+// and, with command, runs it to the end.
+func startBisect(cmd *cobra.Command, good, bad, command string) error {
+	if path := activeBisect(); path != "" {
+		good, _ := repoGit("config", "--get", bisectGoodKey).Output()
+		bad, _ := repoGit("config", "--get", bisectBadKey).Output()
+		return fmt.Errorf("a bisect of %s..%s is already running in %s; finish it with 'wt bisect --finish'",
+			strings.TrimSpace(string(good)), strings.TrimSpace(string(bad)), displayPath(path))
+	}
+	goodCommit, _, err := resolveCommit(good)
+	if err != nil {
+		return err
+	}
+	badCommit, shortBad, err := resolveCommit(bad)
+	if err != nil {
+		return err
+	}
+	repo, err := getRepoName()
+	if err != nil {
+		return err
+	}
+	if err := checkRepoDirOwner(repo); err != nil {
+		return err
+	}
+	path, err := newManager(repo).CreateDetached(bisectName(shortBad), badCommit)
+	if err != nil {
+		return err
+	}
+	_ = repoGit("config", bisectPathKey, path).Run()
+	_ = repoGit("config", bisectGoodKey, good).Run()
+	_ = repoGit("config", bisectBadKey, bad).Run()
+	infof("✓ Bisect worktree created at: %s\n", displayPath(path))
+
+	start := gitIn(path, "bisect", "start", badCommit, goodCommit)
+	start.Stdout, start.Stderr = os.Stderr, os.Stderr
+	if err := start.Run(); err != nil {
+		return fmt.Errorf("git bisect start failed: %w\nClean up with 'wt bisect --finish'", err)
+	}
+
+	if command == "" {
+		infoln("Mark each commit with 'git bisect good', 'git bisect bad' or 'git bisect skip'")
+		infoln("in the bisect worktree, then run 'wt bisect --finish' to report the culprit and clean up.")
+		printCDMarker(path)
+		return nil
+	}
+
+	// git passes the env of the config on to command.
+	run := gitIn(path, bisectRunCommand(runtime.GOOS, command)...)
+	if err := setWorktreeEnv(run.Cmd, path, false); err != nil {
+		return err
+	}
+	run.Stdout, run.Stderr = os.Stderr, os.Stderr
+	if err := run.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "git bisect run did not finish; inspect %s, then run 'wt bisect --finish'\n", displayPath(path))
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitWithCode(cmd, exitErr.ExitCode())
+		}
+		return err
+	}
+	// git ends its last line without a newline.
+	infoln()
+	reportBisectCulprit(path)
+	infoln("Run 'wt bisect --finish' to remove the bisect worktree.")
+	return nil
+}
+
+// reportBisectCulprit prints the first bad commit of the bisect at path, if
+// it is known yet.
+func reportBisectCulprit(path string) bool {
+	log, _ := gitIn(path, "bisect", "log").Output()
+	sha, subject, ok := parseBisectCulprit(string(log))
+	if ok {
+		infof("✓ First bad commit: %s %s\n", sha, subject)
+	}
+	return ok
+}
+
+// finishBisect reports the culprit of the active bisect, resets it and
+// removes its worktree.
+func finishBisect() error {
+	path := activeBisect()
+	if path == "" {
+		return fmt.Errorf("no bisect is running; start one with 'wt bisect <good> <bad>'")
+	}
+	mainPath, _ := mainWorktreePath()
+	current, err := currentWorktreePath()
+	inBisect := err == nil && sameDir(current, path)
+
+	if _, err := os.Stat(path); err == nil {
+		if !reportBisectCulprit(path) {
+			infoln("The bisect did not find the first bad commit.")
+		}
+		_ = gitIn(path, "bisect", "reset", "--quiet").Run()
+		// The worktree is throwaway: build output and local edits go with it.
+		if output, err := gitIn(mainPath, "worktree", "remove", "--force", path).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to remove %s: %s", path, strings.TrimSpace(string(output)))
+		}
+		infof("✓ Removed bisect worktree: %s\n", displayPath(path))
+	} else {
+		_ = gitIn(mainPath, "worktree", "prune").Run()
+		infof("✓ Bisect worktree %s is already gone\n", displayPath(path))
+	}
+	forgetWorktree(mainPath, path)
+	_ = repoGit("config", "--remove-section", "wt-bisect").Run()
+
+	if inBisect && mainPath != "" {
+		printCDMarker(mainPath)
+	}
+	return nil
+}
+
+var bisectCmd = &cobra.Command{
+	Use:   "bisect (<good> <bad> [--run <cmd>] | --finish)",
+	Short: "Bisect in a dedicated worktree",
+	Long: `Run git bisect in a worktree of its own, leaving your worktrees alone.
+
+'wt bisect <good> <bad>' creates the detached worktree bisect-<short bad>,
+starts 'git bisect start <bad> <good>' in it and switches there. Mark commits
+with 'git bisect good/bad/skip' as usual. With --run, 'git bisect run' runs
+the command through the shell instead: exit 0 marks a commit good, 125 skips
+it, 1-127 mark it bad and anything else aborts; wt exits with the status of
+'git bisect run'.
+
+'wt bisect --finish', from any worktree of the repository, prints the first
+bad commit, resets the bisect and removes the bisect worktree.
+
+Examples:
+  wt bisect v1.4.0 main                    # Bisect by hand
+  wt bisect v1.4.0 main --run 'go test ./pkg/...'
+  wt bisect --finish                       # Report the culprit and clean up`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if finish, _ := cmd.Flags().GetBool("finish"); finish {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		finish, _ := cmd.Flags().GetBool("finish")
+		command, _ := cmd.Flags().GetString("run")
+		if finish {
+			if command != "" {
+				return fmt.Errorf("--run and --finish cannot be combined")
+			}
+			return finishBisect()
+		}
+		return startBisect(cmd, args[0], args[1], command)
+	},
+}
+
+func init() {
+	bisectCmd.Flags().String("run", "", "Run the command through the shell to mark each commit, as 'git bisect run'")
+	bisectCmd.Flags().Bool("finish", false, "Report the first bad commit, reset the bisect and remove its worktree")
+}
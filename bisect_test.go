@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseBisectCulprit(t *testing.T) {
+	log := `git bisect start 'b0985009' 'a1b2001'
+# bad: [b0985009178b3abc86ce191ae0ed806892167803] c5
+git bisect bad b0985009178b3abc86ce191ae0ed806892167803
+# good: [26158d2a4181e0413f5675bdbce6d7a851dcb690] c3
+git bisect good 26158d2a4181e0413f5675bdbce6d7a851dcb690
+# first bad commit: [d9defaba183270c4639e00db29d364840df5a83d] Break the build [ci skip]
+`
+	sha, subject, ok := parseBisectCulprit(log)
+	if !ok || sha != "d9defaba183270c4639e00db29d364840df5a83d" || subject != "Break the build [ci skip]" {
+		t.Errorf("parseBisectCulprit() = %q, %q, %v", sha, subject, ok)
+	}
+	if _, _, ok := parseBisectCulprit("git bisect start 'b0985009' 'a1b2001'\n"); ok {
+		t.Error("parseBisectCulprit() found a culprit in an unfinished bisect")
+	}
+}
+
+func TestResolveCommitFromLinkedWorktree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	linked := filepath.Join(tmpDir, "linked")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feature", linked)
+	runGitCommand(t, linked, "commit", "-q", "--allow-empty", "-m", "only on feature")
+	t.Chdir(linked)
+
+	want := strings.TrimSpace(gitOutput(t, linked, "rev-parse", "HEAD"))
+	for _, rev := range []string{"HEAD", "@"} {
+		commit, short, err := resolveCommit(rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if commit != want || !strings.HasPrefix(want, short) {
+			t.Errorf("resolveCommit(%s) = %s, %s; want the HEAD of the linked worktree, %s", rev, commit, short, want)
+		}
+	}
+	commit, _, err := resolveCommit("HEAD~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main := strings.TrimSpace(gitOutput(t, repoDir, "rev-parse", "HEAD")); commit != main {
+		t.Errorf("resolveCommit(HEAD~1) = %s, want the parent of the linked worktree's HEAD, %s", commit, main)
+	}
+}
+
+func TestBisectRunCommand(t *testing.T) {
+	if got, want := bisectRunCommand("linux", "make test"), []string{"bisect", "run", "sh", "-c", "make test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bisectRunCommand(linux) = %q, want %q", got, want)
+	}
+	if got, want := bisectRunCommand("windows", "make test"), []string{"bisect", "run", "cmd", "/C", "make test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bisectRunCommand(windows) = %q, want %q", got, want)
+	}
+}
+
+func TestE2EBisectRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("the --run command is a POSIX shell command")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	root := filepath.Join(tmpDir, "worktrees")
+	setupTestRepo(t, repoDir)
+	for _, file := range []string{"a", "b", "broken", "c"} {
+		if err := os.WriteFile(filepath.Join(repoDir, file), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGitCommand(t, repoDir, "add", file)
+		runGitCommand(t, repoDir, "commit", "-q", "-m", "Add "+file)
+	}
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+root, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	out, err := wt("bisect", "HEAD~4", "HEAD", "--run", "test ! -e broken")
+	if err != nil {
+		t.Fatalf("wt bisect --run failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "First bad commit:") || !strings.Contains(out, "Add broken") {
+		t.Errorf("wt bisect --run: culprit not reported:\n%s", out)
+	}
+	if out, err := wt("bisect", "HEAD~1", "HEAD"); err == nil || !strings.Contains(out, "already running") {
+		t.Errorf("second wt bisect: err = %v, output:\n%s", err, out)
+	}
+
+	out, err = wt("bisect", "--finish")
+	if err != nil {
+		t.Fatalf("wt bisect --finish failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Add broken") || !strings.Contains(out, "Removed bisect worktree") {
+		t.Errorf("wt bisect --finish output:\n%s", out)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, "test-repo")); len(entries) != 0 {
+		t.Errorf("bisect worktree left behind: %v", entries)
+	}
+	if output, err := exec.Command("git", "-C", repoDir, "config", "--get-regexp", "^wt-bisect").Output(); err == nil {
+		t.Errorf("bisect state left in the config:\n%s", output)
+	}
+
+	if out, err := wt("bisect", "HEAD~4", "HEAD", "--run", "exit 200"); err == nil {
+		t.Errorf("wt bisect --run with an aborting command succeeded:\n%s", out)
+	}
+	if out, err := wt("bisect", "--finish"); err != nil || !strings.Contains(out, "did not find") {
+		t.Errorf("wt bisect --finish after an abort: err = %v, output:\n%s", err, out)
+	}
+}
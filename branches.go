@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// branchRef is a local or remote branch with the date of its last commit.
+type branchRef struct {
+	Ref       string // as accepted by git, e.g. "fork/release/2.3"
+	Branch    string // Ref without the remote, e.g. "release/2.3"
+	Remote    string // empty for local branches
+	Committed time.Time
+}
+
+const branchRefFormat = "%(refname) %(committerdate:unix)"
+
+// parseBranchRefs parses `git for-each-ref --format='%(refname) %(committerdate:unix)'`
+// output, keeping the input order. The remote of a remote-tracking branch is
+// the longest of remotes its name starts with, so branch names keep their
+// slashes; remote HEAD pointers are skipped.
+func parseBranchRefs(output string, remotes []string) []branchRef {
+	var refs []branchRef
+	for _, line := range strings.Split(output, "\n") {
+		refname, date, _ := strings.Cut(strings.TrimSpace(line), " ")
+		var ref branchRef
+		switch {
+		case strings.HasPrefix(refname, "refs/heads/"):
+			ref.Ref = strings.TrimPrefix(refname, "refs/heads/")
+			ref.Branch = ref.Ref
+		case strings.HasPrefix(refname, "refs/remotes/"):
+			ref.Ref = strings.TrimPrefix(refname, "refs/remotes/")
+			ref.Remote, ref.Branch = splitRemoteRef(ref.Ref, remotes)
+			if ref.Branch == "HEAD" {
+				continue
+			}
+		default:
+			continue
+		}
+		if ref.Branch == "" {
+			continue
+		}
+		if seconds, err := strconv.ParseInt(date, 10, 64); err == nil {
+			ref.Committed = time.Unix(seconds, 0)
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// splitRemoteRef splits a remote-tracking ref like "fork/feature/x" into its
+// remote and branch. Unknown remotes are assumed to end at the first slash.
+func splitRemoteRef(ref string, remotes []string) (string, string) {
+	remote := ""
+	for _, r := range remotes {
+		if strings.HasPrefix(ref, r+"/") && len(r) > len(remote) {
+			remote = r
+		}
+	}
+	if remote == "" {
+		remote, _, _ = strings.Cut(ref, "/")
+	}
+	return remote, strings.TrimPrefix(ref, remote+"/")
+}
+
+// listBranchRefs returns the local and remote branches, most recently
+// committed first.
+func listBranchRefs() ([]branchRef, error) {
+	output, err := repoGit("for-each-ref", "--sort=-committerdate",
+		"--format="+branchRefFormat, "refs/heads", "refs/remotes").Output()
+	if err != nil {
+		return nil, err
+	}
+	remotes, _ := repoGit("remote").Output()
+	return parseBranchRefs(string(output), strings.Fields(string(remotes))), nil
+}
+
+// branchEntry is a branch name with where it exists: locally and/or on which
+// remotes.
+type branchEntry struct {
+	Name    string
+	Local   bool
+	Remotes []string
+}
+
+// groupBranches deduplicates refs by branch name, keeping the order of refs.
+func groupBranches(refs []branchRef) []branchEntry {
+	index := make(map[string]int)
+	var entries []branchEntry
+	for _, ref := range refs {
+		i, ok := index[ref.Branch]
+		if !ok {
+			i = len(entries)
+			index[ref.Branch] = i
+			entries = append(entries, branchEntry{Name: ref.Branch})
+		}
+		if ref.Remote == "" {
+			entries[i].Local = true
+		} else {
+			entries[i].Remotes = append(entries[i].Remotes, ref.Remote)
+		}
+	}
+	return entries
+}
+
+// uniqueBranches returns the branch names of refs without duplicates, keeping
+// the order of refs.
+func uniqueBranches(refs []branchRef) []string {
+	branches := []string{}
+	for _, entry := range groupBranches(refs) {
+		branches = append(branches, entry.Name)
+	}
+	return branches
+}
+
+// isBaseLike reports whether branch is a typical base for new work: a
+// main-like branch or a release or hotfix branch.
+func isBaseLike(branch string) bool {
+	switch branch {
+	case "main", "master", "develop", "development", "trunk":
+		return true
+	}
+	for _, prefix := range []string{"release/", "releases/", "release-", "hotfix/", "support/"} {
+		if strings.HasPrefix(branch, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// baseCandidates returns the refs offered by the base picker: defaultBase
+// first, then main-like and release branches by recency. A local branch is
+// preferred over its remote counterparts. When no branch looks like a base,
+// all branches are offered.
+func baseCandidates(refs []branchRef, defaultBase string) []string {
+	pick := func(keep func(branchRef) bool) []string {
+		index := make(map[string]int)
+		var candidates []string
+		for _, ref := range refs {
+			if !keep(ref) {
+				continue
+			}
+			if i, ok := index[ref.Branch]; ok {
+				if ref.Remote == "" {
+					candidates[i] = ref.Ref
+				}
+				continue
+			}
+			index[ref.Branch] = len(candidates)
+			candidates = append(candidates, ref.Ref)
+		}
+		return candidates
+	}
+
+	defaultBranch := defaultBase
+	for _, ref := range refs {
+		if ref.Ref == defaultBase {
+			defaultBranch = ref.Branch
+		}
+	}
+	candidates := pick(func(ref branchRef) bool {
+		return ref.Branch != defaultBranch && isBaseLike(ref.Branch)
+	})
+	if len(candidates) == 0 {
+		candidates = pick(func(ref branchRef) bool { return ref.Branch != defaultBranch })
+	}
+	return append([]string{defaultBase}, candidates...)
+}
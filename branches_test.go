@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBranchRefs(t *testing.T) {
+	output := `refs/heads/feature 1700000300
+refs/remotes/origin/HEAD 1700000200
+refs/remotes/origin/feature 1700000200
+refs/remotes/upstream/release/2.3 1700000100
+refs/remotes/team/fork/feature/x 1700000050
+refs/heads/origin 1700000010
+refs/heads/main 1700000000
+refs/tags/v1 1700000000
+`
+	want := []branchRef{
+		{Ref: "feature", Branch: "feature", Committed: time.Unix(1700000300, 0)},
+		{Ref: "origin/feature", Branch: "feature", Remote: "origin", Committed: time.Unix(1700000200, 0)},
+		{Ref: "upstream/release/2.3", Branch: "release/2.3", Remote: "upstream", Committed: time.Unix(1700000100, 0)},
+		{Ref: "team/fork/feature/x", Branch: "feature/x", Remote: "team/fork", Committed: time.Unix(1700000050, 0)},
+		{Ref: "origin", Branch: "origin", Committed: time.Unix(1700000010, 0)},
+		{Ref: "main", Branch: "main", Committed: time.Unix(1700000000, 0)},
+	}
+	got := parseBranchRefs(output, []string{"origin", "upstream", "team", "team/fork"})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBranchRefs() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSplitRemoteRef(t *testing.T) {
+	tests := []struct {
+		ref, remote, branch string
+	}{
+		{"origin/main", "origin", "main"},
+		{"fork/feature/login", "fork", "feature/login"},
+		{"backup/HEAD", "backup", "HEAD"},
+		{"unknown/bugfix/x", "unknown", "bugfix/x"},
+	}
+	for _, tt := range tests {
+		remote, branch := splitRemoteRef(tt.ref, []string{"origin", "fork", "backup"})
+		if remote != tt.remote || branch != tt.branch {
+			t.Errorf("splitRemoteRef(%q) = %q, %q, want %q, %q", tt.ref, remote, branch, tt.remote, tt.branch)
+		}
+	}
+}
+
+func TestGroupBranches(t *testing.T) {
+	refs := parseBranchRefs(`refs/remotes/fork/feature-x 5
+refs/heads/feature-x 4
+refs/remotes/backup/feature-x 3
+refs/remotes/origin/feature-x 3
+refs/remotes/backup/only-backup 2
+refs/heads/origin 1
+`, []string{"origin", "fork", "backup"})
+	want := []branchEntry{
+		{Name: "feature-x", Local: true, Remotes: []string{"fork", "backup", "origin"}},
+		{Name: "only-backup", Remotes: []string{"backup"}},
+		{Name: "origin", Local: true},
+	}
+	if got := groupBranches(refs); !reflect.DeepEqual(got, want) {
+		t.Errorf("groupBranches() = %+v, want %+v", got, want)
+	}
+	if got, want := uniqueBranches(refs), []string{"feature-x", "only-backup", "origin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueBranches() = %v, want %v", got, want)
+	}
+}
+
+func TestBaseCandidates(t *testing.T) {
+	refs := parseBranchRefs(`refs/heads/feature 6
+refs/remotes/origin/release/2.4 5
+refs/remotes/origin/release/2.3 4
+refs/heads/release/2.3 3
+refs/remotes/origin/develop 2
+refs/heads/main 1
+refs/remotes/origin/main 1
+`, []string{"origin"})
+	tests := []struct {
+		name        string
+		refs        []branchRef
+		defaultBase string
+		want        []string
+	}{
+		{
+			name:        "base-like branches by recency, local preferred",
+			refs:        refs,
+			defaultBase: "main",
+			want:        []string{"main", "origin/release/2.4", "release/2.3", "origin/develop"},
+		},
+		{
+			name:        "remote default base",
+			refs:        refs,
+			defaultBase: "origin/main",
+			want:        []string{"origin/main", "origin/release/2.4", "release/2.3", "origin/develop"},
+		},
+		{
+			name:        "release branch only on a fork",
+			refs:        parseBranchRefs("refs/remotes/fork/release/3.0 2\nrefs/heads/main 1\n", []string{"fork"}),
+			defaultBase: "main",
+			want:        []string{"main", "fork/release/3.0"},
+		},
+		{
+			name:        "no base-like branches offers everything",
+			refs:        parseBranchRefs("refs/heads/feature 2\nrefs/heads/trunk-ish 1\n", nil),
+			defaultBase: "main",
+			want:        []string{"main", "feature", "trunk-ish"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baseCandidates(tt.refs, tt.defaultBase); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("baseCandidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
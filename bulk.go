@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// reviewFilter narrows the open PRs/MRs considered by bulk checkout.
+type reviewFilter struct {
+	Label     string
+	Milestone string
+	Author    string
+}
+
+// bulkResult is the outcome of checking out a single review in bulk mode.
+type bulkResult struct {
+	Number string
+	Title  string
+	Status string
+	Path   string
+	Err    error
+}
+
+// listArgs returns the gh/glab list arguments for the filter, fetching at
+// most limit entries.
+func (f reviewFilter) listArgs(remoteType RemoteType, limit int) []string {
+	var args []string
+	if remoteType == RemoteGitLab {
+		args = []string{"mr", "list", "--per-page", strconv.Itoa(limit)}
+	} else {
+		args = []string{"pr", "list", "--json", "number,title", "--jq", ".[] | \"\\(.number)\\t\\(.title)\"", "--limit", strconv.Itoa(limit)}
+	}
+	if f.Label != "" {
+		args = append(args, "--label", f.Label)
+	}
+	if f.Milestone != "" {
+		args = append(args, "--milestone", f.Milestone)
+	}
+	if f.Author != "" {
+		args = append(args, "--author", f.Author)
+	}
+	return args
+}
+
+func getFilteredReviews(remoteType RemoteType, filter reviewFilter, limit int) ([]string, []string, error) {
+	output, err := runForgeCLI(remoteType, filter.listArgs(remoteType, limit)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if remoteType == RemoteGitLab {
+		numbers, labels := reviewChoices(parseMROutput(string(output)), remoteType)
+		return numbers, labels, nil
+	}
+	numbers, labels := reviewChoices(parsePROutput(string(output)), remoteType)
+	return numbers, labels, nil
+}
+
+// updateReviewWorktree fast-forwards an existing review worktree to the
+// current head of the PR/MR.
+func updateReviewWorktree(path, number string, remoteType RemoteType) error {
+	if err := gitRunner().Run(path, nil, os.Stderr, "fetch", "origin", reviewRefSpec(number, remoteType)); err != nil {
+		return fmt.Errorf("fetch failed: %w", err)
+	}
+	if err := gitRunner().Run(path, os.Stderr, os.Stderr, "merge", "--ff-only", "FETCH_HEAD"); err != nil {
+		return fmt.Errorf("fast-forward failed: %w", err)
+	}
+	return nil
+}
+
+// checkoutReviewsInBulk creates or updates a worktree for every review,
+// continuing past individual failures.
+func checkoutReviewsInBulk(repo string, numbers, labels []string, remoteType RemoteType) []bulkResult {
+	results := make([]bulkResult, 0, len(numbers))
+	for i, number := range numbers {
+		result := bulkResult{Number: number, Title: labels[i]}
+		path, existed, err := addReviewWorktree(repo, number, remoteType, false, nil)
+		switch {
+		case err != nil:
+			result.Status = "failed"
+			result.Err = err
+		case existed:
+			result.Path = path
+			result.Status = "updated"
+			if err := updateReviewWorktree(path, number, remoteType); err != nil {
+				result.Status = "stale"
+				result.Err = err
+			}
+		default:
+			result.Path = path
+			result.Status = "created"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func printBulkSummary(w io.Writer, results []bulkResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REVIEW\tSTATUS\tPATH")
+	for _, r := range results {
+		detail := r.Path
+		if r.Err != nil {
+			detail = strings.TrimSpace(fmt.Sprintf("%s (%v)", r.Path, r.Err))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Title, r.Status, detail)
+	}
+	_ = tw.Flush()
+}
+
+// runBulkReviewCheckout implements `wt pr --all` and `wt mr --all`.
+func runBulkReviewCheckout(cmd *cobra.Command, remoteType RemoteType) error {
+	if offline {
+		return fmt.Errorf("--all lists and fetches open %ss and cannot work with --offline", strings.ToUpper(reviewPrefix(remoteType)))
+	}
+	if err := requireReviewCLI(remoteType); err != nil {
+		return err
+	}
+
+	filter := reviewFilter{}
+	filter.Label, _ = cmd.Flags().GetString("label")
+	filter.Milestone, _ = cmd.Flags().GetString("milestone")
+	filter.Author, _ = cmd.Flags().GetString("author")
+	limit, _ := cmd.Flags().GetInt("max")
+	if limit <= 0 {
+		limit = getConfig().maxBulkCheckouts()
+	}
+
+	repo, err := getRepoName()
+	if err != nil {
+		return err
+	}
+
+	kind := strings.ToUpper(reviewPrefix(remoteType))
+	numbers, labels, err := getFilteredReviews(remoteType, filter, limit+1)
+	if err != nil {
+		return describeForgeError(kind+"s", remoteType, err)
+	}
+	if len(numbers) == 0 {
+		infof("No open %ss match the filter\n", kind)
+		return nil
+	}
+	if len(numbers) > limit {
+		infof("More than %d %ss match; only the first %d will be checked out\n", limit, kind, limit)
+		numbers, labels = numbers[:limit], labels[:limit]
+	}
+
+	if err := confirmAction(cmd, fmt.Sprintf("Check out %d %ss into worktrees", len(numbers), kind), labels...); err != nil {
+		return err
+	}
+
+	results := checkoutReviewsInBulk(repo, numbers, labels, remoteType)
+	printBulkSummary(os.Stderr, results)
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == "failed" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d %s checkouts failed", failed, len(results), kind)
+	}
+	return nil
+}
+
+func addBulkFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all", false, "Check out every open review matching the filters")
+	cmd.Flags().String("label", "", "With --all: only reviews with this label")
+	cmd.Flags().String("milestone", "", "With --all: only reviews in this milestone")
+	cmd.Flags().String("author", "", "With --all: only reviews by this author")
+	cmd.Flags().Int("max", 0, fmt.Sprintf("With --all: maximum number of reviews (default from config, %d)", defaultMaxBulkCheckouts))
+	cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+}
+
+func init() {
+	addBulkFlags(prCmd)
+	addBulkFlags(mrCmd)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReviewFilterListArgs(t *testing.T) {
+	filter := reviewFilter{Label: "needs-qa", Milestone: "v2", Author: "alice"}
+
+	gh := strings.Join(filter.listArgs(RemoteGitHub, 21), " ")
+	for _, want := range []string{"pr list", "--limit 21", "--label needs-qa", "--milestone v2", "--author alice"} {
+		if !strings.Contains(gh, want) {
+			t.Errorf("gh args %q missing %q", gh, want)
+		}
+	}
+
+	glab := strings.Join(filter.listArgs(RemoteGitLab, 5), " ")
+	for _, want := range []string{"mr list", "--per-page 5", "--label needs-qa"} {
+		if !strings.Contains(glab, want) {
+			t.Errorf("glab args %q missing %q", glab, want)
+		}
+	}
+
+	if args := strings.Join((reviewFilter{}).listArgs(RemoteGitHub, 1), " "); strings.Contains(args, "--label") {
+		t.Errorf("empty filter should not add filter flags: %q", args)
+	}
+}
+
+func TestPrintBulkSummary(t *testing.T) {
+	var buf bytes.Buffer
+	printBulkSummary(&buf, []bulkResult{
+		{Number: "1", Title: "#1: Fix login", Status: "created", Path: "/trees/repo/pr-1"},
+		{Number: "2", Title: "#2: Broken", Status: "failed", Err: errors.New("boom")},
+	})
+	out := buf.String()
+	for _, want := range []string{"REVIEW", "#1: Fix login", "created", "/trees/repo/pr-1", "failed", "(boom)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCheckoutReviewsInBulk(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	origin := filepath.Join(tmpDir, "origin")
+	setupTestRepo(t, origin)
+	for _, n := range []string{"1", "2"} {
+		runGitCommand(t, origin, "commit", "--allow-empty", "-m", "review "+n)
+		runGitCommand(t, origin, "update-ref", "refs/pull/"+n+"/head", "HEAD")
+	}
+
+	clone := filepath.Join(tmpDir, "clone")
+	if out, err := exec.Command("git", "clone", "-q", origin, clone).CombinedOutput(); err != nil {
+		t.Fatalf("git clone failed: %v\n%s", err, out)
+	}
+	t.Chdir(clone)
+
+	originalRoot := worktreeRoot
+	t.Cleanup(func() {
+		worktreeRoot = originalRoot
+	})
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+
+	results := checkoutReviewsInBulk("repo", []string{"1", "2", "99"}, []string{"#1", "#2", "#99"}, RemoteGitHub)
+	wantStatus := []string{"created", "created", "failed"}
+	for i, want := range wantStatus {
+		if results[i].Status != want {
+			t.Errorf("review %s status = %q (%v), want %q", results[i].Number, results[i].Status, results[i].Err, want)
+		}
+	}
+
+	// A second run updates the existing worktree instead of failing.
+	runGitCommand(t, origin, "commit", "--allow-empty", "-m", "review 1 update")
+	runGitCommand(t, origin, "update-ref", "refs/pull/1/head", "HEAD")
+	results = checkoutReviewsInBulk("repo", []string{"1"}, []string{"#1"}, RemoteGitHub)
+	if results[0].Status != "updated" {
+		t.Errorf("existing review status = %q (%v), want updated", results[0].Status, results[0].Err)
+	}
+}
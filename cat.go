@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// binarySniffLen is how much of a file isBinary looks at, as git does.
+const binarySniffLen = 8000
+
+// containedPath joins rel to the worktree at root, refusing absolute paths
+// and paths that leave root once cleaned, e.g. ../other/secret.
+func containedPath(root, rel string) (string, error) {
+	if rel == "" {
+		return "", fmt.Errorf("no path given")
+	}
+	if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" || strings.HasPrefix(filepath.ToSlash(rel), "/") {
+		return "", fmt.Errorf("%s is not relative to the worktree", rel)
+	}
+	cleaned := filepath.Clean(rel)
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the worktree", rel)
+	}
+	return filepath.Join(root, cleaned), nil
+}
+
+// isBinary reports whether data, the start of a file, looks binary: like
+// git, a NUL byte in the first binarySniffLen bytes.
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// resolveInWorktree returns the path of rel in the worktree at root and
+// checks that it is a file that does not lead out of the worktree through a
+// symlink.
+func resolveInWorktree(root, rel string) (string, error) {
+	path, err := containedPath(root, rel)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%s does not exist in %s", rel, displayPath(root))
+	}
+	if err != nil {
+		return "", err
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	if inside, err := filepath.Rel(resolvedRoot, resolved); err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s leads outside the worktree", rel)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", rel)
+	}
+	return path, nil
+}
+
+// catFile streams the file at path to w, refusing binary files unless
+// binary is set.
+func catFile(w io.Writer, path string, binary bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, binarySniffLen)
+	head, err := r.Peek(binarySniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if !binary && isBinary(head) {
+		return fmt.Errorf("%s is a binary file; pass --binary to print it anyway", path)
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+var catCmd = &cobra.Command{
+	Use:   "cat <branch> <path>",
+	Short: "Print a file as it is in another worktree",
+	Long: `Print a file as it is in the worktree of a branch, uncommitted edits
+included, without switching to it. The path is relative to the top of the
+worktree and may not lead out of it.
+
+With --compare, the file is compared instead with the same path in the
+current worktree, using 'git diff --no-index'; wt exits with status 1 when
+they differ, like diff. Binary files are refused unless --binary is given.
+
+Examples:
+  wt cat feature-x go.mod                  # go.mod in the feature-x worktree
+  wt cat feature-x internal/api.go --compare  # How it differs from the one here`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		branch, rel := args[0], args[1]
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		wt, err := selectWorktree(worktrees, []string{branch})
+		if err != nil {
+			return err
+		}
+		path, err := resolveInWorktree(wt.Path, rel)
+		if err != nil {
+			return err
+		}
+		binary, _ := cmd.Flags().GetBool("binary")
+
+		if compare, _ := cmd.Flags().GetBool("compare"); !compare {
+			return catFile(os.Stdout, path, binary)
+		}
+		current, err := currentWorktreePath()
+		if err != nil {
+			return err
+		}
+		here, err := resolveInWorktree(current, rel)
+		if err != nil {
+			return err
+		}
+		if !binary {
+			for _, p := range []string{here, path} {
+				if head, err := readHead(p); err == nil && isBinary(head) {
+					return fmt.Errorf("%s is a binary file; pass --binary to compare it anyway", p)
+				}
+			}
+		}
+		diff := gitIn("", "diff", "--no-index", "--", here, path)
+		diff.Stdout = os.Stdout
+		diff.Stderr = os.Stderr
+		err = diff.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return exitWithCode(cmd, 1)
+		}
+		return err
+	},
+}
+
+// readHead returns the first binarySniffLen bytes of the file at path.
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+func init() {
+	catCmd.Flags().Bool("compare", false, "Compare the file with the same path in the current worktree")
+	catCmd.Flags().Bool("binary", false, "Print or compare binary files too")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContainedPath(t *testing.T) {
+	root := filepath.FromSlash("/trees/api/feature-x")
+	tests := []struct {
+		rel  string
+		want string
+	}{
+		{"go.mod", "go.mod"},
+		{"internal/../go.mod", "go.mod"},
+		{"./cmd//wt/main.go", "cmd/wt/main.go"},
+		{"..foo/bar", "..foo/bar"},
+		{"..", ""},
+		{"../feature-y/go.mod", ""},
+		{"internal/../../secret", ""},
+		{"/etc/passwd", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := containedPath(root, filepath.FromSlash(tt.rel))
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("containedPath(%q) = %q, want an error", tt.rel, got)
+			}
+			continue
+		}
+		if want := filepath.Join(root, filepath.FromSlash(tt.want)); err != nil || got != want {
+			t.Errorf("containedPath(%q) = %q, %v; want %q", tt.rel, got, err, want)
+		}
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want bool
+	}{
+		{[]byte("package main\n"), false},
+		{[]byte("héllo wörld\n"), false},
+		{nil, false},
+		{[]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), true},
+		// Like git, only the start of the file counts.
+		{append(bytes.Repeat([]byte("a"), binarySniffLen), 0), false},
+	}
+	for _, tt := range tests {
+		if got := isBinary(tt.data); got != tt.want {
+			t.Errorf("isBinary(%.20q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestCat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	path := filepath.Join(worktreeRoot, "repo", "feature-x")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", path, "feature-x")
+	if err := os.WriteFile(filepath.Join(path, "notes.txt"), []byte("uncommitted\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "logo.png"), []byte("\x89PNG\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "notes.txt"), []byte("uncommitted\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	resolved, err := resolveInWorktree(path, "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := catFile(&out, resolved, false); err != nil || out.String() != "uncommitted\n" {
+		t.Errorf("catFile(notes.txt) = %q, %v", out.String(), err)
+	}
+	resolved, err = resolveInWorktree(path, "logo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := catFile(&out, resolved, false); err == nil || !strings.Contains(err.Error(), "--binary") {
+		t.Errorf("catFile(logo.png) = %v, want a refusal", err)
+	}
+	if err := catFile(&out, resolved, true); err != nil {
+		t.Errorf("catFile(logo.png) with --binary: %v", err)
+	}
+	if _, err := resolveInWorktree(path, "missing.txt"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("resolveInWorktree(missing.txt) = %v", err)
+	}
+
+	// A symlink out of the worktree is refused like ../.
+	if err := os.Symlink(filepath.Join(repoDir, "notes.txt"), filepath.Join(path, "escape.txt")); err == nil {
+		if _, err := resolveInWorktree(path, "escape.txt"); err == nil || !strings.Contains(err.Error(), "outside") {
+			t.Errorf("resolveInWorktree(escape.txt) = %v, want it refused", err)
+		}
+	}
+
+	if err := catCmd.Flags().Set("compare", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = catCmd.Flags().Set("compare", "false") })
+	if err := catCmd.RunE(catCmd, []string{"feature-x", "notes.txt"}); err != nil {
+		t.Errorf("cat --compare of equal files = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "notes.txt"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var exitErr *exitCodeError
+	if err := catCmd.RunE(catCmd, []string{"feature-x", "notes.txt"}); !errors.As(err, &exitErr) || exitErr.code != 1 {
+		t.Errorf("cat --compare of different files = %v, want exit code 1", err)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// A cleanup plan lists what a cleanup command is about to do. The dry-run
+// preview, its JSON and the cleanup itself all use the same plan, and a plan
+// saved with --dry-run --json can be applied later with
+// `wt cleanup --plan-file plan.json --apply`, e.g. reviewed in a PR and
+// applied in CI on a shared build machine. So that a saved plan is never
+// applied to a repository it no longer describes, it records a hash of the
+// worktree list.
+
+// cleanupPlanVersion is the version of the cleanup plan schema. Fields are
+// only ever added within a version; renaming or removing one bumps it.
+const cleanupPlanVersion = 1
+
+// Actions of a cleanup plan.
+const (
+	cleanupPrune = "prune"
+	cleanupKeep  = "keep"
+)
+
+// cleanupPlan is what a cleanup command does in one repository.
+type cleanupPlan struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// Command is the cleanup command the plan is for, e.g. "prune".
+	Command string `json:"command"`
+	// Repository is the main worktree, and WorktreesHash the hash of the
+	// worktree list the plan was made from.
+	Repository    string          `json:"repository"`
+	WorktreesHash string          `json:"worktreesHash"`
+	Actions       []cleanupAction `json:"actions"`
+}
+
+// cleanupAction is one step of a cleanup plan.
+type cleanupAction struct {
+	Action string `json:"action"`
+	// Kind is what the action is about; only "worktree" so far.
+	Kind   string `json:"kind"`
+	Branch string `json:"branch,omitempty"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+	// ReclaimedBytes estimates the disk space the action frees.
+	ReclaimedBytes int64 `json:"reclaimedBytes"`
+
+	pinned  bool
+	offline bool
+}
+
+// worktreesHash hashes the worktrees as a cleanup sees them: paths, branches,
+// commits and whether git considers them prunable. Locks are left out, as wt
+// itself locks and unlocks worktrees on offline volumes.
+func worktreesHash(worktrees []Worktree) string {
+	h := sha256.New()
+	for _, wt := range worktrees {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%t\n", wt.Path, wt.Branch, wt.Head, wt.Prunable)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// worktreeAdminDirs maps the paths of the worktrees of the repository to
+// their administrative directories below <common git dir>/worktrees.
+func worktreeAdminDirs() map[string]string {
+	dirs := map[string]string{}
+	commonDir, err := repoCommonDir()
+	if err != nil {
+		return dirs
+	}
+	entries, _ := os.ReadDir(filepath.Join(commonDir, "worktrees"))
+	for _, entry := range entries {
+		admin := filepath.Join(commonDir, "worktrees", entry.Name())
+		gitdir, err := os.ReadFile(filepath.Join(admin, "gitdir"))
+		if err != nil {
+			continue
+		}
+		dirs[filepath.Clean(filepath.Dir(strings.TrimSpace(string(gitdir))))] = admin
+	}
+	return dirs
+}
+
+// adminDirSize estimates what pruning the worktree at path frees: its
+// administrative directory; the worktree itself is gone or on a volume that
+// is not mounted.
+func adminDirSize(adminDirs map[string]string, path string) int64 {
+	admin, ok := adminDirs[filepath.Clean(path)]
+	if !ok {
+		return 0
+	}
+	scan, err := scanTree(context.Background(), admin, 0, nil)
+	if err != nil {
+		return 0
+	}
+	return scan.Size
+}
+
+// cleanupWorktrees lists the worktrees after locking the ones on offline
+// volumes, which makes git stop calling them prunable.
+func cleanupWorktrees() ([]Worktree, map[string]bool, error) {
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		return nil, nil, err
+	}
+	offline := findOfflineWorktrees(worktrees)
+	worktrees, err = listWorktrees("")
+	return worktrees, offline, err
+}
+
+// planPrune plans `wt prune`: the stale worktrees are pruned, except pinned
+// ones, and worktrees on offline volumes only with includeOffline.
+func planPrune(includeOffline bool) (cleanupPlan, error) {
+	worktrees, offline, err := cleanupWorktrees()
+	if err != nil {
+		return cleanupPlan{}, err
+	}
+	plan := cleanupPlan{
+		SchemaVersion: cleanupPlanVersion,
+		Command:       "prune",
+		WorktreesHash: worktreesHash(worktrees),
+		Actions:       []cleanupAction{},
+	}
+	if len(worktrees) > 0 {
+		plan.Repository = worktrees[0].Path
+	}
+	pinned := loadPinnedBranches()
+	adminDirs := worktreeAdminDirs()
+	for _, wt := range worktrees {
+		action := cleanupAction{Action: cleanupPrune, Kind: "worktree", Branch: wt.Branch, Path: wt.Path}
+		switch {
+		case offline[wt.Path] && includeOffline:
+			action.Reason = "on a volume that is not mounted (--include-offline)"
+			action.offline = true
+		case offline[wt.Path]:
+			action.Action, action.Reason = cleanupKeep, "on a volume that is not mounted; pass --include-offline to prune it"
+			action.offline = true
+		case !wt.Prunable:
+			continue
+		case wt.Branch != "" && pinned[wt.Branch]:
+			action.Action, action.Reason = cleanupKeep, "pinned (see 'wt unpin')"
+			action.pinned = true
+		default:
+			action.Reason = "its directory is gone"
+		}
+		if action.Action == cleanupPrune {
+			action.ReclaimedBytes = adminDirSize(adminDirs, wt.Path)
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+	return plan, nil
+}
+
+// skipped counts the pinned and the offline worktrees the plan keeps.
+func (p cleanupPlan) skipped() (pinned, offline int) {
+	for _, a := range p.Actions {
+		if a.Action != cleanupKeep {
+			continue
+		}
+		if a.pinned {
+			pinned++
+		}
+		if a.offline {
+			offline++
+		}
+	}
+	return pinned, offline
+}
+
+// reclaimedBytes sums the estimates of the actions that are carried out.
+func (p cleanupPlan) reclaimedBytes() int64 {
+	var total int64
+	for _, a := range p.Actions {
+		if a.Action != cleanupKeep {
+			total += a.ReclaimedBytes
+		}
+	}
+	return total
+}
+
+// lines describes the plan, one action per line, with paths as wt prints them.
+func (p cleanupPlan) lines() []string {
+	var lines []string
+	for _, a := range p.Actions {
+		name := a.Branch
+		if name == "" {
+			name = "(detached)"
+		}
+		verb := "Prune"
+		if a.Action == cleanupKeep {
+			verb = "Keep"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s: %s", verb, name, displayPath(a.Path), a.Reason))
+	}
+	if len(lines) == 0 {
+		return []string{"Nothing to " + p.Command}
+	}
+	return append(lines, "Reclaims about "+formatBytes(p.reclaimedBytes()))
+}
+
+// printCleanupPlan prints the preview of a cleanup command's --dry-run.
+func printCleanupPlan(w io.Writer, p cleanupPlan) {
+	for _, line := range p.lines() {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w, "Dry run: nothing was changed.")
+}
+
+// checkCleanupPlan refuses a saved plan that is for another schema, another
+// repository, or a worktree list that has changed since.
+func checkCleanupPlan(p cleanupPlan) error {
+	if p.SchemaVersion != cleanupPlanVersion {
+		return fmt.Errorf("the plan has schema version %d; this wt applies version %d", p.SchemaVersion, cleanupPlanVersion)
+	}
+	if p.Command != "prune" {
+		return fmt.Errorf("the plan is for '%s', which wt cannot apply", p.Command)
+	}
+	worktrees, _, err := cleanupWorktrees()
+	if err != nil {
+		return err
+	}
+	if len(worktrees) == 0 || !sameDir(worktrees[0].Path, p.Repository) {
+		return fmt.Errorf("the plan is for the repository at %s, not this one", displayPath(p.Repository))
+	}
+	if worktreesHash(worktrees) != p.WorktreesHash {
+		return fmt.Errorf("the worktrees changed since the plan was made; make a new plan")
+	}
+	return nil
+}
+
+// applyCleanupPlan carries out a plan of `wt prune`: the worktrees to prune
+// on offline volumes are unlocked, every other stale worktree is locked for
+// the duration, and `git worktree prune` drops exactly the planned ones.
+func applyCleanupPlan(p cleanupPlan) error {
+	prune := map[string]bool{}
+	for _, a := range p.Actions {
+		if a.Action == cleanupPrune {
+			prune[filepath.Clean(a.Path)] = true
+		}
+	}
+	if len(prune) == 0 {
+		return nil
+	}
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		return err
+	}
+	unlockOffline(worktrees, prune)
+	if worktrees, err = listWorktrees(""); err != nil {
+		return err
+	}
+	var shielded []string
+	for _, wt := range worktrees {
+		if !wt.Prunable || wt.Locked || prune[filepath.Clean(wt.Path)] {
+			continue
+		}
+		if err := repoGit("worktree", "lock", "--reason", pinLockReason, wt.Path).Run(); err != nil {
+			return fmt.Errorf("failed to protect worktree %s: %w", wt.Path, err)
+		}
+		shielded = append(shielded, wt.Path)
+	}
+	err = gitRunner().Run("", os.Stderr, os.Stderr, "worktree", "prune")
+	for _, path := range shielded {
+		_ = repoGit("worktree", "unlock", path).Run()
+	}
+	return err
+}
+
+// loadCleanupPlan reads a plan saved with --dry-run --json.
+func loadCleanupPlan(file string) (cleanupPlan, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return cleanupPlan{}, err
+	}
+	var plan cleanupPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return cleanupPlan{}, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return plan, nil
+}
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup --plan-file <plan.json> [--apply]",
+	Short: "Apply a saved cleanup plan",
+	Long: `Show or apply a cleanup plan saved with --dry-run --json, e.g. by
+'wt prune --dry-run --json > plan.json'.
+
+Without --apply the plan is shown and checked. With --apply it is carried
+out as saved: the worktrees it prunes are pruned and the ones it keeps are
+kept. wt refuses a plan made for another repository, or when the worktrees
+changed since it was made (the plan records a hash of the worktree list).
+
+Each action of the plan has an action (prune or keep), the kind of target
+(worktree), the branch, the path, the reason and an estimate of the bytes it
+reclaims.
+
+Examples:
+  wt prune --dry-run --json > plan.json     # Review the plan, e.g. in a PR
+  wt cleanup --plan-file plan.json          # Check that it still applies
+  wt cleanup --plan-file plan.json --apply  # Carry it out, e.g. in CI`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("plan-file")
+		plan, err := loadCleanupPlan(file)
+		if err != nil {
+			return err
+		}
+		if err := checkCleanupPlan(plan); err != nil {
+			return fmt.Errorf("cannot apply %s: %w", file, err)
+		}
+		if apply, _ := cmd.Flags().GetBool("apply"); !apply {
+			for _, line := range plan.lines() {
+				fmt.Println(line)
+			}
+			fmt.Println("The plan still applies; pass --apply to carry it out.")
+			return nil
+		}
+		if err := applyCleanupPlan(plan); err != nil {
+			return err
+		}
+		infof("✓ Applied the %s plan from %s\n", plan.Command, file)
+		return nil
+	},
+}
+
+func init() {
+	cleanupCmd.Flags().String("plan-file", "", "The plan saved with --dry-run --json")
+	cleanupCmd.Flags().Bool("apply", false, "Carry the plan out")
+	_ = cleanupCmd.MarkFlagRequired("plan-file")
+}
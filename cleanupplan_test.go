@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCleanupPlanLines(t *testing.T) {
+	plan := cleanupPlan{Command: "prune", Actions: []cleanupAction{
+		{Action: cleanupPrune, Kind: "worktree", Branch: "gone", Path: filepath.FromSlash("/trees/api/gone"), Reason: "its directory is gone", ReclaimedBytes: 2048},
+		{Action: cleanupKeep, Kind: "worktree", Branch: "scratch", Path: filepath.FromSlash("/trees/api/scratch"), Reason: "pinned (see 'wt unpin')", ReclaimedBytes: 512},
+	}}
+	want := []string{
+		"Prune gone " + filepath.FromSlash("/trees/api/gone") + ": its directory is gone",
+		"Keep scratch " + filepath.FromSlash("/trees/api/scratch") + ": pinned (see 'wt unpin')",
+		"Reclaims about 2.0 KiB",
+	}
+	if got := plan.lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("lines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got := (cleanupPlan{Command: "prune"}).lines(); !reflect.DeepEqual(got, []string{"Nothing to prune"}) {
+		t.Errorf("lines() of an empty plan = %q", got)
+	}
+}
+
+func TestWorktreesHash(t *testing.T) {
+	worktrees := []Worktree{{Path: "/src/api", Branch: "main", Head: "abc"}, {Path: "/trees/api/gone", Branch: "gone", Head: "def", Prunable: true}}
+	hash := worktreesHash(worktrees)
+	// wt locks and unlocks offline worktrees itself, so locks do not count.
+	worktrees[0].Locked = true
+	if worktreesHash(worktrees) != hash {
+		t.Error("worktreesHash() changed with a lock")
+	}
+	worktrees[1].Head = "123"
+	if worktreesHash(worktrees) == hash {
+		t.Error("worktreesHash() did not change with a new commit")
+	}
+}
+
+func TestCleanupPlanApply(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	for _, branch := range []string{"scratch", "gone", "later"} {
+		runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", branch, filepath.Join(tmpDir, branch))
+	}
+	runGitCommand(t, repoDir, "config", pinnedKey("scratch"), "true")
+	for _, branch := range []string{"scratch", "gone"} {
+		if err := os.RemoveAll(filepath.Join(tmpDir, branch)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(repoDir)
+
+	plan, err := planPrune(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range plan.Actions {
+		got = append(got, a.Action+" "+a.Branch)
+		if a.Action == cleanupPrune && a.ReclaimedBytes == 0 {
+			t.Errorf("action %+v estimates no reclaimed bytes", a)
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"keep scratch", "prune gone"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planPrune() actions = %q, want %q", got, want)
+	}
+
+	// Save the plan as `wt prune --dry-run --json` does and load it back.
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(tmpDir, "plan.json")
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := loadCleanupPlan(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCleanupPlan(saved); err != nil {
+		t.Fatalf("checkCleanupPlan() = %v, want the plan to still apply", err)
+	}
+
+	// Another worktree going stale changes the worktree list.
+	if err := os.RemoveAll(filepath.Join(tmpDir, "later")); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCleanupPlan(saved); err == nil || !strings.Contains(err.Error(), "changed since the plan was made") {
+		t.Errorf("checkCleanupPlan() after a change = %v, want it refused", err)
+	}
+
+	// Applied as saved, the plan prunes gone only: the pinned worktree and
+	// the one that went stale afterwards stay.
+	if err := applyCleanupPlan(saved); err != nil {
+		t.Fatal(err)
+	}
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var branches []string
+	for _, wt := range worktrees {
+		branches = append(branches, wt.Branch)
+		if wt.Locked {
+			t.Errorf("%s is still locked after applying the plan", wt.Branch)
+		}
+	}
+	sort.Strings(branches)
+	if want := []string{"later", "main", "scratch"}; !reflect.DeepEqual(branches, want) {
+		t.Errorf("worktrees after applying the plan = %q, want %q", branches, want)
+	}
+
+	saved.SchemaVersion = 2
+	if err := checkCleanupPlan(saved); err == nil || !strings.Contains(err.Error(), "schema version 2") {
+		t.Errorf("checkCleanupPlan(version 2) = %v, want it refused", err)
+	}
+}
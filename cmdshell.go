@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Shells accepted by `wt shellenv --shell`.
+const (
+	shellBash       = "bash"
+	shellZsh        = "zsh"
+	shellPowerShell = "powershell"
+	shellPwsh       = "pwsh"
+	shellCmd        = "cmd"
+)
+
+// cmdShimTemplate is the batch wrapper behind the cmd.exe integration. cmd
+// has no functions and cannot tee output, so wt.exe runs with the terminal
+// untouched and writes the directory to switch to into WT_CD_FILE instead.
+// The directory is read back before endlocal and changed after it, so the
+// change outlives the script. %[1]s is the path of the wt executable.
+const cmdShimTemplate = `@echo off
+rem wt wrapper for cmd.exe, written by 'wt shellenv --shell cmd'.
+setlocal
+set "WT_CD_FILE=%%TEMP%%\wt-cd-%%RANDOM%%%%RANDOM%%.txt"
+set "WT_SHELL_INTEGRATION=1"
+"%[1]s" %%*
+set "WT_EXIT=%%ERRORLEVEL%%"
+set "WT_CD="
+if exist "%%WT_CD_FILE%%" (
+    set /p WT_CD=<"%%WT_CD_FILE%%"
+    del "%%WT_CD_FILE%%"
+)
+endlocal & set "WT_CD=%%WT_CD%%" & set "WT_EXIT=%%WT_EXIT%%"
+if "%%WT_EXIT%%"=="0" if defined WT_CD cd /d "%%WT_CD%%"
+set "WT_CD=" & set "WT_EXIT=" & exit /b %%WT_EXIT%%
+`
+
+// cmdShim returns the batch wrapper running the wt executable at exe.
+func cmdShim(exe string) string {
+	return strings.ReplaceAll(fmt.Sprintf(cmdShimTemplate, exe), "\n", "\r\n")
+}
+
+// cmdShimPath is where `wt shellenv --shell cmd` writes the batch wrapper.
+func cmdShimPath() string {
+	return filepath.Join(stateDir(), "wt.cmd")
+}
+
+// cmdIntegration returns the `shellenv --shell cmd` output: a doskey macro
+// routing wt through the wrapper at shim. Macros only apply to commands typed
+// at the prompt; batch files call the wrapper directly.
+func cmdIntegration(shim string) string {
+	lines := []string{
+		"@echo off",
+		"rem wt integration for cmd.exe: auto-cd after checkout/create/pr/mr.",
+		"rem Batch files cannot use the macro; they run: call \"" + shim + "\" <args>",
+		"doskey wt=call \"" + shim + "\" $*",
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// writeCmdShim writes the batch wrapper for the running wt executable and
+// returns its path.
+func writeCmdShim() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	path := cmdShimPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(cmdShim(exe)), 0o755); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// writeCDFile hands the directory to switch to to the cmd.exe wrapper, which
+// cannot read it from wt's output.
+func writeCDFile(path string) {
+	if file := os.Getenv("WT_CD_FILE"); file != "" {
+		_ = os.WriteFile(file, []byte(path), 0o644)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdShim(t *testing.T) {
+	shim := cmdShim(`C:\Tools\wt.exe`)
+	for _, want := range []string{
+		"\"C:\\Tools\\wt.exe\" %*\r\n",
+		`set "WT_CD_FILE=%TEMP%\wt-cd-%RANDOM%%RANDOM%.txt"`,
+		`endlocal & set "WT_CD=%WT_CD%" & set "WT_EXIT=%WT_EXIT%"`,
+		`if "%WT_EXIT%"=="0" if defined WT_CD cd /d "%WT_CD%"`,
+		`exit /b %WT_EXIT%`,
+	} {
+		if !strings.Contains(shim, want) {
+			t.Errorf("shim missing %q:\n%s", want, shim)
+		}
+	}
+	if strings.Contains(strings.ReplaceAll(shim, "\r\n", ""), "\n") {
+		t.Error("shim must use CRLF line endings")
+	}
+
+	integration := cmdIntegration(`C:\Users\me\AppData\Roaming\wt\wt.cmd`)
+	if !strings.Contains(integration, "doskey wt=call \"C:\\Users\\me\\AppData\\Roaming\\wt\\wt.cmd\" $*\r\n") {
+		t.Errorf("integration does not define the doskey macro:\n%s", integration)
+	}
+}
+
+func TestWriteCmdShim(t *testing.T) {
+	t.Setenv("WT_STATE_DIR", filepath.Join(t.TempDir(), "state"))
+	path, err := writeCmdShim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != cmdShimPath() {
+		t.Errorf("writeCmdShim() = %q, want %q", path, cmdShimPath())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, _ := os.Executable()
+	if !strings.Contains(string(data), `"`+exe+`" %*`) {
+		t.Errorf("shim does not run %s:\n%s", exe, data)
+	}
+}
+
+func TestPrintCDMarkerWritesCDFile(t *testing.T) {
+	t.Setenv("WT_STATE_DIR", t.TempDir())
+	t.Setenv("WT_SHELL_INTEGRATION", "1")
+	cdFile := filepath.Join(t.TempDir(), "cd.txt")
+	t.Setenv("WT_CD_FILE", cdFile)
+
+	dir := t.TempDir()
+	printCDMarker(dir + string(filepath.Separator) + "." + string(filepath.Separator))
+	data, err := os.ReadFile(cdFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != filepath.Clean(dir) {
+		t.Errorf("cd file = %q, want the cleaned path %q", data, filepath.Clean(dir))
+	}
+}
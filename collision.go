@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Worktrees live in WORKTREE_ROOT/<repo>, where <repo> is only the name of
+// the repository: github.com/a/api and github.com/b/api share a directory.
+// Before creating a worktree, wt checks that the directory holds no worktrees
+// of another repository, rather than failing later with git errors about
+// existing paths.
+
+// worktreeCommonDir returns the common git dir of the checkout at path, read
+// from its .git file (a linked worktree) or directory (a main clone).
+func worktreeCommonDir(path string) (string, error) {
+	dotGit := filepath.Join(path, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+	content, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("%s: not a gitdir file", dotGit)
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(path, gitDir)
+	}
+	// A linked worktree's git dir, .git/worktrees/<name>, names the common
+	// dir in its commondir file.
+	commonDir := filepath.Join(gitDir, "..", "..")
+	if content, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(content))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	return filepath.Clean(commonDir), nil
+}
+
+// sameDir reports whether a and b are the same directory, following
+// symlinks.
+func sameDir(a, b string) bool {
+	return resolvePath(a) == resolvePath(b)
+}
+
+// foreignWorktree returns the first checkout below repoDir that belongs to a
+// repository other than commonDir, together with that repository's common
+// git dir. Branches with slashes nest worktrees, so directories without a
+// .git are searched further.
+func foreignWorktree(repoDir, commonDir string) (string, string, error) {
+	var path, other string
+	errFound := errors.New("found")
+	err := filepath.WalkDir(repoDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == repoDir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return nil
+		}
+		if !d.IsDir() || p == repoDir {
+			return nil
+		}
+		if _, err := os.Lstat(filepath.Join(p, ".git")); err != nil {
+			return nil
+		}
+		dir, err := worktreeCommonDir(p)
+		if err == nil && !sameDir(dir, commonDir) {
+			path, other = p, dir
+			return errFound
+		}
+		return fs.SkipDir
+	})
+	if err != nil && err != errFound {
+		return "", "", err
+	}
+	return path, other, nil
+}
+
+// checkRepoDirOwner fails when WORKTREE_ROOT/<repo> already holds worktrees
+// of another repository with the same name.
+func checkRepoDirOwner(repo string) error {
+	commonDir, err := repoCommonDir()
+	if err != nil {
+		return err
+	}
+	repoDir := filepath.Join(worktreeRoot, repo)
+	path, other, err := foreignWorktree(repoDir, commonDir)
+	if err != nil || path == "" {
+		return err
+	}
+	return fmt.Errorf("%s already holds worktrees of another repository named %s:\n"+
+		"  %s belongs to %s, not to %s\n"+
+		"Worktrees of both would end up mixed in one directory. Keep them apart by\n"+
+		"using another WORKTREE_ROOT for one of the repositories and running\n"+
+		"'wt move --all' there to migrate its existing worktrees",
+		repoDir, repo, path, other, commonDir)
+}
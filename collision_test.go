@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestForeignWorktree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping fixture test in short mode")
+	}
+
+	// Two repositories named api, as for github.com/a/api and
+	// github.com/b/api, sharing WORKTREE_ROOT/api.
+	tmpDir := t.TempDir()
+	repoA := filepath.Join(tmpDir, "a", "api")
+	repoB := filepath.Join(tmpDir, "b", "api")
+	setupTestRepo(t, repoA)
+	setupTestRepo(t, repoB)
+	repoDir := filepath.Join(tmpDir, "worktrees", "api")
+	runGitCommand(t, repoA, "worktree", "add", "-q", filepath.Join(repoDir, "feature", "x"), "-b", "feature/x")
+
+	commonA := filepath.Join(repoA, ".git")
+	commonB := filepath.Join(repoB, ".git")
+
+	if path, _, err := foreignWorktree(repoDir, commonA); err != nil || path != "" {
+		t.Errorf("foreignWorktree() for the owning repository = %q, %v; want nothing", path, err)
+	}
+	path, other, err := foreignWorktree(repoDir, commonB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(repoDir, "feature", "x") || !sameDir(other, commonA) {
+		t.Errorf("foreignWorktree() = %q, %q; want the worktree of %s", path, other, commonA)
+	}
+	if path, _, err := foreignWorktree(filepath.Join(tmpDir, "missing"), commonB); err != nil || path != "" {
+		t.Errorf("foreignWorktree() of a missing directory = %q, %v", path, err)
+	}
+
+	originalRoot := worktreeRoot
+	t.Cleanup(func() {
+		worktreeRoot = originalRoot
+	})
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	t.Chdir(repoB)
+	err = checkRepoDirOwner("api")
+	if err == nil {
+		t.Fatal("checkRepoDirOwner() should fail for a directory of another repository")
+	}
+	for _, want := range []string{"another repository named api", "wt move --all"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestWorktreeCommonDirOfMainClone(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := worktreeCommonDir(dir); err != nil || got != filepath.Join(dir, ".git") {
+		t.Errorf("worktreeCommonDir() = %q, %v", got, err)
+	}
+}
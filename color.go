@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// ANSI escape sequences used for colored output.
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+// colorEnabled reports whether output to w may be colored: only terminals get
+// colors, and NO_COLOR (https://no-color.org) or TERM=dumb turn them off.
+func colorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// colorize wraps s in the given escape sequence when color is on.
+func colorize(color bool, code, s string) string {
+	if !color {
+		return s
+	}
+	return code + s + ansiReset
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// What the branch argument of a command completes to.
+const (
+	// completeBranch offers local and remote branches.
+	completeBranch = "branch"
+	// completeWorktreeBranch offers the branches that have a worktree.
+	completeWorktreeBranch = "worktree-branch"
+	// completeParkedBranch offers the branches parked with 'wt park'.
+	completeParkedBranch = "parked-branch"
+)
+
+// argCompletion registers the completion of a command's branch argument.
+type argCompletion struct {
+	cmd  *cobra.Command
+	kind string
+}
+
+// argCompletions is the registry of argument completions; a new command
+// taking a branch needs one line here. The shell integrations complete these
+// commands through `wt __complete`.
+var argCompletions = []argCompletion{
+	{checkoutCmd, completeBranch},
+	{switchCmd, completeWorktreeBranch},
+	{pathCmd, completeWorktreeBranch},
+	{adoptCmd, completeBranch},
+	{removeCmd, completeWorktreeBranch},
+	{infoCmd, completeWorktreeBranch},
+	{catCmd, completeWorktreeBranch},
+	{pinCmd, completeWorktreeBranch},
+	{unpinCmd, completeWorktreeBranch},
+	{parkCmd, completeWorktreeBranch},
+	{moveCmd, completeWorktreeBranch},
+	{unparkCmd, completeParkedBranch},
+}
+
+var completionFuncs = map[string]func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective){
+	completeBranch:         completeBranches,
+	completeWorktreeBranch: completeWorktreeBranches,
+	completeParkedBranch:   completeParkedBranches,
+}
+
+func init() {
+	for _, c := range argCompletions {
+		c.cmd.ValidArgsFunction = completionFuncs[c.kind]
+	}
+}
+
+// completedCommandNames returns the names and aliases of the commands in
+// the registry, for the shell integrations.
+func completedCommandNames() []string {
+	var names []string
+	for _, c := range argCompletions {
+		names = append(names, c.cmd.Name())
+		names = append(names, c.cmd.Aliases...)
+	}
+	return names
+}
+
+// worktreeBranchCompletions returns a completion for the branch of every
+// worktree, described by the worktree's path.
+func worktreeBranchCompletions(worktrees []Worktree) []string {
+	var completions []string
+	for _, wt := range worktrees {
+		if wt.Branch == "" || wt.Bare {
+			continue
+		}
+		completions = append(completions, wt.Branch+"\t"+wt.Path)
+	}
+	return completions
+}
+
+// completeWorktreeBranches offers the branches that have a worktree.
+func completeWorktreeBranches(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return worktreeBranchCompletions(worktrees), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeParkedBranches offers the branches parked with 'wt park', described
+// by the worktree they are parked in.
+func completeParkedBranches(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for path, branch := range loadParkedBranches() {
+		completions = append(completions, branch+"\t"+path)
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Placeholders in the shell integration scripts for the commands of the
+// registry.
+const (
+	posixCompletedCommands      = "__WT_COMPLETED_COMMANDS__"
+	powershellCompletedCommands = "'__WT_COMPLETED_COMMANDS__'"
+)
+
+// expandCompletedCommands fills the registry's commands into a shell
+// integration script: a case pattern for bash and zsh, a list for PowerShell.
+func expandCompletedCommands(script string) string {
+	names := completedCommandNames()
+	script = strings.ReplaceAll(script, powershellCompletedCommands, "'"+strings.Join(names, "', '")+"'")
+	return strings.ReplaceAll(script, posixCompletedCommands, strings.Join(names, "|"))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+func TestWorktreeBranchCompletions(t *testing.T) {
+	porcelain := "worktree /src/api\nHEAD 1111111111111111111111111111111111111111\nbranch refs/heads/main\n\n" +
+		"worktree /trees/api/feature/login\nHEAD 2222222222222222222222222222222222222222\nbranch refs/heads/feature/login\n\n" +
+		"worktree /trees/api/detached\nHEAD 3333333333333333333333333333333333333333\ndetached\n\n" +
+		"worktree /trees/api/pr-7\nHEAD 4444444444444444444444444444444444444444\nbranch refs/heads/pr-7\nprunable gitdir file points to non-existent location\n\n"
+
+	got := worktreeBranchCompletions(worktree.ParsePorcelain(porcelain))
+	want := []string{
+		"main\t/src/api",
+		"feature/login\t/trees/api/feature/login",
+		"pr-7\t/trees/api/pr-7",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("worktreeBranchCompletions() = %q, want %q", got, want)
+	}
+}
+
+func TestArgCompletionRegistry(t *testing.T) {
+	for _, c := range argCompletions {
+		if completionFuncs[c.kind] == nil {
+			t.Errorf("%s: no completion function for kind %q", c.cmd.Name(), c.kind)
+		}
+		if c.cmd.ValidArgsFunction == nil {
+			t.Errorf("%s: completion not registered", c.cmd.Name())
+		}
+	}
+
+	names := completedCommandNames()
+	for _, want := range []string{"checkout", "co", "remove", "rm", "pin", "park", "unpark"} {
+		if !strings.Contains(" "+strings.Join(names, " ")+" ", " "+want+" ") {
+			t.Errorf("completedCommandNames() = %v, missing %s", names, want)
+		}
+	}
+}
+
+func TestShellIntegrationCompletedCommands(t *testing.T) {
+	pattern := strings.Join(completedCommandNames(), "|")
+	for _, powershell := range []bool{false, true} {
+		script := shellIntegration(powershell, "wt")
+		if strings.Contains(script, "__WT_COMPLETED_COMMANDS__") {
+			t.Errorf("shellIntegration(powershell=%v) left the placeholder in", powershell)
+		}
+		if !strings.Contains(script, "__complete") {
+			t.Errorf("shellIntegration(powershell=%v) does not complete through wt __complete", powershell)
+		}
+	}
+	if script := shellIntegration(false, "wt"); strings.Count(script, pattern+")") != 2 {
+		t.Errorf("bash and zsh completion should both match %s", pattern)
+	}
+	if script := shellIntegration(true, "wt"); !strings.Contains(script, "@('checkout', 'co', ") {
+		t.Error("PowerShell completion should list the registry's commands")
+	}
+}
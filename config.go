@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Worktree layouts supported by the `layout` config key.
+const (
+	// layoutClassic keeps the main clone wherever the user cloned it and
+	// places linked worktrees at <root>/<repo>/<branch>.
+	layoutClassic = "classic"
+	// layoutNestedMain places the main clone at <root>/<repo>/<default-branch>
+	// with linked worktrees as its siblings.
+	layoutNestedMain = "nested-main"
+)
+
+// Config holds the user configuration read from config.yaml.
+type Config struct {
+	Layout string `yaml:"layout" desc:"Where worktrees go: classic or nested-main"`
+	// MaxBulkCheckouts caps how many reviews `wt pr --all` checks out at once.
+	MaxBulkCheckouts int `yaml:"maxBulkCheckouts" desc:"Most reviews wt pr --all checks out at once"`
+	// AsciiSlug percent-encodes non-ASCII branch names in worktree paths.
+	AsciiSlug bool `yaml:"asciiSlug" desc:"Percent-encode non-ASCII branch names in paths"`
+	// Picker selects the interactive chooser: builtin, fzf or external.
+	Picker string `yaml:"picker" desc:"Interactive chooser: builtin, fzf or external"`
+	// PickerCommand is the command run for fzf/external pickers; {{.Label}}
+	// expands to the prompt label.
+	PickerCommand string `yaml:"pickerCommand" desc:"Command of the fzf or external picker"`
+	// AskBase makes `wt create` prompt for the base branch when --base is
+	// not given.
+	AskBase bool `yaml:"askBase" desc:"Ask for the base branch of wt create"`
+	// AssumeYes skips the confirmation of destructive commands, as if
+	// --yes were passed.
+	AssumeYes bool `yaml:"assumeYes" desc:"Skip confirmations, as --yes does"`
+	// HideCdHint stops printing a 'cd <path>' line to copy when wt runs
+	// without the shell integration.
+	HideCdHint bool `yaml:"hideCdHint" desc:"Do not print a cd line without the shell integration"`
+	// Hints prints a hint about a next step after some commands, each at
+	// most once a day; false turns them off, as --no-hints does.
+	Hints *bool `yaml:"hints" desc:"Hints about next steps; false turns them off"`
+	// PRTitle is the title `wt publish` gives PRs/MRs, a Go template where
+	// {{.Branch}} and {{.Title}} expand to the branch and the title derived
+	// from it.
+	PRTitle string `yaml:"prTitle" desc:"Title template of wt publish"`
+	// Env sets variables for the programs wt starts in a worktree; values
+	// are Go templates with the variables of .envrc templates and
+	// {{.WT_BRANCH_SLUG}}.
+	Env map[string]string `yaml:"env" desc:"Variables for programs started in a worktree"`
+	// Direnv renders a .envrc template and runs `direnv allow` in new
+	// worktrees.
+	Direnv DirenvConfig `yaml:"direnv"`
+	// RevealCommand replaces the command `wt open --reveal` runs, one
+	// argument per item; {{.Path}} and {{.Dir}} expand to the target.
+	RevealCommand []string `yaml:"revealCommand" desc:"Command of wt open --reveal"`
+	// EditorWaitFlags maps editor programs to the flag `wt open --editor
+	// --wait` adds to make them wait, over the built-in ones.
+	EditorWaitFlags map[string]string `yaml:"editorWaitFlags" desc:"Flags that make editors wait, by program"`
+	// PreRemoveBackup lists patterns of git-ignored files that `wt remove`
+	// copies to WORKTREE_ROOT/.backups before removing a worktree.
+	PreRemoveBackup []string `yaml:"preRemoveBackup" desc:"Ignored files backed up before wt remove"`
+	// KeepBackups is how many backups per branch are kept; 0 keeps all.
+	KeepBackups int `yaml:"keepBackups" desc:"Backups kept per branch; 0 keeps all"`
+	// PathMappings rewrite the paths wt prints, e.g. from the host to a
+	// devcontainer, when MapPaths or --map-paths is set.
+	PathMappings []PathMapping `yaml:"pathMappings" desc:"Rewrites of printed paths, e.g. for a devcontainer"`
+	MapPaths     bool          `yaml:"mapPaths" desc:"Apply pathMappings, as --map-paths does"`
+	// Templates are named bundles of settings for new worktrees, selected
+	// with --template.
+	Templates map[string]WorktreeTemplate `yaml:"templates" desc:"Named settings for new worktrees"`
+	// ProtectedRepos are repositories, by name or owner/name, in which bulk
+	// destructive commands such as `wt rm --others` refuse to run.
+	ProtectedRepos []string `yaml:"protectedRepos" desc:"Repositories bulk removal refuses to run in"`
+}
+
+// defaultMaxBulkCheckouts is used when MaxBulkCheckouts is not configured.
+const defaultMaxBulkCheckouts = 20
+
+var loadedConfig *Config
+
+// configFile returns the path of the user configuration file.
+func configFile() string {
+	if path := os.Getenv("WT_CONFIG"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "wt", "config.yaml")
+	}
+	if runtime.GOOS == "windows" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			return filepath.Join(dir, "wt", "config.yaml")
+		}
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "wt", "config.yaml")
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return &Config{}, err
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return &Config{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, cfg.check(path)
+}
+
+// check reports settings that are well-formed YAML but not valid values.
+func (cfg *Config) check(path string) error {
+	switch cfg.Layout {
+	case "", layoutClassic, layoutNestedMain:
+	default:
+		return fmt.Errorf("invalid layout %q in %s (expected %s or %s)", cfg.Layout, path, layoutClassic, layoutNestedMain)
+	}
+	switch cfg.Picker {
+	case "", pickerBuiltin, pickerFzf:
+	case pickerExternal:
+		if cfg.PickerCommand == "" {
+			return fmt.Errorf("picker %q in %s requires pickerCommand", cfg.Picker, path)
+		}
+	default:
+		return fmt.Errorf("invalid picker %q in %s (expected %s, %s or %s)", cfg.Picker, path, pickerBuiltin, pickerFzf, pickerExternal)
+	}
+	for name, value := range cfg.Env {
+		if _, err := parseEnvTemplate(name, value); err != nil {
+			return fmt.Errorf("%w in %s", err, path)
+		}
+	}
+	for _, m := range cfg.PathMappings {
+		if trimSeparators(m.From) == "" || m.To == "" {
+			return fmt.Errorf("invalid pathMappings entry {from: %q, to: %q} in %s (expected a from below the root and a to)", m.From, m.To, path)
+		}
+	}
+	return nil
+}
+
+// configProblem is a mistake in the config file. Line and Column are 1-based,
+// or 0 when not known; Hint is shown by `wt config` only.
+type configProblem struct {
+	Line, Column int
+	Message      string
+	Hint         string
+}
+
+// format prefixes the problem with its position in path, the way compilers do.
+func (p configProblem) format(path string) string {
+	switch {
+	case p.Line == 0:
+		return fmt.Sprintf("%s: %s", path, p.Message)
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d: %s", path, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", path, p.Line, p.Column, p.Message)
+}
+
+// yamlLine matches the position yaml.v3 puts in front of its messages.
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// lintConfig checks data against the schema of Config. yaml.Unmarshal, which
+// loadConfig uses, silently drops keys it does not know, so a typo such as
+// `pickr:` would go unnoticed; here they are reported with their position,
+// a suggestion and the valid keys. Malformed YAML and values of the wrong
+// type are found by decoding strictly.
+func lintConfig(data []byte) []configProblem {
+	var problems []configProblem
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return append(problems, yamlProblems(err)...)
+	}
+	checkConfigKeys(&doc, reflect.TypeOf(Config{}), "", &problems)
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err := dec.Decode(&Config{})
+	if err == nil || errors.Is(err, io.EOF) {
+		return problems
+	}
+	for _, p := range yamlProblems(err) {
+		// Unknown keys were reported above, with more detail.
+		if !strings.Contains(p.Message, " not found in type ") {
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// yamlProblems splits a yaml.v3 error into one problem per message.
+func yamlProblems(err error) []configProblem {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+	problems := make([]configProblem, 0, len(messages))
+	for _, msg := range messages {
+		p := configProblem{Message: strings.TrimPrefix(msg, "yaml: ")}
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+			p.Message = msg[len(m[0]):]
+		}
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// checkConfigKeys reports the mapping keys in node that t, the type the node
+// decodes into, has no yaml field for. prefix is the dotted path of node.
+func checkConfigKeys(node *yaml.Node, t reflect.Type, prefix string, problems *[]configProblem) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case node.Kind == yaml.DocumentNode || node.Kind == yaml.AliasNode:
+		for _, child := range node.Content {
+			checkConfigKeys(child, t, prefix, problems)
+		}
+		if node.Alias != nil {
+			checkConfigKeys(node.Alias, t, prefix, problems)
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, child := range node.Content {
+			checkConfigKeys(child, t.Elem(), prefix, problems)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			checkConfigKeys(value, t.Elem(), prefix+key.Value+".", problems)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q", prefix+key.Value)
+				if guess := closestKey(key.Value, keys); guess != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", prefix+guess)
+				}
+				hint := "valid keys: "
+				if prefix != "" {
+					hint = fmt.Sprintf("valid keys under %s: ", strings.TrimSuffix(prefix, "."))
+				}
+				*problems = append(*problems, configProblem{
+					Line:    key.Line,
+					Column:  key.Column,
+					Message: msg,
+					Hint:    hint + strings.Join(keys, ", "),
+				})
+				continue
+			}
+			checkConfigKeys(value, field.Type, prefix+key.Value+".", problems)
+		}
+	}
+}
+
+// yamlFields maps the yaml keys of the struct type t to its fields.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// closestKey returns the key in keys that key is most likely a typo of, or
+// "" when none is close.
+func closestKey(key string, keys []string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range keys {
+		if strings.EqualFold(key, candidate) {
+			return candidate
+		}
+		if d := editDistance(strings.ToLower(key), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// validateConfig returns every problem of the config file path with the
+// contents data, formatted for the user, or nothing when it is valid.
+func validateConfig(path string, data []byte) []string {
+	var report []string
+	for _, p := range lintConfig(data) {
+		line := p.format(path)
+		if p.Hint != "" {
+			line += "\n    " + p.Hint
+		}
+		report = append(report, line)
+	}
+	// Unmarshal fills in what it can despite type errors, so invalid values
+	// are found even then.
+	cfg := &Config{}
+	_ = yaml.Unmarshal(data, cfg)
+	if err := cfg.check(path); err != nil {
+		report = append(report, err.Error())
+	}
+	return report
+}
+
+// quietConfig is set for `wt config`, which reports config problems itself.
+var quietConfig bool
+
+// getConfig returns the user configuration, loading it on first use. A broken
+// config file is reported once and the defaults are used instead; unknown keys
+// are reported as warnings. The root command loads it for every command, so
+// mistakes show up early.
+func getConfig() *Config {
+	if loadedConfig == nil {
+		path := configFile()
+		cfg, err := loadConfig(path)
+		switch {
+		case quietConfig:
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		default:
+			// The file loaded, so what is left are keys it ignored.
+			if data, err := os.ReadFile(path); err == nil {
+				for _, p := range lintConfig(data) {
+					fmt.Fprintf(os.Stderr, "warning: %s; run 'wt config validate' for details\n", p.format(path))
+				}
+			}
+		}
+		loadedConfig = cfg
+	}
+	return loadedConfig
+}
+
+func (c *Config) nestedMain() bool {
+	return c.Layout == layoutNestedMain
+}
+
+func (c *Config) maxBulkCheckouts() int {
+	if c.MaxBulkCheckouts > 0 {
+		return c.MaxBulkCheckouts
+	}
+	return defaultMaxBulkCheckouts
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	cfg, err := loadConfig(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cfg.nestedMain() {
+		t.Error("default config should use the classic layout")
+	}
+}
+
+func TestLoadConfigLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("layout: nested-main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if !cfg.nestedMain() {
+		t.Errorf("loadConfig() layout = %q, want nested-main", cfg.Layout)
+	}
+}
+
+func TestLoadConfigInvalidLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("layout: sideways\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Error("loadConfig() should reject an unknown layout")
+	}
+}
+
+func TestLoadConfigPicker(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{name: "fzf", yaml: "picker: fzf\n"},
+		{name: "external with command", yaml: "picker: external\npickerCommand: sk --prompt '{{.Label}}'\n"},
+		{name: "external without command", yaml: "picker: external\n", wantErr: true},
+		{name: "unknown", yaml: "picker: dmenu\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadConfig(path); (err != nil) != tt.wantErr {
+				t.Errorf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAskBase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("askBase: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if !cfg.AskBase {
+		t.Error("loadConfig() did not read askBase")
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{name: "valid", yaml: "layout: classic\ndirenv:\n  allow: true\npathMappings:\n  - from: /a\n    to: /b\n"},
+		{name: "empty", yaml: ""},
+		{name: "typo", yaml: "layout: classic\npickr: fzf\n", want: []string{`2:1: unknown key "pickr" (did you mean "picker"?)`}},
+		{name: "case", yaml: "askbase: true\n", want: []string{`1:1: unknown key "askbase" (did you mean "askBase"?)`}},
+		{name: "no guess", yaml: "hooks: []\n", want: []string{`1:1: unknown key "hooks"`}},
+		{name: "nested", yaml: "direnv:\n  templte: .envrc.wt\n", want: []string{`2:3: unknown key "direnv.templte" (did you mean "direnv.template"?)`}},
+		{name: "list item", yaml: "pathMappings:\n  - from: /a\n    too: /b\n", want: []string{`3:5: unknown key "pathMappings.too" (did you mean "pathMappings.to"?)`}},
+		{name: "template", yaml: "templates:\n  docs:\n    base: develop\n    direnv: {}\n"},
+		{name: "template key", yaml: "templates:\n  docs:\n    bse: develop\n", want: []string{`3:5: unknown key "templates.docs.bse" (did you mean "templates.docs.base"?)`}},
+		{name: "wrong type", yaml: "layout: classic\nkeepBackups: many\n", want: []string{"2: cannot unmarshal !!str `many` into int"}},
+		{name: "syntax", yaml: "layout: [\n", want: []string{"1: did not find expected node content"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range lintConfig([]byte(tt.yaml)) {
+				got = append(got, strings.TrimPrefix(p.format("c"), "c:"))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("lintConfig() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	report := validateConfig("config.yaml", []byte("layout: sideways\ndirenv:\n  alow: true\n"))
+	if len(report) != 2 {
+		t.Fatalf("validateConfig() = %q, want an unknown key and an invalid layout", report)
+	}
+	if !strings.Contains(report[0], "config.yaml:3:3") || !strings.Contains(report[0], "valid keys under direnv: allow, template") {
+		t.Errorf("validateConfig() unknown key = %q, want its position and the valid keys", report[0])
+	}
+	if !strings.Contains(report[1], `invalid layout "sideways"`) {
+		t.Errorf("validateConfig() = %q, want the invalid layout", report[1])
+	}
+	if report := validateConfig("config.yaml", []byte("layout: nested-main\n")); len(report) != 0 {
+		t.Errorf("validateConfig() of a valid file = %q", report)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{{"", "", 0}, {"hooks", "", 5}, {"pickr", "picker", 1}, {"layuot", "layout", 2}, {"mapPaths", "mapPaths", 0}} {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
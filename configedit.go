@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+// editorCommand returns the editor to run, the way git picks one: $VISUAL,
+// then $EDITOR, then vi (notepad on Windows).
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(name); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// runEditor opens path in the editor through the shell, so that an editor
+// with arguments such as `code --wait` works. The editor is not data: its
+// output goes to stderr, as stdout is piped by the shell integration. Tests
+// replace it to simulate editing.
+var runEditor = func(path string) error {
+	editor := editorCommand()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", editor+` "`+path+`"`)
+	} else {
+		cmd = exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
+
+// editConfig opens a copy of the config file at path in the editor and saves
+// it back once it is valid. When it is not, the problems are printed and the
+// user is asked with p whether to edit again or discard the changes; without
+// a terminal to ask on they are discarded. The file is only written when it
+// changed.
+func editConfig(p Prompter, path string) error {
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// Keep the .yaml extension for the editor's syntax highlighting.
+	tmp, err := os.CreateTemp("", "wt-config-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(original)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err := runEditor(tmp.Name()); err != nil {
+			return fmt.Errorf("%w; %s was not changed", err, path)
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, original) {
+			infoln("Config unchanged")
+			return nil
+		}
+		problems := validateConfig(path, edited)
+		if len(problems) == 0 {
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, edited, 0o644); err != nil {
+				return err
+			}
+			infof("✓ Saved %s\n", path)
+			return nil
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		again := false
+		if stdinIsTerminal() {
+			if again, err = p.Confirm("Edit again (no discards the changes)"); err != nil {
+				return err
+			}
+		}
+		if !again {
+			infof("Changes discarded; %s was not changed\n", path)
+			return errCancelled
+		}
+	}
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read, change, edit and validate the config file",
+	Long: `Read, change, edit and validate the config file.
+
+The config file is ` + "`~/.config/wt/config.yaml`" + ` (or $XDG_CONFIG_HOME/wt/config.yaml);
+set WT_CONFIG to use another file. Unknown keys are ignored when wt loads it,
+with a warning on every command.`,
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file in $EDITOR and validate it on save",
+	Long: `Open the config file in $VISUAL or $EDITOR and validate it on save.
+
+The changes are only saved when the file is valid. Otherwise the problems are
+shown with their line and column, and you can edit again or discard them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editConfig(promptFor(cmd), configFile())
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Check the config file for unknown keys and invalid values",
+	Long: `Check the config file, or the given file, for unknown keys and invalid values.
+
+Every problem is printed with its line and column, and wt exits with status 1,
+so dotfiles repositories can check their wt config in CI.`,
+	Example: `  wt config validate
+  wt config validate dotfiles/wt/config.yaml`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configFile()
+		if len(args) > 0 {
+			path = args[0]
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) && len(args) == 0 {
+			infof("No config file at %s; the defaults apply\n", path)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		problems := validateConfig(path, data)
+		if len(problems) == 0 {
+			infof("✓ %s is valid\n", path)
+			return nil
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		return exitWithCode(cmd, 1)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// stubEditor makes runEditor write the next of edits to the file, one per
+// run, and returns how often it ran.
+func stubEditor(t *testing.T, edits ...string) *int {
+	t.Helper()
+	orig := runEditor
+	t.Cleanup(func() { runEditor = orig })
+	runs := 0
+	runEditor = func(path string) error {
+		if runs >= len(edits) {
+			t.Fatalf("editor run %d times, want %d", runs+1, len(edits))
+		}
+		runs++
+		return os.WriteFile(path, []byte(edits[runs-1]), 0o644)
+	}
+	return &runs
+}
+
+func TestEditConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		edits    []string
+		terminal bool
+		answer   bool
+		want     string
+		wantErr  error
+	}{
+		{name: "valid", edits: []string{"layout: nested-main\n"}, want: "layout: nested-main\n"},
+		{name: "unchanged", original: "askBase: true\n", edits: []string{"askBase: true\n"}, want: "askBase: true\n"},
+		{name: "edit again", original: "askBase: true\n", edits: []string{"askbase: false\n", "askBase: false\n"}, terminal: true, answer: true, want: "askBase: false\n"},
+		{name: "discard", original: "askBase: true\n", edits: []string{"layout: sideways\n"}, terminal: true, want: "askBase: true\n", wantErr: errCancelled},
+		{name: "non-interactive", original: "askBase: true\n", edits: []string{"pickr: fzf\n"}, want: "askBase: true\n", wantErr: errCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubConfirm(t, tt.terminal, &Config{})
+			p := &scriptedPrompter{t: t}
+			if tt.terminal {
+				p.answers = []any{tt.answer}
+			}
+			runs := stubEditor(t, tt.edits...)
+			path := filepath.Join(t.TempDir(), "wt", "config.yaml")
+			if tt.original != "" {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.original), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := editConfig(p, path); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("editConfig() error = %v, want %v", err, tt.wantErr)
+			}
+			if *runs != len(tt.edits) {
+				t.Errorf("editor ran %d times, want %d", *runs, len(tt.edits))
+			}
+			data, _ := os.ReadFile(path)
+			if string(data) != tt.want {
+				t.Errorf("config after editing = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
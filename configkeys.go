@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// configKey is a setting of the config file. Name is its dotted key, e.g.
+// direnv.allow, Kind the type of its value: bool, int, string, list or map,
+// and Desc the one-line description from the desc tag of its field.
+type configKey struct {
+	Name string
+	Kind string
+	Desc string
+}
+
+// scalar reports whether the value of k is a single value, which `wt config
+// set` can change; lists and maps are edited with `wt config edit`.
+func (k configKey) scalar() bool {
+	return k.Kind != "list" && k.Kind != "map"
+}
+
+// configKeys lists the keys of the struct type t below prefix, sorted. They
+// come from the yaml tags that decode the config, as in checkConfigKeys:
+// nested structs by their dotted keys, lists and maps as a whole. Every
+// field of a key carries a desc tag next to its yaml tag.
+func configKeys(t reflect.Type, prefix string) []configKey {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	fields := yamlFields(t)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var keys []configKey
+	for _, name := range names {
+		ft := fields[name].Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		key := configKey{Name: prefix + name, Kind: ft.Kind().String(), Desc: fields[name].Tag.Get("desc")}
+		switch ft.Kind() {
+		case reflect.Struct:
+			keys = append(keys, configKeys(ft, prefix+name+".")...)
+			continue
+		case reflect.Slice, reflect.Array:
+			key.Kind = "list"
+		case reflect.Map:
+			key.Kind = "map"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			key.Kind = "int"
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// lookupConfigKey returns the config key called name, or an error naming the
+// closest one.
+func lookupConfigKey(name string) (configKey, error) {
+	keys := configKeys(reflect.TypeOf(Config{}), "")
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		if k.Name == name {
+			return k, nil
+		}
+		names[i] = k.Name
+	}
+	if guess := closestKey(name, names); guess != "" {
+		return configKey{}, fmt.Errorf("unknown config key %q (did you mean %q?)", name, guess)
+	}
+	return configKey{}, fmt.Errorf("unknown config key %q", name)
+}
+
+// readConfigDocument parses the config file at path into a YAML document,
+// comments included. A missing or empty file gives an empty mapping.
+func readConfigDocument(path string) (*yaml.Node, error) {
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return doc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var parsed yaml.Node
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(parsed.Content) == 0 {
+		return doc, nil
+	}
+	if parsed.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a mapping of keys to values", path)
+	}
+	return &parsed, nil
+}
+
+// configValue returns the node of the dotted key in doc, or nil when the
+// key is not set.
+func configValue(doc *yaml.Node, key string) *yaml.Node {
+	node := doc.Content[0]
+	for _, part := range strings.Split(key, ".") {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == part {
+				next = node.Content[i+1]
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// setConfigValue sets the dotted key in doc to the scalar value, creating the
+// mappings above it.
+func setConfigValue(doc *yaml.Node, k configKey, value string) error {
+	scalar := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	switch k.Kind {
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s takes true or false, not %q", k.Name, value)
+		}
+		scalar.Value = strconv.FormatBool(b)
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s takes a number, not %q", k.Name, value)
+		}
+	default:
+		// Keep values such as "yes" or "1" strings.
+		scalar.Tag = "!!str"
+	}
+
+	node := doc.Content[0]
+	parts := strings.Split(k.Name, ".")
+	for i, part := range parts {
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == part {
+				next = node.Content[j+1]
+				if i == len(parts)-1 {
+					// Keep the comments around the old value.
+					scalar.HeadComment, scalar.LineComment, scalar.FootComment = next.HeadComment, next.LineComment, next.FootComment
+					node.Content[j+1] = scalar
+				}
+			}
+		}
+		if i == len(parts)-1 {
+			if next == nil {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, scalar)
+			}
+			return nil
+		}
+		if next == nil || next.Kind != yaml.MappingNode {
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, next)
+		}
+		node = next
+	}
+	return nil
+}
+
+// encodeConfig formats doc the way config files are written by hand, with
+// two spaces of indentation.
+func encodeConfig(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// completeConfigKeys offers the keys of the config file with their
+// description. `wt config set` only takes scalar keys, and then true or
+// false for a bool.
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	set := cmd == configSetCmd
+	if len(args) == 1 && set {
+		if k, err := lookupConfigKey(args[0]); err == nil && k.Kind == "bool" {
+			return []string{"true", "false"}, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, k := range configKeys(reflect.TypeOf(Config{}), "") {
+		if set && !k.scalar() {
+			continue
+		}
+		completions = append(completions, k.Name+"\t"+k.Desc)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a setting of the config file",
+	Long: `Print the value of a key of the config file. Nested keys are dotted, e.g.
+direnv.allow; lists and maps are printed as YAML. Nothing is printed and wt
+exits with status 1 when the key is not set.`,
+	Example: `  wt config get picker
+  wt config get direnv.template`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := lookupConfigKey(args[0]); err != nil {
+			return err
+		}
+		doc, err := readConfigDocument(configFile())
+		if err != nil {
+			return err
+		}
+		value := configValue(doc, args[0])
+		if value == nil {
+			return exitWithCode(cmd, 1)
+		}
+		if value.Kind == yaml.ScalarNode {
+			fmt.Println(value.Value)
+			return nil
+		}
+		data, err := encodeConfig(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{value}})
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting of the config file",
+	Long: `Set a key of the config file to a value, keeping the rest of the file and its
+comments. Nested keys are dotted, e.g. direnv.allow. Only single values can
+be set this way; edit lists and maps with 'wt config edit'. The file is only
+written when the result is valid.`,
+	Example: `  wt config set picker fzf
+  wt config set direnv.allow true`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		k, err := lookupConfigKey(args[0])
+		if err != nil {
+			return err
+		}
+		if !k.scalar() {
+			return fmt.Errorf("%s is a %s; edit it with 'wt config edit'", k.Name, k.Kind)
+		}
+		path := configFile()
+		doc, err := readConfigDocument(path)
+		if err != nil {
+			return err
+		}
+		if err := setConfigValue(doc, k, args[1]); err != nil {
+			return err
+		}
+		data, err := encodeConfig(doc)
+		if err != nil {
+			return err
+		}
+		if problems := validateConfig(path, data); len(problems) > 0 {
+			return fmt.Errorf("%s was not changed:\n%s", path, strings.Join(problems, "\n"))
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+		infof("✓ Set %s to %s in %s\n", k.Name, args[1], path)
+		return nil
+	},
+}
+
+func init() {
+	// Set here: completeConfigKeys refers to configSetCmd.
+	configGetCmd.ValidArgsFunction = completeConfigKeys
+	configSetCmd.ValidArgsFunction = completeConfigKeys
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+}
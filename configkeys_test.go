@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// runCapturing runs cmd with args and returns what it printed to stdout.
+func runCapturing(t *testing.T, cmd *cobra.Command, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	err = cmd.RunE(cmd, args)
+	os.Stdout = orig
+	w.Close()
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	return buf.String(), err
+}
+
+func TestConfigKeys(t *testing.T) {
+	keys := configKeys(reflect.TypeOf(Config{}), "")
+	kinds := map[string]string{}
+	for _, k := range keys {
+		kinds[k.Name] = k.Kind
+	}
+	for name, want := range map[string]string{
+		"picker":           "string",
+		"assumeYes":        "bool",
+		"maxBulkCheckouts": "int",
+		"layout":           "string",
+		"direnv.allow":     "bool",
+		"direnv.template":  "string",
+		"env":              "map",
+	} {
+		if kinds[name] != want {
+			t.Errorf("configKeys() has %s as %q, want %q", name, kinds[name], want)
+		}
+	}
+	if _, ok := kinds["direnv"]; ok {
+		t.Error("configKeys() should list the keys below direnv, not direnv itself")
+	}
+	for _, k := range keys {
+		if k.Desc == "" {
+			t.Errorf("config key %s has no desc tag", k.Name)
+		}
+	}
+	if !slices.IsSortedFunc(keys, func(a, b configKey) int { return strings.Compare(a.Name, b.Name) }) {
+		t.Errorf("configKeys() = %v, want them sorted", keys)
+	}
+
+	if _, err := lookupConfigKey("pickr"); err == nil || !strings.Contains(err.Error(), `did you mean "picker"`) {
+		t.Errorf("lookupConfigKey(pickr) = %v, want a suggestion", err)
+	}
+}
+
+func TestCompleteConfigKeys(t *testing.T) {
+	picker := "picker\tInteractive chooser: builtin, fzf or external"
+	env := "env\tVariables for programs started in a worktree"
+	got, _ := completeConfigKeys(configGetCmd, nil, "")
+	if !slices.Contains(got, picker) || !slices.Contains(got, env) {
+		t.Errorf("completion of config get = %v, want every key with its description", got)
+	}
+	got, _ = completeConfigKeys(configSetCmd, nil, "")
+	if !slices.Contains(got, "askBase\tAsk for the base branch of wt create") || slices.Contains(got, env) {
+		t.Errorf("completion of config set = %v, want the single-valued keys only", got)
+	}
+	got, _ = completeConfigKeys(configSetCmd, []string{"direnv.allow"}, "")
+	if !slices.Equal(got, []string{"true", "false"}) {
+		t.Errorf("completion of the value of a bool = %v, want true and false", got)
+	}
+}
+
+func TestConfigSetAndGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("WT_CONFIG", path)
+	original := "# my settings\npicker: builtin # the default\nlayout: classic\nenv:\n  FOO: bar\n"
+	if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{{"picker", "fzf"}, {"direnv.allow", "true"}, {"pickerCommand", "1"}} {
+		if err := configSetCmd.RunE(configSetCmd, args); err != nil {
+			t.Fatalf("wt config set %s: %v", strings.Join(args, " "), err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# my settings\npicker: fzf # the default\nlayout: classic\nenv:\n  FOO: bar\ndirenv:\n  allow: true\npickerCommand: \"1\"\n"
+	if string(data) != want {
+		t.Errorf("config after set =\n%s\nwant\n%s", data, want)
+	}
+
+	for _, args := range [][]string{{"layout", "bogus"}, {"askBase", "maybe"}, {"env", "x"}, {"pickr", "fzf"}} {
+		if err := configSetCmd.RunE(configSetCmd, args); err == nil {
+			t.Errorf("wt config set %s should fail", strings.Join(args, " "))
+		}
+	}
+	if after, _ := os.ReadFile(path); string(after) != want {
+		t.Errorf("a failed set changed the config to\n%s", after)
+	}
+
+	output, err := runCapturing(t, configGetCmd, "direnv.allow")
+	if err != nil || output != "true\n" {
+		t.Errorf("wt config get direnv.allow = %q, %v", output, err)
+	}
+	output, err = runCapturing(t, configGetCmd, "env")
+	if err != nil || output != "FOO: bar\n" {
+		t.Errorf("wt config get env = %q, %v", output, err)
+	}
+	if _, err := runCapturing(t, configGetCmd, "assumeYes"); err == nil {
+		t.Error("wt config get of a key that is not set should fail")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var errCancelled = errors.New("cancelled")
+
+// stdinIsTerminal reports whether the user can answer a prompt. Stdout is not
+// checked: the shell integration pipes it through tee.
+var stdinIsTerminal = func() bool {
+	return isTerminal(os.Stdin)
+}
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// confirmAction guards a destructive operation. It prints what will happen
+// and asks y/N, unless --yes or the assumeYes config is set. Without a
+// terminal to ask on, it refuses unless --yes is given.
+func confirmAction(cmd *cobra.Command, question string, plan ...string) error {
+	if assumeYes, _ := cmd.Flags().GetBool("yes"); assumeYes || getConfig().AssumeYes {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("refusing to %s without confirmation in a non-interactive session; pass --yes",
+			strings.ToLower(question[:1])+question[1:])
+	}
+	for _, line := range plan {
+		fmt.Fprintln(cmd.ErrOrStderr(), line)
+	}
+	yes, err := promptFor(cmd).Confirm(question)
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return errCancelled
+	}
+	return nil
+}
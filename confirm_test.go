@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// stubConfirm makes confirmAction see a terminal (or not) and the config cfg.
+func stubConfirm(t *testing.T, terminal bool, cfg *Config) {
+	t.Helper()
+	origTerminal, origConfig := stdinIsTerminal, loadedConfig
+	t.Cleanup(func() {
+		stdinIsTerminal, loadedConfig = origTerminal, origConfig
+	})
+	stdinIsTerminal = func() bool { return terminal }
+	loadedConfig = cfg
+}
+
+// newFlagTestCmd returns a command with the bool flags names, as the code
+// under test reads them, parsed from args.
+func newFlagTestCmd(t *testing.T, names []string, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{Use: "test"}
+	for _, name := range names {
+		cmd.Flags().Bool(name, false, "")
+	}
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestConfirmAction(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		terminal  bool
+		answer    bool
+		cfg       *Config
+		wantErr   string
+		wantAsked bool
+	}{
+		{name: "yes answer", terminal: true, answer: true, cfg: &Config{}, wantAsked: true},
+		{name: "no answer", terminal: true, answer: false, cfg: &Config{}, wantErr: "cancelled", wantAsked: true},
+		{name: "--yes skips the prompt", args: []string{"--yes"}, terminal: true, cfg: &Config{}},
+		{name: "assumeYes skips the prompt", terminal: true, cfg: &Config{AssumeYes: true}},
+		{name: "non-TTY refuses", cfg: &Config{}, wantErr: "refusing to remove worktree foo"},
+		{name: "non-TTY with --yes", args: []string{"--yes"}, cfg: &Config{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubConfirm(t, tt.terminal, tt.cfg)
+			cmd := newFlagTestCmd(t, []string{"yes"}, tt.args...)
+			var stderr bytes.Buffer
+			cmd.SetErr(&stderr)
+			p := script(t, cmd, tt.answer)
+
+			err := confirmAction(cmd, "Remove worktree foo", "This will remove the worktree /trees/foo")
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("confirmAction() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("confirmAction() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := len(p.asked) > 0; got != tt.wantAsked {
+				t.Errorf("asked = %v, want %v", p.asked, tt.wantAsked)
+			}
+			if tt.wantAsked && !strings.Contains(stderr.String(), "/trees/foo") {
+				t.Errorf("plan not shown before asking: %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestConfirmActionCancelled(t *testing.T) {
+	stubConfirm(t, true, &Config{})
+	cmd := newFlagTestCmd(t, []string{"yes"})
+	script(t, cmd, errCancelled)
+	if err := confirmAction(cmd, "Remove worktree foo"); !errors.Is(err, errCancelled) {
+		t.Errorf("confirmAction() error = %v, want errCancelled", err)
+	}
+}
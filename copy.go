@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Git checks a branch out in one worktree at most. For a second checkout of
+// a branch, `wt checkout <branch> --copy-as <branch>@2` creates a copy of the
+// branch at the same commit and upstream, recorded with
+// branch.<copy>.wt-copy-of=<branch> so the copy can be kept in step with the
+// original.
+func copyOfKey(branch string) string {
+	return "branch." + branch + ".wt-copy-of"
+}
+
+// validateCopyName checks that copy names a copy of original:
+// <original>@<n> with n at least 2. Other uses of @ are refused, as git reads
+// @ and <ref>@{...} as revisions.
+func validateCopyName(original, copy string) error {
+	suffix, ok := strings.CutPrefix(copy, original+"@")
+	if !ok {
+		return fmt.Errorf("invalid copy name %q: use %s@<n>, e.g. %s@2", copy, original, original)
+	}
+	n, err := strconv.Atoi(suffix)
+	if err != nil || n < 2 || strconv.Itoa(n) != suffix {
+		return fmt.Errorf("invalid copy name %q: the suffix after @ must be a number of 2 or more, e.g. %s@2", copy, original)
+	}
+	if err := gitCommand("check-ref-format", "--branch", copy).Run(); err != nil {
+		return fmt.Errorf("invalid copy name %q: not a valid branch name", copy)
+	}
+	return nil
+}
+
+// parseBranchCopies parses `git config --get-regexp '^branch\..*\.wt-copy-of$'`
+// into the original branch of every copy.
+func parseBranchCopies(configOutput string) map[string]string {
+	copies := make(map[string]string)
+	for _, line := range strings.Split(configOutput, "\n") {
+		key, original, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || original == "" {
+			continue
+		}
+		branch := strings.TrimSuffix(strings.TrimPrefix(key, "branch."), ".wt-copy-of")
+		if branch != key {
+			copies[branch] = original
+		}
+	}
+	return copies
+}
+
+// loadBranchCopies returns the original branch of every copy in the
+// repository.
+func loadBranchCopies() map[string]string {
+	output, _ := repoGit("config", "--get-regexp", `^branch\..*\.wt-copy-of$`).Output()
+	return parseBranchCopies(string(output))
+}
+
+// copyBranch creates copy at the commit of original, tracking the same
+// upstream, and records the relationship. An original that only exists on
+// origin is copied from there and tracks it.
+func copyBranch(original, copy string) error {
+	start, upstream := original, ""
+	if repoGit("rev-parse", "--verify", "--quiet", "refs/heads/"+original).Run() == nil {
+		output, err := repoGit("rev-parse", "--abbrev-ref", "--symbolic-full-name", original+"@{upstream}").Output()
+		if err == nil {
+			upstream = strings.TrimSpace(string(output))
+		}
+	} else if repoGit("rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+original).Run() == nil {
+		start, upstream = "origin/"+original, "origin/"+original
+	} else {
+		return fmt.Errorf("branch '%s' does not exist", original)
+	}
+	if output, err := repoGit("branch", "--no-track", copy, start).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create %s: %s", copy, strings.TrimSpace(string(output)))
+	}
+	if upstream != "" {
+		if output, err := repoGit("branch", "--set-upstream-to="+upstream, copy).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to set the upstream of %s: %s", copy, strings.TrimSpace(string(output)))
+		}
+	}
+	return repoGit("config", copyOfKey(copy), original).Run()
+}
+
+// prepareCopy makes sure the copy branch exists for `wt checkout --copy-as`:
+// it is created, or reused when it already is a copy of original.
+func prepareCopy(original, copy string) error {
+	if err := validateCopyName(original, copy); err != nil {
+		return err
+	}
+	if !branchExists(copy) {
+		return copyBranch(original, copy)
+	}
+	if existing := loadBranchCopies()[copy]; existing != original {
+		return fmt.Errorf("branch '%s' already exists and is not a copy of %s", copy, original)
+	}
+	return nil
+}
+
+// markCopies fills in the original of every worktree whose branch is a copy.
+func markCopies(infos []worktreeInfo, copies map[string]string) {
+	for i := range infos {
+		if infos[i].Branch != "" {
+			infos[i].CopyOf = copies[infos[i].Branch]
+		}
+	}
+}
+
+// groupCopies moves the worktrees of copies right after the worktree of
+// their original, keeping the order otherwise. Copies whose original has no
+// worktree stay where they are.
+func groupCopies(infos []worktreeInfo) []worktreeInfo {
+	present := make(map[string]bool)
+	for _, info := range infos {
+		if info.Branch != "" {
+			present[info.Branch] = true
+		}
+	}
+	grouped := make([]worktreeInfo, 0, len(infos))
+	for _, info := range infos {
+		if info.CopyOf != "" && present[info.CopyOf] {
+			continue
+		}
+		grouped = append(grouped, info)
+		if info.Branch == "" {
+			continue
+		}
+		for _, c := range infos {
+			if c.CopyOf == info.Branch {
+				grouped = append(grouped, c)
+			}
+		}
+	}
+	return grouped
+}
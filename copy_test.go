@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+func TestValidateCopyName(t *testing.T) {
+	tests := []struct {
+		copy    string
+		wantErr bool
+	}{
+		{"release/2.3@2", false},
+		{"release/2.3@10", false},
+		{"release/2.3@1", true},
+		{"release/2.3@02", true},
+		{"release/2.3@", true},
+		{"release/2.3@x", true},
+		{"release/2.3@{1}", true},
+		{"release/2.3-2", true},
+		{"main@2", true},
+		{"@", true},
+	}
+	for _, tt := range tests {
+		err := validateCopyName("release/2.3", tt.copy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateCopyName(release/2.3, %q) error = %v, wantErr %v", tt.copy, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseBranchCopies(t *testing.T) {
+	output := "branch.release/2.3@2.wt-copy-of release/2.3\nbranch.main@3.wt-copy-of main\nbranch.broken.wt-copy-of\n"
+	want := map[string]string{"release/2.3@2": "release/2.3", "main@3": "main"}
+	if got := parseBranchCopies(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBranchCopies() = %v, want %v", got, want)
+	}
+}
+
+func TestCopiesInListings(t *testing.T) {
+	infos := newWorktreeInfos([]Worktree{
+		{Path: "/src/api", Branch: "main"},
+		{Path: "/trees/api/release/2.3@2", Branch: "release/2.3@2"},
+		{Path: "/trees/api/feature", Branch: "feature"},
+		{Path: "/trees/api/release/2.3", Branch: "release/2.3"},
+		{Path: "/trees/api/orphan@2", Branch: "orphan@2"},
+	})
+	markCopies(infos, map[string]string{"release/2.3@2": "release/2.3", "orphan@2": "orphan"})
+	infos = groupCopies(infos)
+
+	var order []string
+	for _, info := range infos {
+		order = append(order, info.Branch)
+	}
+	want := []string{"main", "feature", "release/2.3", "release/2.3@2", "orphan@2"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("groupCopies() order = %v, want %v", order, want)
+	}
+
+	var table strings.Builder
+	printWorktreeTable(&table, infos)
+	lines := strings.Split(table.String(), "\n")
+	if !strings.HasPrefix(lines[3], "└ /trees/api/release/2.3@2") || !strings.HasSuffix(lines[3], "copy of release/2.3") {
+		t.Errorf("copy is not grouped under its original:\n%s", table.String())
+	}
+	if strings.HasPrefix(lines[4], "└") || !strings.HasSuffix(lines[4], "copy of orphan") {
+		t.Errorf("copy without its original is indented or unmarked:\n%s", table.String())
+	}
+}
+
+func TestE2ECheckoutCopyAs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	originDir := filepath.Join(tmpDir, "origin")
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	root := filepath.Join(tmpDir, "worktrees")
+	setupTestRepo(t, originDir)
+	if err := testrepo.AddBranch(originDir, "release/2.3", "release.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testrepo.Clone(originDir, repoDir); err != nil {
+		t.Fatal(err)
+	}
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+root, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+	git := func(args ...string) string {
+		t.Helper()
+		output, err := exec.Command("git", append([]string{"-C", repoDir}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	if out, err := wt("checkout", "release/2.3"); err != nil {
+		t.Fatalf("wt checkout failed: %v\n%s", err, out)
+	}
+	out, err := wt("checkout", "release/2.3", "--copy-as", "release/2.3@2")
+	if err != nil {
+		t.Fatalf("wt checkout --copy-as failed: %v\n%s", err, out)
+	}
+	// The repository is named after its origin.
+	path := filepath.Join(root, "origin", "release", "2.3@2")
+	if !strings.Contains(out, "TREE_ME_CD:"+path) {
+		t.Errorf("wt checkout --copy-as: output missing %s:\n%s", path, out)
+	}
+	if got, want := git("rev-parse", "release/2.3@2"), git("rev-parse", "release/2.3"); got != want {
+		t.Errorf("copy is at %s, want %s", got, want)
+	}
+	if got := git("rev-parse", "--abbrev-ref", "release/2.3@2@{upstream}"); got != "origin/release/2.3" {
+		t.Errorf("copy tracks %q, want origin/release/2.3", got)
+	}
+	if got := git("config", copyOfKey("release/2.3@2")); got != "release/2.3" {
+		t.Errorf("%s = %q, want release/2.3", copyOfKey("release/2.3@2"), got)
+	}
+
+	list, err := wt("list")
+	if err != nil {
+		t.Fatalf("wt list failed: %v\n%s", err, list)
+	}
+	if !strings.Contains(list, "└ "+path) || !strings.Contains(list, "copy of release/2.3") {
+		t.Errorf("wt list does not group the copy:\n%s", list)
+	}
+
+	if out, err := wt("checkout", "release/2.3", "--copy-as", "release/2.3@{1}"); err == nil || !strings.Contains(out, "invalid copy name") {
+		t.Errorf("wt checkout --copy-as release/2.3@{1}: err = %v, output:\n%s", err, out)
+	}
+	if out, err := wt("checkout", "release/2.3", "--copy-as", "main@2"); err == nil || !strings.Contains(out, "invalid copy name") {
+		t.Errorf("wt checkout --copy-as main@2: err = %v, output:\n%s", err, out)
+	}
+}
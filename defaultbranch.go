@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+// When a repository renames its default branch upstream, e.g. master to
+// main, the local origin/HEAD keeps pointing at the old one and new branches
+// keep starting there. wt compares origin/HEAD with what origin says, at most
+// once a day per clone, and `wt doctor --fix` catches up.
+
+// defaultBranchCheckInterval is how often wt asks origin for its default
+// branch outside `wt doctor`.
+const defaultBranchCheckInterval = 24 * time.Hour
+
+// defaultBranchCheck is the last answer of origin about its default branch.
+// Previous is the branch origin/HEAD pointed at when a rename was noticed, so
+// that the local branch of that name can be renamed too.
+type defaultBranchCheck struct {
+	Branch    string    `json:"branch"`
+	Previous  string    `json:"previous,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// defaultBranchChecks maps the common git dir of clones to their last check.
+type defaultBranchChecks map[string]defaultBranchCheck
+
+func defaultBranchFile() string {
+	return filepath.Join(stateDir(), "default-branch.json")
+}
+
+func loadDefaultBranchChecks() (defaultBranchChecks, error) {
+	checks := defaultBranchChecks{}
+	data, err := os.ReadFile(defaultBranchFile())
+	if os.IsNotExist(err) {
+		return checks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &checks); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", defaultBranchFile(), err)
+	}
+	return checks, nil
+}
+
+func saveDefaultBranchChecks(checks defaultBranchChecks) error {
+	data, err := json.MarshalIndent(checks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir(), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(defaultBranchFile(), data, 0o644)
+}
+
+// updateDefaultBranchCheck applies update to the check of this clone and
+// saves it. The record only saves network round trips, so failures to read
+// or write it are ignored.
+func updateDefaultBranchCheck(update func(*defaultBranchCheck)) {
+	commonDir, err := repoCommonDir()
+	if err != nil {
+		return
+	}
+	checks, err := loadDefaultBranchChecks()
+	if err != nil {
+		checks = defaultBranchChecks{}
+	}
+	c := checks[commonDir]
+	update(&c)
+	checks[commonDir] = c
+	_ = saveDefaultBranchChecks(checks)
+}
+
+// localOriginHead returns the branch origin/HEAD points at, or "" when it is
+// not set.
+func localOriginHead() string {
+	output, err := repoGit("symbolic-ref", "--quiet", "refs/remotes/origin/HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "refs/remotes/origin/")
+}
+
+// fetchRemoteDefaultBranch asks origin for its default branch, giving up
+// after --timeout, and records the answer.
+func fetchRemoteDefaultBranch(now time.Time) (string, error) {
+	ctx := context.Background()
+	if networkTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, networkTimeout)
+		defer cancel()
+	}
+	output, err := gitCommandContext(ctx, "ls-remote", "--symref", "origin", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot ask origin for its default branch: %w", err)
+	}
+	branch := parseSymrefHead(string(output))
+	if branch == "" {
+		return "", fmt.Errorf("origin does not name its default branch")
+	}
+	local := localOriginHead()
+	updateDefaultBranchCheck(func(c *defaultBranchCheck) {
+		c.Branch, c.CheckedAt = branch, now
+		if local != "" && local != branch {
+			c.Previous = local
+		}
+	})
+	return branch, nil
+}
+
+// cachedRemoteDefaultBranch returns the default branch of origin, asking it
+// at most once per defaultBranchCheckInterval.
+func cachedRemoteDefaultBranch(now time.Time) (string, error) {
+	if commonDir, err := repoCommonDir(); err == nil {
+		if checks, err := loadDefaultBranchChecks(); err == nil {
+			if c, ok := checks[commonDir]; ok && c.Branch != "" && now.Sub(c.CheckedAt) < defaultBranchCheckInterval {
+				return c.Branch, nil
+			}
+		}
+	}
+	return fetchRemoteDefaultBranch(now)
+}
+
+// warnStaleDefaultBranch warns when origin/HEAD no longer names the default
+// branch of origin, before a new branch starts from it. Nothing is checked
+// offline or without origin/HEAD, and origin is asked at most once a day.
+func warnStaleDefaultBranch() {
+	if offline {
+		return
+	}
+	local := localOriginHead()
+	if local == "" {
+		return
+	}
+	remote, err := cachedRemoteDefaultBranch(time.Now())
+	if err != nil || remote == local {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: the default branch of origin is now '%s', but origin/HEAD still points at '%s'\n", remote, local)
+	fmt.Fprintf(os.Stderr, "Run 'wt doctor --fix' to switch to '%s'\n", remote)
+}
+
+// checkDefaultBranch compares origin/HEAD with the default branch of origin.
+func checkDefaultBranch() doctorResult {
+	local := localOriginHead()
+	switch {
+	case local == "":
+		return doctorResult{Name: "default branch", Status: doctorOK, Detail: "origin/HEAD is not set"}
+	case offline:
+		return doctorResult{Name: "default branch", Status: doctorOK, Detail: "origin/" + local + " (not checked offline)"}
+	}
+	remote, err := fetchRemoteDefaultBranch(time.Now())
+	if err != nil {
+		return doctorResult{Name: "default branch", Status: doctorWarn, Detail: err.Error()}
+	}
+	if remote != local {
+		return doctorResult{Name: "default branch", Status: doctorWarn, Detail: fmt.Sprintf("origin renamed it to '%s', but origin/HEAD still points at '%s'", remote, local), Fixable: true}
+	}
+	return doctorResult{Name: "default branch", Status: doctorOK, Detail: "origin/" + local + ", as on origin"}
+}
+
+// fixDefaultBranch fetches origin, dropping the branches it deleted, and
+// points origin/HEAD at its default branch.
+func fixDefaultBranch() error {
+	if output, err := repoGit("fetch", "--prune", "origin").CombinedOutput(); err != nil {
+		return fmt.Errorf("git fetch --prune origin: %s", strings.TrimSpace(string(output)))
+	}
+	return fixOriginHead()
+}
+
+// renamedDefaultBranch returns the local branch named after the previous
+// default branch of origin and the branch it should become, when the former
+// exists and the latter does not.
+func renamedDefaultBranch() (string, string, bool) {
+	commonDir, err := repoCommonDir()
+	if err != nil {
+		return "", "", false
+	}
+	checks, err := loadDefaultBranchChecks()
+	if err != nil {
+		return "", "", false
+	}
+	previous, current := checks[commonDir].Previous, localOriginHead()
+	if previous == "" || current == "" || previous == current {
+		return "", "", false
+	}
+	if !commitExists("refs/heads/"+previous) || commitExists("refs/heads/"+current) {
+		return "", "", false
+	}
+	return previous, current, true
+}
+
+// checkLocalDefaultBranch reports a local branch still named after the
+// previous default branch of origin.
+func checkLocalDefaultBranch() doctorResult {
+	previous, current, ok := renamedDefaultBranch()
+	if !ok {
+		return doctorResult{Name: "local default branch", Status: doctorOK, Detail: "up to date"}
+	}
+	return doctorResult{Name: "local default branch", Status: doctorWarn, Detail: fmt.Sprintf("'%s' is still named after the old default branch; rename it to '%s'", previous, current), Fixable: true}
+}
+
+// fixLocalDefaultBranch renames the local branch named after the previous
+// default branch, sets its upstream to the new one and moves a worktree of
+// it to the location of the new name, as `wt move` would.
+func fixLocalDefaultBranch() error {
+	previous, current, ok := renamedDefaultBranch()
+	if !ok {
+		return nil
+	}
+	repo, err := getRepoName()
+	if err != nil {
+		return err
+	}
+	oldPath := newManager(repo).Path(previous)
+	if output, err := repoGit("branch", "-m", previous, current).CombinedOutput(); err != nil {
+		return fmt.Errorf("git branch -m %s %s: %s", previous, current, strings.TrimSpace(string(output)))
+	}
+	updateDefaultBranchCheck(func(c *defaultBranchCheck) { c.Previous = "" })
+	if commitExists("refs/remotes/origin/" + current) {
+		if output, err := repoGit("branch", "--set-upstream-to", "origin/"+current, current).CombinedOutput(); err != nil {
+			return fmt.Errorf("git branch --set-upstream-to origin/%s: %s", current, strings.TrimSpace(string(output)))
+		}
+	}
+
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		return err
+	}
+	for i, wt := range worktrees {
+		// The main clone is not named after its branch.
+		if i == 0 || wt.Branch != current || filepath.Clean(wt.Path) != filepath.Clean(oldPath) {
+			continue
+		}
+		if err := requireGit(capWorktreeMove); err != nil {
+			return err
+		}
+		newPath, err := ensureWorktreePath(repo, current)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(worktree.LongPath(filepath.Dir(newPath)), 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(newPath), err)
+		}
+		if err := gitRunner().Run(worktrees[0].Path, os.Stderr, os.Stderr, "worktree", "move", wt.Path, newPath); err != nil {
+			return fmt.Errorf("failed to move the worktree of %s: %w", current, err)
+		}
+		infof("✓ Moved %s: %s -> %s\n", current, displayPath(wt.Path), displayPath(newPath))
+	}
+	return nil
+}
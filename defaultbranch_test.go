@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+func TestDefaultBranchRenamedUpstream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	origin := filepath.Join(tmpDir, "origin")
+	repoDir := filepath.Join(tmpDir, "repo")
+	if err := testrepo.Init(origin); err != nil {
+		t.Fatal(err)
+	}
+	if err := testrepo.Clone(origin, repoDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WT_STATE_DIR", t.TempDir())
+	t.Chdir(repoDir)
+	originalRoot := worktreeRoot
+	t.Cleanup(func() { worktreeRoot = originalRoot })
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	quietGit = true
+	t.Cleanup(func() { quietGit = false })
+
+	// main gets a worktree of its own, as when the clone is kept on another
+	// branch.
+	runGitCommand(t, repoDir, "switch", "-q", "-c", "work")
+	repo, err := getRepoName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPath, err := newManager(repo).Checkout("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if got, err := cachedRemoteDefaultBranch(now); err != nil || got != "main" {
+		t.Fatalf("cachedRemoteDefaultBranch() = %q, %v; want main", got, err)
+	}
+	if r := checkDefaultBranch(); r.Status != doctorOK {
+		t.Errorf("checkDefaultBranch() before the rename = %+v, want OK", r)
+	}
+
+	// The remote renames main to trunk after the clone.
+	runGitCommand(t, origin, "branch", "-m", "main", "trunk")
+	if got, _ := cachedRemoteDefaultBranch(now.Add(time.Hour)); got != "main" {
+		t.Errorf("cachedRemoteDefaultBranch() within a day = %q, want the recorded main", got)
+	}
+	if got, _ := cachedRemoteDefaultBranch(now.Add(25 * time.Hour)); got != "trunk" {
+		t.Errorf("cachedRemoteDefaultBranch() a day later = %q, want trunk", got)
+	}
+	offline = true
+	if r := checkDefaultBranch(); r.Status != doctorOK || !strings.Contains(r.Detail, "offline") {
+		t.Errorf("checkDefaultBranch() offline = %+v, want it skipped", r)
+	}
+	offline = false
+
+	results, err := runDoctor([]doctorCheck{
+		{Name: "default branch", Check: checkDefaultBranch, Fix: fixDefaultBranch},
+		{Name: "local default branch", Check: checkLocalDefaultBranch, Fix: fixLocalDefaultBranch},
+	}, func(string) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if !r.Fixed || r.Status != doctorOK {
+			t.Errorf("%s after --fix = %+v, want fixed", r.Name, r)
+		}
+	}
+	if got := localOriginHead(); got != "trunk" {
+		t.Errorf("origin/HEAD after the fix = %q, want trunk", got)
+	}
+	if got := strings.TrimSpace(gitOutput(t, repoDir, "rev-parse", "--abbrev-ref", "trunk@{upstream}")); got != "origin/trunk" {
+		t.Errorf("upstream of the renamed branch = %q, want origin/trunk", got)
+	}
+	newPath := newManager(repo).Path("trunk")
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("the worktree is still at %s: %v", oldPath, err)
+	}
+	if got := strings.TrimSpace(gitOutput(t, newPath, "symbolic-ref", "--short", "HEAD")); got != "trunk" {
+		t.Errorf("%s has %q checked out, want trunk", newPath, got)
+	}
+}
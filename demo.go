@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+// demoMarker is created in every demo sandbox so --cleanup only removes
+// directories wt created itself.
+const demoMarker = ".wt-demo"
+
+// demoSandbox describes the directories of a demo sandbox.
+type demoSandbox struct {
+	Dir          string
+	Origin       string
+	Repo         string
+	WorktreeRoot string
+}
+
+func newDemoSandbox(dir string) demoSandbox {
+	return demoSandbox{
+		Dir:          dir,
+		Origin:       filepath.Join(dir, "origin"),
+		Repo:         filepath.Join(dir, "demo"),
+		WorktreeRoot: filepath.Join(dir, "worktrees"),
+	}
+}
+
+// createDemoSandbox seeds an "origin" repository with a few branches, a fake
+// GitHub PR ref and a fake GitLab MR ref, and clones it as the demo repo.
+func createDemoSandbox() (demoSandbox, error) {
+	dir, err := os.MkdirTemp("", "wt-demo-")
+	if err != nil {
+		return demoSandbox{}, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	sb := newDemoSandbox(dir)
+	if err := os.WriteFile(filepath.Join(dir, demoMarker), nil, 0o644); err != nil {
+		return sb, err
+	}
+
+	steps := []func() error{
+		func() error { return testrepo.Init(sb.Origin) },
+		func() error { return testrepo.AddBranch(sb.Origin, "feature/login", "login.txt") },
+		func() error { return testrepo.AddBranch(sb.Origin, "bugfix/typo", "README.md") },
+		func() error { return testrepo.AddReviewRef(sb.Origin, "refs/pull/1/head", "docs/pr.txt") },
+		func() error { return testrepo.AddReviewRef(sb.Origin, "refs/merge-requests/2/head", "docs/mr.txt") },
+		func() error { return testrepo.Clone(sb.Origin, sb.Repo) },
+		func() error { return os.MkdirAll(sb.WorktreeRoot, 0o755) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return sb, err
+		}
+	}
+	return sb, nil
+}
+
+// findDemoSandboxes returns the sandboxes created by previous `wt demo` runs.
+func findDemoSandboxes(tmpDir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(tmpDir, "wt-demo-*"))
+	if err != nil {
+		return nil, err
+	}
+	var sandboxes []string
+	for _, dir := range matches {
+		if _, err := os.Stat(filepath.Join(dir, demoMarker)); err == nil {
+			sandboxes = append(sandboxes, dir)
+		}
+	}
+	return sandboxes, nil
+}
+
+func printDemoInstructions(sb demoSandbox) {
+	infof("✓ Demo sandbox created at: %s\n\n", sb.Dir)
+	infoln("To use it in your current shell:")
+	if runtime.GOOS == "windows" {
+		infof("  $env:WORKTREE_ROOT = \"%s\"\n", sb.WorktreeRoot)
+	} else {
+		infof("  export WORKTREE_ROOT=%q\n", sb.WorktreeRoot)
+	}
+	infof("  cd %q\n\n", sb.Repo)
+	infoln("Things to try:")
+	infoln("  wt checkout feature/login   # existing branch in a new worktree")
+	infoln("  wt create my-idea           # new branch in a new worktree")
+	infoln("  wt list")
+	infoln("  wt rm feature/login")
+	infoln()
+	infoln("The origin also carries refs/pull/1/head and refs/merge-requests/2/head.")
+	infoln("Run 'wt demo --cleanup' to remove all demo sandboxes.")
+}
+
+// demoShell returns the interactive shell to start inside the sandbox.
+func demoShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return "/bin/sh"
+}
+
+var demoCmd = &cobra.Command{
+	Use:   "demo",
+	Short: "Create a throwaway sandbox repository to try wt",
+	Long: `Create a throwaway repository and WORKTREE_ROOT to try wt safely.
+
+The sandbox is created under the system temp directory and contains a repository
+with a few branches, a fake GitHub PR ref and a fake GitLab MR ref.
+
+Examples:
+  wt demo              # Create a sandbox and print how to use it
+  wt demo --shell      # Create a sandbox and start a shell inside it
+  wt demo --cleanup    # Remove all sandboxes created by wt demo (asks first)`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cleanup, _ := cmd.Flags().GetBool("cleanup")
+		shell, _ := cmd.Flags().GetBool("shell")
+
+		if cleanup {
+			sandboxes, err := findDemoSandboxes(os.TempDir())
+			if err != nil {
+				return err
+			}
+			if len(sandboxes) > 0 {
+				if err := confirmAction(cmd, fmt.Sprintf("Remove %d demo sandboxes", len(sandboxes)), sandboxes...); err != nil {
+					return err
+				}
+			}
+			for _, dir := range sandboxes {
+				if err := os.RemoveAll(dir); err != nil {
+					return fmt.Errorf("failed to remove %s: %w", dir, err)
+				}
+				infof("✓ Removed demo sandbox: %s\n", dir)
+			}
+			if len(sandboxes) == 0 {
+				infoln("No demo sandboxes found")
+			}
+			return nil
+		}
+
+		sb, err := createDemoSandbox()
+		if err != nil {
+			return err
+		}
+
+		if !shell {
+			printDemoInstructions(sb)
+			return nil
+		}
+
+		infof("✓ Demo sandbox created at: %s\n", sb.Dir)
+		infoln("Starting a shell inside it; exit the shell to return.")
+		shellCmd := exec.Command(demoShell())
+		shellCmd.Dir = sb.Repo
+		shellCmd.Env = append(os.Environ(), "WORKTREE_ROOT="+sb.WorktreeRoot)
+		shellCmd.Stdin = os.Stdin
+		shellCmd.Stdout = os.Stdout
+		shellCmd.Stderr = os.Stderr
+		_ = shellCmd.Run()
+		return nil
+	},
+}
+
+func init() {
+	demoCmd.Flags().Bool("shell", false, "Start a shell inside the sandbox")
+	demoCmd.Flags().Bool("cleanup", false, "Remove all sandboxes created by wt demo")
+	demoCmd.Flags().BoolP("yes", "y", false, "With --cleanup: do not ask for confirmation")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+func TestFindDemoSandboxes(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"wt-demo-1", "wt-demo-2", "other"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only directories carrying the marker are sandboxes.
+	for _, dir := range []string{"wt-demo-1", "other"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, dir, demoMarker), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := findDemoSandboxes(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || filepath.Base(got[0]) != "wt-demo-1" {
+		t.Errorf("findDemoSandboxes() = %v, want only wt-demo-1", got)
+	}
+}
+
+func TestCreateDemoSandbox(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	t.Setenv("TMP", tmpDir)
+	t.Setenv("TEMP", tmpDir)
+
+	sb, err := createDemoSandbox()
+	if err != nil {
+		t.Fatalf("createDemoSandbox() error = %v", err)
+	}
+
+	worktrees, err := listWorktrees(sb.Repo)
+	if err != nil {
+		t.Fatalf("demo repo is not a git repository: %v", err)
+	}
+	if len(worktrees) != 1 || worktrees[0].Branch != "main" {
+		t.Errorf("demo repo worktrees = %+v, want only main", worktrees)
+	}
+	for _, ref := range []string{"origin/feature/login", "origin/bugfix/typo"} {
+		if err := testrepo.Git(sb.Repo, "rev-parse", "--verify", "--quiet", ref); err != nil {
+			t.Errorf("demo repo missing %s", ref)
+		}
+	}
+	if err := testrepo.Git(sb.Repo, "fetch", "-q", "origin", "pull/1/head:pr-1"); err != nil {
+		t.Errorf("demo origin should carry a fake PR ref: %v", err)
+	}
+
+	sandboxes, err := findDemoSandboxes(tmpDir)
+	if err != nil || len(sandboxes) != 1 {
+		t.Errorf("findDemoSandboxes() = %v, %v; want the new sandbox", sandboxes, err)
+	}
+}
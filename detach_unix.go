@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach makes cmd the leader of a new session, so that it has no
+// controlling terminal and the hangup of closing the terminal does not reach
+// it.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
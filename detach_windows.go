@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess is DETACHED_PROCESS: no console is attached, so closing
+// the console window of wt does not end the process.
+const detachedProcess = 0x00000008
+
+// detach starts cmd without a console and in its own process group, out of
+// reach of Ctrl-C and of closing the console.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+}
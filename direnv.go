@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/template"
+)
+
+// DirenvConfig sets up direnv in new worktrees.
+type DirenvConfig struct {
+	// Template is a file in the worktree, e.g. .envrc.wt, rendered into
+	// .envrc with the WT_ variables of the worktree.
+	Template string `yaml:"template" desc:"Template rendered into .envrc of new worktrees"`
+	// Allow runs `direnv allow` on the new worktree. It trusts whatever the
+	// branch's .envrc runs, so it is never on by default.
+	Allow bool `yaml:"allow" desc:"Run direnv allow in new worktrees"`
+}
+
+// noDirenv is set by --no-direnv: new worktrees are left as checked out.
+var noDirenv bool
+
+// direnvVars are the variables a .envrc template can use, e.g.
+// {{.WT_BRANCH}}.
+func direnvVars(repo, branch, path string) map[string]string {
+	return map[string]string{
+		"WORKTREE_ROOT": worktreeRoot,
+		"WT_REPO":       repo,
+		"WT_REPO_DIR":   path,
+		"WT_BRANCH":     branch,
+	}
+}
+
+// renderEnvrc renders the template file tmplPath with vars into envrcPath. A
+// missing template is not an error: it reports false. An existing .envrc,
+// e.g. one checked in, is left alone.
+func renderEnvrc(tmplPath, envrcPath string, vars map[string]string) (bool, error) {
+	content, err := os.ReadFile(tmplPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Lstat(envrcPath); err == nil {
+		return false, fmt.Errorf("%s already exists", envrcPath)
+	}
+	tmpl, err := template.New(filepath.Base(tmplPath)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return false, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(envrcPath, out.Bytes(), 0o644)
+}
+
+// setupDirenv renders the configured .envrc template into the new worktree
+// at path and, when allowed in the config, runs `direnv allow` on it. The
+// worktree is usable without direnv, so failures are only warnings.
+func setupDirenv(repo, branch, path string) {
+	cfg := getConfig().Direnv
+	if noDirenv || (cfg.Template == "" && !cfg.Allow) {
+		return
+	}
+	envrc := filepath.Join(path, ".envrc")
+	if cfg.Template != "" {
+		rendered, err := renderEnvrc(filepath.Join(path, cfg.Template), envrc, direnvVars(repo, branch, path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to render %s: %v\n", cfg.Template, err)
+		} else if rendered {
+			infof("✓ Rendered %s into .envrc\n", cfg.Template)
+		}
+	}
+	if !cfg.Allow {
+		return
+	}
+	if _, err := os.Stat(envrc); err != nil {
+		return
+	}
+	if _, err := exec.LookPath("direnv"); err != nil {
+		return
+	}
+	if output, err := exec.Command("direnv", "allow", path).CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: direnv allow failed: %v\n%s", err, output)
+		return
+	}
+	infoln("✓ Allowed .envrc with direnv")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderEnvrc(t *testing.T) {
+	vars := direnvVars("api", "feature/x", "/trees/api/feature/x")
+
+	t.Run("renders", func(t *testing.T) {
+		dir := t.TempDir()
+		tmpl := filepath.Join(dir, ".envrc.wt")
+		if err := os.WriteFile(tmpl, []byte("export CACHE_DIR=/cache/{{.WT_REPO}}/{{.WT_BRANCH}}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		envrc := filepath.Join(dir, ".envrc")
+		rendered, err := renderEnvrc(tmpl, envrc, vars)
+		if err != nil || !rendered {
+			t.Fatalf("renderEnvrc() = %v, %v", rendered, err)
+		}
+		content, err := os.ReadFile(envrc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "export CACHE_DIR=/cache/api/feature/x\n"; string(content) != want {
+			t.Errorf(".envrc = %q, want %q", content, want)
+		}
+	})
+
+	t.Run("missing template", func(t *testing.T) {
+		dir := t.TempDir()
+		if rendered, err := renderEnvrc(filepath.Join(dir, ".envrc.wt"), filepath.Join(dir, ".envrc"), vars); err != nil || rendered {
+			t.Errorf("renderEnvrc() without a template = %v, %v", rendered, err)
+		}
+	})
+
+	t.Run("existing envrc", func(t *testing.T) {
+		dir := t.TempDir()
+		tmpl := filepath.Join(dir, ".envrc.wt")
+		envrc := filepath.Join(dir, ".envrc")
+		for _, f := range []string{tmpl, envrc} {
+			if err := os.WriteFile(f, []byte("use nix\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := renderEnvrc(tmpl, envrc, vars); err == nil {
+			t.Error("renderEnvrc() should not overwrite an existing .envrc")
+		}
+	})
+
+	t.Run("unknown variable", func(t *testing.T) {
+		dir := t.TempDir()
+		tmpl := filepath.Join(dir, ".envrc.wt")
+		if err := os.WriteFile(tmpl, []byte("{{.WT_NOPE}}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := renderEnvrc(tmpl, filepath.Join(dir, ".envrc"), vars); err == nil {
+			t.Error("renderEnvrc() should fail on an unknown variable")
+		}
+	})
+}
+
+func TestLoadConfigDirenv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("direnv:\n  allow: true\n  template: .envrc.wt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if !cfg.Direnv.Allow || cfg.Direnv.Template != ".envrc.wt" {
+		t.Errorf("loadConfig() direnv = %+v", cfg.Direnv)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Outcomes of a doctor check.
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// doctorResult is the outcome of one `wt doctor` check. Fixable problems can
+// be remedied by `wt doctor --fix`; Fixed marks results checked again after
+// that.
+type doctorResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Detail  string `json:"detail"`
+	Fixable bool   `json:"fixable,omitempty"`
+	Fixed   bool   `json:"fixed,omitempty"`
+}
+
+// doctorCheck is one check of `wt doctor`. Fix remedies the problem Check
+// reports as fixable and must be safe to run again; Plan describes it for
+// the confirmation.
+type doctorCheck struct {
+	Name  string
+	Check func() doctorResult
+	Plan  string
+	Fix   func() error
+}
+
+// checkRepository checks that wt runs inside a git repository, whose common
+// git dir is commonDir.
+func checkRepository(commonDir string) doctorResult {
+	if commonDir == "" {
+		return doctorResult{Name: "repository", Status: doctorFail, Detail: "not inside a git repository"}
+	}
+	return doctorResult{Name: "repository", Status: doctorOK, Detail: commonDir}
+}
+
+func checkWorktreeRoot() doctorResult {
+	info, err := os.Stat(worktreeRoot)
+	switch {
+	case err != nil:
+		return doctorResult{Name: "worktree root", Status: doctorWarn, Detail: worktreeRoot + " does not exist yet", Fixable: true}
+	case !info.IsDir():
+		return doctorResult{Name: "worktree root", Status: doctorFail, Detail: worktreeRoot + " is not a directory"}
+	}
+	return doctorResult{Name: "worktree root", Status: doctorOK, Detail: worktreeRoot}
+}
+
+func fixWorktreeRoot() error {
+	return os.MkdirAll(worktreeRoot, 0o755)
+}
+
+// checkFilesystem compares the filesystems of the repository and the
+// worktree root.
+func checkFilesystem(commonDir string, device func(string) (uint64, error)) doctorResult {
+	same, err := sameFilesystem(commonDir, worktreeRoot, device)
+	switch {
+	case err != nil:
+		return doctorResult{Name: "filesystem", Status: doctorWarn, Detail: "cannot compare filesystems: " + err.Error()}
+	case !same:
+		return doctorResult{Name: "filesystem", Status: doctorWarn, Detail: "the worktree root is on another filesystem than the repository;\n" + crossDeviceNote}
+	}
+	return doctorResult{Name: "filesystem", Status: doctorOK, Detail: "the worktree root is on the repository's filesystem"}
+}
+
+func checkOriginHead() doctorResult {
+	if repoGit("remote", "get-url", "origin").Run() != nil {
+		return doctorResult{Name: "origin/HEAD", Status: doctorOK, Detail: "no origin remote"}
+	}
+	if repoGit("symbolic-ref", "--quiet", "refs/remotes/origin/HEAD").Run() != nil {
+		return doctorResult{Name: "origin/HEAD", Status: doctorWarn, Detail: "not set, so the default branch is guessed as main; run 'git remote set-head origin -a'", Fixable: true}
+	}
+	return doctorResult{Name: "origin/HEAD", Status: doctorOK, Detail: "origin/" + getDefaultBase()}
+}
+
+func fixOriginHead() error {
+	if output, err := repoGit("remote", "set-head", "origin", "-a").CombinedOutput(); err != nil {
+		return fmt.Errorf("git remote set-head origin -a: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func checkPrunable() doctorResult {
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		return doctorResult{Name: "worktrees", Status: doctorFail, Detail: err.Error()}
+	}
+	offline := findOfflineWorktrees(worktrees)
+	var prunable []string
+	for _, wt := range worktrees {
+		if wt.Prunable && !offline[wt.Path] {
+			prunable = append(prunable, wt.Path)
+		}
+	}
+	detail := fmt.Sprintf("%d registered", len(worktrees))
+	if len(offline) > 0 {
+		detail += fmt.Sprintf(", %d on offline volumes (kept locked)", len(offline))
+	}
+	if len(prunable) > 0 {
+		return doctorResult{Name: "worktrees", Status: doctorWarn, Detail: fmt.Sprintf("%d registered but deleted, run 'wt prune': %s", len(prunable), strings.Join(prunable, ", ")), Fixable: true}
+	}
+	return doctorResult{Name: "worktrees", Status: doctorOK, Detail: detail}
+}
+
+func fixPrunable() error {
+	_, _, err := pruneWorktrees(false)
+	return err
+}
+
+// checkShellIntegration looks for the marker the shellenv wrapper sets, and
+// otherwise for the source line in the startup file of the user's shell.
+// When auto-cd may not work, it points at the self-test of shellenv --verify.
+func checkShellIntegration(rcFile string) doctorResult {
+	verify := shellenvVerifyHint(runtime.GOOS == "windows" && !isMSYSShell())
+	switch {
+	case os.Getenv("WT_SHELL_INTEGRATION") != "":
+		return doctorResult{Name: "shell integration", Status: doctorOK, Detail: "detected; to test auto-cd, run: " + verify}
+	case rcFile != "" && shellenvInstalled(rcFile):
+		return doctorResult{Name: "shell integration", Status: doctorWarn, Detail: "set up in " + rcFile + " but not active in this shell; open a new shell,\nthen test auto-cd with: " + verify}
+	}
+	return doctorResult{Name: "shell integration", Status: doctorWarn, Detail: "not detected; wt cannot cd for you (see 'wt shellenv --help')", Fixable: rcFile != ""}
+}
+
+// doctorChecks returns the checks of `wt doctor`; the repository checks only
+// inside one.
+func doctorChecks() []doctorCheck {
+	commonDir, _ := repoCommonDir()
+	checks := []doctorCheck{
+		{Name: "repository", Check: func() doctorResult { return checkRepository(commonDir) }},
+		{Name: "worktree root", Check: checkWorktreeRoot, Plan: "Create " + worktreeRoot, Fix: fixWorktreeRoot},
+		{Name: "git version", Check: checkGitVersion},
+		{Name: "git environment", Check: checkGitEnv},
+	}
+	if commonDir != "" {
+		checks = append(checks,
+			doctorCheck{Name: "filesystem", Check: func() doctorResult { return checkFilesystem(commonDir, deviceOf) }},
+			doctorCheck{Name: "origin/HEAD", Check: checkOriginHead, Plan: "Run 'git remote set-head origin -a'", Fix: fixOriginHead},
+			doctorCheck{Name: "default branch", Check: checkDefaultBranch, Plan: "Run 'git fetch --prune origin' and 'git remote set-head origin -a'", Fix: fixDefaultBranch},
+			doctorCheck{Name: "local default branch", Check: checkLocalDefaultBranch, Plan: "Rename the local branch named after the old default branch, and its worktree", Fix: fixLocalDefaultBranch},
+			doctorCheck{Name: "worktrees", Check: checkPrunable, Plan: "Run 'git worktree prune'", Fix: fixPrunable},
+		)
+	}
+	rcFile, _ := shellRCFile(detectShell(), userHomeDir())
+	return append(checks, doctorCheck{
+		Name:  "shell integration",
+		Check: func() doctorResult { return checkShellIntegration(rcFile) },
+		Plan:  fmt.Sprintf("Add '%s' to %s", shellenvLine, rcFile),
+		Fix: func() error {
+			_, err := installShellenv(rcFile)
+			return err
+		},
+	})
+}
+
+// runDoctor runs checks in order. With confirm set, every fixable problem is
+// fixed once confirm approves its plan and checked again, so the results
+// reflect the state after fixing. A declined fix leaves the result as is.
+func runDoctor(checks []doctorCheck, confirm func(plan string) error) ([]doctorResult, error) {
+	results := make([]doctorResult, 0, len(checks))
+	for _, c := range checks {
+		r := c.Check()
+		if confirm != nil && r.Fixable && c.Fix != nil {
+			err := confirm(c.Plan)
+			switch {
+			case errors.Is(err, errCancelled):
+			case err != nil:
+				return nil, err
+			default:
+				fixErr := c.Fix()
+				r = c.Check()
+				if fixErr != nil {
+					r.Detail += "\nfix failed: " + fixErr.Error()
+				} else {
+					r.Fixed = true
+				}
+			}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// printDoctorResults prints one line per check, indenting continuation lines.
+func printDoctorResults(w io.Writer, results []doctorResult, color bool) {
+	for _, r := range results {
+		mark := colorize(color, ansiGreen, "✓")
+		switch r.Status {
+		case doctorWarn:
+			mark = colorize(color, ansiYellow, "!")
+		case doctorFail:
+			mark = colorize(color, ansiRed, "✗")
+		}
+		detail := strings.ReplaceAll(r.Detail, "\n", "\n    ")
+		if r.Fixed {
+			detail += " (fixed)"
+		}
+		fmt.Fprintf(w, "%s %s: %s\n", mark, r.Name, detail)
+	}
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the setup of wt and the current repository",
+	Long: `Check the setup of wt and the current repository and report problems.
+
+Checks the repository, the worktree root and whether it is on the same
+filesystem as the repository, origin/HEAD and whether origin still has that
+default branch, worktrees deleted without 'wt remove', and the shell
+integration. Warnings are marked with '!', errors
+with '✗'; the exit code is 1 when any check fails.
+
+With --fix, wt remedies what it can, each after confirmation (or all with
+--yes): it creates a missing worktree root, sets origin/HEAD with 'git remote
+set-head origin -a', follows a renamed default branch of origin (fetching it,
+moving origin/HEAD and renaming the local branch and its worktree), prunes
+deleted worktrees and adds the shell integration to ~/.bashrc or ~/.zshrc. Fixed checks are run again before the report.
+
+When tab completion does not work, --shell checks the shell integration end
+to end: it sources the current 'wt shellenv' output in a fresh bash or zsh
+(the shell in $SHELL, without its startup files) and asks the shell whether
+wt is the wrapper function ('type wt') and whether completion is registered
+('complete -p wt' in bash; for zsh, the _wt functions and what compdef
+registered after compinit). It then checks the startup file: that it sources
+wt shellenv and, for zsh, after compinit (oh-my-zsh and prezto run it), with
+the lines to move if not, and whether zsh's ~/.zcompdump caches another
+completion of wt.
+
+Examples:
+  wt doctor
+  wt doctor --fix       # fix problems, asking before each fix
+  wt doctor --fix --yes
+  wt doctor --json      # machine-readable results
+  wt doctor --shell     # also check tab completion end to end`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		var confirm func(string) error
+		if fix, _ := cmd.Flags().GetBool("fix"); fix {
+			confirm = func(plan string) error {
+				return confirmAction(cmd, plan)
+			}
+		}
+		checks := doctorChecks()
+		if deep, _ := cmd.Flags().GetBool("shell"); deep {
+			checks = append(checks, shellChecks()...)
+		}
+		results, err := runDoctor(checks, confirm)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			if err := writeJSON(os.Stdout, results); err != nil {
+				return err
+			}
+		} else {
+			printDoctorResults(os.Stdout, results, colorEnabled(os.Stdout))
+		}
+		for _, r := range results {
+			if r.Status == doctorFail {
+				return exitWithCode(cmd, 1)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	doctorCmd.Flags().Bool("json", false, "Output as JSON")
+	doctorCmd.Flags().Bool("fix", false, "Fix the problems wt can fix")
+	doctorCmd.Flags().BoolP("yes", "y", false, "Apply fixes without asking")
+	doctorCmd.Flags().Bool("shell", false, "Also check the shell function and completion end to end")
+}
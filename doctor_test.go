@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintDoctorResults(t *testing.T) {
+	var out strings.Builder
+	printDoctorResults(&out, []doctorResult{
+		{Name: "repository", Status: doctorOK, Detail: "/src/api/.git"},
+		{Name: "filesystem", Status: doctorWarn, Detail: "first line\nsecond line"},
+		{Name: "worktree root", Status: doctorFail, Detail: "/trees is not a directory"},
+		{Name: "worktrees", Status: doctorOK, Detail: "2 registered", Fixed: true},
+	}, false)
+	want := "✓ repository: /src/api/.git\n" +
+		"! filesystem: first line\n    second line\n" +
+		"✗ worktree root: /trees is not a directory\n" +
+		"✓ worktrees: 2 registered (fixed)\n"
+	if out.String() != want {
+		t.Errorf("printDoctorResults() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+// fakeProblem is a fabricated environment with one fixable problem.
+type fakeProblem struct {
+	broken bool
+	fixes  int
+}
+
+func (p *fakeProblem) check() doctorCheck {
+	return doctorCheck{
+		Name: "fake",
+		Check: func() doctorResult {
+			if p.broken {
+				return doctorResult{Name: "fake", Status: doctorWarn, Detail: "broken", Fixable: true}
+			}
+			return doctorResult{Name: "fake", Status: doctorOK, Detail: "fine"}
+		},
+		Plan: "Repair fake",
+		Fix: func() error {
+			p.fixes++
+			p.broken = false
+			return nil
+		},
+	}
+}
+
+func TestRunDoctor(t *testing.T) {
+	approve := func(string) error { return nil }
+
+	t.Run("report only", func(t *testing.T) {
+		p := &fakeProblem{broken: true}
+		results, err := runDoctor([]doctorCheck{p.check()}, nil)
+		if err != nil || len(results) != 1 || results[0].Status != doctorWarn || p.fixes != 0 {
+			t.Errorf("runDoctor() without fixing = %+v, %v; %d fixes", results, err, p.fixes)
+		}
+	})
+
+	t.Run("fix and check again", func(t *testing.T) {
+		p := &fakeProblem{broken: true}
+		var plans []string
+		results, err := runDoctor([]doctorCheck{p.check()}, func(plan string) error {
+			plans = append(plans, plan)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := results[0]; r.Status != doctorOK || !r.Fixed || r.Detail != "fine" {
+			t.Errorf("result after fixing = %+v", r)
+		}
+		if len(plans) != 1 || plans[0] != "Repair fake" {
+			t.Errorf("confirmed plans = %q", plans)
+		}
+
+		// Nothing is left to fix the second time.
+		if _, err := runDoctor([]doctorCheck{p.check()}, approve); err != nil || p.fixes != 1 {
+			t.Errorf("second run: %v, %d fixes; want 1", err, p.fixes)
+		}
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		p := &fakeProblem{broken: true}
+		results, err := runDoctor([]doctorCheck{p.check()}, func(string) error { return errCancelled })
+		if err != nil || results[0].Status != doctorWarn || results[0].Fixed || p.fixes != 0 {
+			t.Errorf("declined fix: %+v, %v; %d fixes", results, err, p.fixes)
+		}
+	})
+
+	t.Run("non-interactive", func(t *testing.T) {
+		p := &fakeProblem{broken: true}
+		refused := errors.New("refusing")
+		if _, err := runDoctor([]doctorCheck{p.check()}, func(string) error { return refused }); !errors.Is(err, refused) {
+			t.Errorf("runDoctor() = %v, want the confirmation error", err)
+		}
+	})
+
+	t.Run("failing fix", func(t *testing.T) {
+		check := (&fakeProblem{broken: true}).check()
+		check.Fix = func() error { return errors.New("no permission") }
+		results, err := runDoctor([]doctorCheck{check}, approve)
+		if err != nil || results[0].Status != doctorWarn || results[0].Fixed || !strings.Contains(results[0].Detail, "fix failed: no permission") {
+			t.Errorf("failing fix: %+v, %v", results, err)
+		}
+	})
+}
+
+func TestFixWorktreeRoot(t *testing.T) {
+	originalRoot := worktreeRoot
+	t.Cleanup(func() {
+		worktreeRoot = originalRoot
+	})
+	worktreeRoot = filepath.Join(t.TempDir(), "trees")
+
+	if r := checkWorktreeRoot(); r.Status != doctorWarn || !r.Fixable {
+		t.Fatalf("checkWorktreeRoot() for a missing root = %+v", r)
+	}
+	for i := 0; i < 2; i++ {
+		if err := fixWorktreeRoot(); err != nil {
+			t.Fatalf("fixWorktreeRoot() run %d: %v", i+1, err)
+		}
+	}
+	if r := checkWorktreeRoot(); r.Status != doctorOK {
+		t.Errorf("checkWorktreeRoot() after fixing = %+v", r)
+	}
+}
+
+func TestCheckShellIntegration(t *testing.T) {
+	t.Setenv("WT_SHELL_INTEGRATION", "")
+	rcFile := filepath.Join(t.TempDir(), ".bashrc")
+
+	if r := checkShellIntegration(""); r.Fixable {
+		t.Errorf("checkShellIntegration() without a known startup file should not be fixable: %+v", r)
+	}
+	if r := checkShellIntegration(rcFile); r.Status != doctorWarn || !r.Fixable {
+		t.Errorf("checkShellIntegration() before installing = %+v", r)
+	}
+	if _, err := installShellenv(rcFile); err != nil {
+		t.Fatal(err)
+	}
+	if r := checkShellIntegration(rcFile); r.Fixable || !strings.Contains(r.Detail, "open a new shell") {
+		t.Errorf("checkShellIntegration() after installing = %+v", r)
+	}
+
+	t.Setenv("WT_SHELL_INTEGRATION", "1")
+	if r := checkShellIntegration(rcFile); r.Status != doctorOK {
+		t.Errorf("checkShellIntegration() with the wrapper = %+v", r)
+	}
+}
+
+func TestInstallShellenv(t *testing.T) {
+	rcFile := filepath.Join(t.TempDir(), ".zshrc")
+	if err := os.WriteFile(rcFile, []byte("autoload -Uz compinit && compinit"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := installShellenv(rcFile)
+	if err != nil || !changed {
+		t.Fatalf("installShellenv() = %v, %v", changed, err)
+	}
+	if changed, err := installShellenv(rcFile); err != nil || changed {
+		t.Errorf("installShellenv() a second time = %v, %v; want no change", changed, err)
+	}
+	content, err := os.ReadFile(rcFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "autoload -Uz compinit && compinit\n\n# wt shell integration\nsource <(wt shellenv)\n"
+	if string(content) != want {
+		t.Errorf("startup file =\n%q\nwant\n%q", content, want)
+	}
+}
+
+func TestShellRCFile(t *testing.T) {
+	t.Setenv("ZDOTDIR", "")
+	home := filepath.FromSlash("/home/me")
+	if got, err := shellRCFile("bash", home); err != nil || got != filepath.Join(home, ".bashrc") {
+		t.Errorf("shellRCFile(bash) = %q, %v", got, err)
+	}
+	if got, err := shellRCFile("zsh", home); err != nil || got != filepath.Join(home, ".zshrc") {
+		t.Errorf("shellRCFile(zsh) = %q, %v", got, err)
+	}
+	if _, err := shellRCFile("fish", home); err == nil {
+		t.Error("shellRCFile(fish) should fail")
+	}
+}
+
+func TestCheckGitEnv(t *testing.T) {
+	originalOverrides, originalRespect := gitEnvOverrides, respectGitEnv
+	t.Cleanup(func() {
+		gitEnvOverrides, respectGitEnv = originalOverrides, originalRespect
+	})
+
+	gitEnvOverrides, respectGitEnv = nil, false
+	if r := checkGitEnv(); r.Status != doctorOK {
+		t.Errorf("checkGitEnv() without overrides = %+v", r)
+	}
+	gitEnvOverrides = []string{"GIT_DIR=/src/other/.git"}
+	if r := checkGitEnv(); r.Status != doctorWarn || !strings.Contains(r.Detail, "ignoring GIT_DIR=/src/other/.git") {
+		t.Errorf("checkGitEnv() with a stripped GIT_DIR = %+v", r)
+	}
+	respectGitEnv = true
+	if r := checkGitEnv(); r.Status != doctorWarn || !strings.Contains(r.Detail, "honoring GIT_DIR=/src/other/.git") {
+		t.Errorf("checkGitEnv() with --respect-git-env = %+v", r)
+	}
+}
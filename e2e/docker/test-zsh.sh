@@ -105,9 +105,9 @@ echo "✓ PASS: wt list executed successfully"
 
 # Test 4: wt remove
 echo ""
-echo "Test 4: wt remove feature-branch"
+echo "Test 4: wt remove feature-branch --yes"
 cd "$REPO_DIR"
-wt remove feature-branch
+wt remove feature-branch --yes
 
 if [[ ! -d "$WORKTREE_ROOT/test-repo/feature-branch" ]]; then
     echo "✓ PASS: Worktree directory removed"
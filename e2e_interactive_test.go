@@ -17,6 +17,10 @@ import (
 	"github.com/aymanbagabas/go-pty"
 )
 
+// These tests drive real shells through a pseudo-terminal and are smoke tests
+// of the menus and the auto-cd. The interactive paths of the commands are
+// covered without a terminal by the scripted Prompter in prompt_test.go.
+
 // ptyShell represents a pseudo-terminal running a shell
 type ptyShell struct {
 	pty       pty.Pty
@@ -298,6 +302,37 @@ func (ps *ptyShell) resetOutput() {
 	ps.output.Reset()
 }
 
+// checkCancelled cancels the prompt on screen with Ctrl-C and checks that wt
+// exits with exitCancelled and prints a single "cancelled" line, without an
+// error or usage. statusCommand echoes the exit status as wt-exit=<code>.
+func checkCancelled(t *testing.T, ps *ptyShell, statusCommand string) {
+	t.Helper()
+	ps.resetOutput()
+	if err := ps.send("\x03"); err != nil {
+		t.Fatalf("Failed to send Ctrl-C: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if err := ps.send(statusCommand + "\n"); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), getContextTimeout())
+	defer cancel()
+	want := fmt.Sprintf("wt-exit=%d", exitCancelled)
+	if err := ps.waitForText(ctx, want); err != nil {
+		t.Fatalf("wt did not exit with %d after Ctrl-C: %v\nOutput:\n%s", exitCancelled, err, ps.getOutput())
+	}
+	output := ps.getOutput()
+	if !strings.Contains(output, "cancelled") {
+		t.Errorf("no 'cancelled' line after Ctrl-C:\n%s", output)
+	}
+	for _, noise := range []string{"Error:", "Usage:"} {
+		if strings.Contains(output, noise) {
+			t.Errorf("cancelling printed %q:\n%s", noise, output)
+		}
+	}
+}
+
 // TestInteractiveCheckoutWithoutArgs demonstrates the hang when running 'wt co'
 // without providing a branch name. This test should FAIL until the bug is fixed.
 func TestInteractiveCheckoutWithoutArgs(t *testing.T) {
@@ -383,9 +418,7 @@ echo "Built wt binary: %s"
 	t.Log("SUCCESS: Interactive prompt appeared!")
 	t.Log("The bug appears to be fixed.")
 
-	// Cancel the prompt and exit cleanly
-	ps.send("\x03") // Ctrl-C to cancel the prompt
-	time.Sleep(500 * time.Millisecond)
+	checkCancelled(t, ps, `echo "wt-exit=$?"`)
 }
 
 // TestNonInteractiveCheckoutWithArgs demonstrates that checkout works when
@@ -557,9 +590,7 @@ echo "Built wt binary: %s"
 	t.Log("SUCCESS: Interactive prompt appeared!")
 	t.Log("The bug appears to be fixed.")
 
-	// Cancel the prompt and exit cleanly
-	ps.send("\x03") // Ctrl-C to cancel the prompt
-	time.Sleep(500 * time.Millisecond)
+	checkCancelled(t, ps, `echo "wt-exit=$?"`)
 }
 
 // TestNonInteractiveCheckoutWithArgsBash demonstrates that checkout works when
@@ -744,9 +775,7 @@ Write-Output "Built wt binary: %s"
 	t.Log("SUCCESS: Interactive prompt appeared!")
 	t.Log("The bug appears to be fixed.")
 
-	// Cancel the prompt and exit cleanly
-	ps.send("\x03") // Ctrl-C to cancel the prompt
-	time.Sleep(500 * time.Millisecond)
+	checkCancelled(t, ps, `echo "wt-exit=$LASTEXITCODE"`)
 }
 
 // TestNonInteractiveCheckoutWithArgsPowerShell demonstrates that checkout works when
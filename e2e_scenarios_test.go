@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timvw/wt/internal/harness"
+	"github.com/timvw/wt/internal/shellrun"
+)
+
+// TestE2EScenarios runs the scenarios of e2e/scenarios in each of their
+// shells; see e2e/scenarios/README.md for the format.
+func TestE2EScenarios(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("bash/zsh scenarios")
+	}
+
+	scenarios, err := harness.LoadDir(filepath.Join("e2e", "scenarios"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wtBinary := buildWtBinary(t, t.TempDir())
+
+	for _, s := range scenarios {
+		for _, shell := range s.RunsIn() {
+			t.Run(s.Name+"/"+shell, func(t *testing.T) {
+				if _, err := exec.LookPath(shell); err != nil {
+					t.Skipf("%s not available", shell)
+				}
+				tmpDir := t.TempDir()
+				fx, err := s.Prepare(tmpDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				root := filepath.Join(tmpDir, "worktrees")
+				vars := map[string]string{"$TMP": tmpDir, "$WORKTREE_ROOT": root, "$REPO": fx.Repo}
+
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+				cmd := shellrun.Command(ctx, shell, s.Script(fx.Repo, vars),
+					"WORKTREE_ROOT="+root,
+					"WT_STATE_DIR="+filepath.Join(tmpDir, "state"),
+					"PATH="+filepath.Dir(wtBinary)+string(os.PathListSeparator)+os.Getenv("PATH"))
+				var stdout, stderr strings.Builder
+				cmd.Stdout, cmd.Stderr = &stdout, &stderr
+				if err := cmd.Run(); err != nil {
+					t.Fatalf("%s: %s failed: %v\nstdout:\n%s\nstderr:\n%s", s.File, shell, err, stdout.String(), stderr.String())
+				}
+
+				result := harness.ParseOutput(stdout.String())
+				failures := s.Check(result, func(out string) string { return normalizeOutput(out, vars) })
+				for _, f := range failures {
+					t.Errorf("%s: %s", s.File, f)
+				}
+				if len(failures) > 0 {
+					t.Logf("stdout:\n%s\nstderr:\n%s", stdout.String(), stderr.String())
+				}
+			})
+		}
+	}
+}
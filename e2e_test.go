@@ -1,12 +1,20 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/timvw/wt/internal/shellrun"
+	"github.com/timvw/wt/internal/testrepo"
 )
 
 // TestE2EAutoCdWithNonInteractiveCommand tests that auto-cd works
@@ -192,7 +200,7 @@ echo "After checkout:"
 pwd
 
 # Remove the worktree (should auto-cd back to main)
-wt remove temp-branch
+wt remove temp-branch --yes
 
 # Print current directory (should be back at main repo)
 echo "After remove:"
@@ -222,21 +230,803 @@ pwd
 	}
 }
 
+// TestE2EWorktreeRootWithSpaces tests that the shell functions follow wt into
+// worktrees under a WORKTREE_ROOT containing spaces, given either as an
+// absolute path or relative to the home directory
+func TestE2EWorktreeRootWithSpaces(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test repo")
+	home := filepath.Join(tmpDir, "home dir")
+	if err := os.MkdirAll(home, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+	runGitCommand(t, repoDir, "branch", "spaced-branch")
+
+	for _, shell := range []string{"bash", "zsh"} {
+		for _, root := range []struct {
+			name, value, want string
+		}{
+			{"absolute", filepath.Join(tmpDir, "my trees"), filepath.Join(tmpDir, "my trees")},
+			{"relative", "./rel trees", filepath.Join(home, "rel trees")},
+		} {
+			t.Run(shell+"/"+root.name, func(t *testing.T) {
+				if _, err := exec.LookPath(shell); err != nil {
+					t.Skipf("%s not available", shell)
+				}
+				script := fmt.Sprintf(`
+export HOME=%s
+export WORKTREE_ROOT=%s
+export PATH=%s:$PATH
+cd %s
+source <(wt shellenv)
+wt checkout spaced-branch
+pwd
+cd %s
+wt remove spaced-branch --yes >/dev/null
+`, shellQuote(home), shellQuote(root.value), shellQuote(filepath.Dir(wtBinary)), shellQuote(repoDir), shellQuote(repoDir))
+
+				output, err := shellrun.Run(shell, script, time.Minute)
+				if err != nil {
+					t.Fatalf("Failed to run %s e2e test: %v\nOutput: %s", shell, err, output)
+				}
+
+				expectedPath := filepath.Join(root.want, "test repo", "spaced-branch")
+				if !strings.Contains(string(output), expectedPath+"\n") {
+					t.Errorf("E2E FAIL: Auto-cd didn't reach %s\nOutput: %s", expectedPath, output)
+				}
+				warned := strings.Contains(string(output), "WORKTREE_ROOT \"./rel trees\" is relative")
+				if warned != (root.name == "relative") {
+					t.Errorf("relative root warning shown = %v\nOutput: %s", warned, output)
+				}
+			})
+		}
+	}
+}
+
+// TestE2EPRNumberWithoutGh tests that `wt pr <number>` only needs git: the
+// PR ref is fetched with plain git credentials, as in CI without gh, and
+// that --timings leaves stdout to the JSON.
+func TestE2EPRNumberWithoutGh(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("builds a PATH from symlinks")
+	}
+
+	tmpDir := t.TempDir()
+	origin := filepath.Join(tmpDir, "origin")
+	setupTestRepo(t, origin)
+	runGitCommand(t, origin, "commit", "--allow-empty", "-m", "review 1")
+	runGitCommand(t, origin, "update-ref", "refs/pull/1/head", "HEAD")
+	runGitCommand(t, origin, "reset", "-q", "--hard", "HEAD~1")
+	clone := filepath.Join(tmpDir, "clone")
+	if out, err := exec.Command("git", "clone", "-q", origin, clone).CombinedOutput(); err != nil {
+		t.Fatalf("git clone failed: %v\n%s", err, out)
+	}
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	// A PATH with git and nothing else, in particular no gh.
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binDir := filepath.Join(tmpDir, "path")
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(git, filepath.Join(binDir, "git")); err != nil {
+		t.Fatal(err)
+	}
+
+	worktreeRoot := filepath.Join(tmpDir, "worktrees")
+	cmd := exec.Command(wtBinary, "pr", "1", "--output", "json", "--timings")
+	cmd.Dir = clone
+	cmd.Env = append(os.Environ(), "PATH="+binDir, "WORKTREE_ROOT="+worktreeRoot, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("wt pr 1 without gh failed: %v\nstderr: %s", err, stderr.String())
+	}
+
+	var result reviewCheckout
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, output)
+	}
+	want := reviewCheckout{Number: "1", Branch: "pr-1", Path: filepath.Join(worktreeRoot, "origin", "pr-1")}
+	if result != want {
+		t.Errorf("wt pr 1 --output json = %+v, want %+v", result, want)
+	}
+	// --timings keeps stdout for the JSON.
+	if timings := stderr.String(); !strings.Contains(timings, "Timings: ") || !strings.Contains(timings, "git fetch") || !strings.Contains(timings, "wt itself") {
+		t.Errorf("wt pr 1 --timings printed on stderr:\n%s\nwant the timings of the fetch", timings)
+	}
+	subject, err := exec.Command("git", "-C", want.Path, "log", "-1", "--format=%s").CombinedOutput()
+	if err != nil || strings.TrimSpace(string(subject)) != "review 1" {
+		t.Errorf("pr-1 worktree is at %q (%v), want the PR commit", subject, err)
+	}
+
+	// Interactive selection still needs gh and says so.
+	cmd = exec.Command(wtBinary, "pr")
+	cmd.Dir = clone
+	cmd.Env = append(os.Environ(), "PATH="+binDir)
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "'gh' CLI not found") {
+		t.Errorf("wt pr without a number and gh = %v\n%s", err, out)
+	}
+}
+
+// TestE2EListDirtyExitCode tests that `wt list --dirty --quiet` reports dirty
+// worktrees through its exit code only
+func TestE2EListDirtyExitCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	run := func() (string, int) {
+		cmd := exec.Command(wtBinary, "list", "--dirty", "--quiet")
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+filepath.Join(tmpDir, "worktrees"))
+		output, err := cmd.CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return string(output), exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatalf("Failed to run wt list: %v", err)
+		}
+		return string(output), 0
+	}
+
+	if output, code := run(); code != 0 || output != "" {
+		t.Errorf("clean repo: exit code %d, output %q; want 0 and no output", code, output)
+	}
+
+	if err := os.WriteFile(filepath.Join(repoDir, "wip.txt"), []byte("wip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if output, code := run(); code != 1 || output != "" {
+		t.Errorf("dirty repo: exit code %d, output %q; want 1 and no output", code, output)
+	}
+}
+
+// TestE2EListJSON checks the document of `wt list --json` against a golden
+// file, with the temporary directory and timestamps replaced.
+func TestE2EListJSON(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	// Fixed dates give the commits the same hashes on every run.
+	t.Setenv("GIT_AUTHOR_DATE", "2026-03-11T12:00:00Z")
+	t.Setenv("GIT_COMMITTER_DATE", "2026-03-11T12:00:00Z")
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+filepath.Join(tmpDir, "worktrees"), "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("wt %s failed: %v", strings.Join(args, " "), err)
+		}
+		return string(output)
+	}
+	wt("create", "feature/login")
+	out := wt("list", "--json")
+
+	assertStdoutJSON(t, out, func(decoded any) error {
+		worktrees, ok := decoded.([]any)
+		if !ok || len(worktrees) != 2 {
+			return fmt.Errorf("want an array of 2 worktrees")
+		}
+		for _, wt := range worktrees {
+			createdAt, _ := wt.(map[string]any)["createdAt"].(string)
+			if _, err := time.Parse(time.RFC3339, createdAt); err != nil {
+				return fmt.Errorf("createdAt: %w", err)
+			}
+		}
+		return nil
+	})
+	timestamps := regexp.MustCompile(`"\d{4}-\d\d-\d\dT[^"]*"`)
+	assertGolden(t, "e2e_list_json.golden", timestamps.ReplaceAllString(out, `"$$TIME"`), map[string]string{"$TMP": tmpDir})
+}
+
+func TestNormalizeOutput(t *testing.T) {
+	roots := map[string]string{"$TMP": `C:\Users\me\Temp\wt1`, "$ROOT": `C:\Users\me\Temp\wt1\worktrees`}
+	tests := []struct{ out, want string }{
+		{`"path": "C:\\Users\\me\\Temp\\wt1\\test-repo"`, `"path": "$TMP/test-repo"`},
+		{`"path": "C:\\Users\\me\\Temp\\wt1\\worktrees\\test-repo\\feature"`, `"path": "$ROOT/test-repo/feature"`},
+		{"Created C:\\Users\\me\\Temp\\wt1\\worktrees\\api\\x done", "Created $ROOT/api/x done"},
+		{"cd C:/Users/me/Temp/wt1/test-repo", "cd $TMP/test-repo"},
+		{"/tmp/other/path", "/tmp/other/path"},
+	}
+	for _, tt := range tests {
+		if got := normalizeOutput(tt.out, roots); got != tt.want {
+			t.Errorf("normalizeOutput(%q) = %q, want %q", tt.out, got, tt.want)
+		}
+	}
+}
+
+// TestE2EStdoutContract checks that checkout and create print only the cd
+// marker on stdout, with their messages on stderr.
+func TestE2EStdoutContract(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	root := filepath.Join(tmpDir, "worktrees")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "branch", "existing")
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	tests := []struct {
+		args []string
+		path string
+		msg  string
+	}{
+		{[]string{"create", "feature"}, filepath.Join(root, "test-repo", "feature"), "Worktree created at:"},
+		{[]string{"checkout", "existing"}, filepath.Join(root, "test-repo", "existing"), "Worktree created at:"},
+		{[]string{"checkout", "existing"}, filepath.Join(root, "test-repo", "existing"), "Worktree already exists:"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		cmd := exec.Command(wtBinary, tt.args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+root, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("wt %s failed: %v\n%s", strings.Join(tt.args, " "), err, stderr.String())
+		}
+		if want := "TREE_ME_CD:" + tt.path + "\n"; stdout.String() != want {
+			t.Errorf("wt %s: stdout = %q, want only %q", strings.Join(tt.args, " "), stdout.String(), want)
+		}
+		if !strings.Contains(stderr.String(), tt.msg) {
+			t.Errorf("wt %s: stderr does not say %q:\n%s", strings.Join(tt.args, " "), tt.msg, stderr.String())
+		}
+	}
+}
+
+// TestE2EWrapperOutputOrderAndExitCode checks that the shell wrapper streams
+// stdout live, keeps it in order with stderr and returns wt's exit code.
+func TestE2EWrapperOutputOrderAndExitCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("bash/zsh wrapper test")
+	}
+
+	tmpDir := t.TempDir()
+	wtBinary := buildWtBinary(t, tmpDir)
+	shellenv, err := exec.Command(wtBinary, "shellenv").Output()
+	if err != nil {
+		t.Fatalf("wt shellenv failed: %v", err)
+	}
+	shellenvFile := filepath.Join(tmpDir, "shellenv.sh")
+	if err := os.WriteFile(shellenvFile, shellenv, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stand-in for wt that interleaves stdout and stderr, then exits with
+	// the code given as its first argument.
+	fakeDir := filepath.Join(tmpDir, "fake")
+	target := filepath.Join(tmpDir, "target")
+	for _, dir := range []string{fakeDir, target} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fakeWt := fmt.Sprintf(`#!/bin/sh
+echo "OUT-1"
+sleep 0.2
+echo "ERR-2" >&2
+sleep 0.2
+echo "OUT-3"
+echo "TREE_ME_CD:%s"
+exit "$1"
+`, target)
+	if err := os.WriteFile(filepath.Join(fakeDir, "wt"), []byte(fakeWt), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, shell := range []string{"bash", "zsh"} {
+		t.Run(shell, func(t *testing.T) {
+			if _, err := exec.LookPath(shell); err != nil {
+				t.Skipf("%s not available", shell)
+			}
+			script := fmt.Sprintf(`
+export PATH=%s:$PATH
+source %s
+cd %s
+wt 3
+echo "exit=$? pwd=$(pwd)"
+wt 0
+echo "exit=$? pwd=$(pwd)"
+`, fakeDir, shellenvFile, tmpDir)
+
+			output, err := shellrun.Run(shell, script, time.Minute)
+			if err != nil {
+				t.Fatalf("%s failed: %v\nOutput: %s", shell, err, output)
+			}
+			out := string(output)
+
+			first, second, ok := strings.Cut(out, "exit=3")
+			if !ok {
+				t.Fatalf("exit code 3 was not propagated:\n%s", out)
+			}
+			i1, i2, i3 := strings.Index(first, "OUT-1"), strings.Index(first, "ERR-2"), strings.Index(first, "OUT-3")
+			if i1 < 0 || i2 < i1 || i3 < i2 {
+				t.Errorf("stdout and stderr are out of order:\n%s", first)
+			}
+			if !strings.Contains(second, "pwd="+tmpDir+"\n") {
+				t.Errorf("wrapper changed directory after a failure:\n%s", out)
+			}
+			if !strings.Contains(second, "exit=0 pwd="+target) {
+				t.Errorf("wrapper did not cd after success:\n%s", out)
+			}
+		})
+	}
+}
+
+// TestE2ERepairAfterMovingRoot moves WORKTREE_ROOT with mv and checks that
+// wt repair makes git work inside every worktree again.
+func TestE2ERepairAfterMovingRoot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	oldRoot := filepath.Join(tmpDir, "worktrees")
+	newRoot := filepath.Join(tmpDir, "moved")
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(root string, args ...string) (string, error) {
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+root, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+	branches := []string{"alpha", "feature/beta"}
+	for _, branch := range branches {
+		if output, err := wt(oldRoot, "create", branch); err != nil {
+			t.Fatalf("wt create %s failed: %v\n%s", branch, err, output)
+		}
+	}
+
+	if err := os.Rename(oldRoot, newRoot); err != nil {
+		t.Fatal(err)
+	}
+	gitStatus := func(branch string) error {
+		return exec.Command("git", "-C", filepath.Join(newRoot, "test-repo", filepath.FromSlash(branch)), "status", "--short").Run()
+	}
+	broken := func() int {
+		worktrees, err := listWorktrees(repoDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(brokenWorktrees(worktrees))
+	}
+	if n := broken(); n != len(branches) {
+		t.Fatalf("%d broken worktrees before repair, want %d", n, len(branches))
+	}
+
+	output, err := wt(newRoot, "repair")
+	if err != nil {
+		t.Fatalf("wt repair failed: %v\n%s", err, output)
+	}
+	for _, branch := range branches {
+		if !strings.Contains(output, "Repaired "+filepath.Join(newRoot, "test-repo", filepath.FromSlash(branch))) {
+			t.Errorf("wt repair did not report %s:\n%s", branch, output)
+		}
+		if err := gitStatus(branch); err != nil {
+			t.Errorf("git status in %s after repair: %v", branch, err)
+		}
+	}
+	if n := broken(); n != 0 {
+		t.Errorf("%d worktrees still broken after repair", n)
+	}
+
+	if output, err := wt(newRoot, "repair"); err != nil || !strings.Contains(output, "linked correctly") {
+		t.Errorf("second wt repair = %v\n%s, want nothing to repair", err, output)
+	}
+}
+
+// TestE2ECheckoutAfterDeletingWorktree simulates `rm -rf` of a worktree
+// directory: git still has it registered, and wt offers to prune it.
+func TestE2ECheckoutAfterDeletingWorktree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	root := filepath.Join(tmpDir, "worktrees")
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(args ...string) (string, error) {
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+root, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+	path := filepath.Join(root, "test-repo", "feature")
+	if output, err := wt("create", "feature"); err != nil {
+		t.Fatalf("wt create failed: %v\n%s", err, output)
+	}
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a terminal wt explains and refuses to prune unasked.
+	output, err := wt("checkout", "feature")
+	if err == nil {
+		t.Fatalf("wt checkout without --yes should refuse to prune:\n%s", output)
+	}
+	for _, want := range []string{"at " + path + " was deleted", "pass --yes"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("worktree directory recreated without confirmation: %v", err)
+	}
+
+	output, err = wt("checkout", "feature", "--yes")
+	if err != nil {
+		t.Fatalf("wt checkout --yes failed: %v\n%s", err, output)
+	}
+	for _, want := range []string{"Pruned the stale worktree of 'feature'", "TREE_ME_CD:" + path} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		t.Errorf("worktree not recreated: %v", err)
+	}
+}
+
+// TestE2ECommandsFromLinkedWorktree runs wt from inside a linked worktree and
+// checks that it acts on the repository, not on the worktree it runs in.
+func TestE2ECommandsFromLinkedWorktree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	root := filepath.Join(tmpDir, "worktrees")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "branch", "existing")
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+root, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("wt %s in %s failed: %v\n%s", strings.Join(args, " "), dir, err, output)
+		}
+		return string(output)
+	}
+
+	wt(repoDir, "create", "first")
+	linked := filepath.Join(root, "test-repo", "first")
+	// Revisions such as HEAD are the linked worktree's, which is ahead of main.
+	runGitCommand(t, linked, "commit", "-q", "--allow-empty", "-m", "only on first")
+	head := strings.TrimSpace(gitOutput(t, linked, "rev-parse", "HEAD"))
+	shortHead := strings.TrimSpace(gitOutput(t, linked, "rev-parse", "--short", "HEAD"))
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"create", "second"}, "TREE_ME_CD:" + filepath.Join(root, "test-repo", "second")},
+		{[]string{"create", "from-head", "--base", "HEAD"}, "TREE_ME_CD:" + filepath.Join(root, "test-repo", "from-head")},
+		{[]string{"create", "--at", "HEAD", "--branch", "at-head"}, "TREE_ME_CD:" + filepath.Join(root, "test-repo", "at-head")},
+		{[]string{"bisect", "main", "HEAD"}, "TREE_ME_CD:" + filepath.Join(root, "test-repo", "bisect-"+shortHead)},
+		{[]string{"checkout", "existing"}, "TREE_ME_CD:" + filepath.Join(root, "test-repo", "existing")},
+		{[]string{"list", "--porcelain"}, repoDir + "\tmain\t"},
+		{[]string{"list", "--tree"}, "test-repo\n"},
+		{[]string{"info", "second"}, "Owned by wt:   yes"},
+		{[]string{"adopt", "existing"}, "now owned by wt"},
+		{[]string{"recent"}, filepath.Join(root, "test-repo", "existing")},
+		{[]string{"repair"}, "linked correctly"},
+		{[]string{"remove", "second", "--yes", "--delete-branch"}, "Deleted branch second"},
+	}
+	for _, tt := range tests {
+		if out := wt(linked, tt.args...); !strings.Contains(out, tt.want) {
+			t.Errorf("wt %s from a linked worktree: output missing %q:\n%s", strings.Join(tt.args, " "), tt.want, out)
+		}
+	}
+	for _, branch := range []string{"from-head", "at-head"} {
+		if got := strings.TrimSpace(gitOutput(t, repoDir, "rev-parse", branch)); got != head {
+			t.Errorf("%s starts at %s, want the HEAD of the linked worktree, %s", branch, got, head)
+		}
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "test-repo" {
+		t.Errorf("worktrees were created outside %s: %v", filepath.Join(root, "test-repo"), entries)
+	}
+}
+
+// TestE2ENoRemote runs the everyday commands in a repository without any
+// remote whose only branch is not called main.
+func TestE2ENoRemote(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "local")
+	root := filepath.Join(tmpDir, "worktrees")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "branch", "-M", "trunk")
+	runGitCommand(t, repoDir, "branch", "existing")
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(args ...string) (string, error) {
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+root, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"checkout", "existing"}, "TREE_ME_CD:" + filepath.Join(root, "local", "existing")},
+		{[]string{"create", "feature"}, "TREE_ME_CD:" + filepath.Join(root, "local", "feature")},
+		{[]string{"list", "--porcelain"}, filepath.Join(root, "local", "feature") + "\tfeature\t"},
+		{[]string{"remove", "feature", "--yes", "--delete-branch"}, "Deleted branch feature"},
+	}
+	for _, tt := range tests {
+		output, err := wt(tt.args...)
+		if err != nil {
+			t.Fatalf("wt %s failed: %v\n%s", strings.Join(tt.args, " "), err, output)
+		}
+		if !strings.Contains(output, tt.want) {
+			t.Errorf("wt %s: output missing %q:\n%s", strings.Join(tt.args, " "), tt.want, output)
+		}
+	}
+
+	// A checkout of a branch that does not exist anywhere must not look for it
+	// on origin.
+	if output, err := wt("checkout", "missing"); err == nil || strings.Contains(output, "origin") {
+		t.Errorf("wt checkout of a missing branch: err = %v, output:\n%s", err, output)
+	}
+
+	for _, args := range [][]string{{"pr", "1"}, {"mr", "1"}, {"pr", "list"}} {
+		output, err := wt(args...)
+		if err == nil || !strings.Contains(output, "repository has no remote") {
+			t.Errorf("wt %s: err = %v, want the no-remote error:\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+}
+
+// TestE2ERemoveFromNestedDirectory runs `wt rm .` and `wt list` from deep
+// inside a worktree, also through symlinked directories, and checks that wt
+// recognises the worktree it runs in.
+func TestE2ERemoveFromNestedDirectory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks need extra privileges on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	root := filepath.Join(tmpDir, "worktrees")
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+root, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("wt %s in %s failed: %v\n%s", strings.Join(args, " "), dir, err, output)
+		}
+		return string(output)
+	}
+	mkdir := func(dir string) string {
+		t.Helper()
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	symlink := func(target, link string) string {
+		t.Helper()
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+		return link
+	}
+
+	// node_modules is ignored, as in any JavaScript project.
+	if err := os.WriteFile(filepath.Join(repoDir, ".git", "info", "exclude"), []byte("node_modules\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A link to the worktree root, as when it lives on another disk.
+	rootLink := symlink(root, filepath.Join(tmpDir, "trees-link"))
+	mkdir(root)
+
+	tests := []struct {
+		name string
+		dir  func(path string) string
+	}{
+		{"nested", func(path string) string {
+			return mkdir(filepath.Join(path, "a", "b", "c", "d", "e", "f", "g", "h", "i", "j"))
+		}},
+		{"symlinked node_modules", func(path string) string {
+			mkdir(filepath.Join(path, "packages", "lib", "src"))
+			symlink(filepath.Join(path, "packages", "lib"), filepath.Join(mkdir(filepath.Join(path, "node_modules")), "lib"))
+			return filepath.Join(path, "node_modules", "lib", "src")
+		}},
+		{"through a symlinked root", func(path string) string {
+			mkdir(filepath.Join(path, "deep"))
+			return filepath.Join(rootLink, "test-repo", filepath.Base(path), "deep")
+		}},
+	}
+	for i, tt := range tests {
+		branch := fmt.Sprintf("feature-%d", i)
+		wt(repoDir, "create", branch)
+		path := filepath.Join(root, "test-repo", branch)
+		dir := tt.dir(path)
+
+		if out := wt(dir, "list", "--porcelain"); !strings.Contains(out, path+"\t"+branch+"\t") {
+			t.Errorf("%s: wt list missing %s:\n%s", tt.name, branch, out)
+		}
+		out := wt(dir, "rm", ".", "--yes")
+		for _, want := range []string{"Removed worktree: " + path, "TREE_ME_CD:" + repoDir} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: wt rm . output missing %q:\n%s", tt.name, want, out)
+			}
+		}
+	}
+}
+
+// TestE2EWorktreeRootFlag checks that --worktree-root overrides
+// WORKTREE_ROOT for one invocation, in every command.
+func TestE2EWorktreeRootFlag(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	envRoot := filepath.Join(tmpDir, "env-root")
+	flagRoot := filepath.Join(tmpDir, "flag-root")
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+envRoot, "WT_STATE_DIR="+filepath.Join(tmpDir, "state"))
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("wt %s failed: %v\n%s", strings.Join(args, " "), err, output)
+		}
+		return string(output)
+	}
+
+	path := filepath.Join(flagRoot, "test-repo", "feature")
+	if out := wt("--worktree-root", flagRoot, "create", "feature"); !strings.Contains(out, "TREE_ME_CD:"+path) {
+		t.Errorf("wt create --worktree-root: output missing %s:\n%s", path, out)
+	}
+	if _, err := os.Stat(filepath.Join(envRoot, "test-repo", "feature")); !os.IsNotExist(err) {
+		t.Errorf("worktree created under WORKTREE_ROOT despite --worktree-root: %v", err)
+	}
+	if out := wt("env", "--worktree-root", flagRoot); !strings.Contains(out, "WORKTREE_ROOT="+shellQuote(flagRoot)) {
+		t.Errorf("wt env --worktree-root: output missing the flag's root:\n%s", out)
+	}
+	if out := wt("env"); !strings.Contains(out, "WORKTREE_ROOT="+shellQuote(envRoot)) {
+		t.Errorf("wt env: output missing WORKTREE_ROOT:\n%s", out)
+	}
+	if out := wt("--help", "--worktree-root="+flagRoot); !strings.Contains(out, "Worktrees are organized at: "+flagRoot) {
+		t.Errorf("wt --help --worktree-root: help does not show the flag's root:\n%s", out)
+	}
+
+	out := wt("--worktree-root", "trees", "env")
+	if !strings.Contains(out, `warning: --worktree-root "trees" is relative`) {
+		t.Errorf("relative --worktree-root: no warning:\n%s", out)
+	}
+}
+
+// TestE2EIgnoresGitEnv checks that GIT_DIR and GIT_WORK_TREE exported by
+// hooks or IDEs do not redirect wt to another repository.
+func TestE2EIgnoresGitEnv(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	otherDir := filepath.Join(tmpDir, "unrelated")
+	worktreeRoot := filepath.Join(tmpDir, "worktrees")
+	setupTestRepo(t, repoDir)
+	setupTestRepo(t, otherDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(args ...string) (string, error) {
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(),
+			"WORKTREE_ROOT="+worktreeRoot,
+			"GIT_DIR="+filepath.Join(otherDir, ".git"),
+			"GIT_WORK_TREE="+otherDir,
+		)
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	output, err := wt("create", "feature")
+	if err != nil {
+		t.Fatalf("wt create failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(output, "warning: ignoring GIT_DIR, GIT_WORK_TREE from the environment") {
+		t.Errorf("wt create did not warn about the git environment:\n%s", output)
+	}
+	if _, err := os.Stat(filepath.Join(worktreeRoot, "test-repo", "feature")); err != nil {
+		t.Errorf("worktree not created for the repository of the current directory: %v", err)
+	}
+	if err := exec.Command("git", "-C", otherDir, "rev-parse", "--verify", "--quiet", "refs/heads/feature").Run(); err == nil {
+		t.Error("branch created in the repository GIT_DIR points at")
+	}
+
+	output, err = wt("list", "--porcelain")
+	if err != nil || !strings.Contains(output, repoDir) || strings.Contains(output, otherDir) {
+		t.Errorf("wt list should list the worktrees of the current directory: %v\n%s", err, output)
+	}
+	output, err = wt("--respect-git-env", "list", "--porcelain")
+	if err != nil || !strings.Contains(output, otherDir) || strings.Contains(output, "warning:") {
+		t.Errorf("wt --respect-git-env list should honor GIT_DIR: %v\n%s", err, output)
+	}
+}
+
 // Helper functions
 
 func setupTestRepo(t *testing.T, repoDir string) {
 	t.Helper()
 
-	if err := os.MkdirAll(repoDir, 0755); err != nil {
-		t.Fatalf("Failed to create repo dir: %v", err)
+	if err := testrepo.Init(repoDir); err != nil {
+		t.Fatalf("Failed to set up test repo: %v", err)
 	}
-
-	// Initialize git repo
-	runGitCommand(t, repoDir, "init")
-	runGitCommand(t, repoDir, "config", "user.email", "test@example.com")
-	runGitCommand(t, repoDir, "config", "user.name", "Test User")
-	runGitCommand(t, repoDir, "commit", "--allow-empty", "-m", "initial commit")
-	runGitCommand(t, repoDir, "branch", "-M", "main")
 }
 
 func buildWtBinary(t *testing.T, tmpDir string) string {
@@ -260,10 +1050,62 @@ func buildWtBinary(t *testing.T, tmpDir string) string {
 func runGitCommand(t *testing.T, dir string, args ...string) {
 	t.Helper()
 
-	cmd := exec.Command("git", args...)
-	cmd.Dir = dir
-	if output, err := cmd.CombinedOutput(); err != nil {
-		t.Fatalf("Git command failed: git %v\nError: %v\nOutput: %s",
-			args, err, output)
+	if err := testrepo.Git(dir, args...); err != nil {
+		t.Fatalf("Git command failed: %v", err)
+	}
+}
+
+// assertStdoutJSON decodes stdout as JSON and validates the result with check.
+func assertStdoutJSON(t *testing.T, stdout string, check func(decoded any) error) {
+	t.Helper()
+
+	var decoded any
+	if err := json.Unmarshal([]byte(stdout), &decoded); err != nil {
+		t.Fatalf("stdout is not JSON: %v\nOutput: %s", err, stdout)
+	}
+	if err := check(decoded); err != nil {
+		t.Errorf("unexpected JSON on stdout: %v\nOutput: %s", err, stdout)
+	}
+}
+
+// assertGolden compares stdout, normalized with roots, against
+// testdata/<name>; -update rewrites the file.
+func assertGolden(t *testing.T, name, stdout string, roots map[string]string) {
+	t.Helper()
+
+	checkGolden(t, name, normalizeOutput(stdout, roots))
+}
+
+// placeholderPath matches a placeholder such as $TMP with the path after it.
+var placeholderPath = regexp.MustCompile(`\$[A-Z_]+[^\s"']*`)
+
+// normalizeOutput replaces the fixture roots in out by their placeholders,
+// e.g. {"$TMP": tmpDir}, and turns the separators of the paths below them into
+// slashes, so that one golden file serves unix and Windows. Roots are also
+// found with symlinks resolved, with slashes and escaped for JSON.
+func normalizeOutput(out string, roots map[string]string) string {
+	type replacement struct{ root, placeholder string }
+	var replacements []replacement
+	for placeholder, root := range roots {
+		forms := []string{root}
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			forms = append(forms, resolved)
+		}
+		for _, form := range forms {
+			replacements = append(replacements,
+				replacement{form, placeholder},
+				replacement{strings.ReplaceAll(form, `\`, "/"), placeholder},
+				replacement{strings.ReplaceAll(form, `\`, `\\`), placeholder})
+		}
+	}
+	// Longer roots first, so that a root below another one wins.
+	sort.Slice(replacements, func(i, j int) bool {
+		return len(replacements[i].root) > len(replacements[j].root)
+	})
+	for _, r := range replacements {
+		out = strings.ReplaceAll(out, r.root, r.placeholder)
 	}
+	return placeholderPath.ReplaceAllStringFunc(out, func(path string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(path, `\\`, "/"), `\`, "/")
+	})
 }
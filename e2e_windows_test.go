@@ -4,6 +4,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -107,6 +108,46 @@ Get-Location | Select-Object -ExpandProperty Path
 	}
 }
 
+// TestE2EAutoCdWithPowerShellSpacesAndBrackets tests that the PowerShell
+// function follows wt into a worktree root whose path contains spaces and
+// brackets, which Set-Location would otherwise read as a wildcard pattern
+func TestE2EAutoCdWithPowerShellSpacesAndBrackets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	powershell := findPowerShell(t)
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	worktreeRoot := filepath.Join(tmpDir, "my trees [work]")
+
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	script := fmt.Sprintf(`
+$env:WORKTREE_ROOT = '%s'
+$env:PATH = '%s;' + $env:PATH
+Set-Location '%s'
+
+Invoke-Expression (& '%s' shellenv)
+wt create spaced-pwsh
+
+Get-Location | Select-Object -ExpandProperty Path
+`, worktreeRoot, filepath.Dir(wtBinary), repoDir, wtBinary)
+
+	cmd := exec.Command(powershell, "-NoProfile", "-NonInteractive", "-Command", script)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to run PowerShell e2e test: %v\nOutput: %s", err, output)
+	}
+
+	expectedPath := filepath.Join(worktreeRoot, "test-repo", "spaced-pwsh")
+	if !strings.Contains(string(output), expectedPath) {
+		t.Errorf("E2E FAIL: Auto-cd didn't reach %s in PowerShell\nOutput: %s", expectedPath, output)
+	}
+}
+
 // TestE2EPowerShellShellenvOutput tests that shellenv outputs valid PowerShell code
 func TestE2EPowerShellShellenvOutput(t *testing.T) {
 	if testing.Short() {
@@ -205,3 +246,110 @@ func findPowerShell(t *testing.T) string {
 	t.Skip("PowerShell not available, skipping PowerShell tests")
 	return ""
 }
+
+// TestE2EAutoCdWithGitBash tests that auto-cd works in Git Bash, where the
+// shell expects /c/... paths instead of C:\...
+func TestE2EAutoCdWithGitBash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	gitBash := filepath.Join(os.Getenv("ProgramFiles"), "Git", "bin", "bash.exe")
+	if _, err := os.Stat(gitBash); err != nil {
+		t.Skip("Git Bash not available, skipping Git Bash test")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	worktreeRoot := filepath.Join(tmpDir, "worktrees")
+
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	runGitCommand(t, repoDir, "checkout", "-b", "gitbash-test-branch")
+	runGitCommand(t, repoDir, "commit", "--allow-empty", "-m", "test commit")
+	runGitCommand(t, repoDir, "checkout", "main")
+
+	script := fmt.Sprintf(`
+export WORKTREE_ROOT='%s'
+export PATH="$(cygpath -u '%s'):$PATH"
+cd "$(cygpath -u '%s')"
+source <(wt shellenv)
+wt checkout gitbash-test-branch
+pwd -W
+`, filepath.ToSlash(worktreeRoot), filepath.ToSlash(filepath.Dir(wtBinary)), filepath.ToSlash(repoDir))
+
+	cmd := exec.Command(gitBash, "-c", script)
+	cmd.Env = append(os.Environ(), "MSYSTEM=MINGW64")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to run Git Bash e2e test: %v\nOutput: %s", err, output)
+	}
+
+	expectedPath := filepath.ToSlash(filepath.Join(worktreeRoot, "test-repo", "gitbash-test-branch"))
+	if !strings.Contains(strings.ToLower(string(output)), strings.ToLower(expectedPath)) {
+		t.Errorf("E2E FAIL: Auto-cd didn't work in Git Bash!\nExpected to be in: %s\nOutput: %s",
+			expectedPath, output)
+	}
+}
+
+// TestE2EAutoCdWithCmd tests the cmd.exe integration: a batch file calls the
+// wrapper written by 'wt shellenv --shell cmd' and ends up in the worktree.
+func TestE2EAutoCdWithCmd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	// Mixed separators must not leak into wt's output.
+	worktreeRoot := filepath.ToSlash(filepath.Join(tmpDir, "worktrees"))
+	stateDir := filepath.Join(tmpDir, "state")
+
+	setupTestRepo(t, repoDir)
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	runGitCommand(t, repoDir, "checkout", "-b", "cmd-test-branch")
+	runGitCommand(t, repoDir, "commit", "--allow-empty", "-m", "test commit")
+	runGitCommand(t, repoDir, "checkout", "main")
+
+	env := append(os.Environ(), "WORKTREE_ROOT="+worktreeRoot, "WT_STATE_DIR="+stateDir)
+	shellenv := exec.Command(wtBinary, "shellenv", "--shell", "cmd")
+	shellenv.Env = env
+	integration, err := shellenv.Output()
+	if err != nil {
+		t.Fatalf("wt shellenv --shell cmd failed: %v", err)
+	}
+	shim := filepath.Join(stateDir, "wt.cmd")
+	if !strings.Contains(string(integration), `doskey wt=call "`+shim+`" $*`) {
+		t.Fatalf("shellenv output does not define the doskey macro:\n%s", integration)
+	}
+
+	script := filepath.Join(tmpDir, "test.cmd")
+	batch := strings.Join([]string{
+		"@echo off",
+		`cd /d "` + repoDir + `"`,
+		`call "` + shim + `" checkout cmd-test-branch`,
+		"if errorlevel 1 exit /b 1",
+		"echo PWD=%CD%",
+	}, "\r\n") + "\r\n"
+	if err := os.WriteFile(script, []byte(batch), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("cmd.exe", "/d", "/c", script)
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to run cmd.exe e2e test: %v\nOutput: %s", err, output)
+	}
+
+	expectedPath := filepath.Join(tmpDir, "worktrees", "test-repo", "cmd-test-branch")
+	if !strings.Contains(strings.ToLower(string(output)), strings.ToLower("PWD="+expectedPath)) {
+		t.Errorf("E2E FAIL: Auto-cd didn't work in cmd.exe!\nExpected to be in: %s\nOutput: %s",
+			expectedPath, output)
+	}
+	if !strings.Contains(string(output), "TREE_ME_CD:"+expectedPath) {
+		t.Errorf("marker path is not in native form, want %s:\n%s", expectedPath, output)
+	}
+}
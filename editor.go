@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// editorWaitFlags are the flags that make editors which open a window and
+// return, or hand the file to a running instance, block until it is closed.
+// editorWaitFlags in the config adds to and overrides them; an empty flag
+// means the editor waits by itself.
+var editorWaitFlags = map[string]string{
+	"atom":          "--wait",
+	"code":          "--wait",
+	"code-insiders": "--wait",
+	"codium":        "--wait",
+	"cursor":        "--wait",
+	"gvim":          "--nofork",
+	"idea":          "--wait",
+	"mate":          "--wait",
+	"mvim":          "--nofork",
+	"subl":          "--wait",
+	"windsurf":      "--wait",
+	"zed":           "--wait",
+}
+
+// terminalEditors run in the terminal wt runs in, so they are never
+// detached from it.
+var terminalEditors = []string{"emacs", "hx", "joe", "kak", "micro", "nano", "nvim", "vi", "vim"}
+
+// editorName is the name of the program of an editor command, as the tables
+// above know it: code for /usr/local/bin/code or C:\...\code.cmd.
+func editorName(program string) string {
+	name := filepath.Base(strings.ReplaceAll(program, `\`, "/"))
+	for _, ext := range []string{".exe", ".cmd", ".bat"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return strings.ToLower(name)
+}
+
+// editorArgs returns the command line opening target in editor, split on
+// spaces as in "code --new-window". With wait, the wait flag of the editor is
+// added unless it is already there.
+func editorArgs(editor, target string, wait bool, overrides map[string]string) ([]string, error) {
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no editor set; set VISUAL or EDITOR")
+	}
+	if wait {
+		name := editorName(args[0])
+		flag, ok := overrides[name]
+		if !ok {
+			flag = editorWaitFlags[name]
+		}
+		if flag != "" && !slices.Contains(args[1:], flag) {
+			args = append(args, flag)
+		}
+	}
+	return append(args, target), nil
+}
+
+// openInEditor opens target in the editor. With wait, or for an editor that
+// runs in the terminal, wt waits for it and exits with its status; otherwise
+// the editor is started detached, so closing the terminal leaves it open.
+func openInEditor(cmd *cobra.Command, target string, wait, verbose bool) error {
+	args, err := editorArgs(editorCommand(), target, wait, getConfig().EditorWaitFlags)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "running: %s\n", strings.Join(args, " "))
+	}
+	editor := exec.Command(args[0], args[1:]...)
+	if err := setWorktreeEnv(editor, editorDir(target), verbose); err != nil {
+		return err
+	}
+	if !wait && !slices.Contains(terminalEditors, editorName(args[0])) {
+		detach(editor)
+		if err := editor.Start(); err != nil {
+			return fmt.Errorf("could not start the editor: %w", err)
+		}
+		return editor.Process.Release()
+	}
+	editor.Stdin = os.Stdin
+	// The editor is not data: stdout is piped by the shell integration.
+	editor.Stdout = os.Stderr
+	editor.Stderr = os.Stderr
+	err = editor.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitWithCode(cmd, exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("could not run the editor: %w", err)
+	}
+	return nil
+}
+
+// editorDir is the directory holding target, which may be a file.
+func editorDir(target string) string {
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		return filepath.Dir(target)
+	}
+	return target
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditorArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		editor    string
+		wait      bool
+		overrides map[string]string
+		want      []string
+	}{
+		{"detached", "code", false, nil, []string{"code", "/w"}},
+		{"known wait flag", "code", true, nil, []string{"code", "--wait", "/w"}},
+		{"with arguments", "subl -n", true, nil, []string{"subl", "-n", "--wait", "/w"}},
+		{"flag already given", "code --wait", true, nil, []string{"code", "--wait", "/w"}},
+		{"full path", "/usr/local/bin/zed", true, nil, []string{"/usr/local/bin/zed", "--wait", "/w"}},
+		{"windows shim", `C:\Tools\Code.cmd`, true, nil, []string{`C:\Tools\Code.cmd`, "--wait", "/w"}},
+		{"other flag", "gvim", true, nil, []string{"gvim", "--nofork", "/w"}},
+		{"unknown editor waits itself", "vim", true, nil, []string{"vim", "/w"}},
+		{"configured editor", "myedit", true, map[string]string{"myedit": "--block"}, []string{"myedit", "--block", "/w"}},
+		{"override", "code", true, map[string]string{"code": "-w"}, []string{"code", "-w", "/w"}},
+		{"override turned off", "code", true, map[string]string{"code": ""}, []string{"code", "/w"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := editorArgs(tt.editor, "/w", tt.wait, tt.overrides)
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("editorArgs(%q, wait=%v) = %q, %v; want %q", tt.editor, tt.wait, got, err, tt.want)
+			}
+		})
+	}
+	if _, err := editorArgs("  ", "/w", true, nil); err == nil {
+		t.Error("editorArgs() with an empty editor should fail")
+	}
+}
@@ -0,0 +1,86 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+)
+
+func TestDetach(t *testing.T) {
+	cmd := exec.Command("sleep", "5")
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	sid, err := unix.Getsid(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	own, _ := unix.Getsid(0)
+	if sid != cmd.Process.Pid || sid == own {
+		t.Errorf("session of the detached process = %d, want its own (%d), not wt's (%d)", sid, cmd.Process.Pid, own)
+	}
+}
+
+// fakeEditor writes an editor script that records its arguments in a file
+// next to it and exits with code.
+func fakeEditor(t *testing.T, code string) (editor, record string) {
+	t.Helper()
+	dir := t.TempDir()
+	editor = filepath.Join(dir, "edit")
+	record = filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + record + ".tmp && mv " + record + ".tmp " + record + "\nexit " + code + "\n"
+	if err := os.WriteFile(editor, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return editor, record
+}
+
+func TestOpenInEditor(t *testing.T) {
+	origConfig := loadedConfig
+	t.Cleanup(func() { loadedConfig = origConfig })
+	t.Setenv("VISUAL", "")
+
+	editor, record := fakeEditor(t, "3")
+	loadedConfig = &Config{EditorWaitFlags: map[string]string{"edit": "--block"}}
+	t.Setenv("EDITOR", editor)
+	err := openInEditor(&cobra.Command{Use: "open"}, "/w", true, false)
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != 3 {
+		t.Fatalf("openInEditor(wait) = %v, want the editor's exit status 3", err)
+	}
+	if data, _ := os.ReadFile(record); string(data) != "--block /w\n" {
+		t.Errorf("editor got %q, want the wait flag and the path", data)
+	}
+
+	editor, record = fakeEditor(t, "3")
+	t.Setenv("EDITOR", editor)
+	if err := openInEditor(&cobra.Command{Use: "open"}, "/w", false, false); err != nil {
+		t.Fatalf("openInEditor() detached = %v, want no error whatever the editor returns", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, err := os.ReadFile(record); err == nil {
+			if string(data) != "/w\n" {
+				t.Errorf("detached editor got %q, want only the path", data)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the detached editor did not run")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
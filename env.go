@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Editor integrations run wt as a subprocess from arbitrary directories. They
+// select the repository with WT_REPO_DIR (any directory inside it) and the
+// branch with WT_BRANCH instead of changing directory first; arguments on the
+// command line always win.
+const (
+	repoDirEnv = "WT_REPO_DIR"
+	branchEnv  = "WT_BRANCH"
+)
+
+// repoIndependentCommands do not act on a repository and ignore WT_REPO_DIR,
+// so that a stale value cannot break e.g. the shell startup.
+var repoIndependentCommands = map[string]bool{
+	"__emit-test-marker": true,
+	"clone":              true,
+	"demo":               true,
+	"help":               true,
+	"shellenv":           true,
+	"version":            true,
+}
+
+// checkRepoDir returns the error for a WT_REPO_DIR that is not an absolute
+// path to a directory inside a git repository.
+func checkRepoDir(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("%s=%s: expected an absolute path", repoDirEnv, dir)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%s=%s: no such directory", repoDirEnv, dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s=%s: not a directory", repoDirEnv, dir)
+	}
+	if gitIn(dir, "rev-parse", "--git-dir").Run() != nil {
+		return fmt.Errorf("%s=%s: not inside a git repository", repoDirEnv, dir)
+	}
+	return nil
+}
+
+// applyRepoDirEnv makes WT_REPO_DIR the working directory of cmd, as if wt
+// had been started there.
+func applyRepoDirEnv(cmd *cobra.Command) error {
+	dir := os.Getenv(repoDirEnv)
+	if dir == "" || repoIndependentCommands[cmd.Name()] {
+		return nil
+	}
+	dir = hostPath(dir)
+	if err := checkRepoDir(dir); err != nil {
+		return err
+	}
+	return os.Chdir(dir)
+}
+
+// branchArgs returns args, or WT_BRANCH as the branch argument when none is
+// given on the command line.
+func branchArgs(args []string) []string {
+	if branch := os.Getenv(branchEnv); len(args) == 0 && branch != "" {
+		return []string{branch}
+	}
+	return args
+}
+
+// wtEnv returns the WORKTREE_ROOT, WT_REPO_DIR and WT_BRANCH that select the
+// current worktree, in that order. Outside a repository the latter two are
+// empty.
+func wtEnv() [][2]string {
+	repoDir, branch := "", os.Getenv(branchEnv)
+	if output, err := gitIn("", "rev-parse", "--show-toplevel").Output(); err == nil {
+		repoDir = filepath.Clean(strings.TrimSpace(string(output)))
+		if branch == "" {
+			output, _ := gitIn("", "symbolic-ref", "--short", "-q", "HEAD").Output()
+			branch = strings.TrimSpace(string(output))
+		}
+	}
+	return [][2]string{
+		{"WORKTREE_ROOT", worktreeRoot},
+		{repoDirEnv, repoDir},
+		{branchEnv, branch},
+	}
+}
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print the environment selecting the current worktree",
+	Long: `Print WORKTREE_ROOT, WT_REPO_DIR and WT_BRANCH for the current worktree.
+
+Editor integrations run wt from arbitrary directories: with WT_REPO_DIR set
+to a directory inside a repository, wt behaves as if started there, and
+WT_BRANCH is the default for commands taking an optional branch (checkout,
+remove, info, pin, park, move, ...). Arguments on the command line override
+both. The output is in the same form, for passing to later invocations.
+
+Examples:
+  wt env                              # KEY=value lines, quoted for the shell
+  wt env --json                       # the same as a JSON object
+  WT_REPO_DIR=/src/api wt info        # info about the worktree at /src/api`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		vars := wtEnv()
+		for i, v := range vars {
+			if v[0] != branchEnv && v[1] != "" {
+				vars[i][1] = displayPath(v[1])
+			}
+		}
+		if asJSON {
+			values := make(map[string]string, len(vars))
+			for _, v := range vars {
+				values[v[0]] = v[1]
+			}
+			return writeJSON(os.Stdout, values)
+		}
+		for _, v := range vars {
+			value := "''"
+			if v[1] != "" {
+				value = shellQuote(v[1])
+			}
+			fmt.Printf("%s=%s\n", v[0], value)
+		}
+		return nil
+	},
+}
+
+func init() {
+	envCmd.Flags().Bool("json", false, "Output as JSON")
+}
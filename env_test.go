@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestBranchArgs(t *testing.T) {
+	t.Setenv(branchEnv, "")
+	if got := branchArgs(nil); len(got) != 0 {
+		t.Errorf("branchArgs() without WT_BRANCH = %v, want none", got)
+	}
+
+	t.Setenv(branchEnv, "feature-x")
+	if got := branchArgs(nil); !reflect.DeepEqual(got, []string{"feature-x"}) {
+		t.Errorf("branchArgs() = %v, want WT_BRANCH", got)
+	}
+	if got := branchArgs([]string{"other"}); !reflect.DeepEqual(got, []string{"other"}) {
+		t.Errorf("branchArgs(other) = %v, the command line must win", got)
+	}
+}
+
+func TestCheckRepoDir(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	file := filepath.Join(tmpDir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkRepoDir(repoDir); err != nil {
+		t.Errorf("checkRepoDir(repo) = %v", err)
+	}
+	for dir, want := range map[string]string{
+		"repo":                          "expected an absolute path",
+		filepath.Join(tmpDir, "absent"): "no such directory",
+		file:                            "not a directory",
+		tmpDir:                          "not inside a git repository",
+	} {
+		err := checkRepoDir(dir)
+		if err == nil || !strings.HasPrefix(err.Error(), "WT_REPO_DIR="+dir+": ") || !strings.Contains(err.Error(), want) {
+			t.Errorf("checkRepoDir(%q) = %v, want an error naming WT_REPO_DIR: %s", dir, err, want)
+		}
+	}
+}
+
+func TestApplyRepoDirEnv(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	t.Chdir(t.TempDir())
+	cwd, _ := os.Getwd()
+
+	t.Setenv(repoDirEnv, filepath.Join(repoDir, "missing"))
+	if err := applyRepoDirEnv(&cobra.Command{Use: "version"}); err != nil {
+		t.Errorf("repository independent commands must ignore WT_REPO_DIR: %v", err)
+	}
+	if err := applyRepoDirEnv(&cobra.Command{Use: "list"}); err == nil {
+		t.Error("an invalid WT_REPO_DIR must fail")
+	}
+	if now, _ := os.Getwd(); now != cwd {
+		t.Errorf("working directory changed to %s", now)
+	}
+
+	t.Setenv(repoDirEnv, repoDir)
+	if err := applyRepoDirEnv(&cobra.Command{Use: "list"}); err != nil {
+		t.Fatal(err)
+	}
+	vars := wtEnv()
+	if vars[1][1] != resolvePath(repoDir) && vars[1][1] != repoDir {
+		t.Errorf("WT_REPO_DIR in wt env = %q, want %q", vars[1][1], repoDir)
+	}
+	if vars[2][1] != "main" {
+		t.Errorf("WT_BRANCH in wt env = %q, want main", vars[2][1])
+	}
+}
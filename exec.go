@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+// execResult is the outcome of a command in one worktree. ExitCode is -1
+// when the command could not be started, with Error saying why. Skipped
+// marks a worktree the command was not run in after a failure elsewhere.
+type execResult struct {
+	Branch   string        `json:"branch"`
+	Path     string        `json:"path"`
+	ExitCode int           `json:"exitCode"`
+	Duration time.Duration `json:"durationNs"`
+	Error    string        `json:"error,omitempty"`
+	Skipped  bool          `json:"skipped,omitempty"`
+	// Output is the captured stdout and stderr, interleaved.
+	Output string `json:"output,omitempty"`
+}
+
+func (r execResult) failed() bool {
+	return r.ExitCode != 0
+}
+
+// label names the worktree of r in headers and tables.
+func (r execResult) label() string {
+	if r.Branch == "" {
+		return displayPath(r.Path)
+	}
+	return r.Branch
+}
+
+// execTargets returns the worktrees of branches, or every worktree with a
+// working tree when all is set.
+func execTargets(branches []string, all bool) ([]Worktree, error) {
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		return nil, err
+	}
+	var usable []Worktree
+	for _, wt := range worktrees {
+		if !wt.Bare && !wt.Prunable && !isOffline(wt) {
+			usable = append(usable, wt)
+		}
+	}
+	if all {
+		return usable, nil
+	}
+	targets := make([]Worktree, 0, len(branches))
+	for _, branch := range branches {
+		found := false
+		for _, wt := range usable {
+			if wt.Branch != "" && worktree.SameBranch(wt.Branch, branch) {
+				targets = append(targets, wt)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no worktree found for branch: %s", branch)
+		}
+	}
+	return targets, nil
+}
+
+// runInWorktree runs argv in the worktree wt with the env of the config.
+// Without stdout, the output is captured into the result.
+func runInWorktree(wt Worktree, argv []string, stdin io.Reader, stdout, stderr io.Writer) execResult {
+	result := execResult{Branch: wt.Branch, Path: wt.Path}
+	c := exec.Command(argv[0], argv[1:]...)
+	c.Dir = wt.Path
+	var output bytes.Buffer
+	if stdout == nil {
+		c.Stdout, c.Stderr = &output, &output
+	} else {
+		c.Stdin, c.Stdout, c.Stderr = stdin, stdout, stderr
+	}
+	start := time.Now()
+	err := setWorktreeEnv(c, wt.Path, false)
+	if err == nil {
+		err = c.Run()
+	}
+	result.Duration = time.Since(start)
+	result.Output = output.String()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		result.ExitCode = -1
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// prefixWriter writes whole lines to w, each behind prefix. The lines of
+// all writers sharing mu are kept apart, so that worktrees running in
+// parallel do not interleave within a line.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		p.writeLine(p.buf[:i+1])
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// flush writes a last line that lacks its newline.
+func (p *prefixWriter) flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, _ = io.WriteString(p.w, p.prefix)
+	_, _ = p.w.Write(line)
+}
+
+// runExec runs argv in every target, parallel at a time. With capture the
+// output is only kept in the results; otherwise it is streamed as it comes,
+// every line behind the [branch] of its worktree. The command gets stdin
+// only when running one at a time. With stopOnError no further worktree is
+// started once the command failed in one; those are marked skipped.
+func runExec(targets []Worktree, argv []string, parallel int, capture, stopOnError bool) []execResult {
+	results := make([]execResult, len(targets))
+	var mu sync.Mutex
+	var stopped atomic.Bool
+	run := func(i int, wt Worktree, stdin io.Reader) {
+		if stopOnError && stopped.Load() {
+			results[i] = execResult{Branch: wt.Branch, Path: wt.Path, Skipped: true}
+			return
+		}
+		if capture {
+			results[i] = runInWorktree(wt, argv, nil, nil, nil)
+		} else {
+			prefix := "[" + targetLabel(wt) + "] "
+			stdout := &prefixWriter{w: os.Stdout, prefix: prefix, mu: &mu}
+			stderr := &prefixWriter{w: os.Stderr, prefix: prefix, mu: &mu}
+			results[i] = runInWorktree(wt, argv, stdin, stdout, stderr)
+			stdout.flush()
+			stderr.flush()
+		}
+		if results[i].failed() {
+			stopped.Store(true)
+		}
+	}
+
+	if parallel <= 1 {
+		for i, wt := range targets {
+			run(i, wt, os.Stdin)
+		}
+		return results
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i, wt := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			run(i, wt, nil)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func targetLabel(wt Worktree) string {
+	return execResult{Branch: wt.Branch, Path: wt.Path}.label()
+}
+
+// execFailures counts the results that failed.
+func execFailures(results []execResult) int {
+	failed := 0
+	for _, r := range results {
+		if r.failed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// execSkipped counts the worktrees skipped after a failure.
+func execSkipped(results []execResult) int {
+	skipped := 0
+	for _, r := range results {
+		if r.Skipped {
+			skipped++
+		}
+	}
+	return skipped
+}
+
+// execSummary says in which worktrees command failed, and how many were
+// skipped after the first failure.
+func execSummary(command string, results []execResult) string {
+	var failed []string
+	for _, r := range results {
+		if r.failed() {
+			failed = append(failed, fmt.Sprintf("%s (exit %s)", r.label(), formatExitCode(r)))
+		}
+	}
+	summary := fmt.Sprintf("%s failed in %d of %d worktrees: %s", command, len(failed), len(results), strings.Join(failed, ", "))
+	if skipped := execSkipped(results); skipped > 0 {
+		summary += fmt.Sprintf("\nskipped %d worktree(s) after the first failure; pass --continue-on-error to run in all of them", skipped)
+	}
+	return summary
+}
+
+// formatExitCode shows the exit code of r, or why the command did not run.
+func formatExitCode(r execResult) string {
+	if r.Skipped {
+		return "skipped"
+	}
+	if r.ExitCode < 0 {
+		return "error: " + r.Error
+	}
+	return strconv.Itoa(r.ExitCode)
+}
+
+// printExecTable prints one line per worktree with its exit code and how
+// long the command took.
+func printExecTable(w io.Writer, results []execResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKTREE\tEXIT\tDURATION")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.label(), formatExitCode(r), r.Duration.Round(time.Millisecond))
+	}
+	_ = tw.Flush()
+}
+
+// printExecFailures replays the captured output of the failed worktrees.
+func printExecFailures(w io.Writer, results []execResult) {
+	for _, r := range results {
+		if !r.failed() {
+			continue
+		}
+		fmt.Fprintf(w, "==> %s (exit %s)\n", r.label(), formatExitCode(r))
+		if r.Output != "" {
+			fmt.Fprint(w, r.Output)
+			if !strings.HasSuffix(r.Output, "\n") {
+				fmt.Fprintln(w)
+			}
+		}
+	}
+}
+
+// execReport returns results for --json: paths as displayed, and the
+// captured output only of failures and only with showFailures.
+func execReport(results []execResult, showFailures bool) []execResult {
+	report := make([]execResult, len(results))
+	for i, r := range results {
+		r.Path = displayPath(r.Path)
+		if !showFailures || !r.failed() {
+			r.Output = ""
+		}
+		report[i] = r
+	}
+	return report
+}
+
+var execCmd = &cobra.Command{
+	Use:   "exec [branch...] -- <command> [args...]",
+	Short: "Run a command in several worktrees",
+	Long: `Run a command in the worktrees of the given branches, or without branches
+(or with --all) in every worktree of the repository, and exit with status 1
+when it fails in any of them.
+
+The command runs without a shell, in the root of each worktree, with the env
+of the config rendered for that worktree. Its output is streamed as it
+comes, every line prefixed with the [branch] of its worktree. By default the
+worktrees are done one after the other; --parallel N runs N at once (0 for
+one per CPU), without stdin.
+
+wt stops at the first worktree where the command fails and skips the rest;
+with --continue-on-error it runs in all of them. Either way the worktrees
+where it failed are listed at the end.
+
+With --status-only the output is captured instead and a table of worktree,
+exit code and duration is printed at the end; --show-failures replays the
+output of the worktrees where the command failed, and --json prints the
+results as JSON instead of the table. Both run in every worktree, as if
+--continue-on-error was given.
+
+Examples:
+  wt exec -- go mod tidy
+  wt exec --continue-on-error --parallel 4 -- git pull --ff-only
+  wt exec feature-x bugfix-y -- git status --short
+  wt exec --all --parallel 4 --status-only -- make test
+  wt exec --all --status-only --show-failures -- go vet ./...
+  wt exec --all --json -- make lint`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() < 0 || cmd.ArgsLenAtDash() == len(args) {
+			return fmt.Errorf("pass the command to run after --, e.g. 'wt exec --all -- make test'")
+		}
+		return nil
+	},
+	ValidArgsFunction: completeWorktreeBranches,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		branches, argv := args[:cmd.ArgsLenAtDash()], args[cmd.ArgsLenAtDash():]
+		all, _ := cmd.Flags().GetBool("all")
+		if all && len(branches) > 0 {
+			return fmt.Errorf("--all runs in every worktree; do not pass branches")
+		}
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		if parallel <= 0 {
+			parallel = runtime.NumCPU()
+		}
+		statusOnly, _ := cmd.Flags().GetBool("status-only")
+		showFailures, _ := cmd.Flags().GetBool("show-failures")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if showFailures && !statusOnly && !asJSON {
+			return fmt.Errorf("--show-failures is only used with --status-only or --json")
+		}
+
+		continueOnError, _ := cmd.Flags().GetBool("continue-on-error")
+
+		targets, err := execTargets(branches, len(branches) == 0)
+		if err != nil {
+			return err
+		}
+		capture := statusOnly || asJSON
+		results := runExec(targets, argv, parallel, capture, !continueOnError && !capture)
+		switch {
+		case asJSON:
+			if err := writeJSON(os.Stdout, execReport(results, showFailures)); err != nil {
+				return err
+			}
+		case statusOnly:
+			if showFailures {
+				printExecFailures(os.Stderr, results)
+			}
+			printExecTable(os.Stdout, results)
+		}
+		if failed := execFailures(results); failed > 0 {
+			if !asJSON {
+				fmt.Fprintln(os.Stderr, execSummary(argv[0], results))
+			}
+			return exitWithCode(cmd, 1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	execCmd.Flags().Bool("all", false, "Run in every worktree of the repository (the default without branches)")
+	execCmd.Flags().Bool("continue-on-error", false, "Keep going after the command failed in a worktree")
+	execCmd.Flags().Int("parallel", 1, "Run in this many worktrees at once (0: one per CPU)")
+	execCmd.Flags().Bool("status-only", false, "Capture the output and print a table of exit codes and durations")
+	execCmd.Flags().Bool("show-failures", false, "With --status-only or --json: show the output of the worktrees that failed")
+	execCmd.Flags().Bool("json", false, "Capture the output and print the results as JSON")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var execTestResults = []execResult{
+	{Branch: "main", Path: "/w/app", ExitCode: 0, Duration: 1200 * time.Millisecond, Output: "ok\n"},
+	{Branch: "feature-x", Path: "/w/app/feature-x", ExitCode: 2, Duration: 3 * time.Second, Output: "FAIL: TestLogin"},
+	{Path: "/w/app/snapshot", ExitCode: -1, Error: "exec: \"mak\": executable file not found in $PATH"},
+}
+
+func TestPrintExecTable(t *testing.T) {
+	var out bytes.Buffer
+	printExecTable(&out, execTestResults)
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "WORKTREE") {
+		t.Fatalf("table = %q, want a header and one line per worktree", lines)
+	}
+	for i, want := range [][]string{{"main", "0", "1.2s"}, {"feature-x", "2", "3s"}, {"/w/app/snapshot", "error: exec"}} {
+		for _, field := range want {
+			if !strings.Contains(lines[i+1], field) {
+				t.Errorf("line %q lacks %q", lines[i+1], field)
+			}
+		}
+	}
+}
+
+func TestPrintExecFailures(t *testing.T) {
+	var out bytes.Buffer
+	printExecFailures(&out, execTestResults)
+	got := out.String()
+	if strings.Contains(got, "ok") || strings.Contains(got, "==> main") {
+		t.Errorf("failures %q include the worktree that passed", got)
+	}
+	if !strings.Contains(got, "==> feature-x (exit 2)\nFAIL: TestLogin\n") {
+		t.Errorf("failures %q lack the output of feature-x, ending in a newline", got)
+	}
+	if !strings.Contains(got, "==> /w/app/snapshot (exit error: ") {
+		t.Errorf("failures %q lack the command that did not start", got)
+	}
+}
+
+func TestExecReport(t *testing.T) {
+	if got := execFailures(execTestResults); got != 2 {
+		t.Errorf("execFailures() = %d, want 2", got)
+	}
+	for _, r := range execReport(execTestResults, false) {
+		if r.Output != "" {
+			t.Errorf("report without --show-failures has output for %s", r.label())
+		}
+	}
+	report := execReport(execTestResults, true)
+	if report[0].Output != "" || report[1].Output != "FAIL: TestLogin" {
+		t.Errorf("report with --show-failures = %+v, want only the output of failures", report)
+	}
+	if execTestResults[0].Output != "ok\n" {
+		t.Error("execReport() changed the results")
+	}
+}
+
+func TestRunExec(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	setupInteractiveRepo(t)
+	repo, err := getRepoName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := newManager(repo).Checkout("feature-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "untracked.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execTargets([]string{"missing"}, false); err == nil {
+		t.Error("execTargets() with a branch without worktree succeeded")
+	}
+	targets, err := execTargets(nil, true)
+	if err != nil || len(targets) != 2 {
+		t.Fatalf("execTargets(--all) = %v, %v; want the main clone and feature-x", targets, err)
+	}
+	results := runExec(targets, []string{"git", "status", "--porcelain"}, 2, true, false)
+	if len(results) != 2 || results[0].ExitCode != 0 || results[1].Branch != "feature-x" {
+		t.Fatalf("results = %+v, want both worktrees in order", results)
+	}
+	if results[0].Output != "" || !strings.Contains(results[1].Output, "untracked.txt") {
+		t.Errorf("outputs = %q, %q; want each worktree's own status", results[0].Output, results[1].Output)
+	}
+
+	// Fails where untracked.txt is missing, in the main clone.
+	results = runExec(targets, []string{"git", "ls-files", "--others", "--error-unmatch", "untracked.txt"}, 1, true, false)
+	if results[0].ExitCode == 0 || results[1].ExitCode != 0 || execFailures(results) != 1 {
+		t.Errorf("results = %+v, want only the main clone failing", results)
+	}
+	results = runExec(targets, []string{"git", "ls-files", "--others", "--error-unmatch", "untracked.txt"}, 1, true, true)
+	if results[0].ExitCode == 0 || !results[1].Skipped || results[1].Branch != "feature-x" {
+		t.Errorf("results when stopping on error = %+v, want feature-x skipped", results)
+	}
+	results = runExec(targets[1:], []string{"wt-no-such-command"}, 1, true, false)
+	if results[0].ExitCode != -1 || results[0].Error == "" {
+		t.Errorf("result of a missing command = %+v, want it not started", results[0])
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := &prefixWriter{w: &out, prefix: "[feature-x] ", mu: &mu}
+	for _, chunk := range []string{"ok  pkg/a\nFA", "IL pkg/b\n\n", "exit status 1"} {
+		if n, err := w.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	w.flush()
+	want := "[feature-x] ok  pkg/a\n[feature-x] FAIL pkg/b\n[feature-x] \n[feature-x] exit status 1\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestExecSummary(t *testing.T) {
+	want := "make failed in 2 of 3 worktrees: feature-x (exit 2), " + execTestResults[2].label() + " (exit " + formatExitCode(execTestResults[2]) + ")"
+	if got := execSummary("make", execTestResults); got != want {
+		t.Errorf("execSummary() = %q, want %q", got, want)
+	}
+	results := append(slices.Clone(execTestResults[:2]), execResult{Branch: "bugfix-y", Skipped: true})
+	got := execSummary("make", results)
+	if !strings.HasPrefix(got, "make failed in 1 of 3 worktrees: feature-x (exit 2)\nskipped 1 worktree(s)") || !strings.Contains(got, "--continue-on-error") {
+		t.Errorf("execSummary() with skipped = %q", got)
+	}
+}
+
+func TestExecTargetsNormalizesBranch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature/café", filepath.Join(tmpDir, "cafe"))
+	t.Chdir(repoDir)
+
+	targets, err := execTargets([]string{norm.NFD.String("feature/café")}, false)
+	if err != nil {
+		t.Fatalf("execTargets() with the NFD branch name: %v", err)
+	}
+	if len(targets) != 1 || filepath.Base(targets[0].Path) != "cafe" {
+		t.Errorf("execTargets() = %+v, want the worktree of feature/café", targets)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// deviceOf identifies the filesystem holding path: the device ID on unix, the
+// volume serial number on Windows. Tests replace it.
+var deviceOf = fileDevice
+
+// existingAncestor returns path or its nearest ancestor that exists, so that
+// a worktree root can be placed before it is created.
+func existingAncestor(path string, device func(string) (uint64, error)) (uint64, error) {
+	for {
+		id, err := device(path)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return id, err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, err
+		}
+		path = parent
+	}
+}
+
+// sameFilesystem reports whether a and b (or their nearest existing
+// ancestors) are on the same filesystem.
+func sameFilesystem(a, b string, device func(string) (uint64, error)) (bool, error) {
+	devA, err := existingAncestor(a, device)
+	if err != nil {
+		return false, err
+	}
+	devB, err := existingAncestor(b, device)
+	if err != nil {
+		return false, err
+	}
+	return devA == devB, nil
+}
+
+// crossDeviceKey records in the repository config that the user was warned
+// about worktrees under root living on another filesystem.
+func crossDeviceKey(root string) string {
+	return "wt-root." + filepath.Clean(root) + ".crossDeviceWarned"
+}
+
+// crossDeviceNote explains what a worktree root on another filesystem than
+// the repository means.
+const crossDeviceNote = `git still shares the object store by path, but hardlinks between the
+repository and its worktrees are impossible: hardlink-based sharing (e.g. of
+node_modules) silently falls back to copies.`
+
+// warnCrossDevice warns once per repository and worktree root when the new
+// worktree at path is on another filesystem than the repository.
+func warnCrossDevice(path string) {
+	commonDir, err := repoCommonDir()
+	if err != nil {
+		return
+	}
+	if same, err := sameFilesystem(commonDir, path, deviceOf); err != nil || same {
+		return
+	}
+	key := crossDeviceKey(worktreeRoot)
+	if output, _ := repoGit("config", "--bool", "--get", key).Output(); strings.TrimSpace(string(output)) == "true" {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s is on another filesystem than the repository (%s)\n", worktreeRoot, commonDir)
+	fmt.Fprintln(os.Stderr, crossDeviceNote)
+	fmt.Fprintln(os.Stderr, "This warning is shown once; 'wt doctor' keeps reporting it.")
+	_ = repoGit("config", key, "true").Run()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+// fakeDevices returns a device lookup over the given paths; other paths do
+// not exist.
+func fakeDevices(devices map[string]uint64) func(string) (uint64, error) {
+	return func(path string) (uint64, error) {
+		if id, ok := devices[filepath.ToSlash(path)]; ok {
+			return id, nil
+		}
+		return 0, fs.ErrNotExist
+	}
+}
+
+func TestSameFilesystem(t *testing.T) {
+	device := fakeDevices(map[string]uint64{
+		"/":                  1,
+		"/home/me":           1,
+		"/home/me/src/api":   1,
+		"/mnt/fast":          2,
+		"/mnt/fast/trees":    2,
+		"/net/share/trees":   3,
+		"/home/me/dev/trees": 1,
+	})
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/home/me/src/api", "/home/me/dev/trees", true},
+		{"/home/me/src/api", "/mnt/fast/trees", false},
+		{"/home/me/src/api", "/net/share/trees", false},
+		// A root that does not exist yet is judged by its nearest ancestor.
+		{"/home/me/src/api", "/mnt/fast/trees/api/feature-x", false},
+		{"/home/me/src/api", "/home/me/new/trees", true},
+	}
+	for _, tt := range tests {
+		got, err := sameFilesystem(filepath.FromSlash(tt.a), filepath.FromSlash(tt.b), device)
+		if err != nil || got != tt.want {
+			t.Errorf("sameFilesystem(%s, %s) = %v, %v; want %v", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+}
+
+func TestSameFilesystemError(t *testing.T) {
+	denied := errors.New("permission denied")
+	device := func(path string) (uint64, error) { return 0, denied }
+	if _, err := sameFilesystem("/a", "/b", device); !errors.Is(err, denied) {
+		t.Errorf("sameFilesystem() error = %v, want %v", err, denied)
+	}
+}
+
+func TestCheckFilesystem(t *testing.T) {
+	old := worktreeRoot
+	t.Cleanup(func() { worktreeRoot = old })
+	device := fakeDevices(map[string]uint64{"/src/api/.git": 1, "/trees": 1, "/mnt/trees": 2})
+
+	worktreeRoot = filepath.FromSlash("/trees")
+	if got := checkFilesystem(filepath.FromSlash("/src/api/.git"), device); got.Status != doctorOK {
+		t.Errorf("same filesystem: %+v", got)
+	}
+	worktreeRoot = filepath.FromSlash("/mnt/trees")
+	if got := checkFilesystem(filepath.FromSlash("/src/api/.git"), device); got.Status != doctorWarn {
+		t.Errorf("cross-device root should warn: %+v", got)
+	}
+}
+
+func TestFileDevice(t *testing.T) {
+	dir := t.TempDir()
+	a, err := fileDevice(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := fileDevice(filepath.Dir(dir))
+	if err != nil || a != b {
+		t.Errorf("fileDevice() of a directory and its parent = %d, %d (%v)", a, b, err)
+	}
+	if _, err := fileDevice(filepath.Join(dir, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("fileDevice(missing) error = %v, want not exist", err)
+	}
+}
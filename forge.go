@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ForgeErrorKind classifies failures of the gh/glab CLIs.
+type ForgeErrorKind int
+
+const (
+	ForgeOther ForgeErrorKind = iota
+	ForgeNotAuthenticated
+	ForgeRateLimited
+	ForgeNotFound
+)
+
+// ForgeError is a failed gh/glab invocation together with what went wrong.
+type ForgeError struct {
+	CLI    string
+	Host   string
+	Kind   ForgeErrorKind
+	Stderr string
+	Err    error
+}
+
+func (e *ForgeError) Error() string {
+	switch e.Kind {
+	case ForgeNotAuthenticated:
+		return fmt.Sprintf("%s is not authenticated for %s\nRun '%s auth login --hostname %s' and try again", e.CLI, e.Host, e.CLI, e.Host)
+	case ForgeRateLimited:
+		return fmt.Sprintf("%s hit the API rate limit of %s; wait a while and try again", e.CLI, e.Host)
+	case ForgeNotFound:
+		return fmt.Sprintf("%s could not find the requested resource on %s: %s", e.CLI, e.Host, firstLine(e.Stderr))
+	}
+	if e.Stderr != "" {
+		return fmt.Sprintf("%s failed: %s", e.CLI, firstLine(e.Stderr))
+	}
+	return fmt.Sprintf("%s failed: %v", e.CLI, e.Err)
+}
+
+func (e *ForgeError) Unwrap() error {
+	return e.Err
+}
+
+// Stderr patterns of gh and glab, checked in order: rate limit responses are
+// often 403s that would otherwise look like permission problems.
+var forgeErrorPatterns = []struct {
+	kind    ForgeErrorKind
+	pattern *regexp.Regexp
+}{
+	{ForgeRateLimited, regexp.MustCompile(`(?i)rate limit|HTTP 429|429 Too Many Requests`)},
+	{ForgeNotAuthenticated, regexp.MustCompile(`(?i)auth login|not logged in|authentication required|bad credentials|HTTP 401|401 Unauthorized|no token found`)},
+	{ForgeNotFound, regexp.MustCompile(`(?i)HTTP 404|404 Not Found|could not resolve to a|not found`)},
+}
+
+// classifyForgeStderr maps the stderr of a failed gh/glab command to a kind.
+func classifyForgeStderr(stderr string) ForgeErrorKind {
+	for _, p := range forgeErrorPatterns {
+		if p.pattern.MatchString(stderr) {
+			return p.kind
+		}
+	}
+	return ForgeOther
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// forgeCLI returns the CLI used to talk to the forge of remoteType.
+func forgeCLI(remoteType RemoteType) string {
+	if remoteType == RemoteGitLab {
+		return "glab"
+	}
+	return "gh"
+}
+
+// remoteHost extracts the host from a git remote URL in scp-like
+// (git@host:org/repo) or URL (https://host/org/repo) form.
+func remoteHost(url string) string {
+	if _, rest, ok := strings.Cut(url, "://"); ok {
+		host, _, _ := strings.Cut(rest, "/")
+		if _, h, ok := strings.Cut(host, "@"); ok {
+			host = h
+		}
+		host, _, _ = strings.Cut(host, ":")
+		return host
+	}
+	if _, rest, ok := strings.Cut(url, "@"); ok {
+		host, _, _ := strings.Cut(rest, ":")
+		return host
+	}
+	return ""
+}
+
+// forgeHost returns the host of the origin remote, defaulting to the public
+// instance of the forge.
+func forgeHost(remoteType RemoteType) string {
+	if output, err := repoGit("remote", "get-url", "origin").Output(); err == nil {
+		if host := remoteHost(strings.TrimSpace(string(output))); host != "" {
+			return host
+		}
+	}
+	if remoteType == RemoteGitLab {
+		return "gitlab.com"
+	}
+	return "github.com"
+}
+
+// runForgeCLI runs gh/glab and returns its output. Failures are returned as a
+// *ForgeError; when stderr is inconclusive, `<cli> auth status` decides
+// whether the user simply is not logged in.
+func runForgeCLI(remoteType RemoteType, args ...string) ([]byte, error) {
+	cli := forgeCLI(remoteType)
+	var stderr bytes.Buffer
+	cmd := exec.Command(cli, args...)
+	cmd.Stderr = &stderr
+	start := time.Now()
+	output, err := cmd.Output()
+	recordCommand(cli, args, "", start, err, output, stderr.Bytes())
+	if err == nil {
+		return output, nil
+	}
+
+	forgeErr := &ForgeError{
+		CLI:    cli,
+		Host:   forgeHost(remoteType),
+		Kind:   classifyForgeStderr(stderr.String()),
+		Stderr: stderr.String(),
+		Err:    err,
+	}
+	var exitErr *exec.ExitError
+	if forgeErr.Kind == ForgeOther && errors.As(err, &exitErr) {
+		if exec.Command(cli, "auth", "status", "--hostname", forgeErr.Host).Run() != nil {
+			forgeErr.Kind = ForgeNotAuthenticated
+		}
+	}
+	return nil, forgeErr
+}
+
+// describeForgeError wraps a failure to list reviews. Classified forge errors
+// already tell the user what to do; anything else gets the install hint.
+func describeForgeError(what string, remoteType RemoteType, err error) error {
+	var forgeErr *ForgeError
+	if errors.As(err, &forgeErr) && forgeErr.Kind != ForgeOther {
+		return err
+	}
+	return fmt.Errorf("failed to get %s: %w (is '%s' CLI installed?)", what, err, forgeCLI(remoteType))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClassifyForgeStderr(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   ForgeErrorKind
+	}{
+		{
+			name:   "gh never logged in",
+			stderr: "To get started with GitHub CLI, please run:  gh auth login\nAlternatively, populate the GH_TOKEN environment variable with a GitHub API authentication token.",
+			want:   ForgeNotAuthenticated,
+		},
+		{
+			name:   "gh bad token",
+			stderr: "HTTP 401: Bad credentials (https://api.github.com/graphql)\nTry authenticating with:  gh auth login",
+			want:   ForgeNotAuthenticated,
+		},
+		{
+			name:   "glab no token",
+			stderr: "ERROR: 401 Unauthorized. Run 'glab auth login' to authenticate.",
+			want:   ForgeNotAuthenticated,
+		},
+		{
+			name:   "gh rate limit",
+			stderr: "GraphQL: API rate limit exceeded for user ID 12345.",
+			want:   ForgeRateLimited,
+		},
+		{
+			name:   "gh secondary rate limit",
+			stderr: "HTTP 403: You have exceeded a secondary rate limit. Please wait a few minutes before you try again.",
+			want:   ForgeRateLimited,
+		},
+		{
+			name:   "glab too many requests",
+			stderr: "GET https://gitlab.com/api/v4/projects/1/merge_requests: 429 Too Many Requests",
+			want:   ForgeRateLimited,
+		},
+		{
+			name:   "gh repository not found",
+			stderr: "GraphQL: Could not resolve to a Repository with the name 'org/missing'. (repository)",
+			want:   ForgeNotFound,
+		},
+		{
+			name:   "glab project not found",
+			stderr: "ERROR: 404 Not Found",
+			want:   ForgeNotFound,
+		},
+		{
+			name:   "network failure",
+			stderr: "dial tcp: lookup api.github.com: no such host",
+			want:   ForgeOther,
+		},
+		{name: "empty", stderr: "", want: ForgeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyForgeStderr(tt.stderr); got != tt.want {
+				t.Errorf("classifyForgeStderr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteHost(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"git@github.com:org/repo.git", "github.com"},
+		{"https://github.com/org/repo.git", "github.com"},
+		{"https://user@gitlab.example.com/group/repo.git", "gitlab.example.com"},
+		{"ssh://git@gitlab.example.com:2222/group/repo.git", "gitlab.example.com"},
+		{"/local/path/repo", ""},
+	}
+	for _, tt := range tests {
+		if got := remoteHost(tt.url); got != tt.want {
+			t.Errorf("remoteHost(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestForgeErrorMessage(t *testing.T) {
+	err := &ForgeError{CLI: "glab", Host: "gitlab.example.com", Kind: ForgeNotAuthenticated, Err: errors.New("exit status 1")}
+	if !strings.Contains(err.Error(), "glab auth login --hostname gitlab.example.com") {
+		t.Errorf("Error() = %q, want login hint for the host", err.Error())
+	}
+
+	wrapped := describeForgeError("MRs", RemoteGitLab, err)
+	if wrapped != error(err) {
+		t.Errorf("describeForgeError() should return classified errors unchanged, got %q", wrapped)
+	}
+
+	other := describeForgeError("PRs", RemoteGitHub, &ForgeError{CLI: "gh", Kind: ForgeOther, Stderr: "boom\nmore", Err: errors.New("exit status 1")})
+	if !strings.Contains(other.Error(), "gh failed: boom") || !strings.Contains(other.Error(), "is 'gh' CLI installed?") {
+		t.Errorf("describeForgeError() = %q", other.Error())
+	}
+}
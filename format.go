@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// formatRow is what a `wt list --format` template is executed on, once per
+// worktree. Its fields are documented in formatFields.
+type formatRow struct {
+	Branch      string
+	Path        string
+	Head        string
+	Dirty       bool
+	DirtyFiles  int
+	Ahead       int
+	Behind      int
+	AheadBehind string
+	Age         string
+	PRNumber    string
+	Pinned      bool
+	Locked      bool
+	Main        bool
+}
+
+// formatField documents one field of formatRow. Load names the data the
+// field needs beyond `git worktree list`.
+type formatField struct {
+	Name string
+	Doc  string
+	Load string
+}
+
+const (
+	formatNeedsDirty    = "dirty"
+	formatNeedsTimes    = "times"
+	formatNeedsUpstream = "upstream"
+	formatNeedsReviews  = "reviews"
+)
+
+var formatFields = []formatField{
+	{"Branch", "short branch name, empty when detached or bare", ""},
+	{"Path", "absolute path of the worktree", ""},
+	{"Head", "full commit hash", ""},
+	{"Dirty", "true when there are uncommitted changes", formatNeedsDirty},
+	{"DirtyFiles", "number of changed files", formatNeedsDirty},
+	{"Ahead", "commits ahead of the upstream branch", formatNeedsUpstream},
+	{"Behind", "commits behind the upstream branch", formatNeedsUpstream},
+	{"AheadBehind", "+ahead/-behind, empty without an upstream", formatNeedsUpstream},
+	{"Age", "time since the worktree was created, e.g. 3d", formatNeedsTimes},
+	{"PRNumber", "number of the PR/MR the branch belongs to", formatNeedsReviews},
+	{"Pinned", "true when pinned with 'wt pin'", ""},
+	{"Locked", "true when locked with 'git worktree lock'", ""},
+	{"Main", "true for the main worktree", ""},
+}
+
+// formatFieldNames returns the names of all template fields.
+func formatFieldNames() []string {
+	names := make([]string, len(formatFields))
+	for i, f := range formatFields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// formatHelp documents the --format fields and functions for the help text.
+func formatHelp() string {
+	var b strings.Builder
+	b.WriteString("With --format every worktree is rendered with a Go template (text/template);\n" +
+		"\\t and \\n stand for a tab and a newline. The fields are:\n\n")
+	for _, f := range formatFields {
+		fmt.Fprintf(&b, "  .%-12s %s\n", f.Name, f.Doc)
+	}
+	b.WriteString("\nand the functions:\n\n" +
+		"  pad N        pad to N characters, e.g. {{.Branch | pad 20}}\n" +
+		"  truncate N   cut to N characters, ending in …\n" +
+		"  color NAME   red, green, yellow, bold or dim, on terminals only\n")
+	return b.String()
+}
+
+// formatFieldRegex finds the fields a template refers to.
+var formatFieldRegex = regexp.MustCompile(`\.([A-Z][A-Za-z]*)`)
+
+// formatLoads returns the data the fields used in format need.
+func formatLoads(format string) map[string]bool {
+	loads := make(map[string]bool)
+	for _, m := range formatFieldRegex.FindAllStringSubmatch(format, -1) {
+		for _, f := range formatFields {
+			if f.Name == m[1] && f.Load != "" {
+				loads[f.Load] = true
+			}
+		}
+	}
+	return loads
+}
+
+// padValue left-aligns v in width characters.
+func padValue(width int, v any) string {
+	s := fmt.Sprint(v)
+	if n := utf8.RuneCountInString(s); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// truncateValue cuts v to at most n characters, marking the cut with an
+// ellipsis.
+func truncateValue(n int, v any) string {
+	s := fmt.Sprint(v)
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+var formatColors = map[string]string{
+	"red":    ansiRed,
+	"green":  ansiGreen,
+	"yellow": ansiYellow,
+	"bold":   ansiBold,
+	"dim":    ansiDim,
+}
+
+// parseListFormat compiles a --format template. Templates referring to
+// unknown fields fail here rather than halfway through the output.
+func parseListFormat(format string, color bool) (*template.Template, error) {
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	funcs := template.FuncMap{
+		"pad":      padValue,
+		"truncate": truncateValue,
+		"color": func(name string, v any) (string, error) {
+			code, ok := formatColors[name]
+			if !ok {
+				return "", fmt.Errorf("unknown color %q", name)
+			}
+			return colorize(color, code, fmt.Sprint(v)), nil
+		},
+	}
+	tmpl, err := template.New("format").Funcs(funcs).Parse(format)
+	if err == nil {
+		err = tmpl.Execute(io.Discard, formatRow{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid --format: %w\navailable fields: %s", err, strings.Join(formatFieldNames(), ", "))
+	}
+	return tmpl, nil
+}
+
+// newFormatRow flattens info for a template. now is the reference time for
+// the age.
+func newFormatRow(info worktreeInfo, now time.Time) formatRow {
+	row := formatRow{
+		Branch:   info.Branch,
+		Path:     info.Path,
+		Head:     info.Head,
+		PRNumber: info.ReviewNumber,
+		Pinned:   info.Pinned,
+		Locked:   info.Locked,
+		Main:     info.Main,
+	}
+	if info.DirtyFiles != nil {
+		row.DirtyFiles = *info.DirtyFiles
+		row.Dirty = row.DirtyFiles > 0
+	}
+	if info.Ahead != nil && info.Behind != nil {
+		row.Ahead, row.Behind = *info.Ahead, *info.Behind
+		row.AheadBehind = fmt.Sprintf("+%d/-%d", row.Ahead, row.Behind)
+	}
+	if info.CreatedAt != nil {
+		row.Age = formatAge(now.Sub(*info.CreatedAt))
+	}
+	return row
+}
+
+// writeFormatted renders every worktree with tmpl, one line each.
+func writeFormatted(w io.Writer, tmpl *template.Template, infos []worktreeInfo, now time.Time) error {
+	for _, info := range infos {
+		if err := tmpl.Execute(w, newFormatRow(info, now)); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// loadUpstreamCounts fills in how far every worktree is ahead of and behind
+// its upstream branch, querying them concurrently.
+func loadUpstreamCounts(infos []worktreeInfo) {
+	var wg sync.WaitGroup
+	for i := range infos {
+		if infos[i].Branch == "" || infos[i].Prunable || infos[i].Offline {
+			continue
+		}
+		wg.Add(1)
+		go func(info *worktreeInfo) {
+			defer wg.Done()
+			output, err := gitIn(info.Path, "rev-list", "--left-right", "--count", "HEAD...@{upstream}").Output()
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(string(output))
+			if len(fields) != 2 {
+				return
+			}
+			ahead, err1 := strconv.Atoi(fields[0])
+			behind, err2 := strconv.Atoi(fields[1])
+			if err1 == nil && err2 == nil {
+				info.Ahead, info.Behind = &ahead, &behind
+			}
+		}(&infos[i])
+	}
+	wg.Wait()
+}
+
+// loadReviewNumbers fills in the PR/MR number of every worktree whose branch
+// was checked out with `wt pr`/`wt mr`.
+func loadReviewNumbers(infos []worktreeInfo) {
+	output, _ := repoGit("config", "--get-regexp", `^wt-review\..*\.branch$`).Output()
+	for i := range infos {
+		if infos[i].Branch == "" {
+			continue
+		}
+		review := reviewForBranch(string(output), infos[i].Branch)
+		if review == "" {
+			review = infos[i].Branch
+		}
+		if _, number, ok := parseReview(review); ok {
+			infos[i].ReviewNumber = number
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func formatTestInfos(now time.Time) []worktreeInfo {
+	created := now.Add(-3 * 24 * time.Hour)
+	week := now.Add(-15 * 24 * time.Hour)
+	zero, three := 0, 3
+	ahead, behind := 2, 1
+	return []worktreeInfo{
+		{Path: "/src/repo", Branch: "main", Head: "1111111111111111111111111111111111111111", Main: true, DirtyFiles: &zero, CreatedAt: &week, Ahead: &zero, Behind: &zero},
+		{Path: "/trees/repo/feature/login", Branch: "feature/login", Head: "2222222222222222222222222222222222222222", Locked: true, DirtyFiles: &three, CreatedAt: &created, Ahead: &ahead, Behind: &behind},
+		{Path: "/trees/repo/pr-512", Branch: "pr-512", Head: "3333333333333333333333333333333333333333", Pinned: true, ReviewNumber: "512"},
+		{Path: "/trees/repo/review", Head: "4444444444444444444444444444444444444444", Detached: true},
+	}
+}
+
+func TestWriteFormatted(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		golden string
+		format string
+	}{
+		{"list_format_columns.golden", `{{.Branch}}\t{{.Path}}\t{{.AheadBehind}}`},
+		{"list_format_status.golden", `{{.Branch | truncate 12 | pad 13}} {{if .Dirty}}{{.DirtyFiles}} dirty{{else}}clean{{end}} {{.Age}}{{if .PRNumber}} #{{.PRNumber}}{{end}}{{if .Pinned}} pinned{{end}}{{if .Locked}} locked{{end}}`},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseListFormat(tt.format, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := writeFormatted(&buf, tmpl, formatTestInfos(now), now); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, tt.golden, buf.String())
+	}
+}
+
+func TestParseListFormatErrors(t *testing.T) {
+	for _, format := range []string{"{{.Branch", "{{.Nope}}", `{{.Branch | color "pink"}}`, "{{pad}}"} {
+		_, err := parseListFormat(format, false)
+		if err == nil {
+			t.Errorf("parseListFormat(%q) should fail", format)
+			continue
+		}
+		if !strings.Contains(err.Error(), "available fields: Branch, Path, Head") {
+			t.Errorf("parseListFormat(%q) error does not list the fields: %v", format, err)
+		}
+	}
+}
+
+func TestFormatColor(t *testing.T) {
+	for color, want := range map[bool]string{false: "main", true: ansiGreen + "main" + ansiReset} {
+		tmpl, err := parseListFormat(`{{.Branch | color "green"}}`, color)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, formatRow{Branch: "main"}); err != nil || buf.String() != want {
+			t.Errorf("color with color=%v = %q, %v; want %q", color, buf.String(), err, want)
+		}
+	}
+}
+
+func TestPadAndTruncate(t *testing.T) {
+	if got := padValue(6, "café"); got != "café  " {
+		t.Errorf("padValue() = %q", got)
+	}
+	if got := padValue(2, 12345); got != "12345" {
+		t.Errorf("padValue() of a long value = %q", got)
+	}
+	if got := truncateValue(4, "feature/login"); got != "fea…" {
+		t.Errorf("truncateValue() = %q", got)
+	}
+	if got := truncateValue(4, "main"); got != "main" {
+		t.Errorf("truncateValue() of a short value = %q", got)
+	}
+}
+
+func TestFormatLoads(t *testing.T) {
+	got := formatLoads(`{{.Branch}} {{.AheadBehind}} {{if .Dirty}}*{{end}}`)
+	want := map[string]bool{formatNeedsUpstream: true, formatNeedsDirty: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("formatLoads() = %v, want %v", got, want)
+	}
+}
+
+// The documented fields must be exactly the fields of formatRow.
+func TestFormatFieldsMatchRow(t *testing.T) {
+	rowType := reflect.TypeOf(formatRow{})
+	var fields []string
+	for i := 0; i < rowType.NumField(); i++ {
+		fields = append(fields, rowType.Field(i).Name)
+	}
+	if !reflect.DeepEqual(fields, formatFieldNames()) {
+		t.Errorf("formatRow fields %v, documented %v", fields, formatFieldNames())
+	}
+	help := formatHelp()
+	for _, name := range fields {
+		if !strings.Contains(help, "."+name+" ") {
+			t.Errorf("help text does not document .%s", name)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// gitEnvVars are the variables that make git operate on another repository,
+// work tree or index than the one of the current directory. Hooks (e.g.
+// pre-commit) and IDEs export them; wt started from there would create
+// worktrees of the wrong repository.
+var gitEnvVars = []string{"GIT_DIR", "GIT_WORK_TREE", "GIT_COMMON_DIR", "GIT_INDEX_FILE"}
+
+// gitEnvOverrides are the variables of gitEnvVars set at startup, as
+// NAME=value, and respectGitEnv whether --respect-git-env kept them.
+var (
+	gitEnvOverrides []string
+	respectGitEnv   bool
+)
+
+// lookupGitEnv returns the variables of gitEnvVars set in the
+// environment, as NAME=value.
+func lookupGitEnv() []string {
+	var set []string
+	for _, name := range gitEnvVars {
+		if value, ok := os.LookupEnv(name); ok {
+			set = append(set, name+"="+value)
+		}
+	}
+	return set
+}
+
+// applyGitEnv strips the git environment overrides from the environment of
+// wt and every git it runs, with a warning naming them, unless
+// --respect-git-env is given.
+func applyGitEnv(cmd *cobra.Command) {
+	respectGitEnv, _ = cmd.Flags().GetBool("respect-git-env")
+	gitEnvOverrides = lookupGitEnv()
+	if respectGitEnv || len(gitEnvOverrides) == 0 {
+		return
+	}
+	var names []string
+	for _, override := range gitEnvOverrides {
+		name, _, _ := strings.Cut(override, "=")
+		names = append(names, name)
+		os.Unsetenv(name)
+	}
+	fmt.Fprintf(os.Stderr, "warning: ignoring %s from the environment; wt acts on the repository of the current directory (pass --respect-git-env to use them)\n", strings.Join(names, ", "))
+}
+
+// checkGitEnv reports the git environment overrides wt was started with and
+// what it did with them.
+func checkGitEnv() doctorResult {
+	switch {
+	case len(gitEnvOverrides) == 0:
+		return doctorResult{Name: "git environment", Status: doctorOK, Detail: "no " + strings.Join(gitEnvVars, "/") + " overrides"}
+	case respectGitEnv:
+		return doctorResult{Name: "git environment", Status: doctorWarn, Detail: "honoring " + strings.Join(gitEnvOverrides, " ") + " (--respect-git-env);\ngit runs against them rather than the current directory"}
+	}
+	return doctorResult{Name: "git environment", Status: doctorWarn, Detail: "ignoring " + strings.Join(gitEnvOverrides, " ") + ";\nwt strips them from git's environment unless --respect-git-env is given"}
+}
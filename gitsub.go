@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// invokedAsGitSubcommand reports whether wt was started as git-wt, which is
+// how git runs it for `git wt ...`.
+func invokedAsGitSubcommand(arg0 string) bool {
+	name := strings.TrimSuffix(filepath.Base(arg0), ".exe")
+	return name == "git-wt"
+}
+
+// gitSubcommandIntegration is appended to the bash/zsh integration when wt
+// is installed as git-wt. `git wt` runs as a child of git and can never change
+// the shell's directory, so gwt offers the same commands with auto-cd.
+const gitSubcommandIntegration = `
+# 'git wt' cannot change your shell's directory; use 'gwt' (or 'wt') for auto-cd
+gwt() {
+    wt "$@"
+}
+
+if [ -n "$BASH_VERSION" ]; then
+    complete -F _wt_complete gwt
+    # Completion for 'git wt <command>' via git's bash completion
+    _git_wt() {
+        __gitcomp "$(command git-wt __complete "" 2>/dev/null | grep -v '^:' | cut -f1)"
+    }
+fi
+
+if [ -n "$ZSH_VERSION" ]; then
+    if (( $+functions[compdef] )); then
+        compdef _wt_complete_zsh gwt
+    fi
+fi
+`
+
+// powershellGitSubcommandIntegration is the PowerShell counterpart of
+// gitSubcommandIntegration.
+const powershellGitSubcommandIntegration = `
+# 'git wt' cannot change your location; use 'gwt' (or 'wt') for auto-cd
+function gwt { wt @args }
+`
+
+// shellIntegration returns the shellenv output. binary is the executable the
+// wrapper and the completion run: wt, or git-wt when installed as a git
+// subcommand.
+func shellIntegration(powershell bool, binary string) string {
+	if powershell {
+		script := expandCompletedCommands(powershellIntegration)
+		if binary != "wt" {
+			script = strings.NewReplacer(
+				"& wt.exe @args", "& "+binary+".exe @args",
+				"& wt.exe __complete ", "& "+binary+".exe __complete ",
+			).Replace(script)
+			script = strings.ReplaceAll(script, "-CommandName wt ", "-CommandName wt, gwt ")
+			script += powershellGitSubcommandIntegration
+		}
+		return script
+	}
+	script := expandCompletedCommands(posixIntegration)
+	if binary != "wt" {
+		script = strings.NewReplacer(
+			`command wt "$@"`, "command "+binary+` "$@"`,
+			"command wt __complete ", "command "+binary+" __complete ",
+		).Replace(script)
+		script += gitSubcommandIntegration
+	}
+	return script
+}
+
+func init() {
+	if invokedAsGitSubcommand(os.Args[0]) {
+		rootCmd.Annotations = map[string]string{cobra.CommandDisplayNameAnnotation: "git wt"}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInvokedAsGitSubcommand(t *testing.T) {
+	tests := []struct {
+		arg0 string
+		want bool
+	}{
+		{"wt", false},
+		{"/usr/local/bin/wt", false},
+		{"git-wt", true},
+		{"/usr/local/bin/git-wt", true},
+		{`C:\tools\git-wt.exe`, true},
+		{"git-wtx", false},
+	}
+	for _, tt := range tests {
+		if got := invokedAsGitSubcommand(strings.ReplaceAll(tt.arg0, `\`, "/")); got != tt.want {
+			t.Errorf("invokedAsGitSubcommand(%q) = %v, want %v", tt.arg0, got, tt.want)
+		}
+	}
+}
+
+func TestShellIntegrationGitSubcommand(t *testing.T) {
+	posix := shellIntegration(false, "wt")
+	if strings.Contains(posix, "gwt") {
+		t.Error("plain wt integration should not define gwt")
+	}
+
+	git := shellIntegration(false, "git-wt")
+	for _, want := range []string{`command git-wt "$@" | tee`, "command git-wt __complete recent", `command git-wt __complete "$prev"`, `command git-wt __complete "$words[2]"`, "gwt() {", "complete -F _wt_complete gwt", "compdef _wt_complete_zsh gwt", "_git_wt()"} {
+		if !strings.Contains(git, want) {
+			t.Errorf("git-wt integration missing %q", want)
+		}
+	}
+	if strings.Contains(git, `command wt "$@"`) || strings.Contains(git, "command wt __complete") {
+		t.Error("git-wt integration should not run the wt binary")
+	}
+
+	ps := shellIntegration(true, "git-wt")
+	for _, want := range []string{"& git-wt.exe @args", "& git-wt.exe __complete ", "-CommandName wt, gwt ", "function gwt"} {
+		if !strings.Contains(ps, want) {
+			t.Errorf("git-wt PowerShell integration missing %q", want)
+		}
+	}
+	if strings.Contains(ps, "& wt.exe") {
+		t.Error("git-wt PowerShell integration should not run the wt binary")
+	}
+}
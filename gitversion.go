@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gitVersion is a git release, e.g. 2.39.2.
+type gitVersion struct {
+	Major, Minor, Patch int
+}
+
+func (v gitVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// atLeast reports whether v is min or newer.
+func (v gitVersion) atLeast(min gitVersion) bool {
+	if v.Major != min.Major {
+		return v.Major > min.Major
+	}
+	if v.Minor != min.Minor {
+		return v.Minor > min.Minor
+	}
+	return v.Patch >= min.Patch
+}
+
+// short drops a zero patch level: 2.17 rather than 2.17.0.
+func (v gitVersion) short() string {
+	if v.Patch == 0 {
+		return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	}
+	return v.String()
+}
+
+var gitVersionRegex = regexp.MustCompile(`^git version (\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseGitVersion parses the output of `git version`, ignoring what
+// distributions add after the number: "git version 2.39.3 (Apple Git-146)",
+// "git version 2.45.1.windows.1" or "git version 2.43.0.rc1".
+func parseGitVersion(output string) (gitVersion, error) {
+	m := gitVersionRegex.FindStringSubmatch(strings.TrimSpace(output))
+	if m == nil {
+		return gitVersion{}, fmt.Errorf("cannot parse git version from %q", strings.TrimSpace(output))
+	}
+	var v gitVersion
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, nil
+}
+
+// gitCapability is a git feature wt uses that older releases lack.
+type gitCapability struct {
+	Name string
+	Min  gitVersion
+	// Used says what wt needs it for, for `wt doctor`.
+	Used string
+	// Required capabilities are needed for wt to work at all.
+	Required bool
+}
+
+// The capabilities wt checks for, oldest first.
+var (
+	capWorktreeList   = gitCapability{"git worktree list --porcelain", gitVersion{2, 7, 0}, "listing worktrees", true}
+	capWorktreeLock   = gitCapability{"git worktree lock --reason", gitVersion{2, 10, 0}, "pin --lock, prune and offline worktrees", false}
+	capWorktreeMove   = gitCapability{"git worktree move", gitVersion{2, 17, 0}, "wt move", false}
+	capWorktreeRepair = gitCapability{"git worktree repair", gitVersion{2, 30, 0}, "wt repair and moving the main clone", false}
+	capPrunable       = gitCapability{"prunable in git worktree list", gitVersion{2, 31, 0}, "finding worktrees whose directory is gone", false}
+)
+
+var gitCapabilities = []gitCapability{capWorktreeList, capWorktreeLock, capWorktreeMove, capWorktreeRepair, capPrunable}
+
+// detectGitVersion runs `git version` once per invocation; the result is
+// cached, including a failure to detect it.
+var detectGitVersion = sync.OnceValues(func() (gitVersion, error) {
+	output, err := gitIn("", "version").Output()
+	if err != nil {
+		return gitVersion{}, fmt.Errorf("cannot run git: %w", err)
+	}
+	return parseGitVersion(string(output))
+})
+
+// supports reports whether git version v has capability c.
+func (v gitVersion) supports(c gitCapability) bool {
+	return v.atLeast(c.Min)
+}
+
+// missingCapabilities returns the capabilities git version v lacks.
+func missingCapabilities(v gitVersion) []gitCapability {
+	var missing []gitCapability
+	for _, c := range gitCapabilities {
+		if !v.supports(c) {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// gitSupports reports whether the installed git has capability c. When the
+// version cannot be detected, git is given the benefit of the doubt.
+func gitSupports(c gitCapability) bool {
+	v, err := detectGitVersion()
+	return err != nil || v.supports(c)
+}
+
+// requireGit fails when the installed git lacks capability c, before wt
+// fails halfway with git's "unknown option".
+func requireGit(c gitCapability) error {
+	if gitSupports(c) {
+		return nil
+	}
+	v, _ := detectGitVersion()
+	return fmt.Errorf("git %s required for %s, found %s", c.Min.short(), c.Name, v)
+}
+
+// checkRequiredGit fails early when git lacks what wt cannot work without.
+func checkRequiredGit() error {
+	for _, c := range gitCapabilities {
+		if c.Required {
+			if err := requireGit(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkGitVersion reports the version of git for `wt doctor` and what it
+// lacks.
+func checkGitVersion() doctorResult {
+	v, err := detectGitVersion()
+	if err != nil {
+		return doctorResult{Name: "git version", Status: doctorFail, Detail: err.Error()}
+	}
+	missing := missingCapabilities(v)
+	if len(missing) == 0 {
+		return doctorResult{Name: "git version", Status: doctorOK, Detail: v.String()}
+	}
+	status := doctorWarn
+	lines := []string{v.String() + " lacks:"}
+	for _, c := range missing {
+		if c.Required {
+			status = doctorFail
+		}
+		lines = append(lines, fmt.Sprintf("%s (git %s, for %s)", c.Name, c.Min.short(), c.Used))
+	}
+	return doctorResult{Name: "git version", Status: status, Detail: strings.Join(lines, "\n")}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGitVersion(t *testing.T) {
+	tests := map[string]gitVersion{
+		"git version 2.39.2\n":                {2, 39, 2},
+		"git version 2.39.3 (Apple Git-146)":  {2, 39, 3},
+		"git version 2.45.1.windows.1":        {2, 45, 1},
+		"git version 2.43.0.rc1":              {2, 43, 0},
+		"git version 2.34.1.vfs.0.0":          {2, 34, 1},
+		"git version 1.8.3.1":                 {1, 8, 3},
+		"git version 2.7":                     {2, 7, 0},
+		"git version 2.40.1.593.g8b5a1e1b1c7": {2, 40, 1},
+	}
+	for output, want := range tests {
+		if got, err := parseGitVersion(output); err != nil || got != want {
+			t.Errorf("parseGitVersion(%q) = %v, %v; want %v", output, got, err, want)
+		}
+	}
+	for _, output := range []string{"", "git: command not found", "hub version 2.14.2"} {
+		if _, err := parseGitVersion(output); err == nil {
+			t.Errorf("parseGitVersion(%q) should fail", output)
+		}
+	}
+}
+
+func TestGitVersionAtLeast(t *testing.T) {
+	min := gitVersion{2, 17, 0}
+	for v, want := range map[gitVersion]bool{
+		{2, 17, 0}: true,
+		{2, 17, 1}: true,
+		{2, 30, 0}: true,
+		{3, 0, 0}:  true,
+		{2, 16, 9}: false,
+		{1, 99, 0}: false,
+	} {
+		if got := v.atLeast(min); got != want {
+			t.Errorf("%v.atLeast(%v) = %v, want %v", v, min, got, want)
+		}
+	}
+}
+
+// stubGitVersion makes detectGitVersion return v and err for the rest of the
+// test.
+func stubGitVersion(t *testing.T, v gitVersion, err error) {
+	t.Helper()
+	orig := detectGitVersion
+	t.Cleanup(func() { detectGitVersion = orig })
+	detectGitVersion = func() (gitVersion, error) { return v, err }
+}
+
+func TestCapabilityGating(t *testing.T) {
+	stubGitVersion(t, gitVersion{2, 25, 1}, nil)
+	if !gitSupports(capWorktreeMove) || gitSupports(capWorktreeRepair) {
+		t.Error("git 2.25.1 should have worktree move and lack worktree repair")
+	}
+	err := requireGit(capWorktreeRepair)
+	if err == nil || err.Error() != "git 2.30 required for git worktree repair, found 2.25.1" {
+		t.Errorf("requireGit(repair) = %v", err)
+	}
+	if err := checkRequiredGit(); err != nil {
+		t.Errorf("checkRequiredGit() with git 2.25.1 = %v", err)
+	}
+	var names []string
+	for _, c := range missingCapabilities(gitVersion{2, 25, 1}) {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, ", "); got != "git worktree repair, prunable in git worktree list" {
+		t.Errorf("missingCapabilities(2.25.1) = %s", got)
+	}
+	if r := checkGitVersion(); r.Status != doctorWarn || !strings.Contains(r.Detail, "git worktree repair (git 2.30, for wt repair") {
+		t.Errorf("checkGitVersion() with git 2.25.1 = %+v", r)
+	}
+
+	stubGitVersion(t, gitVersion{2, 5, 0}, nil)
+	if err := checkRequiredGit(); err == nil || !strings.Contains(err.Error(), "git 2.7 required") {
+		t.Errorf("checkRequiredGit() with git 2.5.0 = %v", err)
+	}
+	if r := checkGitVersion(); r.Status != doctorFail {
+		t.Errorf("checkGitVersion() with git 2.5.0 = %+v, want a failure", r)
+	}
+
+	stubGitVersion(t, gitVersion{}, errors.New("cannot run git"))
+	if !gitSupports(capWorktreeRepair) || checkRequiredGit() != nil {
+		t.Error("an undetected version should not block anything")
+	}
+}
+
+func TestMarkMissingPrunable(t *testing.T) {
+	dir := t.TempDir()
+	worktrees := []Worktree{
+		{Path: filepath.Join(dir, "main")},
+		{Path: dir, Branch: "present"},
+		{Path: filepath.Join(dir, "gone"), Branch: "gone"},
+	}
+	if err := os.Mkdir(worktrees[0].Path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	markMissingPrunable(worktrees)
+	if worktrees[0].Prunable || worktrees[1].Prunable || !worktrees[2].Prunable {
+		t.Errorf("markMissingPrunable() = %+v, want only the missing worktree prunable", worktrees)
+	}
+}
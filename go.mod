@@ -6,6 +6,10 @@ require (
 	github.com/aymanbagabas/go-pty v0.2.2
 	github.com/manifoldco/promptui v0.9.0
 	github.com/spf13/cobra v1.8.0
+	golang.org/x/sys v0.38.0
+	golang.org/x/term v0.37.0
+	golang.org/x/text v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,5 +19,4 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/u-root/u-root v0.11.0 // indirect
 	golang.org/x/crypto v0.45.0 // indirect
-	golang.org/x/sys v0.38.0 // indirect
 )
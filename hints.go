@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// hintInterval is how long a hint stays quiet after it was shown.
+const hintInterval = 24 * time.Hour
+
+// noHints is set by --no-hints: no next-step hints are printed.
+var noHints bool
+
+// hint is a next step printed after a command succeeds, so that features are
+// found without reading the docs. {command} and {branch} in Text expand to
+// the command and the branch it worked on.
+type hint struct {
+	ID string
+	// Commands are the names of the commands the hint follows; none means
+	// any command that shows hints.
+	Commands []string
+	// When, if set, decides whether the hint applies at all.
+	When func() bool
+	Text string
+}
+
+// hints are tried in order; the first one that applies and was not shown
+// within hintInterval is printed. At most one is printed per run.
+var hints = []hint{
+	{
+		ID:   "shell-integration",
+		When: func() bool { return os.Getenv("WT_SHELL_INTEGRATION") == "" },
+		Text: "Run 'wt shellenv --install' so that wt can change to the worktree for you",
+	},
+	{
+		ID:       "create-cleanup",
+		Commands: []string{"create"},
+		Text:     "When you are done: 'wt rm {branch} --delete-branch' removes the worktree and the branch",
+	},
+	{
+		ID:       "create-publish",
+		Commands: []string{"create"},
+		Text:     "'wt publish' pushes {branch} and opens a draft PR/MR; 'wt create --publish' does both at once",
+	},
+	{
+		ID:       "review-cleanup",
+		Commands: []string{"pr", "mr"},
+		Text:     "After the review: 'wt rm {branch} --delete-branch' removes the worktree, the branch and the review metadata",
+	},
+	{
+		ID:       "remove-undo",
+		Commands: []string{"remove"},
+		Text:     "Removed the wrong one? 'wt undo' brings {branch} back with its changes",
+	},
+	{
+		ID:       "review-list",
+		Commands: []string{"pr", "mr"},
+		Text:     "'wt {command} list' shows the open reviews and which of them are checked out",
+	},
+}
+
+// hintState maps hint IDs to when they were last shown.
+type hintState map[string]time.Time
+
+func hintFile() string {
+	return filepath.Join(stateDir(), "hints.json")
+}
+
+func loadHintState() (hintState, error) {
+	state := hintState{}
+	data, err := os.ReadFile(hintFile())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", hintFile(), err)
+	}
+	return state, nil
+}
+
+func saveHintState(state hintState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir(), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(hintFile(), data, 0o644)
+}
+
+// due reports whether the hint id may be shown at now.
+func (s hintState) due(id string, now time.Time) bool {
+	last, ok := s[id]
+	return !ok || now.Sub(last) >= hintInterval
+}
+
+// selectHint returns the first of candidates that follows command, applies
+// and is due.
+func selectHint(candidates []hint, command string, state hintState, now time.Time) (hint, bool) {
+	for _, h := range candidates {
+		if len(h.Commands) > 0 && !slices.Contains(h.Commands, command) {
+			continue
+		}
+		if h.When != nil && !h.When() {
+			continue
+		}
+		if state.due(h.ID, now) {
+			return h, true
+		}
+	}
+	return hint{}, false
+}
+
+// hintsEnabled reports whether hints may be printed: not turned off with
+// --no-hints or `hints: false`, and stderr is a terminal, so scripts and
+// captured output stay as they were.
+func hintsEnabled() bool {
+	if noHints {
+		return false
+	}
+	if cfg := getConfig(); cfg.Hints != nil && !*cfg.Hints {
+		return false
+	}
+	return stderrIsTerminal()
+}
+
+var stderrIsTerminal = func() bool {
+	return isTerminal(os.Stderr)
+}
+
+// showHint prints the next hint for command, which just succeeded on branch,
+// to stderr and records it. Hints are a convenience, so failures to read or
+// write their state are silently ignored.
+func showHint(command, branch string) {
+	if !hintsEnabled() {
+		return
+	}
+	state, err := loadHintState()
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	h, ok := selectHint(hints, command, state, now)
+	if !ok {
+		return
+	}
+	state[h.ID] = now
+	if err := saveHintState(state); err != nil {
+		return
+	}
+	text := strings.NewReplacer("{command}", command, "{branch}", branch).Replace(h.Text)
+	infof("hint: %s\n", text)
+}
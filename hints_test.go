@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelectHint(t *testing.T) {
+	now := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	integrated := false
+	candidates := []hint{
+		{ID: "shell", When: func() bool { return !integrated }},
+		{ID: "create", Commands: []string{"create"}},
+		{ID: "review", Commands: []string{"pr", "mr"}},
+		{ID: "review-more", Commands: []string{"pr", "mr"}},
+	}
+
+	tests := []struct {
+		name       string
+		command    string
+		integrated bool
+		state      hintState
+		want       string
+	}{
+		{"first applicable", "create", false, hintState{}, "shell"},
+		{"condition not met", "create", true, hintState{}, "create"},
+		{"other command", "checkout", true, hintState{}, ""},
+		{"shown today", "pr", true, hintState{"review": now.Add(-time.Hour)}, "review-more"},
+		{"shown yesterday", "pr", true, hintState{"review": now.Add(-hintInterval)}, "review"},
+		{"all shown", "mr", true, hintState{"review": now, "review-more": now}, ""},
+		{"any command", "checkout", false, hintState{}, "shell"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			integrated = tt.integrated
+			got, ok := selectHint(candidates, tt.command, tt.state, now)
+			if ok != (tt.want != "") || got.ID != tt.want {
+				t.Errorf("selectHint(%q) = %q, %v, want %q", tt.command, got.ID, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestHintStateRoundTrip(t *testing.T) {
+	t.Setenv("WT_STATE_DIR", t.TempDir())
+	state, err := loadHintState()
+	if err != nil || len(state) != 0 {
+		t.Fatalf("loadHintState() without a file = %v, %v, want an empty state", state, err)
+	}
+	shown := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	state["create-cleanup"] = shown
+	if err := saveHintState(state); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadHintState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded["create-cleanup"].Equal(shown) {
+		t.Errorf("loaded state = %v, want create-cleanup at %v", loaded, shown)
+	}
+	if loaded.due("create-cleanup", shown.Add(time.Hour)) {
+		t.Error("hint is due an hour after it was shown")
+	}
+	if !loaded.due("create-cleanup", shown.Add(25*time.Hour)) || !loaded.due("review-list", shown) {
+		t.Error("hint is not due a day later, or one never shown is not due")
+	}
+}
+
+// captureHint runs showHint and returns what it printed.
+func captureHint(t *testing.T, command, branch string) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stderr
+	os.Stderr = w
+	showHint(command, branch)
+	os.Stderr = orig
+	w.Close()
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	return buf.String()
+}
+
+func TestShowHint(t *testing.T) {
+	t.Setenv("WT_STATE_DIR", t.TempDir())
+	t.Setenv("WT_SHELL_INTEGRATION", "1")
+	origTerminal, origConfig, origNoHints := stderrIsTerminal, loadedConfig, noHints
+	t.Cleanup(func() { stderrIsTerminal, loadedConfig, noHints = origTerminal, origConfig, origNoHints })
+	stderrIsTerminal = func() bool { return true }
+	loadedConfig = &Config{}
+
+	got := captureHint(t, "pr", "pr-42")
+	if want := "hint: After the review: 'wt rm pr-42 --delete-branch'"; !strings.HasPrefix(got, want) {
+		t.Errorf("first hint = %q, want it to start with %q", got, want)
+	}
+	if got := captureHint(t, "pr", "pr-42"); !strings.Contains(got, "'wt pr list'") {
+		t.Errorf("second hint = %q, want the next one for pr", got)
+	}
+	if got := captureHint(t, "pr", "pr-42"); got != "" {
+		t.Errorf("third hint = %q, want none until tomorrow", got)
+	}
+
+	t.Setenv("WT_STATE_DIR", t.TempDir())
+	off := false
+	loadedConfig = &Config{Hints: &off}
+	if got := captureHint(t, "create", "feature"); got != "" {
+		t.Errorf("hint with hints: false = %q, want none", got)
+	}
+	loadedConfig = &Config{}
+	noHints = true
+	if got := captureHint(t, "create", "feature"); got != "" {
+		t.Errorf("hint with --no-hints = %q, want none", got)
+	}
+	noHints = false
+	stderrIsTerminal = func() bool { return false }
+	if got := captureHint(t, "create", "feature"); got != "" {
+		t.Errorf("hint without a terminal = %q, want none", got)
+	}
+}
@@ -0,0 +1,345 @@
+// Package harness runs end-to-end scenarios described as data: YAML or JSON
+// files with setup steps, the commands to run in a shell with the wt
+// integration sourced, and assertions on their output and on the directory
+// the shell ends up in. wt's e2e tests discover them in e2e/scenarios.
+package harness
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+// Shells scenarios run in unless they name their own.
+var Shells = []string{"bash", "zsh"}
+
+// Scenario is one scenario file.
+type Scenario struct {
+	// File is the file the scenario was loaded from.
+	File string `yaml:"-"`
+	Name string `yaml:"name"`
+	// Shells restricts the scenario to some of Shells.
+	Shells     []string    `yaml:"shells"`
+	Setup      []SetupStep `yaml:"setup"`
+	Steps      []Step      `yaml:"steps"`
+	Assertions []Assertion `yaml:"assertions"`
+}
+
+// SetupStep prepares the fixture; exactly one field is set. Branches and
+// PR refs are created in the origin before it is cloned, remotes are added
+// to the clone.
+type SetupStep struct {
+	// CreateBranch is a branch with one commit.
+	CreateBranch string `yaml:"createBranch"`
+	// CreatePRRef is the number of a PR, exposed as refs/pull/<n>/head.
+	CreatePRRef string  `yaml:"createPRRef"`
+	AddRemote   *Remote `yaml:"addRemote"`
+}
+
+// Remote is a remote to add to the clone.
+type Remote struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// Step is a command run in the clone, e.g. wt with its arguments.
+type Step struct {
+	Cmd        string   `yaml:"cmd"`
+	Args       []string `yaml:"args"`
+	ExpectExit int      `yaml:"expectExit"`
+}
+
+// Assertion is checked after the last step; exactly one field is set.
+// Values are compared with the output normalized to placeholders, such as
+// $TMP for the fixture's temporary directory.
+type Assertion struct {
+	StdoutContains string `yaml:"stdoutContains"`
+	PwdEquals      string `yaml:"pwdEquals"`
+}
+
+// yamlLine matches the position yaml.v3 puts in front of its messages.
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// Load reads and validates the scenario in file. JSON is read as the YAML
+// it also is. Errors name the file and the line or key at fault.
+func Load(file string) (Scenario, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Scenario{}, err
+	}
+	var s Scenario
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Scenario{}, fmt.Errorf("%s: empty scenario", file)
+		}
+		return Scenario{}, fmt.Errorf("%s", describeYAMLError(file, err))
+	}
+	s.File = file
+	if err := s.Validate(); err != nil {
+		return Scenario{}, err
+	}
+	return s, nil
+}
+
+// describeYAMLError puts file in front of each message of err, with the line
+// where yaml.v3 has one.
+func describeYAMLError(file string, err error) string {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+	for i, msg := range messages {
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			messages[i] = fmt.Sprintf("%s:%s: %s", file, m[1], msg[len(m[0]):])
+		} else {
+			messages[i] = fmt.Sprintf("%s: %s", file, strings.TrimPrefix(msg, "yaml: "))
+		}
+	}
+	return strings.Join(messages, "\n")
+}
+
+// LoadDir loads the scenarios in the *.yaml, *.yml and *.json files of dir,
+// sorted by file name.
+func LoadDir(dir string) ([]Scenario, error) {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	var scenarios []Scenario
+	var problems []string
+	for _, file := range files {
+		s, err := Load(file)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		scenarios = append(scenarios, s)
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "\n"))
+	}
+	return scenarios, nil
+}
+
+// Validate checks what the schema cannot: required keys, and entries that
+// must set exactly one of their keys.
+func (s Scenario) Validate() error {
+	var problems []string
+	add := func(key, format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("%s: %s: %s", s.File, key, fmt.Sprintf(format, args...)))
+	}
+	if s.Name == "" {
+		add("name", "is required")
+	}
+	for i, shell := range s.Shells {
+		if !slices.Contains(Shells, shell) {
+			add(fmt.Sprintf("shells[%d]", i), "unknown shell %q; use one of %s", shell, strings.Join(Shells, ", "))
+		}
+	}
+	for i, step := range s.Setup {
+		key := fmt.Sprintf("setup[%d]", i)
+		if countSet(step.CreateBranch != "", step.CreatePRRef != "", step.AddRemote != nil) != 1 {
+			add(key, "set exactly one of createBranch, createPRRef and addRemote")
+			continue
+		}
+		if _, err := strconv.Atoi(step.CreatePRRef); step.CreatePRRef != "" && err != nil {
+			add(key+".createPRRef", "%q is not a PR number", step.CreatePRRef)
+		}
+		if r := step.AddRemote; r != nil {
+			if r.Name == "" {
+				add(key+".addRemote.name", "is required")
+			}
+			if r.URL == "" {
+				add(key+".addRemote.url", "is required")
+			}
+		}
+	}
+	if len(s.Steps) == 0 {
+		add("steps", "at least one step is required")
+	}
+	for i, step := range s.Steps {
+		if step.Cmd == "" {
+			add(fmt.Sprintf("steps[%d].cmd", i), "is required")
+		}
+	}
+	for i, a := range s.Assertions {
+		if countSet(a.StdoutContains != "", a.PwdEquals != "") != 1 {
+			add(fmt.Sprintf("assertions[%d]", i), "set exactly one of stdoutContains and pwdEquals")
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func countSet(set ...bool) int {
+	n := 0
+	for _, b := range set {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// RunsIn returns the shells of Shells the scenario runs in.
+func (s Scenario) RunsIn() []string {
+	if len(s.Shells) == 0 {
+		return Shells
+	}
+	return s.Shells
+}
+
+// Fixture is the repository a scenario runs in: Repo, a clone of Origin
+// named test-repo.
+type Fixture struct {
+	Origin string
+	Repo   string
+}
+
+// Prepare creates the fixture of the scenario below dir. $TMP in the URLs of
+// remotes stands for dir.
+func (s Scenario) Prepare(dir string) (Fixture, error) {
+	fx := Fixture{Origin: filepath.Join(dir, "origin", "test-repo"), Repo: filepath.Join(dir, "test-repo")}
+	if err := testrepo.Init(fx.Origin); err != nil {
+		return fx, err
+	}
+	for _, step := range s.Setup {
+		var err error
+		switch {
+		case step.CreateBranch != "":
+			err = testrepo.AddBranch(fx.Origin, step.CreateBranch, strings.ReplaceAll(step.CreateBranch, "/", "-")+".txt")
+		case step.CreatePRRef != "":
+			err = testrepo.AddReviewRef(fx.Origin, "refs/pull/"+step.CreatePRRef+"/head", "pr-"+step.CreatePRRef+".txt")
+		}
+		if err != nil {
+			return fx, err
+		}
+	}
+	if err := testrepo.Clone(fx.Origin, fx.Repo); err != nil {
+		return fx, err
+	}
+	for _, step := range s.Setup {
+		if r := step.AddRemote; r != nil {
+			if err := testrepo.Git(fx.Repo, "remote", "add", r.Name, Expand(r.URL, map[string]string{"$TMP": dir})); err != nil {
+				return fx, err
+			}
+		}
+	}
+	return fx, nil
+}
+
+// Markers the script prints on stdout around the output of the steps.
+const (
+	exitMarker = "::wt-scenario-exit "
+	pwdMarker  = "::wt-scenario-pwd "
+)
+
+// Script returns the shell script running the steps in repo, with the wt
+// integration sourced. Placeholders in the arguments are replaced by their
+// values in vars, e.g. {"$TMP": dir}.
+func (s Scenario) Script(repo string, vars map[string]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cd %s || exit 1\nsource <(wt shellenv)\n", Quote(repo))
+	for _, step := range s.Steps {
+		words := []string{Quote(Expand(step.Cmd, vars))}
+		for _, arg := range step.Args {
+			words = append(words, Quote(Expand(arg, vars)))
+		}
+		fmt.Fprintf(&b, "%s\nprintf '\\n%s%%s\\n' \"$?\"\n", strings.Join(words, " "), exitMarker)
+	}
+	fmt.Fprintf(&b, "printf '%s%%s\\n' \"$(pwd)\"\n", pwdMarker)
+	return b.String()
+}
+
+// Result is what the script of a scenario printed on stdout.
+type Result struct {
+	Stdout string
+	Exits  []int
+	Pwd    string
+}
+
+// ParseOutput splits the stdout of a scenario script into the output of
+// the steps, their exit codes and the final directory.
+func ParseOutput(stdout string) Result {
+	var r Result
+	var out []string
+	for _, line := range strings.Split(stdout, "\n") {
+		switch {
+		case strings.HasPrefix(line, exitMarker):
+			code, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, exitMarker)))
+			r.Exits = append(r.Exits, code)
+		case strings.HasPrefix(line, pwdMarker):
+			r.Pwd = strings.TrimSpace(strings.TrimPrefix(line, pwdMarker))
+		case line != "":
+			out = append(out, line)
+		}
+	}
+	r.Stdout = strings.Join(out, "\n")
+	return r
+}
+
+// Check compares r with the steps and assertions of the scenario. normalize
+// turns paths in the output into the placeholders assertions use.
+func (s Scenario) Check(r Result, normalize func(string) string) []string {
+	var failures []string
+	for i, step := range s.Steps {
+		if i >= len(r.Exits) {
+			failures = append(failures, fmt.Sprintf("steps[%d] (%s): did not run", i, step.Cmd))
+			continue
+		}
+		if r.Exits[i] != step.ExpectExit {
+			failures = append(failures, fmt.Sprintf("steps[%d] (%s %s): exit code %d, want %d", i, step.Cmd, strings.Join(step.Args, " "), r.Exits[i], step.ExpectExit))
+		}
+	}
+	stdout, pwd := normalize(r.Stdout), normalize(r.Pwd)
+	for i, a := range s.Assertions {
+		switch {
+		case a.StdoutContains != "" && !strings.Contains(stdout, a.StdoutContains):
+			failures = append(failures, fmt.Sprintf("assertions[%d]: stdout does not contain %q", i, a.StdoutContains))
+		case a.PwdEquals != "" && pwd != a.PwdEquals:
+			failures = append(failures, fmt.Sprintf("assertions[%d]: pwd is %q, want %q", i, pwd, a.PwdEquals))
+		}
+	}
+	return failures
+}
+
+// Expand replaces the placeholders of vars in s by their values, longer
+// placeholders first.
+func Expand(s string, vars map[string]string) string {
+	placeholders := make([]string, 0, len(vars))
+	for p := range vars {
+		placeholders = append(placeholders, p)
+	}
+	sort.Slice(placeholders, func(i, j int) bool { return len(placeholders[i]) > len(placeholders[j]) })
+	for _, p := range placeholders {
+		s = strings.ReplaceAll(s, p, vars[p])
+	}
+	return s
+}
+
+// Quote quotes s for bash and zsh.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
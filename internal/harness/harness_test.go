@@ -0,0 +1,180 @@
+package harness
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timvw/wt/internal/shellrun"
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+func writeScenario(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := writeScenario(t, dir, "a.yaml", `
+name: checkout
+setup:
+  - createBranch: feature
+steps:
+  - cmd: wt
+    args: [checkout, feature]
+assertions:
+  - pwdEquals: $WORKTREE_ROOT/test-repo/feature
+`)
+	jsonFile := writeScenario(t, dir, "b.json", `{
+  "name": "missing branch",
+  "shells": ["bash"],
+  "steps": [{"cmd": "wt", "args": ["checkout", "nope"], "expectExit": 1}]
+}`)
+
+	s, err := Load(yamlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Scenario{
+		File:       yamlFile,
+		Name:       "checkout",
+		Setup:      []SetupStep{{CreateBranch: "feature"}},
+		Steps:      []Step{{Cmd: "wt", Args: []string{"checkout", "feature"}}},
+		Assertions: []Assertion{{PwdEquals: "$WORKTREE_ROOT/test-repo/feature"}},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("Load(yaml) = %+v, want %+v", s, want)
+	}
+	if !reflect.DeepEqual(s.RunsIn(), Shells) {
+		t.Errorf("RunsIn() = %q, want every shell", s.RunsIn())
+	}
+
+	scenarios, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) != 2 || scenarios[1].File != jsonFile || scenarios[1].Steps[0].ExpectExit != 1 || !reflect.DeepEqual(scenarios[1].RunsIn(), []string{"bash"}) {
+		t.Errorf("LoadDir() = %+v", scenarios)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, content string
+		want          []string
+	}{
+		{"unknown.yaml", "name: x\nsteps:\n  - cmd: wt\n    expect: 1\n", []string{"unknown.yaml:4: field expect not found"}},
+		{"type.yaml", "name: x\nsteps:\n  - cmd: wt\n    expectExit: one\n", []string{"type.yaml:4: cannot unmarshal"}},
+		{"empty.yaml", "", []string{"empty.yaml: empty scenario"}},
+		{"keys.yaml", `
+shells: [fish]
+setup:
+  - createBranch: a
+    createPRRef: "1"
+  - createPRRef: abc
+  - addRemote: {name: upstream}
+steps:
+  - args: [list]
+assertions:
+  - {}
+`, []string{
+			"keys.yaml: name: is required",
+			`keys.yaml: shells[0]: unknown shell "fish"`,
+			"keys.yaml: setup[0]: set exactly one of createBranch, createPRRef and addRemote",
+			`keys.yaml: setup[1].createPRRef: "abc" is not a PR number`,
+			"keys.yaml: setup[2].addRemote.url: is required",
+			"keys.yaml: steps[0].cmd: is required",
+			"keys.yaml: assertions[0]: set exactly one of stdoutContains and pwdEquals",
+		}},
+		{"nosteps.json", `{"name": "x"}`, []string{"nosteps.json: steps: at least one step is required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeScenario(t, dir, tt.name, tt.content)
+			_, err := Load(file)
+			if err == nil {
+				t.Fatal("Load() succeeded, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), filepath.Join(dir, want)) {
+					t.Errorf("Load() = %v\nwant it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := Scenario{
+		Steps:      []Step{{Cmd: "wt", Args: []string{"create", "x"}}, {Cmd: "wt", Args: []string{"checkout", "y"}, ExpectExit: 1}, {Cmd: "true"}},
+		Assertions: []Assertion{{StdoutContains: "TREE_ME_CD:$ROOT/x"}, {PwdEquals: "$ROOT/x"}},
+	}
+	r := ParseOutput("TREE_ME_CD:/w/x\n\n" + exitMarker + "0\n\n" + exitMarker + "0\n" + pwdMarker + "/w/y\n")
+	if r.Stdout != "TREE_ME_CD:/w/x" || !reflect.DeepEqual(r.Exits, []int{0, 0}) || r.Pwd != "/w/y" {
+		t.Fatalf("ParseOutput() = %+v", r)
+	}
+	normalize := func(s string) string { return strings.ReplaceAll(s, "/w", "$ROOT") }
+	want := []string{
+		"steps[1] (wt checkout y): exit code 0, want 1",
+		"steps[2] (true): did not run",
+		`assertions[1]: pwd is "$ROOT/y", want "$ROOT/x"`,
+	}
+	if got := s.Check(r, normalize); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestScript(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	tmpDir := t.TempDir()
+	s := Scenario{
+		Setup: []SetupStep{{CreatePRRef: "7"}, {AddRemote: &Remote{Name: "upstream", URL: "$TMP/upstream"}}},
+		Steps: []Step{
+			{Cmd: "git", Args: []string{"remote", "get-url", "upstream"}},
+			{Cmd: "printf", Args: []string{"%s\n", "it's $TMP"}},
+			{Cmd: "false"},
+		},
+	}
+	fx, err := s.Prepare(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testrepo.Git(fx.Origin, "rev-parse", "--verify", "-q", "refs/pull/7/head"); err != nil {
+		t.Errorf("createPRRef did not create refs/pull/7/head: %v", err)
+	}
+
+	// The script sources wt shellenv; a stub stands in for the binary.
+	bin := filepath.Join(tmpDir, "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "wt"), []byte("#!/bin/sh\necho 'wt() { command wt \"$@\"; }'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := s.Script(fx.Repo, map[string]string{"$TMP": tmpDir})
+	output, err := shellrun.Run("bash", "PATH="+Quote(bin)+":$PATH\n"+script, 30*time.Second)
+	if err != nil {
+		t.Fatalf("script failed: %v\n%s", err, output)
+	}
+	r := ParseOutput(string(output))
+	want := Result{
+		Stdout: filepath.Join(tmpDir, "upstream") + "\nit's " + tmpDir,
+		Exits:  []int{0, 0, 1},
+		Pwd:    fx.Repo,
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("script result = %+v, want %+v", r, want)
+	}
+}
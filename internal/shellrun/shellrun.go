@@ -0,0 +1,50 @@
+// Package shellrun runs scripts in bash or zsh without the user's startup
+// files, for wt's shell diagnostics and its end-to-end tests.
+package shellrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Command returns the command running script in shell non-interactively.
+// Startup files are skipped, so that only what the script sets up is
+// defined: bash gets --norc and --noprofile and no $BASH_ENV, zsh gets -f.
+// env is added to the environment.
+func Command(ctx context.Context, shell, script string, env ...string) *exec.Cmd {
+	var args []string
+	switch filepath.Base(shell) {
+	case "bash":
+		args = []string{"--norc", "--noprofile", "-c", script}
+	case "zsh":
+		args = []string{"-f", "-c", script}
+	default:
+		args = []string{"-c", script}
+	}
+	cmd := exec.CommandContext(ctx, shell, args...)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "BASH_ENV=") && !strings.HasPrefix(kv, "ENV=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, env...)
+	return cmd
+}
+
+// Run runs script in shell like Command and returns its combined output.
+// The shell is killed when it takes longer than timeout.
+func Run(shell, script string, timeout time.Duration, env ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	output, err := Command(ctx, shell, script, env...).CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output, fmt.Errorf("%s did not finish within %s", shell, timeout)
+	}
+	return output, err
+}
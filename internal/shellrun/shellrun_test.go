@@ -0,0 +1,34 @@
+package shellrun
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	// A $BASH_ENV would otherwise run before the script.
+	bashEnv := filepath.Join(t.TempDir(), "env.sh")
+	if err := os.WriteFile(bashEnv, []byte("echo from-bash-env\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BASH_ENV", bashEnv)
+
+	output, err := Run("bash", `echo "value=$PROBE"`, 10*time.Second, "PROBE=42")
+	if err != nil {
+		t.Fatalf("Run() = %v\n%s", err, output)
+	}
+	if got := string(output); got != "value=42\n" {
+		t.Errorf("Run() output = %q, want only the script's output", got)
+	}
+
+	if _, err := Run("bash", "sleep 5", 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Errorf("Run(sleep) = %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package testrepo builds throwaway git repositories for wt's tests and its
+// demo sandbox.
+package testrepo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Git runs git with args in dir. On failure the returned error includes the
+// command's combined output.
+func Git(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w\n%s", strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// Init creates a repository at dir with a single empty commit on main.
+func Init(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create repo dir: %w", err)
+	}
+	steps := [][]string{
+		{"init"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test User"},
+		{"commit", "--allow-empty", "-m", "initial commit"},
+		{"branch", "-M", "main"},
+	}
+	for _, args := range steps {
+		if err := Git(dir, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddBranch creates branch with one commit touching file, leaving main
+// checked out.
+func AddBranch(dir, branch, file string) error {
+	if err := Git(dir, "checkout", "-q", "-b", branch, "main"); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(branch+"\n"), 0o644); err != nil {
+		return err
+	}
+	for _, args := range [][]string{
+		{"add", file},
+		{"commit", "-q", "-m", "Work on " + branch},
+		{"checkout", "-q", "main"},
+	} {
+		if err := Git(dir, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddReviewRef creates ref (e.g. refs/pull/1/head) pointing at a new commit on
+// top of main without creating a branch, the way forges expose PRs and MRs.
+func AddReviewRef(dir, ref, file string) error {
+	name := strings.ReplaceAll(strings.TrimPrefix(ref, "refs/"), "/", "-")
+	if err := AddBranch(dir, name, file); err != nil {
+		return err
+	}
+	if err := Git(dir, "update-ref", ref, "refs/heads/"+name); err != nil {
+		return err
+	}
+	return Git(dir, "branch", "-q", "-D", name)
+}
+
+// Clone clones src into dst.
+func Clone(src, dst string) error {
+	cmd := exec.Command("git", "clone", "-q", src, dst)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git clone %s: %w\n%s", src, err, output)
+	}
+	for _, args := range [][]string{
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test User"},
+	} {
+		if err := Git(dst, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddSubmodule adds the repository at sub as a submodule of the repository at
+// dir, checked out at path, and commits it.
+func AddSubmodule(dir, sub, path string) error {
+	// Local submodule URLs need the file protocol, which git disables for
+	// submodules by default.
+	if err := Git(dir, "-c", "protocol.file.allow=always", "submodule", "add", "-q", sub, path); err != nil {
+		return err
+	}
+	return Git(dir, "commit", "-q", "-m", "Add submodule "+path)
+}
+
+// Populate gives the repository at dir the size of a long-lived one: n
+// branches named branch-0001 and up, all at main, and a worktree below root
+// for each of the first worktrees of them. Performance tests use it as their
+// fixture.
+func Populate(dir string, n, worktrees int, root string) error {
+	output, err := exec.Command("git", "-C", dir, "rev-parse", "main").Output()
+	if err != nil {
+		return fmt.Errorf("git rev-parse main: %w", err)
+	}
+	commit := strings.TrimSpace(string(output))
+	var updates strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&updates, "create refs/heads/%s %s\n", PopulatedBranch(i), commit)
+	}
+	cmd := exec.Command("git", "update-ref", "--stdin")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(updates.String())
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git update-ref --stdin: %w\n%s", err, output)
+	}
+	for i := 1; i <= worktrees && i <= n; i++ {
+		if err := Git(dir, "worktree", "add", "-q", filepath.Join(root, PopulatedBranch(i)), PopulatedBranch(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PopulatedBranch returns the name of the i-th branch Populate creates.
+func PopulatedBranch(i int) string {
+	return fmt.Sprintf("branch-%04d", i)
+}
@@ -0,0 +1,108 @@
+package testrepo
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixture(t *testing.T) {
+	tmpDir := t.TempDir()
+	origin := filepath.Join(tmpDir, "origin")
+	if err := Init(origin); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddBranch(origin, "feature/login", "login.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddReviewRef(origin, "refs/pull/1/head", "pr.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	clone := filepath.Join(tmpDir, "clone")
+	if err := Clone(origin, clone); err != nil {
+		t.Fatal(err)
+	}
+	if err := Git(clone, "fetch", "-q", "origin", "pull/1/head:pr-1"); err != nil {
+		t.Fatalf("review ref should be fetchable: %v", err)
+	}
+
+	output, err := exec.Command("git", "-C", origin, "for-each-ref", "--format=%(refname)").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := string(output)
+	for _, want := range []string{"refs/heads/main", "refs/heads/feature/login", "refs/pull/1/head"} {
+		if !strings.Contains(refs, want) {
+			t.Errorf("origin refs missing %s:\n%s", want, refs)
+		}
+	}
+	if strings.Contains(refs, "refs/heads/pull-1-head") {
+		t.Error("AddReviewRef should not leave its helper branch behind")
+	}
+}
+
+func TestAddSubmodule(t *testing.T) {
+	tmpDir := t.TempDir()
+	sub := filepath.Join(tmpDir, "lib")
+	super := filepath.Join(tmpDir, "app")
+	for _, dir := range []string{sub, super} {
+		if err := Init(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AddSubmodule(super, sub, "vendor/lib"); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("git", "-C", filepath.Join(super, "vendor", "lib"), "rev-parse", "--show-superproject-working-tree").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := filepath.EvalSymlinks(strings.TrimSpace(string(output))); got != mustEvalSymlinks(t, super) {
+		t.Errorf("superproject of the submodule = %q, want %q", got, super)
+	}
+	output, err = exec.Command("git", "-C", super, "status", "--porcelain").Output()
+	if err != nil || len(output) > 0 {
+		t.Errorf("superproject should be clean after AddSubmodule: %v\n%s", err, output)
+	}
+}
+
+func mustEvalSymlinks(t *testing.T, path string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
+
+func TestPopulate(t *testing.T) {
+	tmpDir := t.TempDir()
+	repo := filepath.Join(tmpDir, "repo")
+	if err := Init(repo); err != nil {
+		t.Fatal(err)
+	}
+	if err := Populate(repo, 30, 2, filepath.Join(tmpDir, "trees")); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("git", "-C", repo, "for-each-ref", "--format=%(refname)", "refs/heads").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := strings.Fields(string(output)); len(refs) != 31 {
+		t.Errorf("Populate() left %d branches, want 30 and main", len(refs))
+	}
+	output, err = exec.Command("git", "-C", repo, "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(output), "worktree "); n != 3 {
+		t.Errorf("Populate() left %d worktrees, want 2 and the main one", n)
+	}
+	if !strings.Contains(string(output), "branch refs/heads/"+PopulatedBranch(2)) {
+		t.Errorf("no worktree for %s:\n%s", PopulatedBranch(2), output)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+// mainWorktreePath returns the path of the main worktree of the current
+// repository. git always lists it first.
+func mainWorktreePath() (string, error) {
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		return "", err
+	}
+	if len(worktrees) == 0 {
+		return "", fmt.Errorf("no worktrees found")
+	}
+	return worktrees[0].Path, nil
+}
+
+// nestedMainPath returns where the main clone lives in the nested-main layout.
+func nestedMainPath(repo, defaultBranch string) string {
+	return filepath.Join(worktreeRoot, repo, defaultBranch)
+}
+
+// isWithin reports whether path equals dir or lies below it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// outsideWorktreeRoot reports whether path is a linked worktree that does not
+// live below WORKTREE_ROOT. The main worktree is never reported: in the classic
+// layout it lives wherever the user cloned it.
+func outsideWorktreeRoot(path string) bool {
+	if isWithin(resolvePath(path), resolvePath(worktreeRoot)) {
+		return false
+	}
+	mainPath, err := mainWorktreePath()
+	return err == nil && resolvePath(mainPath) != resolvePath(path)
+}
+
+// resolvePath cleans path and resolves symlinks where possible, so that e.g.
+// /var and /private/var on macOS compare equal.
+func resolvePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// relocate maps path from inside oldDir to the same relative location inside
+// newDir. It reports false when path is not inside oldDir.
+func relocate(path, oldDir, newDir string) (string, bool) {
+	if !isWithin(path, oldDir) {
+		return "", false
+	}
+	rel, _ := filepath.Rel(oldDir, path)
+	return filepath.Join(newDir, rel), true
+}
+
+// parseSymrefHead extracts the default branch from `git ls-remote --symref <url> HEAD`.
+func parseSymrefHead(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if rest, ok := strings.CutPrefix(line, "ref: "); ok {
+			ref, _, _ := strings.Cut(rest, "\t")
+			return strings.TrimPrefix(strings.TrimSpace(ref), "refs/heads/")
+		}
+	}
+	return ""
+}
+
+func remoteDefaultBranch(url string) string {
+	output, err := gitCommand("ls-remote", "--symref", url, "HEAD").Output()
+	if err != nil {
+		return "main"
+	}
+	if branch := parseSymrefHead(string(output)); branch != "" {
+		return branch
+	}
+	return "main"
+}
+
+// adoptMainCheckout moves the main clone of the current repository to its
+// nested-main location and repairs the links of its worktrees. It returns the
+// old and new location of the main clone.
+func adoptMainCheckout(repo string) (string, string, error) {
+	mainPath, err := mainWorktreePath()
+	if err != nil {
+		return "", "", err
+	}
+	target := nestedMainPath(repo, getDefaultBase())
+	if filepath.Clean(mainPath) == filepath.Clean(target) {
+		return mainPath, target, nil
+	}
+	if _, err := os.Stat(target); err == nil {
+		return "", "", fmt.Errorf("cannot move main clone to %s: path already exists", target)
+	}
+	if err := requireGit(capWorktreeRepair); err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", "", fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+	}
+	if err := os.Rename(mainPath, target); err != nil {
+		return "", "", fmt.Errorf("failed to move main clone from %s to %s: %w\nMove it manually and run 'git worktree repair' inside it", mainPath, target, err)
+	}
+
+	if err := gitRunner().Run(target, os.Stderr, os.Stderr, "worktree", "repair"); err != nil {
+		return mainPath, target, fmt.Errorf("main clone moved to %s but 'git worktree repair' failed: %w", target, err)
+	}
+	return mainPath, target, nil
+}
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone <url> [name]",
+	Short: "Clone a repository into the worktree layout",
+	Long: `Clone a repository.
+
+With the nested-main layout (layout: nested-main in config.yaml) the clone is
+placed at $WORKTREE_ROOT/<repo>/<default-branch> so that all worktrees of the
+repository live side by side. With the classic layout the clone is created in
+the current directory, like 'git clone'.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url := args[0]
+		cwd, _ := os.Getwd()
+		repo := repoNameFromURL(url, cwd)
+		if len(args) > 1 {
+			repo = args[1]
+		}
+		if repo == "" {
+			return fmt.Errorf("cannot name the repository after %q; pass the name: wt clone <url> <name>", url)
+		}
+
+		dest := repo
+		if getConfig().nestedMain() {
+			dest = nestedMainPath(repo, remoteDefaultBranch(url))
+			if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+			}
+		}
+		dest, err := filepath.Abs(dest)
+		if err != nil {
+			return err
+		}
+
+		if err := gitRunner().Run("", os.Stderr, os.Stderr, "clone", url, dest); err != nil {
+			return fmt.Errorf("failed to clone %s: %w", url, err)
+		}
+
+		infof("✓ Cloned %s to: %s\n", repo, displayPath(dest))
+		printCDMarker(dest)
+		return nil
+	},
+}
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Prepare the current repository for the configured layout",
+	Long: `Prepare the current repository for the configured layout.
+
+With the nested-main layout, the main clone is moved to
+$WORKTREE_ROOT/<repo>/<default-branch> and the links of existing worktrees are
+repaired. With the classic layout only the repository directory under
+$WORKTREE_ROOT is created.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepoName()
+		if err != nil {
+			return err
+		}
+
+		if !getConfig().nestedMain() {
+			if err := os.MkdirAll(filepath.Join(worktreeRoot, repo), 0o755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", filepath.Join(worktreeRoot, repo), err)
+			}
+			infof("✓ Worktrees for %s will be created in: %s\n", repo, displayPath(filepath.Join(worktreeRoot, repo)))
+			return nil
+		}
+
+		cwd, _ := os.Getwd()
+		// The main clone may have moved even when repairing its links failed;
+		// the shell should follow it either way.
+		oldPath, newPath, err := adoptMainCheckout(repo)
+		if oldPath == "" {
+			return err
+		}
+		if oldPath == newPath {
+			infof("✓ Main clone already at: %s\n", displayPath(newPath))
+			return nil
+		}
+
+		infof("✓ Moved main clone to: %s\n", displayPath(newPath))
+		if dest, ok := relocate(cwd, oldPath, newPath); ok {
+			printCDMarker(dest)
+		}
+		return err
+	},
+}
+
+var moveCmd = &cobra.Command{
+	Use:   "move [branch]",
+	Short: "Move worktrees to their location in the configured layout",
+	Long: `Move worktrees to the location wt would create them at today.
+
+Use this after changing WORKTREE_ROOT or the layout. With --all every worktree
+of the repository is moved; with the nested-main layout this includes the main
+clone, which is migrated to $WORKTREE_ROOT/<repo>/<default-branch>.
+
+Examples:
+  wt move feature-x    # Move a single worktree
+  wt move --all        # Migrate every worktree of the repository`,
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if !all {
+			args = branchArgs(args)
+		}
+		if all == (len(args) == 1) {
+			return fmt.Errorf("specify either a branch or --all")
+		}
+		if err := requireGit(capWorktreeMove); err != nil {
+			return err
+		}
+
+		repo, err := getRepoName()
+		if err != nil {
+			return err
+		}
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		if len(worktrees) == 0 {
+			return fmt.Errorf("no worktrees found")
+		}
+		mainPath := worktrees[0].Path
+		if !all {
+			if _, ok := worktreeExists(args[0]); !ok {
+				return fmt.Errorf("no worktree found for branch: %s", args[0])
+			}
+		}
+		cwd, _ := os.Getwd()
+		_ = os.Chdir(mainPath)
+		cdTarget := ""
+
+		// Keep going after a failure, so the shell still follows a worktree
+		// that did move; the failures are reported at the end.
+		var failed []string
+		var errs []error
+		for _, wt := range worktrees[1:] {
+			if wt.Branch == "" || (!all && wt.Branch != args[0]) {
+				continue
+			}
+			path, err := ensureWorktreePath(repo, wt.Branch)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if filepath.Clean(path) == filepath.Clean(wt.Path) {
+				continue
+			}
+			if err := os.MkdirAll(worktree.LongPath(filepath.Dir(path)), 0o755); err != nil {
+				errs = append(errs, fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err))
+				continue
+			}
+
+			if err := gitRunner().Run(mainPath, os.Stderr, os.Stderr, "worktree", "move", wt.Path, path); err != nil {
+				failed = append(failed, wt.Branch)
+				continue
+			}
+			infof("✓ Moved %s: %s -> %s\n", wt.Branch, displayPath(wt.Path), displayPath(path))
+			if dest, ok := relocate(cwd, wt.Path, path); ok {
+				cdTarget = dest
+			}
+		}
+
+		if all && getConfig().nestedMain() {
+			// The main clone may have moved even when repairing its links
+			// failed.
+			oldPath, newPath, err := adoptMainCheckout(repo)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if oldPath != newPath {
+				infof("✓ Moved main clone: %s -> %s\n", displayPath(oldPath), displayPath(newPath))
+				if dest, ok := relocate(cwd, oldPath, newPath); ok {
+					cdTarget = dest
+				}
+			}
+		}
+
+		if cdTarget != "" {
+			printCDMarker(cdTarget)
+		}
+		if len(failed) > 0 {
+			errs = append(errs, fmt.Errorf("failed to move: %s", strings.Join(failed, ", ")))
+		}
+		return errors.Join(errs...)
+	},
+}
+
+func init() {
+	moveCmd.Flags().Bool("all", false, "Move every worktree of the repository")
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsWithin(t *testing.T) {
+	root := filepath.FromSlash("/trees/repo/main")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/trees/repo/main", true},
+		{"/trees/repo/main/src/pkg", true},
+		{"/trees/repo/main-old", false},
+		{"/trees/repo", false},
+		{"/elsewhere", false},
+	}
+	for _, tt := range tests {
+		if got := isWithin(filepath.FromSlash(tt.path), root); got != tt.want {
+			t.Errorf("isWithin(%q, %q) = %v, want %v", tt.path, root, got, tt.want)
+		}
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	oldDir := filepath.FromSlash("/src/repo")
+	newDir := filepath.FromSlash("/trees/repo/main")
+
+	got, ok := relocate(filepath.FromSlash("/src/repo/cmd/tool"), oldDir, newDir)
+	if !ok || got != filepath.FromSlash("/trees/repo/main/cmd/tool") {
+		t.Errorf("relocate() = %q, %v", got, ok)
+	}
+
+	if _, ok := relocate(filepath.FromSlash("/src/other"), oldDir, newDir); ok {
+		t.Error("relocate() should not map paths outside the old directory")
+	}
+}
+
+func TestParseSymrefHead(t *testing.T) {
+	output := "ref: refs/heads/trunk\tHEAD\n1234567890abcdef\tHEAD\n"
+	if got := parseSymrefHead(output); got != "trunk" {
+		t.Errorf("parseSymrefHead() = %q, want trunk", got)
+	}
+	if got := parseSymrefHead("1234567890abcdef\tHEAD\n"); got != "" {
+		t.Errorf("parseSymrefHead() without symref = %q, want empty", got)
+	}
+}
+
+func TestNestedMainPath(t *testing.T) {
+	originalRoot := worktreeRoot
+	t.Cleanup(func() {
+		worktreeRoot = originalRoot
+	})
+	worktreeRoot = filepath.FromSlash("/trees")
+
+	if got := nestedMainPath("api", "main"); got != filepath.FromSlash("/trees/api/main") {
+		t.Errorf("nestedMainPath() = %q", got)
+	}
+}
+
+func TestOutsideWorktreeRoot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	t.Chdir(repoDir)
+
+	oldRoot := filepath.Join(tmpDir, "old-root")
+	newRoot := filepath.Join(tmpDir, "new-root")
+	oldPath := filepath.Join(oldRoot, "repo", "feature")
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature", oldPath)
+
+	originalRoot := worktreeRoot
+	t.Cleanup(func() {
+		worktreeRoot = originalRoot
+	})
+
+	worktreeRoot = oldRoot
+	if outsideWorktreeRoot(oldPath) {
+		t.Error("outsideWorktreeRoot() = true for a worktree below the root")
+	}
+
+	worktreeRoot = newRoot
+	if !outsideWorktreeRoot(oldPath) {
+		t.Error("outsideWorktreeRoot() = false after WORKTREE_ROOT changed")
+	}
+	if outsideWorktreeRoot(repoDir) {
+		t.Error("outsideWorktreeRoot() should never report the main worktree")
+	}
+}
+
+// useLayout points WORKTREE_ROOT at root and makes the config use layout.
+func useLayout(t *testing.T, root, layout string) {
+	t.Helper()
+	originalRoot, originalConfig := worktreeRoot, loadedConfig
+	t.Cleanup(func() { worktreeRoot, loadedConfig = originalRoot, originalConfig })
+	worktreeRoot = root
+	loadedConfig = &Config{Layout: layout}
+}
+
+func TestMoveCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	featurePath := filepath.Join(tmpDir, "old", "feature-x")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feature-x", featurePath)
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "team/fix", filepath.Join(tmpDir, "old", "fix"))
+	trees := filepath.Join(tmpDir, "trees")
+	useLayout(t, trees, layoutClassic)
+	// A file where the directory of team/fix belongs makes its move fail.
+	if err := os.MkdirAll(filepath.Join(trees, "repo"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(trees, "repo", "team"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(featurePath)
+	if err := moveCmd.Flags().Set("all", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = moveCmd.Flags().Set("all", "false") })
+
+	output, err := runCapturing(t, moveCmd)
+	if err == nil || !strings.Contains(err.Error(), "team") {
+		t.Errorf("wt move --all = %v, want the failure of team/fix", err)
+	}
+	moved := filepath.Join(trees, "repo", "feature-x")
+	if path, _ := worktreeExists("feature-x"); resolvePath(path) != resolvePath(moved) {
+		t.Errorf("feature-x is at %s, want %s", path, moved)
+	}
+	if !strings.Contains(output, "TREE_ME_CD:"+moved) {
+		t.Errorf("wt move --all printed %q, want the shell sent to %s despite the failure", output, moved)
+	}
+}
+
+func TestInitCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	trees := filepath.Join(tmpDir, "trees")
+	useLayout(t, trees, layoutClassic)
+	t.Chdir(repoDir)
+
+	output, err := runCapturing(t, initCmd)
+	if err != nil {
+		t.Fatalf("wt init: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(trees, "repo")); err != nil || !info.IsDir() {
+		t.Errorf("wt init did not create the repository directory: %v", err)
+	}
+	if strings.Contains(output, "TREE_ME_CD:") {
+		t.Errorf("wt init with the classic layout printed %q, want no cd", output)
+	}
+
+	loadedConfig = &Config{Layout: layoutNestedMain}
+	if err := os.Mkdir(filepath.Join(repoDir, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(repoDir, "docs"))
+	branch := strings.TrimSpace(gitOutput(t, repoDir, "branch", "--show-current"))
+	output, err = runCapturing(t, initCmd)
+	if err != nil {
+		t.Fatalf("wt init with the nested-main layout: %v", err)
+	}
+	want := filepath.Join(trees, "repo", branch, "docs")
+	if !strings.Contains(output, "TREE_ME_CD:"+want) {
+		t.Errorf("wt init printed %q, want the shell sent to %s", output, want)
+	}
+	if _, err := os.Stat(repoDir); !os.IsNotExist(err) {
+		t.Errorf("the main clone is still at %s", repoDir)
+	}
+}
+
+func TestCloneCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	origin := filepath.Join(tmpDir, "origin")
+	setupTestRepo(t, origin)
+	branch := strings.TrimSpace(gitOutput(t, origin, "branch", "--show-current"))
+	trees := filepath.Join(tmpDir, "trees")
+	useLayout(t, trees, layoutClassic)
+	work := filepath.Join(tmpDir, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(work)
+
+	for _, tt := range []struct {
+		layout string
+		args   []string
+		want   string
+	}{
+		{layoutClassic, []string{origin, "api"}, filepath.Join(work, "api")},
+		{layoutNestedMain, []string{origin, "web"}, filepath.Join(trees, "web", branch)},
+	} {
+		loadedConfig = &Config{Layout: tt.layout}
+		output, err := runCapturing(t, cloneCmd, tt.args...)
+		if err != nil {
+			t.Fatalf("wt clone with the %s layout: %v", tt.layout, err)
+		}
+		if _, err := os.Stat(filepath.Join(tt.want, ".git")); err != nil {
+			t.Errorf("wt clone with the %s layout did not clone to %s: %v", tt.layout, tt.want, err)
+		}
+		if !strings.Contains(output, "TREE_ME_CD:"+tt.want) {
+			t.Errorf("wt clone with the %s layout printed %q, want the shell sent to %s", tt.layout, output, tt.want)
+		}
+	}
+	if _, err := runCapturing(t, cloneCmd, filepath.Join(tmpDir, "missing")); err == nil {
+		t.Error("wt clone of a missing repository should fail")
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// worktreeInfo is a worktree enriched with optional status information, as
+// rendered by `wt list`.
+type worktreeInfo struct {
+	Path       string `json:"path"`
+	Branch     string `json:"branch,omitempty"`
+	Head       string `json:"head"`
+	Main       bool   `json:"main"`
+	Bare       bool   `json:"bare,omitempty"`
+	Detached   bool   `json:"detached,omitempty"`
+	Locked     bool   `json:"locked,omitempty"`
+	Prunable   bool   `json:"prunable,omitempty"`
+	Pinned     bool   `json:"pinned,omitempty"`
+	Offline    bool   `json:"offline,omitempty"` // on a volume that is not mounted
+	CopyOf     string `json:"copyOf,omitempty"`  // original branch of a `wt checkout --copy-as` copy
+	Dirty      *bool  `json:"dirty,omitempty"`
+	DirtyFiles *int   `json:"dirtyFiles,omitempty"`
+	// StatusError is why the dirty state is unknown, e.g. a corrupt index.
+	StatusError string `json:"statusError,omitempty"`
+	// Timestamps recorded in the worktree metadata, RFC3339 in JSON.
+	CreatedAt      *time.Time `json:"createdAt,omitempty"`
+	LastSwitchedAt *time.Time `json:"lastSwitchedAt,omitempty"`
+	// Activity, loaded with --since: the last commit, the newest change to a
+	// dirty file, and the newest of those and the last switch.
+	LastCommitAt    *time.Time `json:"lastCommitAt,omitempty"`
+	DirtyModifiedAt *time.Time `json:"dirtyModifiedAt,omitempty"`
+	LastActivityAt  *time.Time `json:"lastActivityAt,omitempty"`
+	Activity        string     `json:"activity,omitempty"`
+	// Loaded for --format templates that use them.
+	Ahead        *int   `json:"ahead,omitempty"`
+	Behind       *int   `json:"behind,omitempty"`
+	ReviewNumber string `json:"reviewNumber,omitempty"`
+
+	// The dirty files split up, for wt status.
+	modifiedFiles, untrackedFiles *int
+}
+
+func newWorktreeInfos(worktrees []Worktree) []worktreeInfo {
+	infos := make([]worktreeInfo, len(worktrees))
+	for i, wt := range worktrees {
+		infos[i] = worktreeInfo{
+			Path:     wt.Path,
+			Branch:   wt.Branch,
+			Head:     wt.Head,
+			Main:     i == 0,
+			Bare:     wt.Bare,
+			Detached: wt.Detached,
+			Locked:   wt.Locked,
+			Prunable: wt.Prunable,
+		}
+	}
+	return infos
+}
+
+// countDirtyFiles returns the number of entries in `git status --porcelain`
+// for the worktree at path.
+func countDirtyFiles(path string) (int, error) {
+	modified, untracked, err := countChanges(path)
+	return modified + untracked, err
+}
+
+// countChanges returns how many files of the worktree at path have changes
+// and how many are untracked, according to `git status --porcelain`.
+func countChanges(path string) (modified, untracked int, err error) {
+	output, err := gitIn(path, "status", "--porcelain").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return 0, 0, fmt.Errorf("git status failed: %s", firstLine(string(exitErr.Stderr)))
+		}
+		return 0, 0, fmt.Errorf("git status failed: %w", err)
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case strings.TrimSpace(line) == "":
+		case strings.HasPrefix(line, "??"):
+			untracked++
+		default:
+			modified++
+		}
+	}
+	return modified, untracked, nil
+}
+
+// loadDirtyState fills in the dirty fields of every worktree, querying them
+// concurrently. Bare and prunable worktrees have no working tree to inspect;
+// a worktree git cannot report on gets a StatusError instead.
+func loadDirtyState(infos []worktreeInfo) {
+	var wg sync.WaitGroup
+	for i := range infos {
+		if infos[i].Bare || infos[i].Prunable || infos[i].Offline {
+			continue
+		}
+		wg.Add(1)
+		go func(info *worktreeInfo) {
+			defer wg.Done()
+			modified, untracked, err := countChanges(info.Path)
+			if err != nil {
+				info.StatusError = err.Error()
+				return
+			}
+			count := modified + untracked
+			dirty := count > 0
+			info.Dirty = &dirty
+			info.DirtyFiles = &count
+			info.modifiedFiles, info.untrackedFiles = &modified, &untracked
+		}(&infos[i])
+	}
+	wg.Wait()
+}
+
+// loadTimes fills in the recorded timestamps of every worktree. Worktrees
+// without a creation time get one from their directory, in memory only: list
+// and status are read-only, touchWorktree records it.
+func loadTimes(infos []worktreeInfo) {
+	times := loadWorktreeTimes("")
+	for i := range infos {
+		if infos[i].Bare || infos[i].Prunable || infos[i].Offline {
+			continue
+		}
+		t := times[filepath.Clean(infos[i].Path)]
+		backfillCreatedAt(&t, infos[i].Path)
+		if !t.CreatedAt.IsZero() {
+			infos[i].CreatedAt = &t.CreatedAt
+		}
+		if !t.LastSwitchedAt.IsZero() {
+			infos[i].LastSwitchedAt = &t.LastSwitchedAt
+		}
+	}
+}
+
+// filterDirty keeps only worktrees with uncommitted changes, and those whose
+// state is unknown: they may have some.
+func filterDirty(infos []worktreeInfo) []worktreeInfo {
+	dirty := []worktreeInfo{}
+	for _, info := range infos {
+		if info.Dirty != nil && *info.Dirty || info.StatusError != "" {
+			dirty = append(dirty, info)
+		}
+	}
+	return dirty
+}
+
+// printWorktreeTable renders worktrees similar to `git worktree list`. When
+// timestamps are loaded, an age column shows how long ago each was created,
+// and with --since an activity column when and how each was last touched.
+func printWorktreeTable(w io.Writer, infos []worktreeInfo) {
+	showAge, showActivity := false, false
+	for _, info := range infos {
+		showAge = showAge || info.CreatedAt != nil
+		showActivity = showActivity || info.LastActivityAt != nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for i, info := range infos {
+		head := info.Head
+		if len(head) > 7 {
+			head = head[:7]
+		}
+		label := "[" + info.Branch + "]"
+		switch {
+		case info.Bare:
+			label = "(bare)"
+		case info.Branch == "":
+			label = "(detached HEAD)"
+		}
+		var notes []string
+		if info.Locked {
+			notes = append(notes, "locked")
+		}
+		if info.Offline {
+			notes = append(notes, "offline")
+		} else if info.Prunable {
+			notes = append(notes, "prunable")
+		}
+		if info.Pinned {
+			notes = append(notes, "📌 pinned")
+		}
+		path := info.Path
+		if info.CopyOf != "" {
+			notes = append(notes, "copy of "+info.CopyOf)
+			if i > 0 && (infos[i-1].Branch == info.CopyOf || infos[i-1].CopyOf == info.CopyOf) {
+				path = "└ " + path
+			}
+		}
+		if info.DirtyFiles != nil && *info.DirtyFiles > 0 {
+			notes = append(notes, fmt.Sprintf("%d dirty", *info.DirtyFiles))
+		}
+		if info.StatusError != "" {
+			notes = append(notes, "status unknown")
+		}
+		line := fmt.Sprintf("%s\t%s\t%s", path, head, label)
+		if showAge {
+			age := "-"
+			if info.CreatedAt != nil {
+				age = formatAge(time.Since(*info.CreatedAt))
+			}
+			line += "\t" + age
+		}
+		if showActivity {
+			activity := "-"
+			if info.LastActivityAt != nil {
+				activity = fmt.Sprintf("%s %s ago", info.Activity, formatAge(time.Since(*info.LastActivityAt)))
+			}
+			line += "\t" + activity
+		}
+		if len(notes) > 0 {
+			line += "\t" + strings.Join(notes, ", ")
+		}
+		fmt.Fprintln(tw, line)
+	}
+	_ = tw.Flush()
+}
+
+// porcelainColumn is one tab-separated column of `wt list --porcelain`. The
+// same definitions render the output and document it in the help text.
+type porcelainColumn struct {
+	Name  string
+	Doc   string
+	Value func(worktreeInfo) string
+}
+
+var porcelainColumns = []porcelainColumn{
+	{"path", "absolute path of the worktree", func(i worktreeInfo) string { return i.Path }},
+	{"branch", "short branch name, empty when detached or bare", func(i worktreeInfo) string { return i.Branch }},
+	{"head", "full commit hash", func(i worktreeInfo) string { return i.Head }},
+	{"flags", "comma-separated subset of main,locked,prunable,detached,dirty,pinned,offline\n(dirty only with --status or --dirty)", porcelainFlags},
+}
+
+func porcelainFlags(info worktreeInfo) string {
+	var flags []string
+	if info.Main {
+		flags = append(flags, "main")
+	}
+	if info.Locked {
+		flags = append(flags, "locked")
+	}
+	if info.Prunable {
+		flags = append(flags, "prunable")
+	}
+	if info.Detached {
+		flags = append(flags, "detached")
+	}
+	if info.Dirty != nil && *info.Dirty {
+		flags = append(flags, "dirty")
+	}
+	if info.Pinned {
+		flags = append(flags, "pinned")
+	}
+	if info.Offline {
+		flags = append(flags, "offline")
+	}
+	return strings.Join(flags, ",")
+}
+
+// writePorcelain prints one tab-separated line per worktree, without headers.
+// The format is stable: columns are only ever appended.
+func writePorcelain(w io.Writer, infos []worktreeInfo) {
+	for _, info := range infos {
+		values := make([]string, len(porcelainColumns))
+		for i, col := range porcelainColumns {
+			values[i] = col.Value(info)
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+}
+
+// porcelainHelp documents the --porcelain columns for the help text.
+func porcelainHelp() string {
+	names := make([]string, len(porcelainColumns))
+	var b strings.Builder
+	for i, col := range porcelainColumns {
+		names[i] = col.Name
+		doc := strings.ReplaceAll(col.Doc, "\n", "\n            ")
+		fmt.Fprintf(&b, "  %-9s %s\n", col.Name, doc)
+	}
+	return "With --porcelain one tab-separated line is printed per worktree, with no\n" +
+		"headers or colors. The columns are stable; new ones are only ever appended:\n\n" +
+		"  " + strings.Join(names, "<TAB>") + "\n\n" + b.String()
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all worktrees",
+	Long: `List all worktrees of the current repository.
+
+With --status the dirty file count and the age of every worktree are shown.
+With --tree worktrees are grouped by the prefix segments of their branch names
+(feature/, bugfix/, ...) with the main worktree first; ✓ marks a clean
+worktree and ● the number of changed files.
+With --since only worktrees active within the window are listed: a commit, a
+switch to the worktree, or a change to an uncommitted file. The newest of
+those is shown. The window is a duration such as 90m, 24h, 1d or 2w.
+With --dirty only worktrees with uncommitted changes are listed and the exit
+code reports whether any were found: 0 when all worktrees are clean, 1 when at
+least one is dirty. A worktree git status fails on counts as dirty, as it may
+be. Combine with --quiet to only get the exit code.
+
+` + porcelainHelp() + `
+` + formatHelp() + `
+Examples:
+  wt list                     # List all worktrees
+  wt list --status            # Dirty files and age per worktree
+  wt list --tree              # Worktrees grouped by branch prefix
+  wt list --json              # Machine-readable output
+  wt list --porcelain         # Stable tab-separated output for scripts
+  wt list --dirty             # Worktrees with uncommitted changes
+  wt list --dirty --quiet     # Exit code only, e.g. in a shutdown script
+  wt list --status --since 1d # What you touched since yesterday
+  wt list --since 1w --json   # The same for a script posting a summary
+  wt list --format '{{.Branch | pad 30}} {{.AheadBehind}} {{.Age}}'`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		dirtyOnly, _ := cmd.Flags().GetBool("dirty")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		status, _ := cmd.Flags().GetBool("status")
+		porcelain, _ := cmd.Flags().GetBool("porcelain")
+		tree, _ := cmd.Flags().GetBool("tree")
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		format, _ := cmd.Flags().GetString("format")
+		var tmpl *template.Template
+		var loads map[string]bool
+		if format != "" {
+			var err error
+			if tmpl, err = parseListFormat(format, colorEnabled(os.Stdout)); err != nil {
+				return err
+			}
+			loads = formatLoads(format)
+		}
+		var since time.Duration
+		if sinceFlag != "" {
+			var err error
+			if since, err = parseSince(sinceFlag); err != nil {
+				return err
+			}
+			status = status || !(asJSON || porcelain || tree || quiet)
+		}
+
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		pinned := loadPinnedBranches()
+		copies := loadBranchCopies()
+		offline := findOfflineWorktrees(worktrees)
+		// Plain `git worktree list` output, unless pins, copies or offline
+		// volumes need to be shown, paths need to be mapped, or git would list
+		// a submodule's git dir as its main worktree.
+		if !asJSON && !dirtyOnly && !quiet && !status && !porcelain && !tree && format == "" && len(pinned) == 0 && len(copies) == 0 && len(offline) == 0 && !mapPaths && !inSubmodule() {
+			gitCmd := gitCommand("worktree", "list")
+			gitCmd.Stdout = os.Stdout
+			gitCmd.Stderr = os.Stderr
+			_ = gitCmd.Run()
+			return nil
+		}
+
+		infos := newWorktreeInfos(worktrees)
+		markPinned(infos, pinned)
+		markCopies(infos, copies)
+		markOffline(infos, offline)
+		infos = groupCopies(infos)
+		if dirtyOnly || status || tree || sinceFlag != "" || loads[formatNeedsDirty] {
+			loadDirtyState(infos)
+		}
+		if asJSON || status || sinceFlag != "" || loads[formatNeedsTimes] {
+			loadTimes(infos)
+		}
+		if loads[formatNeedsUpstream] {
+			loadUpstreamCounts(infos)
+		}
+		if loads[formatNeedsReviews] {
+			loadReviewNumbers(infos)
+		}
+		if sinceFlag != "" {
+			loadActivity(infos)
+			infos = filterActiveSince(infos, time.Now().Add(-since))
+		}
+		if dirtyOnly {
+			infos = filterDirty(infos)
+		}
+		for i := range infos {
+			infos[i].Path = displayPath(infos[i].Path)
+		}
+
+		switch {
+		case quiet:
+		case asJSON:
+			if err := writeJSON(os.Stdout, infos); err != nil {
+				return err
+			}
+		case porcelain:
+			writePorcelain(os.Stdout, infos)
+		case tmpl != nil:
+			if err := writeFormatted(os.Stdout, tmpl, infos, time.Now()); err != nil {
+				return err
+			}
+		case tree:
+			repo, err := getRepoName()
+			if err != nil {
+				return err
+			}
+			printWorktreeTree(os.Stdout, buildWorktreeTree(repo, infos), colorEnabled(os.Stdout))
+		default:
+			printWorktreeTable(os.Stdout, infos)
+		}
+
+		if dirtyOnly && len(infos) > 0 {
+			return exitWithCode(cmd, 1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	listCmd.Flags().Bool("json", false, "Output as JSON")
+	listCmd.Flags().Bool("porcelain", false, "Stable tab-separated output for scripts")
+	listCmd.Flags().Bool("tree", false, "Group worktrees by branch prefix in a tree")
+	listCmd.Flags().String("format", "", "Render each worktree with a Go template, e.g. '{{.Branch}}\\t{{.Path}}'")
+	listCmd.MarkFlagsMutuallyExclusive("json", "porcelain", "tree", "format")
+	listCmd.Flags().Bool("status", false, "Show dirty file counts and worktree age")
+	listCmd.Flags().String("since", "", "Only worktrees active within this window, e.g. 24h, 1d, 2w")
+	listCmd.Flags().Bool("dirty", false, "Only list worktrees with uncommitted changes (exit code 1 if any)")
+	listCmd.Flags().BoolP("quiet", "q", false, "Print nothing; only set the exit code")
+}
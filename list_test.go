@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterDirty(t *testing.T) {
+	clean, dirty := false, true
+	zero, three := 0, 3
+	infos := []worktreeInfo{
+		{Path: "/a", Dirty: &clean, DirtyFiles: &zero},
+		{Path: "/b", Dirty: &dirty, DirtyFiles: &three},
+		{Path: "/c"}, // status unknown
+	}
+	got := filterDirty(infos)
+	if len(got) != 1 || got[0].Path != "/b" {
+		t.Errorf("filterDirty() = %+v, want only /b", got)
+	}
+}
+
+func TestPrintWorktreeTable(t *testing.T) {
+	three := 3
+	var buf bytes.Buffer
+	printWorktreeTable(&buf, []worktreeInfo{
+		{Path: "/src/repo", Branch: "main", Head: "1234567890"},
+		{Path: "/trees/repo/feature", Branch: "feature", Head: "abcdef0123", Locked: true, DirtyFiles: &three},
+		{Path: "/trees/repo/review", Head: "0000000000", Detached: true},
+	})
+	out := buf.String()
+	for _, want := range []string{"[main]", "1234567", "[feature]", "locked, 3 dirty", "(detached HEAD)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestLoadDirtyState(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	cleanDir := filepath.Join(tmpDir, "clean")
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "clean", cleanDir)
+	if err := os.WriteFile(filepath.Join(repoDir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "b.txt"), []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	worktrees, err := listWorktrees(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := newWorktreeInfos(worktrees)
+	loadDirtyState(infos)
+
+	if infos[0].DirtyFiles == nil || *infos[0].DirtyFiles != 2 {
+		t.Errorf("main worktree dirty files = %v, want 2", infos[0].DirtyFiles)
+	}
+	if infos[1].Dirty == nil || *infos[1].Dirty {
+		t.Errorf("clean worktree dirty = %v, want false", infos[1].Dirty)
+	}
+	if !infos[0].Main || infos[1].Main {
+		t.Error("only the first worktree should be marked as main")
+	}
+}
+
+func TestLoadTimesIsReadOnly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping git fixture test in short mode")
+	}
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	t.Chdir(repoDir)
+	configBefore := gitOutput(t, repoDir, "config", "--list", "--local")
+
+	worktrees, err := listWorktrees(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := newWorktreeInfos(worktrees)
+	loadTimes(infos)
+	if infos[0].CreatedAt == nil {
+		t.Error("loadTimes() should fill in the creation time from the directory")
+	}
+	if after := gitOutput(t, repoDir, "config", "--list", "--local"); after != configBefore {
+		t.Errorf("loadTimes() changed the config from\n%s\nto\n%s", configBefore, after)
+	}
+}
+
+func TestPrintWorktreeTableAge(t *testing.T) {
+	created := time.Now().Add(-3 * 24 * time.Hour)
+	var buf bytes.Buffer
+	printWorktreeTable(&buf, []worktreeInfo{
+		{Path: "/src/repo", Branch: "main", Head: "1234567890", CreatedAt: &created},
+		{Path: "/trees/repo/bare", Bare: true},
+	})
+	out := buf.String()
+	if !strings.Contains(out, "3d") {
+		t.Errorf("table missing age column:\n%s", out)
+	}
+	if !strings.Contains(out, "(bare) -") {
+		t.Errorf("table should show - for unknown age:\n%s", out)
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// checkGolden compares got with testdata/<name>, rewriting the file with
+// -update.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output does not match %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestWritePorcelain(t *testing.T) {
+	dirty, clean := true, false
+	infos := []worktreeInfo{
+		{Path: "/src/repo", Branch: "main", Head: "1111111111111111111111111111111111111111", Main: true, Dirty: &clean},
+		{Path: "/trees/repo/feature/login", Branch: "feature/login", Head: "2222222222222222222222222222222222222222", Locked: true, Dirty: &dirty},
+		{Path: "/trees/repo/with space", Branch: "café", Head: "3333333333333333333333333333333333333333"},
+		{Path: "/trees/repo/review", Head: "4444444444444444444444444444444444444444", Detached: true, Prunable: true},
+	}
+	var buf bytes.Buffer
+	writePorcelain(&buf, infos)
+	checkGolden(t, "list_porcelain.golden", buf.String())
+}
+
+func TestPorcelainHelpMatchesColumns(t *testing.T) {
+	help := porcelainHelp()
+	for _, col := range porcelainColumns {
+		if !strings.Contains(help, "  "+col.Name+" ") {
+			t.Errorf("porcelain help does not document column %q", col.Name)
+		}
+	}
+	if !strings.Contains(listCmd.Long, help) {
+		t.Error("list help does not include the porcelain format")
+	}
+}
+
+func TestDirtyWithFailingStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	brokenDir := filepath.Join(tmpDir, "broken")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "broken", brokenDir)
+	index := strings.TrimSpace(gitOutput(t, brokenDir, "rev-parse", "--path-format=absolute", "--git-path", "index"))
+	if err := os.WriteFile(index, []byte("not an index"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repoDir)
+
+	worktrees, err := listWorktrees(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := newWorktreeInfos(worktrees)
+	loadDirtyState(infos)
+	if infos[1].Dirty != nil || !strings.HasPrefix(infos[1].StatusError, "git status failed") {
+		t.Errorf("broken worktree dirty = %v, statusError = %q, want the failure", infos[1].Dirty, infos[1].StatusError)
+	}
+	if got := filterDirty(infos); len(got) != 1 || got[0].Path != infos[1].Path {
+		t.Errorf("filterDirty() = %+v, want the broken worktree", got)
+	}
+
+	for _, name := range []string{"dirty", "quiet"} {
+		if err := listCmd.Flags().Set(name, "true"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = listCmd.Flags().Set(name, "false") })
+	}
+	var exitErr *exitCodeError
+	if err := listCmd.RunE(listCmd, nil); !errors.As(err, &exitErr) || exitErr.code != 1 {
+		t.Errorf("wt list --dirty with a failing git status = %v, want exit code 1", err)
+	}
+	output, err := runCapturing(t, statusCmd)
+	if !errors.As(err, &exitErr) || exitErr.code != 1 {
+		t.Errorf("wt status with a failing git status = %v, want exit code 1", err)
+	}
+	if !strings.Contains(output, "! status: git status failed") {
+		t.Errorf("wt status output = %q, want the failure", output)
+	}
+}
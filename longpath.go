@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+)
+
+// Windows limits paths to MAX_PATH (260) UTF-16 code units unless long paths
+// are enabled, and a directory must leave room for an 8.3 file name in it,
+// so CreateDirectory fails from 248 on.
+const (
+	maxPath    = 260
+	maxDirPath = maxPath - 12
+	// minFileRoom is what a worktree path should leave below MAX_PATH for the
+	// files in it before wt suggests long paths.
+	minFileRoom = 60
+)
+
+// shortPath is set by --short-path: the new worktree gets a short hashed
+// directory name instead of the branch name.
+var shortPath bool
+
+// pathLength returns the length of path as Windows counts it, in UTF-16
+// code units.
+func pathLength(path string) int {
+	n := 0
+	for _, r := range path {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// longPathProblem checks a worktree path about to be created on goos. A path
+// Windows cannot create a directory at is an error unless git's core.longpaths
+// is on; one that leaves little room for the files in it gets a warning.
+func longPathProblem(goos, path string, longPaths bool) (string, error) {
+	if goos != "windows" {
+		return "", nil
+	}
+	n := pathLength(path)
+	switch {
+	case n >= maxDirPath && longPaths:
+		return fmt.Sprintf("⚠ Worktree path %s is %d characters, over the Windows limit of %d; git handles it with core.longpaths, other tools may not", displayPath(path), n, maxPath), nil
+	case n >= maxDirPath:
+		return "", fmt.Errorf("worktree path %s is %d characters, too long for a directory on Windows (at most %d)\n%s", displayPath(path), n, maxDirPath-1, longPathAdvice())
+	case n+minFileRoom > maxPath && !longPaths:
+		return fmt.Sprintf("⚠ Worktree path %s is %d characters, leaving %d for the files in it before the Windows limit of %d\n%s", displayPath(path), n, maxPath-n, maxPath, longPathAdvice()), nil
+	}
+	return "", nil
+}
+
+// longPathAdvice suggests the ways around the Windows path limit.
+func longPathAdvice() string {
+	advice := "Enable long paths with 'git config --global core.longpaths true' (and LongPathsEnabled in Windows)"
+	if !shortPath {
+		advice += ", or pass --short-path for a shorter directory name"
+	}
+	return advice
+}
+
+// checkWorktreePathLength reports a worktree path that is too long for
+// Windows before git fails on it with a less helpful error.
+func checkWorktreePathLength(path string) error {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	longPaths := false
+	if runtime.GOOS == "windows" {
+		output, err := repoGit("config", "--bool", "--get", "core.longpaths").Output()
+		longPaths = err == nil && strings.TrimSpace(string(output)) == "true"
+	}
+	warning, err := longPathProblem(runtime.GOOS, path, longPaths)
+	if warning != "" {
+		infoln(warning)
+	}
+	return err
+}
+
+// Worktrees created with --short-path are marked on their branch with
+// branch.<name>.wt-short-path=true, so that later path computations, e.g.
+// `wt move --all`, keep the short name. git records the real branch of the
+// worktree, which is what list and rm go by.
+func shortPathKey(branch string) string {
+	return "branch." + branch + ".wt-short-path"
+}
+
+// hasShortPath reports whether the worktree of branch uses a short name.
+func hasShortPath(branch string) bool {
+	output, err := repoGit("config", "--bool", "--get", shortPathKey(branch)).Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// markShortPath records that the worktree of branch uses a short name.
+func markShortPath(branch string) error {
+	return repoGit("config", "--bool", shortPathKey(branch), "true").Run()
+}
+
+// shortDirName returns the short directory name of branch: the start of its
+// last component, for recognition, and a hash of the whole name, for
+// uniqueness, e.g. "feature/JIRA-1234/refactor-the-billing-service" becomes
+// "refactor-the-bil-403828d1ea". The name is flat, so nesting is gone too.
+func shortDirName(branch string) string {
+	sum := sha256.Sum256([]byte(branch))
+	readable := branch[strings.LastIndex(branch, "/")+1:]
+	if getConfig().AsciiSlug {
+		readable = asciiSlug(readable)
+	}
+	if runes := []rune(readable); len(runes) > 16 {
+		readable = string(runes[:16])
+	}
+	readable = strings.TrimRight(readable, ".-_ ")
+	if readable == "" {
+		return hex.EncodeToString(sum[:5])
+	}
+	return readable + "-" + hex.EncodeToString(sum[:5])
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPathLength(t *testing.T) {
+	tests := []struct {
+		path string
+		want int
+	}{
+		{`C:\trees\api\feature`, 20},
+		{"café", 4},
+		{"修复", 2},
+		// Outside the BMP: a surrogate pair each.
+		{"fix-🐛", 6},
+	}
+	for _, tt := range tests {
+		if got := pathLength(tt.path); got != tt.want {
+			t.Errorf("pathLength(%q) = %d, want %d", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLongPathProblem(t *testing.T) {
+	pathOf := func(n int) string {
+		return `C:\` + strings.Repeat("a", n-3)
+	}
+	tests := []struct {
+		name      string
+		goos      string
+		path      string
+		longPaths bool
+		warning   bool
+		err       bool
+	}{
+		{"short", "windows", pathOf(100), false, false, false},
+		{"little room for files", "windows", pathOf(201), false, true, false},
+		{"little room with long paths", "windows", pathOf(201), true, false, false},
+		{"longest directory", "windows", pathOf(maxDirPath - 1), false, true, false},
+		{"too long", "windows", pathOf(maxDirPath), false, false, true},
+		{"too long with long paths", "windows", pathOf(300), true, true, false},
+		{"not windows", "linux", "/" + strings.Repeat("a", 400), false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning, err := longPathProblem(tt.goos, tt.path, tt.longPaths)
+			if (warning != "") != tt.warning || (err != nil) != tt.err {
+				t.Errorf("longPathProblem() = %q, %v; want warning %v, error %v", warning, err, tt.warning, tt.err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "core.longpaths") {
+				t.Errorf("longPathProblem() error %q does not suggest core.longpaths", err)
+			}
+		})
+	}
+}
+
+func TestShortDirName(t *testing.T) {
+	original := loadedConfig
+	t.Cleanup(func() { loadedConfig = original })
+	loadedConfig = &Config{}
+
+	long := "feature/JIRA-1234/refactor-the-billing-service"
+	if got := shortDirName(long); got != "refactor-the-bil-403828d1ea" {
+		t.Errorf("shortDirName(%q) = %q", long, got)
+	}
+	if shortDirName(long) != shortDirName(long) {
+		t.Error("shortDirName() is not stable")
+	}
+	// Branches that only differ early on get different names.
+	if shortDirName("team-a/"+long) == shortDirName("team-b/"+long) {
+		t.Error("shortDirName() gives two branches the same name")
+	}
+	for _, branch := range []string{"fix", "release/1.0.", "a/---", strings.Repeat("x", 200)} {
+		got := shortDirName(branch)
+		if strings.ContainsAny(got, `/\`) || pathLength(got) > 27 || strings.HasPrefix(got, "-") {
+			t.Errorf("shortDirName(%q) = %q, want one short component", branch, got)
+		}
+	}
+
+	loadedConfig = &Config{AsciiSlug: true}
+	if got := shortDirName("feature/café"); !strings.HasPrefix(got, "cafe-") {
+		t.Errorf("shortDirName() with asciiSlug = %q, want an ASCII name", got)
+	}
+}
+
+func TestShortPathIsRemembered(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	original := loadedConfig
+	t.Cleanup(func() { loadedConfig = original })
+	loadedConfig = &Config{}
+
+	branch := "feature/JIRA-1234/refactor-the-billing-service"
+	runGitCommand(t, repoDir, "branch", branch)
+	if got := worktreeDirName(branch); got != branch {
+		t.Errorf("worktreeDirName() = %q before --short-path", got)
+	}
+	if err := markShortPath(branch); err != nil {
+		t.Fatal(err)
+	}
+	if got := worktreeDirName(branch); got != shortDirName(branch) {
+		t.Errorf("worktreeDirName() = %q after --short-path, want %q", got, shortDirName(branch))
+	}
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	"github.com/timvw/wt/pkg/worktree"
 )
 
 var (
@@ -19,74 +22,308 @@ var (
 )
 
 func init() {
-	// Set worktree root from environment or default
-	worktreeRoot = os.Getenv("WORKTREE_ROOT")
-	if worktreeRoot == "" {
-		home, _ := os.UserHomeDir()
-		worktreeRoot = filepath.Join(home, "dev", "worktrees")
+	worktreeRoot = resolveWorktreeRoot(nil)
+}
+
+// worktreeRootSetting returns the worktree root as given by the
+// --worktree-root flag of cmd or, when that is not set, by WORKTREE_ROOT,
+// together with its name for messages. cmd may be nil before flags are
+// parsed.
+func worktreeRootSetting(cmd *cobra.Command) (root, source string) {
+	if cmd != nil {
+		if flag := cmd.Flags().Lookup("worktree-root"); flag != nil && flag.Changed {
+			return flag.Value.String(), "--worktree-root"
+		}
+	}
+	return os.Getenv("WORKTREE_ROOT"), "WORKTREE_ROOT"
+}
+
+// resolveWorktreeRoot returns the worktree root of cmd's invocation:
+// --worktree-root, WORKTREE_ROOT, or ~/dev/worktrees when neither is set.
+func resolveWorktreeRoot(cmd *cobra.Command) string {
+	root, _ := worktreeRootSetting(cmd)
+	path, _ := expandWorktreeRoot(hostPath(root), userHomeDir())
+	return path
+}
+
+// expandWorktreeRoot makes root absolute and clean. A leading ~ stands for
+// home, as it does in the shell but not when the variable is set with the ~
+// quoted. Other relative roots are taken relative to home rather than to the
+// current directory, so worktrees do not scatter over wherever wt happens to
+// run; relative reports that this happened.
+func expandWorktreeRoot(root, home string) (path string, relative bool) {
+	switch {
+	case root == "":
+		return filepath.Join(home, "dev", "worktrees"), false
+	case root == "~":
+		return filepath.Clean(home), false
+	case strings.HasPrefix(root, "~/") || strings.HasPrefix(root, `~`+string(filepath.Separator)):
+		return filepath.Join(home, root[2:]), false
+	case filepath.IsAbs(root):
+		return filepath.Clean(root), false
 	}
+	return filepath.Join(home, root), true
+}
+
+// warnRelativeWorktreeRoot warns when the worktree root of cmd's invocation
+// is a relative path.
+func warnRelativeWorktreeRoot(cmd *cobra.Command) {
+	if root, source := worktreeRootSetting(cmd); root != "" {
+		if path, relative := expandWorktreeRoot(root, userHomeDir()); relative {
+			fmt.Fprintf(os.Stderr, "warning: %s %q is relative; using %s (set an absolute path to silence this)\n", source, root, path)
+		}
+	}
+}
+
+func userHomeDir() string {
+	home, _ := os.UserHomeDir()
+	return home
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	start := time.Now()
+	silenceCancellation(rootCmd)
+	err := rootCmd.Execute()
+	if timings, _ := rootCmd.PersistentFlags().GetBool("timings"); timings {
+		printTimings(os.Stderr, tracedCommands(), time.Since(start))
+	}
+	if err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		recordFailure(err)
 		os.Exit(1)
 	}
 }
 
+// exitCodeError makes wt exit with a specific code without printing anything.
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// exitWithCode returns an error that terminates wt with code and suppresses
+// cobra's error and usage output.
+func exitWithCode(cmd *cobra.Command, code int) error {
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return &exitCodeError{code: code}
+}
+
+// exitCancelled is the exit code when the user cancels a prompt, as for a
+// command interrupted with Ctrl-C. The shell integration does not cd on it.
+const exitCancelled = 130
+
+// silenceCancellation makes every command below cmd exit with exitCancelled
+// and a single "cancelled" line when the user cancels a selection or
+// confirmation, rather than reporting an error.
+func silenceCancellation(cmd *cobra.Command) {
+	for _, sub := range cmd.Commands() {
+		silenceCancellation(sub)
+	}
+	run := cmd.RunE
+	if run == nil {
+		return
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		err := run(cmd, args)
+		if errors.Is(err, errSelectionCancelled) || errors.Is(err, errCancelled) {
+			fmt.Fprintln(os.Stderr, "cancelled")
+			return exitWithCode(cmd, exitCancelled)
+		}
+		return err
+	}
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "wt",
 	Short: "Git worktree helper with organized directory structure",
-	Long: `Git-like worktree management with organized directory structure.
-
-Worktrees are organized at: ` + worktreeRoot + `/<repo>/<branch>
-Set WORKTREE_ROOT to customize the location.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Flags and arguments are valid by now: errors from here on are not
+		// helped by the usage.
+		cmd.SilenceUsage = true
+		applyGitEnv(cmd)
+		quietConfig = cmd.HasParent() && cmd.Parent() == configCmd
+		applyMapPaths(cmd)
+		worktreeRoot = resolveWorktreeRoot(cmd)
+		warnRelativeWorktreeRoot(cmd)
+		quietGit, _ = cmd.Flags().GetBool("quiet-git")
+		noDirenv, _ = cmd.Flags().GetBool("no-direnv")
+		shortPath, _ = cmd.Flags().GetBool("short-path")
+		offline, _ = cmd.Flags().GetBool("offline")
+		networkTimeout, _ = cmd.Flags().GetDuration("timeout")
+		noHints, _ = cmd.Flags().GetBool("no-hints")
+		if err := applyRepoDirEnv(cmd); err != nil {
+			return err
+		}
+		// doctor reports an old git instead of failing on it.
+		if !repoIndependentCommands[cmd.Name()] && cmd != doctorCmd {
+			if err := checkRequiredGit(); err != nil {
+				return err
+			}
+		}
+		return applySuperFlag(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		_ = cmd.Help()
 	},
 }
 
+// rootLongHelp describes wt with the worktree root in effect right now.
+func rootLongHelp() string {
+	return `Git-like worktree management with organized directory structure.
+
+Worktrees are organized at: ` + resolveWorktreeRoot(rootCmd) + `/<repo>/<branch>
+Set WORKTREE_ROOT to customize the location, or pass --worktree-root for a
+single invocation.`
+}
+
 func init() {
+	defaultHelp := rootCmd.HelpFunc()
+	rootCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		if cmd == rootCmd {
+			cmd.Long = rootLongHelp()
+		}
+		defaultHelp(cmd, args)
+	})
+
+	rootCmd.PersistentFlags().String("worktree-root", "", "Worktree root for this invocation, overriding WORKTREE_ROOT")
+	_ = rootCmd.MarkPersistentFlagDirname("worktree-root")
+	rootCmd.PersistentFlags().Bool("respect-git-env", false, "Use GIT_DIR, GIT_WORK_TREE, GIT_COMMON_DIR and GIT_INDEX_FILE from the environment instead of ignoring them")
+	rootCmd.PersistentFlags().Bool("map-paths", false, "Rewrite printed paths with the pathMappings of the config (and map path arguments back)")
+	rootCmd.PersistentFlags().Bool("super", false, "Inside a submodule, operate on the superproject")
+	rootCmd.PersistentFlags().Bool("offline", false, "Do not fetch; use local copies of PRs/MRs")
+	rootCmd.PersistentFlags().Duration("timeout", defaultNetworkTimeout, "Give up fetching PRs/MRs after this long (0 for no limit)")
+	rootCmd.PersistentFlags().Bool("no-hints", false, "Do not print hints about next steps")
+	rootCmd.PersistentFlags().Bool("timings", false, "At exit, print the time taken by each git and gh/glab command and by wt itself to stderr")
+
+	createCmd.Flags().String("base", "", "Branch or commit to start the new branch from (default: main/master)")
+	_ = createCmd.RegisterFlagCompletionFunc("base", completeBranches)
+	createCmd.Flags().Bool("interactive-base", false, "Pick the base branch from a list when --base is not given")
+	createCmd.Flags().String("at", "", "Start at the last commit before a date (RFC3339, YYYY-MM-DD, 2.weeks.ago) or at a revision")
+	createCmd.Flags().String("branch", "", "With --at, create this branch instead of a detached worktree")
+	checkoutCmd.Flags().String("at", "", "With --detach, check out the branch as of a date or revision")
+	checkoutCmd.Flags().Bool("detach", false, "Check out a detached worktree of the branch (requires --at)")
+	pruneCmd.Flags().Bool("include-offline", false, "Also drop worktrees on volumes that are not mounted")
+	pruneCmd.Flags().Bool("dry-run", false, "Show what would be pruned and kept, and change nothing")
+	pruneCmd.Flags().Bool("json", false, "With --dry-run: output the cleanup plan as JSON, for 'wt cleanup --plan-file'")
+	checkoutCmd.Flags().String("copy-as", "", "Check out a copy of the branch as <branch>@<n>, for a second worktree of it")
+
+	// Commands that run git with its output on the terminal. `wt list` has
+	// its own -q/--quiet.
+	for _, cmd := range []*cobra.Command{checkoutCmd, createCmd, switchCmd, prCmd, mrCmd, removeCmd, pruneCmd, cloneCmd, moveCmd} {
+		addQuietGitFlag(cmd)
+	}
+	// Commands that create worktrees.
+	for _, cmd := range []*cobra.Command{checkoutCmd, createCmd, switchCmd, prCmd, mrCmd} {
+		cmd.Flags().Bool("no-direnv", false, "Do not set up direnv in the new worktree")
+		cmd.Flags().Bool("force", false, "Move a file or symlink in the way of the new worktree aside without asking")
+	}
+	// pr and mr get --yes with their bulk flags.
+	checkoutCmd.Flags().BoolP("yes", "y", false, "Prune a deleted worktree of the branch without asking")
+	createCmd.Flags().BoolP("yes", "y", false, "Prune a deleted worktree of the branch without asking")
+	switchCmd.Flags().StringP("create", "c", "", "Create this branch in a new worktree, as 'wt create' does, and switch to it")
+	switchCmd.Flags().StringP("force-create", "C", "", "Like --create, but reset the branch to the base if it exists (asks first)")
+	switchCmd.Flags().String("base", "", "With -c/-C: branch or commit to start the branch from (default: main/master)")
+	_ = switchCmd.RegisterFlagCompletionFunc("base", completeBranches)
+	_ = switchCmd.RegisterFlagCompletionFunc("force-create", completeBranches)
+	switchCmd.MarkFlagsMutuallyExclusive("create", "force-create")
+	switchCmd.Flags().BoolP("yes", "y", false, "Reset the branch of -C and prune a deleted worktree without asking")
+	for _, cmd := range []*cobra.Command{checkoutCmd, createCmd, switchCmd} {
+		cmd.Flags().Bool("short-path", false, "Use a short hashed directory name, for paths too long for Windows")
+		cmd.Flags().String("lock-scope", string(worktree.LockRepo), "Serialize concurrent runs on this clone per repo or per branch (none to skip)")
+		_ = cmd.RegisterFlagCompletionFunc("lock-scope", cobra.FixedCompletions(
+			[]string{string(worktree.LockRepo), string(worktree.LockBranch), "none"}, cobra.ShellCompDirectiveNoFileComp))
+		cmd.Flags().Duration("repo-lock-timeout", 60*time.Second, "Give up waiting for another wt operation on this clone after this long (0 to wait indefinitely)")
+	}
+	for _, cmd := range []*cobra.Command{checkoutCmd, createCmd} {
+		cmd.Flags().String("template", "", "Apply the settings of a template from the config (flags win over it)")
+		_ = cmd.RegisterFlagCompletionFunc("template", completeTemplates)
+	}
+
+	rootCmd.AddCommand(adoptCmd)
+	rootCmd.AddCommand(bisectCmd)
 	rootCmd.AddCommand(checkoutCmd)
+	rootCmd.AddCommand(switchCmd)
+	rootCmd.AddCommand(pathCmd)
+	rootCmd.AddCommand(cloneCmd)
+	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(debugCmd)
+	rootCmd.AddCommand(demoCmd)
+	rootCmd.AddCommand(execCmd)
 	rootCmd.AddCommand(prCmd)
 	rootCmd.AddCommand(mrCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(removeCmd)
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(cleanupCmd)
+	rootCmd.AddCommand(repairCmd)
+	rootCmd.AddCommand(moveCmd)
+	rootCmd.AddCommand(openCmd)
+	rootCmd.AddCommand(pinCmd)
+	rootCmd.AddCommand(unpinCmd)
+	rootCmd.AddCommand(parkCmd)
+	rootCmd.AddCommand(unparkCmd)
 	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(recentCmd)
 	rootCmd.AddCommand(shellenvCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(catCmd)
+	rootCmd.AddCommand(doctorCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
 // Helper functions
 
 func getRepoName() (string, error) {
-	// Try to get from remote origin URL
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	output, err := cmd.Output()
+	commonDir, err := repoCommonDir()
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository")
+	}
+	// Try to get from remote origin URL. A relative path remote is relative
+	// to the main worktree.
+	output, err := repoGit("remote", "get-url", "origin").Output()
+	url := strings.TrimSpace(string(output))
 	if err == nil {
-		url := strings.TrimSpace(string(output))
-		base := filepath.Base(url)
-		return strings.TrimSuffix(base, ".git"), nil
+		if name := repoNameFromURL(url, repoMainDir(commonDir)); name != "" {
+			return name, nil
+		}
 	}
 
-	// Fallback to toplevel directory name
-	cmd = exec.Command("git", "rev-parse", "--show-toplevel")
-	output, err = cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("not in a git repository")
+	// Fall back to the directory of the repository. Not the toplevel of the
+	// current worktree: from a linked worktree that would be its own name.
+	name := repoNameFromGitDir(commonDir)
+	if err == nil && !warnedRepoName {
+		warnedRepoName = true
+		fmt.Fprintf(os.Stderr, "warning: origin %q does not name the repository; using its directory name %q, which changes if the clone is moved\n", url, name)
 	}
-	toplevel := strings.TrimSpace(string(output))
-	return filepath.Base(toplevel), nil
+	return name, nil
 }
 
 func getDefaultBase() string {
-	cmd := exec.Command("git", "symbolic-ref", "refs/remotes/origin/HEAD")
-	output, err := cmd.Output()
-	if err != nil {
-		return "main"
+	output, err := repoGit("symbolic-ref", "refs/remotes/origin/HEAD").Output()
+	if err == nil {
+		ref := strings.TrimSpace(string(output))
+		return strings.TrimPrefix(ref, "refs/remotes/origin/")
+	}
+	// Without remotes, the branch of the main worktree is the best guess;
+	// "main" may not even exist.
+	if !hasRemote() {
+		if output, err := repoGit("symbolic-ref", "--short", "HEAD").Output(); err == nil {
+			return strings.TrimSpace(string(output))
+		}
 	}
-	ref := strings.TrimSpace(string(output))
-	return strings.TrimPrefix(ref, "refs/remotes/origin/")
+	return "main"
 }
 
 type RemoteType int
@@ -97,17 +334,29 @@ const (
 	RemoteUnknown
 )
 
-func getPRNumber(input string) (string, error) {
-	// Check if it's a GitHub PR URL
-	githubRegex := regexp.MustCompile(`^https://github\.com/.*/pull/([0-9]+)`)
-	if matches := githubRegex.FindStringSubmatch(input); matches != nil {
-		return matches[1], nil
-	}
+// Review URLs are matched on their path, so that whatever follows the number
+// in a browser bar, e.g. /files#diff-abc, /commits/<sha> or
+// /diffs?commit_id=<sha>, still names the review. GitLab projects may be in
+// subgroups.
+var (
+	githubReviewPath = regexp.MustCompile(`^/[^/]+/[^/]+/pull/([0-9]+)(?:/.*)?$`)
+	gitlabReviewPath = regexp.MustCompile(`^/[^/]+(?:/[^/]+)+/-/merge_requests/([0-9]+)(?:/.*)?$`)
+)
 
-	// Check if it's a GitLab MR URL
-	gitlabRegex := regexp.MustCompile(`^https://gitlab\.com/.*/-/merge_requests/([0-9]+)`)
-	if matches := gitlabRegex.FindStringSubmatch(input); matches != nil {
-		return matches[1], nil
+func getPRNumber(input string) (string, error) {
+	if u, err := url.Parse(input); err == nil && (u.Scheme == "https" || u.Scheme == "http") {
+		pattern := githubReviewPath
+		switch strings.TrimPrefix(strings.ToLower(u.Host), "www.") {
+		case "github.com":
+		case "gitlab.com":
+			pattern = gitlabReviewPath
+		default:
+			return "", fmt.Errorf("invalid PR/MR number or URL: %s", input)
+		}
+		if matches := pattern.FindStringSubmatch(u.Path); matches != nil {
+			return matches[1], nil
+		}
+		return "", fmt.Errorf("invalid PR/MR number or URL: %s", input)
 	}
 
 	// Check if it's just a number
@@ -119,108 +368,148 @@ func getPRNumber(input string) (string, error) {
 	return "", fmt.Errorf("invalid PR/MR number or URL: %s", input)
 }
 
-func worktreeExists(branch string) (string, bool) {
-	cmd := exec.Command("git", "worktree", "list")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", false
+// newManager returns the worktree manager for repo below WORKTREE_ROOT, wired
+// to the terminal and the configured directory naming.
+func newManager(repo string) *worktree.Manager {
+	return &worktree.Manager{
+		Root:    worktreeRoot,
+		Repo:    repo,
+		Git:     gitRunner(),
+		Stdout:  os.Stderr,
+		Stderr:  os.Stderr,
+		DirName: worktreeDirName,
+		// Skip lookups on origin in purely local repositories.
+		NoRemotes: !hasRemote(),
 	}
+}
 
-	lines := strings.Split(string(output), "\n")
-	searchPattern := fmt.Sprintf("[%s]", branch)
-	for _, line := range lines {
-		if strings.Contains(line, searchPattern) {
-			// Extract the path (first field)
-			fields := strings.Fields(line)
-			if len(fields) > 0 {
-				return fields[0], true
-			}
-		}
+// quietGit is set by -q/--quiet-git: git's own output is held back and only
+// shown when git fails, leaving just wt's summary on success.
+var quietGit bool
+
+// gitRunner returns the runner for git commands whose output reaches the
+// terminal. Its commands are traced for `wt debug last-failure`.
+func gitRunner() worktree.Runner {
+	if quietGit {
+		return tracingRunner{worktree.QuietRunner{}}
 	}
-	return "", false
+	return tracingRunner{worktree.ExecRunner{}}
 }
 
-func branchExists(branch string) bool {
-	// Check local branch
-	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", fmt.Sprintf("refs/heads/%s", branch))
-	if cmd.Run() == nil {
-		return true
-	}
+// offline is set by --offline: PRs/MRs are not fetched, only local copies
+// are checked out.
+var offline bool
 
-	// Check remote branch
-	cmd = exec.Command("git", "show-ref", "--verify", "--quiet", fmt.Sprintf("refs/remotes/origin/%s", branch))
-	return cmd.Run() == nil
-}
+// networkTimeout is set by --timeout and bounds fetches of PRs/MRs, so that
+// a broken network fails fast instead of stalling.
+var networkTimeout time.Duration
 
-func ensureWorktreePath(repo, branch string) (string, error) {
-	targetRoot := filepath.Join(worktreeRoot, repo)
+const defaultNetworkTimeout = time.Minute
 
-	info, err := os.Stat(targetRoot)
-	switch {
-	case err == nil:
-		if !info.IsDir() {
-			return "", fmt.Errorf("WORKTREE_ROOT path %s is not a directory", targetRoot)
-		}
-	case os.IsNotExist(err):
-		if err := os.MkdirAll(targetRoot, 0o755); err != nil {
-			return "", fmt.Errorf("failed to create WORKTREE_ROOT directory %s: %w", targetRoot, err)
-		}
-	default:
-		return "", fmt.Errorf("failed to access WORKTREE_ROOT directory %s: %w", targetRoot, err)
-	}
+// addQuietGitFlag registers -q/--quiet-git on a command that runs git.
+func addQuietGitFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolP("quiet-git", "q", false, "Hide git's own output unless it fails")
+}
 
-	return filepath.Join(targetRoot, branch), nil
+// lockScope returns the --lock-scope of cmd: what concurrent wt runs against
+// one clone, e.g. CI matrix jobs, wait for while adding a worktree.
+func lockScope(cmd *cobra.Command) (worktree.LockScope, error) {
+	scope, _ := cmd.Flags().GetString("lock-scope")
+	return worktree.ParseLockScope(scope)
 }
 
-func printCDMarker(path string) {
-	fmt.Printf("TREE_ME_CD:%s\n", path)
+// lockWait returns how long cmd waits for another wt operation holding the
+// lock, and how it describes itself to operations waiting for it.
+func lockWait(cmd *cobra.Command, branch string) (time.Duration, string) {
+	timeout, _ := cmd.Flags().GetDuration("repo-lock-timeout")
+	return timeout, cmd.Name() + " " + branch
 }
 
-func getAvailableBranches() ([]string, error) {
-	// Get local and remote branches
-	cmd := exec.Command("git", "branch", "-a", "--format=%(refname:short)")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
+func worktreeExists(branch string) (string, bool) {
+	wt, ok := newManager("").Find(branch)
+	return wt.Path, ok
+}
 
-	// Use a map to deduplicate
-	branchMap := make(map[string]bool)
+func branchExists(branch string) bool {
+	return newManager("").BranchExists(branch)
+}
 
-	for _, line := range strings.Split(string(output), "\n") {
-		branch := strings.TrimSpace(line)
-		if branch == "" {
-			continue
-		}
+func ensureWorktreePath(repo, branch string) (string, error) {
+	return newManager(repo).EnsurePath(branch)
+}
 
-		// Skip remote HEAD pointers and detached HEAD states
-		if strings.HasPrefix(branch, "origin/HEAD") || strings.Contains(branch, "->") || strings.Contains(branch, "HEAD") {
-			continue
-		}
+// reportExistingWorktree tells the user a branch already has a worktree and
+// cds there. A linked worktree outside WORKTREE_ROOT usually predates a change
+// of the root, so point at `wt move --all` instead of silently using it.
+func reportExistingWorktree(path string) {
+	infof("✓ Worktree already exists: %s\n", displayPath(path))
+	if outsideWorktreeRoot(path) {
+		fmt.Fprintf(os.Stderr, "warning: this worktree is outside WORKTREE_ROOT (%s)\n", worktreeRoot)
+		fmt.Fprintln(os.Stderr, "Run 'wt move --all' to migrate existing worktrees to the current root")
+	}
+	printCDMarker(path)
+}
 
-		// For remote branches, strip the origin/ prefix
-		branch = strings.TrimPrefix(branch, "origin/")
+// pruneDeletedWorktree handles a worktree of branch whose directory was
+// deleted by hand. git still has it registered (prunable), so the branch
+// counts as checked out and cannot get a new worktree. After confirmation the
+// stale entries are pruned so the caller can continue.
+func pruneDeletedWorktree(cmd *cobra.Command, branch string) error {
+	stale, ok := newManager("").Find(branch)
+	if ok && isOffline(stale) {
+		return fmt.Errorf("the worktree of '%s' is at %s, on a volume that is not mounted\nMount it again, or drop the worktree with 'wt prune --include-offline'", branch, displayPath(stale.Path))
+	}
+	if !ok || !stale.Prunable {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "The worktree of '%s' at %s was deleted, but git still has it registered.\n", branch, stale.Path)
+	pinned := loadPinnedBranches()
+	if pinned[stale.Branch] {
+		return fmt.Errorf("the worktree of '%s' is pinned; run 'wt unpin %s' to let wt prune it", branch, branch)
+	}
 
-		// Skip if branch name is just "origin" or other remote names
-		if branch == "origin" || branch == "upstream" {
-			continue
+	plan := []string{"'git worktree prune' will drop these stale entries:"}
+	if worktrees, err := listWorktrees(""); err == nil {
+		for _, wt := range worktrees {
+			if wt.Prunable && !pinned[wt.Branch] && !isOffline(wt) {
+				plan = append(plan, "  "+wt.Path)
+			}
 		}
-
-		// Add to map (deduplicates automatically)
-		branchMap[branch] = true
 	}
-
-	// Convert map to slice
-	branches := []string{}
-	for branch := range branchMap {
-		branches = append(branches, branch)
+	if err := confirmAction(cmd, "Prune stale worktrees and continue", plan...); err != nil {
+		return err
+	}
+	skipped, offlineSkipped, err := pruneWorktrees(false)
+	if err != nil {
+		return fmt.Errorf("failed to prune stale worktrees: %w", err)
 	}
+	infof("✓ Pruned the stale worktree of '%s'\n", branch)
+	printPinnedSkipped(skipped)
+	printOfflineSkipped(offlineSkipped)
+	return nil
+}
 
-	return branches, nil
+func printCDMarker(path string) {
+	path = filepath.Clean(path)
+	fmt.Printf("TREE_ME_CD:%s\n", markerPath(displayPath(path), os.Getenv("WT_PATH_STYLE")))
+	printCDHint(displayPath(path))
+	writeCDFile(path)
+	recordVisit(path)
+	touchWorktree(path)
+}
+
+// getAvailableBranches returns the local and remote branch names, without their
+// remote prefix and deduplicated, most recently committed first.
+func getAvailableBranches() ([]string, error) {
+	refs, err := listBranchRefs()
+	if err != nil {
+		return nil, err
+	}
+	return uniqueBranches(refs), nil
 }
 
 func getExistingWorktreeBranches() ([]string, error) {
-	cmd := exec.Command("git", "worktree", "list")
+	cmd := gitCommand("worktree", "list")
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -240,55 +529,110 @@ func getExistingWorktreeBranches() ([]string, error) {
 	return branches, nil
 }
 
-func parsePROutput(output string) ([]string, []string) {
-	var numbers []string
-	var labels []string
+// Worktree is a single entry of `git worktree list --porcelain`.
+type Worktree = worktree.Worktree
+
+// listWorktrees returns the worktrees of the repository containing dir
+// (the current directory when dir is empty).
+func listWorktrees(dir string) ([]Worktree, error) {
+	m := newManager("")
+	m.Dir = dir
+	worktrees, err := m.List()
+	if err == nil && !gitSupports(capPrunable) {
+		markMissingPrunable(worktrees)
+	}
+	return worktrees, err
+}
+
+// markMissingPrunable flags linked worktrees whose directory is gone, as git
+// does itself since 2.31, so that prune and pin work alike on older git.
+func markMissingPrunable(worktrees []Worktree) {
+	for i := range worktrees {
+		if i == 0 || worktrees[i].Bare {
+			continue
+		}
+		if _, err := os.Stat(worktrees[i].Path); os.IsNotExist(err) {
+			worktrees[i].Prunable = true
+		}
+	}
+}
+
+// Review is an open PR or MR as listed by gh or glab, independent of the
+// forge it comes from.
+type Review struct {
+	Number string
+	Title  string
+}
+
+// Label formats the review for pickers and summaries: "#123: Title" for PRs,
+// "!123: Title" for MRs.
+func (r Review) Label(remoteType RemoteType) string {
+	sigil := "#"
+	if remoteType == RemoteGitLab {
+		sigil = "!"
+	}
+	return fmt.Sprintf("%s%s: %s", sigil, r.Number, r.Title)
+}
+
+// reviewChoices returns the numbers and labels of reviews, index for index.
+func reviewChoices(reviews []Review, remoteType RemoteType) ([]string, []string) {
+	numbers := make([]string, len(reviews))
+	labels := make([]string, len(reviews))
+	for i, r := range reviews {
+		numbers[i] = r.Number
+		labels[i] = r.Label(remoteType)
+	}
+	return numbers, labels
+}
+
+// parsePROutput parses `gh pr list` output formatted as "<number>\t<title>"
+// lines.
+func parsePROutput(output string) []Review {
+	var reviews []Review
 	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		line = strings.TrimRight(line, "\r")
 		if line == "" {
 			continue
 		}
 		parts := strings.SplitN(line, "\t", 2)
 		if len(parts) == 2 {
-			numbers = append(numbers, parts[0])
-			labels = append(labels, fmt.Sprintf("#%s: %s", parts[0], parts[1]))
+			reviews = append(reviews, Review{Number: parts[0], Title: parts[1]})
 		}
 	}
-	return numbers, labels
+	return reviews
 }
 
 func getOpenPRs() ([]string, []string, error) {
-	cmd := exec.Command("gh", "pr", "list", "--json", "number,title", "--jq", ".[] | \"\\(.number)\\t\\(.title)\"")
-	output, err := cmd.Output()
+	output, err := runForgeCLI(RemoteGitHub, "pr", "list", "--json", "number,title", "--jq", ".[] | \"\\(.number)\\t\\(.title)\"")
 	if err != nil {
 		return nil, nil, err
 	}
 
-	numbers, labels := parsePROutput(string(output))
+	numbers, labels := reviewChoices(parsePROutput(string(output)), RemoteGitHub)
 	return numbers, labels, nil
 }
 
-func parseMROutput(output string) ([]string, []string) {
-	var numbers []string
-	var labels []string
-	// Parse glab output: !123  title  (branch) ← (target)
-	mrRegex := regexp.MustCompile(`^!(\d+)\s+[^\s]+\s+(.+?)\s+\(`)
+// mrRegex matches a line of `glab mr list`: !123  OPEN  title  (branch) ← (target)
+var mrRegex = regexp.MustCompile(`^!(\d+)\s+[^\s]+\s+(.+?)\s+\(`)
+
+// parseMROutput parses the human-readable output of `glab mr list`.
+func parseMROutput(output string) []Review {
+	var reviews []Review
 	for _, line := range strings.Split(output, "\n") {
 		if matches := mrRegex.FindStringSubmatch(line); matches != nil {
-			numbers = append(numbers, matches[1])
-			labels = append(labels, fmt.Sprintf("!%s: %s", matches[1], strings.TrimSpace(matches[2])))
+			reviews = append(reviews, Review{Number: matches[1], Title: strings.TrimSpace(matches[2])})
 		}
 	}
-	return numbers, labels
+	return reviews
 }
 
 func getOpenMRs() ([]string, []string, error) {
-	cmd := exec.Command("glab", "mr", "list")
-	output, err := cmd.Output()
+	output, err := runForgeCLI(RemoteGitLab, "mr", "list")
 	if err != nil {
 		return nil, nil, err
 	}
 
-	numbers, labels := parseMROutput(string(output))
+	numbers, labels := reviewChoices(parseMROutput(string(output)), RemoteGitLab)
 	return numbers, labels, nil
 }
 
@@ -300,6 +644,14 @@ var checkoutCmd = &cobra.Command{
 	Short:   "Checkout existing branch in new worktree",
 	Args:    cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		args = branchArgs(args)
+		lock, err := lockScope(cmd)
+		if err != nil {
+			return err
+		}
+		if _, err := applyTemplate(cmd); err != nil {
+			return err
+		}
 		var branch string
 
 		// Interactive selection if no branch provided
@@ -312,94 +664,379 @@ var checkoutCmd = &cobra.Command{
 				return fmt.Errorf("no available branches to checkout")
 			}
 
-			prompt := promptui.Select{
-				Label: "Select branch to checkout",
-				Items: branches,
-			}
-			_, result, err := prompt.Run()
+			idx, err := promptFor(cmd).Select("Select branch to checkout", branches, SelectOptions{})
 			if err != nil {
-				return fmt.Errorf("selection cancelled")
+				return err
 			}
-			branch = result
+			branch = branches[idx]
 		} else {
 			branch = args[0]
 		}
+		at, _ := cmd.Flags().GetString("at")
+		detach, _ := cmd.Flags().GetBool("detach")
+		switch {
+		case at != "" && detach:
+			return createSnapshot(cmd, "", "", branch, at)
+		case at != "":
+			return fmt.Errorf("--at needs --detach; use 'wt create <new-branch> --at %s --base %s' for a branch", at, branch)
+		case detach:
+			return fmt.Errorf("--detach needs --at")
+		}
 		repo, err := getRepoName()
 		if err != nil {
 			return err
 		}
+		if copyAs, _ := cmd.Flags().GetString("copy-as"); copyAs != "" {
+			if err := prepareCopy(branch, copyAs); err != nil {
+				return err
+			}
+			branch = copyAs
+		}
+		if err := pruneDeletedWorktree(cmd, branch); err != nil {
+			return err
+		}
 
 		// Check if worktree already exists
 		if existingPath, exists := worktreeExists(branch); exists {
-			fmt.Printf("✓ Worktree already exists: %s\n", existingPath)
-			printCDMarker(existingPath)
+			reportExistingWorktree(existingPath)
 			return nil
 		}
 
-		// Check if branch exists
-		if !branchExists(branch) {
+		if err := checkRepoDirOwner(repo); err != nil {
+			return err
+		}
+		m := newManager(repo)
+		m.Lock = lock
+		m.LockTimeout, m.Operation = lockWait(cmd, branch)
+		if err := checkWorktreePathLength(m.Path(branch)); err != nil {
+			return err
+		}
+		if err := resolvePathConflict(cmd, m.Path(branch)); err != nil {
+			return err
+		}
+		path, err := m.Checkout(branch)
+		if errors.Is(err, worktree.ErrBranchNotFound) {
 			return fmt.Errorf("branch '%s' does not exist\nUse 'wt create %s' to create a new branch", branch, branch)
 		}
-
-		path, err := ensureWorktreePath(repo, branch)
 		if err != nil {
 			return err
 		}
-
-		// Create worktree
-		gitCmd := exec.Command("git", "worktree", "add", path, branch)
-		gitCmd.Stdout = os.Stdout
-		gitCmd.Stderr = os.Stderr
-		if err := gitCmd.Run(); err != nil {
-			return fmt.Errorf("failed to create worktree: %w", err)
+		if shortPath {
+			_ = markShortPath(branch)
 		}
 
-		fmt.Printf("✓ Worktree created at: %s\n", path)
+		infof("✓ Worktree created at: %s\n", displayPath(path))
+		warnCrossDevice(path)
+		setupDirenv(repo, branch, path)
 		printCDMarker(path)
+		showHint(cmd.Name(), branch)
 		return nil
 	},
 }
 
+// chooseBase picks the base branch for createCmd from the --base flag and the
+// deprecated positional argument. It reports whether the positional form was
+// used so the caller can print a deprecation note.
+func chooseBase(flagBase string, positional []string) (string, bool, error) {
+	if len(positional) == 0 {
+		return flagBase, false, nil
+	}
+	if flagBase != "" && flagBase != positional[0] {
+		return "", true, fmt.Errorf("conflicting base branches: --base %s and positional %s", flagBase, positional[0])
+	}
+	return positional[0], true, nil
+}
+
+// commitExists reports whether rev resolves to a commit.
+func commitExists(rev string) bool {
+	return gitCommand("rev-parse", "--verify", "--quiet", rev+"^{commit}").Run() == nil
+}
+
+// headRelativeRegex matches bases relative to what the invoking worktree has
+// checked out: HEAD, @ and forms like HEAD~2, HEAD^ or @~1.
+var headRelativeRegex = regexp.MustCompile(`^(HEAD|@)([~^].*)?$`)
+
+// resolveHeadBase resolves a HEAD-relative base to a commit in the current
+// worktree, detached or not, rather than in the main clone. On failure git's
+// error is returned verbatim together with the worktree it was resolved in.
+func resolveHeadBase(base string) (string, error) {
+	output, err := gitCommand("rev-parse", base, "--").Output()
+	if err != nil {
+		where := "the current directory"
+		if top, topErr := gitCommand("rev-parse", "--show-toplevel").Output(); topErr == nil {
+			where = "worktree " + filepath.Clean(strings.TrimSpace(string(top)))
+		}
+		msg := err.Error()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			msg = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("cannot resolve base '%s' in %s: %s", base, where, msg)
+	}
+	commit, _, _ := strings.Cut(string(output), "\n")
+	return commit, nil
+}
+
+// completeBranches offers local and remote branch names for shell completion.
+func completeBranches(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	branches, err := getAvailableBranches()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return branches, cobra.ShellCompDirectiveNoFileComp
+}
+
+// selectBase asks the user for the base branch of a new branch.
+func selectBase(cmd *cobra.Command) (string, error) {
+	refs, err := listBranchRefs()
+	if err != nil {
+		return "", fmt.Errorf("failed to get branches: %w", err)
+	}
+	candidates := baseCandidates(refs, getDefaultBase())
+	idx, err := promptFor(cmd).Select("Select base branch", candidates, SelectOptions{})
+	if err != nil {
+		return "", err
+	}
+	return candidates[idx], nil
+}
+
 var createCmd = &cobra.Command{
-	Use:   "create <branch> [base-branch]",
+	Use:   "create <branch> [--base <branch>]",
 	Short: "Create new branch in worktree (default: main/master)",
-	Args:  cobra.RangeArgs(1, 2),
+	Long: `Create a new branch in a new worktree.
+
+The branch starts from --base, or from the repository's default branch
+(origin/HEAD, falling back to main) when no base is given. With
+--interactive-base (or askBase: true in the config) and no --base, wt asks
+for the base, offering main-like and release branches by recency.
+
+A base of HEAD, @ or a relative ref like HEAD~2 is resolved in the worktree
+wt runs in, so the new branch starts exactly at the commit checked out there,
+even on a detached HEAD.
+
+Examples:
+  wt create my-feature                  # Branch off the default branch
+  wt create hotfix --base release/2.3   # Branch off another branch
+  wt create hotfix --interactive-base   # Pick the base from a list
+  wt create fix --base HEAD             # Branch off the commit checked out here
+  wt create --at 2024-05-14             # The default branch as of that day, detached
+  wt create --at 2.weeks.ago --base release/2.3 --branch bisect-start
+  wt create my-feature --publish        # Also push it and open a draft PR/MR
+
+With --publish[=draft|ready] the new branch is pushed with upstream tracking
+and a PR (gh) or MR (glab) is opened for it, as 'wt publish' does: a draft
+unless =ready, titled after the branch name or --title, with the
+description from --body-file. When publishing fails the worktree is kept and
+the 'wt publish' command to retry is printed.
+
+With --at <date|rev> the worktree starts at the last commit on the base
+before the date (RFC3339, YYYY-MM-DD meaning the end of that day, or
+anything git understands, e.g. 2.weeks.ago), or at the revision. It is
+detached, in a directory named after the day (snapshot-2024-05-14) or the
+optional argument, unless --branch names a new branch. 'wt info' shows the
+requested point.
+
+The base may still be given as a second positional argument, but this form is
+deprecated.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if at, _ := cmd.Flags().GetString("at"); at != "" {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		return cobra.RangeArgs(1, 2)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		branch := args[0]
-		base := getDefaultBase()
-		if len(args) > 1 {
-			base = args[1]
+		tmpl, err := applyTemplate(cmd)
+		if err != nil {
+			return err
+		}
+		flagBase, _ := cmd.Flags().GetString("base")
+		at, _ := cmd.Flags().GetString("at")
+		newBranch, _ := cmd.Flags().GetString("branch")
+		if at == "" && newBranch != "" {
+			return fmt.Errorf("--branch is only used with --at; pass the branch as the argument instead")
+		}
+		publish := cmd.Flags().Changed("publish")
+		publishOpts := publishFlags(cmd)
+		if publish {
+			if at != "" {
+				return fmt.Errorf("--publish cannot be used with --at")
+			}
+			mode, _ := cmd.Flags().GetString("publish")
+			if publishOpts.Ready, err = parsePublishMode(mode); err != nil {
+				return err
+			}
+			// Fail before creating anything when the description is missing.
+			if _, err := readPublishBody(publishOpts); err != nil {
+				return err
+			}
+		} else if publishOpts.Title != "" || publishOpts.BodyFile != "" {
+			return fmt.Errorf("--title and --body-file are only used with --publish")
+		}
+		if at != "" {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			if name != "" && newBranch != "" {
+				return fmt.Errorf("pass either a directory name or --branch, not both")
+			}
+			base := flagBase
+			if base == "" {
+				base = tmpl.Base
+			}
+			askBase, _ := cmd.Flags().GetBool("interactive-base")
+			if base == "" && (askBase || getConfig().AskBase) {
+				if base, err = selectBase(cmd); err != nil {
+					return err
+				}
+			}
+			return createSnapshot(cmd, name, newBranch, base, at)
 		}
 
-		repo, err := getRepoName()
+		branch := args[0]
+		base, deprecated, err := chooseBase(flagBase, args[1:])
 		if err != nil {
 			return err
 		}
-
-		// Check if worktree already exists
-		if existingPath, exists := worktreeExists(branch); exists {
-			fmt.Printf("✓ Worktree already exists: %s\n", existingPath)
-			printCDMarker(existingPath)
+		if deprecated {
+			fmt.Fprintf(os.Stderr, "note: passing the base branch as a positional argument is deprecated; use 'wt create %s --base %s'\n", branch, base)
+		}
+		if base == "" {
+			base = tmpl.Base
+		}
+		askBase, _ := cmd.Flags().GetBool("interactive-base")
+		if base == "" && (askBase || getConfig().AskBase) {
+			base, err = selectBase(cmd)
+			if err != nil {
+				return err
+			}
+		}
+		if err := createWorktree(cmd, branch, base); err != nil || !publish {
+			return err
+		}
+		// The worktree stays when publishing fails: it is what the user
+		// asked for first, and the retry can run from inside it.
+		url, err := publishBranch(branch, publishOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ Worktree created, but publishing failed: %v\nRetry with: %s\n", err, publishRetryCommand(branch, publishOpts))
 			return nil
 		}
+		reportPublished(url, publishOpts)
+		return nil
+	},
+}
+
+// createWorktree creates branch from base (the default branch when empty) in
+// a new worktree and cds there. It is the code path of every command that
+// creates branches, so they resolve bases and report results the same way.
+func createWorktree(cmd *cobra.Command, branch, base string) error {
+	return addBranchWorktree(cmd, branch, base, false)
+}
 
-		path, err := ensureWorktreePath(repo, branch)
+// resetWorktree is createWorktree for a branch that may exist: after
+// confirmation, an existing branch is moved to base before its worktree is
+// created.
+func resetWorktree(cmd *cobra.Command, branch, base string) error {
+	return addBranchWorktree(cmd, branch, base, true)
+}
+
+func addBranchWorktree(cmd *cobra.Command, branch, base string, reset bool) error {
+	lock, err := lockScope(cmd)
+	if err != nil {
+		return err
+	}
+	if base == "" {
+		warnStaleDefaultBranch()
+		base = getDefaultBase()
+	}
+	if headRelativeRegex.MatchString(base) {
+		resolved, err := resolveHeadBase(base)
 		if err != nil {
 			return err
 		}
+		base = resolved
+	} else if !commitExists(base) {
+		return fmt.Errorf("base branch '%s' not found", base)
+	}
+
+	repo, err := getRepoName()
+	if err != nil {
+		return err
+	}
+	if err := pruneDeletedWorktree(cmd, branch); err != nil {
+		return err
+	}
 
-		// Create new branch and worktree
-		gitCmd := exec.Command("git", "worktree", "add", path, "-b", branch, base)
-		gitCmd.Stdout = os.Stdout
-		gitCmd.Stderr = os.Stderr
-		if err := gitCmd.Run(); err != nil {
-			return fmt.Errorf("failed to create worktree: %w", err)
+	// Check if worktree already exists
+	if existingPath, exists := worktreeExists(branch); exists {
+		if reset {
+			return fmt.Errorf("branch '%s' is checked out at %s; cannot reset it", branch, displayPath(existingPath))
+		}
+		reportExistingWorktree(existingPath)
+		return nil
+	}
+	// Only a branch wt created is wt's to delete later, not one -C reset.
+	existed := false
+	if reset {
+		if err := confirmReset(cmd, branch, base); err != nil {
+			return err
 		}
+		existed = branchExists(branch)
+	}
 
-		fmt.Printf("✓ Worktree created at: %s\n", path)
-		printCDMarker(path)
+	if err := checkRepoDirOwner(repo); err != nil {
+		return err
+	}
+	m := newManager(repo)
+	m.Lock = lock
+	m.LockTimeout, m.Operation = lockWait(cmd, branch)
+	if err := checkWorktreePathLength(m.Path(branch)); err != nil {
+		return err
+	}
+	if err := resolvePathConflict(cmd, m.Path(branch)); err != nil {
+		return err
+	}
+	create := m.Create
+	if reset {
+		create = m.Reset
+	}
+	path, err := create(branch, base)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		_ = markBranchOwned("", branch)
+	}
+	if shortPath {
+		_ = markShortPath(branch)
+	}
+
+	infof("✓ Worktree created at: %s\n", displayPath(path))
+	warnCrossDevice(path)
+	setupDirenv(repo, branch, path)
+	printCDMarker(path)
+	showHint(cmd.Name(), branch)
+	return nil
+}
+
+// confirmReset asks before resetWorktree moves an existing branch. A new
+// branch, or one already at base, moves nothing and needs no answer.
+func confirmReset(cmd *cobra.Command, branch, base string) error {
+	from, fromShort, err := resolveCommit("refs/heads/" + branch)
+	if err != nil {
 		return nil
-	},
+	}
+	to, toShort, err := resolveCommit(base)
+	if err != nil || from == to {
+		return nil
+	}
+	plan := []string{fmt.Sprintf("  %s: %s -> %s (%s)", branch, fromShort, toShort, base)}
+	if output, err := repoGit("rev-list", "--count", to+".."+from).Output(); err == nil {
+		if n := strings.TrimSpace(string(output)); n != "0" {
+			plan = append(plan, fmt.Sprintf("  %s commit(s) on %s will no longer be on the branch", n, branch))
+		}
+	}
+	return confirmAction(cmd, fmt.Sprintf("Reset branch %s to %s", branch, base), plan...)
 }
 
 var prCmd = &cobra.Command{
@@ -407,41 +1044,86 @@ var prCmd = &cobra.Command{
 	Short: "Checkout GitHub PR in worktree (uses gh CLI)",
 	Long: `Checkout a GitHub Pull Request in a worktree.
 
-Uses the 'gh' CLI to fetch and checkout pull requests.
+A PR given by number or URL is fetched with plain git (pull/<n>/head), so the
+'gh' CLI is optional there, e.g. in CI with a token-authenticated remote.
+Interactive selection, --all and 'wt pr list' need gh. With --output json the
+result is printed as JSON and no auto-cd happens.
 For GitLab Merge Requests, use 'wt mr' instead.
 
+The PR is fetched every time, giving up after --timeout. When that fails and
+pr-<n> exists locally, the local copy is used with a warning saying how old
+it is. --no-fetch uses an existing pr-<n> as is; --offline never fetches.
+
+For a re-review, --at <sha> checks pr-<n> out at a commit of the PR, e.g. the
+one you reviewed last, instead of its latest head; the commit must be part of
+the PR as fetched. --round <n> picks the head of the n-th round of review
+instead, counting force-pushes as GitHub records them (needs gh). An existing
+pr-<n> worktree is moved there if it has no changes. 'wt status' then shows
+how many commits were pushed since.
+
 Examples:
   wt pr                                        # Interactive PR selection
   wt pr 123                                    # GitHub PR number
-  wt pr https://github.com/org/repo/pull/123   # GitHub PR URL`,
+  wt pr https://github.com/org/repo/pull/123   # GitHub PR URL
+  wt pr https://github.com/org/repo/pull/123/files#diff-abc  # Any page of the PR
+  wt pr 123 --isolated                         # Separate pr-123 worktree even if the PR branch is checked out
+  wt pr 123 --no-fetch                         # Reuse the local pr-123 without fetching
+  wt pr 123 --at 4f2c1e9                       # The commit you reviewed last
+  wt pr 123 --round 2                          # The head after the first force-push
+  wt pr 123 --output json                      # Script mode: {"number","branch","path","existed"}
+  wt pr --all --label needs-qa                 # Worktrees for every matching PR
+  wt pr list                                   # Open PRs and their worktrees, without checking out
+  wt pr diff 123 --stat                        # Glance at a PR without checking it out
+  wt pr --preview                              # Interactive selection showing the diff stat first
+  wt pr --web                                  # Open the PR of the current branch in the browser`,
 	Args: cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireRemote(); err != nil {
+			return err
+		}
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			if len(args) > 0 {
+				return fmt.Errorf("--all cannot be combined with a PR number")
+			}
+			return runBulkReviewCheckout(cmd, RemoteGitHub)
+		}
+		if web, _ := cmd.Flags().GetBool("web"); web {
+			var number string
+			if len(args) > 0 {
+				n, err := getPRNumber(args[0])
+				if err != nil {
+					return err
+				}
+				number = n
+			}
+			return openWeb(RemoteGitHub, number)
+		}
+
 		var input string
 
 		// Interactive selection if no PR provided
 		if len(args) == 0 {
+			if err := requireReviewCLI(RemoteGitHub); err != nil {
+				return err
+			}
 			numbers, labels, err := getOpenPRs()
 			if err != nil {
-				return fmt.Errorf("failed to get PRs: %w (is 'gh' CLI installed?)", err)
+				return describeForgeError("PRs", RemoteGitHub, err)
 			}
 			if len(labels) == 0 {
 				return fmt.Errorf("no open PRs found")
 			}
 
-			prompt := promptui.Select{
-				Label: "Select Pull Request",
-				Items: labels,
-			}
-			idx, _, err := prompt.Run()
+			input, err = selectReview(cmd, RemoteGitHub, "Select Pull Request", numbers, labels)
 			if err != nil {
-				return fmt.Errorf("selection cancelled")
+				return err
 			}
-			input = numbers[idx]
 		} else {
 			input = args[0]
 		}
 
-		return checkoutPROrMR(input, RemoteGitHub)
+		isolated, _ := cmd.Flags().GetBool("isolated")
+		return checkoutPROrMR(cmd, input, RemoteGitHub, isolated)
 	},
 }
 
@@ -450,124 +1132,338 @@ var mrCmd = &cobra.Command{
 	Short: "Checkout GitLab MR in worktree (uses glab CLI)",
 	Long: `Checkout a GitLab Merge Request in a worktree.
 
-Uses the 'glab' CLI to fetch and checkout merge requests.
+An MR given by number or URL is fetched with plain git
+(merge-requests/<n>/head), so the 'glab' CLI is optional there, e.g. in CI.
+Interactive selection, --all and 'wt mr list' need glab. With --output json
+the result is printed as JSON and no auto-cd happens.
 For GitHub Pull Requests, use 'wt pr' instead.
 
+The MR is fetched every time, giving up after --timeout. When that fails and
+mr-<n> exists locally, the local copy is used with a warning saying how old
+it is. --no-fetch uses an existing mr-<n> as is; --offline never fetches.
+
+For a re-review, --at <sha> checks mr-<n> out at a commit of the MR, e.g. the
+one you reviewed last, instead of its latest head; the commit must be part of
+the MR as fetched. An existing mr-<n> worktree is moved there if it has no
+changes. 'wt status' then shows how many commits were pushed since.
+
 Examples:
   wt mr                                        # Interactive MR selection
   wt mr 123                                    # GitLab MR number
-  wt mr https://gitlab.com/org/repo/-/merge_requests/123  # GitLab MR URL`,
+  wt mr https://gitlab.com/org/repo/-/merge_requests/123  # GitLab MR URL
+  wt mr https://gitlab.com/group/sub/repo/-/merge_requests/123/diffs  # Any page of the MR, in subgroups too
+  wt mr 123 --isolated                         # Separate mr-123 worktree even if the MR branch is checked out
+  wt mr 123 --no-fetch                         # Reuse the local mr-123 without fetching
+  wt mr 123 --at 4f2c1e9                       # The commit you reviewed last
+  wt mr 123 --output json                      # Script mode: {"number","branch","path","existed"}
+  wt mr --all --label needs-qa                 # Worktrees for every matching MR
+  wt mr list                                   # Open MRs and their worktrees, without checking out
+  wt mr diff 123 --stat                        # Glance at an MR without checking it out
+  wt mr --preview                              # Interactive selection showing the diff stat first
+  wt mr --web                                  # Open the MR of the current branch in the browser`,
 	Args: cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireRemote(); err != nil {
+			return err
+		}
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			if len(args) > 0 {
+				return fmt.Errorf("--all cannot be combined with a MR number")
+			}
+			return runBulkReviewCheckout(cmd, RemoteGitLab)
+		}
+		if web, _ := cmd.Flags().GetBool("web"); web {
+			var number string
+			if len(args) > 0 {
+				n, err := getPRNumber(args[0])
+				if err != nil {
+					return err
+				}
+				number = n
+			}
+			return openWeb(RemoteGitLab, number)
+		}
+
 		var input string
 
 		// Interactive selection if no MR provided
 		if len(args) == 0 {
+			if err := requireReviewCLI(RemoteGitLab); err != nil {
+				return err
+			}
 			numbers, labels, err := getOpenMRs()
 			if err != nil {
-				return fmt.Errorf("failed to get MRs: %w (is 'glab' CLI installed?)", err)
+				return describeForgeError("MRs", RemoteGitLab, err)
 			}
 			if len(labels) == 0 {
 				return fmt.Errorf("no open MRs found")
 			}
 
-			prompt := promptui.Select{
-				Label: "Select Merge Request",
-				Items: labels,
-			}
-			idx, _, err := prompt.Run()
+			input, err = selectReview(cmd, RemoteGitLab, "Select Merge Request", numbers, labels)
 			if err != nil {
-				return fmt.Errorf("selection cancelled")
+				return err
 			}
-			input = numbers[idx]
 		} else {
 			input = args[0]
 		}
 
-		return checkoutPROrMR(input, RemoteGitLab)
+		isolated, _ := cmd.Flags().GetBool("isolated")
+		return checkoutPROrMR(cmd, input, RemoteGitLab, isolated)
 	},
 }
 
-func checkoutPROrMR(input string, remoteType RemoteType) error {
+func checkoutPROrMR(cmd *cobra.Command, input string, remoteType RemoteType, isolated bool) error {
 	prNumber, err := getPRNumber(input)
 	if err != nil {
 		return err
 	}
+	output, _ := cmd.Flags().GetString("output")
+	if output != outputText && output != outputJSON {
+		return fmt.Errorf("invalid --output %q (expected %s or %s)", output, outputText, outputJSON)
+	}
+	if output == outputJSON {
+		// Keep stdout for the JSON document; git's output is shown on failure.
+		quietGit = true
+	}
+
+	// A number is fetched with plain git (pull/<n>/head), so the forge CLI is
+	// optional here: without it only the lookup of the PR's head branch is
+	// skipped. This keeps CI with a token-authenticated remote working.
+	repo, err := getRepoName()
+	if err != nil {
+		return err
+	}
+
+	kind := strings.ToUpper(reviewPrefix(remoteType))
+	at, round := reviewPointFlags(cmd)
+	var point *reviewPoint
+	if at != "" || round > 0 {
+		p, err := resolveReviewPoint(prNumber, remoteType, at, round)
+		if err != nil {
+			return err
+		}
+		point = &p
+		// A review point needs wt's own copy of the branch.
+		isolated = true
+	}
+	if !isolated {
+		if path, branch, ok := existingReviewWorktree(prNumber, remoteType); ok && branch != reviewBranch(prNumber, remoteType) {
+			if output == outputJSON {
+				return writeJSON(os.Stdout, reviewCheckout{prNumber, branch, displayPath(path), true})
+			}
+			infof("✓ %s #%s is already checked out as %s: %s\n", kind, prNumber, branch, displayPath(path))
+			infoln("  Use --isolated to check it out into its own worktree")
+			printCDMarker(path)
+			return nil
+		}
+	}
+
+	if err := pruneDeletedWorktree(cmd, reviewBranch(prNumber, remoteType)); err != nil {
+		return err
+	}
+	if _, exists := worktreeExists(reviewBranch(prNumber, remoteType)); !exists {
+		if err := resolvePathConflict(cmd, newManager(repo).Path(reviewBranch(prNumber, remoteType))); err != nil {
+			return err
+		}
+	}
+	noFetch, _ := cmd.Flags().GetBool("no-fetch")
+	path, existed, err := addReviewWorktree(repo, prNumber, remoteType, noFetch, point)
+	if err != nil {
+		return err
+	}
+	if point != nil && existed {
+		if err := pinReviewWorktree(path, *point); err != nil {
+			return err
+		}
+		recordReviewPoint(reviewBranch(prNumber, remoteType), *point)
+	}
+
+	if output == outputJSON {
+		return writeJSON(os.Stdout, reviewCheckout{prNumber, reviewBranch(prNumber, remoteType), displayPath(path), existed})
+	}
+	if point != nil {
+		infof("✓ %s #%s checked out %s: %s\n", kind, prNumber, describeReviewPoint(*point), displayPath(path))
+		printCDMarker(path)
+		return nil
+	}
+	if existed {
+		reportExistingWorktree(path)
+		return nil
+	}
+	infof("✓ %s #%s checked out at: %s\n", kind, prNumber, displayPath(path))
+	printCDMarker(path)
+	showHint(cmd.Name(), reviewBranch(prNumber, remoteType))
+	return nil
+}
+
+// Values of the --output flag of `wt pr` and `wt mr`.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// reviewCheckout is the result of `wt pr`/`wt mr` with --output json. With
+// --output json no TREE_ME_CD marker is printed.
+type reviewCheckout struct {
+	Number  string `json:"number"`
+	Branch  string `json:"branch"`
+	Path    string `json:"path"`
+	Existed bool   `json:"existed"`
+}
+
+// reviewPrefix returns the branch prefix used for PR/MR worktrees.
+func reviewPrefix(remoteType RemoteType) string {
+	if remoteType == RemoteGitLab {
+		return "mr"
+	}
+	return "pr"
+}
 
-	var refSpec, prefix string
+// reviewBranch returns the local branch wt uses for a PR/MR, e.g. pr-123.
+func reviewBranch(number string, remoteType RemoteType) string {
+	return fmt.Sprintf("%s-%s", reviewPrefix(remoteType), number)
+}
 
+// reviewRefSpec returns the remote ref holding the head of a PR/MR.
+func reviewRefSpec(number string, remoteType RemoteType) string {
+	if remoteType == RemoteGitLab {
+		return fmt.Sprintf("merge-requests/%s/head", number)
+	}
+	return fmt.Sprintf("pull/%s/head", number)
+}
+
+// requireReviewCLI checks that the forge CLI for remoteType is installed.
+func requireReviewCLI(remoteType RemoteType) error {
 	switch remoteType {
 	case RemoteGitHub:
-		refSpec = fmt.Sprintf("pull/%s/head", prNumber)
-		prefix = "pr"
 		if _, err := exec.LookPath("gh"); err != nil {
 			return fmt.Errorf("'gh' CLI not found. Install it from https://cli.github.com")
 		}
 	case RemoteGitLab:
-		refSpec = fmt.Sprintf("merge-requests/%s/head", prNumber)
-		prefix = "mr"
 		if _, err := exec.LookPath("glab"); err != nil {
 			return fmt.Errorf("'glab' CLI not found. Install it from https://gitlab.com/gitlab-org/cli")
 		}
 	default:
 		return fmt.Errorf("invalid remote type")
 	}
+	return nil
+}
 
-	repo, err := getRepoName()
-	if err != nil {
-		return err
-	}
-
-	branch := fmt.Sprintf("%s-%s", prefix, prNumber)
+// addReviewWorktree fetches a PR/MR into its pr-<n>/mr-<n> branch and adds a
+// worktree for it. It reports whether the worktree already existed, in which
+// case nothing is fetched. With noFetch or --offline an existing local
+// branch is used as is; when the local branch is used because the fetch
+// failed, a notice with its age is printed. With a review point, already
+// fetched, the branch starts at its commit instead.
+func addReviewWorktree(repo, number string, remoteType RemoteType, noFetch bool, point *reviewPoint) (string, bool, error) {
+	branch := reviewBranch(number, remoteType)
 
 	// Check if worktree already exists
 	if existingPath, exists := worktreeExists(branch); exists {
-		fmt.Printf("✓ Worktree already exists: %s\n", existingPath)
-		printCDMarker(existingPath)
-		return nil
+		return existingPath, true, nil
 	}
 
-	path, err := ensureWorktreePath(repo, branch)
+	if err := checkRepoDirOwner(repo); err != nil {
+		return "", false, err
+	}
+	// Bulk checkouts do not ask; a single one has moved the file aside.
+	if conflict := describePathConflict(newManager(repo).Path(branch)); conflict != "" {
+		return "", false, fmt.Errorf("%s; move it away and try again", conflict)
+	}
+	if point != nil {
+		// No worktree has the branch checked out, so it can be moved.
+		if output, err := repoGit("branch", "-f", branch, point.At).CombinedOutput(); err != nil {
+			return "", false, fmt.Errorf("failed to point %s at %s: %s", branch, shortSHA(point.At), firstLine(string(output)))
+		}
+		noFetch = true
+	}
+	checkout, err := newManager(repo).CheckoutRefWithOptions(reviewRefSpec(number, remoteType), branch, worktree.CheckoutRefOptions{
+		NoFetch:      noFetch,
+		Offline:      offline,
+		FetchTimeout: networkTimeout,
+	})
 	if err != nil {
-		return err
+		return "", false, err
 	}
-
-	// Fetch the PR/MR
-	fetchCmd := exec.Command("git", "fetch", "origin", fmt.Sprintf("%s:%s", refSpec, branch))
-	fetchCmd.Stderr = os.Stderr
-	_ = fetchCmd.Run() // Ignore errors, branch might already exist
-
-	// Create worktree
-	gitCmd := exec.Command("git", "worktree", "add", path, branch)
-	gitCmd.Stdout = os.Stdout
-	gitCmd.Stderr = os.Stderr
-	if err := gitCmd.Run(); err != nil {
-		return fmt.Errorf("failed to create worktree: %w", err)
+	if !checkout.Fetched && point == nil {
+		fetchedAt, known := branchFetchedAt(branch)
+		fmt.Fprintln(os.Stderr, staleNotice(branch, checkout.FetchErr, fetchedAt, known, time.Now(), colorEnabled(os.Stderr)))
 	}
-
-	fmt.Printf("✓ %s #%s checked out at: %s\n", strings.ToUpper(prefix), prNumber, path)
-	printCDMarker(path)
-	return nil
-}
-
-var listCmd = &cobra.Command{
-	Use:     "list",
-	Aliases: []string{"ls"},
-	Short:   "List all worktrees",
-	Run: func(cmd *cobra.Command, args []string) {
-		gitCmd := exec.Command("git", "worktree", "list")
-		gitCmd.Stdout = os.Stdout
-		gitCmd.Stderr = os.Stderr
-		_ = gitCmd.Run()
-	},
+	recordReviewBranch(number, remoteType, branch)
+	if point != nil {
+		recordReviewPoint(branch, *point)
+	} else {
+		clearReviewPoint(branch)
+	}
+	_ = markBranchOwned("", branch)
+	warnCrossDevice(checkout.Path)
+	setupDirenv(repo, branch, checkout.Path)
+	return checkout.Path, false, nil
 }
 
 var removeCmd = &cobra.Command{
 	Use:     "remove [branch]",
 	Aliases: []string{"rm"},
 	Short:   "Remove a worktree",
-	Args:    cobra.RangeArgs(0, 1),
+	Long: `Remove a worktree.
+
+With --delete-branch the branch is deleted as well, provided wt created it
+(see 'wt adopt'); pass --include-unowned to also delete branches created
+outside wt. For PR/MR worktrees --delete-branch also enables --review-cleanup,
+which clears the review metadata. Each cleanup step is attempted
+independently and summarized at the end.
+
+wt asks for confirmation first; pass --yes (or set assumeYes: true in the
+config) to skip it. Without a terminal to ask on, wt refuses unless --yes is
+given.
+
+Git-ignored files matching the preRemoveBackup patterns of the config (e.g.
+.idea, *.sqlite) are first copied to WORKTREE_ROOT/.backups/<repo>/
+<branch>-<timestamp>/; keepBackups limits how many backups of a branch are
+kept. Pass --no-backup to skip it.
+
+--dry-run shows all of this without changing anything: the worktree and its
+modified and untracked files, the backup, what happens to the branch and the
+review, and whether your shell switches to the main worktree. It is the same
+plan the confirmation shows; add --json for tooling. A worktree with changes
+is not removed unless --force is given; its changes are lost then.
+
+Other processes whose current directory is in the worktree, such as a shell
+in another terminal, are listed in the confirmation: removing the worktree
+leaves them in a deleted directory. --yes alone does not remove it then; pass
+--force, or answer the extra question. wt finds them through /proc on Linux
+and lsof on macOS, skipping what it may not inspect; --no-process-check
+skips the check.
+
+'.' stands for the worktree containing the current directory.
+
+--others removes every worktree except the main one and the one you are in,
+after one confirmation listing them all. Pinned, locked and detached worktrees
+are kept, and so are worktrees with changes unless --force is given; the
+summary says why. wt exits with status 1 unless every listed worktree was
+removed, and stays in the current directory.
+In a repository listed in protectedRepos of the config (by name or
+owner/name), --others refuses to run. Pass --override-protection to go ahead
+after typing the repository name; --yes does not skip this. Removing a single
+worktree is always allowed.
+
+Examples:
+  wt rm feature-x                    # Remove the worktree, keep the branch
+  wt rm .                            # Remove the worktree you are in
+  wt rm feature-x --yes              # Remove without asking, e.g. in scripts
+  wt rm feature-x --dry-run          # Show what removing it entails
+  wt rm feature-x --delete-branch    # Remove the worktree and the merged branch
+  wt rm pr-512 --delete-branch       # Remove a PR worktree and everything wt added for it
+  wt rm --others --delete-branch     # Keep only this worktree and main, and delete the branches`,
+	Args: cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if others, _ := cmd.Flags().GetBool("others"); others {
+			if len(args) > 0 {
+				return fmt.Errorf("--others removes every other worktree; do not pass a branch")
+			}
+			return removeOtherWorktrees(cmd)
+		}
+		args = branchArgs(args)
 		var branch string
 
 		// Interactive selection if no branch provided
@@ -580,59 +1476,59 @@ var removeCmd = &cobra.Command{
 				return fmt.Errorf("no worktrees to remove")
 			}
 
-			prompt := promptui.Select{
-				Label: "Select worktree to remove",
-				Items: branches,
-			}
-			_, result, err := prompt.Run()
+			idx, err := promptFor(cmd).Select("Select worktree to remove", branches, SelectOptions{})
 			if err != nil {
-				return fmt.Errorf("selection cancelled")
+				return err
 			}
-			branch = result
+			branch = branches[idx]
 		} else {
 			branch = args[0]
 		}
-
-		existingPath, exists := worktreeExists(branch)
-		if !exists {
-			return fmt.Errorf("no worktree found for branch: %s", branch)
-		}
-
-		// Check if we're currently in the worktree being removed
-		cwd, err := os.Getwd()
-		inRemovedWorktree := err == nil && strings.HasPrefix(cwd, existingPath)
-
-		// Find the main worktree path (for cd after removal)
-		var mainWorktreePath string
-		if inRemovedWorktree {
-			listCmd := exec.Command("git", "worktree", "list")
-			output, err := listCmd.Output()
-			if err == nil {
-				lines := strings.Split(string(output), "\n")
-				if len(lines) > 0 {
-					// First line is always the main worktree
-					fields := strings.Fields(lines[0])
-					if len(fields) > 0 {
-						mainWorktreePath = fields[0]
-					}
-				}
+		if branch == "." {
+			worktrees, err := listWorktrees("")
+			if err != nil {
+				return err
 			}
+			wt, err := selectWorktree(worktrees, nil)
+			if err != nil {
+				return err
+			}
+			if wt.Branch == "" {
+				return fmt.Errorf("%s has no branch; remove it with 'git worktree remove'", wt.Path)
+			}
+			branch = wt.Branch
 		}
 
-		gitCmd := exec.Command("git", "worktree", "remove", existingPath)
-		gitCmd.Stdout = os.Stdout
-		gitCmd.Stderr = os.Stderr
-		if err := gitCmd.Run(); err != nil {
-			return fmt.Errorf("failed to remove worktree: %w", err)
+		plan, err := planRemoval(cmd, branch, time.Now())
+		if err != nil {
+			return err
+		}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				return writeRemovalPlanJSON(plan)
+			}
+			printRemovalPlan(os.Stdout, plan)
+			return nil
+		}
+		if err := plan.check(); err != nil {
+			return err
+		}
+		if err := confirmAction(cmd, "Remove worktree "+branch, plan.lines()...); err != nil {
+			return err
+		}
+		if err := plan.confirmProcesses(cmd); err != nil {
+			return err
 		}
 
-		fmt.Printf("✓ Removed worktree: %s\n", existingPath)
+		if err := carryOutRemoval(cmd, plan); err != nil {
+			return err
+		}
 
 		// If we were in the removed worktree, navigate to main
-		if inRemovedWorktree && mainWorktreePath != "" {
-			printCDMarker(mainWorktreePath)
+		if plan.CdTo != "" {
+			printCDMarker(plan.CdTo)
 		}
-
+		showHint(cmd.Name(), branch)
 		return nil
 	},
 }
@@ -640,13 +1536,38 @@ var removeCmd = &cobra.Command{
 var pruneCmd = &cobra.Command{
 	Use:   "prune",
 	Short: "Remove worktree administrative files",
-	Run: func(cmd *cobra.Command, args []string) {
-		gitCmd := exec.Command("git", "worktree", "prune")
-		gitCmd.Stdout = os.Stdout
-		gitCmd.Stderr = os.Stderr
-		if err := gitCmd.Run(); err == nil {
-			fmt.Println("✓ Pruned stale worktree administrative files")
+	Long: `Remove the administrative files of worktrees whose directory is gone,
+like 'git worktree prune'. Pinned worktrees (see 'wt pin') are kept.
+
+Worktrees on a volume that is not mounted (e.g. an unplugged external drive)
+are not gone: they are shown as offline, locked so that plain 'git worktree
+prune' keeps them too, and unlocked once the volume is back. Pass
+--include-offline to drop them anyway.
+
+--dry-run shows what would be pruned and kept, and why, with an estimate of
+the space reclaimed; add --json for the cleanup plan as JSON. A plan saved
+that way can be applied later with 'wt cleanup --plan-file plan.json --apply'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		includeOffline, _ := cmd.Flags().GetBool("include-offline")
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			plan, err := planPrune(includeOffline)
+			if err != nil {
+				return err
+			}
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				return writeJSON(os.Stdout, plan)
+			}
+			printCleanupPlan(os.Stdout, plan)
+			return nil
 		}
+		skipped, offlineSkipped, err := pruneWorktrees(includeOffline)
+		if err != nil {
+			return err
+		}
+		infoln("✓ Pruned stale worktree administrative files")
+		printPinnedSkipped(skipped)
+		printOfflineSkipped(offlineSkipped)
+		return nil
 	},
 }
 
@@ -658,33 +1579,110 @@ var shellenvCmd = &cobra.Command{
 Add this to the END of your ~/.bashrc or ~/.zshrc:
   source <(wt shellenv)
 
+This also applies to Git Bash, MSYS2 and Cygwin on Windows. Should wt not
+recognize the shell there, use: source <(wt shellenv --shell bash)
+
 For PowerShell, add this to your $PROFILE:
   Invoke-Expression (& wt shellenv)
 
+For cmd.exe, save the output of 'wt shellenv --shell cmd' as a .cmd file and
+run it from your AutoRun script. It defines a doskey macro calling a wrapper
+script that wt writes next to its state files; batch files call the wrapper
+directly, as the macro only applies to typed commands.
+
 Note: For zsh, place this AFTER compinit to enable tab completion.
 
 This enables:
-- Automatic cd to worktree after checkout/create/pr/mr commands
-- Tab completion for commands and branch names`,
-	Run: func(cmd *cobra.Command, args []string) {
-		// Output OS-specific shell integration
+- Automatic cd to worktree after checkout/create/pr/mr/switch commands
+- Tab completion for commands and branch names
+
+'wt shellenv --install' appends the source line to ~/.bashrc or ~/.zshrc
+(for the shell in $SHELL, or --shell) unless it is already there.
+
+Where shell functions are not allowed in rc files, use
+  source <(wt shellenv --minimal)
+which only registers completion. Commands then print a 'cd <path>' line to
+copy; set hideCdHint: true in the config to turn it off.
+
+If auto-cd does not work, run the self-test, which sets up the integration,
+checks that the shell changes directory on a marker from wt, and prints PASS
+or FAIL with what it found:
+  source <(wt shellenv --verify)
+  (& wt shellenv --verify) | Out-String | Invoke-Expression    # PowerShell`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		binary := "wt"
+		if invokedAsGitSubcommand(os.Args[0]) {
+			binary = "git-wt"
+		}
+		shell, _ := cmd.Flags().GetString("shell")
+		minimal, _ := cmd.Flags().GetBool("minimal")
+		verify, _ := cmd.Flags().GetBool("verify")
+		if verify && minimal {
+			return fmt.Errorf("--minimal defines no wrapper to verify")
+		}
+		if install, _ := cmd.Flags().GetBool("install"); install {
+			return runShellenvInstall(shell)
+		}
+
 		// On Windows, default to PowerShell. On Unix, output bash/zsh.
-		if runtime.GOOS == "windows" {
-			// PowerShell integration for Windows
-			fmt.Print(`# PowerShell integration (Windows)
+		// Git Bash/MSYS2/Cygwin on Windows get the bash integration.
+		powershell := runtime.GOOS == "windows" && !isMSYSShell()
+		switch shell {
+		case "":
+		case shellBash, shellZsh:
+			powershell = false
+		case shellPowerShell, shellPwsh:
+			powershell = true
+		case shellCmd:
+			if verify {
+				return fmt.Errorf("--verify supports bash, zsh and PowerShell; for cmd.exe, run 'wt __emit-test-marker' through the doskey macro and check that the directory changed")
+			}
+			if minimal {
+				return fmt.Errorf("cmd.exe has no completion to register; --minimal does not apply to --shell cmd")
+			}
+			shim, err := writeCmdShim()
+			if err != nil {
+				return err
+			}
+			fmt.Print(cmdIntegration(shim))
+			return nil
+		default:
+			return fmt.Errorf("unsupported shell %q (expected bash, zsh, powershell or cmd)", shell)
+		}
+
+		if minimal {
+			fmt.Print(minimalIntegration(powershell, binary))
+			return nil
+		}
+		if verify {
+			fmt.Print(verifyIntegration(powershell, binary))
+			return nil
+		}
+		fmt.Print(shellIntegration(powershell, binary))
+		return nil
+	},
+}
+
+// powershellIntegration is the shellenv output for PowerShell.
+const powershellIntegration = `# PowerShell integration (Windows)
 # Detected via runtime.GOOS, compatible with $PSVersionTable
 # NOTE: Requires wt.exe to be in PATH or current directory
 
+# Tells wt that the function below follows it into new worktrees
+$env:WT_SHELL_INTEGRATION = '1'
+
 function wt {
     # Call wt.exe explicitly to avoid recursive function call
     # PowerShell will find wt.exe in PATH or current directory
-    $output = & wt.exe @args
+    # Tee-Object passes stdout (only data and the TREE_ME_CD marker; wt's
+    # messages go to stderr) through live while keeping a copy to find the
+    # marker in
+    & wt.exe @args | Tee-Object -Variable output
     $exitCode = $LASTEXITCODE
-    Write-Output $output
     if ($exitCode -eq 0) {
-        $cdPath = $output | Select-String -Pattern "^TREE_ME_CD:" | ForEach-Object { $_.Line.Substring(11) }
+        $cdPath = $output | Select-String -Pattern "^TREE_ME_CD:" | ForEach-Object { $_.Line.Substring(11) } | Select-Object -Last 1
         if ($cdPath) {
-            Set-Location $cdPath
+            Set-Location -LiteralPath $cdPath
         }
     }
     $global:LASTEXITCODE = $exitCode
@@ -694,7 +1692,7 @@ function wt {
 Register-ArgumentCompleter -CommandName wt -ScriptBlock {
     param($commandName, $wordToComplete, $commandAst, $fakeBoundParameters)
 
-    $commands = @('checkout', 'co', 'create', 'pr', 'mr', 'list', 'ls', 'remove', 'rm', 'prune', 'help', 'shellenv')
+    $commands = @('checkout', 'co', 'create', 'pr', 'mr', 'list', 'ls', 'remove', 'rm', 'prune', 'recent', 'clone', 'init', 'move', 'demo', 'info', 'adopt', 'repair', 'open', 'pin', 'unpin', 'park', 'unpark', 'env', 'doctor', 'status', 'bisect', 'stats', 'config', 'cat', 'debug', 'publish', 'exec', 'cleanup', 'switch', 'sw', 'undo', 'path', 'help', 'shellenv')
 
     # Get the position in the command line
     $position = $commandAst.CommandElements.Count - 1
@@ -706,45 +1704,50 @@ Register-ArgumentCompleter -CommandName wt -ScriptBlock {
         }
     } elseif ($position -eq 1) {
         $subCommand = $commandAst.CommandElements[1].Value
-        if ($subCommand -in @('checkout', 'co', 'remove', 'rm')) {
-            # Complete branch names from worktree list
-            $branches = git worktree list 2>$null | Select-Object -Skip 1 | ForEach-Object {
-                if ($_ -match '\[([^\]]+)\]') { $matches[1] }
-            }
-            $branches | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
-                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+        if ($subCommand -in @('__WT_COMPLETED_COMMANDS__')) {
+            # Complete branch arguments through the command's completion;
+            # lines are "branch<TAB>description", the last one a directive
+            & wt.exe __complete $subCommand $wordToComplete 2>$null | Where-Object { $_ -notlike ':*' } | ForEach-Object {
+                $name, $description = $_ -split "\t", 2
+                if (-not $description) { $description = $name }
+                if ($name -like "$wordToComplete*") {
+                    [System.Management.Automation.CompletionResult]::new($name, $name, 'ParameterValue', $description)
+                }
             }
         }
     }
 }
-`)
-			return
-		}
-
-		// Bash/Zsh integration for Unix systems
-		fmt.Print(`wt() {
-    # Use script(1) to provide a PTY for interactive commands (e.g., promptui menus)
-    # Command substitution $(command wt) doesn't allocate a TTY, which breaks interactive prompts
-    local log_file exit_code cd_path
+`
+
+// posixIntegration is the shellenv output for bash and zsh.
+const posixIntegration = `wt() {
+    # Tee stdout into a temp file to pick up the TREE_ME_CD marker. wt keeps
+    # stdout for data and the marker; its messages, git's output and menus go
+    # to stderr, which stays attached to the terminal together with stdin.
+    # stdout is passed through live, so output keeps its order. A pipeline is used
+    # rather than >(tee ...) because the shell waits for every stage of a
+    # pipeline: the marker is guaranteed to be in the file when wt returns.
+    local log_file exit_code cd_path path_style
     log_file=$(mktemp -t wt.XXXXXX)
 
-    # Detect OS to use correct script syntax (macOS vs Linux)
-    if [ "$(uname)" = "Darwin" ]; then
-        # macOS: script -q file command args
-        script -q "$log_file" /bin/sh -c 'command wt "$@"' wt "$@"
-    else
-        # Linux: script -q -c "command wt $*" "$log_file"
-        script -q -c "command wt $*" "$log_file"
-    fi
-    exit_code=$?
+    # Git Bash/MSYS2 and Cygwin cannot cd to C:\... paths; ask wt for a
+    # POSIX-style marker instead
+    case "$OSTYPE" in
+        msys*) path_style=msys ;;
+        cygwin*) path_style=cygwin ;;
+    esac
+
+    WT_SHELL_INTEGRATION=1 WT_PATH_STYLE=$path_style command wt "$@" | tee "$log_file"
+    # wt's own exit code: PIPESTATUS in bash, pipestatus in zsh
+    exit_code=${PIPESTATUS[0]:-${pipestatus[1]}}
 
     # Extract the TREE_ME_CD marker for auto-cd
     cd_path=$(grep '^TREE_ME_CD:' "$log_file" | tail -1 | cut -d: -f2-)
     rm -f "$log_file"
     cd_path=${cd_path%$'\r'}
 
-    if [ $exit_code -eq 0 ] && [ -n "$cd_path" ]; then
-        cd "$cd_path"
+    if [ "$exit_code" -eq 0 ] && [ -n "$cd_path" ]; then
+        cd -- "$cd_path"
     fi
     return $exit_code
 }
@@ -756,7 +1759,7 @@ if [ -n "$BASH_VERSION" ]; then
         COMPREPLY=()
         cur="${COMP_WORDS[COMP_CWORD]}"
         prev="${COMP_WORDS[COMP_CWORD-1]}"
-        commands="checkout co create pr mr list ls remove rm prune help shellenv"
+        commands="checkout co create pr mr list ls remove rm prune recent clone init move demo info adopt repair open pin unpin park unpark env doctor status bisect stats config cat debug publish exec cleanup switch sw undo path help shellenv"
 
         # Complete commands if first argument
         if [ $COMP_CWORD -eq 1 ]; then
@@ -764,15 +1767,35 @@ if [ -n "$BASH_VERSION" ]; then
             return 0
         fi
 
-        # Complete branch names for checkout/remove/rm
+        # Complete branch arguments through the commands' completion
         case "$prev" in
-            checkout|co|remove|rm)
+            --base)
+                local refs
+                refs=$(git for-each-ref --format='%(refname:short)' refs/heads refs/remotes 2>/dev/null)
+                COMPREPLY=( $(compgen -W "$refs" -- "$cur") )
+                return 0
+                ;;
+            --repo)
+                local repos
+                repos=$(command wt __complete recent --repo "$cur" 2>/dev/null | grep -v '^:')
+                COMPREPLY=( $(compgen -W "$repos" -- "$cur") )
+                return 0
+                ;;
+            __WT_COMPLETED_COMMANDS__)
                 local branches
-                branches=$(git worktree list 2>/dev/null | awk 'NR>1 {match($0, /\[([^]]+)\]/, arr); if (arr[1]) print arr[1]}')
+                branches=$(command wt __complete "$prev" "$cur" 2>/dev/null | grep -v '^:' | cut -f1)
                 COMPREPLY=( $(compgen -W "$branches" -- "$cur") )
                 return 0
                 ;;
         esac
+
+        # Complete config subcommands, keys and values
+        if [ "${COMP_WORDS[1]}" = config ]; then
+            local keys
+            keys=$(command wt __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" "$cur" 2>/dev/null | grep -v '^:' | cut -f1)
+            COMPREPLY=( $(compgen -W "$keys" -- "$cur") )
+            return 0
+        fi
     }
     complete -F _wt_complete wt
 fi
@@ -792,16 +1815,56 @@ if [ -n "$ZSH_VERSION" ]; then
             'remove:Remove a worktree'
             'rm:Remove a worktree'
             'prune:Remove worktree administrative files'
+            'recent:List recently visited worktrees'
+            'clone:Clone a repository into the worktree layout'
+            'init:Prepare the current repository for the configured layout'
+            'move:Move worktrees to their location in the configured layout'
+            'demo:Create a throwaway sandbox repository to try wt'
+            'info:Show what wt knows about a worktree'
+            'adopt:Mark a branch as created by wt'
+            'repair:Fix worktree links after moving worktrees by hand'
+            'open:Open the PR/MR or branch page of the current worktree'
+            'pin:Protect a worktree from bulk cleanup'
+            'unpin:Let bulk cleanup consider a worktree again'
+            'park:Detach a worktree to free its branch'
+            'unpark:Re-attach a parked branch'
+            'env:Print the environment selecting the current worktree'
+            'doctor:Check the setup of wt and the current repository'
+            'status:Show the status of every worktree'
+            'bisect:Bisect in a dedicated worktree'
+            'stats:Show disk usage and object sharing of the worktrees'
+            'config:Read, change, edit and validate the config file'
+            'cat:Print a file as it is in another worktree'
+            'debug:Show information for bug reports'
+            'publish:Push a branch and open a draft PR/MR for it'
+            'exec:Run a command in several worktrees'
+            'cleanup:Apply a saved cleanup plan'
+            'switch:Switch to an existing worktree'
+            'sw:Switch to an existing worktree'
+            'undo:Undo the last removal of a worktree'
+            'path:Print the path of a worktree'
             'help:Show help'
             'shellenv:Output shell function for auto-cd'
         )
 
-        if (( CURRENT == 2 )); then
+        if [[ "$words[CURRENT-1]" == --base ]]; then
+            branches=(${(f)"$(git for-each-ref --format='%(refname:short)' refs/heads refs/remotes 2>/dev/null)"})
+            _describe 'branch' branches
+        elif [[ "$words[CURRENT-1]" == --repo ]]; then
+            local -a repos
+            repos=(${(f)"$(command wt __complete recent --repo "$words[CURRENT]" 2>/dev/null | grep -v '^:')"})
+            _describe 'repository' repos
+        elif (( CURRENT == 2 )); then
             _describe 'command' commands
+        elif [[ "$words[2]" == config ]]; then
+            local -a keys
+            keys=(${(f)"$(command wt __complete "${(@)words[2,CURRENT-1]}" "$words[CURRENT]" 2>/dev/null | grep -v '^:' | tr '\t' ':')"})
+            _describe 'config' keys
         elif (( CURRENT == 3 )); then
             case "$words[2]" in
-                checkout|co|remove|rm)
-                    branches=(${(f)"$(git worktree list 2>/dev/null | awk 'NR>1 {match($0, /\[([^]]+)\]/, arr); if (arr[1]) print arr[1]}')"})
+                __WT_COMPLETED_COMMANDS__)
+                    # "branch<TAB>description" becomes "branch:description"
+                    branches=(${(f)"$(command wt __complete "$words[2]" "$words[CURRENT]" 2>/dev/null | grep -v '^:' | tr '\t' ':')"})
                     _describe 'branch' branches
                     ;;
             esac
@@ -812,9 +1875,7 @@ if [ -n "$ZSH_VERSION" ]; then
         compdef _wt_complete_zsh wt
     fi
 fi
-`)
-	},
-}
+`
 
 var versionCmd = &cobra.Command{
 	Use:   "version",
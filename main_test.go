@@ -2,11 +2,27 @@ package main
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
 
+// TestMain isolates the tests (and the wt binaries spawned by e2e tests) from
+// the developer's own config and state files.
+func TestMain(m *testing.M) {
+	stateDir, err := os.MkdirTemp("", "wt-test-state")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("WT_CONFIG", filepath.Join(stateDir, "config.yaml"))
+	os.Setenv("WT_STATE_DIR", stateDir)
+	code := m.Run()
+	os.RemoveAll(stateDir)
+	os.Exit(code)
+}
+
 func TestGetPRNumber(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -32,6 +48,66 @@ func TestGetPRNumber(t *testing.T) {
 			want:    "789",
 			wantErr: false,
 		},
+		{
+			name:  "GitHub PR files tab with a diff anchor",
+			input: "https://github.com/owner/repo/pull/123/files#diff-abc",
+			want:  "123",
+		},
+		{
+			name:  "GitHub PR commit",
+			input: "https://github.com/owner/repo/pull/123/commits/deadbeef",
+			want:  "123",
+		},
+		{
+			name:  "GitHub PR with query and comment anchor",
+			input: "https://www.github.com/owner/repo/pull/123?notification_referrer_id=x#issuecomment-42",
+			want:  "123",
+		},
+		{
+			name:  "GitLab MR diffs of a commit",
+			input: "https://gitlab.com/owner/repo/-/merge_requests/45/diffs?commit_id=deadbeef",
+			want:  "45",
+		},
+		{
+			name:  "GitLab MR in a subgroup",
+			input: "https://gitlab.com/group/sub/repo/-/merge_requests/7",
+			want:  "7",
+		},
+		{
+			name:  "GitLab MR note anchor",
+			input: "https://gitlab.com/group/sub/repo/-/merge_requests/7#note_99",
+			want:  "7",
+		},
+		{
+			name:    "GitHub issue",
+			input:   "https://github.com/owner/repo/issues/123",
+			wantErr: true,
+		},
+		{
+			name:    "GitHub discussion",
+			input:   "https://github.com/owner/repo/discussions/123",
+			wantErr: true,
+		},
+		{
+			name:    "GitHub issue linking a PR in its query",
+			input:   "https://github.com/owner/repo/issues/5?from=/owner/repo/pull/3",
+			wantErr: true,
+		},
+		{
+			name:    "GitHub PR number followed by letters",
+			input:   "https://github.com/owner/repo/pull/123abc",
+			wantErr: true,
+		},
+		{
+			name:    "GitLab issue",
+			input:   "https://gitlab.com/group/repo/-/issues/7",
+			wantErr: true,
+		},
+		{
+			name:    "GitLab MR list",
+			input:   "https://gitlab.com/group/repo/-/merge_requests?scope=all",
+			wantErr: true,
+		},
 		{
 			name:    "Invalid input",
 			input:   "not-a-number",
@@ -287,11 +363,23 @@ func TestParsePROutput(t *testing.T) {
 			wantNumbers: []string{"123", "456"},
 			wantLabels:  []string{"#123: First PR", "#456: Second PR"},
 		},
+		{
+			name:        "CRLF line endings",
+			output:      "123\tFirst PR\r\n456\tSecond PR\r\n",
+			wantNumbers: []string{"123", "456"},
+			wantLabels:  []string{"#123: First PR", "#456: Second PR"},
+		},
+		{
+			name:        "Unicode title",
+			output:      "123\tRésumé naïve café 🚀\n456\t修复登录",
+			wantNumbers: []string{"123", "456"},
+			wantLabels:  []string{"#123: Résumé naïve café 🚀", "#456: 修复登录"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotNumbers, gotLabels := parsePROutput(tt.output)
+			gotNumbers, gotLabels := reviewChoices(parsePROutput(tt.output), RemoteGitHub)
 
 			if len(gotNumbers) != len(tt.wantNumbers) {
 				t.Errorf("parsePROutput() gotNumbers length = %v, want %v", len(gotNumbers), len(tt.wantNumbers))
@@ -488,12 +576,7 @@ func TestExtractRepoNameFromURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Extract base name from URL (mimics filepath.Base logic)
-			base := filepath.Base(tt.url)
-			// Remove trailing slash if present
-			base = strings.TrimSuffix(base, "/")
-			// Remove .git suffix
-			got := strings.TrimSuffix(base, ".git")
+			got := repoNameFromURL(tt.url, "")
 
 			if got != tt.want {
 				t.Errorf("extractRepoName(%q) = %q, want %q", tt.url, got, tt.want)
@@ -587,11 +670,23 @@ invalid line without proper format
 			wantNumbers: []string{"123"},
 			wantLabels:  []string{"!123: Fix bug"},
 		},
+		{
+			name:        "CRLF line endings",
+			output:      "!123  OPEN  First MR  (branch1) ← (main)\r\n!456  OPEN  Second MR  (branch2) ← (main)\r\n",
+			wantNumbers: []string{"123", "456"},
+			wantLabels:  []string{"!123: First MR", "!456: Second MR"},
+		},
+		{
+			name:        "Unicode title",
+			output:      "!123  OPEN  Résumé naïve café 🚀  (résumé) ← (main)\n!456  OPEN  修复登录  (fix) ← (main)",
+			wantNumbers: []string{"123", "456"},
+			wantLabels:  []string{"!123: Résumé naïve café 🚀", "!456: 修复登录"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotNumbers, gotLabels := parseMROutput(tt.output)
+			gotNumbers, gotLabels := reviewChoices(parseMROutput(tt.output), RemoteGitLab)
 
 			if len(gotNumbers) != len(tt.wantNumbers) {
 				t.Errorf("parseMROutput() gotNumbers length = %v, want %v", len(gotNumbers), len(tt.wantNumbers))
@@ -673,3 +768,137 @@ func TestEnsureWorktreePathFailsWhenRootIsFile(t *testing.T) {
 		t.Fatal("expected ensureWorktreePath() to fail when WORKTREE_ROOT is a file")
 	}
 }
+
+func TestChooseBase(t *testing.T) {
+	tests := []struct {
+		name           string
+		flagBase       string
+		positional     []string
+		want           string
+		wantDeprecated bool
+		wantErr        bool
+	}{
+		{name: "No base", want: ""},
+		{name: "Flag only", flagBase: "develop", want: "develop"},
+		{name: "Positional only", positional: []string{"develop"}, want: "develop", wantDeprecated: true},
+		{name: "Flag and matching positional", flagBase: "develop", positional: []string{"develop"}, want: "develop", wantDeprecated: true},
+		{name: "Flag and conflicting positional", flagBase: "develop", positional: []string{"typo-in-readme"}, wantDeprecated: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, deprecated, err := chooseBase(tt.flagBase, tt.positional)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("chooseBase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want || deprecated != tt.wantDeprecated {
+				t.Errorf("chooseBase() = %q, %v; want %q, %v", got, deprecated, tt.want, tt.wantDeprecated)
+			}
+		})
+	}
+}
+
+func TestCommitExists(t *testing.T) {
+	if !commitExists("HEAD") {
+		t.Error("commitExists(HEAD) = false, want true")
+	}
+	if commitExists("this-branch-definitely-does-not-exist-24680") {
+		t.Error("commitExists() = true for a missing branch")
+	}
+}
+
+func TestRootLongHelp(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "custom-root")
+	t.Setenv("WORKTREE_ROOT", root)
+	if !strings.Contains(rootLongHelp(), root+"/<repo>/<branch>") {
+		t.Errorf("rootLongHelp() does not mention the current root:\n%s", rootLongHelp())
+	}
+}
+
+func TestResolveHeadBase(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	for _, base := range []string{"HEAD", "@", "HEAD~2", "HEAD^", "@~1"} {
+		if !headRelativeRegex.MatchString(base) {
+			t.Errorf("%q should be HEAD-relative", base)
+		}
+	}
+	for _, base := range []string{"main", "HEADS", "origin/HEAD", "@{-1}"} {
+		if headRelativeRegex.MatchString(base) {
+			t.Errorf("%q should not be HEAD-relative", base)
+		}
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "commit", "--allow-empty", "-m", "second")
+
+	// A detached worktree one commit behind main: HEAD must mean its commit,
+	// not the main clone's.
+	reviewDir := filepath.Join(tmpDir, "review")
+	runGitCommand(t, repoDir, "worktree", "add", "--detach", reviewDir, "HEAD~1")
+	t.Chdir(reviewDir)
+
+	want, err := exec.Command("git", "-C", repoDir, "rev-parse", "HEAD~1").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, base := range []string{"HEAD", "@"} {
+		got, err := resolveHeadBase(base)
+		if err != nil {
+			t.Fatalf("resolveHeadBase(%q) error = %v", base, err)
+		}
+		if got != strings.TrimSpace(string(want)) {
+			t.Errorf("resolveHeadBase(%q) = %q, want %q", base, got, want)
+		}
+	}
+
+	_, err = resolveHeadBase("HEAD~2")
+	if err == nil {
+		t.Fatal("resolveHeadBase(HEAD~2) should fail with a single commit behind HEAD")
+	}
+	for _, part := range []string{"bad revision 'HEAD~2'", "worktree " + resolvePath(reviewDir)} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("error %q does not mention %q", err, part)
+		}
+	}
+}
+
+func TestExpandWorktreeRoot(t *testing.T) {
+	home := filepath.Join(string(filepath.Separator)+"home", "me")
+	abs := filepath.Join(string(filepath.Separator)+"srv", "my trees")
+	if runtime.GOOS == "windows" {
+		home, abs = `C:\Users\me`, `D:\my trees`
+	}
+	tests := []struct {
+		root     string
+		want     string
+		relative bool
+	}{
+		{"", filepath.Join(home, "dev", "worktrees"), false},
+		{"~", home, false},
+		{"~/my trees", filepath.Join(home, "my trees"), false},
+		{"~/my trees/", filepath.Join(home, "my trees"), false},
+		{abs + string(filepath.Separator) + "x" + string(filepath.Separator) + "..", abs, false},
+		{"./trees", filepath.Join(home, "trees"), true},
+		{"trees", filepath.Join(home, "trees"), true},
+		{"../shared trees", filepath.Join(filepath.Dir(home), "shared trees"), true},
+	}
+	for _, tt := range tests {
+		got, relative := expandWorktreeRoot(tt.root, home)
+		if got != tt.want || relative != tt.relative {
+			t.Errorf("expandWorktreeRoot(%q) = %q, %v; want %q, %v", tt.root, got, relative, tt.want, tt.relative)
+		}
+	}
+}
+
+func TestResolveWorktreeRootIsClean(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("WORKTREE_ROOT", root+"/nested/../")
+	if got := resolveWorktreeRoot(nil); got != filepath.Clean(root) {
+		t.Errorf("resolveWorktreeRoot() = %q, want %q", got, filepath.Clean(root))
+	}
+}
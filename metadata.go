@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Worktree timestamps are kept in the repository's git config, keyed by the
+// worktree path:
+//
+//	[wt-worktree "/home/me/dev/worktrees/api/feature-x"]
+//		createdAt = 2026-01-02T15:04:05Z
+//		lastSwitchedAt = 2026-01-09T08:00:00Z
+const worktreeMetaSection = "wt-worktree"
+
+// worktreeTimes holds the timestamps wt records for a worktree.
+type worktreeTimes struct {
+	CreatedAt      time.Time
+	LastSwitchedAt time.Time
+}
+
+func worktreeMetaKey(path, name string) string {
+	return worktreeMetaSection + "." + filepath.Clean(path) + "." + name
+}
+
+// parseWorktreeTimes parses `git config -z --get-regexp ^wt-worktree\.` into
+// timestamps per worktree path. Git lowercases the variable names.
+func parseWorktreeTimes(output string) map[string]worktreeTimes {
+	times := make(map[string]worktreeTimes)
+	for _, entry := range strings.Split(output, "\x00") {
+		key, value, ok := strings.Cut(entry, "\n")
+		if !ok {
+			continue
+		}
+		rest, ok := strings.CutPrefix(key, worktreeMetaSection+".")
+		if !ok {
+			continue
+		}
+		dot := strings.LastIndex(rest, ".")
+		if dot < 0 {
+			continue
+		}
+		path, name := rest[:dot], rest[dot+1:]
+		ts, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		t := times[path]
+		switch name {
+		case "createdat":
+			t.CreatedAt = ts
+		case "lastswitchedat":
+			t.LastSwitchedAt = ts
+		default:
+			continue
+		}
+		times[path] = t
+	}
+	return times
+}
+
+// loadWorktreeTimes reads the timestamps of all worktrees of the repository
+// containing dir (the current directory when dir is empty).
+func loadWorktreeTimes(dir string) map[string]worktreeTimes {
+	output, err := gitIn(dir, "config", "-z", "--get-regexp", `^`+worktreeMetaSection+`\.`).Output()
+	if err != nil {
+		return map[string]worktreeTimes{}
+	}
+	return parseWorktreeTimes(string(output))
+}
+
+// backfillCreatedAt fills in a missing creation time from the modification
+// time of the worktree directory, for worktrees created before wt recorded
+// timestamps. It reports whether anything changed.
+func backfillCreatedAt(t *worktreeTimes, path string) bool {
+	if !t.CreatedAt.IsZero() {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	t.CreatedAt = info.ModTime().UTC().Truncate(time.Second)
+	return true
+}
+
+func setWorktreeTime(path, name string, ts time.Time) {
+	_ = gitIn(path, "config", worktreeMetaKey(path, name), ts.UTC().Format(time.RFC3339)).Run()
+}
+
+// touchWorktree records that the user switched to the worktree at path,
+// backfilling its creation time on first touch.
+func touchWorktree(path string) {
+	times := loadWorktreeTimes(path)[filepath.Clean(path)]
+	if backfillCreatedAt(&times, path) {
+		setWorktreeTime(path, "createdAt", times.CreatedAt)
+	}
+	setWorktreeTime(path, "lastSwitchedAt", time.Now())
+}
+
+// forgetWorktree drops the recorded timestamps of a removed worktree.
+func forgetWorktree(dir, path string) {
+	_ = gitIn(dir, "config", "--remove-section", worktreeMetaSection+"."+filepath.Clean(path)).Run()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseWorktreeTimes(t *testing.T) {
+	output := "wt-worktree./trees/api/feature x.createdat\n2026-01-02T15:04:05Z\x00" +
+		"wt-worktree./trees/api/feature x.lastswitchedat\n2026-01-09T08:00:00Z\x00" +
+		"wt-worktree./trees/api/v1.2.createdat\n2026-02-01T00:00:00Z\x00" +
+		"wt-worktree./trees/api/bad.createdat\nnot a time\x00" +
+		"wt-worktree./trees/api/other.unknown\n2026-02-01T00:00:00Z\x00"
+
+	got := parseWorktreeTimes(output)
+	feature := got["/trees/api/feature x"]
+	if feature.CreatedAt != time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC) {
+		t.Errorf("createdAt = %v", feature.CreatedAt)
+	}
+	if feature.LastSwitchedAt != time.Date(2026, 1, 9, 8, 0, 0, 0, time.UTC) {
+		t.Errorf("lastSwitchedAt = %v", feature.LastSwitchedAt)
+	}
+	if _, ok := got["/trees/api/v1.2"]; !ok {
+		t.Errorf("paths containing dots should be parsed, got %v", got)
+	}
+	if len(got) != 2 {
+		t.Errorf("parseWorktreeTimes() = %v, want only valid entries", got)
+	}
+}
+
+func TestBackfillCreatedAt(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(dir, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	var times worktreeTimes
+	if !backfillCreatedAt(&times, dir) {
+		t.Fatal("backfillCreatedAt() should fill a missing creation time")
+	}
+	if !times.CreatedAt.Equal(mtime) {
+		t.Errorf("CreatedAt = %v, want directory mtime %v", times.CreatedAt, mtime)
+	}
+
+	recorded := worktreeTimes{CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if backfillCreatedAt(&recorded, dir) {
+		t.Error("backfillCreatedAt() should keep a recorded creation time")
+	}
+	if backfillCreatedAt(&times, filepath.Join(dir, "missing")) {
+		t.Error("backfillCreatedAt() should not change already filled times")
+	}
+	var missing worktreeTimes
+	if backfillCreatedAt(&missing, filepath.Join(dir, "missing")) {
+		t.Error("backfillCreatedAt() should skip worktrees whose directory is gone")
+	}
+}
+
+func TestTouchWorktree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+
+	touchWorktree(repoDir)
+	times := loadWorktreeTimes(repoDir)[filepath.Clean(repoDir)]
+	if times.CreatedAt.IsZero() || times.LastSwitchedAt.IsZero() {
+		t.Fatalf("touchWorktree() recorded %+v", times)
+	}
+
+	forgetWorktree(repoDir, repoDir)
+	if _, ok := loadWorktreeTimes(repoDir)[filepath.Clean(repoDir)]; ok {
+		t.Error("forgetWorktree() kept the timestamps")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// posixMinimalIntegration is the `shellenv --minimal` output for bash and
+// zsh: completion only, without defining any shell function. bash runs the
+// completer as a command (complete -C); zsh gets bash's complete through
+// bashcompinit, which needs compinit to be loaded.
+const posixMinimalIntegration = `# wt shell integration, minimal mode: completion only, no shell functions.
+# Without the wrapper your shell cannot follow wt into new worktrees;
+# wt prints a 'cd <path>' line to copy instead.
+if [ -n "$ZSH_VERSION" ]; then
+    if whence compdef >/dev/null 2>&1; then
+        autoload -U +X bashcompinit && bashcompinit
+        complete -C 'command wt __complete-words' wt
+    fi
+else
+    complete -C 'command wt __complete-words' wt
+fi
+`
+
+// minimalIntegration returns the `shellenv --minimal` output. PowerShell
+// gets the argument completer of the full integration without the function.
+func minimalIntegration(powershell bool, binary string) string {
+	if powershell {
+		_, completion, _ := strings.Cut(shellIntegration(true, "wt"), "# PowerShell completion\n")
+		return "# PowerShell completion\n" + completion
+	}
+	script := posixMinimalIntegration
+	if binary != "wt" {
+		script = strings.ReplaceAll(script, "command wt __complete-words' wt", "command "+binary+" __complete-words' "+binary)
+	}
+	return script
+}
+
+// completionWords splits the part of line before the cursor into the words
+// after the command name. A trailing empty word means a new word is started.
+func completionWords(line string, point int) []string {
+	if point >= 0 && point < len(line) {
+		line = line[:point]
+	}
+	words := strings.Fields(line)
+	if line == "" || strings.TrimRight(line, " \t") != line {
+		words = append(words, "")
+	}
+	if len(words) > 0 {
+		words = words[1:]
+	}
+	return words
+}
+
+// parseCompletions returns the candidates of cobra's `__complete` output
+// that start with prefix, without their descriptions and the directive line.
+func parseCompletions(r io.Reader, prefix string) []string {
+	var candidates []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+		candidate, _, _ := strings.Cut(line, "\t")
+		if strings.HasPrefix(candidate, prefix) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// completeWordsCmd is the command bash (and zsh's bashcompinit) runs for
+// `complete -C`: it reads the command line from COMP_LINE/COMP_POINT and
+// prints one candidate per line.
+var completeWordsCmd = &cobra.Command{
+	Use:                "__complete-words",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		point, err := strconv.Atoi(os.Getenv("COMP_POINT"))
+		if err != nil {
+			point = -1
+		}
+		words := completionWords(os.Getenv("COMP_LINE"), point)
+		if len(words) == 0 {
+			return nil
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		output, err := exec.Command(exe, append([]string{cobra.ShellCompRequestCmd}, words...)...).Output()
+		if err != nil {
+			return nil
+		}
+		for _, candidate := range parseCompletions(strings.NewReader(string(output)), words[len(words)-1]) {
+			fmt.Println(candidate)
+		}
+		return nil
+	},
+}
+
+var shellSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes path for pasting into a POSIX shell. Paths without single
+// quotes paste into PowerShell as well.
+func shellQuote(path string) string {
+	if shellSafeRegex.MatchString(path) {
+		return path
+	}
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
+
+// printCDHint prints a cd line to copy when no shell integration will follow
+// wt into path. The wrapper function sets WT_SHELL_INTEGRATION; hideCdHint in
+// the config turns the hint off. It goes to stderr so scripts reading stdout
+// are not affected.
+func printCDHint(path string) {
+	if os.Getenv("WT_SHELL_INTEGRATION") != "" || getConfig().HideCdHint {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "cd %s  # wt: no shell integration; run this to switch\n", shellQuote(path))
+}
+
+func init() {
+	shellenvCmd.Flags().Bool("minimal", false, "Only register completion; define no shell functions")
+	shellenvCmd.Flags().Bool("install", false, "Add the source line to the startup file of bash or zsh")
+	shellenvCmd.Flags().String("shell", "", "Shell to integrate with: bash, zsh, powershell or cmd (default: detected)")
+	_ = shellenvCmd.RegisterFlagCompletionFunc("shell", cobra.FixedCompletions(
+		[]string{shellBash, shellZsh, shellPowerShell, shellCmd}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.AddCommand(completeWordsCmd)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompletionWords(t *testing.T) {
+	tests := []struct {
+		line  string
+		point int
+		want  []string
+	}{
+		{"wt ", 3, []string{""}},
+		{"wt cr", 5, []string{"cr"}},
+		{"wt create x --base ", 19, []string{"create", "x", "--base", ""}},
+		{"wt create x --base ma", 21, []string{"create", "x", "--base", "ma"}},
+		{"wt checkout feat extra", 16, []string{"checkout", "feat"}},
+		{"wt co", -1, []string{"co"}},
+		{"", 0, nil},
+	}
+	for _, tt := range tests {
+		got := completionWords(tt.line, tt.point)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("completionWords(%q, %d) = %q, want %q", tt.line, tt.point, got, tt.want)
+		}
+	}
+}
+
+func TestParseCompletions(t *testing.T) {
+	output := "checkout\tCheckout existing branch in new worktree\nco\tCheckout existing branch\ncreate\tCreate new branch\n:4\n"
+	if got, want := parseCompletions(strings.NewReader(output), "c"), []string{"checkout", "co", "create"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCompletions() = %q, want %q", got, want)
+	}
+	if got, want := parseCompletions(strings.NewReader(output), "cr"), []string{"create"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCompletions() = %q, want %q", got, want)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"/home/me/dev/worktrees/api/feature-x": "/home/me/dev/worktrees/api/feature-x",
+		"/home/me/my trees/api/x":              "'/home/me/my trees/api/x'",
+		"/tmp/it's":                            `'/tmp/it'\''s'`,
+		`C:\Users\me\api`:                      `'C:\Users\me\api'`,
+	}
+	for path, want := range tests {
+		if got := shellQuote(path); got != want {
+			t.Errorf("shellQuote(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestMinimalIntegration(t *testing.T) {
+	for _, binary := range []string{"wt", "git-wt"} {
+		script := minimalIntegration(false, binary)
+		if strings.Contains(script, "() {") || strings.Contains(script, "function ") {
+			t.Errorf("minimal integration for %s defines a shell function:\n%s", binary, script)
+		}
+		if !strings.Contains(script, "complete -C 'command "+binary+" __complete-words' "+binary) {
+			t.Errorf("minimal integration for %s does not register completion:\n%s", binary, script)
+		}
+		for _, shell := range []string{"bash", "zsh"} {
+			if _, err := exec.LookPath(shell); err != nil {
+				continue
+			}
+			if output, err := exec.Command(shell, "-n", "-c", script).CombinedOutput(); err != nil {
+				t.Errorf("%s rejects the minimal integration: %v\n%s", shell, err, output)
+			}
+		}
+	}
+
+	ps := minimalIntegration(true, "wt")
+	if !strings.Contains(ps, "Register-ArgumentCompleter") || strings.Contains(ps, "function wt") {
+		t.Errorf("PowerShell minimal integration should only register the completer:\n%s", ps)
+	}
+}
+
+func TestShellIntegrationMarksWrapper(t *testing.T) {
+	if !strings.Contains(shellIntegration(false, "wt"), "WT_SHELL_INTEGRATION=1 WT_PATH_STYLE=$path_style command wt") {
+		t.Error("the bash/zsh wrapper must set WT_SHELL_INTEGRATION")
+	}
+	if !strings.Contains(shellIntegration(true, "wt"), "$env:WT_SHELL_INTEGRATION = '1'") {
+		t.Error("the PowerShell wrapper must set WT_SHELL_INTEGRATION")
+	}
+}
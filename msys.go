@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Path styles a shell wrapper can request via WT_PATH_STYLE for the
+// TREE_ME_CD marker.
+const (
+	pathStyleMSYS   = "msys"
+	pathStyleCygwin = "cygwin"
+)
+
+// isMSYSShell reports whether wt runs inside Git Bash, MSYS2 or Cygwin on
+// Windows, which want the bash wrapper rather than the PowerShell one. Git
+// Bash and MSYS2 export MSYSTEM. Cygwin bash exports neither that nor OSTYPE,
+// so a terminal (TERM, which cmd.exe and PowerShell do not set) with
+// cygwin1.dll on the PATH counts as Cygwin too. When all of this misses,
+// 'wt shellenv --shell bash' picks the wrapper explicitly.
+func isMSYSShell() bool {
+	if os.Getenv("MSYSTEM") != "" || os.Getenv("CYGWIN") != "" || strings.HasPrefix(os.Getenv("OSTYPE"), "cygwin") {
+		return true
+	}
+	return os.Getenv("TERM") != "" && cygwinOnPath()
+}
+
+// cygwinOnPath reports whether a directory of PATH holds the Cygwin runtime,
+// as the bin directory of a Cygwin shell does.
+func cygwinOnPath() bool {
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, "cygwin1.dll")); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// markerPath converts a Windows path to the form the calling shell can cd to:
+// C:\Users\me becomes /c/Users/me for MSYS (Git Bash) and
+// /cygdrive/c/Users/me for Cygwin. Other styles leave path unchanged.
+func markerPath(path, style string) string {
+	if style != pathStyleMSYS && style != pathStyleCygwin {
+		return path
+	}
+	p := strings.ReplaceAll(path, `\`, "/")
+	if len(p) >= 2 && p[1] == ':' && isASCIILetter(p[0]) {
+		prefix := "/"
+		if style == pathStyleCygwin {
+			prefix = "/cygdrive/"
+		}
+		p = prefix + strings.ToLower(p[:1]) + p[2:]
+	}
+	return p
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
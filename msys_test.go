@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarkerPath(t *testing.T) {
+	tests := []struct {
+		path  string
+		style string
+		want  string
+	}{
+		{`C:\Users\me\dev\worktrees\api\feature`, pathStyleMSYS, "/c/Users/me/dev/worktrees/api/feature"},
+		{`D:\src\api`, pathStyleCygwin, "/cygdrive/d/src/api"},
+		{`c:/Users/me`, pathStyleMSYS, "/c/Users/me"},
+		{`\\server\share\api`, pathStyleMSYS, "//server/share/api"},
+		{`C:\Users\me`, "", `C:\Users\me`},
+		{"/home/me/dev/worktrees/api", pathStyleMSYS, "/home/me/dev/worktrees/api"},
+		{"/home/me/dev/worktrees/api", "", "/home/me/dev/worktrees/api"},
+	}
+	for _, tt := range tests {
+		if got := markerPath(tt.path, tt.style); got != tt.want {
+			t.Errorf("markerPath(%q, %q) = %q, want %q", tt.path, tt.style, got, tt.want)
+		}
+	}
+}
+
+func TestIsMSYSShell(t *testing.T) {
+	for _, name := range []string{"MSYSTEM", "OSTYPE", "CYGWIN", "TERM"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("PATH", t.TempDir())
+	if isMSYSShell() {
+		t.Error("isMSYSShell() = true without any sign of MSYS or Cygwin")
+	}
+	t.Setenv("MSYSTEM", "MINGW64")
+	if !isMSYSShell() {
+		t.Error("isMSYSShell() = false with MSYSTEM=MINGW64")
+	}
+	t.Setenv("MSYSTEM", "")
+	t.Setenv("OSTYPE", "cygwin")
+	if !isMSYSShell() {
+		t.Error("isMSYSShell() = false with OSTYPE=cygwin")
+	}
+	t.Setenv("OSTYPE", "")
+
+	// Cygwin bash exports neither: its bin directory with the runtime is on
+	// the PATH, and a terminal is set.
+	cygwinBin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(cygwinBin, "cygwin1.dll"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", t.TempDir()+string(os.PathListSeparator)+cygwinBin)
+	if isMSYSShell() {
+		t.Error("isMSYSShell() = true with Cygwin on the PATH of a shell without TERM, like PowerShell")
+	}
+	t.Setenv("TERM", "xterm-256color")
+	if !isMSYSShell() {
+		t.Error("isMSYSShell() = false in a Cygwin terminal")
+	}
+}
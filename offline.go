@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Worktrees on an external drive look deleted to git while the drive is
+// unplugged, and `git worktree prune` would unregister them. wt tells such
+// offline worktrees apart from deleted ones and locks them, so that neither
+// wt prune nor plain git prune drop them, until they are back.
+
+// offlineLockReason is the `git worktree lock` reason of worktrees locked by
+// wt because their volume is offline.
+const offlineLockReason = "volume offline, locked by wt"
+
+// mountParents are the directories removable volumes are mounted in (or one
+// level below, as /media/<user>).
+var mountParents = []string{"/Volumes", "/media", "/mnt", "/run/media"}
+
+// volumeOffline reports whether the missing directory path lives on a volume
+// that is not mounted rather than having been deleted. Walking up to the
+// nearest existing ancestor: a worktree removed from an otherwise present
+// WORKTREE_ROOT was deleted, while an ancestor that is a mount point, an empty
+// directory (an unmounted mount point), a directory volumes are mounted in,
+// or no ancestor at all (a missing drive) means the volume is gone.
+func volumeOffline(path, root string, device func(string) (uint64, error)) bool {
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(dir); !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return true
+		}
+		dir = parent
+	}
+	if isWithin(resolvePath(dir), resolvePath(root)) {
+		return false
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) == 0 {
+		return true
+	}
+	for _, mounts := range mountParents {
+		if dir == mounts || filepath.Dir(dir) == mounts {
+			return true
+		}
+	}
+	parent := filepath.Dir(dir)
+	if parent == dir {
+		return false
+	}
+	dev, err := device(dir)
+	parentDev, parentErr := device(parent)
+	return err == nil && parentErr == nil && dev != parentDev
+}
+
+// isOffline reports whether the linked worktree wt is on an offline volume.
+func isOffline(wt Worktree) bool {
+	return !wt.Bare && volumeOffline(wt.Path, worktreeRoot, deviceOf)
+}
+
+// findOfflineWorktrees returns the linked worktrees on offline volumes, by
+// path. Newly offline ones are locked; ones wt locked that are back are
+// unlocked. Locking failures only cost the protection from plain git prune.
+func findOfflineWorktrees(worktrees []Worktree) map[string]bool {
+	offline := make(map[string]bool)
+	for i, wt := range worktrees {
+		if i == 0 {
+			continue
+		}
+		switch {
+		case isOffline(wt):
+			offline[wt.Path] = true
+			if !wt.Locked && gitSupports(capWorktreeLock) {
+				_ = repoGit("worktree", "lock", "--reason", offlineLockReason, wt.Path).Run()
+			}
+		case wt.Locked && wt.LockReason == offlineLockReason:
+			if _, err := os.Stat(wt.Path); err == nil {
+				_ = repoGit("worktree", "unlock", wt.Path).Run()
+			}
+		}
+	}
+	return offline
+}
+
+// markOffline fills in the offline flag of every worktree.
+func markOffline(infos []worktreeInfo, offline map[string]bool) {
+	for i := range infos {
+		infos[i].Offline = offline[infos[i].Path]
+	}
+}
+
+// unlockOffline lifts the locks wt put on the offline worktrees, so that
+// `wt prune --include-offline` can drop them. Locks of the user stay.
+func unlockOffline(worktrees []Worktree, offline map[string]bool) {
+	for _, wt := range worktrees {
+		if offline[wt.Path] && (!wt.Locked || wt.LockReason == offlineLockReason) {
+			_ = repoGit("worktree", "unlock", wt.Path).Run()
+		}
+	}
+}
+
+// printOfflineSkipped tells how many offline worktrees a prune left alone.
+func printOfflineSkipped(skipped int) {
+	switch {
+	case skipped == 1:
+		infoln("Skipped 1 worktree on an offline volume (see --include-offline)")
+	case skipped > 1:
+		infof("Skipped %d worktrees on offline volumes (see --include-offline)\n", skipped)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVolumeOffline(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "trees")
+	drive := filepath.Join(tmpDir, "drive")
+	mount := filepath.Join(tmpDir, "mount")
+	for _, dir := range []string{filepath.Join(root, "api", "main"), drive, filepath.Join(mount, "lost+found")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sameDevice := func(string) (uint64, error) { return 1, nil }
+	// mount is the root of another filesystem.
+	mountDevice := func(path string) (uint64, error) {
+		if path == mount {
+			return 2, nil
+		}
+		return 1, nil
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		root   string
+		device func(string) (uint64, error)
+		want   bool
+	}{
+		{"present", filepath.Join(root, "api", "main"), root, sameDevice, false},
+		{"deleted below the root", filepath.Join(root, "api", "feature"), root, sameDevice, false},
+		{"repository gone below the root", filepath.Join(root, "web", "feature"), root, sameDevice, false},
+		{"root on an unmounted drive", filepath.Join(drive, "trees", "api", "feature"), filepath.Join(drive, "trees"), sameDevice, true},
+		{"outside the root on an unmounted drive", filepath.Join(drive, "wip", "feature"), root, sameDevice, true},
+		{"below a mount point", filepath.Join(mount, "wip", "feature"), root, mountDevice, true},
+		{"deleted outside the root", filepath.Join(mount, "wip", "feature"), root, sameDevice, false},
+	}
+	for _, tt := range tests {
+		if got := volumeOffline(tt.path, tt.root, tt.device); got != tt.want {
+			t.Errorf("%s: volumeOffline(%s) = %v, want %v", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPruneWorktreesKeepsOffline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	drive := filepath.Join(tmpDir, "drive")
+	setupTestRepo(t, repoDir)
+	external := filepath.Join(drive, "trees", "repo", "external")
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "external", external)
+	t.Chdir(repoDir)
+	oldRoot := worktreeRoot
+	worktreeRoot = filepath.Join(drive, "trees")
+	t.Cleanup(func() { worktreeRoot = oldRoot })
+
+	// Unplug the drive: its mount point stays behind, empty.
+	if err := os.RemoveAll(filepath.Join(drive, "trees")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, offlineSkipped, err := pruneWorktrees(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offlineSkipped != 1 {
+		t.Errorf("pruneWorktrees() skipped %d offline worktrees, want 1", offlineSkipped)
+	}
+	wt, ok := newManager("").Find("external")
+	if !ok || !wt.Locked || wt.LockReason != offlineLockReason {
+		t.Fatalf("offline worktree after prune = %+v, %v; want it kept and locked", wt, ok)
+	}
+	infos := newWorktreeInfos([]Worktree{{Path: repoDir, Branch: "main"}, wt})
+	markOffline(infos, map[string]bool{wt.Path: true})
+	if got := porcelainFlags(infos[1]); got != "locked,offline" {
+		t.Errorf("porcelainFlags() = %q, want locked,offline", got)
+	}
+
+	// Plug it back in: the lock is lifted.
+	if err := os.MkdirAll(wt.Path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offline := findOfflineWorktrees(worktrees); len(offline) != 0 {
+		t.Errorf("findOfflineWorktrees() = %v after the drive is back", offline)
+	}
+	if wt, _ := newManager("").Find("external"); wt.Locked {
+		t.Error("the worktree should be unlocked once its volume is back")
+	}
+
+	if err := os.RemoveAll(filepath.Join(drive, "trees")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := pruneWorktrees(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := newManager("").Find("external"); ok {
+		t.Errorf("--include-offline should prune %s", wt.Path)
+	}
+}
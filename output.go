@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// stdout carries only data: paths, JSON, porcelain, reports such as wt list,
+// and the TREE_ME_CD marker the shell wrapper cds to, so that scripts and the
+// wrapper can consume it. Commentary for the user, such as "✓ Worktree created
+// at: ...", git's own output and prompts go to stderr.
+
+// infof prints commentary for the user to stderr.
+func infof(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// infoln prints a line of commentary for the user to stderr.
+func infoln(args ...any) {
+	fmt.Fprintln(os.Stderr, args...)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+// Branches created by wt are marked in git config with
+// branch.<name>.wt-owned=true. Destructive branch operations only act on
+// owned branches unless the user passes --include-unowned; git drops the
+// marker together with the rest of the branch's config when it is deleted.
+func ownedKey(branch string) string {
+	return "branch." + branch + ".wt-owned"
+}
+
+// markBranchOwned records that wt created branch.
+func markBranchOwned(dir, branch string) error {
+	return gitIn(dir, "config", ownedKey(branch), "true").Run()
+}
+
+// unmarkBranchOwned removes the ownership marker of branch.
+func unmarkBranchOwned(dir, branch string) error {
+	return gitIn(dir, "config", "--unset", ownedKey(branch)).Run()
+}
+
+// isBranchOwned reports whether wt created branch. Branches wt fetched for a
+// review (pr-<n>/mr-<n>) count as owned even if they predate the marker.
+func isBranchOwned(dir, branch, review string) bool {
+	if review != "" && worktree.SameBranch(review, branch) {
+		return true
+	}
+	output, err := gitIn(dir, "config", "--bool", "--get", ownedKey(branch)).Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+var adoptCmd = &cobra.Command{
+	Use:   "adopt <branch>",
+	Short: "Mark a branch as created by wt",
+	Long: `Mark a branch as created by wt.
+
+Destructive branch operations such as 'wt rm --delete-branch' only act on
+branches wt created, unless --include-unowned is passed. Adopt a branch you
+created yourself to let wt manage it like its own, or use --disown to
+protect a branch wt created.
+
+Examples:
+  wt adopt feature-x             # wt may now delete feature-x
+  wt adopt feature-x --disown    # wt keeps its hands off feature-x`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		branch := args[0]
+		disown, _ := cmd.Flags().GetBool("disown")
+
+		if repoGit("show-ref", "--verify", "--quiet", "refs/heads/"+branch).Run() != nil {
+			return fmt.Errorf("branch '%s' does not exist", branch)
+		}
+
+		if disown {
+			if isBranchOwned("", branch, "") {
+				if err := unmarkBranchOwned("", branch); err != nil {
+					return fmt.Errorf("failed to disown %s: %w", branch, err)
+				}
+			}
+			infof("✓ Branch %s is no longer owned by wt\n", branch)
+			return nil
+		}
+		if err := markBranchOwned("", branch); err != nil {
+			return fmt.Errorf("failed to adopt %s: %w", branch, err)
+		}
+		infof("✓ Branch %s is now owned by wt\n", branch)
+		return nil
+	},
+}
+
+// selectWorktree returns the worktree of the branch in args, or the worktree
+// containing the current directory when args is empty.
+func selectWorktree(worktrees []Worktree, args []string) (Worktree, error) {
+	var wt Worktree
+	found := false
+	if len(args) == 1 {
+		for _, candidate := range worktrees {
+			if candidate.Branch != "" && worktree.SameBranch(candidate.Branch, args[0]) {
+				wt, found = candidate, true
+			}
+		}
+		if !found {
+			return wt, fmt.Errorf("no worktree found for branch: %s", args[0])
+		}
+		return wt, nil
+	}
+	top, err := currentWorktreePath()
+	if err != nil {
+		return wt, err
+	}
+	for _, candidate := range worktrees {
+		if sameDir(candidate.Path, top) {
+			return candidate, nil
+		}
+	}
+	return wt, fmt.Errorf("not inside a worktree")
+}
+
+// currentWorktreePath returns the top directory of the worktree containing
+// the current directory. git resolves it, so symlinked directories on the way
+// and nested worktrees (the nested-main layout) need no path comparisons.
+func currentWorktreePath() (string, error) {
+	output, err := gitIn("", "rev-parse", "--show-toplevel").Output()
+	top := strings.TrimSpace(string(output))
+	if err != nil || top == "" {
+		return "", fmt.Errorf("not inside a worktree")
+	}
+	return resolvePath(top), nil
+}
+
+// formatTimestamp renders ts with its age, or "unknown" when unset.
+func formatTimestamp(ts time.Time) string {
+	if ts.IsZero() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s (%s ago)", ts.Local().Format(time.RFC3339), formatAge(time.Since(ts)))
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info [branch]",
+	Short: "Show what wt knows about a worktree",
+	Long: `Show what wt knows about a worktree: its path, whether wt created its
+branch, when it was created and last switched to, and the PR/MR it belongs to.
+
+Without a branch, the worktree containing the current directory is shown.`,
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		wt, err := selectWorktree(worktrees, branchArgs(args))
+		if err != nil {
+			return err
+		}
+
+		times := loadWorktreeTimes("")[filepath.Clean(wt.Path)]
+		review := ""
+		owned := "-"
+		if wt.Branch != "" {
+			review = lookupReview("", wt.Branch)
+			owned = "no"
+			if isBranchOwned("", wt.Branch, review) {
+				owned = "yes"
+			}
+		}
+		branch := wt.Branch
+		if branch == "" {
+			branch = "(detached HEAD)"
+		}
+
+		fmt.Printf("Branch:        %s\n", branch)
+		fmt.Printf("Path:          %s\n", wt.Path)
+		fmt.Printf("HEAD:          %s\n", wt.Head)
+		fmt.Printf("Owned by wt:   %s\n", owned)
+		fmt.Printf("Created:       %s\n", formatTimestamp(times.CreatedAt))
+		fmt.Printf("Last switched: %s\n", formatTimestamp(times.LastSwitchedAt))
+		if review != "" {
+			fmt.Printf("Review:        %s\n", review)
+		}
+		if base, at, ok := loadSnapshot(wt.Path); ok {
+			fmt.Printf("Snapshot:      %s as of %s\n", base, at)
+		}
+		if wt.Branch != "" && isBranchPinned("", wt.Branch) {
+			fmt.Println("Pinned:        yes")
+		}
+		if parked := loadParkedBranches()[filepath.Clean(wt.Path)]; parked != "" {
+			fmt.Printf("Parked:        %s (see 'wt unpark')\n", parked)
+		}
+		return nil
+	},
+}
+
+func init() {
+	adoptCmd.Flags().Bool("disown", false, "Remove the ownership mark instead")
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// A parked worktree has its HEAD detached so that its branch can be checked
+// out elsewhere. The branch is recorded in the worktree's metadata
+// (wt-worktree.<path>.parkedBranch) and uncommitted changes to tracked files
+// are kept in refs/wt/parked/<branch>, a commit made with `git stash create`,
+// which leaves the stash list alone.
+const parkedBranchName = "parkedBranch"
+
+func parkedRef(branch string) string {
+	return "refs/wt/parked/" + branch
+}
+
+// parseParkedBranches parses `git config -z --get-regexp` output of the
+// parkedBranch metadata into branches by worktree path.
+func parseParkedBranches(output string) map[string]string {
+	parked := make(map[string]string)
+	suffix := "." + strings.ToLower(parkedBranchName)
+	for _, entry := range strings.Split(output, "\x00") {
+		key, value, ok := strings.Cut(entry, "\n")
+		if !ok {
+			continue
+		}
+		rest, ok := strings.CutPrefix(key, worktreeMetaSection+".")
+		if !ok {
+			continue
+		}
+		path, ok := strings.CutSuffix(rest, suffix)
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+		parked[path] = strings.TrimSpace(value)
+	}
+	return parked
+}
+
+// loadParkedBranches returns the parked branches of the repository by
+// worktree path.
+func loadParkedBranches() map[string]string {
+	output, err := repoGit("config", "-z", "--get-regexp", `^`+worktreeMetaSection+`\..*\.`+strings.ToLower(parkedBranchName)+`$`).Output()
+	if err != nil {
+		return map[string]string{}
+	}
+	return parseParkedBranches(string(output))
+}
+
+// findParkedWorktree returns the path of the worktree where branch is
+// parked, or of the worktree containing the current directory when branch is
+// empty, together with the parked branch.
+func findParkedWorktree(worktrees []Worktree, branch string) (string, string, error) {
+	parked := loadParkedBranches()
+	if branch == "" {
+		wt, err := selectWorktree(worktrees, nil)
+		if err != nil {
+			return "", "", err
+		}
+		path := filepath.Clean(wt.Path)
+		if parked[path] == "" {
+			return "", "", fmt.Errorf("%s is not parked", wt.Path)
+		}
+		return path, parked[path], nil
+	}
+	for _, wt := range worktrees {
+		path := filepath.Clean(wt.Path)
+		if parked[path] == branch {
+			return path, branch, nil
+		}
+	}
+	return "", "", fmt.Errorf("branch %s is not parked in any worktree", branch)
+}
+
+var parkCmd = &cobra.Command{
+	Use:   "park [branch]",
+	Short: "Detach a worktree to free its branch, keeping its changes",
+	Long: `Park a worktree: free its branch for use elsewhere without losing work.
+
+Uncommitted changes to tracked files are saved in refs/wt/parked/<branch>
+(your stash list is left alone) and the worktree is cleaned and detached at
+the branch's commit. Untracked files stay in place. 'wt unpark' checks the
+branch out again, wherever it has moved in the meantime, and restores the
+saved changes.
+
+Without a branch, the worktree containing the current directory is parked.
+
+Examples:
+  wt park feature-x       # Free feature-x, e.g. for a script in the main clone
+  wt unpark feature-x     # Take it back with the changes it had`,
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		wt, err := selectWorktree(worktrees, branchArgs(args))
+		if err != nil {
+			return err
+		}
+		if wt.Bare {
+			return fmt.Errorf("cannot park the bare repository")
+		}
+		if wt.Branch == "" {
+			return fmt.Errorf("cannot park %s: its HEAD is already detached", wt.Path)
+		}
+		ref := parkedRef(wt.Branch)
+		if gitIn(wt.Path, "rev-parse", "--verify", "--quiet", ref).Run() == nil {
+			return fmt.Errorf("%s already exists; run 'wt unpark %s' first or delete it with 'git update-ref -d %s'", ref, wt.Branch, ref)
+		}
+
+		output, err := gitIn(wt.Path, "stash", "create", "wt park "+wt.Branch).Output()
+		if err != nil {
+			return fmt.Errorf("failed to save the changes in %s: %w", wt.Path, err)
+		}
+		saved := strings.TrimSpace(string(output))
+		if saved != "" {
+			if output, err := gitIn(wt.Path, "update-ref", "-m", "wt park", ref, saved).CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to save the changes in %s: %s", ref, strings.TrimSpace(string(output)))
+			}
+			if output, err := gitIn(wt.Path, "reset", "--hard", "--quiet").CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to clean %s (changes are saved in %s): %s", wt.Path, ref, strings.TrimSpace(string(output)))
+			}
+		}
+		if output, err := gitIn(wt.Path, "checkout", "--detach", "--quiet").CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to detach %s: %s", wt.Path, strings.TrimSpace(string(output)))
+		}
+		if err := gitIn(wt.Path, "config", worktreeMetaKey(wt.Path, parkedBranchName), wt.Branch).Run(); err != nil {
+			return fmt.Errorf("failed to record that %s is parked: %w", wt.Branch, err)
+		}
+
+		infof("✓ Parked %s: %s\n", wt.Branch, displayPath(wt.Path))
+		if saved != "" {
+			infof("  Uncommitted changes saved in %s\n", ref)
+		}
+		return nil
+	},
+}
+
+var unparkCmd = &cobra.Command{
+	Use:   "unpark [branch]",
+	Short: "Check a parked branch out again and restore its changes",
+	Long: `Re-attach the branch of a parked worktree and restore the changes saved
+by 'wt park'. The branch must not be checked out in another worktree.
+
+Without a branch, the worktree containing the current directory is unparked.`,
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		branch := ""
+		if args := branchArgs(args); len(args) == 1 {
+			branch = args[0]
+		}
+		path, branch, err := findParkedWorktree(worktrees, branch)
+		if err != nil {
+			return err
+		}
+
+		if output, err := gitIn(path, "switch", "--quiet", branch).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to check out %s in %s: %s", branch, path, strings.TrimSpace(string(output)))
+		}
+		_ = gitIn(path, "config", "--unset", worktreeMetaKey(path, parkedBranchName)).Run()
+		infof("✓ Unparked %s: %s\n", branch, displayPath(path))
+
+		ref := parkedRef(branch)
+		if gitIn(path, "rev-parse", "--verify", "--quiet", ref).Run() != nil {
+			return nil
+		}
+		if output, err := gitIn(path, "stash", "apply", "--index", ref).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to restore the parked changes, which are kept in %s; resolve and run 'git update-ref -d %s':\n%s",
+				ref, ref, strings.TrimSpace(string(output)))
+		}
+		_ = gitIn(path, "update-ref", "-d", ref).Run()
+		infoln("  Restored the parked changes")
+		return nil
+	},
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseParkedBranches(t *testing.T) {
+	output := "wt-worktree./trees/api/feature x.parkedbranch\nfeature-x\x00" +
+		"wt-worktree./trees/api/v1.2.parkedbranch\nrelease/1.2\x00" +
+		"wt-worktree./trees/api/v1.2.createdat\n2026-02-01T00:00:00Z\x00" +
+		"wt-worktree./trees/api/empty.parkedbranch\n\x00"
+	want := map[string]string{
+		"/trees/api/feature x": "feature-x",
+		"/trees/api/v1.2":      "release/1.2",
+	}
+	if got := parseParkedBranches(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseParkedBranches() = %v, want %v", got, want)
+	}
+}
+
+func TestParkAndUnpark(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	if err := os.WriteFile(filepath.Join(repoDir, "file.txt"), []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, repoDir, "add", "file.txt")
+	runGitCommand(t, repoDir, "commit", "-m", "add file")
+	feature := filepath.Join(tmpDir, "feature")
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature", feature)
+
+	// Leave a modified, a staged and an untracked file behind.
+	for name, content := range map[string]string{"file.txt": "two\n", "staged.txt": "staged\n", "untracked.txt": "untracked\n"} {
+		if err := os.WriteFile(filepath.Join(feature, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGitCommand(t, feature, "add", "staged.txt")
+	t.Chdir(feature)
+
+	if err := parkCmd.RunE(parkCmd, nil); err != nil {
+		t.Fatalf("park: %v", err)
+	}
+	if branch := strings.TrimSpace(gitOutput(t, feature, "branch", "--show-current")); branch != "" {
+		t.Fatalf("parked worktree is still on %s", branch)
+	}
+	if status := gitOutput(t, feature, "status", "--porcelain"); status != "?? untracked.txt\n" {
+		t.Errorf("parked worktree status = %q, want only the untracked file", status)
+	}
+	if stashes := gitOutput(t, feature, "stash", "list"); stashes != "" {
+		t.Errorf("park touched the stash list: %q", stashes)
+	}
+
+	// The branch is free: move it on from the main clone.
+	runGitCommand(t, repoDir, "checkout", "feature")
+	runGitCommand(t, repoDir, "commit", "--allow-empty", "-m", "moved on")
+	runGitCommand(t, repoDir, "checkout", "main")
+
+	if err := unparkCmd.RunE(unparkCmd, []string{"feature"}); err != nil {
+		t.Fatalf("unpark: %v", err)
+	}
+	if branch := strings.TrimSpace(gitOutput(t, feature, "branch", "--show-current")); branch != "feature" {
+		t.Errorf("unparked worktree is on %q, want feature", branch)
+	}
+	if subject := strings.TrimSpace(gitOutput(t, feature, "log", "-1", "--format=%s")); subject != "moved on" {
+		t.Errorf("unparked worktree is at %q, want the moved branch", subject)
+	}
+	if status := gitOutput(t, feature, "status", "--porcelain"); status != " M file.txt\nA  staged.txt\n?? untracked.txt\n" {
+		t.Errorf("unparked worktree status = %q", status)
+	}
+	if refs := gitOutput(t, feature, "for-each-ref", "refs/wt/"); refs != "" {
+		t.Errorf("parked ref was not deleted: %q", refs)
+	}
+	if parked := loadParkedBranches(); len(parked) != 0 {
+		t.Errorf("parked metadata was not removed: %v", parked)
+	}
+}
+
+// gitOutput returns the output of git in dir, failing the test on errors.
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	output, err := gitIn(dir, args...).Output()
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return string(output)
+}
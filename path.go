@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var pathCmd = &cobra.Command{
+	Use:   "path [branch]",
+	Short: "Print the path of a worktree",
+	Long: `Print the absolute path of the worktree of a branch, or of the worktree
+containing the current directory, and nothing else. Without such a worktree
+wt exits with status 1 and prints nothing on stdout.
+
+Meant for scripts and editors, which need no cd marker to parse; under Git
+Bash or Cygwin the path is in the form the shell can cd to.
+
+Examples:
+  code "$(wt path feature-x)"   # Open the worktree of feature-x
+  cd "$(wt path pr-123)"        # Change to a worktree without the shell integration
+  wt path                       # The worktree you are in`,
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		var wt Worktree
+		if args := branchArgs(args); len(args) == 1 {
+			wt, err = findSwitchTarget(worktrees, args[0])
+		} else {
+			wt, err = selectWorktree(worktrees, nil)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(markerPath(displayPath(wt.Path), os.Getenv("WT_PATH_STYLE")))
+		return nil
+	},
+}
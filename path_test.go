@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	featurePath := filepath.Join(tmpDir, "feature-x")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feature-x", featurePath)
+	runGitCommand(t, repoDir, "branch", "no-worktree")
+	t.Chdir(featurePath)
+	t.Setenv("WT_BRANCH", "")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"main"}, repoDir},
+		{[]string{"feature-x"}, featurePath},
+		{nil, featurePath},
+	}
+	for _, tt := range tests {
+		output, err := runCapturing(t, pathCmd, tt.args...)
+		if err != nil {
+			t.Fatalf("wt path %v: %v", tt.args, err)
+		}
+		if got := strings.TrimSuffix(output, "\n"); !sameDir(got, tt.want) || strings.Contains(got, "\n") {
+			t.Errorf("wt path %v printed %q, want only %s", tt.args, output, tt.want)
+		}
+	}
+
+	output, err := runCapturing(t, pathCmd, "no-worktree")
+	if err == nil || output != "" {
+		t.Errorf("wt path no-worktree = %q, %v; want an error and no output", output, err)
+	}
+}
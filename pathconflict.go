@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// A file or symlink where a new worktree should go, e.g. from output
+// redirected to the worktree path by mistake, makes `git worktree add` fail
+// with an error that does not say what is in the way. wt checks the target
+// first and offers to move the file aside.
+
+// describePathConflict says what is in the way at path, or returns "" when
+// nothing or a directory is there; git deals with directories itself.
+func describePathConflict(path string) string {
+	info, err := os.Lstat(path)
+	if err != nil || info.IsDir() {
+		return ""
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, _ := os.Readlink(path)
+		return fmt.Sprintf("target path %s exists and is a symlink to %s", displayPath(path), target)
+	case info.Mode().IsRegular():
+		return fmt.Sprintf("target path %s exists and is a file modified at %s, size %d bytes",
+			displayPath(path), info.ModTime().Format("2006-01-02 15:04:05"), info.Size())
+	}
+	return fmt.Sprintf("target path %s exists and is a %s, not a directory", displayPath(path), info.Mode().Type())
+}
+
+// conflictAsidePath is where a file in the way of a worktree is moved to.
+func conflictAsidePath(path string, now time.Time) string {
+	return path + ".wt-conflict-" + now.Format("20060102-150405")
+}
+
+// resolvePathConflict moves a file or symlink in the way of the worktree at
+// path aside, after asking, or right away with --force. Without a terminal
+// to ask on and without --force it fails, saying what is in the way.
+func resolvePathConflict(cmd *cobra.Command, path string) error {
+	conflict := describePathConflict(path)
+	if conflict == "" {
+		return nil
+	}
+	aside := conflictAsidePath(path, time.Now())
+	if force, _ := cmd.Flags().GetBool("force"); !force {
+		if !stdinIsTerminal() {
+			return fmt.Errorf("%s\nMove it away, or pass --force to move it to %s", conflict, displayPath(aside))
+		}
+		fmt.Fprintln(cmd.ErrOrStderr(), conflict)
+		ok, err := promptFor(cmd).Confirm("Move it to " + displayPath(aside))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errCancelled
+		}
+	}
+	if err := os.Rename(path, aside); err != nil {
+		return fmt.Errorf("failed to move %s aside: %w", displayPath(path), err)
+	}
+	infof("✓ Moved %s out of the way to %s\n", displayPath(path), displayPath(aside))
+	return nil
+}
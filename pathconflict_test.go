@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribePathConflict(t *testing.T) {
+	dir := t.TempDir()
+	if got := describePathConflict(filepath.Join(dir, "missing")); got != "" {
+		t.Errorf("describePathConflict(missing) = %q, want none", got)
+	}
+	if got := describePathConflict(dir); got != "" {
+		t.Errorf("describePathConflict(directory) = %q, want none", got)
+	}
+
+	file := filepath.Join(dir, "feature-x")
+	if err := os.WriteFile(file, []byte("build log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2026, 3, 4, 5, 6, 7, 0, time.Local)
+	if err := os.Chtimes(file, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	got := describePathConflict(file)
+	if !strings.Contains(got, "is a file modified at 2026-03-04 05:06:07, size 10 bytes") {
+		t.Errorf("describePathConflict(file) = %q, want its mtime and size", got)
+	}
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+	if got := describePathConflict(link); !strings.Contains(got, "is a symlink to "+dir) {
+		t.Errorf("describePathConflict(symlink to a directory) = %q, want the symlink named", got)
+	}
+}
+
+func TestConflictAsidePath(t *testing.T) {
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	if got, want := conflictAsidePath("/w/api/feature-x", now), "/w/api/feature-x.wt-conflict-20260304-050607"; got != want {
+		t.Errorf("conflictAsidePath() = %q, want %q", got, want)
+	}
+}
+
+func TestResolvePathConflict(t *testing.T) {
+	stubConfirm(t, false, &Config{})
+	dir := t.TempDir()
+	path := filepath.Join(dir, "feature-x")
+	if err := resolvePathConflict(newFlagTestCmd(t, []string{"force"}), path); err != nil {
+		t.Fatalf("resolvePathConflict() with nothing in the way = %v", err)
+	}
+	if err := os.WriteFile(path, []byte("oops"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := resolvePathConflict(newFlagTestCmd(t, []string{"force"}), path)
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("resolvePathConflict() without a terminal = %v, want a refusal naming --force", err)
+	}
+
+	stdinIsTerminal = func() bool { return true }
+	cmd := newFlagTestCmd(t, []string{"force"})
+	p := script(t, cmd, false)
+	if err := resolvePathConflict(cmd, path); !errors.Is(err, errCancelled) {
+		t.Errorf("resolvePathConflict() declined = %v, want cancelled", err)
+	}
+	if len(p.asked) != 1 || !strings.Contains(p.asked[0], ".wt-conflict-") {
+		t.Errorf("prompts = %q, want to be asked about moving the file aside", p.asked)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("declined, but the file is gone: %v", err)
+	}
+
+	stdinIsTerminal = func() bool { return false }
+	if err := resolvePathConflict(newFlagTestCmd(t, []string{"force"}, "--force"), path); err != nil {
+		t.Fatalf("resolvePathConflict(--force) = %v", err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("the file is still in the way after --force: %v", err)
+	}
+	moved, _ := filepath.Glob(path + ".wt-conflict-*")
+	if len(moved) != 1 {
+		t.Fatalf("moved files = %q, want one", moved)
+	}
+	if data, _ := os.ReadFile(moved[0]); string(data) != "oops" {
+		t.Errorf("moved file holds %q, want the original content", data)
+	}
+}
+
+func TestCreateWithFileInTheWay(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	setupInteractiveRepo(t)
+	stubConfirm(t, false, &Config{})
+	repo, err := getRepoName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := newManager(repo).Path("add-login")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("redirected output"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = createCmd.RunE(createCmd, []string{"add-login"})
+	if err == nil || !strings.Contains(err.Error(), "exists and is a file") {
+		t.Fatalf("create with a file in the way = %v, want it described", err)
+	}
+	if err := createCmd.Flags().Set("force", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = createCmd.Flags().Set("force", "false") })
+	if err := createCmd.RunE(createCmd, []string{"add-login"}); err != nil {
+		t.Fatalf("create --force with a file in the way = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		t.Errorf("no worktree at %s after moving the file aside: %v", path, err)
+	}
+}
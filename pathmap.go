@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// In a devcontainer or codespace the worktree root is mounted at another path
+// than on the host, so paths printed by wt on one side are useless to a shell
+// on the other. With --map-paths (or mapPaths in the config) the pathMappings
+// of the config rewrite the paths wt prints, and paths given to wt are mapped
+// back.
+
+// PathMapping maps paths below From to the same paths below To.
+type PathMapping struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// mapPaths is whether --map-paths or the mapPaths config applies.
+var mapPaths bool
+
+// applyMapPaths sets mapPaths for cmd's invocation.
+func applyMapPaths(cmd *cobra.Command) {
+	flag, _ := cmd.Flags().GetBool("map-paths")
+	mapPaths = flag || getConfig().MapPaths
+}
+
+// displayPath is path as wt prints it: mapped when mapping is active.
+func displayPath(path string) string {
+	if !mapPaths {
+		return path
+	}
+	return mapPath(path, getConfig().PathMappings)
+}
+
+// hostPath is a path given to wt as wt uses it: mapped back when mapping is
+// active.
+func hostPath(path string) string {
+	if !mapPaths {
+		return path
+	}
+	return unmapPath(path, getConfig().PathMappings)
+}
+
+// mapPath rewrites path with the mapping whose From is its longest prefix, so
+// a mapping of /home/me/src/api wins over one of /home/me/src. Prefixes only
+// match whole components and either separator; the rest of the path takes
+// the separator of To. Paths no mapping matches are returned as is.
+func mapPath(path string, mappings []PathMapping) string {
+	best, bestLen := path, -1
+	for _, m := range mappings {
+		from := trimSeparators(m.From)
+		if from == "" || len(from) <= bestLen {
+			continue
+		}
+		if mapped, ok := replacePathPrefix(path, from, m.To); ok {
+			best, bestLen = mapped, len(from)
+		}
+	}
+	return best
+}
+
+// unmapPath undoes mapPath: it applies the mappings from To to From.
+func unmapPath(path string, mappings []PathMapping) string {
+	inverse := make([]PathMapping, len(mappings))
+	for i, m := range mappings {
+		inverse[i] = PathMapping{From: m.To, To: m.From}
+	}
+	return mapPath(path, inverse)
+}
+
+// replacePathPrefix replaces the prefix from of path by to, if from is a
+// prefix of whole components.
+func replacePathPrefix(path, from, to string) (string, bool) {
+	p, f := toSlash(path), toSlash(from)
+	if len(p) < len(f) || !samePathPrefix(p[:len(f)], f) {
+		return "", false
+	}
+	rest := p[len(f):]
+	if rest != "" && rest[0] != '/' {
+		return "", false
+	}
+	sep := "/"
+	if strings.Contains(to, `\`) || (hasDriveLetter(to) && !strings.Contains(to, "/")) {
+		sep = `\`
+	}
+	mapped := trimSeparators(to) + strings.ReplaceAll(rest, "/", sep)
+	if mapped == "" {
+		return to, true
+	}
+	return mapped, true
+}
+
+// samePathPrefix compares slash-separated prefixes; Windows paths (with a
+// drive letter) ignore case.
+func samePathPrefix(a, b string) bool {
+	if hasDriveLetter(a) && hasDriveLetter(b) {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// trimSeparators removes trailing separators; the root becomes empty.
+func trimSeparators(path string) string {
+	return strings.TrimRight(path, `/\`)
+}
+
+func toSlash(path string) string {
+	return strings.ReplaceAll(path, `\`, "/")
+}
+
+func hasDriveLetter(path string) bool {
+	return len(path) >= 2 && path[1] == ':' && isASCIILetter(path[0])
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMapPath(t *testing.T) {
+	mappings := []PathMapping{
+		{From: "/home/me/dev/worktrees", To: "/workspaces/worktrees"},
+		{From: "/home/me/dev/worktrees/api/", To: "/workspaces/api"},
+		{From: `C:\Users\me\worktrees`, To: "/mnt/worktrees"},
+		{From: "/srv/trees", To: `D:\trees`},
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/home/me/dev/worktrees/web/main", "/workspaces/worktrees/web/main"},
+		// The longest matching prefix wins, whatever the order.
+		{"/home/me/dev/worktrees/api/feature", "/workspaces/api/feature"},
+		{"/home/me/dev/worktrees/api", "/workspaces/api"},
+		{"/home/me/dev/worktrees", "/workspaces/worktrees"},
+		// Prefixes match whole components only.
+		{"/home/me/dev/worktrees-old/web", "/home/me/dev/worktrees-old/web"},
+		{"/home/me/dev/worktrees/apis/x", "/workspaces/worktrees/apis/x"},
+		// Either separator, drive letters in any case.
+		{`C:\Users\me\worktrees\api\feature`, "/mnt/worktrees/api/feature"},
+		{`c:/users/me/worktrees/api`, "/mnt/worktrees/api"},
+		{"/srv/trees/api/feature", `D:\trees\api\feature`},
+		{"/elsewhere/api", "/elsewhere/api"},
+	}
+	for _, tt := range tests {
+		if got := mapPath(tt.path, mappings); got != tt.want {
+			t.Errorf("mapPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestUnmapPath(t *testing.T) {
+	mappings := []PathMapping{
+		{From: "/home/me/dev/worktrees", To: "/workspaces"},
+		{From: `C:\Users\me\worktrees`, To: "/mnt/worktrees/"},
+		{From: "/home/me/root", To: "/"},
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/workspaces/api/feature", "/home/me/dev/worktrees/api/feature"},
+		{"/mnt/worktrees/api", `C:\Users\me\worktrees\api`},
+		{"/workspaces-old/api", "/workspaces-old/api"},
+		// A mapping to the root cannot be undone: it would match any path.
+		{"/srv/api", "/srv/api"},
+	}
+	for _, tt := range tests {
+		if got := unmapPath(tt.path, mappings); got != tt.want {
+			t.Errorf("unmapPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestE2EMapPaths(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("the container paths in this test are Unix paths")
+	}
+
+	// git prints resolved paths; macOS's temporary directory is a symlink.
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(tmpDir, "test-repo")
+	root := filepath.Join(tmpDir, "worktrees")
+	config := filepath.Join(tmpDir, "config.yaml")
+	setupTestRepo(t, repoDir)
+	if err := os.WriteFile(config, []byte("pathMappings:\n  - from: "+tmpDir+"\n    to: /workspaces\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	wt := func(env []string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command(wtBinary, args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), append([]string{"WORKTREE_ROOT=" + root, "WT_CONFIG=" + config, "WT_STATE_DIR=" + filepath.Join(tmpDir, "state")}, env...)...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("wt %s failed: %v\n%s", strings.Join(args, " "), err, output)
+		}
+		return string(output)
+	}
+
+	if out := wt(nil, "create", "feature"); !strings.Contains(out, "TREE_ME_CD:"+filepath.Join(root, "test-repo", "feature")) {
+		t.Errorf("wt create without --map-paths: marker not on the host:\n%s", out)
+	}
+	out := wt(nil, "--map-paths", "create", "other")
+	for _, want := range []string{"Worktree created at: /workspaces/worktrees/test-repo/other", "TREE_ME_CD:/workspaces/worktrees/test-repo/other"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("wt --map-paths create: output missing %q:\n%s", want, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "test-repo", "other")); err != nil {
+		t.Errorf("worktree not created on the host: %v", err)
+	}
+	if out := wt(nil, "--map-paths", "list", "--json"); !strings.Contains(out, `"path": "/workspaces/worktrees/test-repo/feature"`) || strings.Contains(out, tmpDir) {
+		t.Errorf("wt --map-paths list --json: paths not mapped:\n%s", out)
+	}
+
+	// Paths given in container form are mapped back.
+	if out := wt([]string{"WT_REPO_DIR=/workspaces/test-repo"}, "--map-paths", "env"); !strings.Contains(out, "WT_REPO_DIR=/workspaces/test-repo") {
+		t.Errorf("wt --map-paths env with a container WT_REPO_DIR:\n%s", out)
+	}
+}
+
+func TestLoadConfigPathMappings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("mapPaths: true\npathMappings:\n  - from: /home/me/dev\n    to: /workspaces\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if !cfg.MapPaths || len(cfg.PathMappings) != 1 || cfg.PathMappings[0] != (PathMapping{From: "/home/me/dev", To: "/workspaces"}) {
+		t.Errorf("loadConfig() = %+v", cfg)
+	}
+
+	if err := os.WriteFile(path, []byte("pathMappings:\n  - from: /\n    to: /workspaces\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Error("loadConfig() should reject a mapping from the root")
+	}
+}
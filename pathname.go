@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/timvw/wt/pkg/worktree"
+	"golang.org/x/text/unicode/norm"
+)
+
+// worktreeDirName returns the directory name (relative to the repository
+// directory) used for a branch's worktree. With asciiSlug enabled, non-ASCII
+// characters are transliterated where possible and percent-encoded otherwise;
+// the real branch name is still what git records for the worktree. With
+// --short-path, or for a branch whose worktree was created with it, the name
+// is a short hash instead (see shortDirName).
+func worktreeDirName(branch string) string {
+	branch = worktree.NormalizeBranch(branch)
+	if shortPath || hasShortPath(branch) {
+		return shortDirName(branch)
+	}
+	if !getConfig().AsciiSlug {
+		return branch
+	}
+	return asciiSlug(branch)
+}
+
+// asciiSlug strips diacritics from a branch name and percent-encodes any
+// remaining non-ASCII characters, e.g. "café/фича" becomes
+// "cafe/%D1%84%D0%B8%D1%87%D0%B0".
+func asciiSlug(branch string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(branch) {
+		switch {
+		case r <= unicode.MaxASCII:
+			b.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+			// Drop combining marks left over from decomposition.
+		default:
+			for _, c := range []byte(string(r)) {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestAsciiSlug(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"feature/login", "feature/login"},
+		{"feature/café", "feature/cafe"},
+		{norm.NFD.String("feature/café"), "feature/cafe"},
+		{"фича", "%D1%84%D0%B8%D1%87%D0%B0"},
+		{"修复", "%E4%BF%AE%E5%A4%8D"},
+		{"fix-🐛", "fix-%F0%9F%90%9B"},
+	}
+	for _, tt := range tests {
+		if got := asciiSlug(tt.branch); got != tt.want {
+			t.Errorf("asciiSlug(%q) = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestWorktreeDirName(t *testing.T) {
+	original := loadedConfig
+	t.Cleanup(func() {
+		loadedConfig = original
+	})
+
+	nfd := norm.NFD.String("ñandú")
+
+	loadedConfig = &Config{}
+	if got := worktreeDirName(nfd); got != "ñandú" {
+		t.Errorf("worktreeDirName() = %q, want NFC branch name", got)
+	}
+
+	loadedConfig = &Config{AsciiSlug: true}
+	if got := worktreeDirName(nfd); got != "nandu" {
+		t.Errorf("worktreeDirName() with asciiSlug = %q, want nandu", got)
+	}
+}
+
+func TestWorktreeExistsNormalizesBranch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature/café", filepath.Join(tmpDir, "cafe"))
+	t.Chdir(repoDir)
+
+	path, ok := worktreeExists(norm.NFD.String("feature/café"))
+	if !ok {
+		t.Fatal("worktreeExists() did not find the worktree using the NFD branch name")
+	}
+	if filepath.Base(path) != "cafe" {
+		t.Errorf("worktreeExists() path = %q", path)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/manifoldco/promptui"
+)
+
+// Pickers supported by the `picker` config key.
+const (
+	pickerBuiltin  = "builtin"
+	pickerFzf      = "fzf"
+	pickerExternal = "external"
+)
+
+// defaultFzfCommand hides the index prefix wt uses to map the selection back.
+const defaultFzfCommand = `fzf --prompt '{{.Label}}> ' --delimiter '\t' --with-nth 2..`
+
+var errSelectionCancelled = errors.New("selection cancelled")
+
+// pickerCommand returns the external picker command to run for label, or
+// false to use the builtin picker. A picker that is not installed falls back
+// to the builtin one with a warning.
+func pickerCommand(cfg *Config, label string) (string, bool) {
+	if cfg.Picker == "" || cfg.Picker == pickerBuiltin {
+		return "", false
+	}
+	command := cfg.PickerCommand
+	if command == "" {
+		command = defaultFzfCommand
+	}
+	rendered, err := renderPickerCommand(command, label)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: invalid pickerCommand: %v; using the builtin picker\n", err)
+		return "", false
+	}
+	fields := strings.Fields(rendered)
+	if len(fields) == 0 {
+		return "", false
+	}
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: picker %q not found; using the builtin picker\n", fields[0])
+		return "", false
+	}
+	return rendered, true
+}
+
+// selectBuiltin asks for one of items in promptui's menu, starting on the
+// item at cursor.
+func selectBuiltin(label string, items []string, cursor int) (int, error) {
+	prompt := promptui.Select{
+		Label:     label,
+		Items:     items,
+		CursorPos: cursor,
+		// The menu is not data: keep it off stdout.
+		Stdout: os.Stderr,
+		Searcher: func(input string, index int) bool {
+			return strings.Contains(strings.ToLower(items[index]), strings.ToLower(input))
+		},
+	}
+	idx, _, err := prompt.Run()
+	if err != nil {
+		return 0, errSelectionCancelled
+	}
+	return idx, nil
+}
+
+// renderPickerCommand expands the pickerCommand template; {{.Label}} is the
+// prompt label.
+func renderPickerCommand(command, label string) (string, error) {
+	tmpl, err := template.New("picker").Parse(command)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, struct{ Label string }{label}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// pickerInput prefixes every item with its index and a tab, one per line.
+func pickerInput(items []string) string {
+	var b strings.Builder
+	for i, item := range items {
+		fmt.Fprintf(&b, "%d\t%s\n", i, strings.ReplaceAll(item, "\n", " "))
+	}
+	return b.String()
+}
+
+// parsePickerOutput maps the line chosen in an external picker back to the
+// index of its item.
+func parsePickerOutput(output string, count int) (int, error) {
+	line := strings.TrimRight(output, "\r\n")
+	prefix, _, ok := strings.Cut(line, "\t")
+	if !ok {
+		return 0, errSelectionCancelled
+	}
+	idx, err := strconv.Atoi(strings.TrimSpace(prefix))
+	if err != nil || idx < 0 || idx >= count {
+		return 0, errSelectionCancelled
+	}
+	return idx, nil
+}
+
+// selectExternal runs command through the shell with the items on stdin.
+// Like promptui, a picker that exits non-zero counts as cancelled.
+func selectExternal(command string, items []string) (int, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdin = strings.NewReader(pickerInput(items))
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return 0, errSelectionCancelled
+	}
+	return parsePickerOutput(stdout.String(), len(items))
+}
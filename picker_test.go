@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestPickerInputRoundTrip(t *testing.T) {
+	items := []string{"main", "feature\tx", "#12: Fix\nbug"}
+	input := pickerInput(items)
+	if input != "0\tmain\n1\tfeature\tx\n2\t#12: Fix bug\n" {
+		t.Fatalf("pickerInput() = %q", input)
+	}
+
+	idx, err := parsePickerOutput("1\tfeature\tx\n", len(items))
+	if err != nil || idx != 1 {
+		t.Errorf("parsePickerOutput() = %d, %v, want 1", idx, err)
+	}
+	for _, output := range []string{"", "feature\n", "7\tmissing\n", "-1\tneg\n"} {
+		if _, err := parsePickerOutput(output, len(items)); !errors.Is(err, errSelectionCancelled) {
+			t.Errorf("parsePickerOutput(%q) error = %v, want cancellation", output, err)
+		}
+	}
+}
+
+func TestRenderPickerCommand(t *testing.T) {
+	got, err := renderPickerCommand(defaultFzfCommand, "Select branch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `fzf --prompt 'Select branch> ' --delimiter '\t' --with-nth 2..` {
+		t.Errorf("renderPickerCommand() = %q", got)
+	}
+	if _, err := renderPickerCommand("fzf {{.Nope", "x"); err == nil {
+		t.Error("renderPickerCommand() should reject invalid templates")
+	}
+}
+
+func TestPickerCommand(t *testing.T) {
+	if _, ok := pickerCommand(&Config{}, "x"); ok {
+		t.Error("pickerCommand() should use the builtin picker by default")
+	}
+	if _, ok := pickerCommand(&Config{Picker: pickerExternal, PickerCommand: "wt-missing-picker --x"}, "x"); ok {
+		t.Error("pickerCommand() should fall back when the picker is not installed")
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	got, ok := pickerCommand(&Config{Picker: pickerExternal, PickerCommand: "sh -c 'echo {{.Label}}'"}, "hi")
+	if !ok || got != "sh -c 'echo hi'" {
+		t.Errorf("pickerCommand() = %q, %v", got, ok)
+	}
+}
+
+func TestSelectExternal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("picker commands are run through sh")
+	}
+	items := []string{"main", "feature", "bugfix"}
+
+	idx, err := selectExternal("grep feature", items)
+	if err != nil || idx != 1 {
+		t.Errorf("selectExternal() = %d, %v, want 1", idx, err)
+	}
+	if _, err := selectExternal("exit 130", items); !errors.Is(err, errSelectionCancelled) {
+		t.Errorf("selectExternal() error = %v, want cancellation on non-zero exit", err)
+	}
+}
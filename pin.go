@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Pinned worktrees are marked on their branch with
+// branch.<name>.wt-pinned=true. Bulk cleanup never touches them, whatever
+// flags are given.
+func pinnedKey(branch string) string {
+	return "branch." + branch + ".wt-pinned"
+}
+
+// pinLockReason is the `git worktree lock` reason of worktrees locked by
+// `wt pin --lock`; `wt unpin` only unlocks worktrees with this reason.
+const pinLockReason = "pinned by wt"
+
+// isBranchPinned reports whether the worktree of branch is pinned.
+func isBranchPinned(dir, branch string) bool {
+	output, err := gitIn(dir, "config", "--bool", "--get", pinnedKey(branch)).Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// parsePinnedBranches parses `git config --get-regexp '^branch\..*\.wt-pinned$'`
+// into the set of pinned branches.
+func parsePinnedBranches(configOutput string) map[string]bool {
+	pinned := make(map[string]bool)
+	for _, line := range strings.Split(configOutput, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || value != "true" {
+			continue
+		}
+		branch := strings.TrimSuffix(strings.TrimPrefix(key, "branch."), ".wt-pinned")
+		if branch != key {
+			pinned[branch] = true
+		}
+	}
+	return pinned
+}
+
+// loadPinnedBranches returns the pinned branches of the repository.
+func loadPinnedBranches() map[string]bool {
+	output, _ := repoGit("config", "--get-regexp", `^branch\..*\.wt-pinned$`).Output()
+	return parsePinnedBranches(string(output))
+}
+
+// markPinned fills in the pinned flag of every worktree.
+func markPinned(infos []worktreeInfo, pinned map[string]bool) {
+	for i := range infos {
+		infos[i].Pinned = infos[i].Branch != "" && pinned[infos[i].Branch]
+	}
+}
+
+// pruneWorktrees runs `git worktree prune` without dropping pinned worktrees
+// whose directory is missing: those are locked for the duration of the
+// prune. Worktrees on offline volumes are kept locked unless includeOffline.
+// It returns how many pinned and offline worktrees were skipped.
+func pruneWorktrees(includeOffline bool) (int, int, error) {
+	plan, err := planPrune(includeOffline)
+	if err != nil {
+		return 0, 0, err
+	}
+	skipped, offlineSkipped := plan.skipped()
+	return skipped, offlineSkipped, applyCleanupPlan(plan)
+}
+
+// printPinnedSkipped tells how many pinned worktrees a bulk operation left alone.
+func printPinnedSkipped(skipped int) {
+	switch {
+	case skipped == 1:
+		infoln("Skipped 1 pinned worktree (see 'wt unpin')")
+	case skipped > 1:
+		infof("Skipped %d pinned worktrees (see 'wt unpin')\n", skipped)
+	}
+}
+
+var pinCmd = &cobra.Command{
+	Use:   "pin [branch]",
+	Short: "Protect a worktree from bulk cleanup",
+	Long: `Pin a worktree so that bulk cleanup never touches it.
+
+Pinned worktrees are skipped by 'wt prune' (and every other bulk operation
+that removes worktrees) unconditionally, even with --force. Pinning is
+recorded on the branch. With --lock the worktree is also locked with
+'git worktree lock', so that plain 'git worktree prune' respects it too.
+
+Without a branch, the worktree containing the current directory is pinned.
+
+Examples:
+  wt pin scratch          # Keep the scratch worktree forever
+  wt pin --lock           # Pin and lock the current worktree
+  wt unpin scratch        # Let cleanup consider scratch again`,
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lock, _ := cmd.Flags().GetBool("lock")
+		if lock {
+			if err := requireGit(capWorktreeLock); err != nil {
+				return err
+			}
+		}
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		wt, err := selectWorktree(worktrees, branchArgs(args))
+		if err != nil {
+			return err
+		}
+		if wt.Branch == "" {
+			return fmt.Errorf("cannot pin %s: pinning needs a branch, and its HEAD is detached", wt.Path)
+		}
+
+		if err := repoGit("config", pinnedKey(wt.Branch), "true").Run(); err != nil {
+			return fmt.Errorf("failed to pin %s: %w", wt.Branch, err)
+		}
+		if lock && !wt.Locked {
+			if output, err := repoGit("worktree", "lock", "--reason", pinLockReason, wt.Path).CombinedOutput(); err != nil {
+				return fmt.Errorf("pinned %s but failed to lock it: %s", wt.Branch, strings.TrimSpace(string(output)))
+			}
+		}
+		infof("✓ Pinned %s: %s\n", wt.Branch, displayPath(wt.Path))
+		return nil
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin [branch]",
+	Short: "Let bulk cleanup consider a worktree again",
+	Long: `Remove the pin of a worktree. A lock taken by 'wt pin --lock' is released
+as well; locks set by hand are left alone.
+
+Without a branch, the worktree containing the current directory is unpinned.`,
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		wt, err := selectWorktree(worktrees, branchArgs(args))
+		if err != nil {
+			return err
+		}
+		if wt.Branch == "" || !isBranchPinned("", wt.Branch) {
+			infof("%s is not pinned\n", wt.Path)
+			return nil
+		}
+
+		if err := repoGit("config", "--unset", pinnedKey(wt.Branch)).Run(); err != nil {
+			return fmt.Errorf("failed to unpin %s: %w", wt.Branch, err)
+		}
+		if wt.Locked && wt.LockReason == pinLockReason {
+			_ = repoGit("worktree", "unlock", wt.Path).Run()
+		}
+		infof("✓ Unpinned %s: %s\n", wt.Branch, displayPath(wt.Path))
+		return nil
+	},
+}
+
+func init() {
+	pinCmd.Flags().Bool("lock", false, "Also lock the worktree with 'git worktree lock'")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePinnedBranches(t *testing.T) {
+	output := "branch.scratch.wt-pinned true\nbranch.release/signing.wt-pinned true\nbranch.old.wt-pinned false\n"
+	want := map[string]bool{"scratch": true, "release/signing": true}
+	if got := parsePinnedBranches(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePinnedBranches() = %v, want %v", got, want)
+	}
+}
+
+func TestPinnedInListings(t *testing.T) {
+	infos := newWorktreeInfos([]Worktree{
+		{Path: "/src/api", Branch: "main"},
+		{Path: "/trees/api/scratch", Branch: "scratch"},
+		{Path: "/trees/api/review", Detached: true},
+	})
+	markPinned(infos, map[string]bool{"scratch": true})
+	if infos[0].Pinned || !infos[1].Pinned || infos[2].Pinned {
+		t.Fatalf("markPinned() = %+v", infos)
+	}
+
+	if got := porcelainFlags(infos[1]); got != "pinned" {
+		t.Errorf("porcelainFlags() = %q, want pinned", got)
+	}
+	var table strings.Builder
+	printWorktreeTable(&table, infos)
+	if !strings.Contains(table.String(), "[scratch] 📌 pinned") {
+		t.Errorf("table does not show the pin:\n%s", table.String())
+	}
+	if got := treeGlyphs(&infos[1], false); got != "📌" {
+		t.Errorf("treeGlyphs() = %q, want 📌", got)
+	}
+}
+
+func TestPruneWorktreesSkipsPinned(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	scratch := filepath.Join(tmpDir, "scratch")
+	gone := filepath.Join(tmpDir, "gone")
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "scratch", scratch)
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "gone", gone)
+	runGitCommand(t, repoDir, "config", pinnedKey("scratch"), "true")
+	for _, dir := range []string{scratch, gone} {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(repoDir)
+
+	skipped, _, err := pruneWorktrees(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 1 {
+		t.Errorf("pruneWorktrees() skipped %d, want 1", skipped)
+	}
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var branches []string
+	for _, wt := range worktrees {
+		branches = append(branches, wt.Branch)
+		if wt.Branch == "scratch" && wt.Locked {
+			t.Error("the pinned worktree should only be locked during the prune")
+		}
+	}
+	if want := []string{"main", "scratch"}; !reflect.DeepEqual(branches, want) {
+		t.Errorf("worktrees after prune = %v, want %v", branches, want)
+	}
+}
+
+func TestPruneCommandReportsFailure(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+	t.Chdir(dir)
+
+	if err := pruneCmd.RunE(pruneCmd, nil); err == nil {
+		t.Error("wt prune outside a repository should fail")
+	}
+}
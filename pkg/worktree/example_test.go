@@ -0,0 +1,41 @@
+package worktree_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+func ExampleManager_Create() {
+	m := &worktree.Manager{
+		Root:   "/home/me/dev/worktrees",
+		Repo:   "api",
+		Stderr: os.Stderr,
+	}
+	path, err := m.Create("feature-x", "main")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("created", path)
+}
+
+func ExampleManager_List() {
+	m := &worktree.Manager{Dir: "/home/me/src/api"}
+	worktrees, err := m.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, wt := range worktrees {
+		fmt.Println(wt.Branch, wt.Path)
+	}
+}
+
+func ExampleParsePorcelain() {
+	output := "worktree /src/api\nHEAD 1111111111111111111111111111111111111111\nbranch refs/heads/main\n"
+	for _, wt := range worktree.ParsePorcelain(output) {
+		fmt.Println(wt.Branch, wt.Head[:7])
+	}
+	// Output: main 1111111
+}
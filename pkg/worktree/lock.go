@@ -0,0 +1,220 @@
+package worktree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LockScope selects what Manager serializes, across processes, while it adds
+// a worktree. Processes sharing a clone, such as CI matrix jobs, otherwise
+// race on the worktree directory and on git's own lock files.
+type LockScope string
+
+const (
+	// LockNone takes no lock.
+	LockNone LockScope = ""
+	// LockRepo lets one worktree be added to the repository at a time.
+	LockRepo LockScope = "repo"
+	// LockBranch only serializes adds of the same branch; different branches
+	// are added in parallel.
+	LockBranch LockScope = "branch"
+)
+
+// ParseLockScope parses "repo" or "branch" ("" and "none" mean LockNone).
+func ParseLockScope(s string) (LockScope, error) {
+	switch s {
+	case "", "none":
+		return LockNone, nil
+	case string(LockRepo), string(LockBranch):
+		return LockScope(s), nil
+	}
+	return LockNone, fmt.Errorf("invalid lock scope %q (use repo, branch or none)", s)
+}
+
+// retryDelay is the longest pause before retrying a failed add, picked at
+// random so that colliding processes do not collide again.
+var retryDelay = 500 * time.Millisecond
+
+// lockPollMin and lockPollMax bound the backoff between attempts to take a
+// lock another process holds.
+var lockPollMin, lockPollMax = 50 * time.Millisecond, time.Second
+
+// lockHolder is what the holder of a lock records in the lock file: waiting
+// processes show it, and finding it when taking the lock means the previous
+// holder died without releasing it.
+type lockHolder struct {
+	PID       int       `json:"pid"`
+	Operation string    `json:"operation,omitempty"`
+	Started   time.Time `json:"started"`
+}
+
+// readLockHolder returns the holder recorded in the lock file at path, if
+// any.
+func readLockHolder(path string) (lockHolder, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return lockHolder{}, false
+	}
+	var holder lockHolder
+	if err := json.Unmarshal(data, &holder); err != nil || holder.PID == 0 {
+		return lockHolder{}, false
+	}
+	return holder, true
+}
+
+// String describes the holder, e.g. "pid 1234: 'create feature-x'".
+func (h lockHolder) String() string {
+	s := fmt.Sprintf("pid %d", h.PID)
+	if h.Operation != "" {
+		s += ": '" + h.Operation + "'"
+	}
+	return s
+}
+
+// describeHolder describes the holder of the lock file at path as a waiting
+// process sees it.
+func describeHolder(path string) string {
+	holder, ok := readLockHolder(path)
+	if !ok {
+		return "another wt operation"
+	}
+	return fmt.Sprintf("another wt operation (%s, running %s)", holder, time.Since(holder.Started).Round(time.Second))
+}
+
+// lockKey returns the name of the lock file for adding branch.
+func (m *Manager) lockKey(branch string) string {
+	if m.Lock == LockBranch {
+		return fmt.Sprintf("branch-%x.lock", sha256.Sum256([]byte(NormalizeBranch(branch))))
+	}
+	return "repo.lock"
+}
+
+// lock takes the advisory lock for adding branch, waiting for other holders,
+// and returns the function releasing it. The lock files live in wt-locks in
+// the common git dir and are never removed, which would race with waiters.
+func (m *Manager) lock(branch string) (func(), error) {
+	if m.Lock == LockNone {
+		return func() {}, nil
+	}
+	output, err := m.git().Output(m.Dir, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the git directory: %w", err)
+	}
+	commonDir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(commonDir) {
+		dir := m.Dir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		commonDir = filepath.Join(dir, commonDir)
+	}
+	lockDir := filepath.Join(commonDir, "wt-locks")
+	if err := os.MkdirAll(lockDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	path := filepath.Join(lockDir, m.lockKey(branch))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := m.waitForLock(f, path); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if stale, ok := readLockHolder(path); ok && m.Stderr != nil {
+		fmt.Fprintf(m.Stderr, "Breaking a stale lock of %s, started %s: the process is gone\n", stale, stale.Started.Local().Format(time.DateTime))
+	}
+	record, _ := json.Marshal(lockHolder{PID: os.Getpid(), Operation: m.Operation, Started: time.Now()})
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt(record, 0)
+	}
+	return func() {
+		// Clear the record first: closing the file releases the lock.
+		_ = f.Truncate(0)
+		f.Close()
+	}, nil
+}
+
+// waitForLock takes the lock on f, polling with backoff while another
+// process holds it. The wait is announced on m.Stderr, again whenever the
+// lock changes hands, and given up after m.LockTimeout.
+func (m *Manager) waitForLock(f *os.File, path string) error {
+	start := time.Now()
+	delay := lockPollMin
+	announced, waiting := lockHolder{}, false
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			return fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if ok {
+			return nil
+		}
+		waited := time.Since(start)
+		if m.LockTimeout > 0 && waited >= m.LockTimeout {
+			return fmt.Errorf("%w: %s still holds %s after %s", ErrLockTimeout, describeHolder(path), path, m.LockTimeout)
+		}
+		if holder, _ := readLockHolder(path); m.Stderr != nil && (!waiting || holder != announced) {
+			fmt.Fprintf(m.Stderr, "Waiting for %s…\n", describeHolder(path))
+			announced, waiting = holder, true
+		}
+		if m.LockTimeout > 0 {
+			delay = min(delay, m.LockTimeout-waited)
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, lockPollMax)
+	}
+}
+
+// add runs `git worktree add` through attempt, which is given whether it is
+// the retry. With a lock scope, a failed add is retried once, as concurrent
+// adds to one repository can collide on git's lock files: unless another
+// process added the worktree of branch meanwhile, wt waits a moment, prunes
+// stale worktree entries, removes an empty directory left at path and tries
+// again. git's output of the first attempt is held back and only shown when
+// it succeeds, so a failure is reported once.
+func (m *Manager) add(branch, path string, attempt func(stderr *bytes.Buffer, retry bool) error) (string, error) {
+	if m.Lock == LockNone {
+		if err := attempt(nil, false); err != nil {
+			return "", fmt.Errorf("failed to create worktree: %w", err)
+		}
+		return path, nil
+	}
+	var held bytes.Buffer
+	err := attempt(&held, false)
+	if err == nil {
+		if m.Stderr != nil {
+			_, _ = held.WriteTo(m.Stderr)
+		}
+		return path, nil
+	}
+	if wt, ok := m.Find(branch); ok {
+		return wt.Path, nil
+	}
+	time.Sleep(time.Duration(rand.Int64N(int64(retryDelay) + 1)))
+	_ = m.git().Run(m.Dir, nil, nil, "worktree", "prune")
+	if entries, err := os.ReadDir(path); err == nil && len(entries) == 0 {
+		_ = os.Remove(path)
+	}
+	if err := attempt(nil, true); err != nil {
+		return "", fmt.Errorf("failed to create worktree: %w", err)
+	}
+	return path, nil
+}
+
+// runTo runs git with its error output going to stderr instead of m.Stderr
+// when stderr is not nil.
+func (m *Manager) runTo(stderr *bytes.Buffer, args ...string) error {
+	if stderr == nil {
+		return m.run(args...)
+	}
+	return m.git().Run(m.Dir, m.Stdout, stderr, args...)
+}
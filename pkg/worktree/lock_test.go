@@ -0,0 +1,292 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+func TestParseLockScope(t *testing.T) {
+	for input, want := range map[string]LockScope{"": LockNone, "none": LockNone, "repo": LockRepo, "branch": LockBranch} {
+		if got, err := ParseLockScope(input); err != nil || got != want {
+			t.Errorf("ParseLockScope(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseLockScope("global"); err == nil {
+		t.Error("ParseLockScope(global) should fail")
+	}
+}
+
+func TestLockKey(t *testing.T) {
+	m := &Manager{Lock: LockBranch}
+	if m.lockKey("job-1") == m.lockKey("job-2") {
+		t.Error("different branches share a lock with LockBranch")
+	}
+	if m.lockKey("feature/x") != m.lockKey("feature/x") || strings.Contains(m.lockKey("feature/x"), "/") {
+		t.Errorf("lockKey(feature/x) = %q, want a stable file name", m.lockKey("feature/x"))
+	}
+	m.Lock = LockRepo
+	if m.lockKey("job-1") != m.lockKey("job-2") {
+		t.Error("branches have separate locks with LockRepo")
+	}
+}
+
+// TestConcurrentCreate adds worktrees from many goroutines at once, the way
+// CI matrix jobs sharing a clone do, and checks that every one ends up with
+// its own branch checked out.
+func TestConcurrentCreate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping concurrent git test in short mode")
+	}
+
+	for _, scope := range []LockScope{LockBranch, LockRepo} {
+		t.Run(string(scope), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			repoDir := filepath.Join(tmpDir, "repo")
+			if err := testrepo.Init(repoDir); err != nil {
+				t.Fatal(err)
+			}
+			root := filepath.Join(tmpDir, "worktrees")
+			newManager := func() *Manager {
+				return &Manager{Root: root, Repo: "repo", Dir: repoDir, NoRemotes: true, Lock: scope}
+			}
+
+			const jobs = 12
+			paths := make([]string, jobs)
+			errs := make([]error, jobs)
+			var wg sync.WaitGroup
+			for i := range jobs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					paths[i], errs[i] = newManager().Create(fmt.Sprintf("job-%d", i), "main")
+				}(i)
+			}
+			// Racing adds of one branch must all get the same worktree.
+			shared := make([]string, 4)
+			sharedErrs := make([]error, len(shared))
+			for i := range shared {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					shared[i], sharedErrs[i] = newManager().Create("shared", "main")
+				}(i)
+			}
+			wg.Wait()
+
+			for i := range jobs {
+				if errs[i] != nil {
+					t.Errorf("Create(job-%d) failed: %v", i, errs[i])
+					continue
+				}
+				if want := filepath.Join(root, "repo", fmt.Sprintf("job-%d", i)); paths[i] != want {
+					t.Errorf("Create(job-%d) = %s, want %s", i, paths[i], want)
+				}
+				output, err := exec.Command("git", "-C", paths[i], "rev-parse", "--abbrev-ref", "HEAD").Output()
+				if got := strings.TrimSpace(string(output)); err != nil || got != fmt.Sprintf("job-%d", i) {
+					t.Errorf("%s has %q checked out (%v), want job-%d", paths[i], got, err, i)
+				}
+			}
+			for i := range shared {
+				if sharedErrs[i] != nil || shared[i] != filepath.Join(root, "repo", "shared") {
+					t.Errorf("Create(shared) = %s, %v", shared[i], sharedErrs[i])
+				}
+			}
+			worktrees, err := newManager().List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(worktrees) != jobs+2 {
+				t.Errorf("%d worktrees registered, want %d", len(worktrees), jobs+2)
+			}
+		})
+	}
+}
+
+// flakyRunner fails the first add of a worktree, as when another process
+// holds one of git's lock files.
+type flakyRunner struct {
+	*fakeRunner
+	failed bool
+}
+
+func (f *flakyRunner) Run(dir string, stdout, stderr io.Writer, args ...string) error {
+	if !f.failed && len(args) > 1 && args[1] == "add" {
+		f.failed = true
+		f.fakeRunner.calls = append(f.fakeRunner.calls, strings.Join(args, " "))
+		fmt.Fprintln(stderr, "fatal: Unable to create '.git/config.lock': File exists.")
+		return errors.New("exit status 128")
+	}
+	return f.fakeRunner.Run(dir, stdout, stderr, args...)
+}
+
+func TestManagerCreateRetry(t *testing.T) {
+	oldDelay := retryDelay
+	retryDelay = 0
+	t.Cleanup(func() { retryDelay = oldDelay })
+
+	root := t.TempDir()
+	fake := newFake()
+	fake.outputs["rev-parse --git-common-dir"] = filepath.Join(root, "git") + "\n"
+	flaky := &flakyRunner{fakeRunner: fake}
+	var stderr strings.Builder
+	m := &Manager{Root: root, Repo: "repo", Git: flaky, Stderr: &stderr, Lock: LockBranch}
+
+	path, err := m.Create("y", "main")
+	if err != nil {
+		t.Fatalf("Create() after a transient failure: %v", err)
+	}
+	add := "worktree add " + path + " -b y main"
+	if n := strings.Count(strings.Join(fake.calls, "\n"), add); n != 2 || !fake.called("worktree prune") {
+		t.Errorf("Create() ran %v, want the add retried after a prune", fake.calls)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("the output of the failed attempt was shown: %q", stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(root, "git", "wt-locks", m.lockKey("y"))); err != nil {
+		t.Errorf("lock file not created: %v", err)
+	}
+}
+
+// blockingRunner holds up `git worktree add` until release is closed, like a
+// long fetch or submodule init, and closes adding when it gets there.
+type blockingRunner struct {
+	*fakeRunner
+	adding, release chan struct{}
+}
+
+func (b *blockingRunner) Run(dir string, stdout, stderr io.Writer, args ...string) error {
+	if len(args) > 1 && args[1] == "add" {
+		close(b.adding)
+		<-b.release
+	}
+	return b.fakeRunner.Run(dir, stdout, stderr, args...)
+}
+
+// syncBuilder is a strings.Builder safe to write from several goroutines.
+type syncBuilder struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (s *syncBuilder) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuilder) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+// TestLockContention runs a Create that holds the repository lock while a
+// second one waits for it, first with a timeout and then without.
+func TestLockContention(t *testing.T) {
+	oldMin, oldMax := lockPollMin, lockPollMax
+	lockPollMin, lockPollMax = time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { lockPollMin, lockPollMax = oldMin, oldMax })
+
+	root := t.TempDir()
+	newManager := func(git Runner, stderr io.Writer) *Manager {
+		return &Manager{Root: root, Repo: "repo", Git: git, Stderr: stderr, Lock: LockRepo}
+	}
+	newGit := func() *fakeRunner {
+		fake := newFake()
+		fake.outputs["rev-parse --git-common-dir"] = filepath.Join(root, "git") + "\n"
+		return fake
+	}
+	lockPath := filepath.Join(root, "git", "wt-locks", "repo.lock")
+
+	holderGit := &blockingRunner{fakeRunner: newGit(), adding: make(chan struct{}), release: make(chan struct{})}
+	holder := newManager(holderGit, io.Discard)
+	holder.Operation = "create feature-x"
+	holderDone := make(chan error, 1)
+	go func() {
+		_, err := holder.Create("feature-x", "main")
+		holderDone <- err
+	}()
+	<-holderGit.adding
+
+	var stderr syncBuilder
+	impatient := newManager(newGit(), &stderr)
+	impatient.LockTimeout = 100 * time.Millisecond
+	impatientDone := make(chan error, 1)
+	go func() {
+		_, err := impatient.Create("feature-y", "main")
+		impatientDone <- err
+	}()
+	err := <-impatientDone
+	if !errors.Is(err, ErrLockTimeout) || !strings.Contains(err.Error(), "'create feature-x'") {
+		t.Errorf("Create() while the lock is held = %v, want ErrLockTimeout naming the holder", err)
+	}
+	want := fmt.Sprintf("Waiting for another wt operation (pid %d: 'create feature-x', running ", os.Getpid())
+	if !strings.Contains(stderr.String(), want) {
+		t.Errorf("waiting printed %q, want %q", stderr.String(), want)
+	}
+
+	patient := newManager(newGit(), io.Discard)
+	patientDone := make(chan error, 1)
+	go func() {
+		_, err := patient.Create("feature-z", "main")
+		patientDone <- err
+	}()
+	select {
+	case err := <-patientDone:
+		t.Fatalf("Create() without a timeout returned %v while the lock is held", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(holderGit.release)
+	if err := <-holderDone; err != nil {
+		t.Errorf("Create() holding the lock: %v", err)
+	}
+	if err := <-patientDone; err != nil {
+		t.Errorf("Create() after the lock was released: %v", err)
+	}
+	if data, err := os.ReadFile(lockPath); err != nil || len(data) != 0 {
+		t.Errorf("lock file after the releases = %q, %v; want it empty", data, err)
+	}
+}
+
+func TestBreakStaleLock(t *testing.T) {
+	root := t.TempDir()
+	fake := newFake()
+	fake.outputs["rev-parse --git-common-dir"] = filepath.Join(root, "git") + "\n"
+	var stderr strings.Builder
+	m := &Manager{Root: root, Repo: "repo", Git: fake, Stderr: &stderr, Lock: LockRepo}
+
+	// A holder that died keeps its record, but not the lock.
+	lockPath := filepath.Join(root, "git", "wt-locks", "repo.lock")
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockPath, []byte(`{"pid":99999,"operation":"create crashed","started":"2026-01-02T03:04:05Z"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := m.lock("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stderr.String(); !strings.Contains(got, "stale lock of pid 99999: 'create crashed'") {
+		t.Errorf("breaking the stale lock printed %q", got)
+	}
+	holder, ok := readLockHolder(lockPath)
+	if !ok || holder.PID != os.Getpid() {
+		t.Errorf("lock file records %+v, %v; want this process", holder, ok)
+	}
+	unlock()
+	if _, ok := readLockHolder(lockPath); ok {
+		t.Error("the record was left after unlocking")
+	}
+}
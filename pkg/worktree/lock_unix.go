@@ -0,0 +1,25 @@
+//go:build !windows
+
+package worktree
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile takes an exclusive lock on f, held until f is closed, and
+// reports false without waiting when another file holds it.
+func tryLockFile(f *os.File) (bool, error) {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, syscall.EWOULDBLOCK):
+			return false, nil
+		case err != syscall.EINTR:
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package worktree
+
+import (
+	"errors"
+	"math"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// tryLockFile takes an exclusive lock on f, held until f is closed, and
+// reports false without waiting when another file holds it. Windows locks
+// are mandatory, so the locked byte lies far beyond the holder's record,
+// which waiting processes read.
+func tryLockFile(f *os.File) (bool, error) {
+	ol := windows.Overlapped{Offset: math.MaxUint32}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,34 @@
+package worktree
+
+import (
+	"runtime"
+	"strings"
+)
+
+// LongPath returns path in a form Windows accepts beyond MAX_PATH when
+// directories are created: an absolute path gets the \\?\ prefix (\\?\UNC\
+// for a share). Go's os package adds it as well for most absolute paths, but
+// not, e.g., for paths it cannot clean without changing their meaning. On
+// other systems, and for relative paths, path is returned unchanged.
+func LongPath(path string) string {
+	if runtime.GOOS != "windows" {
+		return path
+	}
+	return extendedLengthPath(path)
+}
+
+// extendedLengthPath adds the \\?\ prefix to an absolute Windows path. The
+// prefix turns off the normalization of the Win32 API, so slashes are turned
+// into backslashes first.
+func extendedLengthPath(path string) string {
+	p := strings.ReplaceAll(path, "/", `\`)
+	switch {
+	case strings.HasPrefix(p, `\\?\`), strings.HasPrefix(p, `\\.\`):
+		return p
+	case strings.HasPrefix(p, `\\`):
+		return `\\?\UNC\` + p[2:]
+	case len(p) >= 3 && p[1] == ':' && p[2] == '\\':
+		return `\\?\` + p
+	}
+	return path
+}
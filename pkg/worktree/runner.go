@@ -0,0 +1,152 @@
+package worktree
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// Runner executes git commands. The default ExecRunner runs the git binary;
+// tests substitute a fake.
+type Runner interface {
+	// Output runs git in dir and returns its standard output.
+	Output(dir string, args ...string) ([]byte, error)
+	// Run runs git in dir, streaming its output to stdout and stderr (either
+	// may be nil to discard it).
+	Run(dir string, stdout, stderr io.Writer, args ...string) error
+}
+
+// ContextRunner is implemented by runners that can stop a command when a
+// context is done. Manager uses it to bound fetches with a timeout; with
+// runners lacking it, fetches are not bounded.
+type ContextRunner interface {
+	// RunContext is Run, killing git when ctx is done.
+	RunContext(ctx context.Context, dir string, stdout, stderr io.Writer, args ...string) error
+}
+
+// runContext runs git through r, bounded by ctx when r supports it.
+func runContext(ctx context.Context, r Runner, dir string, stdout, stderr io.Writer, args ...string) error {
+	if cr, ok := r.(ContextRunner); ok {
+		return cr.RunContext(ctx, dir, stdout, stderr, args...)
+	}
+	return r.Run(dir, stdout, stderr, args...)
+}
+
+// ExecRunner runs the git binary found in PATH.
+type ExecRunner struct{}
+
+// Output implements Runner.
+func (ExecRunner) Output(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd.Output()
+}
+
+// Run implements Runner.
+func (r ExecRunner) Run(dir string, stdout, stderr io.Writer, args ...string) error {
+	return r.RunContext(context.Background(), dir, stdout, stderr, args...)
+}
+
+// RunContext implements ContextRunner.
+func (ExecRunner) RunContext(ctx context.Context, dir string, stdout, stderr io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// DefaultQuietLimit is the number of bytes QuietRunner keeps per stream.
+const DefaultQuietLimit = 1 << 20
+
+// QuietRunner holds back the output of Run and only replays it, stdout and
+// stderr separately, when git fails. Successful commands print nothing.
+type QuietRunner struct {
+	// Runner runs the commands. Nil means ExecRunner.
+	Runner Runner
+	// Limit caps the bytes kept per stream; only the last Limit bytes are
+	// replayed. Zero means DefaultQuietLimit.
+	Limit int
+}
+
+func (q QuietRunner) runner() Runner {
+	if q.Runner == nil {
+		return ExecRunner{}
+	}
+	return q.Runner
+}
+
+// Output implements Runner.
+func (q QuietRunner) Output(dir string, args ...string) ([]byte, error) {
+	return q.runner().Output(dir, args...)
+}
+
+// Run implements Runner.
+func (q QuietRunner) Run(dir string, stdout, stderr io.Writer, args ...string) error {
+	return q.RunContext(context.Background(), dir, stdout, stderr, args...)
+}
+
+// RunContext implements ContextRunner.
+func (q QuietRunner) RunContext(ctx context.Context, dir string, stdout, stderr io.Writer, args ...string) error {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultQuietLimit
+	}
+	var out, errOut *tailBuffer
+	var outW, errW io.Writer
+	if stdout != nil {
+		out = &tailBuffer{limit: limit}
+		outW = out
+	}
+	if stderr != nil {
+		errOut = &tailBuffer{limit: limit}
+		errW = errOut
+	}
+	err := runContext(ctx, q.runner(), dir, outW, errW, args...)
+	if err != nil {
+		if out != nil {
+			out.replay(stdout)
+		}
+		if errOut != nil {
+			errOut.replay(stderr)
+		}
+	}
+	return err
+}
+
+// tailBuffer keeps the last limit bytes written to it and counts the rest.
+type tailBuffer struct {
+	limit   int
+	data    []byte
+	dropped int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	// Trim only once twice the limit is buffered, so long outputs are not
+	// copied on every write.
+	if len(b.data) > 2*b.limit {
+		drop := len(b.data) - b.limit
+		b.dropped += drop
+		b.data = append(b.data[:0], b.data[drop:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) replay(w io.Writer) {
+	data := b.data
+	dropped := b.dropped
+	if len(data) > b.limit {
+		dropped += len(data) - b.limit
+		data = data[len(data)-b.limit:]
+	}
+	if dropped > 0 {
+		fmt.Fprintf(w, "[%d bytes of earlier git output truncated]\n", dropped)
+	}
+	_, _ = w.Write(data)
+}
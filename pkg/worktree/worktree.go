@@ -0,0 +1,446 @@
+// Package worktree manages git worktrees laid out as <root>/<repo>/<branch>.
+//
+// It is the engine behind the wt command line tool and can be embedded in
+// other programs that want the same layout without shelling out to wt:
+//
+//	m := &worktree.Manager{Root: "/home/me/dev/worktrees", Repo: "api"}
+//	path, err := m.Create("feature-x", "main")
+package worktree
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// ErrNotFound is returned when no worktree exists for a branch.
+	ErrNotFound = errors.New("no worktree found")
+	// ErrBranchNotFound is returned when checking out a branch that exists
+	// neither locally nor on origin.
+	ErrBranchNotFound = errors.New("branch does not exist")
+	// ErrMainWorktree is returned when trying to remove the main worktree.
+	ErrMainWorktree = errors.New("cannot remove the main worktree")
+	// ErrLockTimeout is returned when another process held the lock for
+	// longer than Manager.LockTimeout.
+	ErrLockTimeout = errors.New("timed out waiting for another wt operation")
+)
+
+// Worktree is a single entry of `git worktree list --porcelain`.
+type Worktree struct {
+	Path       string
+	Head       string
+	Branch     string // short branch name, empty when detached or bare
+	Bare       bool
+	Detached   bool
+	Locked     bool
+	LockReason string // reason given to `git worktree lock`, if any
+	Prunable   bool
+}
+
+// Manager creates, lists and removes the worktrees of one repository.
+type Manager struct {
+	// Root is the directory holding the worktrees of all repositories.
+	Root string
+	// Repo is the name of the repository's directory below Root.
+	Repo string
+	// Dir is a directory inside the repository that git runs in. Empty means
+	// the current directory.
+	Dir string
+	// Git runs git commands. Nil means ExecRunner.
+	Git Runner
+	// Stdout and Stderr receive git's own output. Nil discards it.
+	Stdout, Stderr io.Writer
+	// DirName maps a branch to its directory name below Root/Repo. Nil uses
+	// the NFC-normalized branch name.
+	DirName func(branch string) string
+	// NoRemotes skips the lookup of branches on origin, for repositories
+	// without remotes.
+	NoRemotes bool
+	// Lock serializes Checkout and Create across processes and retries a
+	// failed add once. The zero value takes no lock and does not retry.
+	Lock LockScope
+	// LockTimeout is how long Checkout and Create wait for another holder of
+	// the lock before failing with ErrLockTimeout. Zero waits indefinitely.
+	LockTimeout time.Duration
+	// Operation describes the caller, e.g. "create feature-x", to the
+	// processes waiting for its lock.
+	Operation string
+}
+
+// CheckoutRefOptions controls how Manager.CheckoutRefWithOptions fetches.
+type CheckoutRefOptions struct {
+	// NoFetch uses the local branch as is when it exists. A missing branch
+	// is fetched anyway.
+	NoFetch bool
+	// Offline never fetches; the branch must exist locally.
+	Offline bool
+	// FetchTimeout stops the fetch after this long, when the runner is a
+	// ContextRunner. Zero means no limit.
+	FetchTimeout time.Duration
+}
+
+// RefCheckout is the outcome of Manager.CheckoutRefWithOptions.
+type RefCheckout struct {
+	Path string
+	// Existed is true when the branch already had a worktree; nothing was
+	// fetched then.
+	Existed bool
+	// Fetched is true when the branch was updated from origin.
+	Fetched bool
+	// FetchErr is the error of a failed fetch when the local branch was
+	// used instead.
+	FetchErr error
+}
+
+// RemoveOptions controls Manager.Remove.
+type RemoveOptions struct {
+	// Force removes the worktree even if it has uncommitted changes.
+	Force bool
+}
+
+// NormalizeBranch returns the NFC form of a branch name. Filesystems such as
+// APFS may hand back decomposed (NFD) names, so comparisons and path
+// computations use the composed form.
+func NormalizeBranch(branch string) string {
+	return norm.NFC.String(branch)
+}
+
+// SameBranch reports whether two branch names are equal after normalization.
+func SameBranch(a, b string) bool {
+	return NormalizeBranch(a) == NormalizeBranch(b)
+}
+
+// ParsePorcelain parses the output of `git worktree list --porcelain`.
+func ParsePorcelain(output string) []Worktree {
+	var worktrees []Worktree
+	var current *Worktree
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			current = nil
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		if key == "worktree" {
+			worktrees = append(worktrees, Worktree{Path: filepath.Clean(value)})
+			current = &worktrees[len(worktrees)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		switch key {
+		case "HEAD":
+			current.Head = value
+		case "branch":
+			current.Branch = strings.TrimPrefix(value, "refs/heads/")
+		case "bare":
+			current.Bare = true
+		case "detached":
+			current.Detached = true
+		case "locked":
+			current.Locked = true
+			current.LockReason = value
+		case "prunable":
+			current.Prunable = true
+		}
+	}
+	return worktrees
+}
+
+func (m *Manager) git() Runner {
+	if m.Git == nil {
+		return ExecRunner{}
+	}
+	return m.Git
+}
+
+func (m *Manager) run(args ...string) error {
+	return m.git().Run(m.Dir, m.Stdout, m.Stderr, args...)
+}
+
+// Path returns where the worktree for branch lives (or would be created).
+func (m *Manager) Path(branch string) string {
+	name := NormalizeBranch(branch)
+	if m.DirName != nil {
+		name = m.DirName(branch)
+	}
+	return filepath.Join(m.Root, m.Repo, name)
+}
+
+// EnsurePath creates the repository directory below Root if needed and
+// returns the worktree path for branch.
+func (m *Manager) EnsurePath(branch string) (string, error) {
+	targetRoot := filepath.Join(m.Root, m.Repo)
+
+	info, err := os.Stat(targetRoot)
+	switch {
+	case err == nil:
+		if !info.IsDir() {
+			return "", fmt.Errorf("WORKTREE_ROOT path %s is not a directory", targetRoot)
+		}
+	case os.IsNotExist(err):
+		if err := os.MkdirAll(LongPath(targetRoot), 0o755); err != nil {
+			return "", fmt.Errorf("failed to create WORKTREE_ROOT directory %s: %w", targetRoot, err)
+		}
+	default:
+		return "", fmt.Errorf("failed to access WORKTREE_ROOT directory %s: %w", targetRoot, err)
+	}
+
+	return m.Path(branch), nil
+}
+
+// List returns all worktrees of the repository, the main worktree first.
+func (m *Manager) List() ([]Worktree, error) {
+	output, err := m.git().Output(m.Dir, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %w", err)
+	}
+	worktrees := ParsePorcelain(string(output))
+	if len(worktrees) > 0 {
+		worktrees[0].Path = m.mainCheckout(worktrees[0])
+	}
+	return worktrees, nil
+}
+
+// mainCheckout returns the directory of the main worktree main. git lists a
+// submodule's main worktree as its git dir in the superproject's
+// .git/modules; the checkout is where the git dir's core.worktree points.
+func (m *Manager) mainCheckout(main Worktree) string {
+	if main.Bare {
+		return main.Path
+	}
+	if _, err := os.Lstat(filepath.Join(main.Path, ".git")); err == nil {
+		return main.Path
+	}
+	if _, err := os.Stat(filepath.Join(main.Path, "HEAD")); err != nil {
+		return main.Path
+	}
+	output, err := m.git().Output(main.Path, "config", "--get", "core.worktree")
+	if err != nil {
+		return main.Path
+	}
+	checkout := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(checkout) {
+		checkout = filepath.Join(main.Path, checkout)
+	}
+	return filepath.Clean(checkout)
+}
+
+// Find returns the worktree that has branch checked out.
+func (m *Manager) Find(branch string) (Worktree, bool) {
+	if branch == "" {
+		return Worktree{}, false
+	}
+	worktrees, err := m.List()
+	if err != nil {
+		return Worktree{}, false
+	}
+	for _, wt := range worktrees {
+		if wt.Branch != "" && SameBranch(wt.Branch, branch) {
+			return wt, true
+		}
+	}
+	return Worktree{}, false
+}
+
+// BranchExists reports whether branch exists locally or on origin.
+func (m *Manager) BranchExists(branch string) bool {
+	if _, err := m.git().Output(m.Dir, "show-ref", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		return true
+	}
+	if m.NoRemotes {
+		return false
+	}
+	_, err := m.git().Output(m.Dir, "show-ref", "--verify", "--quiet", "refs/remotes/origin/"+branch)
+	return err == nil
+}
+
+// Checkout adds a worktree for an existing branch and returns its path. If
+// the branch already has a worktree, its path is returned unchanged.
+func (m *Manager) Checkout(branch string) (string, error) {
+	unlock, err := m.lock(branch)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if wt, ok := m.Find(branch); ok {
+		return wt.Path, nil
+	}
+	if !m.BranchExists(branch) {
+		return "", fmt.Errorf("%w: %s", ErrBranchNotFound, branch)
+	}
+	path, err := m.EnsurePath(branch)
+	if err != nil {
+		return "", err
+	}
+	return m.add(branch, path, func(stderr *bytes.Buffer, retry bool) error {
+		return m.runTo(stderr, "worktree", "add", path, branch)
+	})
+}
+
+// Create adds a worktree for a new branch starting at base and returns its
+// path. If the branch already has a worktree, its path is returned unchanged.
+func (m *Manager) Create(branch, base string) (string, error) {
+	unlock, err := m.lock(branch)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if wt, ok := m.Find(branch); ok {
+		return wt.Path, nil
+	}
+	path, err := m.EnsurePath(branch)
+	if err != nil {
+		return "", err
+	}
+	existed := m.Lock != LockNone && m.localBranchExists(branch)
+	return m.add(branch, path, func(stderr *bytes.Buffer, retry bool) error {
+		// A failed first attempt may have created the branch already.
+		if retry && !existed && m.localBranchExists(branch) {
+			return m.runTo(stderr, "worktree", "add", path, branch)
+		}
+		return m.runTo(stderr, "worktree", "add", path, "-b", branch, base)
+	})
+}
+
+// localBranchExists reports whether branch exists locally.
+func (m *Manager) localBranchExists(branch string) bool {
+	_, err := m.git().Output(m.Dir, "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
+	return err == nil
+}
+
+// Reset is Create for a branch that may exist already: the branch is
+// created, or moved to base when it exists, and checked out in a new
+// worktree. A branch checked out in a worktree is not moved.
+func (m *Manager) Reset(branch, base string) (string, error) {
+	unlock, err := m.lock(branch)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if wt, ok := m.Find(branch); ok {
+		return "", fmt.Errorf("branch '%s' is checked out at %s", branch, wt.Path)
+	}
+	path, err := m.EnsurePath(branch)
+	if err != nil {
+		return "", err
+	}
+	return m.add(branch, path, func(stderr *bytes.Buffer, retry bool) error {
+		return m.runTo(stderr, "worktree", "add", path, "-B", branch, base)
+	})
+}
+
+// CreateDetached adds a worktree for name with commit checked out on a
+// detached HEAD and returns its path. Without a branch to find it by, an
+// existing directory for name is an error rather than reused.
+func (m *Manager) CreateDetached(name, commit string) (string, error) {
+	path, err := m.EnsurePath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	if err := m.run("worktree", "add", "--detach", path, commit); err != nil {
+		return "", fmt.Errorf("failed to create worktree: %w", err)
+	}
+	return path, nil
+}
+
+// CheckoutRef fetches ref from origin into branch and adds a worktree for it,
+// as used for PRs (pull/<n>/head) and MRs (merge-requests/<n>/head). If the
+// branch already has a worktree, its path is returned and nothing is fetched.
+// A failed fetch falls back to the local branch if there is one.
+func (m *Manager) CheckoutRef(ref, branch string) (string, error) {
+	checkout, err := m.CheckoutRefWithOptions(ref, branch, CheckoutRefOptions{})
+	return checkout.Path, err
+}
+
+// CheckoutRefWithOptions is CheckoutRef with control over the fetch. When
+// the fetch fails or is skipped and the local branch is used instead, the
+// result says so, so that callers can warn about a stale branch.
+func (m *Manager) CheckoutRefWithOptions(ref, branch string, opts CheckoutRefOptions) (RefCheckout, error) {
+	if wt, ok := m.Find(branch); ok {
+		return RefCheckout{Path: wt.Path, Existed: true}, nil
+	}
+	_, err := m.git().Output(m.Dir, "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
+	local := err == nil
+
+	var checkout RefCheckout
+	switch {
+	case opts.Offline && !local:
+		return checkout, fmt.Errorf("%w: %s has not been fetched yet and wt is offline", ErrBranchNotFound, branch)
+	case opts.Offline, opts.NoFetch && local:
+	default:
+		err := m.fetch(ref, branch, opts.FetchTimeout)
+		switch {
+		case err == nil:
+			checkout.Fetched = true
+		case local:
+			checkout.FetchErr = err
+		default:
+			return checkout, fmt.Errorf("failed to fetch %s: %w", ref, err)
+		}
+	}
+
+	path, err := m.EnsurePath(branch)
+	if err != nil {
+		return checkout, err
+	}
+	if err := m.run("worktree", "add", path, branch); err != nil {
+		return checkout, fmt.Errorf("failed to create worktree: %w", err)
+	}
+	checkout.Path = path
+	return checkout, nil
+}
+
+// fetch fetches ref from origin into branch, giving up after timeout unless
+// it is zero.
+func (m *Manager) fetch(ref, branch string, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := runContext(ctx, m.git(), m.Dir, nil, m.Stderr, "fetch", "origin", fmt.Sprintf("%s:%s", ref, branch))
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// Remove removes the worktree of branch and returns the path it occupied.
+func (m *Manager) Remove(branch string, opts RemoveOptions) (string, error) {
+	worktrees, err := m.List()
+	if err != nil {
+		return "", err
+	}
+	for i, wt := range worktrees {
+		if wt.Branch == "" || !SameBranch(wt.Branch, branch) {
+			continue
+		}
+		if i == 0 {
+			return "", fmt.Errorf("%w: %s", ErrMainWorktree, wt.Path)
+		}
+		args := []string{"worktree", "remove", wt.Path}
+		if opts.Force {
+			args = append(args, "--force")
+		}
+		if err := m.run(args...); err != nil {
+			return "", fmt.Errorf("failed to remove worktree: %w", err)
+		}
+		return wt.Path, nil
+	}
+	return "", fmt.Errorf("%w for branch: %s", ErrNotFound, branch)
+}
@@ -0,0 +1,431 @@
+package worktree
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// fakeRunner answers git commands from a table of canned outputs keyed by the
+// joined arguments and records every command it was asked to run.
+type fakeRunner struct {
+	outputs map[string]string
+	fail    map[string]bool
+	calls   []string
+	// deadlines counts the commands run with a deadline.
+	deadlines int
+}
+
+func (f *fakeRunner) Output(dir string, args ...string) ([]byte, error) {
+	key := strings.Join(args, " ")
+	f.calls = append(f.calls, key)
+	if out, ok := f.outputs[key]; ok {
+		return []byte(out), nil
+	}
+	return nil, errors.New("exit status 1")
+}
+
+func (f *fakeRunner) Run(dir string, stdout, stderr io.Writer, args ...string) error {
+	key := strings.Join(args, " ")
+	f.calls = append(f.calls, key)
+	if f.fail[key] {
+		return errors.New("exit status 128")
+	}
+	return nil
+}
+
+// RunContext implements ContextRunner, recording whether the command had a
+// deadline.
+func (f *fakeRunner) RunContext(ctx context.Context, dir string, stdout, stderr io.Writer, args ...string) error {
+	if _, ok := ctx.Deadline(); ok {
+		f.deadlines++
+	}
+	return f.Run(dir, stdout, stderr, args...)
+}
+
+func (f *fakeRunner) called(key string) bool {
+	for _, c := range f.calls {
+		if c == key {
+			return true
+		}
+	}
+	return false
+}
+
+const porcelain = "worktree /src/repo\nHEAD 1111111111111111111111111111111111111111\nbranch refs/heads/main\n\n" +
+	"worktree /trees/repo/feature\nHEAD 2222222222222222222222222222222222222222\nbranch refs/heads/feature\n\n"
+
+func newFake() *fakeRunner {
+	return &fakeRunner{
+		outputs: map[string]string{
+			"worktree list --porcelain":                          porcelain,
+			"show-ref --verify --quiet refs/heads/main":          "",
+			"show-ref --verify --quiet refs/heads/feature":       "",
+			"show-ref --verify --quiet refs/remotes/origin/next": "",
+		},
+		fail: map[string]bool{},
+	}
+}
+
+func TestNormalizeBranch(t *testing.T) {
+	nfd := norm.NFD.String("feature/café")
+	if nfd == "feature/café" {
+		t.Fatal("test input should be decomposed")
+	}
+	if got := NormalizeBranch(nfd); got != "feature/café" {
+		t.Errorf("NormalizeBranch(NFD) = %q, want NFC form", got)
+	}
+	if !SameBranch(nfd, "feature/café") {
+		t.Error("SameBranch() should treat NFD and NFC forms as equal")
+	}
+	if SameBranch("feature/cafe", "feature/café") {
+		t.Error("SameBranch() should not strip accents")
+	}
+}
+
+func TestExtendedLengthPath(t *testing.T) {
+	tests := map[string]string{
+		`C:\trees\api\feature`:        `\\?\C:\trees\api\feature`,
+		`C:/trees/api/feature`:        `\\?\C:\trees\api\feature`,
+		`\\server\share\trees`:        `\\?\UNC\server\share\trees`,
+		`\\?\C:\trees`:                `\\?\C:\trees`,
+		`trees\api`:                   `trees\api`,
+		`/home/dev/trees/api/feature`: `/home/dev/trees/api/feature`,
+	}
+	for path, want := range tests {
+		if got := extendedLengthPath(path); got != want {
+			t.Errorf("extendedLengthPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestParseWorktreePorcelain(t *testing.T) {
+	output := "worktree /src/repo\nHEAD 1111111111111111111111111111111111111111\nbranch refs/heads/main\n\n" +
+		"worktree /trees/repo/feature/login\nHEAD 2222222222222222222222222222222222222222\nbranch refs/heads/feature/login\nlocked pinned by wt\n\n" +
+		"worktree /trees/repo/review\nHEAD 3333333333333333333333333333333333333333\ndetached\nprunable gitdir file points to non-existent location\n\n"
+
+	got := ParsePorcelain(output)
+	if len(got) != 3 {
+		t.Fatalf("ParsePorcelain() returned %d worktrees, want 3", len(got))
+	}
+
+	if got[0].Path != filepath.Clean("/src/repo") || got[0].Branch != "main" {
+		t.Errorf("main worktree = %+v", got[0])
+	}
+	if got[1].Branch != "feature/login" || !got[1].Locked || got[1].LockReason != "pinned by wt" {
+		t.Errorf("feature worktree = %+v, want branch feature/login and locked with a reason", got[1])
+	}
+	if got[2].Branch != "" || !got[2].Detached || !got[2].Prunable {
+		t.Errorf("review worktree = %+v, want detached and prunable", got[2])
+	}
+	if got[2].Head != "3333333333333333333333333333333333333333" {
+		t.Errorf("review worktree head = %q", got[2].Head)
+	}
+}
+
+func TestParseWorktreePorcelainCRLF(t *testing.T) {
+	output := "worktree /src/repo\r\nHEAD abc\r\nbranch refs/heads/main\r\n\r\n"
+	got := ParsePorcelain(output)
+	if len(got) != 1 || got[0].Branch != "main" || got[0].Head != "abc" {
+		t.Fatalf("ParsePorcelain() = %+v", got)
+	}
+}
+
+func TestManagerList(t *testing.T) {
+	m := &Manager{Git: newFake()}
+	got, err := m.List()
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[1].Branch != "feature" {
+		t.Fatalf("List() = %+v", got)
+	}
+}
+
+func TestManagerCheckout(t *testing.T) {
+	root := t.TempDir()
+
+	t.Run("Existing worktree", func(t *testing.T) {
+		fake := newFake()
+		m := &Manager{Root: root, Repo: "repo", Git: fake}
+		path, err := m.Checkout("feature")
+		if err != nil || path != filepath.Clean("/trees/repo/feature") {
+			t.Fatalf("Checkout() = %q, %v", path, err)
+		}
+		if len(fake.calls) != 1 {
+			t.Errorf("Checkout() ran %v, want only the worktree listing", fake.calls)
+		}
+	})
+
+	t.Run("Remote branch", func(t *testing.T) {
+		fake := newFake()
+		m := &Manager{Root: root, Repo: "repo", Git: fake}
+		path, err := m.Checkout("next")
+		if err != nil {
+			t.Fatalf("Checkout() unexpected error: %v", err)
+		}
+		if want := filepath.Join(root, "repo", "next"); path != want {
+			t.Errorf("Checkout() = %q, want %q", path, want)
+		}
+		if !fake.called("worktree add " + path + " next") {
+			t.Errorf("Checkout() did not add the worktree: %v", fake.calls)
+		}
+	})
+
+	t.Run("Missing branch", func(t *testing.T) {
+		m := &Manager{Root: root, Repo: "repo", Git: newFake()}
+		if _, err := m.Checkout("nope"); !errors.Is(err, ErrBranchNotFound) {
+			t.Errorf("Checkout() error = %v, want ErrBranchNotFound", err)
+		}
+	})
+}
+
+func TestManagerCreate(t *testing.T) {
+	root := t.TempDir()
+	fake := newFake()
+	m := &Manager{Root: root, Repo: "repo", Git: fake, DirName: func(branch string) string {
+		return strings.ReplaceAll(branch, "/", "-")
+	}}
+
+	path, err := m.Create("feature/x", "main")
+	if err != nil {
+		t.Fatalf("Create() unexpected error: %v", err)
+	}
+	if want := filepath.Join(root, "repo", "feature-x"); path != want {
+		t.Errorf("Create() = %q, want %q", path, want)
+	}
+	if !fake.called("worktree add " + path + " -b feature/x main") {
+		t.Errorf("Create() did not add the worktree: %v", fake.calls)
+	}
+
+	fake.fail["worktree add "+filepath.Join(root, "repo", "y")+" -b y main"] = true
+	if _, err := m.Create("y", "main"); err == nil {
+		t.Error("Create() should report git failures")
+	}
+}
+
+func TestManagerCreateDetached(t *testing.T) {
+	root := t.TempDir()
+	fake := newFake()
+	m := &Manager{Root: root, Repo: "repo", Git: fake}
+
+	path, err := m.CreateDetached("snapshot-2024-05-14", "abc1234")
+	if err != nil {
+		t.Fatalf("CreateDetached() unexpected error: %v", err)
+	}
+	if want := filepath.Join(root, "repo", "snapshot-2024-05-14"); path != want {
+		t.Errorf("CreateDetached() = %q, want %q", path, want)
+	}
+	if !fake.called("worktree add --detach " + path + " abc1234") {
+		t.Errorf("CreateDetached() did not add the worktree: %v", fake.calls)
+	}
+
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CreateDetached("snapshot-2024-05-14", "abc1234"); err == nil {
+		t.Error("CreateDetached() should refuse an existing directory")
+	}
+}
+
+func TestManagerCheckoutRef(t *testing.T) {
+	fake := newFake()
+	m := &Manager{Root: t.TempDir(), Repo: "repo", Git: fake}
+	path, err := m.CheckoutRef("pull/7/head", "pr-7")
+	if err != nil {
+		t.Fatalf("CheckoutRef() unexpected error: %v", err)
+	}
+	if !fake.called("fetch origin pull/7/head:pr-7") || !fake.called("worktree add "+path+" pr-7") {
+		t.Errorf("CheckoutRef() ran %v", fake.calls)
+	}
+}
+
+func TestManagerReset(t *testing.T) {
+	root := t.TempDir()
+	fake := newFake()
+	m := &Manager{Root: root, Repo: "repo", Git: fake}
+
+	path, err := m.Reset("scratch", "main")
+	if err != nil {
+		t.Fatalf("Reset() unexpected error: %v", err)
+	}
+	if !fake.called("worktree add " + path + " -B scratch main") {
+		t.Errorf("Reset() did not add the worktree with -B: %v", fake.calls)
+	}
+
+	// A branch checked out in a worktree stays where it is.
+	if _, err := m.Reset("feature", "main"); err == nil {
+		t.Error("Reset() should refuse a branch that has a worktree")
+	}
+}
+
+func TestManagerCheckoutRefWithOptions(t *testing.T) {
+	const fetch = "fetch origin pull/7/head:pr-7"
+	tests := []struct {
+		name        string
+		local       bool
+		fetchFails  bool
+		opts        CheckoutRefOptions
+		wantFetch   bool
+		wantFetched bool
+		wantErr     error
+		wantStale   bool
+	}{
+		{name: "fetch", wantFetch: true, wantFetched: true},
+		{name: "fetch over local branch", local: true, wantFetch: true, wantFetched: true},
+		{name: "no-fetch with local branch", local: true, opts: CheckoutRefOptions{NoFetch: true}},
+		{name: "no-fetch without local branch", opts: CheckoutRefOptions{NoFetch: true}, wantFetch: true, wantFetched: true},
+		{name: "offline with local branch", local: true, opts: CheckoutRefOptions{Offline: true}},
+		{name: "offline without local branch", opts: CheckoutRefOptions{Offline: true}, wantErr: ErrBranchNotFound},
+		{name: "failed fetch with local branch", local: true, fetchFails: true, wantFetch: true, wantStale: true},
+		{name: "failed fetch without local branch", fetchFails: true, wantFetch: true, wantErr: errors.New("any")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFake()
+			if tt.local {
+				fake.outputs["show-ref --verify --quiet refs/heads/pr-7"] = ""
+			}
+			fake.fail[fetch] = tt.fetchFails
+			m := &Manager{Root: t.TempDir(), Repo: "repo", Git: fake}
+
+			checkout, err := m.CheckoutRefWithOptions("pull/7/head", "pr-7", tt.opts)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("CheckoutRefWithOptions() unexpected error: %v", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatal("CheckoutRefWithOptions() should fail")
+			case errors.Is(tt.wantErr, ErrBranchNotFound) && !errors.Is(err, ErrBranchNotFound):
+				t.Errorf("CheckoutRefWithOptions() = %v, want ErrBranchNotFound", err)
+			}
+			if fake.called(fetch) != tt.wantFetch {
+				t.Errorf("fetched = %v, want %v; ran %v", fake.called(fetch), tt.wantFetch, fake.calls)
+			}
+			if checkout.Fetched != tt.wantFetched || (checkout.FetchErr != nil) != tt.wantStale {
+				t.Errorf("CheckoutRefWithOptions() = %+v", checkout)
+			}
+			if added := fake.called("worktree add " + m.Path("pr-7") + " pr-7"); added != (tt.wantErr == nil) {
+				t.Errorf("worktree added = %v; ran %v", added, fake.calls)
+			}
+		})
+	}
+}
+
+func TestManagerCheckoutRefTimeout(t *testing.T) {
+	fake := newFake()
+	m := &Manager{Root: t.TempDir(), Repo: "repo", Git: fake}
+	if _, err := m.CheckoutRefWithOptions("pull/7/head", "pr-7", CheckoutRefOptions{FetchTimeout: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if fake.deadlines != 1 {
+		t.Errorf("%d commands ran with a deadline, want only the fetch", fake.deadlines)
+	}
+}
+
+func TestExecRunnerTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	err := ExecRunner{}.RunContext(ctx, t.TempDir(), nil, nil, "version")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunContext() with an expired context = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestManagerRemove(t *testing.T) {
+	fake := newFake()
+	m := &Manager{Git: fake}
+
+	path, err := m.Remove("feature", RemoveOptions{Force: true})
+	if err != nil {
+		t.Fatalf("Remove() unexpected error: %v", err)
+	}
+	if !fake.called("worktree remove " + path + " --force") {
+		t.Errorf("Remove() ran %v", fake.calls)
+	}
+
+	if _, err := m.Remove("main", RemoveOptions{}); !errors.Is(err, ErrMainWorktree) {
+		t.Errorf("Remove(main) error = %v, want ErrMainWorktree", err)
+	}
+	if _, err := m.Remove("gone", RemoveOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Remove(gone) error = %v, want ErrNotFound", err)
+	}
+}
+
+// chattyRunner writes to both streams, like git does, and fails on demand.
+type chattyRunner struct {
+	fakeRunner
+	stdout, stderr string
+	err            error
+}
+
+func (c *chattyRunner) Run(dir string, stdout, stderr io.Writer, args ...string) error {
+	if stdout != nil {
+		_, _ = io.WriteString(stdout, c.stdout)
+	}
+	if stderr != nil {
+		_, _ = io.WriteString(stderr, c.stderr)
+	}
+	return c.err
+}
+
+// RunContext overrides the one of the embedded fakeRunner.
+func (c *chattyRunner) RunContext(ctx context.Context, dir string, stdout, stderr io.Writer, args ...string) error {
+	return c.Run(dir, stdout, stderr, args...)
+}
+
+func TestQuietRunner(t *testing.T) {
+	chatty := &chattyRunner{stdout: "HEAD is now at 1111111\n", stderr: "Preparing worktree\n"}
+	q := QuietRunner{Runner: chatty}
+
+	var stdout, stderr strings.Builder
+	if err := q.Run("", &stdout, &stderr, "worktree", "add"); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Errorf("successful command printed %q / %q", stdout.String(), stderr.String())
+	}
+
+	chatty.err = errors.New("exit status 128")
+	chatty.stderr = "fatal: invalid reference\n"
+	if err := q.Run("", &stdout, &stderr, "worktree", "add"); err == nil {
+		t.Fatal("expected the error to be passed through")
+	}
+	if stdout.String() != chatty.stdout || stderr.String() != chatty.stderr {
+		t.Errorf("failure replayed %q / %q, want %q / %q", stdout.String(), stderr.String(), chatty.stdout, chatty.stderr)
+	}
+
+	// Nil writers still discard the stream.
+	if err := q.Run("", nil, &stderr, "fetch"); err == nil {
+		t.Fatal("expected the error to be passed through")
+	}
+}
+
+func TestQuietRunnerTruncates(t *testing.T) {
+	chatty := &chattyRunner{stderr: strings.Repeat("x", 50) + "fatal: the end\n", err: errors.New("exit status 1")}
+	q := QuietRunner{Runner: chatty, Limit: 15}
+
+	var stderr strings.Builder
+	_ = q.Run("", nil, &stderr, "clone")
+	want := "[50 bytes of earlier git output truncated]\nfatal: the end\n"
+	if stderr.String() != want {
+		t.Errorf("replayed %q, want %q", stderr.String(), want)
+	}
+
+	b := &tailBuffer{limit: 4}
+	for i := 0; i < 10; i++ {
+		_, _ = b.Write([]byte("ab"))
+	}
+	var out strings.Builder
+	b.replay(&out)
+	if want := "[16 bytes of earlier git output truncated]\nabab"; out.String() != want {
+		t.Errorf("replayed %q, want %q", out.String(), want)
+	}
+}
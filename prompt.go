@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+// Prompter asks the user questions. Commands get theirs with promptFor, so
+// that tests can put a scripted one in the command context and run the
+// interactive paths without a terminal.
+type Prompter interface {
+	// Select asks for one of items and returns its index, or
+	// errSelectionCancelled.
+	Select(label string, items []string, opts SelectOptions) (int, error)
+	// MultiSelect asks for any number of items and returns their indexes in
+	// order, or errSelectionCancelled.
+	MultiSelect(label string, items []string) ([]int, error)
+	// Confirm asks a yes/no question. errCancelled means it was interrupted
+	// rather than answered.
+	Confirm(label string) (bool, error)
+	// Input asks for a line of text, offering def. errCancelled means it was
+	// interrupted.
+	Input(label, def string) (string, error)
+}
+
+// SelectOptions tune a Select.
+type SelectOptions struct {
+	// Cursor is the index of the item the menu starts on.
+	Cursor int
+}
+
+type prompterKey struct{}
+
+// withPrompter returns a copy of ctx in which commands prompt with p.
+func withPrompter(ctx context.Context, p Prompter) context.Context {
+	return context.WithValue(ctx, prompterKey{}, p)
+}
+
+// promptFor returns the Prompter in cmd's context, or the terminal one.
+func promptFor(cmd *cobra.Command) Prompter {
+	if ctx := cmd.Context(); ctx != nil {
+		if p, ok := ctx.Value(prompterKey{}).(Prompter); ok {
+			return p
+		}
+	}
+	return promptuiPrompter{}
+}
+
+// promptuiPrompter prompts on the terminal with promptui. Select uses the
+// external picker of the config instead when there is one. Prompts are not
+// data: they are drawn on stderr.
+type promptuiPrompter struct{}
+
+func (promptuiPrompter) Select(label string, items []string, opts SelectOptions) (int, error) {
+	if command, ok := pickerCommand(getConfig(), label); ok {
+		return selectExternal(command, items)
+	}
+	return selectBuiltin(label, items, opts.Cursor)
+}
+
+// MultiSelect toggles items in the builtin menu until Done is picked;
+// external pickers are not used, as few of them can return several lines.
+func (promptuiPrompter) MultiSelect(label string, items []string) ([]int, error) {
+	chosen := make([]bool, len(items))
+	cursor := 0
+	for {
+		menu := []string{"Done"}
+		for i, item := range items {
+			mark := "[ ] "
+			if chosen[i] {
+				mark = "[x] "
+			}
+			menu = append(menu, mark+item)
+		}
+		idx, err := selectBuiltin(label, menu, cursor)
+		if err != nil {
+			return nil, err
+		}
+		if idx == 0 {
+			break
+		}
+		chosen[idx-1] = !chosen[idx-1]
+		cursor = idx
+	}
+	var indexes []int
+	for i, ok := range chosen {
+		if ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+func (promptuiPrompter) Confirm(label string) (bool, error) {
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+		Stdout:    os.Stderr,
+	}
+	_, err := prompt.Run()
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, promptui.ErrAbort):
+		return false, nil
+	}
+	return false, errCancelled
+}
+
+func (promptuiPrompter) Input(label, def string) (string, error) {
+	prompt := promptui.Prompt{
+		Label:     label,
+		Default:   def,
+		AllowEdit: true,
+		Stdout:    os.Stderr,
+	}
+	answer, err := prompt.Run()
+	if err != nil {
+		return "", errCancelled
+	}
+	return answer, nil
+}
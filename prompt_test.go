@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// scriptedPrompter answers prompts from a script, in order, and fails the
+// test on a prompt the script does not expect. A Select answer is the index
+// or the text of the item; an error answer makes the prompt fail with it.
+type scriptedPrompter struct {
+	t       *testing.T
+	answers []any
+	asked   []string
+}
+
+func (p *scriptedPrompter) next(label string) any {
+	p.t.Helper()
+	p.asked = append(p.asked, label)
+	if len(p.answers) == 0 {
+		p.t.Fatalf("unexpected prompt %q", label)
+	}
+	answer := p.answers[0]
+	p.answers = p.answers[1:]
+	return answer
+}
+
+func (p *scriptedPrompter) Select(label string, items []string, _ SelectOptions) (int, error) {
+	switch answer := p.next(label).(type) {
+	case error:
+		return 0, answer
+	case string:
+		if idx := slices.Index(items, answer); idx >= 0 {
+			return idx, nil
+		}
+		p.t.Fatalf("prompt %q has no item %q: %q", label, answer, items)
+	case int:
+		return answer, nil
+	}
+	p.t.Fatalf("prompt %q: no Select answer", label)
+	return 0, nil
+}
+
+func (p *scriptedPrompter) MultiSelect(label string, _ []string) ([]int, error) {
+	switch answer := p.next(label).(type) {
+	case error:
+		return nil, answer
+	case []int:
+		return answer, nil
+	}
+	p.t.Fatalf("prompt %q: no MultiSelect answer", label)
+	return nil, nil
+}
+
+func (p *scriptedPrompter) Confirm(label string) (bool, error) {
+	switch answer := p.next(label).(type) {
+	case error:
+		return false, answer
+	case bool:
+		return answer, nil
+	}
+	p.t.Fatalf("prompt %q: no Confirm answer", label)
+	return false, nil
+}
+
+func (p *scriptedPrompter) Input(label, _ string) (string, error) {
+	switch answer := p.next(label).(type) {
+	case error:
+		return "", answer
+	case string:
+		return answer, nil
+	}
+	p.t.Fatalf("prompt %q: no Input answer", label)
+	return "", nil
+}
+
+// script puts a scriptedPrompter with answers in the context of cmd for the
+// rest of the test.
+func script(t *testing.T, cmd *cobra.Command, answers ...any) *scriptedPrompter {
+	t.Helper()
+	p := &scriptedPrompter{t: t, answers: answers}
+	orig := cmd.Context()
+	t.Cleanup(func() { cmd.SetContext(orig) })
+	cmd.SetContext(withPrompter(context.Background(), p))
+	return p
+}
+
+func TestPromptFor(t *testing.T) {
+	cmd := &cobra.Command{Use: "test"}
+	if _, ok := promptFor(cmd).(promptuiPrompter); !ok {
+		t.Errorf("promptFor() without a context = %T, want promptuiPrompter", promptFor(cmd))
+	}
+	p := script(t, cmd)
+	if promptFor(cmd) != p {
+		t.Errorf("promptFor() = %T, want the prompter of the context", promptFor(cmd))
+	}
+}
+
+func TestSilenceCancellationExitCode(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	cmd := &cobra.Command{
+		Use: "pick",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := promptFor(cmd).Select("Pick", []string{"a", "b"}, SelectOptions{})
+			return err
+		},
+	}
+	root.AddCommand(cmd)
+	silenceCancellation(root)
+	script(t, cmd, errSelectionCancelled)
+
+	var exitErr *exitCodeError
+	if err := cmd.RunE(cmd, nil); !errors.As(err, &exitErr) || exitErr.code != exitCancelled {
+		t.Errorf("cancelled selection returned %v, want exit code %d", err, exitCancelled)
+	}
+}
+
+// setupInteractiveRepo creates a repository with the branch feature-x and a
+// worktree root, and makes the current directory the repository.
+func setupInteractiveRepo(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "branch", "feature-x")
+	originalRoot := worktreeRoot
+	t.Cleanup(func() { worktreeRoot = originalRoot })
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	t.Chdir(repoDir)
+	return repoDir
+}
+
+func TestCheckoutInteractive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	setupInteractiveRepo(t)
+	p := script(t, checkoutCmd, "feature-x")
+	if err := checkoutCmd.RunE(checkoutCmd, nil); err != nil {
+		t.Fatalf("checkout after selecting feature-x: %v", err)
+	}
+	if len(p.asked) != 1 || p.asked[0] != "Select branch to checkout" {
+		t.Errorf("prompts = %q, want the branch selection", p.asked)
+	}
+	path, ok := worktreeExists("feature-x")
+	if !ok {
+		t.Fatal("no worktree for the selected branch")
+	}
+	output, err := exec.Command("git", "-C", path, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil || strings.TrimSpace(string(output)) != "feature-x" {
+		t.Errorf("%s has %q checked out (%v), want feature-x", path, output, err)
+	}
+}
+
+func TestCheckoutInteractiveCancelled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	setupInteractiveRepo(t)
+	script(t, checkoutCmd, errSelectionCancelled)
+	if err := checkoutCmd.RunE(checkoutCmd, nil); !errors.Is(err, errSelectionCancelled) {
+		t.Errorf("checkout with a cancelled selection = %v, want errSelectionCancelled", err)
+	}
+	entries, _ := os.ReadDir(worktreeRoot)
+	if len(entries) != 0 {
+		t.Errorf("cancelled checkout created %d entries in the worktree root", len(entries))
+	}
+}
+
+func TestRemoveInteractive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	path := filepath.Join(worktreeRoot, "repo", "feature-x")
+	runGitCommand(t, repoDir, "worktree", "add", path, "feature-x")
+	stubConfirm(t, true, &Config{})
+
+	p := script(t, removeCmd, "feature-x", false)
+	if err := removeCmd.RunE(removeCmd, nil); !errors.Is(err, errCancelled) {
+		t.Fatalf("remove answered no = %v, want errCancelled", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("worktree removed although the removal was declined: %v", err)
+	}
+
+	p = script(t, removeCmd, "feature-x", true)
+	if err := removeCmd.RunE(removeCmd, nil); err != nil {
+		t.Fatalf("remove answered yes: %v", err)
+	}
+	if want := []string{"Select worktree to remove", "Remove worktree feature-x"}; !slices.Equal(p.asked, want) {
+		t.Errorf("prompts = %q, want %q", p.asked, want)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("worktree still exists after removal: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// repoOwnerFromURL returns the directory above the repository in its remote
+// URL, the owner on a forge: "acme" for https://github.com/acme/api.git or
+// git@github.com:acme/api.git. It returns "" when there is none.
+func repoOwnerFromURL(url string) string {
+	p := strings.ReplaceAll(url, `\`, "/")
+	if _, rest, ok := strings.Cut(p, "://"); ok {
+		_, p, _ = strings.Cut(rest, "/")
+	} else if colon := strings.Index(p, ":"); colon > 0 && !strings.Contains(p[:colon], "/") {
+		p = p[colon+1:]
+	}
+	p = strings.TrimSuffix(path.Clean("/"+p), "/.git")
+	owner := path.Base(path.Dir(p))
+	if owner == "/" || owner == "." {
+		return ""
+	}
+	return owner
+}
+
+// matchProtectedRepo returns the entry of protected naming the repository:
+// either its name, e.g. payments, or owner/name, e.g. acme/payments. Names
+// are compared ignoring case, as forges do.
+func matchProtectedRepo(protected []string, name, owner string) (string, bool) {
+	for _, entry := range protected {
+		want := strings.TrimSpace(entry)
+		got := name
+		if strings.Contains(want, "/") {
+			if owner == "" {
+				continue
+			}
+			got = owner + "/" + name
+		}
+		if strings.EqualFold(want, got) {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+// checkRepoProtection guards a bulk destructive operation. In a repository
+// listed in protectedRepos it refuses, unless --override-protection is given
+// and the repository name is typed to confirm. --yes does not skip this.
+func checkRepoProtection(cmd *cobra.Command, operation string) error {
+	protected := getConfig().ProtectedRepos
+	if len(protected) == 0 {
+		return nil
+	}
+	name, err := getRepoName()
+	if err != nil {
+		return err
+	}
+	owner := ""
+	if output, err := repoGit("remote", "get-url", "origin").Output(); err == nil {
+		owner = repoOwnerFromURL(strings.TrimSpace(string(output)))
+	}
+	entry, ok := matchProtectedRepo(protected, name, owner)
+	if !ok {
+		return nil
+	}
+	if override, _ := cmd.Flags().GetBool("override-protection"); !override {
+		return fmt.Errorf("%s is protected (%q in protectedRepos): refusing to %s\nRemove worktrees one at a time, or pass --override-protection and type the repository name to go ahead", name, entry, operation)
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%s is protected: --override-protection needs a terminal to type the repository name on", name)
+	}
+	typed, err := promptFor(cmd).Input(fmt.Sprintf("%s is protected. Type its name to %s", name, operation), "")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(typed) != name {
+		return fmt.Errorf("%q is not %s; nothing was changed", typed, name)
+	}
+	return nil
+}
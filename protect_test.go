@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRepoOwnerFromURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://github.com/acme/payments.git", "acme"},
+		{"https://gitlab.com/acme/platform/payments", "platform"},
+		{"git@github.com:acme/payments.git", "acme"},
+		{"ssh://git@github.com/acme/payments", "acme"},
+		{"https://host/payments.git", ""},
+		{"/srv/git/payments.git", "git"},
+		{"payments", ""},
+	}
+	for _, tt := range tests {
+		if got := repoOwnerFromURL(tt.url); got != tt.want {
+			t.Errorf("repoOwnerFromURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestMatchProtectedRepo(t *testing.T) {
+	protected := []string{"infra-live", "acme/payments"}
+	tests := []struct {
+		name, owner string
+		want        string
+	}{
+		{"infra-live", "", "infra-live"},
+		{"Infra-Live", "acme", "infra-live"},
+		{"payments", "acme", "acme/payments"},
+		{"payments", "ACME", "acme/payments"},
+		{"payments", "other", ""},
+		{"payments", "", ""},
+		{"api", "acme", ""},
+	}
+	for _, tt := range tests {
+		got, ok := matchProtectedRepo(protected, tt.name, tt.owner)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("matchProtectedRepo(%q, %q) = %q, %v, want %q", tt.name, tt.owner, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCheckRepoProtection(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	setupInteractiveRepo(t)
+	stubConfirm(t, true, &Config{ProtectedRepos: []string{"other"}})
+	if err := checkRepoProtection(newFlagTestCmd(t, []string{"override-protection"}), "remove"); err != nil {
+		t.Fatalf("unprotected repository: %v", err)
+	}
+
+	loadedConfig = &Config{ProtectedRepos: []string{"repo"}}
+	err := checkRepoProtection(newFlagTestCmd(t, []string{"override-protection"}), "remove")
+	if err == nil || !strings.Contains(err.Error(), "--override-protection") {
+		t.Errorf("protected repository without override = %v, want a refusal naming --override-protection", err)
+	}
+
+	cmd := newFlagTestCmd(t, []string{"override-protection"}, "--override-protection")
+	p := script(t, cmd, "rep", "repo")
+	if err := checkRepoProtection(cmd, "remove"); err == nil {
+		t.Error("override with the wrong name typed went ahead")
+	}
+	if err := checkRepoProtection(cmd, "remove"); err != nil {
+		t.Errorf("override with the name typed: %v", err)
+	}
+	if len(p.asked) != 2 || !strings.Contains(p.asked[0], "Type its name") {
+		t.Errorf("prompts = %q, want the typed confirmation twice", p.asked)
+	}
+
+	stdinIsTerminal = func() bool { return false }
+	if err := checkRepoProtection(cmd, "remove"); err == nil {
+		t.Error("override without a terminal went ahead")
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// publishOptions says how `wt publish` and `wt create --publish` open the
+// PR/MR of a branch.
+type publishOptions struct {
+	// Ready opens it ready for review instead of as a draft.
+	Ready bool
+	// Title replaces the title derived from the branch name.
+	Title string
+	// BodyFile holds the description; it is empty without one.
+	BodyFile string
+}
+
+// parsePublishMode maps the value of --publish to whether the PR/MR is
+// opened ready for review.
+func parsePublishMode(mode string) (bool, error) {
+	switch mode {
+	case "draft":
+		return false, nil
+	case "ready":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid --publish %q: use draft or ready", mode)
+}
+
+var ticketPrefixRegex = regexp.MustCompile(`^([A-Z][A-Z0-9]+-[0-9]+)(?:[-_ ]+(.*))?$`)
+
+// titleFromBranch derives a PR/MR title from the last segment of a branch
+// name: feature/add-login becomes "Add login", JIRA-123-add-login becomes
+// "JIRA-123: Add login".
+func titleFromBranch(branch string) string {
+	name := branch[strings.LastIndex(branch, "/")+1:]
+	ticket := ""
+	if m := ticketPrefixRegex.FindStringSubmatch(name); m != nil {
+		ticket, name = m[1], m[2]
+	}
+	words := strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_'
+	}), " ")
+	if r, size := utf8.DecodeRuneInString(words); size > 0 {
+		words = string(unicode.ToUpper(r)) + words[size:]
+	}
+	switch {
+	case ticket == "":
+		return words
+	case words == "":
+		return ticket
+	}
+	return ticket + ": " + words
+}
+
+// prTitleData is what a prTitle template can refer to: {{.Branch}} is the
+// branch and {{.Title}} the title derived from it.
+type prTitleData struct {
+	Branch string
+	Title  string
+}
+
+// publishTitle returns the title of the PR/MR of branch: the configured
+// prTitle template, or the title derived from the branch name.
+func publishTitle(branch, custom string) (string, error) {
+	data := prTitleData{Branch: branch, Title: titleFromBranch(branch)}
+	if custom == "" {
+		return data.Title, nil
+	}
+	tmpl, err := template.New("prTitle").Option("missingkey=error").Parse(custom)
+	if err != nil {
+		return "", fmt.Errorf("invalid prTitle: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid prTitle: %w", err)
+	}
+	return b.String(), nil
+}
+
+// publishArgs returns the gh/glab command line opening the PR/MR of branch.
+// The title and body are always given, so neither CLI prompts.
+func publishArgs(remoteType RemoteType, branch, title, body string, ready bool) []string {
+	var args []string
+	if remoteType == RemoteGitLab {
+		args = []string{"mr", "create", "--source-branch", branch, "--title", title, "--description", body, "--yes"}
+	} else {
+		args = []string{"pr", "create", "--head", branch, "--title", title, "--body", body}
+	}
+	if !ready {
+		args = append(args, "--draft")
+	}
+	return args
+}
+
+// publishRetryCommand is the `wt publish` command line doing what failed.
+func publishRetryCommand(branch string, opts publishOptions) string {
+	parts := []string{"wt", "publish", shellQuote(branch)}
+	if opts.Ready {
+		parts = append(parts, "--ready")
+	}
+	if opts.Title != "" {
+		parts = append(parts, "--title", shellQuote(opts.Title))
+	}
+	if opts.BodyFile != "" {
+		parts = append(parts, "--body-file", shellQuote(opts.BodyFile))
+	}
+	return strings.Join(parts, " ")
+}
+
+// readPublishBody returns the description of the PR/MR from opts.
+func readPublishBody(opts publishOptions) (string, error) {
+	if opts.BodyFile == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(opts.BodyFile)
+	if err != nil {
+		return "", fmt.Errorf("cannot read --body-file: %w", err)
+	}
+	return string(data), nil
+}
+
+// publishBranch pushes branch to origin with upstream tracking and opens a
+// PR/MR for it with gh or glab, picked by the origin host. It returns the
+// URL of the PR/MR.
+func publishBranch(branch string, opts publishOptions) (string, error) {
+	if err := requireRemote(); err != nil {
+		return "", err
+	}
+	if offline {
+		return "", fmt.Errorf("cannot publish %s while offline", branch)
+	}
+	remoteURL, err := repoGit("remote", "get-url", "origin").Output()
+	if err != nil {
+		return "", fmt.Errorf("no origin remote to publish to")
+	}
+	remoteType := guessRemoteType(remoteHost(strings.TrimSpace(string(remoteURL))))
+	if err := requireReviewCLI(remoteType); err != nil {
+		return "", err
+	}
+	body, err := readPublishBody(opts)
+	if err != nil {
+		return "", err
+	}
+	title := opts.Title
+	if title == "" {
+		if title, err = publishTitle(branch, getConfig().PRTitle); err != nil {
+			return "", err
+		}
+	}
+
+	if err := gitRunner().Run("", os.Stderr, os.Stderr, "push", "--set-upstream", "origin", branch); err != nil {
+		return "", fmt.Errorf("failed to push %s: %w", branch, err)
+	}
+	output, err := runForgeCLI(remoteType, publishArgs(remoteType, branch, title, body, opts.Ready)...)
+	if err != nil {
+		return "", err
+	}
+	// gh and glab print the URL of the new PR/MR last.
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
+
+// reportPublished says what publishBranch opened.
+func reportPublished(url string, opts publishOptions) {
+	kind := "Draft opened"
+	if opts.Ready {
+		kind = "Opened for review"
+	}
+	infof("✓ %s: %s\n", kind, url)
+}
+
+// publishFlags reads --title and --body-file of cmd.
+func publishFlags(cmd *cobra.Command) publishOptions {
+	var opts publishOptions
+	opts.Title, _ = cmd.Flags().GetString("title")
+	opts.BodyFile, _ = cmd.Flags().GetString("body-file")
+	return opts
+}
+
+var publishCmd = &cobra.Command{
+	Use:   "publish [branch]",
+	Short: "Push a branch and open a draft PR/MR for it",
+	Long: `Push a branch, the one checked out here by default, to origin with upstream
+tracking and open a PR (gh) or MR (glab) for it, picked by the origin host.
+
+The PR/MR is a draft unless --ready is given. Its title is derived from the
+branch name (feature/JIRA-123-add-login becomes "JIRA-123: Add login"), or
+rendered from prTitle in the config or the template, where {{.Branch}} and
+{{.Title}} expand to the branch and the derived title. --title replaces it.
+The description is empty unless --body-file is given.
+
+'wt create --publish' does the same right after creating the worktree.
+
+Examples:
+  wt publish                        # The branch checked out here, as a draft
+  wt publish feature-x --ready      # Ready for review
+  wt publish --title "Fix login" --body-file NOTES.md`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeBranches,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		branch := ""
+		if len(args) > 0 {
+			branch = args[0]
+		} else {
+			output, _ := gitIn("", "symbolic-ref", "--short", "-q", "HEAD").Output()
+			if branch = strings.TrimSpace(string(output)); branch == "" {
+				return fmt.Errorf("not on a branch; pass the branch to publish")
+			}
+		}
+		opts := publishFlags(cmd)
+		opts.Ready, _ = cmd.Flags().GetBool("ready")
+		url, err := publishBranch(branch, opts)
+		if err != nil {
+			return err
+		}
+		reportPublished(url, opts)
+		return nil
+	},
+}
+
+func init() {
+	publishCmd.Flags().Bool("ready", false, "Open the PR/MR ready for review instead of as a draft")
+	for _, cmd := range []*cobra.Command{publishCmd, createCmd} {
+		cmd.Flags().String("title", "", "Title of the PR/MR (default: derived from the branch name)")
+		cmd.Flags().String("body-file", "", "Read the description of the PR/MR from this file")
+	}
+	createCmd.Flags().String("publish", "", "After creating the worktree, push the branch and open a PR/MR: draft (default) or ready")
+	createCmd.Flags().Lookup("publish").NoOptDefVal = "draft"
+	_ = createCmd.RegisterFlagCompletionFunc("publish", cobra.FixedCompletions(
+		[]string{"draft", "ready"}, cobra.ShellCompDirectiveNoFileComp))
+}
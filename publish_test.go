@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTitleFromBranch(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"add-login", "Add login"},
+		{"feature/add_login-page", "Add login page"},
+		{"JIRA-123-add-login", "JIRA-123: Add login"},
+		{"timvw/JIRA-123_fix", "JIRA-123: Fix"},
+		{"JIRA-123", "JIRA-123"},
+		{"ünicode-fix", "Ünicode fix"},
+		{"v2-cleanup", "V2 cleanup"},
+	}
+	for _, tt := range tests {
+		if got := titleFromBranch(tt.branch); got != tt.want {
+			t.Errorf("titleFromBranch(%q) = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestPublishTitle(t *testing.T) {
+	if got, err := publishTitle("feature/add-login", ""); err != nil || got != "Add login" {
+		t.Errorf("publishTitle() without template = %q, %v; want the derived title", got, err)
+	}
+	if got, err := publishTitle("feature/add-login", "[{{.Branch}}] {{.Title}}"); err != nil || got != "[feature/add-login] Add login" {
+		t.Errorf("publishTitle() with template = %q, %v", got, err)
+	}
+	if _, err := publishTitle("x", "{{.Ticket}}"); err == nil {
+		t.Error("publishTitle() with an unknown field succeeded")
+	}
+}
+
+func TestParsePublishMode(t *testing.T) {
+	if ready, err := parsePublishMode("draft"); err != nil || ready {
+		t.Errorf("parsePublishMode(draft) = %v, %v", ready, err)
+	}
+	if ready, err := parsePublishMode("ready"); err != nil || !ready {
+		t.Errorf("parsePublishMode(ready) = %v, %v", ready, err)
+	}
+	if _, err := parsePublishMode("open"); err == nil {
+		t.Error("parsePublishMode(open) succeeded")
+	}
+}
+
+func TestPublishArgs(t *testing.T) {
+	got := publishArgs(RemoteGitHub, "add-login", "Add login", "", false)
+	want := []string{"pr", "create", "--head", "add-login", "--title", "Add login", "--body", "", "--draft"}
+	if !slices.Equal(got, want) {
+		t.Errorf("publishArgs(GitHub, draft) = %q, want %q", got, want)
+	}
+	got = publishArgs(RemoteGitLab, "add-login", "Add login", "body", true)
+	want = []string{"mr", "create", "--source-branch", "add-login", "--title", "Add login", "--description", "body", "--yes"}
+	if !slices.Equal(got, want) {
+		t.Errorf("publishArgs(GitLab, ready) = %q, want %q", got, want)
+	}
+}
+
+func TestPublishRetryCommand(t *testing.T) {
+	if got := publishRetryCommand("add-login", publishOptions{}); got != "wt publish add-login" {
+		t.Errorf("publishRetryCommand() = %q", got)
+	}
+	got := publishRetryCommand("add-login", publishOptions{Ready: true, Title: "Don't", BodyFile: "notes.md"})
+	if want := `wt publish add-login --ready --title 'Don'\''t' --body-file notes.md`; got != want {
+		t.Errorf("publishRetryCommand() = %q, want %q", got, want)
+	}
+}
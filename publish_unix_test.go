@@ -0,0 +1,72 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeGH puts a gh script on PATH that records its arguments in the returned
+// file and prints a PR URL, or fails with code.
+func fakeGH(t *testing.T, code string) string {
+	t.Helper()
+	dir := t.TempDir()
+	record := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + record + "\n" +
+		"[ " + code + " = 0 ] || { echo 'pull request create failed' >&2; exit " + code + "; }\n" +
+		"echo https://github.com/acme/repo/pull/7\n"
+	if err := os.WriteFile(filepath.Join(dir, "gh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return record
+}
+
+func TestCreatePublish(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	origin := filepath.Join(t.TempDir(), "origin.git")
+	runGitCommand(t, repoDir, "init", "-q", "--bare", origin)
+	runGitCommand(t, repoDir, "remote", "add", "origin", origin)
+	quietGit = true
+	t.Cleanup(func() { quietGit = false })
+	stubConfirm(t, false, &Config{})
+
+	record := fakeGH(t, "0")
+	url, err := publishBranch("feature-x", publishOptions{})
+	if err != nil {
+		t.Fatalf("publishBranch() = %v", err)
+	}
+	if url != "https://github.com/acme/repo/pull/7" {
+		t.Errorf("publishBranch() = %q, want the URL gh printed", url)
+	}
+	if got := strings.TrimSpace(gitOutput(t, repoDir, "rev-parse", "--abbrev-ref", "feature-x@{upstream}")); got != "origin/feature-x" {
+		t.Errorf("upstream of feature-x = %q, want origin/feature-x", got)
+	}
+	if data, _ := os.ReadFile(record); !strings.Contains(string(data), "--title\nFeature x\n--body\n\n--draft\n") {
+		t.Errorf("gh got %q, want a draft titled after the branch with an empty body", data)
+	}
+
+	// A failure to open the PR keeps the worktree and succeeds.
+	fakeGH(t, "1")
+	flag := createCmd.Flags().Lookup("publish")
+	t.Cleanup(func() {
+		_ = flag.Value.Set("")
+		flag.Changed = false
+	})
+	if err := createCmd.Flags().Set("publish", "ready"); err != nil {
+		t.Fatal(err)
+	}
+	if err := createCmd.RunE(createCmd, []string{"add-login"}); err != nil {
+		t.Fatalf("create --publish with gh failing = %v, want the worktree kept and no error", err)
+	}
+	if _, ok := worktreeExists("add-login"); !ok {
+		t.Error("the worktree was removed after publishing failed")
+	}
+}
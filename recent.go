@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// maxHistoryEntries caps the number of visits kept in the history file.
+const maxHistoryEntries = 200
+
+// visit records that the shell was sent to a worktree path.
+type visit struct {
+	Repo      string    `json:"repo"`
+	Path      string    `json:"path"`
+	VisitedAt time.Time `json:"visitedAt"`
+}
+
+// recentEntry is a live worktree joined with its most recent visit, if any.
+type recentEntry struct {
+	Repo      string     `json:"repo"`
+	Branch    string     `json:"branch"`
+	Path      string     `json:"path"`
+	VisitedAt *time.Time `json:"visitedAt,omitempty"`
+}
+
+// stateDir returns the directory where wt keeps its own state files.
+func stateDir() string {
+	if dir := os.Getenv("WT_STATE_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "wt")
+	}
+	if runtime.GOOS == "windows" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			return filepath.Join(dir, "wt")
+		}
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "state", "wt")
+}
+
+func historyFile() string {
+	return filepath.Join(stateDir(), "history.json")
+}
+
+func loadHistory() ([]visit, error) {
+	data, err := os.ReadFile(historyFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var history []visit
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", historyFile(), err)
+	}
+	return history, nil
+}
+
+func saveHistory(history []visit) error {
+	if len(history) > maxHistoryEntries {
+		history = history[:maxHistoryEntries]
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir(), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(historyFile(), data, 0o644)
+}
+
+// recordVisit stores path as the most recently visited worktree. History is
+// a convenience, so failures are silently ignored.
+func recordVisit(path string) {
+	repo, err := getRepoName()
+	if err != nil {
+		return
+	}
+	history, err := loadHistory()
+	if err != nil {
+		return
+	}
+	path = filepath.Clean(path)
+	updated := []visit{{Repo: repo, Path: path, VisitedAt: time.Now().UTC()}}
+	for _, v := range history {
+		if v.Path != path {
+			updated = append(updated, v)
+		}
+	}
+	_ = saveHistory(updated)
+}
+
+// mergeRecent joins the visit history of repo with the live worktree listing.
+// Visited worktrees come first, most recent first, followed by the remaining
+// worktrees sorted by branch. History entries for repo whose path is no longer
+// a worktree are returned as stale so the caller can prune them.
+func mergeRecent(repo string, history []visit, worktrees []Worktree) ([]recentEntry, []visit) {
+	live := make(map[string]Worktree)
+	for _, wt := range worktrees {
+		if wt.Bare {
+			continue
+		}
+		live[filepath.Clean(wt.Path)] = wt
+	}
+
+	visits := make([]visit, 0, len(history))
+	for _, v := range history {
+		if v.Repo == repo {
+			visits = append(visits, v)
+		}
+	}
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visits[i].VisitedAt.After(visits[j].VisitedAt)
+	})
+
+	var entries []recentEntry
+	var stale []visit
+	seen := make(map[string]bool)
+	for _, v := range visits {
+		path := filepath.Clean(v.Path)
+		wt, ok := live[path]
+		if !ok {
+			stale = append(stale, v)
+			continue
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		visitedAt := v.VisitedAt
+		entries = append(entries, recentEntry{Repo: repo, Branch: worktreeLabel(wt), Path: wt.Path, VisitedAt: &visitedAt})
+	}
+
+	var rest []recentEntry
+	for path, wt := range live {
+		if !seen[path] {
+			rest = append(rest, recentEntry{Repo: repo, Branch: worktreeLabel(wt), Path: wt.Path})
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		if rest[i].Branch != rest[j].Branch {
+			return rest[i].Branch < rest[j].Branch
+		}
+		return rest[i].Path < rest[j].Path
+	})
+
+	return append(entries, rest...), stale
+}
+
+// worktreeLabel returns the branch of a worktree, or a description of its
+// detached state.
+func worktreeLabel(wt Worktree) string {
+	if wt.Branch != "" {
+		return wt.Branch
+	}
+	if len(wt.Head) >= 7 {
+		return fmt.Sprintf("(detached %s)", wt.Head[:7])
+	}
+	return "(detached)"
+}
+
+// pruneHistory removes the given stale visits from the history file.
+func pruneHistory(stale []visit) {
+	if len(stale) == 0 {
+		return
+	}
+	history, err := loadHistory()
+	if err != nil {
+		return
+	}
+	drop := make(map[string]bool)
+	for _, v := range stale {
+		drop[v.Repo+"\x00"+filepath.Clean(v.Path)] = true
+	}
+	kept := history[:0]
+	for _, v := range history {
+		if !drop[v.Repo+"\x00"+filepath.Clean(v.Path)] {
+			kept = append(kept, v)
+		}
+	}
+	_ = saveHistory(kept)
+}
+
+// recentWorktrees returns the live worktrees of repo ordered by recency and
+// prunes history entries pointing at worktrees that no longer exist. When repo
+// is not the current repository, the listing is taken from any of its
+// remembered worktree paths that still exists.
+func recentWorktrees(repo string) ([]recentEntry, error) {
+	history, err := loadHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	var worktrees []Worktree
+	if current, err := getRepoName(); err == nil && current == repo {
+		worktrees, err = listWorktrees("")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, v := range history {
+			if v.Repo != repo {
+				continue
+			}
+			if info, err := os.Stat(v.Path); err == nil && info.IsDir() {
+				if worktrees, err = listWorktrees(v.Path); err == nil {
+					break
+				}
+			}
+		}
+	}
+
+	entries, stale := mergeRecent(repo, history, worktrees)
+	pruneHistory(stale)
+	return entries, nil
+}
+
+// formatAge renders a duration compactly, e.g. "45s", "3h", "2d", "5w".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 14*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	default:
+		return fmt.Sprintf("%dw", int(d.Hours()/(24*7)))
+	}
+}
+
+// knownRepos returns the repositories with a directory under root or an entry
+// in history, sorted and without duplicates. It only reads the filesystem so
+// it is fast enough for shell completion.
+func knownRepos(root string, history []visit) []string {
+	seen := make(map[string]bool)
+	if entries, err := os.ReadDir(root); err == nil {
+		for _, e := range entries {
+			if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+				seen[e.Name()] = true
+			}
+		}
+	}
+	for _, v := range history {
+		if v.Repo != "" {
+			seen[v.Repo] = true
+		}
+	}
+	repos := make([]string, 0, len(seen))
+	for repo := range seen {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+// completeRepos offers known repository names for --repo.
+func completeRepos(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	history, _ := loadHistory()
+	return knownRepos(resolveWorktreeRoot(cmd), history), cobra.ShellCompDirectiveNoFileComp
+}
+
+var recentCmd = &cobra.Command{
+	Use:   "recent",
+	Short: "List recently visited worktrees",
+	Long: `List the worktrees you most recently navigated to, most recent first.
+
+Only worktrees that still exist are shown; history entries for removed
+worktrees are pruned as a side effect.
+
+Examples:
+  wt recent                     # Recent worktrees of the current repository
+  wt recent --repo api          # Recent worktrees of another repository
+  wt recent --json --limit 10   # Jump list for editor integrations`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, _ := cmd.Flags().GetString("repo")
+		limit, _ := cmd.Flags().GetInt("limit")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		if repo == "" {
+			current, err := getRepoName()
+			if err != nil {
+				return fmt.Errorf("%w (use --repo to select a repository)", err)
+			}
+			repo = current
+		}
+
+		entries, err := recentWorktrees(repo)
+		if err != nil {
+			return err
+		}
+
+		visited := []recentEntry{}
+		for _, e := range entries {
+			if e.VisitedAt == nil {
+				break
+			}
+			visited = append(visited, e)
+		}
+		if limit > 0 && len(visited) > limit {
+			visited = visited[:limit]
+		}
+		for i := range visited {
+			visited[i].Path = displayPath(visited[i].Path)
+		}
+
+		if asJSON {
+			return writeJSON(os.Stdout, visited)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, e := range visited {
+			fmt.Fprintf(w, "%s\t%s\t%s ago\n", e.Branch, e.Path, formatAge(time.Since(*e.VisitedAt)))
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	recentCmd.Flags().Int("limit", 0, "Maximum number of entries to show (0 for all)")
+	recentCmd.Flags().String("repo", "", "Repository name (default: current repository)")
+	_ = recentCmd.RegisterFlagCompletionFunc("repo", completeRepos)
+	recentCmd.Flags().Bool("json", false, "Output as JSON")
+}
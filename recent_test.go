@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeRecent(t *testing.T) {
+	now := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	worktrees := []Worktree{
+		{Path: filepath.Clean("/src/repo"), Branch: "main"},
+		{Path: filepath.Clean("/trees/repo/zeta"), Branch: "zeta"},
+		{Path: filepath.Clean("/trees/repo/alpha"), Branch: "alpha"},
+		{Path: filepath.Clean("/trees/repo/beta"), Branch: "beta"},
+		{Path: filepath.Clean("/trees/repo/gamma"), Branch: "gamma"},
+	}
+	history := []visit{
+		{Repo: "repo", Path: "/trees/repo/beta", VisitedAt: now.Add(-3 * time.Hour)},
+		{Repo: "repo", Path: "/trees/repo/zeta", VisitedAt: now.Add(-1 * time.Hour)},
+		{Repo: "repo", Path: "/trees/repo/deleted", VisitedAt: now.Add(-2 * time.Hour)},
+		{Repo: "other", Path: "/trees/other/alpha", VisitedAt: now},
+	}
+
+	entries, stale := mergeRecent("repo", history, worktrees)
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Branch)
+	}
+	want := []string{"zeta", "beta", "alpha", "gamma", "main"}
+	if len(got) != len(want) {
+		t.Fatalf("mergeRecent() order = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("mergeRecent() order = %v, want %v", got, want)
+		}
+	}
+
+	if entries[0].VisitedAt == nil || !entries[0].VisitedAt.Equal(now.Add(-1*time.Hour)) {
+		t.Errorf("mergeRecent() first entry visitedAt = %v", entries[0].VisitedAt)
+	}
+	if entries[2].VisitedAt != nil {
+		t.Errorf("mergeRecent() unvisited entry should have no visitedAt, got %v", entries[2].VisitedAt)
+	}
+
+	if len(stale) != 1 || stale[0].Path != "/trees/repo/deleted" {
+		t.Errorf("mergeRecent() stale = %+v, want only the deleted worktree", stale)
+	}
+}
+
+func TestMergeRecentSkipsBareAndDuplicates(t *testing.T) {
+	now := time.Now()
+	worktrees := []Worktree{
+		{Path: filepath.Clean("/src/repo.git"), Bare: true},
+		{Path: filepath.Clean("/trees/repo/feature"), Branch: "feature"},
+	}
+	history := []visit{
+		{Repo: "repo", Path: "/trees/repo/feature", VisitedAt: now},
+		{Repo: "repo", Path: "/trees/repo/feature/", VisitedAt: now.Add(-time.Hour)},
+		{Repo: "repo", Path: "/src/repo.git", VisitedAt: now.Add(-2 * time.Hour)},
+	}
+
+	entries, stale := mergeRecent("repo", history, worktrees)
+	if len(entries) != 1 || entries[0].Branch != "feature" {
+		t.Fatalf("mergeRecent() = %+v, want only the feature worktree", entries)
+	}
+	if len(stale) != 1 {
+		t.Errorf("mergeRecent() stale = %+v, want the bare repository entry", stale)
+	}
+}
+
+func TestRecordVisitAndPrune(t *testing.T) {
+	t.Setenv("WT_STATE_DIR", t.TempDir())
+
+	if err := saveHistory([]visit{
+		{Repo: "repo", Path: "/a", VisitedAt: time.Now()},
+		{Repo: "repo", Path: "/b", VisitedAt: time.Now()},
+	}); err != nil {
+		t.Fatalf("saveHistory() error = %v", err)
+	}
+
+	pruneHistory([]visit{{Repo: "repo", Path: "/a"}})
+
+	history, err := loadHistory()
+	if err != nil {
+		t.Fatalf("loadHistory() error = %v", err)
+	}
+	if len(history) != 1 || history[0].Path != "/b" {
+		t.Errorf("history after prune = %+v, want only /b", history)
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "30s"},
+		{5 * time.Minute, "5m"},
+		{3 * time.Hour, "3h"},
+		{3 * 24 * time.Hour, "3d"},
+		{15 * 24 * time.Hour, "2w"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestKnownRepos(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"api", "web", ".cache"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	history := []visit{{Repo: "cli"}, {Repo: "api"}}
+
+	got := knownRepos(root, history)
+	want := []string{"api", "cli", "web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("knownRepos() = %v, want %v", got, want)
+	}
+	if got := knownRepos(filepath.Join(root, "missing"), nil); len(got) != 0 {
+		t.Errorf("knownRepos() with missing root = %v, want none", got)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+// removalPlan is what `wt rm` is about to do. The confirmation, --dry-run and
+// --dry-run --json all render the same plan, and the removal carries it out,
+// so the preview cannot diverge from what happens.
+type removalPlan struct {
+	Branch string `json:"branch"`
+	Path   string `json:"path"`
+	// ModifiedFiles and UntrackedFiles count the changes that make git
+	// refuse the removal.
+	ModifiedFiles  int                `json:"modifiedFiles"`
+	UntrackedFiles int                `json:"untrackedFiles"`
+	Backup         *removalBackup     `json:"backup,omitempty"`
+	DeleteBranch   *branchDeletion    `json:"deleteBranch,omitempty"`
+	ReviewCleanup  *reviewCleanupPlan `json:"reviewCleanup,omitempty"`
+	// CdTo is where the shell integration switches to, when the current
+	// directory is in the removed worktree.
+	CdTo string `json:"cdTo,omitempty"`
+	// Force removes the worktree even with modified or untracked files,
+	// which are lost, or while other processes use it.
+	Force bool `json:"force,omitempty"`
+	// Processes are the PIDs of other processes whose current directory is
+	// in the worktree, as far as wt can tell.
+	Processes []int `json:"processes,omitempty"`
+	// Problems are reasons the removal would fail; wt refuses to start it.
+	Problems []string `json:"problems"`
+
+	mainPath string
+	review   string
+}
+
+// removalBackup is the backup of ignored files taken before the removal.
+type removalBackup struct {
+	Dir   string   `json:"dir"`
+	Files []string `json:"files"`
+}
+
+// branchDeletion is how --delete-branch will treat the branch.
+type branchDeletion struct {
+	// Force deletes the branch even if it is not merged, as for review
+	// branches wt fetched.
+	Force bool `json:"force"`
+	// Skipped says why the branch is kept, e.g. because wt did not create it.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// reviewCleanupPlan is the PR/MR state dropped with the worktree.
+type reviewCleanupPlan struct {
+	Review string `json:"review"`
+}
+
+// planRemoval gathers what removing the worktree of branch entails, with the
+// flags of cmd, without changing anything.
+func planRemoval(cmd *cobra.Command, branch string, now time.Time) (removalPlan, error) {
+	path, exists := worktreeExists(branch)
+	if !exists {
+		return removalPlan{}, fmt.Errorf("no worktree found for branch: %s", branch)
+	}
+	plan := removalPlan{Branch: branch, Path: path, Problems: []string{}}
+	plan.mainPath, _ = mainWorktreePath()
+	if sameDir(path, plan.mainPath) {
+		plan.Problems = append(plan.Problems, "it is the main worktree, which cannot be removed")
+	}
+
+	// When git status fails, e.g. for a worktree whose directory is gone,
+	// git worktree remove has the final say.
+	modified, untracked, _ := countChanges(path)
+	plan.ModifiedFiles, plan.UntrackedFiles = modified, untracked
+	plan.Force, _ = cmd.Flags().GetBool("force")
+	if modified+untracked > 0 && !plan.Force {
+		plan.Problems = append(plan.Problems, "git refuses to remove a worktree with modified or untracked files; commit, stash or clean them first")
+	}
+
+	if noProcessCheck, _ := cmd.Flags().GetBool("no-process-check"); !noProcessCheck {
+		plan.Processes = processesInWorktree(path)
+	}
+
+	noBackup, _ := cmd.Flags().GetBool("no-backup")
+	if patterns := getConfig().PreRemoveBackup; len(patterns) > 0 && !noBackup {
+		repo, err := getRepoName()
+		if err != nil {
+			return plan, err
+		}
+		files, err := backupFiles(path, patterns)
+		if err != nil {
+			return plan, err
+		}
+		if len(files) > 0 {
+			plan.Backup = &removalBackup{Dir: backupDirFor(repo, branch, now), Files: files}
+		}
+	}
+
+	plan.review = lookupReview(plan.mainPath, branch)
+	deleteBranchFlag, _ := cmd.Flags().GetBool("delete-branch")
+	if deleteBranchFlag {
+		includeUnowned, _ := cmd.Flags().GetBool("include-unowned")
+		plan.DeleteBranch = &branchDeletion{Force: plan.review != "" && worktree.SameBranch(plan.review, branch)}
+		if !includeUnowned && !isBranchOwned(plan.mainPath, branch, plan.review) {
+			plan.DeleteBranch.Skipped = "it was not created by wt (use --include-unowned or 'wt adopt " + branch + "')"
+		}
+	}
+	reviewCleanup, _ := cmd.Flags().GetBool("review-cleanup")
+	if !cmd.Flags().Changed("review-cleanup") {
+		reviewCleanup = deleteBranchFlag && plan.review != ""
+	}
+	if reviewCleanup && plan.review != "" {
+		plan.ReviewCleanup = &reviewCleanupPlan{Review: plan.review}
+	}
+
+	if current, err := currentWorktreePath(); err == nil && sameDir(current, path) && plan.mainPath != "" {
+		plan.CdTo = plan.mainPath
+	}
+	return plan, nil
+}
+
+// lines describes the plan, one step per line, with paths as wt prints them.
+func (p removalPlan) lines() []string {
+	lines := []string{"Remove the worktree " + displayPath(p.Path)}
+	if p.ModifiedFiles+p.UntrackedFiles > 0 {
+		line := fmt.Sprintf("  with %d modified and %d untracked file(s)", p.ModifiedFiles, p.UntrackedFiles)
+		if p.Force {
+			line += ", which are lost (--force)"
+		}
+		lines = append(lines, line)
+	}
+	if len(p.Processes) > 0 {
+		line := "  while " + describeProcesses(p.Processes)
+		if p.Force {
+			line += "; they are left in a deleted directory (--force)"
+		}
+		lines = append(lines, line)
+	}
+	if p.Backup != nil {
+		lines = append(lines, fmt.Sprintf("Back up %d ignored file(s) to %s first", len(p.Backup.Files), displayPath(p.Backup.Dir)))
+	}
+	if d := p.DeleteBranch; d != nil {
+		switch {
+		case d.Skipped != "":
+			lines = append(lines, "Keep the branch "+p.Branch+": "+d.Skipped)
+		case d.Force:
+			lines = append(lines, "Delete the branch "+p.Branch+", even if not merged")
+		default:
+			lines = append(lines, "Delete the branch "+p.Branch+" if it is merged")
+		}
+	}
+	if r := p.ReviewCleanup; r != nil {
+		lines = append(lines, "Clear the metadata of "+r.Review)
+	}
+	if p.CdTo != "" {
+		lines = append(lines, "Switch to "+displayPath(p.CdTo))
+	}
+	for _, problem := range p.Problems {
+		lines = append(lines, "✗ Cannot remove: "+problem)
+	}
+	return lines
+}
+
+// check returns an error naming the problems of the plan, if any.
+func (p removalPlan) check() error {
+	if len(p.Problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("cannot remove %s: %s", displayPath(p.Path), strings.Join(p.Problems, "; "))
+}
+
+// confirmProcesses asks again before removing a worktree that other
+// processes use, when --yes skipped the confirmation showing them: only
+// --force or an answer goes ahead.
+func (p removalPlan) confirmProcesses(cmd *cobra.Command) error {
+	if len(p.Processes) == 0 || p.Force {
+		return nil
+	}
+	if assumeYes, _ := cmd.Flags().GetBool("yes"); !assumeYes && !getConfig().AssumeYes {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%s\nPass --force to remove it anyway, or --no-process-check to skip the check", describeProcesses(p.Processes))
+	}
+	fmt.Fprintln(cmd.ErrOrStderr(), describeProcesses(p.Processes))
+	ok, err := promptFor(cmd).Confirm("Remove it anyway")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errCancelled
+	}
+	return nil
+}
+
+// printRemovalPlan prints the preview of `wt rm --dry-run`.
+func printRemovalPlan(w io.Writer, p removalPlan) {
+	for _, line := range p.lines() {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w, "Dry run: nothing was changed.")
+}
+
+// displayed returns the plan with paths as wt prints them.
+func (p removalPlan) displayed() removalPlan {
+	p.Path = displayPath(p.Path)
+	if p.CdTo != "" {
+		p.CdTo = displayPath(p.CdTo)
+	}
+	if p.Backup != nil {
+		backup := *p.Backup
+		backup.Dir = displayPath(backup.Dir)
+		p.Backup = &backup
+	}
+	return p
+}
+
+// writeRemovalPlanJSON prints the plan as JSON, with paths as wt prints them.
+func writeRemovalPlanJSON(p removalPlan) error {
+	return writeJSON(os.Stdout, p.displayed())
+}
+
+// carryOutRemoval removes the worktree of a checked plan: the backup first,
+// then the worktree, recorded for `wt undo`, then the cleanup steps, whose
+// outcome is summarized without failing the removal. It never switches
+// directories.
+func carryOutRemoval(cmd *cobra.Command, plan removalPlan) error {
+	if plan.Backup != nil {
+		if err := copyBackup(plan.Path, plan.Backup.Dir, plan.Backup.Files); err != nil {
+			return fmt.Errorf("%w\nThe worktree was not removed; pass --no-backup to remove it without a backup", err)
+		}
+		repo, _ := getRepoName()
+		if err := pruneBackups(repo, plan.Branch, getConfig().KeepBackups); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to prune old backups: %v\n", err)
+		}
+	}
+
+	entry, err := recordRemoval(plan, time.Now())
+	recorded := err == nil
+	if !recorded {
+		fmt.Fprintf(os.Stderr, "warning: 'wt undo' will not be able to restore %s: %v\n", plan.Branch, err)
+	}
+	if _, err := newManager("").Remove(plan.Branch, worktree.RemoveOptions{Force: plan.Force}); err != nil {
+		if recorded {
+			dropUndoRefs(entry)
+		}
+		return err
+	}
+	if recorded {
+		if err := pushUndoEntry(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to record the removal for 'wt undo': %v\n", err)
+		}
+	}
+
+	infof("✓ Removed worktree: %s\n", displayPath(plan.Path))
+	forgetWorktree(plan.mainPath, plan.Path)
+
+	// Run cleanup from the main worktree; the current directory may be gone.
+	var steps []cleanupStep
+	if plan.DeleteBranch != nil {
+		includeUnowned, _ := cmd.Flags().GetBool("include-unowned")
+		steps = append(steps, deleteBranch(plan.mainPath, plan.Branch, plan.review, includeUnowned))
+	}
+	if plan.ReviewCleanup != nil {
+		steps = append(steps, cleanupReview(plan.mainPath, plan.review)...)
+	}
+	printCleanupSummary(os.Stderr, steps)
+	if plan.Backup != nil {
+		infof("✓ Backed up %d ignored file(s) to %s\n", len(plan.Backup.Files), displayPath(plan.Backup.Dir))
+	}
+	return nil
+}
+
+// keptWorktree is a worktree `wt rm --others` leaves alone, and why.
+type keptWorktree struct {
+	Branch string `json:"branch,omitempty"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// planOtherRemovals plans the removal of every worktree except the main one
+// and the one containing the current directory. Pinned, locked and detached
+// worktrees are kept, as are those whose removal has problems, e.g. changes
+// without --force.
+func planOtherRemovals(cmd *cobra.Command, now time.Time) ([]removalPlan, []keptWorktree, error) {
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		return nil, nil, err
+	}
+	current, _ := currentWorktreePath()
+	pinned := loadPinnedBranches()
+	var plans []removalPlan
+	var kept []keptWorktree
+	for i, wt := range worktrees {
+		if i == 0 || wt.Bare || (current != "" && sameDir(wt.Path, current)) {
+			continue
+		}
+		keep := func(reason string) {
+			kept = append(kept, keptWorktree{Branch: wt.Branch, Path: wt.Path, Reason: reason})
+		}
+		switch {
+		case wt.Branch == "":
+			keep("detached HEAD; remove it with 'git worktree remove'")
+			continue
+		case pinned[wt.Branch]:
+			keep("pinned (see 'wt unpin')")
+			continue
+		case wt.Locked:
+			reason := "locked"
+			if wt.LockReason != "" {
+				reason += ": " + wt.LockReason
+			}
+			keep(reason)
+			continue
+		case wt.Prunable:
+			keep("its directory is gone; run 'wt prune'")
+			continue
+		}
+		plan, err := planRemoval(cmd, wt.Branch, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case plan.ModifiedFiles+plan.UntrackedFiles > 0 && !plan.Force:
+			keep(fmt.Sprintf("%d modified and %d untracked file(s); pass --force to remove it anyway", plan.ModifiedFiles, plan.UntrackedFiles))
+		case len(plan.Processes) > 0 && !plan.Force:
+			keep(describeProcesses(plan.Processes) + "; pass --force to remove it anyway")
+		case len(plan.Problems) > 0:
+			keep(strings.Join(plan.Problems, "; "))
+		default:
+			plans = append(plans, plan)
+		}
+	}
+	return plans, kept, nil
+}
+
+// name is the branch of the kept worktree, or its path when detached.
+func (k keptWorktree) name() string {
+	if k.Branch == "" {
+		return displayPath(k.Path)
+	}
+	return k.Branch
+}
+
+// otherRemovalLines describes what `wt rm --others` removes and keeps.
+func otherRemovalLines(plans []removalPlan, kept []keptWorktree) []string {
+	var lines []string
+	for _, plan := range plans {
+		lines = append(lines, plan.lines()...)
+	}
+	for _, k := range kept {
+		lines = append(lines, "Keep "+k.name()+": "+k.Reason)
+	}
+	return lines
+}
+
+// removeOtherWorktrees is `wt rm --others`: it removes the planned worktrees
+// one by one after a single confirmation and exits with status 1 unless all
+// of them were removed. The current directory stays, so there is no cd.
+func removeOtherWorktrees(cmd *cobra.Command) error {
+	plans, kept, err := planOtherRemovals(cmd, time.Now())
+	if err != nil {
+		return err
+	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			preview := struct {
+				Remove []removalPlan  `json:"remove"`
+				Keep   []keptWorktree `json:"keep"`
+			}{[]removalPlan{}, []keptWorktree{}}
+			for _, plan := range plans {
+				preview.Remove = append(preview.Remove, plan.displayed())
+			}
+			for _, k := range kept {
+				k.Path = displayPath(k.Path)
+				preview.Keep = append(preview.Keep, k)
+			}
+			return writeJSON(os.Stdout, preview)
+		}
+		for _, line := range otherRemovalLines(plans, kept) {
+			fmt.Println(line)
+		}
+		fmt.Println("Dry run: nothing was changed.")
+		return nil
+	}
+	if len(plans) == 0 {
+		for _, line := range otherRemovalLines(nil, kept) {
+			infoln(line)
+		}
+		infoln("No other worktrees to remove")
+		return nil
+	}
+	if err := checkRepoProtection(cmd, "remove every other worktree"); err != nil {
+		return err
+	}
+	if err := confirmAction(cmd, fmt.Sprintf("Remove %d worktree(s)", len(plans)), otherRemovalLines(plans, kept)...); err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, plan := range plans {
+		if err := carryOutRemoval(cmd, plan); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ Could not remove %s: %v\n", plan.Branch, err)
+			continue
+		}
+		removed++
+	}
+	infof("Removed %d of %d worktree(s), kept %d\n", removed, len(plans), len(kept))
+	for _, k := range kept {
+		infof("  %s: %s\n", k.name(), k.Reason)
+	}
+	if removed < len(plans) {
+		return exitWithCode(cmd, 1)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// removeFlags are the flags planRemoval reads.
+var removeFlags = []string{"delete-branch", "include-unowned", "no-backup", "review-cleanup", "force"}
+
+func TestRemovalPlanLines(t *testing.T) {
+	plan := removalPlan{
+		Branch:         "pr-512",
+		Path:           filepath.FromSlash("/trees/api/pr-512"),
+		ModifiedFiles:  1,
+		UntrackedFiles: 2,
+		Backup:         &removalBackup{Dir: filepath.FromSlash("/trees/.backups/api/pr-512-x"), Files: []string{".idea/a.xml"}},
+		DeleteBranch:   &branchDeletion{Force: true},
+		ReviewCleanup:  &reviewCleanupPlan{Review: "pr-512"},
+		CdTo:           filepath.FromSlash("/src/api"),
+		Problems:       []string{"dirty"},
+	}
+	want := []string{
+		"Remove the worktree " + filepath.FromSlash("/trees/api/pr-512"),
+		"  with 1 modified and 2 untracked file(s)",
+		"Back up 1 ignored file(s) to " + filepath.FromSlash("/trees/.backups/api/pr-512-x") + " first",
+		"Delete the branch pr-512, even if not merged",
+		"Clear the metadata of pr-512",
+		"Switch to " + filepath.FromSlash("/src/api"),
+		"✗ Cannot remove: dirty",
+	}
+	if got := plan.lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("lines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	plan.DeleteBranch = &branchDeletion{Skipped: "it was not created by wt"}
+	if got := plan.lines()[3]; got != "Keep the branch pr-512: it was not created by wt" {
+		t.Errorf("lines() for a skipped branch = %q", got)
+	}
+	if err := plan.check(); err == nil || !strings.Contains(err.Error(), "dirty") {
+		t.Errorf("check() = %v, want the problem", err)
+	}
+}
+
+func TestPlanRemoval(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	path := filepath.Join(worktreeRoot, "repo", "feature-x")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", path, "feature-x")
+	writeTree(t, path, map[string]int{"data.bin": 1, ".idea/workspace.xml": 10})
+	if err := os.WriteFile(filepath.Join(path, ".gitignore"), []byte(".idea/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, path, "add", ".gitignore", "data.bin")
+	runGitCommand(t, path, "commit", "-q", "-m", "ignore .idea")
+	stubConfirm(t, false, &Config{PreRemoveBackup: []string{".idea"}})
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	plan, err := planRemoval(newFlagTestCmd(t, removeFlags, "--delete-branch"), "feature-x", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameDir(plan.Path, path) || plan.ModifiedFiles != 0 || plan.UntrackedFiles != 0 || len(plan.Problems) != 0 {
+		t.Errorf("planRemoval() = %+v, want a clean worktree at %s", plan, path)
+	}
+	wantBackup := &removalBackup{Dir: backupDirFor("repo", "feature-x", now), Files: []string{".idea/workspace.xml"}}
+	if !reflect.DeepEqual(plan.Backup, wantBackup) {
+		t.Errorf("planRemoval() backup = %+v, want %+v", plan.Backup, wantBackup)
+	}
+	if d := plan.DeleteBranch; d == nil || d.Force || !strings.Contains(d.Skipped, "not created by wt") {
+		t.Errorf("planRemoval() branch deletion = %+v, want it skipped for an unowned branch", d)
+	}
+	if plan.ReviewCleanup != nil || plan.CdTo != "" {
+		t.Errorf("planRemoval() = %+v, want no review cleanup and no cd", plan)
+	}
+
+	// From inside the worktree, with changes.
+	writeTree(t, path, map[string]int{"new.txt": 1, "data.bin": 3})
+	t.Chdir(path)
+	plan, err = planRemoval(newFlagTestCmd(t, removeFlags, "--no-backup"), "feature-x", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.ModifiedFiles != 1 || plan.UntrackedFiles != 1 || len(plan.Problems) != 1 || plan.check() == nil {
+		t.Errorf("planRemoval() of a dirty worktree = %+v, want 1 modified, 1 untracked and a problem", plan)
+	}
+	if plan.Backup != nil || plan.DeleteBranch != nil || !sameDir(plan.CdTo, repoDir) {
+		t.Errorf("planRemoval() = %+v, want no backup or deletion and a cd to %s", plan, repoDir)
+	}
+
+	// --dry-run --json and the confirmation render this plan; nothing changed.
+	var decoded map[string]any
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"branch", "path", "modifiedFiles", "untrackedFiles", "cdTo", "problems"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("JSON plan has no %q: %s", key, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(path, "new.txt")); err != nil {
+		t.Errorf("planRemoval() changed the worktree: %v", err)
+	}
+}
+
+func TestRemoveOthers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	stubConfirm(t, false, &Config{})
+	paths := map[string]string{}
+	for _, branch := range []string{"done", "dirty", "pinned", "locked", "here"} {
+		runGitCommand(t, repoDir, "branch", branch)
+		paths[branch] = filepath.Join(worktreeRoot, "repo", branch)
+		runGitCommand(t, repoDir, "worktree", "add", "-q", paths[branch], branch)
+	}
+	paths["detached"] = filepath.Join(worktreeRoot, "repo", "detached")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "--detach", paths["detached"], "main")
+	writeTree(t, paths["dirty"], map[string]int{"scratch.txt": 1})
+	runGitCommand(t, repoDir, "config", pinnedKey("pinned"), "true")
+	runGitCommand(t, repoDir, "worktree", "lock", "--reason", "on a USB stick", paths["locked"])
+	t.Chdir(paths["here"])
+
+	plans, kept, err := planOtherRemovals(newFlagTestCmd(t, removeFlags), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 1 || plans[0].Branch != "done" {
+		t.Errorf("planOtherRemovals() removes %+v, want only done", plans)
+	}
+	reasons := map[string]string{}
+	for _, k := range kept {
+		reasons[k.name()] = k.Reason
+	}
+	for name, want := range map[string]string{
+		"dirty":                        "pass --force",
+		"pinned":                       "pinned",
+		"locked":                       "on a USB stick",
+		displayPath(paths["detached"]): "detached HEAD",
+	} {
+		if !strings.Contains(reasons[name], want) {
+			t.Errorf("%s kept for %q, want %q", name, reasons[name], want)
+		}
+	}
+	if _, ok := reasons["here"]; ok || len(kept) != 4 {
+		t.Errorf("kept = %+v, want the current worktree left out of the listing", kept)
+	}
+
+	for _, flag := range []string{"others", "force", "yes"} {
+		if err := removeCmd.Flags().Set(flag, "true"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = removeCmd.Flags().Set(flag, "false") })
+	}
+	if err := removeCmd.RunE(removeCmd, nil); err != nil {
+		t.Fatalf("rm --others --force --yes: %v", err)
+	}
+	for branch, path := range paths {
+		_, err := os.Stat(path)
+		if gone := os.IsNotExist(err); gone != (branch == "done" || branch == "dirty") {
+			t.Errorf("%s: removed = %v after rm --others --force", branch, gone)
+		}
+	}
+	if err := removeCmd.RunE(removeCmd, []string{"pinned"}); err == nil {
+		t.Error("rm --others with a branch should fail")
+	}
+}
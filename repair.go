@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// findWorktreeDirs returns the linked worktrees below dir: directories with a
+// .git file. Directories with a .git directory (a main clone in the nested
+// layout) are skipped, and nothing below a worktree is searched.
+func findWorktreeDirs(dir string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		info, err := os.Stat(filepath.Join(path, ".git"))
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return filepath.SkipDir
+	})
+	return dirs, err
+}
+
+// belongsToRepo reports whether the worktree at path was created from the
+// repository with the given common dir: its .git file must name one of the
+// repository's worktree entries. The pointer may still use the old location
+// of a moved repository, so only the entry name is compared.
+func belongsToRepo(path, commonDir string) bool {
+	data, err := os.ReadFile(filepath.Join(path, ".git"))
+	if err != nil {
+		return false
+	}
+	gitdir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return false
+	}
+	gitdir = filepath.Clean(filepath.FromSlash(gitdir))
+	if filepath.Base(filepath.Dir(gitdir)) != "worktrees" {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(commonDir, "worktrees", filepath.Base(gitdir)))
+	return err == nil
+}
+
+// repairResult is the outcome of `git worktree repair` for one worktree.
+type repairResult struct {
+	Path     string
+	Repaired bool
+	Err      error
+}
+
+// repairWorktrees runs `git worktree repair` from the main worktree for each
+// path. git prints a line for every link it fixes, so any output means the
+// worktree was broken.
+func repairWorktrees(mainPath string, paths []string) []repairResult {
+	results := make([]repairResult, len(paths))
+	for i, path := range paths {
+		results[i].Path = path
+		output, err := gitIn(mainPath, "worktree", "repair", path).CombinedOutput()
+		if err != nil {
+			results[i].Err = fmt.Errorf("%s", firstLine(string(output)))
+			continue
+		}
+		results[i].Repaired = strings.TrimSpace(string(output)) != ""
+	}
+	return results
+}
+
+// brokenWorktrees returns the linked worktrees git still cannot find, or in
+// which git commands fail because their .git file points nowhere.
+func brokenWorktrees(worktrees []Worktree) []Worktree {
+	var broken []Worktree
+	for i, wt := range worktrees {
+		if i == 0 || wt.Bare {
+			continue
+		}
+		if wt.Prunable || gitIn(wt.Path, "rev-parse", "--git-dir").Run() != nil {
+			broken = append(broken, wt)
+		}
+	}
+	return broken
+}
+
+func printRepairSummary(w io.Writer, results []repairResult, broken []Worktree) {
+	repaired := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(w, "✗ Could not repair %s: %v\n", r.Path, r.Err)
+		case r.Repaired:
+			repaired++
+			fmt.Fprintf(w, "✓ Repaired %s\n", r.Path)
+		}
+	}
+	if repaired == 0 && len(broken) == 0 {
+		fmt.Fprintln(w, "✓ All worktrees are linked correctly")
+	}
+	for _, wt := range broken {
+		fmt.Fprintf(w, "✗ Still broken: %s\n", wt.Path)
+	}
+	if len(broken) > 0 {
+		fmt.Fprintln(w, "If these worktrees were moved elsewhere, run 'wt repair <new path>...';")
+		fmt.Fprintln(w, "if they were deleted, run 'wt prune'. When the main repository itself was")
+		fmt.Fprintln(w, "moved, run 'wt repair' from its new location.")
+	}
+}
+
+var repairCmd = &cobra.Command{
+	Use:   "repair [path...]",
+	Short: "Fix worktree links after moving worktrees by hand",
+	Long: `Fix the links between the repository and its worktrees.
+
+After moving WORKTREE_ROOT with mv, git no longer finds the worktrees and
+'git worktree prune' would drop them; after moving the main repository, git
+commands inside the worktrees fail. Run 'wt repair' from the main repository:
+it runs 'git worktree repair' for every worktree of the repository under the
+current WORKTREE_ROOT (or for the given paths), reports which links were fixed
+and lists the worktrees git still cannot find.
+
+Examples:
+  wt repair                             # Repair all worktrees under WORKTREE_ROOT
+  wt repair ~/elsewhere/api/feature-x   # Repair a worktree moved elsewhere`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireGit(capWorktreeRepair); err != nil {
+			return err
+		}
+		repo, err := getRepoName()
+		if err != nil {
+			return fmt.Errorf("%w (run 'wt repair' from the main repository)", err)
+		}
+		mainPath, err := mainWorktreePath()
+		if err != nil {
+			return err
+		}
+
+		paths := args
+		if len(paths) == 0 {
+			commonDir, err := gitCommonDir(mainPath)
+			if err != nil {
+				return err
+			}
+			dirs, err := findWorktreeDirs(filepath.Join(worktreeRoot, repo))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			for _, dir := range dirs {
+				if belongsToRepo(dir, commonDir) {
+					paths = append(paths, dir)
+				}
+			}
+		}
+		for i, path := range paths {
+			path = hostPath(path)
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			paths[i] = resolvePath(path)
+		}
+
+		results := repairWorktrees(mainPath, paths)
+		worktrees, err := listWorktrees(mainPath)
+		if err != nil {
+			return err
+		}
+		broken := brokenWorktrees(worktrees)
+		printRepairSummary(os.Stderr, results, broken)
+
+		for _, r := range results {
+			if r.Err != nil {
+				return exitWithCode(cmd, 1)
+			}
+		}
+		if len(broken) > 0 {
+			return exitWithCode(cmd, 1)
+		}
+		return nil
+	},
+}
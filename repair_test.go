@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindWorktreeDirs(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"a/.git":              "gitdir: /repo/.git/worktrees/a\n",
+		"feature/b/.git":      "gitdir: /repo/.git/worktrees/b\n",
+		"feature/b/sub/.git":  "gitdir: /other\n", // nested below a worktree: ignored
+		".hidden/c/.git":      "gitdir: /repo/.git/worktrees/c\n",
+		"main/.git/HEAD":      "ref: refs/heads/main\n", // nested-main clone
+		"main/nested/d/.git":  "gitdir: /repo/.git/worktrees/d\n",
+		"plain/readme.txt":    "",
+		"feature/e/empty.txt": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := findWorktreeDirs(root)
+	if err != nil {
+		t.Fatalf("findWorktreeDirs() error = %v", err)
+	}
+	want := []string{filepath.Join(root, "a"), filepath.Join(root, "feature", "b")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findWorktreeDirs() = %v, want %v", got, want)
+	}
+}
+
+func TestBelongsToRepo(t *testing.T) {
+	dir := t.TempDir()
+	commonDir := filepath.Join(dir, "repo", ".git")
+	if err := os.MkdirAll(filepath.Join(commonDir, "worktrees", "feature-x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, ".git"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"current location", write("wt1", "gitdir: "+filepath.Join(commonDir, "worktrees", "feature-x")+"\n"), true},
+		{"repository moved since", write("wt2", "gitdir: /old/place/repo/.git/worktrees/feature-x\n"), true},
+		{"unknown entry", write("wt3", "gitdir: "+filepath.Join(commonDir, "worktrees", "other")+"\n"), false},
+		{"submodule", write("wt4", "gitdir: ../.git/modules/lib\n"), false},
+		{"no .git file", filepath.Join(dir, "missing"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := belongsToRepo(tt.path, commonDir); got != tt.want {
+				t.Errorf("belongsToRepo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Repository-level git state (refs, remotes, config) is shared by all
+// worktrees, but a few things are not: FETCH_HEAD, HEAD and the toplevel
+// directory differ per worktree. Queries and mutations of the repository go
+// through repoGit so they behave the same from the main clone and from any
+// linked worktree; worktree-level operations (status, merge, rebase) target a
+// worktree path with gitIn instead.
+
+// commonDirCache remembers the common git dir per working directory, so it is
+// resolved once per invocation.
+var commonDirCache struct {
+	cwd string
+	dir string
+}
+
+// gitCommonDir returns the absolute path of the .git directory shared by all
+// worktrees of the repository at dir (the current directory when empty).
+func gitCommonDir(dir string) (string, error) {
+	output, err := gitIn(dir, "rev-parse", "--git-common-dir").Output()
+	if err != nil {
+		return "", err
+	}
+	common := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(dir, common)
+	}
+	return filepath.Abs(common)
+}
+
+// repoCommonDir returns the common git dir of the repository in the current
+// directory.
+func repoCommonDir() (string, error) {
+	cwd, _ := os.Getwd()
+	if commonDirCache.dir != "" && commonDirCache.cwd == cwd {
+		return commonDirCache.dir, nil
+	}
+	dir, err := gitCommonDir("")
+	if err != nil {
+		return "", err
+	}
+	commonDirCache.cwd, commonDirCache.dir = cwd, dir
+	return dir, nil
+}
+
+// repoGit runs a repository-level git command against the common git dir.
+// Outside a repository it runs plain git, which then reports the error.
+// It must not resolve revisions the user typed: with --git-dir, HEAD, @ and
+// HEAD~n are the main clone's, not those of the worktree wt runs in. Resolve
+// them with gitIn("", ...) or resolveCommit instead.
+func repoGit(args ...string) *tracedCmd {
+	dir, err := repoCommonDir()
+	if err != nil {
+		return gitCommand(args...)
+	}
+	return gitCommand(append([]string{"--git-dir", dir}, args...)...)
+}
+
+// repoNameFromGitDir names a repository after its common git dir:
+// /src/api/.git and /src/api.git are both "api".
+func repoNameFromGitDir(commonDir string) string {
+	if filepath.Base(commonDir) == ".git" {
+		return filepath.Base(filepath.Dir(commonDir))
+	}
+	return strings.TrimSuffix(filepath.Base(commonDir), ".git")
+}
+
+// repoMainDir returns the directory of the main worktree of the repository
+// with the common git dir commonDir, or commonDir itself when it is bare.
+func repoMainDir(commonDir string) string {
+	if filepath.Base(commonDir) == ".git" {
+		return filepath.Dir(commonDir)
+	}
+	return commonDir
+}
+
+// warnedRepoName is set once getRepoName warned that the origin URL does not
+// name the repository, so the warning is shown once per invocation.
+var warnedRepoName bool
+
+// windowsPath matches Windows paths: C:\repo, C:/repo and \\server\share.
+var windowsPath = regexp.MustCompile(`^(?:[A-Za-z]:[\\/]|\\\\)`)
+
+// repoNameFromURL names a repository after its remote URL, accepting both
+// URLs (https://host/org/api.git, git@host:org/api.git) and local paths
+// (/srv/git/api.git, ../api, C:\git\api.git or file:// URLs). Relative
+// paths are resolved against base when it is not empty. It returns "" when
+// the URL has no usable path, e.g. ssh://git@mirror or a path such as "..".
+func repoNameFromURL(url, base string) string {
+	var p string
+	switch {
+	case strings.HasPrefix(url, "file://"):
+		p = strings.TrimPrefix(strings.TrimPrefix(url, "file://"), "localhost")
+	case windowsPath.MatchString(url):
+		p = url
+	case strings.Contains(url, "://"):
+		_, rest, _ := strings.Cut(url, "://")
+		_, p, _ = strings.Cut(rest, "/")
+		p = "/" + p
+	default:
+		// git treats host:path as scp-like syntax when the colon comes
+		// before any slash.
+		colon := strings.Index(url, ":")
+		if colon > 0 && !strings.Contains(url[:colon], "/") {
+			p = "/" + url[colon+1:]
+		} else {
+			p = url
+			if base != "" && !path.IsAbs(filepath.ToSlash(p)) && !windowsPath.MatchString(p) {
+				p = base + "/" + p
+			}
+		}
+	}
+	// Accept either separator, whichever OS the path comes from.
+	p = path.Clean(strings.ReplaceAll(p, `\`, "/"))
+	name := path.Base(p)
+	if name == ".git" {
+		name = path.Base(path.Dir(p))
+	}
+	name = strings.TrimSuffix(name, ".git")
+	switch name {
+	case "", ".", "..", "/", "~":
+		return ""
+	}
+	if strings.HasSuffix(name, ":") {
+		// A drive root such as C:.
+		return ""
+	}
+	return name
+}
+
+// remoteCache remembers per repository whether it has any remote.
+var remoteCache struct {
+	commonDir string
+	has       bool
+}
+
+// hasRemote reports whether the repository has at least one remote. Purely
+// local repositories skip lookups on origin and cannot use PR/MR commands.
+// Outside a repository it reports true, leaving the error to git.
+func hasRemote() bool {
+	dir, err := repoCommonDir()
+	if err != nil {
+		return true
+	}
+	if remoteCache.commonDir != dir {
+		output, err := repoGit("remote").Output()
+		remoteCache.commonDir, remoteCache.has = dir, err != nil || strings.TrimSpace(string(output)) != ""
+	}
+	return remoteCache.has
+}
+
+// errNoRemote is returned by the PR/MR commands in purely local repositories.
+var errNoRemote = errors.New("repository has no remote; PR/MR commands need a GitHub or GitLab remote")
+
+// requireRemote fails with errNoRemote when the repository has no remote.
+func requireRemote() error {
+	if !hasRemote() {
+		return errNoRemote
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepoNameFromGitDir(t *testing.T) {
+	tests := map[string]string{
+		filepath.FromSlash("/src/api/.git"):           "api",
+		filepath.FromSlash("/srv/git/api.git"):        "api",
+		filepath.FromSlash("/trees/api/main/.git"):    "main",
+		filepath.FromSlash("/srv/git/plain-bare-dir"): "plain-bare-dir",
+	}
+	for dir, want := range tests {
+		if got := repoNameFromGitDir(dir); got != want {
+			t.Errorf("repoNameFromGitDir(%q) = %q, want %q", dir, got, want)
+		}
+	}
+}
+
+func TestRepoNameFromURLPaths(t *testing.T) {
+	tests := []struct {
+		url, base, want string
+	}{
+		// Filesystem paths, unix and Windows.
+		{url: "/srv/git/project.git", want: "project"},
+		{url: "/srv/git/project.git/", want: "project"},
+		{url: "/srv/git/project//", want: "project"},
+		{url: "/srv/git/project/.git", want: "project"},
+		{url: "file:///srv/git/project.git", want: "project"},
+		{url: "file://localhost/srv/git/project", want: "project"},
+		{url: `C:\git\project.git`, want: "project"},
+		{url: `C:\git\project\`, want: "project"},
+		{url: "C:/git/project.git", want: "project"},
+		{url: `\\server\share\project.git`, want: "project"},
+		// Relative paths are resolved against base.
+		{url: "../other-repo", base: "/src/api", want: "other-repo"},
+		{url: "../other-repo.git/", base: "/src/api", want: "other-repo"},
+		{url: "..", base: "/src/api", want: "src"},
+		{url: "../..", base: "/src/api", want: ""},
+		{url: ".", base: "/src/api", want: "api"},
+		{url: `..\other-repo`, base: `C:\src\api`, want: "other-repo"},
+		{url: `..`, base: `C:\src\api`, want: "src"},
+		{url: "..", want: ""},
+		// URLs without a usable path.
+		{url: "ssh://git@internal-mirror", want: ""},
+		{url: "ssh://git@internal-mirror:2222/", want: ""},
+		{url: "https://example.com/", want: ""},
+		{url: "git@internal-mirror:", want: ""},
+		{url: "git@internal-mirror:~", want: ""},
+		{url: "", want: ""},
+		// A scp-like URL with a path is not mistaken for a relative path.
+		{url: "git@internal-mirror:project.git", base: "/src/api", want: "project"},
+	}
+	for _, tt := range tests {
+		if got := repoNameFromURL(tt.url, tt.base); got != tt.want {
+			t.Errorf("repoNameFromURL(%q, %q) = %q, want %q", tt.url, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestGetRepoNameDegenerateOrigin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "api")
+	setupTestRepo(t, repoDir)
+	t.Chdir(repoDir)
+	t.Cleanup(func() { warnedRepoName = false })
+
+	runGitCommand(t, repoDir, "remote", "add", "origin", "../shared/project.git")
+	if name, err := getRepoName(); err != nil || name != "project" {
+		t.Errorf("getRepoName() with a relative path origin = %q, %v; want project", name, err)
+	}
+	if warnedRepoName {
+		t.Error("getRepoName() warned about a usable origin")
+	}
+
+	runGitCommand(t, repoDir, "remote", "set-url", "origin", "ssh://git@internal-mirror")
+	if name, err := getRepoName(); err != nil || name != "api" {
+		t.Errorf("getRepoName() with a degenerate origin = %q, %v; want api", name, err)
+	}
+	if !warnedRepoName {
+		t.Error("getRepoName() did not warn about falling back to the directory name")
+	}
+}
+
+func TestRepoLevelQueriesFromLinkedWorktree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "api")
+	linked := filepath.Join(tmpDir, "elsewhere", "feature-x")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature-x", linked)
+	runGitCommand(t, repoDir, "branch", "release/1.0")
+	t.Chdir(linked)
+
+	// Without an origin the repository is named after the main clone, not
+	// after the linked worktree we are in.
+	if name, err := getRepoName(); err != nil || name != "api" {
+		t.Errorf("getRepoName() = %q, %v; want api", name, err)
+	}
+
+	commonDir, err := repoCommonDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(resolvePath(repoDir), ".git"); resolvePath(commonDir) != want {
+		t.Errorf("repoCommonDir() = %q, want %q", commonDir, want)
+	}
+
+	output, err := repoGit("for-each-ref", "--format=%(refname:short)", "refs/heads").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "release/1.0") {
+		t.Errorf("repoGit() did not see the repository's branches:\n%s", output)
+	}
+
+	runGitCommand(t, linked, "remote", "add", "origin", "https://example.com/org/api.git")
+	if name, err := getRepoName(); err != nil || name != "api" {
+		t.Errorf("getRepoName() with origin = %q, %v; want api", name, err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+)
+
+// revealTarget is what a revealCommand template can refer to: {{.Path}} is
+// the file or directory to show, {{.Dir}} the directory holding it (the
+// directory itself when Path is one).
+type revealTarget struct {
+	Path string
+	Dir  string
+}
+
+// revealArgs returns the command line showing target in the file manager of
+// goos, or the configured revealCommand when set. Each argument is expanded
+// on its own and no shell is involved, so paths are never interpreted.
+func revealArgs(goos string, target revealTarget, custom []string) ([]string, error) {
+	if len(custom) > 0 {
+		args := make([]string, len(custom))
+		for i, arg := range custom {
+			tmpl, err := template.New("reveal").Option("missingkey=error").Parse(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid revealCommand: %w", err)
+			}
+			var b strings.Builder
+			if err := tmpl.Execute(&b, target); err != nil {
+				return nil, fmt.Errorf("invalid revealCommand: %w", err)
+			}
+			args[i] = b.String()
+		}
+		return args, nil
+	}
+	switch goos {
+	case "darwin":
+		return []string{"open", "-R", target.Path}, nil
+	case "windows":
+		return []string{"explorer", "/select," + target.Path}, nil
+	default:
+		// xdg-open cannot select a file; open the directory holding it.
+		return []string{"xdg-open", target.Dir}, nil
+	}
+}
+
+// resolveRevealTarget returns what `wt open --reveal` shows: the current
+// worktree, or path (relative to the current directory) when given. The
+// target must exist.
+func resolveRevealTarget(path string) (revealTarget, error) {
+	if path == "" {
+		worktreePath, err := currentWorktreePath()
+		if err != nil {
+			return revealTarget{}, err
+		}
+		path = worktreePath
+	}
+	path, err := filepath.Abs(hostPath(path))
+	if err != nil {
+		return revealTarget{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return revealTarget{}, fmt.Errorf("cannot reveal %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return revealTarget{Path: path, Dir: path}, nil
+	}
+	return revealTarget{Path: path, Dir: filepath.Dir(path)}, nil
+}
+
+// revealInFileManager shows path, or the current worktree when empty, in the
+// system file manager. With verbose the launched command is printed.
+func revealInFileManager(path string, verbose bool) error {
+	target, err := resolveRevealTarget(path)
+	if err != nil {
+		return err
+	}
+	args, err := revealArgs(runtime.GOOS, target, getConfig().RevealCommand)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "running: %s\n", strings.Join(args, " "))
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := setWorktreeEnv(cmd, target.Dir, verbose); err != nil {
+		return err
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start the file manager: %w", err)
+	}
+	_ = cmd.Process.Release()
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRevealArgs(t *testing.T) {
+	file := revealTarget{Path: "/trees/api/my branch/main.go", Dir: "/trees/api/my branch"}
+	tests := map[string][]string{
+		"darwin":  {"open", "-R", file.Path},
+		"linux":   {"xdg-open", file.Dir},
+		"freebsd": {"xdg-open", file.Dir},
+		"windows": {"explorer", "/select," + file.Path},
+	}
+	for goos, want := range tests {
+		if got, err := revealArgs(goos, file, nil); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("revealArgs(%s) = %v, %v; want %v", goos, got, err, want)
+		}
+	}
+
+	custom := []string{"nautilus", "--select", "{{.Path}}"}
+	if got, err := revealArgs("linux", file, custom); err != nil || !reflect.DeepEqual(got, []string{"nautilus", "--select", file.Path}) {
+		t.Errorf("revealArgs() with revealCommand = %v, %v", got, err)
+	}
+	if _, err := revealArgs("linux", file, []string{"nautilus", "{{.Nope}}"}); err == nil {
+		t.Error("revealArgs() should reject an unknown template field")
+	}
+}
+
+func TestResolveRevealTarget(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	if got, err := resolveRevealTarget("main.go"); err != nil || got != (revealTarget{Path: file, Dir: dir}) {
+		t.Errorf("resolveRevealTarget(file) = %+v, %v", got, err)
+	}
+	if got, err := resolveRevealTarget(dir); err != nil || got != (revealTarget{Path: dir, Dir: dir}) {
+		t.Errorf("resolveRevealTarget(dir) = %+v, %v", got, err)
+	}
+	if _, err := resolveRevealTarget("missing.go"); err == nil {
+		t.Error("resolveRevealTarget() should fail for a missing path")
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+// reviewHead is the source branch of a PR/MR as reported by the forge.
+type reviewHead struct {
+	Branch string
+	// CrossRepo is set for reviews from forks, whose branch names say nothing
+	// about local branches.
+	CrossRepo bool
+}
+
+// parseGitHubReviewHead parses `gh pr view --json headRefName,isCrossRepository`.
+func parseGitHubReviewHead(output []byte) (reviewHead, error) {
+	var pr struct {
+		HeadRefName       string `json:"headRefName"`
+		IsCrossRepository bool   `json:"isCrossRepository"`
+	}
+	if err := json.Unmarshal(output, &pr); err != nil {
+		return reviewHead{}, err
+	}
+	return reviewHead{Branch: pr.HeadRefName, CrossRepo: pr.IsCrossRepository}, nil
+}
+
+// parseGitLabReviewHead parses `glab mr view -F json`.
+func parseGitLabReviewHead(output []byte) (reviewHead, error) {
+	var mr struct {
+		SourceBranch    string `json:"source_branch"`
+		SourceProjectID int    `json:"source_project_id"`
+		TargetProjectID int    `json:"target_project_id"`
+	}
+	if err := json.Unmarshal(output, &mr); err != nil {
+		return reviewHead{}, err
+	}
+	return reviewHead{Branch: mr.SourceBranch, CrossRepo: mr.SourceProjectID != mr.TargetProjectID}, nil
+}
+
+// getReviewHead asks the forge for the source branch of a PR/MR.
+func getReviewHead(number string, remoteType RemoteType) (reviewHead, error) {
+	if remoteType == RemoteGitLab {
+		output, err := runForgeCLI(remoteType, "mr", "view", number, "-F", "json")
+		if err != nil {
+			return reviewHead{}, err
+		}
+		return parseGitLabReviewHead(output)
+	}
+	output, err := runForgeCLI(remoteType, "pr", "view", number, "--json", "headRefName,isCrossRepository")
+	if err != nil {
+		return reviewHead{}, err
+	}
+	return parseGitHubReviewHead(output)
+}
+
+// parseUpstreams parses `git for-each-ref --format='%(refname:short) %(upstream:short)' refs/heads`
+// into a map from upstream to the local branches tracking it.
+func parseUpstreams(output string) map[string][]string {
+	upstreams := make(map[string][]string)
+	for _, line := range strings.Split(output, "\n") {
+		branch, upstream, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || upstream == "" {
+			continue
+		}
+		upstreams[upstream] = append(upstreams[upstream], branch)
+	}
+	return upstreams
+}
+
+// findBranchWorktree returns the worktree of branch, or of a local branch that
+// tracks origin/<branch>.
+func findBranchWorktree(branch string) (string, string, bool) {
+	if path, ok := worktreeExists(branch); ok {
+		return path, branch, true
+	}
+	output, err := repoGit("for-each-ref", "--format=%(refname:short) %(upstream:short)", "refs/heads").Output()
+	if err != nil {
+		return "", "", false
+	}
+	for _, local := range parseUpstreams(string(output))["origin/"+branch] {
+		if path, ok := worktreeExists(local); ok {
+			return path, local, true
+		}
+	}
+	return "", "", false
+}
+
+// reviewConfigKey is the git config key recording which local branch holds a
+// PR/MR, e.g. wt-review.pr-512.branch.
+func reviewConfigKey(number string, remoteType RemoteType) string {
+	return fmt.Sprintf("wt-review.%s-%s.branch", reviewPrefix(remoteType), number)
+}
+
+// recordedReviewBranch returns the branch previously associated with a PR/MR.
+func recordedReviewBranch(number string, remoteType RemoteType) string {
+	output, err := repoGit("config", "--get", reviewConfigKey(number, remoteType)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func recordReviewBranch(number string, remoteType RemoteType, branch string) {
+	_ = repoGit("config", reviewConfigKey(number, remoteType), branch).Run()
+}
+
+// existingReviewWorktree finds a worktree that already holds the PR/MR's
+// commits: first via the recorded association, then by asking the forge for
+// the head branch. Reviews from forks are never matched by branch name.
+func existingReviewWorktree(number string, remoteType RemoteType) (string, string, bool) {
+	if branch := recordedReviewBranch(number, remoteType); branch != "" {
+		if path, ok := worktreeExists(branch); ok {
+			return path, branch, true
+		}
+	}
+	if requireReviewCLI(remoteType) != nil {
+		return "", "", false
+	}
+	head, err := getReviewHead(number, remoteType)
+	if err != nil || head.Branch == "" || head.CrossRepo {
+		return "", "", false
+	}
+	path, branch, ok := findBranchWorktree(head.Branch)
+	if ok {
+		recordReviewBranch(number, remoteType, branch)
+	}
+	return path, branch, ok
+}
+
+// branchFetchedAt returns when branch was last updated, from its reflog.
+func branchFetchedAt(branch string) (time.Time, bool) {
+	output, err := repoGit("reflog", "-1", "--date=unix", "--format=%gd", "refs/heads/"+branch).Output()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parseReflogTime(string(output))
+}
+
+// parseReflogTime parses the date of `git reflog --date=unix --format=%gd`
+// output such as "refs/heads/pr-7@{1700000000}".
+func parseReflogTime(output string) (time.Time, bool) {
+	_, date, ok := strings.Cut(strings.TrimSpace(output), "@{")
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSuffix(date, "}"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// staleNotice tells that the local copy of branch was checked out without
+// fetching: because fetchErr occurred, or, when it is nil, because fetching
+// was turned off. fetchedAt is when the branch was last updated, if known.
+func staleNotice(branch string, fetchErr error, fetchedAt time.Time, known bool, now time.Time, color bool) string {
+	age := "at an unknown time"
+	if known {
+		age = formatAge(now.Sub(fetchedAt)) + " ago"
+	}
+	if fetchErr == nil {
+		return fmt.Sprintf("Not fetched: using the local copy of %s fetched %s", branch, age)
+	}
+	return colorize(color, ansiYellow, fmt.Sprintf("warning: fetch failed (%v)\n  using possibly stale local copy of %s fetched %s", fetchErr, branch, age))
+}
+
+func init() {
+	removeCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	removeCmd.Flags().Bool("delete-branch", false, "Also delete the branch of the removed worktree")
+	removeCmd.Flags().Bool("include-unowned", false, "With --delete-branch: also delete branches wt did not create")
+	removeCmd.Flags().Bool("no-backup", false, "Do not back up the ignored files matching preRemoveBackup")
+	removeCmd.Flags().Bool("review-cleanup", false, "Also clear the metadata of a PR/MR (default on with --delete-branch for review branches)")
+	removeCmd.Flags().Bool("dry-run", false, "Show what would be removed, deleted and backed up, and change nothing")
+	removeCmd.Flags().Bool("json", false, "With --dry-run: output the plan as JSON")
+	removeCmd.Flags().Bool("others", false, "Remove every worktree except the main one and the current one")
+	removeCmd.Flags().Bool("force", false, "Remove worktrees with modified or untracked files, or used by other processes; the changes are lost")
+	removeCmd.Flags().Bool("no-process-check", false, "Do not look for other processes using the worktree")
+	removeCmd.Flags().Bool("override-protection", false, "With --others: go ahead in a repository listed in protectedRepos, after typing its name")
+	prCmd.Flags().Bool("isolated", false, "Always use a separate pr-<n> worktree, even if the PR branch is checked out")
+	mrCmd.Flags().Bool("isolated", false, "Always use a separate mr-<n> worktree, even if the MR branch is checked out")
+	prCmd.Flags().Int("round", 0, "Check out the head of the n-th round of review, counting force-pushes (needs gh)")
+	for _, cmd := range []*cobra.Command{prCmd, mrCmd} {
+		cmd.Flags().String("at", "", "Check out this commit of the review instead of its latest head, e.g. the one reviewed last")
+		cmd.Flags().String("output", outputText, "Output format: text, or json for scripts (no auto-cd)")
+		cmd.Flags().Bool("fetch", true, "Fetch the latest version before checking it out")
+		cmd.Flags().Bool("no-fetch", false, "Use the local branch as is when it exists")
+		cmd.MarkFlagsMutuallyExclusive("fetch", "no-fetch")
+		cmd.MarkFlagsMutuallyExclusive("at", "all")
+		_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{outputText, outputJSON}, cobra.ShellCompDirectiveNoFileComp))
+	}
+	prCmd.MarkFlagsMutuallyExclusive("at", "round")
+	prCmd.MarkFlagsMutuallyExclusive("round", "all")
+}
+
+// reviewForBranch returns the review (e.g. "pr-512") whose recorded branch is
+// branch, parsing `git config --get-regexp` output.
+func reviewForBranch(configOutput, branch string) string {
+	for _, line := range strings.Split(configOutput, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || !worktree.SameBranch(value, branch) {
+			continue
+		}
+		review := strings.TrimSuffix(strings.TrimPrefix(key, "wt-review."), ".branch")
+		if review != key {
+			return review
+		}
+	}
+	return ""
+}
+
+// gitIn runs git in dir (the current directory when dir is empty).
+func gitIn(dir string, args ...string) *tracedCmd {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	return gitCommand(args...)
+}
+
+// lookupReview returns the review recorded for branch, if any.
+func lookupReview(dir, branch string) string {
+	output, err := gitIn(dir, "config", "--get-regexp", `^wt-review\..*\.branch$`).Output()
+	if err != nil {
+		return ""
+	}
+	return reviewForBranch(string(output), branch)
+}
+
+// cleanupStep is the outcome of one review cleanup action.
+type cleanupStep struct {
+	Action string // e.g. "delete branch pr-512"
+	Done   string // e.g. "Deleted branch pr-512"
+	Err    error
+}
+
+// deleteBranch deletes branch. Branches wt fetched for a review are throwaway
+// copies and are force-deleted; any other branch must be merged. Branches wt
+// did not create are left alone unless includeUnowned is set.
+func deleteBranch(dir, branch, review string, includeUnowned bool) cleanupStep {
+	flag := "-d"
+	if review != "" && worktree.SameBranch(review, branch) {
+		flag = "-D"
+	}
+	step := cleanupStep{Action: "delete branch " + branch, Done: "Deleted branch " + branch}
+	if !includeUnowned && !isBranchOwned(dir, branch, review) {
+		step.Err = fmt.Errorf("it was not created by wt (use --include-unowned or 'wt adopt %s')", branch)
+		return step
+	}
+	if output, err := gitIn(dir, "branch", flag, branch).CombinedOutput(); err != nil {
+		step.Err = fmt.Errorf("%s", firstLine(string(output)))
+	}
+	return step
+}
+
+// cleanupReview clears the review metadata and the ref of the last fetched
+// revision.
+func cleanupReview(dir, review string) []cleanupStep {
+	step := cleanupStep{Action: "clear metadata for " + review, Done: "Cleared metadata for " + review}
+	if err := gitIn(dir, "config", "--remove-section", "wt-review."+review).Run(); err != nil {
+		step.Err = err
+	}
+	_ = gitIn(dir, "update-ref", "-d", reviewLatestRef(review)).Run()
+	return []cleanupStep{step}
+}
+
+func printCleanupSummary(w io.Writer, steps []cleanupStep) {
+	for _, step := range steps {
+		if step.Err != nil {
+			fmt.Fprintf(w, "✗ Could not %s: %v\n", step.Action, step.Err)
+			continue
+		}
+		fmt.Fprintf(w, "✓ %s\n", step.Done)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseReviewHead(t *testing.T) {
+	gh, err := parseGitHubReviewHead([]byte(`{"headRefName":"feature/login","isCrossRepository":false}`))
+	if err != nil || gh.Branch != "feature/login" || gh.CrossRepo {
+		t.Errorf("parseGitHubReviewHead() = %+v, %v", gh, err)
+	}
+
+	fork, err := parseGitHubReviewHead([]byte(`{"headRefName":"main","isCrossRepository":true}`))
+	if err != nil || !fork.CrossRepo {
+		t.Errorf("parseGitHubReviewHead(fork) = %+v, %v", fork, err)
+	}
+
+	gl, err := parseGitLabReviewHead([]byte(`{"iid":7,"source_branch":"fix/typo","source_project_id":3,"target_project_id":3}`))
+	if err != nil || gl.Branch != "fix/typo" || gl.CrossRepo {
+		t.Errorf("parseGitLabReviewHead() = %+v, %v", gl, err)
+	}
+
+	if _, err := parseGitHubReviewHead([]byte("not json")); err == nil {
+		t.Error("parseGitHubReviewHead() should fail on invalid JSON")
+	}
+}
+
+func TestParseUpstreams(t *testing.T) {
+	got := parseUpstreams("main origin/main\nlogin origin/feature/login\nscratch \n")
+	if len(got["origin/feature/login"]) != 1 || got["origin/feature/login"][0] != "login" {
+		t.Errorf("parseUpstreams() = %v", got)
+	}
+	if _, ok := got[""]; ok {
+		t.Error("parseUpstreams() should skip branches without upstream")
+	}
+}
+
+func TestExistingReviewWorktree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	origin := filepath.Join(tmpDir, "origin")
+	setupTestRepo(t, origin)
+	runGitCommand(t, origin, "branch", "feature/login")
+
+	clone := filepath.Join(tmpDir, "clone")
+	runGitCommand(t, tmpDir, "clone", "-q", origin, clone)
+	t.Chdir(clone)
+
+	// A local branch with a different name tracking the PR's head branch.
+	loginPath := filepath.Join(tmpDir, "worktrees", "login")
+	runGitCommand(t, clone, "worktree", "add", "-b", "login", loginPath, "origin/feature/login")
+
+	path, branch, ok := findBranchWorktree("feature/login")
+	if !ok || branch != "login" || filepath.Base(path) != "login" {
+		t.Fatalf("findBranchWorktree() = %q, %q, %v", path, branch, ok)
+	}
+	if _, _, ok := findBranchWorktree("nope"); ok {
+		t.Error("findBranchWorktree() found a worktree for an unknown branch")
+	}
+
+	// A recorded association short-circuits without asking the forge.
+	recordReviewBranch("512", RemoteGitHub, "login")
+	if got := recordedReviewBranch("512", RemoteGitHub); got != "login" {
+		t.Fatalf("recordedReviewBranch() = %q", got)
+	}
+	path, branch, ok = existingReviewWorktree("512", RemoteGitHub)
+	if !ok || branch != "login" || filepath.Base(path) != "login" {
+		t.Errorf("existingReviewWorktree() = %q, %q, %v", path, branch, ok)
+	}
+}
+
+func TestReviewForBranch(t *testing.T) {
+	output := "wt-review.pr-512.branch login\nwt-review.mr-7.branch mr-7\n"
+	if got := reviewForBranch(output, "login"); got != "pr-512" {
+		t.Errorf("reviewForBranch(login) = %q, want pr-512", got)
+	}
+	if got := reviewForBranch(output, "mr-7"); got != "mr-7" {
+		t.Errorf("reviewForBranch(mr-7) = %q, want mr-7", got)
+	}
+	if got := reviewForBranch(output, "other"); got != "" {
+		t.Errorf("reviewForBranch(other) = %q, want empty", got)
+	}
+}
+
+func TestReviewCleanup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "branch", "pr-7")
+	runGitCommand(t, repoDir, "config", "wt-review.pr-7.branch", "pr-7")
+	// A remote a review points at belongs to the user and survives cleanup.
+	runGitCommand(t, repoDir, "remote", "add", "upstream", "https://example.com/org/repo.git")
+	runGitCommand(t, repoDir, "config", "wt-review.pr-7.remote", "upstream")
+
+	review := lookupReview(repoDir, "pr-7")
+	if review != "pr-7" {
+		t.Fatalf("lookupReview() = %q, want pr-7", review)
+	}
+	steps := append([]cleanupStep{deleteBranch(repoDir, "pr-7", review, false)}, cleanupReview(repoDir, review)...)
+	if len(steps) != 2 {
+		t.Fatalf("cleanup ran %d steps, want 2: %+v", len(steps), steps)
+	}
+	for _, step := range steps {
+		if step.Err != nil {
+			t.Errorf("%s failed: %v", step.Action, step.Err)
+		}
+	}
+	if gitIn(repoDir, "rev-parse", "--verify", "--quiet", "refs/heads/pr-7").Run() == nil {
+		t.Error("branch pr-7 still exists")
+	}
+	if lookupReview(repoDir, "pr-7") != "" {
+		t.Error("review metadata was not cleared")
+	}
+
+	if gitIn(repoDir, "remote", "get-url", "upstream").Run() != nil {
+		t.Error("cleanup removed the remote of the review")
+	}
+}
+
+func TestDeleteBranchOwnership(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+
+	// Created outside wt: kept unless explicitly included.
+	runGitCommand(t, repoDir, "branch", "manual")
+	if isBranchOwned(repoDir, "manual", "") {
+		t.Fatal("a branch created with git should not be owned")
+	}
+	if step := deleteBranch(repoDir, "manual", "", false); step.Err == nil {
+		t.Error("deleteBranch() deleted a branch wt did not create")
+	}
+	if gitIn(repoDir, "rev-parse", "--verify", "--quiet", "refs/heads/manual").Run() != nil {
+		t.Fatal("unowned branch was deleted")
+	}
+	if step := deleteBranch(repoDir, "manual", "", true); step.Err != nil {
+		t.Errorf("deleteBranch(includeUnowned) error = %v", step.Err)
+	}
+
+	// Created by wt: deleted, and the marker goes with the branch.
+	runGitCommand(t, repoDir, "branch", "created")
+	if err := markBranchOwned(repoDir, "created"); err != nil {
+		t.Fatal(err)
+	}
+	if step := deleteBranch(repoDir, "created", "", false); step.Err != nil {
+		t.Errorf("deleteBranch(owned) error = %v", step.Err)
+	}
+	runGitCommand(t, repoDir, "branch", "created")
+	if isBranchOwned(repoDir, "created", "") {
+		t.Error("a recreated branch should not inherit the ownership marker")
+	}
+
+	// Adopted, then disowned.
+	if err := markBranchOwned(repoDir, "created"); err != nil {
+		t.Fatal(err)
+	}
+	if err := unmarkBranchOwned(repoDir, "created"); err != nil {
+		t.Fatal(err)
+	}
+	if isBranchOwned(repoDir, "created", "") {
+		t.Error("unmarkBranchOwned() kept the marker")
+	}
+
+	// Review branches fetched by wt count as owned even without the marker.
+	if !isBranchOwned(repoDir, "pr-3", "pr-3") {
+		t.Error("review branches should be owned")
+	}
+}
+
+func TestParseReflogTime(t *testing.T) {
+	got, ok := parseReflogTime("refs/heads/pr-7@{1700000000}\n")
+	if !ok || !got.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("parseReflogTime() = %v, %v", got, ok)
+	}
+	for _, output := range []string{"", "refs/heads/pr-7@{0}x", "refs/heads/pr-7@{yesterday}"} {
+		if _, ok := parseReflogTime(output); ok {
+			t.Errorf("parseReflogTime(%q) should fail", output)
+		}
+	}
+}
+
+func TestStaleNotice(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	fetchedAt := now.Add(-3 * 24 * time.Hour)
+	tests := []struct {
+		name     string
+		fetchErr error
+		known    bool
+		want     string
+	}{
+		{"failed fetch", errors.New("timed out after 1m0s"), true,
+			"warning: fetch failed (timed out after 1m0s)\n  using possibly stale local copy of pr-7 fetched 3d ago"},
+		{"failed fetch without reflog", errors.New("exit status 128"), false,
+			"warning: fetch failed (exit status 128)\n  using possibly stale local copy of pr-7 fetched at an unknown time"},
+		{"fetch skipped", nil, true,
+			"Not fetched: using the local copy of pr-7 fetched 3d ago"},
+	}
+	for _, tt := range tests {
+		if got := staleNotice("pr-7", tt.fetchErr, fetchedAt, tt.known, now, false); got != tt.want {
+			t.Errorf("%s: staleNotice() =\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// For a re-review, `wt pr --at <sha>` and `wt pr --round <n>` check the PR
+// out at the commit reviewed last time instead of its latest head. Both
+// commits are recorded with the review, so that `wt status` can say how many
+// commits were pushed since.
+
+// reviewPoint is the commit a review worktree was checked out at and the
+// head of the PR/MR when it was fetched.
+type reviewPoint struct {
+	At     string
+	Latest string
+}
+
+// reviewLatestRef keeps the recorded head of review reachable, so that the
+// commits since the review point can still be counted after a force-push.
+func reviewLatestRef(review string) string {
+	return "refs/wt/reviews/" + review + "/latest"
+}
+
+// recordReviewPoint stores p in the metadata of review.
+func recordReviewPoint(review string, p reviewPoint) {
+	_ = repoGit("config", "wt-review."+review+".reviewedAt", p.At).Run()
+	_ = repoGit("config", "wt-review."+review+".latestHead", p.Latest).Run()
+	_ = repoGit("update-ref", reviewLatestRef(review), p.Latest).Run()
+}
+
+// clearReviewPoint forgets the review point of review, for a checkout at its
+// latest head.
+func clearReviewPoint(review string) {
+	_ = repoGit("config", "--unset", "wt-review."+review+".reviewedAt").Run()
+	_ = repoGit("config", "--unset", "wt-review."+review+".latestHead").Run()
+	_ = repoGit("update-ref", "-d", reviewLatestRef(review)).Run()
+}
+
+// loadReviewPoint returns the review point recorded for review, if any.
+func loadReviewPoint(review string) (reviewPoint, bool) {
+	at, err := repoGit("config", "--get", "wt-review."+review+".reviewedAt").Output()
+	if err != nil {
+		return reviewPoint{}, false
+	}
+	latest, err := repoGit("config", "--get", "wt-review."+review+".latestHead").Output()
+	if err != nil {
+		return reviewPoint{}, false
+	}
+	return reviewPoint{At: strings.TrimSpace(string(at)), Latest: strings.TrimSpace(string(latest))}, true
+}
+
+// newCommitsSince counts the commits of the recorded head that the review
+// point does not have.
+func newCommitsSince(p reviewPoint) (int, bool) {
+	output, err := repoGit("rev-list", "--count", p.At+".."+p.Latest).Output()
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	return n, err == nil
+}
+
+// forcePushEvent is a force-push of a PR branch: the head before and after.
+type forcePushEvent struct {
+	Before string
+	After  string
+}
+
+// forcePushQuery asks GitHub for the force-pushes of a PR, oldest first.
+const forcePushQuery = `query($owner: String!, $repo: String!, $number: Int!) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      timelineItems(itemTypes: [HEAD_REF_FORCE_PUSHED_EVENT], first: 100) {
+        nodes { ... on HeadRefForcePushedEvent { beforeCommit { oid } afterCommit { oid } } }
+      }
+    }
+  }
+}`
+
+// parseForcePushEvents parses the answer to forcePushQuery.
+func parseForcePushEvents(output []byte) ([]forcePushEvent, error) {
+	var answer struct {
+		Data struct {
+			Repository struct {
+				PullRequest *struct {
+					TimelineItems struct {
+						Nodes []struct {
+							BeforeCommit *struct{ Oid string } `json:"beforeCommit"`
+							AfterCommit  *struct{ Oid string } `json:"afterCommit"`
+						} `json:"nodes"`
+					} `json:"timelineItems"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(output, &answer); err != nil {
+		return nil, fmt.Errorf("unexpected gh output: %w", err)
+	}
+	pr := answer.Data.Repository.PullRequest
+	if pr == nil {
+		return nil, fmt.Errorf("pull request not found")
+	}
+	var events []forcePushEvent
+	for _, node := range pr.TimelineItems.Nodes {
+		// Commits GitHub no longer has are null.
+		var e forcePushEvent
+		if node.BeforeCommit != nil {
+			e.Before = node.BeforeCommit.Oid
+		}
+		if node.AfterCommit != nil {
+			e.After = node.AfterCommit.Oid
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// roundHead returns the head of review round n: round 1 is what was pushed
+// before the first force-push, round n+1 what the n-th force-push left, and
+// the round after the last force-push is the latest head.
+func roundHead(events []forcePushEvent, latest string, n int) (string, error) {
+	rounds := len(events) + 1
+	if n < 1 || n > rounds {
+		return "", fmt.Errorf("--round %d: the PR has %d round(s), one more than its force-pushes", n, rounds)
+	}
+	var head string
+	switch {
+	case n == rounds:
+		head = latest
+	case n == 1:
+		head = events[0].Before
+	default:
+		head = events[n-2].After
+	}
+	if head == "" {
+		return "", fmt.Errorf("--round %d: GitHub no longer has the head of that round", n)
+	}
+	return head, nil
+}
+
+// resolveReviewPoint fetches the head of PR/MR number and returns it with
+// the commit of --at or --round. A commit given with --at must be part of
+// the fetched head; rounds before a force-push are not, so their head is
+// fetched by the commit ID GitHub reports.
+func resolveReviewPoint(number string, remoteType RemoteType, at string, round int) (reviewPoint, error) {
+	head, err := fetchReviewHead(number, remoteType)
+	if err != nil {
+		return reviewPoint{}, err
+	}
+	// FETCH_HEAD is kept per worktree, so it is resolved here rather than in
+	// the common git dir.
+	output, err := gitIn("", "rev-parse", "--verify", head+"^{commit}").Output()
+	if err != nil {
+		return reviewPoint{}, fmt.Errorf("cannot resolve the head of %s", reviewBranch(number, remoteType))
+	}
+	latest := strings.TrimSpace(string(output))
+	if recorded, ok := loadReviewPoint(reviewBranch(number, remoteType)); ok && offline {
+		// Offline the local branch may be at an earlier review point.
+		latest = recorded.Latest
+	}
+	kind := strings.ToUpper(reviewPrefix(remoteType))
+
+	if round > 0 {
+		if remoteType != RemoteGitHub {
+			return reviewPoint{}, fmt.Errorf("--round is only supported for GitHub PRs; use --at <sha>")
+		}
+		if err := requireReviewCLI(remoteType); err != nil {
+			return reviewPoint{}, fmt.Errorf("--round asks GitHub for the force-pushes of the PR: %w", err)
+		}
+		output, err := runForgeCLI(remoteType, "api", "graphql", "-F", "owner={owner}", "-F", "repo={repo}",
+			"-F", "number="+number, "-f", "query="+forcePushQuery)
+		if err != nil {
+			return reviewPoint{}, describeForgeError("the force-pushes of PR #"+number, remoteType, err)
+		}
+		events, err := parseForcePushEvents(output)
+		if err != nil {
+			return reviewPoint{}, err
+		}
+		commit, err := roundHead(events, latest, round)
+		if err != nil {
+			return reviewPoint{}, err
+		}
+		if !commitExists(commit) {
+			if offline {
+				return reviewPoint{}, fmt.Errorf("round %d (%s) has not been fetched yet and wt is offline", round, shortSHA(commit))
+			}
+			if err := fetchIntoFetchHead(commit); err != nil {
+				return reviewPoint{}, err
+			}
+		}
+		return reviewPoint{At: commit, Latest: latest}, nil
+	}
+
+	// at is a revision the user typed, resolved where they typed it.
+	output, err = gitIn("", "rev-parse", "--verify", "--quiet", at+"^{commit}").Output()
+	if err != nil {
+		return reviewPoint{}, fmt.Errorf("commit %s not found; it is not part of %s #%s", at, kind, number)
+	}
+	commit := strings.TrimSpace(string(output))
+	if repoGit("merge-base", "--is-ancestor", commit, latest).Run() != nil {
+		err := fmt.Errorf("%s is not part of %s #%s (head %s)", shortSHA(commit), kind, number, shortSHA(latest))
+		if remoteType == RemoteGitHub {
+			err = fmt.Errorf("%w; for a commit from before a force-push, use --round", err)
+		}
+		return reviewPoint{}, err
+	}
+	return reviewPoint{At: commit, Latest: latest}, nil
+}
+
+// pinReviewWorktree moves the existing worktree at path to the review point.
+// The branch is wt's throwaway copy of the review, but changes in the
+// worktree are not thrown away.
+func pinReviewWorktree(path string, p reviewPoint) error {
+	modified, untracked, err := countChanges(path)
+	if err != nil {
+		return err
+	}
+	if modified+untracked > 0 {
+		return fmt.Errorf("%s has %d modified and %d untracked file(s); commit, stash or clean them before moving it to %s",
+			displayPath(path), modified, untracked, shortSHA(p.At))
+	}
+	if output, err := gitIn(path, "reset", "--hard", "-q", p.At).CombinedOutput(); err != nil {
+		return fmt.Errorf("git reset --hard %s: %s", shortSHA(p.At), firstLine(string(output)))
+	}
+	return nil
+}
+
+// pluralCommits says "1 new commit" or "n new commits".
+func pluralCommits(n int) string {
+	if n == 1 {
+		return "1 new commit"
+	}
+	return fmt.Sprintf("%d new commits", n)
+}
+
+// describeReviewPoint says where a review worktree was checked out.
+func describeReviewPoint(p reviewPoint) string {
+	if n, ok := newCommitsSince(p); ok && n > 0 {
+		return fmt.Sprintf("at %s (%s since)", shortSHA(p.At), pluralCommits(n))
+	}
+	return "at " + shortSHA(p.At)
+}
+
+// reviewPointFlags reads --at and --round of cmd; mrCmd has no --round.
+func reviewPointFlags(cmd *cobra.Command) (string, int) {
+	at, _ := cmd.Flags().GetString("at")
+	round, _ := cmd.Flags().GetInt("round")
+	return at, round
+}
+
+// shortSHA abbreviates a commit ID for messages.
+func shortSHA(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+func TestParseForcePushEvents(t *testing.T) {
+	output := []byte(`{"data":{"repository":{"pullRequest":{"timelineItems":{"nodes":[
+		{"beforeCommit":{"oid":"aaa"},"afterCommit":{"oid":"bbb"}},
+		{"beforeCommit":{"oid":"bbb"},"afterCommit":null}]}}}}}`)
+	events, err := parseForcePushEvents(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0] != (forcePushEvent{"aaa", "bbb"}) || events[1] != (forcePushEvent{"bbb", ""}) {
+		t.Errorf("parseForcePushEvents() = %+v", events)
+	}
+	if _, err := parseForcePushEvents([]byte(`{"data":{"repository":{"pullRequest":null}}}`)); err == nil {
+		t.Error("parseForcePushEvents(no PR) should fail")
+	}
+}
+
+func TestRoundHead(t *testing.T) {
+	events := []forcePushEvent{{"r1", "r2"}, {"r2b", ""}}
+	tests := []struct {
+		n       int
+		want    string
+		wantErr string
+	}{
+		{1, "r1", ""},
+		{2, "r2", ""},
+		{3, "latest", ""},
+		{0, "", "has 3 round(s)"},
+		{4, "", "has 3 round(s)"},
+	}
+	for _, tt := range tests {
+		got, err := roundHead(events, "latest", tt.n)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("roundHead(%d) = %q, %v; want an error containing %q", tt.n, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("roundHead(%d) = %q, %v; want %q", tt.n, got, err, tt.want)
+		}
+	}
+	if _, err := roundHead([]forcePushEvent{{"", "x"}}, "latest", 1); err == nil {
+		t.Error("roundHead() of a round GitHub no longer has should fail")
+	}
+	if got, err := roundHead(nil, "latest", 1); err != nil || got != "latest" {
+		t.Errorf("roundHead() without force-pushes = %q, %v; want the latest head", got, err)
+	}
+}
+
+func TestReviewAt(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	origin := filepath.Join(tmpDir, "origin")
+	repoDir := filepath.Join(tmpDir, "repo")
+	if err := testrepo.Init(origin); err != nil {
+		t.Fatal(err)
+	}
+	if err := testrepo.AddReviewRef(origin, "refs/pull/7/head", "pr.txt"); err != nil {
+		t.Fatal(err)
+	}
+	reviewed := strings.TrimSpace(gitOutput(t, origin, "rev-parse", "refs/pull/7/head"))
+	// Two more pushes after the review.
+	for _, msg := range []string{"address comments", "fix tests"} {
+		head := strings.TrimSpace(gitOutput(t, origin, "rev-parse", "refs/pull/7/head"))
+		next := strings.TrimSpace(gitOutput(t, origin, "commit-tree", head+"^{tree}", "-p", head, "-m", msg))
+		runGitCommand(t, origin, "update-ref", "refs/pull/7/head", next)
+	}
+	latest := strings.TrimSpace(gitOutput(t, origin, "rev-parse", "refs/pull/7/head"))
+	if err := testrepo.Clone(origin, repoDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repoDir)
+	originalRoot := worktreeRoot
+	t.Cleanup(func() { worktreeRoot = originalRoot })
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+
+	if _, err := resolveReviewPoint("7", RemoteGitHub, strings.TrimSpace(gitOutput(t, repoDir, "rev-parse", "HEAD")), 0); err != nil {
+		t.Errorf("resolveReviewPoint(--at the base of the PR) = %v, want it accepted as part of the PR", err)
+	}
+	unrelated := strings.TrimSpace(gitOutput(t, repoDir, "commit-tree", "HEAD^{tree}", "-m", "elsewhere"))
+	if _, err := resolveReviewPoint("7", RemoteGitHub, unrelated, 0); err == nil || !strings.Contains(err.Error(), "not part of PR #7") {
+		t.Errorf("resolveReviewPoint(--at a commit outside the PR) = %v, want it refused", err)
+	}
+	if _, err := resolveReviewPoint("7", RemoteGitHub, "deadbeef", 0); err == nil {
+		t.Error("resolveReviewPoint(--at an unknown commit) should fail")
+	}
+
+	point, err := resolveReviewPoint("7", RemoteGitHub, reviewed[:8], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if point.At != reviewed || point.Latest != latest {
+		t.Fatalf("resolveReviewPoint() = %+v, want at %s with head %s", point, reviewed, latest)
+	}
+	path, existed, err := addReviewWorktree("repo", "7", RemoteGitHub, false, &point)
+	if err != nil || existed {
+		t.Fatalf("addReviewWorktree() = %q, %v, %v", path, existed, err)
+	}
+	if head := strings.TrimSpace(gitOutput(t, path, "rev-parse", "HEAD")); head != reviewed {
+		t.Errorf("pr-7 worktree is at %s, want the review point %s", head, reviewed)
+	}
+	recorded, ok := loadReviewPoint("pr-7")
+	if !ok || recorded != point {
+		t.Errorf("loadReviewPoint() = %+v, %v; want %+v", recorded, ok, point)
+	}
+	if n, ok := newCommitsSince(recorded); !ok || n != 2 {
+		t.Errorf("newCommitsSince() = %d, %v; want 2", n, ok)
+	}
+	worktrees := []statusWorktree{{Branch: "pr-7"}}
+	origOffline := offline
+	t.Cleanup(func() { offline = origOffline })
+	offline = true
+	loadStatusReviews(worktrees)
+	if r := worktrees[0].Review; r == nil || r.ReviewedAt != reviewed || r.NewCommits == nil || *r.NewCommits != 2 {
+		t.Errorf("status review = %+v, want the review point and 2 new commits", r)
+	}
+	offline = origOffline
+
+	// Moving the existing worktree to the latest head needs a clean tree.
+	point.At = latest
+	writeTree(t, path, map[string]int{"scratch.txt": 1})
+	if err := pinReviewWorktree(path, point); err == nil {
+		t.Error("pinReviewWorktree() with an untracked file should fail")
+	}
+	runGitCommand(t, path, "clean", "-fq")
+	if err := pinReviewWorktree(path, point); err != nil {
+		t.Fatal(err)
+	}
+	if head := strings.TrimSpace(gitOutput(t, path, "rev-parse", "HEAD")); head != latest {
+		t.Errorf("pr-7 worktree is at %s after pinning, want %s", head, latest)
+	}
+
+	// Review cleanup drops the point along with the rest of the metadata.
+	cleanupReview("", "pr-7")
+	if _, ok := loadReviewPoint("pr-7"); ok {
+		t.Error("review point survived the review cleanup")
+	}
+	if commitExists(reviewLatestRef("pr-7")) {
+		t.Errorf("%s survived the review cleanup", reviewLatestRef("pr-7"))
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// reviewDiffBase returns what reviews are compared with: the default branch
+// of origin when it has been fetched, the local one otherwise.
+func reviewDiffBase() (string, error) {
+	base := getDefaultBase()
+	if commitExists("origin/" + base) {
+		return "origin/" + base, nil
+	}
+	if commitExists(base) {
+		return base, nil
+	}
+	return "", fmt.Errorf("base branch '%s' not found", base)
+}
+
+// fetchReviewHead fetches the head of PR/MR number into FETCH_HEAD only: no
+// branch, remote-tracking branch or worktree is created. It returns the
+// revision to compare. With --offline nothing is fetched and the local
+// pr-<n>/mr-<n> branch is used if there is one.
+func fetchReviewHead(number string, remoteType RemoteType) (string, error) {
+	if offline {
+		branch := reviewBranch(number, remoteType)
+		if !commitExists("refs/heads/" + branch) {
+			return "", fmt.Errorf("%s has not been fetched yet and wt is offline", branch)
+		}
+		return branch, nil
+	}
+	if err := fetchIntoFetchHead(reviewRefSpec(number, remoteType)); err != nil {
+		return "", err
+	}
+	return "FETCH_HEAD", nil
+}
+
+// fetchIntoFetchHead fetches ref, a ref or commit of origin, into FETCH_HEAD,
+// giving up after --timeout.
+func fetchIntoFetchHead(ref string) error {
+	ctx := context.Background()
+	if networkTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, networkTimeout)
+		defer cancel()
+	}
+	fetch := gitCommandContext(ctx, "fetch", "--no-tags", "origin", ref)
+	var stderr strings.Builder
+	fetch.Stderr = &stderr
+	if !quietGit {
+		fetch.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	}
+	err := fetch.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("failed to fetch %s: timed out after %s", ref, networkTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %s", ref, firstLine(stderr.String()))
+	}
+	return nil
+}
+
+// reviewDiffArgs returns the git command line showing the changes of head
+// since it left base, as the PR/MR page does.
+func reviewDiffArgs(base, head string, stat, nameOnly bool) []string {
+	args := []string{"diff"}
+	if stat {
+		args = append(args, "--stat")
+	}
+	if nameOnly {
+		args = append(args, "--name-only")
+	}
+	return append(args, base+"..."+head, "--")
+}
+
+// showReviewDiff fetches PR/MR number and writes its diff to w. git colors
+// it when w is the terminal, and pages it then unless pager is false.
+func showReviewDiff(w io.Writer, number string, remoteType RemoteType, stat, nameOnly, pager bool) error {
+	head, err := fetchReviewHead(number, remoteType)
+	if err != nil {
+		return err
+	}
+	base, err := reviewDiffBase()
+	if err != nil {
+		return err
+	}
+	args := reviewDiffArgs(base, head, stat, nameOnly)
+	if !pager {
+		args = append([]string{"--no-pager"}, args...)
+	}
+	diff := gitIn("", args...)
+	diff.Stdin = os.Stdin
+	diff.Stdout = w
+	diff.Stderr = os.Stderr
+	return diff.Run()
+}
+
+// selectReview asks which of the open reviews to check out. With --preview
+// the diff stat of the highlighted one is shown and checking it out must be
+// confirmed; declining returns to the list.
+func selectReview(cmd *cobra.Command, remoteType RemoteType, label string, numbers, labels []string) (string, error) {
+	preview, _ := cmd.Flags().GetBool("preview")
+	cursor := 0
+	for {
+		idx, err := promptFor(cmd).Select(label, labels, SelectOptions{Cursor: cursor})
+		if err != nil {
+			return "", err
+		}
+		if !preview {
+			return numbers[idx], nil
+		}
+		if err := showReviewDiff(cmd.ErrOrStderr(), numbers[idx], remoteType, true, false, false); err != nil {
+			return "", err
+		}
+		ok, err := promptFor(cmd).Confirm("Check out " + labels[idx])
+		if err != nil || ok {
+			return numbers[idx], err
+		}
+		cursor = idx
+	}
+}
+
+// newReviewDiffCmd builds the `diff` subcommand of `wt pr` and `wt mr`.
+func newReviewDiffCmd(remoteType RemoteType) *cobra.Command {
+	kind, article := strings.ToUpper(reviewPrefix(remoteType)), "a"
+	if remoteType == RemoteGitLab {
+		article = "an"
+	}
+	cmd := &cobra.Command{
+		Use:   "diff <number|url>",
+		Short: fmt.Sprintf("Show the changes of %s %s without checking it out", article, kind),
+		Long: fmt.Sprintf(`Show the changes of %[3]s %[1]s, to glance at it before deciding whether it
+deserves a worktree. The %[1]s is fetched into FETCH_HEAD only: no branch and
+no worktree are created. It is compared with the default branch from where
+they diverged (git diff <base>...FETCH_HEAD), through git's pager. With
+--offline the local %[2]s-<n> branch is shown, if there is one.
+
+Examples:
+  wt %[2]s diff 123              # The full diff
+  wt %[2]s diff 123 --stat       # Changed files with a summary
+  wt %[2]s diff 123 --name-only  # Only the names of the changed files`, kind, reviewPrefix(remoteType), article),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireRemote(); err != nil {
+				return err
+			}
+			number, err := getPRNumber(args[0])
+			if err != nil {
+				return err
+			}
+			stat, _ := cmd.Flags().GetBool("stat")
+			nameOnly, _ := cmd.Flags().GetBool("name-only")
+			return showReviewDiff(os.Stdout, number, remoteType, stat, nameOnly, true)
+		},
+	}
+	cmd.Flags().Bool("stat", false, "Show a diffstat instead of the patch")
+	cmd.Flags().Bool("name-only", false, "Show only the names of the changed files")
+	return cmd
+}
+
+func init() {
+	prCmd.AddCommand(newReviewDiffCmd(RemoteGitHub))
+	mrCmd.AddCommand(newReviewDiffCmd(RemoteGitLab))
+	prCmd.Flags().Bool("preview", false, "In the interactive selection, show the diff stat of a PR before checking it out")
+	mrCmd.Flags().Bool("preview", false, "In the interactive selection, show the diff stat of an MR before checking it out")
+}
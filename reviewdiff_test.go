@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+func TestReviewDiffArgs(t *testing.T) {
+	tests := []struct {
+		stat, nameOnly bool
+		want           []string
+	}{
+		{false, false, []string{"diff", "origin/main...FETCH_HEAD", "--"}},
+		{true, false, []string{"diff", "--stat", "origin/main...FETCH_HEAD", "--"}},
+		{false, true, []string{"diff", "--name-only", "origin/main...FETCH_HEAD", "--"}},
+	}
+	for _, tt := range tests {
+		if got := reviewDiffArgs("origin/main", "FETCH_HEAD", tt.stat, tt.nameOnly); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("reviewDiffArgs(stat=%v, nameOnly=%v) = %q, want %q", tt.stat, tt.nameOnly, got, tt.want)
+		}
+	}
+}
+
+func TestReviewDiff(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	origin := filepath.Join(tmpDir, "origin")
+	repoDir := filepath.Join(tmpDir, "repo")
+	if err := testrepo.Init(origin); err != nil {
+		t.Fatal(err)
+	}
+	if err := testrepo.AddReviewRef(origin, "refs/pull/7/head", "pr.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testrepo.Clone(origin, repoDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repoDir)
+	refs := gitOutput(t, repoDir, "for-each-ref")
+
+	var out bytes.Buffer
+	if err := showReviewDiff(&out, "7", RemoteGitHub, false, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out.String()) != "pr.txt" {
+		t.Errorf("diff --name-only of PR 7 = %q, want pr.txt", out.String())
+	}
+	if after := gitOutput(t, repoDir, "for-each-ref"); after != refs {
+		t.Errorf("refs after wt pr diff:\n%s\nwant them unchanged:\n%s", after, refs)
+	}
+	if err := showReviewDiff(&out, "8", RemoteGitHub, false, false, false); err == nil {
+		t.Error("diff of a PR that does not exist should fail")
+	}
+
+	cmd := &cobra.Command{Use: "pr"}
+	cmd.Flags().Bool("preview", true, "")
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	labels := []string{"#7: Add pr.txt", "#9: Other"}
+	p := script(t, cmd, "#7: Add pr.txt", false, "#7: Add pr.txt", true)
+	number, err := selectReview(cmd, RemoteGitHub, "Select Pull Request", []string{"7", "9"}, labels)
+	if err != nil || number != "7" {
+		t.Fatalf("selectReview() = %q, %v; want 7", number, err)
+	}
+	if !strings.Contains(stderr.String(), "pr.txt") || !strings.Contains(stderr.String(), "1 file changed") {
+		t.Errorf("preview = %q, want the diff stat of PR 7", stderr.String())
+	}
+	if want := []string{"Select Pull Request", "Check out #7: Add pr.txt", "Select Pull Request", "Check out #7: Add pr.txt"}; !reflect.DeepEqual(p.asked, want) {
+		t.Errorf("prompts = %q, want %q", p.asked, want)
+	}
+}
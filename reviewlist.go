@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+// reviewSummary is an open PR/MR as reported by `wt pr list` / `wt mr list`.
+type reviewSummary struct {
+	Number    string `json:"number"`
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	Branch    string `json:"branch"`
+	CrossRepo bool   `json:"crossRepo"`
+	Worktree  string `json:"worktree,omitempty"`
+}
+
+// reviewListArgs returns the gh/glab arguments listing open reviews as JSON.
+func reviewListArgs(remoteType RemoteType, label string, mine bool, limit int) []string {
+	var args []string
+	if remoteType == RemoteGitLab {
+		args = []string{"mr", "list", "-F", "json", "--per-page", strconv.Itoa(limit)}
+	} else {
+		args = []string{"pr", "list", "--json", "number,title,author,headRefName,isCrossRepository", "--limit", strconv.Itoa(limit)}
+	}
+	if label != "" {
+		args = append(args, "--label", label)
+	}
+	if mine {
+		args = append(args, "--author", "@me")
+	}
+	return args
+}
+
+// parseGitHubReviewList parses `gh pr list --json number,title,author,headRefName,isCrossRepository`.
+func parseGitHubReviewList(output []byte) ([]reviewSummary, error) {
+	var prs []struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Author struct {
+			Login string `json:"login"`
+		} `json:"author"`
+		HeadRefName       string `json:"headRefName"`
+		IsCrossRepository bool   `json:"isCrossRepository"`
+	}
+	if err := json.Unmarshal(output, &prs); err != nil {
+		return nil, err
+	}
+	reviews := make([]reviewSummary, len(prs))
+	for i, pr := range prs {
+		reviews[i] = reviewSummary{
+			Number:    strconv.Itoa(pr.Number),
+			Title:     pr.Title,
+			Author:    pr.Author.Login,
+			Branch:    pr.HeadRefName,
+			CrossRepo: pr.IsCrossRepository,
+		}
+	}
+	return reviews, nil
+}
+
+// parseGitLabReviewList parses `glab mr list -F json`.
+func parseGitLabReviewList(output []byte) ([]reviewSummary, error) {
+	var mrs []struct {
+		IID    int    `json:"iid"`
+		Title  string `json:"title"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+		SourceBranch    string `json:"source_branch"`
+		SourceProjectID int    `json:"source_project_id"`
+		TargetProjectID int    `json:"target_project_id"`
+	}
+	if err := json.Unmarshal(output, &mrs); err != nil {
+		return nil, err
+	}
+	reviews := make([]reviewSummary, len(mrs))
+	for i, mr := range mrs {
+		reviews[i] = reviewSummary{
+			Number:    strconv.Itoa(mr.IID),
+			Title:     mr.Title,
+			Author:    mr.Author.Username,
+			Branch:    mr.SourceBranch,
+			CrossRepo: mr.SourceProjectID != mr.TargetProjectID,
+		}
+	}
+	return reviews, nil
+}
+
+// parseReviewBranches parses `git config --get-regexp '^wt-review\..*\.branch$'`
+// into a map from review (e.g. "pr-512") to its recorded branch.
+func parseReviewBranches(configOutput string) map[string]string {
+	branches := make(map[string]string)
+	for _, line := range strings.Split(configOutput, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		review := strings.TrimSuffix(strings.TrimPrefix(key, "wt-review."), ".branch")
+		if review != key {
+			branches[review] = value
+		}
+	}
+	return branches
+}
+
+// attachWorktrees fills in the local worktree of every review, looking in the
+// same places as checkout: the recorded branch, the pr-<n>/mr-<n> branch, and
+// the head branch for reviews from the same repository.
+func attachWorktrees(reviews []reviewSummary, remoteType RemoteType, worktrees []Worktree, recorded map[string]string) {
+	find := func(branch string) string {
+		if branch == "" {
+			return ""
+		}
+		for _, wt := range worktrees {
+			if wt.Branch != "" && worktree.SameBranch(wt.Branch, branch) {
+				return wt.Path
+			}
+		}
+		return ""
+	}
+	for i := range reviews {
+		r := &reviews[i]
+		candidates := []string{recorded[reviewPrefix(remoteType)+"-"+r.Number], reviewBranch(r.Number, remoteType)}
+		if !r.CrossRepo {
+			candidates = append(candidates, r.Branch)
+		}
+		for _, branch := range candidates {
+			if path := find(branch); path != "" {
+				r.Worktree = path
+				break
+			}
+		}
+	}
+}
+
+// listReviews returns the open reviews matching the filters, with their
+// local worktrees.
+func listReviews(remoteType RemoteType, label string, mine bool, limit int) ([]reviewSummary, error) {
+	output, err := runForgeCLI(remoteType, reviewListArgs(remoteType, label, mine, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	var reviews []reviewSummary
+	if remoteType == RemoteGitLab {
+		reviews, err = parseGitLabReviewList(output)
+	} else {
+		reviews, err = parseGitHubReviewList(output)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s output: %w", forgeCLI(remoteType), err)
+	}
+
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		return nil, err
+	}
+	configOutput, _ := repoGit("config", "--get-regexp", `^wt-review\..*\.branch$`).Output()
+	attachWorktrees(reviews, remoteType, worktrees, parseReviewBranches(string(configOutput)))
+	return reviews, nil
+}
+
+func printReviewList(w io.Writer, reviews []reviewSummary, remoteType RemoteType) {
+	sigil := "#"
+	if remoteType == RemoteGitLab {
+		sigil = "!"
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NUMBER\tTITLE\tAUTHOR\tBRANCH\tWORKTREE")
+	for _, r := range reviews {
+		path := r.Worktree
+		if path == "" {
+			path = "-"
+		}
+		fmt.Fprintf(tw, "%s%s\t%s\t%s\t%s\t%s\n", sigil, r.Number, r.Title, r.Author, r.Branch, path)
+	}
+	_ = tw.Flush()
+}
+
+// newReviewListCmd builds the `list` subcommand of `wt pr` and `wt mr`.
+func newReviewListCmd(remoteType RemoteType) *cobra.Command {
+	kind := strings.ToUpper(reviewPrefix(remoteType))
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: fmt.Sprintf("List open %ss and their local worktrees", kind),
+		Long: fmt.Sprintf(`List open %[1]ss with their number, title, author, head branch and the
+local worktree holding them, if any. Nothing is checked out.
+
+Examples:
+  wt %[2]s list                    # Open %[1]ss
+  wt %[2]s list --mine --limit 10  # Your own %[1]ss
+  wt %[2]s list --json             # Machine-readable output`, kind, reviewPrefix(remoteType)),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireRemote(); err != nil {
+				return err
+			}
+			if err := requireReviewCLI(remoteType); err != nil {
+				return err
+			}
+			label, _ := cmd.Flags().GetString("label")
+			mine, _ := cmd.Flags().GetBool("mine")
+			limit, _ := cmd.Flags().GetInt("limit")
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			reviews, err := listReviews(remoteType, label, mine, limit)
+			if err != nil {
+				return describeForgeError(kind+"s", remoteType, err)
+			}
+			if asJSON {
+				return writeJSON(os.Stdout, reviews)
+			}
+			printReviewList(os.Stdout, reviews, remoteType)
+			return nil
+		},
+	}
+	cmd.Flags().String("label", "", "Only reviews with this label")
+	cmd.Flags().Bool("mine", false, "Only reviews you authored")
+	cmd.Flags().Int("limit", 30, "Maximum number of reviews")
+	cmd.Flags().Bool("json", false, "Output as JSON")
+	return cmd
+}
+
+func init() {
+	prCmd.AddCommand(newReviewListCmd(RemoteGitHub))
+	mrCmd.AddCommand(newReviewListCmd(RemoteGitLab))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGitHubReviewList(t *testing.T) {
+	output := []byte(`[
+  {"number": 12, "title": "Add login", "author": {"login": "ann"}, "headRefName": "feature/login", "isCrossRepository": false},
+  {"number": 13, "title": "Fix typo", "author": {"login": "bob"}, "headRefName": "main", "isCrossRepository": true}
+]`)
+	got, err := parseGitHubReviewList(output)
+	if err != nil {
+		t.Fatalf("parseGitHubReviewList() error = %v", err)
+	}
+	want := []reviewSummary{
+		{Number: "12", Title: "Add login", Author: "ann", Branch: "feature/login"},
+		{Number: "13", Title: "Fix typo", Author: "bob", Branch: "main", CrossRepo: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGitHubReviewList() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseGitLabReviewList(t *testing.T) {
+	output := []byte(`[
+  {"iid": 7, "title": "Add login", "author": {"username": "ann"}, "source_branch": "feature/login", "source_project_id": 1, "target_project_id": 1},
+  {"iid": 8, "title": "From fork", "author": {"username": "bob"}, "source_branch": "patch-1", "source_project_id": 2, "target_project_id": 1}
+]`)
+	got, err := parseGitLabReviewList(output)
+	if err != nil {
+		t.Fatalf("parseGitLabReviewList() error = %v", err)
+	}
+	want := []reviewSummary{
+		{Number: "7", Title: "Add login", Author: "ann", Branch: "feature/login"},
+		{Number: "8", Title: "From fork", Author: "bob", Branch: "patch-1", CrossRepo: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGitLabReviewList() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReviewListArgs(t *testing.T) {
+	gh := strings.Join(reviewListArgs(RemoteGitHub, "needs-qa", true, 10), " ")
+	for _, want := range []string{"pr list", "--limit 10", "--label needs-qa", "--author @me", "headRefName"} {
+		if !strings.Contains(gh, want) {
+			t.Errorf("gh args %q missing %q", gh, want)
+		}
+	}
+	glab := strings.Join(reviewListArgs(RemoteGitLab, "", false, 5), " ")
+	if glab != "mr list -F json --per-page 5" {
+		t.Errorf("glab args = %q", glab)
+	}
+}
+
+func TestAttachWorktrees(t *testing.T) {
+	worktrees := []Worktree{
+		{Path: "/src/repo", Branch: "main"},
+		{Path: "/trees/repo/feature/login", Branch: "feature/login"},
+		{Path: "/trees/repo/pr-20", Branch: "pr-20"},
+		{Path: "/trees/repo/review-30", Branch: "my-review"},
+	}
+	recorded := parseReviewBranches("wt-review.pr-30.branch my-review\nwt-review.pr-30.remote fork\n")
+	reviews := []reviewSummary{
+		{Number: "10", Branch: "feature/login"},
+		{Number: "11", Branch: "main", CrossRepo: true}, // a fork's main is not ours
+		{Number: "20", Branch: "patch-1", CrossRepo: true},
+		{Number: "30", Branch: "elsewhere"},
+		{Number: "40", Branch: "unknown"},
+	}
+	attachWorktrees(reviews, RemoteGitHub, worktrees, recorded)
+
+	want := []string{"/trees/repo/feature/login", "", "/trees/repo/pr-20", "/trees/repo/review-30", ""}
+	for i, r := range reviews {
+		if r.Worktree != want[i] {
+			t.Errorf("review %s worktree = %q, want %q", r.Number, r.Worktree, want[i])
+		}
+	}
+}
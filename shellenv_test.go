@@ -40,7 +40,7 @@ func TestShellenvInteractiveModeOutputCapture(t *testing.T) {
 			"EXPECTED: Remove the special case and let all commands use the same output capture logic.")
 	}
 
-	// Verify the fix: should use script(1) to provide PTY for interactive commands
+	// Verify the fix: stdout is captured in a log file
 	if !strings.Contains(shellenv, "log_file=$(mktemp") {
 		t.Error("Shell function must use a log file to capture output")
 	}
@@ -50,9 +50,19 @@ func TestShellenvInteractiveModeOutputCapture(t *testing.T) {
 		t.Error("Shell function must extract cd_path from TREE_ME_CD marker in log file")
 	}
 
-	// Verify the fix: should use script command for PTY allocation
-	if !strings.Contains(shellenv, "script -q") {
-		t.Error("Shell function must use script command to allocate PTY for interactive prompts")
+	// stdout is teed to the log file so output stays live and in order
+	if !strings.Contains(shellenv, `command wt "$@" | tee "$log_file"`) {
+		t.Error("Shell function must tee stdout to the log file while passing it through")
+	}
+
+	// Git Bash/MSYS/Cygwin need POSIX-style marker paths
+	if !strings.Contains(shellenv, `WT_PATH_STYLE=$path_style command wt`) {
+		t.Error("Shell function must pass the path style for MSYS/Cygwin shells to wt")
+	}
+
+	// The exit code must be wt's, not tee's
+	if !strings.Contains(shellenv, "${PIPESTATUS[0]:-${pipestatus[1]}}") {
+		t.Error("Shell function must take the exit code from PIPESTATUS/pipestatus")
 	}
 }
 
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shellenvLine is what `wt shellenv --install` appends to the startup file.
+const shellenvLine = "source <(wt shellenv)"
+
+// detectShell returns the name of the user's login shell, e.g. bash.
+func detectShell() string {
+	return filepath.Base(os.Getenv("SHELL"))
+}
+
+// shellRCFile returns the startup file the integration of shell goes in.
+// Only bash and zsh are supported; PowerShell profiles and cmd.exe AutoRun
+// scripts are left to the user.
+func shellRCFile(shell, home string) (string, error) {
+	switch shell {
+	case shellBash:
+		return filepath.Join(home, ".bashrc"), nil
+	case shellZsh:
+		if dir := os.Getenv("ZDOTDIR"); dir != "" {
+			return filepath.Join(dir, ".zshrc"), nil
+		}
+		return filepath.Join(home, ".zshrc"), nil
+	}
+	return "", fmt.Errorf("cannot install the shell integration for %q; see 'wt shellenv --help'", shell)
+}
+
+// shellenvInstalled reports whether rcFile already sources wt shellenv.
+func shellenvInstalled(rcFile string) bool {
+	content, err := os.ReadFile(rcFile)
+	return err == nil && strings.Contains(string(content), "wt shellenv")
+}
+
+// installShellenv appends shellenvLine to rcFile unless it already sources
+// wt shellenv. It reports whether the file was changed.
+func installShellenv(rcFile string) (bool, error) {
+	if shellenvInstalled(rcFile) {
+		return false, nil
+	}
+	content, _ := os.ReadFile(rcFile)
+	f, err := os.OpenFile(rcFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return false, err
+	}
+	prefix := "\n"
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		prefix = "\n\n"
+	}
+	if _, err := fmt.Fprintf(f, "%s# wt shell integration\n%s\n", prefix, shellenvLine); err != nil {
+		f.Close()
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// runShellenvInstall implements `wt shellenv --install` for shell, the
+// detected shell when empty.
+func runShellenvInstall(shell string) error {
+	if shell == "" {
+		shell = detectShell()
+	}
+	rcFile, err := shellRCFile(shell, userHomeDir())
+	if err != nil {
+		return err
+	}
+	changed, err := installShellenv(rcFile)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", rcFile, err)
+	}
+	if !changed {
+		infof("%s already sources wt shellenv\n", rcFile)
+		return nil
+	}
+	infof("✓ Added '%s' to %s; open a new shell to use it\n", shellenvLine, rcFile)
+	return nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timvw/wt/internal/shellrun"
+)
+
+// `wt doctor --shell` checks the shell integration end to end. It starts the
+// user's shell without startup files, sources the current shellenv output and
+// asks the shell whether wt is the wrapper function and whether completion is
+// registered for it. It then reads the startup file for what keeps that from
+// happening in a real session, above all zsh's compinit running after the
+// source line, and zsh's cached compdump.
+
+// shellProbeTimeout bounds the probe shell.
+const shellProbeTimeout = 10 * time.Second
+
+// bashProbeScript sources the shellenv output in $WT_PROBE_SHELLENV and
+// prints what bash knows about wt, one key=value per line.
+const bashProbeScript = `source "$WT_PROBE_SHELLENV"
+echo "type=$(type -t wt)"
+echo "binary=$(type -P wt)"
+echo "complete=$(complete -p wt 2>/dev/null)"
+`
+
+// zshProbeScript sources the shellenv output twice: as in a startup file
+// without compinit, where it must define the wrapper without errors, and
+// after compinit, where it must register completion.
+const zshProbeScript = `source "$WT_PROBE_SHELLENV"
+print -r -- "type=$(whence -w wt)"
+print -r -- "binary=$(whence -p wt)"
+autoload -Uz compinit && compinit -u -D
+source "$WT_PROBE_SHELLENV"
+print -r -- "complete=${_comps[wt]}"
+print -l ${(k)functions} | grep '^_wt' | sed 's/^/function=/'
+`
+
+// Completion functions of the shellenv output.
+const (
+	bashCompletion = "complete -F _wt_complete wt"
+	zshCompletion  = "_wt_complete_zsh"
+)
+
+// shellProbe is what the probe shell reported after sourcing shellenv.
+type shellProbe struct {
+	// Type is what wt is: function, file or alias; empty if undefined.
+	Type string
+	// Binary is the wt executable on PATH.
+	Binary string
+	// Complete is the completion of wt: bash's `complete -p wt`, or the
+	// function zsh's _comps maps wt to.
+	Complete string
+	// Functions are the shell functions named _wt* (zsh only).
+	Functions []string
+	// Errors is any other output, e.g. errors from sourcing shellenv.
+	Errors []string
+}
+
+// parseShellProbe parses the output of bashProbeScript or zshProbeScript.
+func parseShellProbe(output string) shellProbe {
+	var p shellProbe
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "type":
+			// zsh's whence -w says "wt: function"
+			p.Type = strings.TrimPrefix(value, "wt: ")
+			if p.Type == "none" {
+				p.Type = ""
+			}
+		case "binary":
+			p.Binary = value
+		case "complete":
+			p.Complete = value
+		case "function":
+			p.Functions = append(p.Functions, value)
+		default:
+			if strings.TrimSpace(line) != "" {
+				p.Errors = append(p.Errors, line)
+			}
+		}
+	}
+	return p
+}
+
+// probeShellPath returns the executable of shell: $SHELL when it is that
+// shell, otherwise the one on PATH.
+func probeShellPath(shell string) (string, error) {
+	if path := os.Getenv("SHELL"); filepath.Base(path) == shell {
+		return path, nil
+	}
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		return "", fmt.Errorf("%s not found", shell)
+	}
+	return path, nil
+}
+
+// runShellProbe sources the current shellenv output in a fresh shell and
+// reports what it defined.
+func runShellProbe(shell string) (shellProbe, error) {
+	script := bashProbeScript
+	if shell == shellZsh {
+		script = zshProbeScript
+	}
+	path, err := probeShellPath(shell)
+	if err != nil {
+		return shellProbe{}, err
+	}
+	binary := "wt"
+	if invokedAsGitSubcommand(os.Args[0]) {
+		binary = "git-wt"
+	}
+	f, err := os.CreateTemp("", "wt-shellenv-*.sh")
+	if err != nil {
+		return shellProbe{}, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(shellIntegration(false, binary))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return shellProbe{}, err
+	}
+
+	output, err := shellrun.Run(path, script, shellProbeTimeout, "WT_PROBE_SHELLENV="+f.Name())
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return shellProbe{}, err
+	}
+	return parseShellProbe(string(output)), nil
+}
+
+// withProbeErrors appends what the probe shell printed besides its answers.
+func withProbeErrors(detail string, p shellProbe) string {
+	if len(p.Errors) == 0 {
+		return detail
+	}
+	return detail + "\nthe shell printed:\n  " + strings.Join(p.Errors, "\n  ")
+}
+
+// checkProbeFunction checks that sourcing shellenv made wt the wrapper
+// function, which does the auto-cd.
+func checkProbeFunction(shell string, p shellProbe) doctorResult {
+	r := doctorResult{Name: "shell function"}
+	switch {
+	case p.Type != "function":
+		kind := "undefined"
+		if p.Type != "" {
+			kind = "a " + p.Type
+		}
+		r.Status, r.Detail = doctorFail, fmt.Sprintf("after sourcing 'wt shellenv' in a fresh %s, wt is %s instead of the wrapper function", shell, kind)
+	case p.Binary == "":
+		r.Status, r.Detail = doctorWarn, "the wrapper function is defined, but no wt binary is on PATH for it to run"
+	default:
+		r.Status, r.Detail = doctorOK, fmt.Sprintf("'wt shellenv' defines the wrapper function in %s, running %s", shell, p.Binary)
+	}
+	r.Detail = withProbeErrors(r.Detail, p)
+	return r
+}
+
+// checkProbeCompletion checks that sourcing shellenv registered wt's
+// completion; in zsh after compinit, as it only can then.
+func checkProbeCompletion(shell string, p shellProbe) doctorResult {
+	r := doctorResult{Name: "shell completion"}
+	want, after := bashCompletion, ""
+	if shell == shellZsh {
+		want, after = zshCompletion, " after compinit"
+	}
+	switch p.Complete {
+	case want:
+		r.Status, r.Detail = doctorOK, fmt.Sprintf("'wt shellenv' registers %s%s", want, after)
+	case "":
+		r.Status, r.Detail = doctorFail, fmt.Sprintf("no completion is registered for wt after sourcing 'wt shellenv'%s", after)
+	default:
+		r.Status, r.Detail = doctorFail, fmt.Sprintf("the completion of wt is %q instead of %q", p.Complete, want)
+	}
+	var others []string
+	for _, name := range p.Functions {
+		if name != zshCompletion {
+			others = append(others, name)
+		}
+	}
+	if len(others) > 0 {
+		r.Detail += fmt.Sprintf("\nalso defined: %s, e.g. from a completion file in $fpath; wt's own replaces it when 'wt shellenv' is sourced after compinit", strings.Join(others, ", "))
+	}
+	r.Detail = withProbeErrors(r.Detail, p)
+	return r
+}
+
+var (
+	// autoloadCompinit is `autoload -Uz compinit`, which only declares it.
+	autoloadCompinit = regexp.MustCompile(`autoload(\s+-\S+)*\s+compinit`)
+	runsCompinit     = regexp.MustCompile(`(^|[\s;&|({])compinit(\s|[;&|)]|$)`)
+	// Frameworks that run compinit themselves.
+	loadsCompinitFramework = regexp.MustCompile(`oh-my-zsh\.sh|zprezto/init\.zsh`)
+)
+
+// rcLines finds the first lines of a startup file that source wt shellenv
+// and run compinit, counting from 1; 0 if there is none.
+func rcLines(content string) (shellenv, compinit int) {
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if shellenv == 0 && strings.Contains(line, "wt shellenv") {
+			shellenv = i + 1
+		}
+		if compinit == 0 && (runsCompinit.MatchString(autoloadCompinit.ReplaceAllString(line, "")) || loadsCompinitFramework.MatchString(line)) {
+			compinit = i + 1
+		}
+	}
+	return shellenv, compinit
+}
+
+// checkRCOrder checks that rcFile sources wt shellenv and, for zsh, that it
+// does so after compinit, which defines the compdef the integration needs.
+func checkRCOrder(shell, rcFile, content string) doctorResult {
+	r := doctorResult{Name: "startup file"}
+	shellenv, compinit := rcLines(content)
+	if shell != shellZsh {
+		if shellenv == 0 {
+			r.Status, r.Detail = doctorWarn, fmt.Sprintf("%s does not source wt shellenv; add at the end: %s", rcFile, shellenvLine)
+			return r
+		}
+		r.Status, r.Detail = doctorOK, fmt.Sprintf("%s sources wt shellenv at line %d", rcFile, shellenv)
+		return r
+	}
+	switch {
+	case shellenv == 0 && compinit == 0:
+		r.Status, r.Detail = doctorWarn, fmt.Sprintf("%s neither runs compinit nor sources wt shellenv; add at the end:\n  autoload -Uz compinit && compinit\n  %s", rcFile, shellenvLine)
+	case shellenv == 0:
+		r.Status, r.Detail = doctorWarn, fmt.Sprintf("%s does not source wt shellenv; add below line %d (compinit): %s", rcFile, compinit, shellenvLine)
+	case compinit == 0:
+		r.Status, r.Detail = doctorWarn, fmt.Sprintf("%s does not run compinit, so compdef is undefined when wt shellenv is sourced at line %d\n"+
+			"and zsh completion stays off (unless a system-wide zshrc runs it); add above line %d: autoload -Uz compinit && compinit", rcFile, shellenv, shellenv)
+	case shellenv < compinit:
+		r.Status, r.Detail = doctorWarn, fmt.Sprintf("%s sources wt shellenv at line %d, before compinit runs at line %d, so completion is not registered;\n"+
+			"move line %d below line %d", rcFile, shellenv, compinit, shellenv, compinit)
+	default:
+		r.Status, r.Detail = doctorOK, fmt.Sprintf("%s sources wt shellenv at line %d, after compinit at line %d", rcFile, shellenv, compinit)
+	}
+	return r
+}
+
+// compdumpEntry matches the completion a compdump file caches for wt.
+var compdumpEntry = regexp.MustCompile(`'wt' '([^']*)'`)
+
+// checkCompdump looks for the completion of wt in zsh's compdump files,
+// which compinit writes from the completion files in $fpath. An entry is
+// left from a completion file installed for wt before; when that file is
+// gone or outdated, tab completion fails until the cache is rebuilt.
+func checkCompdump(dumps map[string]string) doctorResult {
+	r := doctorResult{Name: "zsh compdump", Status: doctorOK, Detail: "no completion of wt cached"}
+	var files, cached []string
+	for file, content := range dumps {
+		if m := compdumpEntry.FindStringSubmatch(content); m != nil && m[1] != zshCompletion {
+			files = append(files, file)
+			cached = append(cached, m[1])
+		}
+	}
+	if len(files) == 0 {
+		return r
+	}
+	sort.Strings(files)
+	sort.Strings(cached)
+	r.Status = doctorWarn
+	r.Detail = fmt.Sprintf("%s cached as the completion of wt, from a completion file installed earlier;\n"+
+		"if it is stale, remove the cache and start a new shell: rm -f %s", strings.Join(slices.Compact(cached), ", "), strings.Join(files, " "))
+	return r
+}
+
+// readCompdumps reads zsh's compdump files, ${ZDOTDIR:-$HOME}/.zcompdump*.
+func readCompdumps(home string) map[string]string {
+	dir := os.Getenv("ZDOTDIR")
+	if dir == "" {
+		dir = home
+	}
+	dumps := map[string]string{}
+	matches, _ := filepath.Glob(filepath.Join(dir, ".zcompdump*"))
+	for _, file := range matches {
+		if strings.HasSuffix(file, ".zwc") {
+			continue
+		}
+		if content, err := os.ReadFile(file); err == nil {
+			dumps[file] = string(content)
+		}
+	}
+	return dumps
+}
+
+// checkBashLoginProfile checks that ~/.bash_profile reads ~/.bashrc: macOS
+// terminals start login shells, which only read the profile.
+func checkBashLoginProfile(profile, content string, exists bool) doctorResult {
+	r := doctorResult{Name: "login shells", Status: doctorOK}
+	switch {
+	case !exists:
+		r.Status = doctorWarn
+		r.Detail = fmt.Sprintf("login shells read %s, which does not exist; create it with:\n  [ -f ~/.bashrc ] && . ~/.bashrc", profile)
+	case strings.Contains(content, ".bashrc") || strings.Contains(content, "wt shellenv"):
+		r.Detail = profile + " reads ~/.bashrc"
+	default:
+		r.Status = doctorWarn
+		r.Detail = fmt.Sprintf("login shells read %s, which does not read ~/.bashrc; add to it:\n  [ -f ~/.bashrc ] && . ~/.bashrc", profile)
+	}
+	return r
+}
+
+// shellChecks returns the checks of `wt doctor --shell` for the user's
+// shell. The probe shell runs once, for the first check that needs it.
+func shellChecks() []doctorCheck {
+	shell := detectShell()
+	home := userHomeDir()
+	rcFile, err := shellRCFile(shell, home)
+	if err != nil {
+		return []doctorCheck{{Name: "shell", Check: func() doctorResult {
+			return doctorResult{Name: "shell", Status: doctorWarn, Detail: fmt.Sprintf("--shell checks bash and zsh; $SHELL is %q", os.Getenv("SHELL"))}
+		}}}
+	}
+
+	var once sync.Once
+	var probe shellProbe
+	var probeErr error
+	probed := func(check func(string, shellProbe) doctorResult, name string) func() doctorResult {
+		return func() doctorResult {
+			once.Do(func() { probe, probeErr = runShellProbe(shell) })
+			if probeErr != nil {
+				return doctorResult{Name: name, Status: doctorFail, Detail: "cannot run " + shell + ": " + probeErr.Error()}
+			}
+			return check(shell, probe)
+		}
+	}
+	checks := []doctorCheck{
+		{Name: "shell function", Check: probed(checkProbeFunction, "shell function")},
+		{Name: "shell completion", Check: probed(checkProbeCompletion, "shell completion")},
+		{Name: "startup file", Check: func() doctorResult {
+			content, _ := os.ReadFile(rcFile)
+			return checkRCOrder(shell, rcFile, string(content))
+		}},
+	}
+	switch {
+	case shell == shellZsh:
+		checks = append(checks, doctorCheck{Name: "zsh compdump", Check: func() doctorResult {
+			return checkCompdump(readCompdumps(home))
+		}})
+	case runtime.GOOS == "darwin":
+		checks = append(checks, doctorCheck{Name: "login shells", Check: func() doctorResult {
+			profile := filepath.Join(home, ".bash_profile")
+			content, err := os.ReadFile(profile)
+			return checkBashLoginProfile(profile, string(content), err == nil)
+		}})
+	}
+	return checks
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseShellProbe(t *testing.T) {
+	output := "compdef: unknown command\ntype=wt: function\nbinary=/usr/local/bin/wt\ncomplete=_wt_complete_zsh\nfunction=_wt\nfunction=_wt_complete_zsh\n"
+	want := shellProbe{
+		Type:      "function",
+		Binary:    "/usr/local/bin/wt",
+		Complete:  zshCompletion,
+		Functions: []string{"_wt", zshCompletion},
+		Errors:    []string{"compdef: unknown command"},
+	}
+	if got := parseShellProbe(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseShellProbe() = %+v, want %+v", got, want)
+	}
+	if got := parseShellProbe("type=wt: none\nbinary=\ncomplete=\n"); got.Type != "" {
+		t.Errorf("parseShellProbe(undefined in zsh).Type = %q, want empty", got.Type)
+	}
+}
+
+func TestCheckProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		shell  string
+		probe  shellProbe
+		check  func(string, shellProbe) doctorResult
+		status string
+		detail string
+	}{
+		{"function", shellBash, shellProbe{Type: "function", Binary: "/bin/wt"}, checkProbeFunction, doctorOK, "running /bin/wt"},
+		{"binary only", shellBash, shellProbe{Type: "file", Binary: "/bin/wt"}, checkProbeFunction, doctorFail, "wt is a file instead of the wrapper function"},
+		{"undefined", shellZsh, shellProbe{Errors: []string{"parse error"}}, checkProbeFunction, doctorFail, "wt is undefined instead of the wrapper function\nthe shell printed:\n  parse error"},
+		{"not on PATH", shellBash, shellProbe{Type: "function"}, checkProbeFunction, doctorWarn, "no wt binary is on PATH"},
+		{"bash completion", shellBash, shellProbe{Complete: bashCompletion}, checkProbeCompletion, doctorOK, "registers complete -F _wt_complete wt"},
+		{"bash other completion", shellBash, shellProbe{Complete: "complete -o default -F __start_wt wt"}, checkProbeCompletion, doctorFail, `instead of "complete -F _wt_complete wt"`},
+		{"zsh completion", shellZsh, shellProbe{Complete: zshCompletion, Functions: []string{"_wt", zshCompletion}}, checkProbeCompletion, doctorOK, "after compinit\nalso defined: _wt,"},
+		{"zsh no completion", shellZsh, shellProbe{}, checkProbeCompletion, doctorFail, "no completion is registered for wt after sourcing 'wt shellenv' after compinit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.check(tt.shell, tt.probe)
+			if r.Status != tt.status || !strings.Contains(r.Detail, tt.detail) {
+				t.Errorf("got %s %q, want %s containing %q", r.Status, r.Detail, tt.status, tt.detail)
+			}
+		})
+	}
+}
+
+func TestRCLines(t *testing.T) {
+	tests := []struct {
+		content            string
+		shellenv, compinit int
+	}{
+		{"autoload -Uz compinit && compinit\nsource <(wt shellenv)\n", 2, 1},
+		{"source <(wt shellenv)\nautoload -Uz compinit\ncompinit -C\n", 1, 3},
+		// Declaring compinit does not run it, and comments do not count.
+		{"autoload -Uz compinit\n# compinit\n  # source <(wt shellenv)\n", 0, 0},
+		{"export ZSH=~/.oh-my-zsh\nsource $ZSH/oh-my-zsh.sh\neval \"$(wt shellenv)\"\n", 3, 2},
+		{"[[ -n $x ]] && { compinit; }\n", 0, 1},
+		{"zstyle ':completion:*' menu select\nmycompinit\n", 0, 0},
+	}
+	for _, tt := range tests {
+		if shellenv, compinit := rcLines(tt.content); shellenv != tt.shellenv || compinit != tt.compinit {
+			t.Errorf("rcLines(%q) = %d, %d; want %d, %d", tt.content, shellenv, compinit, tt.shellenv, tt.compinit)
+		}
+	}
+}
+
+func TestCheckRCOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		shell   string
+		content string
+		status  string
+		detail  string
+	}{
+		{"bash", shellBash, "alias ll='ls -l'\nsource <(wt shellenv)\n", doctorOK, "sources wt shellenv at line 2"},
+		{"bash missing", shellBash, "", doctorWarn, "add at the end: source <(wt shellenv)"},
+		{"zsh in order", shellZsh, "autoload -Uz compinit && compinit\nsource <(wt shellenv)\n", doctorOK, "at line 2, after compinit at line 1"},
+		{"zsh before compinit", shellZsh, "source <(wt shellenv)\nexport EDITOR=vim\nautoload -Uz compinit && compinit\n", doctorWarn, "before compinit runs at line 3, so completion is not registered;\nmove line 1 below line 3"},
+		{"zsh without compinit", shellZsh, "source <(wt shellenv)\n", doctorWarn, "add above line 1: autoload -Uz compinit && compinit"},
+		{"zsh without shellenv", shellZsh, "autoload -Uz compinit && compinit\n", doctorWarn, "add below line 1 (compinit): source <(wt shellenv)"},
+		{"zsh empty", shellZsh, "", doctorWarn, "autoload -Uz compinit && compinit\n  source <(wt shellenv)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := checkRCOrder(tt.shell, "~/.rc", tt.content)
+			if r.Status != tt.status || !strings.Contains(r.Detail, tt.detail) {
+				t.Errorf("checkRCOrder() = %s %q, want %s containing %q", r.Status, r.Detail, tt.status, tt.detail)
+			}
+		})
+	}
+}
+
+func TestCheckCompdump(t *testing.T) {
+	if r := checkCompdump(map[string]string{"/home/u/.zcompdump": "_comps=(\n'git' '_git'\n)\n"}); r.Status != doctorOK {
+		t.Errorf("checkCompdump(no wt) = %+v, want ok", r)
+	}
+	r := checkCompdump(map[string]string{
+		"/home/u/.zcompdump":        "_comps=(\n'wt' '_wt'\n)\n",
+		"/home/u/.zcompdump-host-5": "_comps=(\n'wt' '_wt'\n)\n",
+	})
+	if r.Status != doctorWarn || !strings.HasPrefix(r.Detail, "_wt cached") || !strings.HasSuffix(r.Detail, "rm -f /home/u/.zcompdump /home/u/.zcompdump-host-5") {
+		t.Errorf("checkCompdump(stale) = %+v", r)
+	}
+}
+
+func TestCheckBashLoginProfile(t *testing.T) {
+	if r := checkBashLoginProfile("~/.bash_profile", "[ -r ~/.bashrc ] && source ~/.bashrc\n", true); r.Status != doctorOK {
+		t.Errorf("checkBashLoginProfile(reads .bashrc) = %+v", r)
+	}
+	if r := checkBashLoginProfile("~/.bash_profile", "export PATH=~/bin:$PATH\n", true); r.Status != doctorWarn || !strings.Contains(r.Detail, ". ~/.bashrc") {
+		t.Errorf("checkBashLoginProfile(without .bashrc) = %+v", r)
+	}
+}
+
+// TestRunShellProbe sources the real shellenv output in each available shell.
+func TestRunShellProbe(t *testing.T) {
+	for _, shell := range []string{shellBash, shellZsh} {
+		t.Run(shell, func(t *testing.T) {
+			if _, err := exec.LookPath(shell); err != nil {
+				t.Skipf("%s not available", shell)
+			}
+			t.Setenv("SHELL", "")
+			probe, err := runShellProbe(shell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r := checkProbeFunction(shell, probe); r.Status == doctorFail || len(probe.Errors) > 0 {
+				t.Errorf("shell function: %+v", r)
+			}
+			if r := checkProbeCompletion(shell, probe); r.Status != doctorOK {
+				t.Errorf("shell completion: %+v", r)
+			}
+		})
+	}
+}
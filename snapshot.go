@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Snapshots are worktrees at a historical point of a branch, created with
+// --at: `wt create --at 2024-05-14` checks out the default branch as of that
+// day on a detached HEAD.
+
+var dateOnlyRegex = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+
+// snapshotBefore turns the --at value at into a date for git's --before and
+// the date naming the snapshot. A day means its end, so the snapshot includes
+// that day's commits; RFC3339 is passed as is. Anything else (e.g.
+// 2.weeks.ago) is left to git's date parsing and gets no name.
+func snapshotBefore(at string) (before, label string, isDate bool) {
+	if dateOnlyRegex.MatchString(at) {
+		if _, err := time.Parse("2006-01-02", at); err == nil {
+			return at + " 23:59:59", at, true
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, at); err == nil {
+		return at, t.Format("2006-01-02"), true
+	}
+	return at, "", false
+}
+
+// resolveAt returns the commit --at stands for on base: a revision as is,
+// otherwise the last commit on base before the date. label names a snapshot
+// of it: the day, or the short commit for revisions.
+func resolveAt(at, base string) (commit, label string, err error) {
+	before, label, isDate := snapshotBefore(at)
+	if !isDate {
+		if commit, short, err := resolveCommit(at); err == nil {
+			return commit, short, nil
+		}
+	}
+	// base, like at, is what the user typed: HEAD is the current worktree's.
+	output, err := gitIn("", "rev-list", "-1", "--before="+before, base, "--").Output()
+	commit = strings.TrimSpace(string(output))
+	if err != nil || commit == "" {
+		return "", "", fmt.Errorf("no commit on %s before %s", base, at)
+	}
+	if label == "" {
+		// A relative date is named after the commit it found.
+		output, _ := repoGit("show", "-s", "--format=%cs", commit).Output()
+		label = strings.TrimSpace(string(output))
+	}
+	return commit, label, nil
+}
+
+// snapshotName is the directory name of a snapshot without a branch.
+func snapshotName(label string) string {
+	return "snapshot-" + label
+}
+
+// Snapshot metadata is kept with the worktree timestamps.
+const (
+	snapshotBaseKey = "snapshotBase"
+	snapshotAtKey   = "snapshotAt"
+)
+
+// recordSnapshot remembers for `wt info` which point of base the worktree at
+// path was created at.
+func recordSnapshot(path, base, at string) {
+	_ = gitIn(path, "config", worktreeMetaKey(path, snapshotBaseKey), base).Run()
+	_ = gitIn(path, "config", worktreeMetaKey(path, snapshotAtKey), at).Run()
+}
+
+// loadSnapshot returns what recordSnapshot remembered for path, or false.
+func loadSnapshot(path string) (base, at string, ok bool) {
+	output, err := repoGit("config", "--get", worktreeMetaKey(path, snapshotAtKey)).Output()
+	if err != nil {
+		return "", "", false
+	}
+	baseOutput, _ := repoGit("config", "--get", worktreeMetaKey(path, snapshotBaseKey)).Output()
+	return strings.TrimSpace(string(baseOutput)), strings.TrimSpace(string(output)), true
+}
+
+// createSnapshot creates a worktree at the point of base --at stands for:
+// with branch set on a new branch, otherwise detached in a directory named
+// name, or after the date.
+func createSnapshot(cmd *cobra.Command, name, branch, base, at string) error {
+	if base == "" {
+		base = getDefaultBase()
+	}
+	if !commitExists(base) {
+		if !commitExists("origin/" + base) {
+			return fmt.Errorf("base branch '%s' not found", base)
+		}
+		base = "origin/" + base
+	}
+	commit, label, err := resolveAt(at, base)
+	if err != nil {
+		return err
+	}
+	repo, err := getRepoName()
+	if err != nil {
+		return err
+	}
+	if branch != "" {
+		if err := pruneDeletedWorktree(cmd, branch); err != nil {
+			return err
+		}
+		if existingPath, exists := worktreeExists(branch); exists {
+			reportExistingWorktree(existingPath)
+			return nil
+		}
+	}
+	if err := checkRepoDirOwner(repo); err != nil {
+		return err
+	}
+
+	m := newManager(repo)
+	target := branch
+	if target == "" {
+		if name == "" {
+			name = snapshotName(label)
+		}
+		target = name
+	}
+	if err := resolvePathConflict(cmd, m.Path(target)); err != nil {
+		return err
+	}
+	var path string
+	if branch != "" {
+		path, err = m.Create(branch, commit)
+		if err == nil {
+			_ = markBranchOwned("", branch)
+		}
+	} else {
+		path, err = m.CreateDetached(name, commit)
+	}
+	if err != nil {
+		return err
+	}
+	recordSnapshot(path, base, at)
+
+	infof("✓ Worktree created at: %s (%s as of %s: %.12s)\n", displayPath(path), base, at, commit)
+	warnCrossDevice(path)
+	setupDirenv(repo, branch, path)
+	printCDMarker(path)
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotBefore(t *testing.T) {
+	tests := []struct {
+		at, before, label string
+		isDate            bool
+	}{
+		{"2024-05-14", "2024-05-14 23:59:59", "2024-05-14", true},
+		{"2024-05-14T08:30:00+02:00", "2024-05-14T08:30:00+02:00", "2024-05-14", true},
+		{"2.weeks.ago", "2.weeks.ago", "", false},
+		{"2024-13-45", "2024-13-45", "", false},
+		{"v1.2.0", "v1.2.0", "", false},
+	}
+	for _, tt := range tests {
+		before, label, isDate := snapshotBefore(tt.at)
+		if before != tt.before || label != tt.label || isDate != tt.isDate {
+			t.Errorf("snapshotBefore(%q) = %q, %q, %v; want %q, %q, %v", tt.at, before, label, isDate, tt.before, tt.label, tt.isDate)
+		}
+	}
+}
+
+func TestResolveAt(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping fixture test in short mode")
+	}
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	commits := map[string]string{}
+	for _, day := range []string{"2024-05-10", "2024-05-14", "2024-05-20"} {
+		t.Setenv("GIT_AUTHOR_DATE", day+"T10:00:00Z")
+		t.Setenv("GIT_COMMITTER_DATE", day+"T10:00:00Z")
+		runGitCommand(t, repoDir, "commit", "-q", "--allow-empty", "-m", day)
+		output, err := exec.Command("git", "-C", repoDir, "rev-parse", "HEAD").Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits[day] = strings.TrimSpace(string(output))
+	}
+	t.Chdir(repoDir)
+
+	tests := []struct {
+		at, commit, label string
+	}{
+		// A day includes its own commits.
+		{"2024-05-14", commits["2024-05-14"], "2024-05-14"},
+		{"2024-05-12T00:00:00Z", commits["2024-05-10"], "2024-05-12"},
+		// Relative dates are named after the commit found.
+		{"2000.days.ago", "", ""},
+		{"HEAD~1", commits["2024-05-14"], ""},
+	}
+	for _, tt := range tests {
+		commit, label, err := resolveAt(tt.at, "main")
+		if tt.commit == "" {
+			if err == nil {
+				t.Errorf("resolveAt(%q) = %q, want an error for a point before the first commit", tt.at, commit)
+			}
+			continue
+		}
+		if err != nil || commit != tt.commit {
+			t.Errorf("resolveAt(%q) = %q, %v; want %q", tt.at, commit, err, tt.commit)
+		}
+		if tt.label != "" && label != tt.label {
+			t.Errorf("resolveAt(%q) label = %q, want %q", tt.at, label, tt.label)
+		}
+	}
+}
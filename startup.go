@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// startupProbe is a wt invocation shells make on their own, whose latency the
+// user feels: on every TAB, or whenever an editor integration runs.
+type startupProbe struct {
+	name string
+	args []string
+}
+
+var startupProbes = []startupProbe{
+	{"complete", []string{cobra.ShellCompRequestCmd, "checkout", ""}},
+	{"env", []string{"env"}},
+}
+
+// startupResult is the latency of a probe over several runs.
+type startupResult struct {
+	Name   string        `json:"name"`
+	Args   []string      `json:"args"`
+	Runs   int           `json:"runs"`
+	Min    time.Duration `json:"minNs"`
+	Median time.Duration `json:"medianNs"`
+	Max    time.Duration `json:"maxNs"`
+}
+
+// measureStartup runs exe with the probe's arguments in dir runs times, each
+// in a new process as the shell does, and returns the wall-clock latencies.
+func measureStartup(exe, dir string, probe startupProbe, runs int) (startupResult, error) {
+	var times []time.Duration
+	for range runs {
+		cmd := exec.Command(exe, probe.args...)
+		cmd.Dir = dir
+		cmd.Stdout = io.Discard
+		cmd.Stderr = io.Discard
+		start := time.Now()
+		if err := cmd.Run(); err != nil {
+			return startupResult{}, fmt.Errorf("wt %s: %w", strings.Join(probe.args, " "), err)
+		}
+		times = append(times, time.Since(start))
+	}
+	slices.Sort(times)
+	return startupResult{
+		Name:   probe.name,
+		Args:   probe.args,
+		Runs:   runs,
+		Min:    times[0],
+		Median: times[len(times)/2],
+		Max:    times[len(times)-1],
+	}, nil
+}
+
+var startupBenchmarkCmd = &cobra.Command{
+	Use:    "__startup-benchmark",
+	Short:  "Measure the latency of the invocations the shell integration makes",
+	Hidden: true,
+	Long: `Measure how long 'wt __complete' and 'wt env' take in the current
+directory, each run in a new process as the shell runs them. The startup test
+runs this against a large repository and fails when a budget is exceeded.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runs, _ := cmd.Flags().GetInt("runs")
+		if runs < 1 {
+			return fmt.Errorf("--runs must be at least 1")
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		var results []startupResult
+		for _, probe := range startupProbes {
+			result, err := measureStartup(exe, dir, probe, runs)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			return writeJSON(os.Stdout, results)
+		}
+		for _, r := range results {
+			fmt.Printf("%-10s median %v (min %v, max %v, %d runs)\n", r.Name, r.Median.Round(time.Microsecond*100), r.Min.Round(time.Microsecond*100), r.Max.Round(time.Microsecond*100), r.Runs)
+		}
+		return nil
+	},
+}
+
+func init() {
+	startupBenchmarkCmd.Flags().Int("runs", 5, "Runs per invocation")
+	startupBenchmarkCmd.Flags().Bool("json", false, "Output as JSON")
+	rootCmd.AddCommand(startupBenchmarkCmd)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+// defaultStartupBudget is how long a TAB or `wt env` may take at most in a
+// repository with many branches and worktrees.
+const defaultStartupBudget = 150 * time.Millisecond
+
+// startupBudget returns the budget of a probe: WT_STARTUP_BUDGET_<NAME>, else
+// WT_STARTUP_BUDGET, else the default. Slow CI runners raise it; 0 only
+// reports the latency.
+func startupBudget(t *testing.T, name string) time.Duration {
+	t.Helper()
+	for _, key := range []string{"WT_STARTUP_BUDGET_" + strings.ToUpper(name), "WT_STARTUP_BUDGET"} {
+		if value := os.Getenv(key); value != "" {
+			budget, err := time.ParseDuration(value)
+			if err != nil {
+				t.Fatalf("%s=%q: %v", key, value, err)
+			}
+			return budget
+		}
+	}
+	return defaultStartupBudget
+}
+
+func TestStartupLatency(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping startup benchmark in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	if err := testrepo.Populate(repoDir, 2000, 30, filepath.Join(tmpDir, "worktrees", "repo")); err != nil {
+		t.Fatal(err)
+	}
+	wtBinary := buildWtBinary(t, tmpDir)
+
+	cmd := exec.Command(wtBinary, "__startup-benchmark", "--json")
+	cmd.Dir = repoDir
+	cmd.Env = append(os.Environ(), "WORKTREE_ROOT="+filepath.Join(tmpDir, "worktrees"))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("wt __startup-benchmark: %v", err)
+	}
+	var results []startupResult
+	if err := json.Unmarshal(output, &results); err != nil {
+		t.Fatalf("wt __startup-benchmark --json: %v\n%s", err, output)
+	}
+	if len(results) != len(startupProbes) {
+		t.Fatalf("got %d results, want one per probe: %s", len(results), output)
+	}
+	for _, r := range results {
+		budget := startupBudget(t, r.Name)
+		t.Logf("wt %s: median %v (min %v, max %v), budget %v", strings.Join(r.Args, " "), r.Median, r.Min, r.Max, budget)
+		if budget > 0 && r.Median > budget {
+			t.Errorf("wt %s takes %v, over the budget of %v (set WT_STARTUP_BUDGET to adjust)", strings.Join(r.Args, " "), r.Median, budget)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// Worktrees share the object store of the repository: only the checked out
+// files take space of their own. `wt stats` shows where the space goes, and
+// finds the cases that break the sharing: a linked worktree with a .git
+// directory of its own or pointing at another repository, and repositories
+// nested inside a worktree (e.g. by running git init in a subdirectory).
+
+// defaultStatsDepth is how many directory levels below a worktree are
+// searched for nested repositories.
+const defaultStatsDepth = 4
+
+// treeScan is the result of walking a directory.
+type treeScan struct {
+	Size int64
+	// Nested are the directories below the root with a .git entry.
+	Nested []string
+}
+
+// treeWalker walks a directory tree concurrently: every directory is read in
+// its own goroutine, with at most one read per CPU at a time.
+type treeWalker struct {
+	ctx      context.Context
+	maxDepth int
+	skip     map[string]bool
+	sem      chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	scan     treeScan
+}
+
+// scanTree sums the size of the files below root, except those in root's own
+// .git, and collects the nested repositories up to maxDepth levels below it.
+// The directories in skip (other worktrees inside root) and unreadable
+// subdirectories are left out. It stops early with the error of ctx when ctx
+// is done.
+func scanTree(ctx context.Context, root string, maxDepth int, skip map[string]bool) (treeScan, error) {
+	if _, err := os.Stat(root); err != nil {
+		return treeScan{}, err
+	}
+	w := &treeWalker{ctx: ctx, maxDepth: maxDepth, skip: skip, sem: make(chan struct{}, runtime.NumCPU())}
+	w.wg.Add(1)
+	go w.walk(root, 0)
+	w.wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return treeScan{}, err
+	}
+	sort.Strings(w.scan.Nested)
+	return w.scan, nil
+}
+
+func (w *treeWalker) walk(dir string, depth int) {
+	defer w.wg.Done()
+	select {
+	case <-w.ctx.Done():
+		return
+	case w.sem <- struct{}{}:
+	}
+	entries, err := os.ReadDir(dir)
+	<-w.sem
+	if err != nil {
+		return
+	}
+	var size int64
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			if depth == 0 {
+				continue
+			}
+			if depth <= w.maxDepth {
+				w.mu.Lock()
+				w.scan.Nested = append(w.scan.Nested, dir)
+				w.mu.Unlock()
+			}
+		}
+		if entry.IsDir() {
+			path := filepath.Join(dir, entry.Name())
+			if !w.skip[path] {
+				w.wg.Add(1)
+				go w.walk(path, depth+1)
+			}
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+	}
+	w.mu.Lock()
+	w.scan.Size += size
+	w.mu.Unlock()
+}
+
+// worktreeStats is the disk usage and sharing state of one worktree.
+type worktreeStats struct {
+	Path   string `json:"path"`
+	Branch string `json:"branch,omitempty"`
+	Size   int64  `json:"size"`
+	// Shared is whether the worktree uses the object store of the repository.
+	Shared  bool     `json:"shared"`
+	Problem string   `json:"problem,omitempty"`
+	Fix     string   `json:"fix,omitempty"`
+	Nested  []string `json:"nested,omitempty"`
+	Skipped string   `json:"skipped,omitempty"`
+}
+
+// repoStats is the `wt stats` document.
+type repoStats struct {
+	CommonDir   string          `json:"commonDir"`
+	ObjectsSize int64           `json:"objectsSize"`
+	Worktrees   []worktreeStats `json:"worktrees"`
+	// WorktreesSize is the size of all working trees together.
+	WorktreesSize int64 `json:"worktreesSize"`
+}
+
+// checkSharing reports whether the checkout at path uses the object store in
+// commonDir and, if not, what is wrong and how to fix it. The main worktree
+// has commonDir as its .git directory; a linked worktree has a .git file
+// pointing into it.
+func checkSharing(path, commonDir string, main bool) (problem, fix string) {
+	dotGit := filepath.Join(path, ".git")
+	info, err := os.Lstat(dotGit)
+	switch {
+	case err != nil:
+		return "no .git entry: git does not see this directory as a worktree",
+			fmt.Sprintf("restore the link with 'wt repair %s'", path)
+	case main:
+		if !sameDir(dotGit, commonDir) {
+			return fmt.Sprintf(".git is not the shared git directory %s", commonDir), ""
+		}
+		return "", ""
+	case info.IsDir():
+		return "has a .git directory of its own instead of sharing " + commonDir,
+			fmt.Sprintf("move %s out of the way, then run 'wt repair %s'", dotGit, path)
+	}
+	other, err := worktreeCommonDir(path)
+	if err != nil {
+		return "unreadable .git file: " + err.Error(),
+			fmt.Sprintf("restore the link with 'wt repair %s'", path)
+	}
+	if !sameDir(other, commonDir) {
+		return "uses the object store of another repository, " + other,
+			fmt.Sprintf("run 'wt repair %s' from the repository it belongs to", path)
+	}
+	return "", ""
+}
+
+// nestedFix suggests what to do about the repository nested in dir, or
+// returns "" for a checkout with a .git file, such as a submodule. A .git
+// directory is usually left over from an accidental git init or clone.
+func nestedFix(dir string) string {
+	if info, err := os.Lstat(filepath.Join(dir, ".git")); err == nil && !info.IsDir() {
+		return ""
+	}
+	return fmt.Sprintf("if %s was created by accident, remove it; otherwise consider a submodule", filepath.Join(dir, ".git"))
+}
+
+// collectStats measures the object store in commonDir and every worktree,
+// the worktrees concurrently.
+func collectStats(ctx context.Context, commonDir string, worktrees []Worktree, maxDepth int) (repoStats, error) {
+	stats := repoStats{CommonDir: commonDir, Worktrees: make([]worktreeStats, len(worktrees))}
+	objects, err := scanTree(ctx, filepath.Join(commonDir, "objects"), 0, nil)
+	if err != nil {
+		return repoStats{}, err
+	}
+	stats.ObjectsSize = objects.Size
+
+	others := make(map[string]bool)
+	for _, wt := range worktrees {
+		others[filepath.Clean(wt.Path)] = true
+	}
+	var wg sync.WaitGroup
+	for i, wt := range worktrees {
+		ws := &stats.Worktrees[i]
+		ws.Path, ws.Branch = wt.Path, wt.Branch
+		switch {
+		case wt.Bare:
+			ws.Skipped = "bare"
+			continue
+		case isOffline(wt):
+			ws.Skipped = "offline"
+			continue
+		}
+		if _, err := os.Stat(wt.Path); err != nil {
+			ws.Skipped = "prunable"
+			continue
+		}
+		ws.Problem, ws.Fix = checkSharing(wt.Path, commonDir, i == 0)
+		ws.Shared = ws.Problem == ""
+		wg.Add(1)
+		go func(ws *worktreeStats) {
+			defer wg.Done()
+			scan, err := scanTree(ctx, ws.Path, maxDepth, others)
+			if err != nil {
+				ws.Skipped = err.Error()
+				return
+			}
+			ws.Size, ws.Nested = scan.Size, scan.Nested
+		}(ws)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return repoStats{}, err
+	}
+	for _, ws := range stats.Worktrees {
+		stats.WorktreesSize += ws.Size
+	}
+	return stats, nil
+}
+
+// brokenSharing counts the worktrees that do not use the shared object store.
+func brokenSharing(stats repoStats) int {
+	broken := 0
+	for _, ws := range stats.Worktrees {
+		if ws.Problem != "" {
+			broken++
+		}
+	}
+	return broken
+}
+
+// formatBytes renders n in binary units, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exp])
+}
+
+// printStats renders stats for humans: the shared object store, a line per
+// worktree and the problems found, with how to fix them.
+func printStats(w io.Writer, stats repoStats, color bool) {
+	shared := 0
+	for _, ws := range stats.Worktrees {
+		if ws.Shared {
+			shared++
+		}
+	}
+	fmt.Fprintf(w, "Object store: %s  %s, shared by %d worktrees\n\n", formatBytes(stats.ObjectsSize), stats.CommonDir, shared)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKTREE\tSIZE\tSHARING")
+	for _, ws := range stats.Worktrees {
+		size, sharing := formatBytes(ws.Size), "ok"
+		switch {
+		case ws.Skipped != "":
+			size, sharing = "-", ws.Skipped
+		case ws.Problem != "":
+			sharing = colorize(color, ansiRed+ansiBold, "BROKEN")
+		case len(ws.Nested) > 0:
+			sharing = colorize(color, ansiYellow, fmt.Sprintf("ok, %d nested", len(ws.Nested)))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", displayPath(ws.Path), size, sharing)
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintf(w, "\nWorking trees: %s; total on disk: %s\n", formatBytes(stats.WorktreesSize), formatBytes(stats.ObjectsSize+stats.WorktreesSize))
+	if shared > 1 {
+		fmt.Fprintf(w, "Sharing saves about %s over a clone per worktree\n", formatBytes(int64(shared-1)*stats.ObjectsSize))
+	}
+
+	for _, ws := range stats.Worktrees {
+		if ws.Problem == "" && len(ws.Nested) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s %s\n", colorize(color, ansiYellow, "!"), displayPath(ws.Path))
+		if ws.Problem != "" {
+			fmt.Fprintf(w, "    %s\n", colorize(color, ansiRed, ws.Problem))
+			if ws.Fix != "" {
+				fmt.Fprintf(w, "    fix: %s\n", ws.Fix)
+			}
+		}
+		for _, dir := range ws.Nested {
+			rel, err := filepath.Rel(ws.Path, dir)
+			if err != nil {
+				rel = dir
+			}
+			kind := "nested repository"
+			fix := nestedFix(dir)
+			if fix == "" {
+				kind = "nested checkout (.git file, e.g. a submodule)"
+			}
+			fmt.Fprintf(w, "    %s: %s\n", kind, rel)
+			if fix != "" {
+				fmt.Fprintf(w, "    fix: %s\n", fix)
+			}
+		}
+	}
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the disk usage of the worktrees and check that they share objects",
+	Long: `Show how much disk space the repository and its worktrees take.
+
+Worktrees share the object store of the repository: each one only adds its
+checked out files. For every worktree wt stats shows the size of its files
+and whether it uses the shared object store, then the size of the object
+store and the totals.
+
+A worktree with a .git directory of its own, or a .git file pointing at
+another repository, does not share objects: it is marked BROKEN together
+with how to fix it, and wt stats exits with status 1. Repositories nested
+inside a worktree (a .git entry in a subdirectory, up to --depth levels
+deep) are listed too; a stray 'git init' in a subdirectory is a common
+cause.
+
+The directories are walked concurrently; Ctrl-C stops the walk.
+
+Examples:
+  wt stats              # Sizes and sharing of every worktree
+  wt stats --depth 8    # Search deeper for nested repositories
+  wt stats --json       # Machine-readable output`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		depth, _ := cmd.Flags().GetInt("depth")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if depth < 0 {
+			return fmt.Errorf("--depth must not be negative")
+		}
+
+		commonDir, err := repoCommonDir()
+		if err != nil {
+			return err
+		}
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		stats, err := collectStats(ctx, commonDir, worktrees, depth)
+		if errors.Is(err, context.Canceled) {
+			return exitWithCode(cmd, exitCancelled)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to measure %s: %w", commonDir, err)
+		}
+
+		if asJSON {
+			for i := range stats.Worktrees {
+				ws := &stats.Worktrees[i]
+				ws.Path = displayPath(ws.Path)
+				for j := range ws.Nested {
+					ws.Nested[j] = displayPath(ws.Nested[j])
+				}
+			}
+			if err := writeJSON(os.Stdout, stats); err != nil {
+				return err
+			}
+		} else {
+			printStats(os.Stdout, stats, colorEnabled(os.Stdout))
+		}
+		if brokenSharing(stats) > 0 {
+			return exitWithCode(cmd, 1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	statsCmd.Flags().Int("depth", defaultStatsDepth, "How many directory levels below a worktree to search for nested repositories")
+	statsCmd.Flags().Bool("json", false, "Output JSON")
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree creates the files of tree below root, with the given sizes.
+// Paths ending in / are created as empty directories.
+func writeTree(t *testing.T, root string, tree map[string]int) {
+	t.Helper()
+	for name, size := range tree {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanTree(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]int{
+		".git/objects/pack/big":          4096,
+		"README.md":                      100,
+		"src/main.go":                    200,
+		"src/vendor/lib/.git/HEAD":       23,
+		"src/vendor/lib/lib.go":          50,
+		"sub/.git":                       30,
+		"a/b/c/d/e/.git/":                0,
+		"a/b/c/d/e/deep.txt":             7,
+		"trees/feature/.git":             40,
+		"trees/feature/not-counted.txt":  1000,
+		"trees/feature/nested/.git/HEAD": 1000,
+	})
+
+	scan, err := scanTree(context.Background(), root, 3, map[string]bool{filepath.Join(root, "trees", "feature"): true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(100 + 200 + 23 + 50 + 30 + 7); scan.Size != want {
+		t.Errorf("scanTree() size = %d, want %d", scan.Size, want)
+	}
+	want := []string{filepath.Join(root, "src", "vendor", "lib"), filepath.Join(root, "sub")}
+	if !reflect.DeepEqual(scan.Nested, want) {
+		t.Errorf("scanTree() nested = %q, want %q", scan.Nested, want)
+	}
+
+	scan, err = scanTree(context.Background(), root, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scan.Nested) != 5 {
+		t.Errorf("scanTree() at depth 5 nested = %q, want 5 repositories", scan.Nested)
+	}
+}
+
+func TestScanTreeCancelled(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]int{"a/b/c/file": 1, "d/e/f/file": 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := scanTree(ctx, root, 3, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("scanTree() with a cancelled context: err = %v, want context.Canceled", err)
+	}
+	if _, err := scanTree(context.Background(), filepath.Join(root, "missing"), 3, nil); err == nil {
+		t.Error("scanTree() of a missing directory succeeded")
+	}
+}
+
+func TestCheckSharing(t *testing.T) {
+	tmpDir := t.TempDir()
+	commonDir := filepath.Join(tmpDir, "api", ".git")
+	otherDir := filepath.Join(tmpDir, "web", ".git")
+	writeTree(t, tmpDir, map[string]int{
+		"api/.git/worktrees/shared/": 0,
+		"web/.git/worktrees/stray/":  0,
+		"trees/own/.git/HEAD":        23,
+		"trees/gone/":                0,
+	})
+	for name, gitdir := range map[string]string{
+		"shared": filepath.Join(commonDir, "worktrees", "shared"),
+		"stray":  filepath.Join(otherDir, "worktrees", "stray"),
+	} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, "trees", name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, "trees", name, ".git"), []byte("gitdir: "+gitdir+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path    string
+		main    bool
+		problem string
+	}{
+		{filepath.Join(tmpDir, "api"), true, ""},
+		{filepath.Join(tmpDir, "web"), true, "not the shared git directory"},
+		{filepath.Join(tmpDir, "trees", "shared"), false, ""},
+		{filepath.Join(tmpDir, "trees", "own"), false, "a .git directory of its own"},
+		{filepath.Join(tmpDir, "trees", "stray"), false, "another repository"},
+		{filepath.Join(tmpDir, "trees", "gone"), false, "no .git entry"},
+	}
+	for _, tt := range tests {
+		problem, fix := checkSharing(tt.path, commonDir, tt.main)
+		if tt.problem == "" && problem != "" || !strings.Contains(problem, tt.problem) {
+			t.Errorf("checkSharing(%s) = %q, want %q", tt.path, problem, tt.problem)
+		}
+		if problem != "" && !tt.main && !strings.Contains(fix, "wt repair") {
+			t.Errorf("checkSharing(%s) fix = %q, want a wt repair hint", tt.path, fix)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:          "0 B",
+		1023:       "1023 B",
+		1536:       "1.5 KiB",
+		5 << 20:    "5.0 MiB",
+		3 << 30:    "3.0 GiB",
+		1<<40 + 1:  "1.0 TiB",
+		2048 << 40: "2.0 PiB",
+		4096 << 50: "4096.0 PiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestCollectStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	feature := filepath.Join(tmpDir, "trees", "feature")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feature", feature)
+	// An accidental git init in a subdirectory of the worktree.
+	runGitCommand(t, feature, "init", "-q", "scratch")
+	t.Chdir(repoDir)
+
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commonDir, err := repoCommonDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := collectStats(context.Background(), commonDir, worktrees, defaultStatsDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ObjectsSize == 0 || stats.WorktreesSize == 0 {
+		t.Errorf("collectStats() sizes = %d objects, %d worktrees", stats.ObjectsSize, stats.WorktreesSize)
+	}
+	if len(stats.Worktrees) != 2 || !stats.Worktrees[0].Shared || !stats.Worktrees[1].Shared {
+		t.Fatalf("collectStats() worktrees = %+v, want two sharing objects", stats.Worktrees)
+	}
+	if got := stats.Worktrees[1].Nested; len(got) != 1 || filepath.Base(got[0]) != "scratch" {
+		t.Errorf("nested repositories = %q, want scratch", got)
+	}
+	if brokenSharing(stats) != 0 {
+		t.Error("brokenSharing() > 0 for healthy worktrees")
+	}
+
+	var out strings.Builder
+	printStats(&out, stats, false)
+	for _, want := range []string{"shared by 2 worktrees", "ok, 1 nested", "nested repository: scratch", "Sharing saves"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printStats() output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	// Replace the link with a repository of its own.
+	if err := os.Remove(filepath.Join(feature, ".git")); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, feature, "init", "-q")
+	stats, err = collectStats(context.Background(), commonDir, worktrees, defaultStatsDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Worktrees[1].Shared || brokenSharing(stats) != 1 {
+		t.Errorf("worktree with its own .git directory = %+v, want broken sharing", stats.Worktrees[1])
+	}
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// statusSchemaVersion is the version of the `wt status --json` document.
+// Fields are only ever added within a version; renaming or removing one
+// bumps it.
+const statusSchemaVersion = 1
+
+// statusDocument describes the worktrees of one or more repositories for
+// dashboards. Problems reading a repository or a worktree end up in the
+// errors of that entry instead of failing the whole document.
+type statusDocument struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	GeneratedAt   time.Time          `json:"generatedAt"`
+	WorktreeRoot  string             `json:"worktreeRoot"`
+	Repositories  []statusRepository `json:"repositories"`
+}
+
+type statusRepository struct {
+	Name string `json:"name"`
+	// Path is the main worktree, or the directory that could not be read.
+	Path      string           `json:"path"`
+	Worktrees []statusWorktree `json:"worktrees"`
+	Errors    []string         `json:"errors"`
+}
+
+type statusWorktree struct {
+	Path     string `json:"path"`
+	Branch   string `json:"branch"`
+	Head     string `json:"head"`
+	Main     bool   `json:"main"`
+	Detached bool   `json:"detached"`
+	Locked   bool   `json:"locked"`
+	Pinned   bool   `json:"pinned"`
+	Prunable bool   `json:"prunable"`
+	// Missing marks a prunable worktree whose directory was deleted.
+	Missing bool `json:"missing"`
+	Offline bool `json:"offline"`
+	// Unknown counts are null: no working tree to inspect, or no upstream.
+	// DirtyFiles is the sum of ModifiedFiles and UntrackedFiles.
+	DirtyFiles     *int          `json:"dirtyFiles"`
+	ModifiedFiles  *int          `json:"modifiedFiles"`
+	UntrackedFiles *int          `json:"untrackedFiles"`
+	Ahead          *int          `json:"ahead"`
+	Behind         *int          `json:"behind"`
+	CreatedAt      *time.Time    `json:"createdAt"`
+	LastSwitchedAt *time.Time    `json:"lastSwitchedAt"`
+	AgeSeconds     *int64        `json:"ageSeconds"`
+	Review         *statusReview `json:"review"`
+	Errors         []string      `json:"errors"`
+
+	// unknown is set when git status failed, so the worktree may be dirty.
+	unknown bool
+}
+
+// statusReview is the PR/MR a worktree was checked out for. State and title
+// come from the forge and stay empty when it cannot be asked.
+type statusReview struct {
+	Forge  string `json:"forge"`
+	Number string `json:"number"`
+	State  string `json:"state"`
+	Title  string `json:"title"`
+	// ReviewedAt is the commit of `wt pr --at`/`--round`, and NewCommits
+	// counts the commits of the head fetched with it that it lacks.
+	ReviewedAt string `json:"reviewedAt,omitempty"`
+	NewCommits *int   `json:"newCommits,omitempty"`
+}
+
+// statusSource is a checkout to gather the status of a repository from, or
+// the error that made a directory below WORKTREE_ROOT unreadable.
+type statusSource struct {
+	Dir string
+	Err error
+}
+
+// findStatusSources returns one checkout per repository with worktrees below
+// root. Directories whose git dir cannot be found are returned with an error,
+// so they show up in the document.
+func findStatusSources(root string) ([]statusSource, error) {
+	var sources []statusSource
+	seen := map[string]bool{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return nil
+		}
+		if !d.IsDir() || p == root {
+			return nil
+		}
+		if filepath.Dir(p) == root && d.Name() == backupsDirName {
+			return fs.SkipDir
+		}
+		if _, err := os.Lstat(filepath.Join(p, ".git")); err != nil {
+			return nil
+		}
+		commonDir, err := worktreeCommonDir(p)
+		if err == nil {
+			if _, statErr := os.Stat(commonDir); statErr != nil {
+				err = fmt.Errorf("git dir %s is gone", commonDir)
+			}
+		}
+		switch {
+		case err != nil:
+			sources = append(sources, statusSource{Dir: p, Err: fmt.Errorf("unreadable checkout %s: %w", p, err)})
+		case !seen[resolvePath(commonDir)]:
+			seen[resolvePath(commonDir)] = true
+			sources = append(sources, statusSource{Dir: p})
+		}
+		return fs.SkipDir
+	})
+	return sources, err
+}
+
+// mapStatusPaths rewrites the paths of doc for printing, see displayPath.
+func mapStatusPaths(doc *statusDocument) {
+	doc.WorktreeRoot = displayPath(doc.WorktreeRoot)
+	for i := range doc.Repositories {
+		repo := &doc.Repositories[i]
+		repo.Path = displayPath(repo.Path)
+		for j := range repo.Worktrees {
+			repo.Worktrees[j].Path = displayPath(repo.Worktrees[j].Path)
+		}
+	}
+}
+
+// gatherStatus collects the document for sources, the repositories in the
+// order given.
+func gatherStatus(sources []statusSource, now time.Time) statusDocument {
+	doc := statusDocument{
+		SchemaVersion: statusSchemaVersion,
+		GeneratedAt:   now.UTC(),
+		WorktreeRoot:  worktreeRoot,
+		Repositories:  []statusRepository{},
+	}
+	seen := map[string]bool{}
+	for _, source := range sources {
+		repo := gatherRepoStatus(source, now)
+		if repo.Path != "" && len(repo.Errors) == 0 {
+			if seen[repo.Path] {
+				continue
+			}
+			seen[repo.Path] = true
+		}
+		doc.Repositories = append(doc.Repositories, repo)
+	}
+	sort.SliceStable(doc.Repositories, func(i, j int) bool {
+		a, b := doc.Repositories[i], doc.Repositories[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Path < b.Path
+	})
+	return doc
+}
+
+// gatherRepoStatus collects the worktrees of the repository at source.Dir.
+// Repository state is read relative to the working directory, so it changes
+// there for the duration.
+func gatherRepoStatus(source statusSource, now time.Time) statusRepository {
+	repo := statusRepository{
+		Name:      filepath.Base(source.Dir),
+		Path:      source.Dir,
+		Worktrees: []statusWorktree{},
+		Errors:    []string{},
+	}
+	if rel, err := filepath.Rel(worktreeRoot, source.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+		repo.Name = strings.Split(filepath.ToSlash(rel), "/")[0]
+	}
+	if source.Err != nil {
+		repo.Errors = append(repo.Errors, source.Err.Error())
+		return repo
+	}
+	cwd, err := os.Getwd()
+	if err == nil {
+		err = os.Chdir(source.Dir)
+	}
+	if err != nil {
+		repo.Errors = append(repo.Errors, err.Error())
+		return repo
+	}
+	defer func() { _ = os.Chdir(cwd) }()
+
+	if name, err := getRepoName(); err == nil {
+		repo.Name = name
+	}
+	worktrees, err := listWorktrees("")
+	if err != nil {
+		repo.Errors = append(repo.Errors, err.Error())
+		return repo
+	}
+	infos := newWorktreeInfos(worktrees)
+	markPinned(infos, loadPinnedBranches())
+	markOffline(infos, findOfflineWorktrees(worktrees))
+	loadDirtyState(infos)
+	loadTimes(infos)
+	loadUpstreamCounts(infos)
+	if len(infos) > 0 {
+		repo.Path = infos[0].Path
+	}
+	for _, info := range infos {
+		repo.Worktrees = append(repo.Worktrees, newStatusWorktree(info, now))
+	}
+	loadStatusReviews(repo.Worktrees)
+	return repo
+}
+
+func newStatusWorktree(info worktreeInfo, now time.Time) statusWorktree {
+	wt := statusWorktree{
+		Path:           info.Path,
+		Branch:         info.Branch,
+		Head:           info.Head,
+		Main:           info.Main,
+		Detached:       info.Detached,
+		Locked:         info.Locked,
+		Pinned:         info.Pinned,
+		Prunable:       info.Prunable,
+		Offline:        info.Offline,
+		DirtyFiles:     info.DirtyFiles,
+		ModifiedFiles:  info.modifiedFiles,
+		UntrackedFiles: info.untrackedFiles,
+		Ahead:          info.Ahead,
+		Behind:         info.Behind,
+		CreatedAt:      info.CreatedAt,
+		LastSwitchedAt: info.LastSwitchedAt,
+		Errors:         []string{},
+	}
+	if info.Prunable {
+		_, err := os.Lstat(info.Path)
+		wt.Missing = errors.Is(err, fs.ErrNotExist)
+	}
+	if info.CreatedAt != nil {
+		age := int64(now.Sub(*info.CreatedAt).Seconds())
+		wt.AgeSeconds = &age
+	}
+	if info.StatusError != "" {
+		wt.unknown = true
+		wt.Errors = append(wt.Errors, "status: "+info.StatusError)
+	}
+	return wt
+}
+
+// loadStatusReviews fills in the PR/MR of every worktree checked out with
+// `wt pr`/`wt mr`, asking the forge for state and title concurrently. When
+// the forge cannot be asked, the review keeps its number and the worktree
+// gets an error.
+func loadStatusReviews(worktrees []statusWorktree) {
+	output, _ := repoGit("config", "--get-regexp", `^wt-review\..*\.branch$`).Output()
+	var wg sync.WaitGroup
+	for i := range worktrees {
+		wt := &worktrees[i]
+		if wt.Branch == "" {
+			continue
+		}
+		review := reviewForBranch(string(output), wt.Branch)
+		if review == "" {
+			review = wt.Branch
+		}
+		remoteType, number, ok := parseReview(review)
+		if !ok {
+			continue
+		}
+		wt.Review = &statusReview{Forge: forgeCLI(remoteType), Number: number}
+		if point, ok := loadReviewPoint(review); ok {
+			wt.Review.ReviewedAt = point.At
+			if n, ok := newCommitsSince(point); ok {
+				wt.Review.NewCommits = &n
+			}
+		}
+		if offline {
+			wt.Errors = append(wt.Errors, "review: offline")
+			continue
+		}
+		if err := requireRemote(); err != nil {
+			wt.Errors = append(wt.Errors, "review: "+err.Error())
+			continue
+		}
+		wg.Add(1)
+		go func(wt *statusWorktree, remoteType RemoteType) {
+			defer wg.Done()
+			state, title, err := queryReviewState(remoteType, wt.Review.Number)
+			if err != nil {
+				wt.Errors = append(wt.Errors, "review: "+firstLine(err.Error()))
+				return
+			}
+			wt.Review.State, wt.Review.Title = state, title
+		}(wt, remoteType)
+	}
+	wg.Wait()
+}
+
+// queryReviewState asks gh/glab for the state and title of PR/MR number.
+func queryReviewState(remoteType RemoteType, number string) (string, string, error) {
+	if err := requireReviewCLI(remoteType); err != nil {
+		return "", "", err
+	}
+	var output []byte
+	var err error
+	if remoteType == RemoteGitLab {
+		output, err = runForgeCLI(remoteType, "mr", "view", number, "-F", "json")
+	} else {
+		output, err = runForgeCLI(remoteType, "pr", "view", number, "--json", "state,title")
+	}
+	if err != nil {
+		return "", "", err
+	}
+	var review struct {
+		State string `json:"state"`
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(output, &review); err != nil {
+		return "", "", fmt.Errorf("unexpected %s output: %w", forgeCLI(remoteType), err)
+	}
+	return strings.ToLower(review.State), review.Title, nil
+}
+
+// printStatus renders the document for humans: a heading per repository and
+// a line per worktree.
+func printStatus(w io.Writer, doc statusDocument) {
+	for i, repo := range doc.Repositories {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s  %s\n", repo.Name, repo.Path)
+		for _, e := range repo.Errors {
+			fmt.Fprintf(w, "  ! %s\n", e)
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, wt := range repo.Worktrees {
+			branch := wt.Branch
+			if branch == "" {
+				branch = "(detached)"
+			}
+			columns := []string{"  " + branch, wt.Path, statusSummary(wt)}
+			if wt.Review != nil {
+				columns = append(columns, strings.TrimSpace(fmt.Sprintf("%s %s %s", reviewSigil(wt.Review.Forge)+wt.Review.Number, wt.Review.State, wt.Review.Title)))
+				if n := wt.Review.NewCommits; n != nil && *n > 0 {
+					columns[len(columns)-1] += fmt.Sprintf(" (%s since your review point)", pluralCommits(*n))
+				}
+			}
+			fmt.Fprintln(tw, strings.Join(columns, "\t"))
+			for _, e := range wt.Errors {
+				fmt.Fprintf(tw, "    ! %s\n", e)
+			}
+		}
+		tw.Flush()
+	}
+}
+
+// statusSummary describes the working tree and upstream state of wt, e.g.
+// "2 modified, 1 untracked +1/-0".
+func statusSummary(wt statusWorktree) string {
+	var parts []string
+	switch {
+	case wt.Offline:
+		parts = append(parts, "offline")
+	case wt.Missing:
+		parts = append(parts, "missing")
+	case wt.Prunable:
+		parts = append(parts, "prunable")
+	case wt.DirtyFiles == nil:
+		parts = append(parts, "?")
+	case *wt.DirtyFiles > 0:
+		parts = append(parts, dirtySummary(wt))
+	default:
+		parts = append(parts, "clean")
+	}
+	if wt.Ahead != nil && wt.Behind != nil {
+		parts = append(parts, fmt.Sprintf("+%d/-%d", *wt.Ahead, *wt.Behind))
+	}
+	if wt.Pinned {
+		parts = append(parts, "pinned")
+	}
+	if wt.Locked {
+		parts = append(parts, "locked")
+	}
+	return strings.Join(parts, " ")
+}
+
+// dirtySummary says what the dirty files of wt are, e.g. "3 modified, 1
+// untracked".
+func dirtySummary(wt statusWorktree) string {
+	if wt.ModifiedFiles == nil || wt.UntrackedFiles == nil {
+		return fmt.Sprintf("%d dirty", *wt.DirtyFiles)
+	}
+	var counts []string
+	if n := *wt.ModifiedFiles; n > 0 {
+		counts = append(counts, fmt.Sprintf("%d modified", n))
+	}
+	if n := *wt.UntrackedFiles; n > 0 {
+		counts = append(counts, fmt.Sprintf("%d untracked", n))
+	}
+	return strings.Join(counts, ", ")
+}
+
+// anyDirty reports whether a worktree of doc has uncommitted changes, or may
+// have them because git status failed on it.
+func anyDirty(doc statusDocument) bool {
+	for _, repo := range doc.Repositories {
+		for _, wt := range repo.Worktrees {
+			if wt.DirtyFiles != nil && *wt.DirtyFiles > 0 || wt.unknown {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reviewSigil is # for GitHub PRs and ! for GitLab MRs.
+func reviewSigil(forge string) string {
+	if forge == forgeCLI(RemoteGitLab) {
+		return "!"
+	}
+	return "#"
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status [--all] [--json]",
+	Short: "Show the status of every worktree, optionally across repositories",
+	Long: `Show branch, dirty files, ahead/behind counts, age and PR/MR of every
+worktree of the current repository, or with --all of every repository with
+worktrees below WORKTREE_ROOT.
+
+Each worktree is summarized as "clean" or by its changes, e.g. "3 modified,
+1 untracked". A worktree whose directory was deleted by hand is "missing"
+(see 'wt prune'), one on a volume that is not mounted "offline". Detached
+worktrees show as (detached). The exit code is 1 when any worktree has
+uncommitted changes, or may have them because git status failed on it ("?"),
+so scripts can check before cleaning up.
+
+With --json a versioned document for dashboards is printed ("schemaVersion":
+1). A repository that cannot be read, or a forge that cannot be asked, adds
+to the "errors" of that repository or worktree instead of failing the whole
+document. PR/MR state and title are asked from gh/glab unless --offline.
+
+Examples:
+  wt status                 # Worktrees of the current repository
+  wt status >/dev/null || echo "uncommitted work"
+  wt status --all --json    # Every repository, for a dashboard`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		var sources []statusSource
+		if all {
+			found, err := findStatusSources(worktreeRoot)
+			if err != nil {
+				return err
+			}
+			sources = found
+		}
+		if cwd, err := currentWorktreePath(); err == nil {
+			sources = append([]statusSource{{Dir: cwd}}, sources...)
+		} else if !all {
+			return err
+		}
+
+		doc := gatherStatus(sources, time.Now())
+		mapStatusPaths(&doc)
+		if asJSON {
+			if err := writeJSON(os.Stdout, doc); err != nil {
+				return err
+			}
+		} else {
+			printStatus(os.Stdout, doc)
+		}
+		if anyDirty(doc) {
+			return exitWithCode(cmd, 1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	statusCmd.Flags().Bool("all", false, "Every repository with worktrees below WORKTREE_ROOT")
+	statusCmd.Flags().Bool("json", false, "Output a versioned JSON document")
+}
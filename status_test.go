@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupStatusFixture fabricates two repositories with worktrees below
+// <tmp>/trees, one of them deleted by hand, and a checkout there whose
+// repository is gone.
+func setupStatusFixture(t *testing.T, tmpDir string) {
+	t.Helper()
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	t.Setenv("GIT_AUTHOR_DATE", created.Format(time.RFC3339))
+	t.Setenv("GIT_COMMITTER_DATE", created.Format(time.RFC3339))
+
+	trees := filepath.Join(tmpDir, "trees")
+	api := filepath.Join(tmpDir, "src", "api")
+	web := filepath.Join(tmpDir, "src", "web")
+	setupTestRepo(t, api)
+	setupTestRepo(t, web)
+	runGitCommand(t, api, "worktree", "add", "-q", filepath.Join(trees, "api", "feature", "x"), "-b", "feature/x")
+	runGitCommand(t, api, "worktree", "add", "-q", filepath.Join(trees, "api", "pr-7"), "-b", "pr-7")
+	runGitCommand(t, api, "config", "branch.feature/x.wt-pinned", "true")
+	runGitCommand(t, api, "worktree", "add", "-q", filepath.Join(trees, "api", "spike"), "-b", "spike")
+	if err := os.RemoveAll(filepath.Join(trees, "api", "spike")); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, web, "worktree", "add", "-q", filepath.Join(trees, "web", "fix"), "-b", "fix")
+	if err := os.WriteFile(filepath.Join(trees, "web", "fix", "notes.txt"), []byte("wip\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{api, web, filepath.Join(trees, "api", "feature", "x"), filepath.Join(trees, "api", "pr-7"), filepath.Join(trees, "web", "fix")} {
+		setWorktreeTime(path, "createdAt", created)
+	}
+
+	broken := filepath.Join(trees, "gone", "old")
+	if err := os.MkdirAll(broken, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitFile := "gitdir: " + filepath.Join(tmpDir, "gone", ".git", "worktrees", "old") + "\n"
+	if err := os.WriteFile(filepath.Join(broken, ".git"), []byte(gitFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStatusAllJSON(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping fixture test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("the golden file has slash-separated paths")
+	}
+
+	tmpDir := t.TempDir()
+	setupStatusFixture(t, tmpDir)
+	originalRoot := worktreeRoot
+	t.Cleanup(func() {
+		worktreeRoot = originalRoot
+	})
+	worktreeRoot = filepath.Join(tmpDir, "trees")
+	cwd, _ := os.Getwd()
+
+	sources, err := findStatusSources(worktreeRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := gatherStatus(sources, time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC))
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "status_all.golden", strings.ReplaceAll(buf.String(), tmpDir, "$TMP"))
+
+	if dir, _ := os.Getwd(); dir != cwd {
+		t.Errorf("gatherStatus() left the working directory at %s", dir)
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	dirty, modified, untracked, clean, ahead, behind := 3, 2, 1, 0, 1, 0
+	doc := statusDocument{Repositories: []statusRepository{
+		{Name: "api", Path: "/src/api", Worktrees: []statusWorktree{
+			{Path: "/src/api", Branch: "main", Main: true, DirtyFiles: &clean, ModifiedFiles: &clean, UntrackedFiles: &clean},
+			{Path: "/trees/api/pr-7", Branch: "pr-7", DirtyFiles: &dirty, ModifiedFiles: &modified, UntrackedFiles: &untracked, Ahead: &ahead, Behind: &behind, Pinned: true,
+				Review: &statusReview{Forge: "gh", Number: "7", State: "open", Title: "Fix login"}},
+			{Path: "/trees/api/spike", Branch: "spike", Prunable: true, Missing: true},
+			{Path: "/trees/api/snap", Detached: true, DirtyFiles: &untracked, ModifiedFiles: &clean, UntrackedFiles: &untracked},
+		}},
+		{Name: "gone", Path: "/trees/gone/old", Errors: []string{"unreadable checkout /trees/gone/old"}},
+	}}
+	var buf bytes.Buffer
+	printStatus(&buf, doc)
+	want := "api  /src/api\n" +
+		"  main        /src/api          clean\n" +
+		"  pr-7        /trees/api/pr-7   2 modified, 1 untracked +1/-0 pinned  #7 open Fix login\n" +
+		"  spike       /trees/api/spike  missing\n" +
+		"  (detached)  /trees/api/snap   1 untracked\n" +
+		"\n" +
+		"gone  /trees/gone/old\n" +
+		"  ! unreadable checkout /trees/gone/old\n"
+	if buf.String() != want {
+		t.Errorf("printStatus() =\n%s\nwant\n%s", buf.String(), want)
+	}
+	if !anyDirty(doc) {
+		t.Error("anyDirty() = false with pr-7 dirty")
+	}
+	doc.Repositories[0].Worktrees = doc.Repositories[0].Worktrees[:1]
+	if anyDirty(doc) {
+		t.Error("anyDirty() = true with only clean worktrees")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Inside a submodule, wt treats the submodule as a repository of its own:
+// git resolves the submodule's git dir (in the superproject's .git/modules),
+// so the repository name comes from the submodule's URL and its worktrees
+// live in their own directory below WORKTREE_ROOT. --super switches to the
+// superproject instead.
+
+// inSubmodule reports whether the repository in the current directory is a
+// submodule.
+func inSubmodule() bool {
+	dir, err := repoCommonDir()
+	return err == nil && strings.Contains(filepath.ToSlash(dir), "/.git/modules/")
+}
+
+// superprojectDir returns the working tree of the superproject of the
+// submodule at dir (the current directory when empty), or "" when dir is not
+// in a submodule. Linked worktrees of a submodule are not known to the
+// superproject; their superproject is that of the submodule's main checkout.
+func superprojectDir(dir string) (string, error) {
+	output, err := gitIn(dir, "rev-parse", "--show-superproject-working-tree").Output()
+	if err != nil {
+		return "", err
+	}
+	if super := strings.TrimSpace(string(output)); super != "" {
+		return filepath.Clean(super), nil
+	}
+	if dir != "" || !inSubmodule() {
+		return "", nil
+	}
+	worktrees, err := listWorktrees("")
+	if err != nil || len(worktrees) == 0 {
+		return "", err
+	}
+	return superprojectDir(worktrees[0].Path)
+}
+
+// applySuperFlag makes the superproject the working directory of cmd when
+// --super is given.
+func applySuperFlag(cmd *cobra.Command) error {
+	if super, _ := cmd.Flags().GetBool("super"); !super {
+		return nil
+	}
+	dir, err := superprojectDir("")
+	if err != nil {
+		return fmt.Errorf("--super: not in a git repository")
+	}
+	if dir == "" {
+		return fmt.Errorf("--super: not inside a submodule")
+	}
+	return os.Chdir(dir)
+}
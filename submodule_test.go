@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/timvw/wt/internal/testrepo"
+)
+
+func TestSubmoduleContext(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	lib := filepath.Join(tmpDir, "lib")
+	app := filepath.Join(tmpDir, "app")
+	setupTestRepo(t, lib)
+	setupTestRepo(t, app)
+	if err := testrepo.AddSubmodule(app, lib, "vendor/lib"); err != nil {
+		t.Fatal(err)
+	}
+	checkout := filepath.Join(app, "vendor", "lib")
+	linked := filepath.Join(tmpDir, "worktrees", "lib", "feature")
+	runGitCommand(t, checkout, "worktree", "add", "-q", linked, "-b", "feature")
+
+	t.Chdir(app)
+	if inSubmodule() {
+		t.Error("inSubmodule() in the superproject = true")
+	}
+	if name, err := getRepoName(); err != nil || name != "app" {
+		t.Errorf("getRepoName() in the superproject = %q, %v", name, err)
+	}
+	if dir, err := superprojectDir(""); err != nil || dir != "" {
+		t.Errorf("superprojectDir() in the superproject = %q, %v", dir, err)
+	}
+
+	for _, dir := range []string{checkout, linked} {
+		t.Chdir(dir)
+		if !inSubmodule() {
+			t.Errorf("inSubmodule() in %s = false", dir)
+		}
+		if name, err := getRepoName(); err != nil || name != "lib" {
+			t.Errorf("getRepoName() in %s = %q, %v; want the submodule's name", dir, name, err)
+		}
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(worktrees) != 2 || !sameDir(worktrees[0].Path, checkout) || !sameDir(worktrees[1].Path, linked) {
+			t.Errorf("listWorktrees() in %s = %+v; want the submodule's checkout first", dir, worktrees)
+		}
+		if super, err := superprojectDir(""); err != nil || !sameDir(super, app) {
+			t.Errorf("superprojectDir() in %s = %q, %v; want %s", dir, super, err, app)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/timvw/wt/pkg/worktree"
+)
+
+// switchTargets returns the worktrees `wt switch` can jump to: the ones with
+// a branch whose directory still exists.
+func switchTargets(worktrees []Worktree) []Worktree {
+	var targets []Worktree
+	for _, wt := range worktrees {
+		if wt.Bare || wt.Branch == "" || wt.Prunable {
+			continue
+		}
+		targets = append(targets, wt)
+	}
+	return targets
+}
+
+// findSwitchTarget returns the worktree of branch. It never creates one: a
+// branch without a worktree is pointed at `wt checkout`.
+func findSwitchTarget(worktrees []Worktree, branch string) (Worktree, error) {
+	for _, wt := range worktrees {
+		if wt.Branch == "" || !worktree.SameBranch(wt.Branch, branch) {
+			continue
+		}
+		if wt.Prunable {
+			return Worktree{}, fmt.Errorf("the worktree of '%s' at %s was deleted\nRun 'wt prune', then 'wt checkout %s' to create it again", branch, displayPath(wt.Path), branch)
+		}
+		return wt, nil
+	}
+	return Worktree{}, fmt.Errorf("no worktree for branch '%s'\nUse 'wt checkout %s' to create one", branch, branch)
+}
+
+// recentFirst orders targets as `wt recent` does: the visited worktrees of
+// repo first, most recent first, then the rest by branch. It also returns the
+// visits of worktrees that are gone, to prune.
+func recentFirst(repo string, history []visit, targets []Worktree) ([]Worktree, []visit) {
+	entries, stale := mergeRecent(repo, history, targets)
+	byPath := make(map[string]Worktree, len(targets))
+	for _, wt := range targets {
+		byPath[filepath.Clean(wt.Path)] = wt
+	}
+	ordered := make([]Worktree, 0, len(entries))
+	for _, e := range entries {
+		ordered = append(ordered, byPath[filepath.Clean(e.Path)])
+	}
+	return ordered, stale
+}
+
+// switchLabels labels the targets of the picker with their branch and path,
+// aligned.
+func switchLabels(targets []Worktree) []string {
+	width := 0
+	for _, wt := range targets {
+		width = max(width, len(wt.Branch))
+	}
+	labels := make([]string, len(targets))
+	for i, wt := range targets {
+		labels[i] = fmt.Sprintf("%-*s  %s", width, wt.Branch, displayPath(wt.Path))
+	}
+	return labels
+}
+
+var switchCmd = &cobra.Command{
+	Use:     "switch [branch]",
+	Aliases: []string{"sw"},
+	Short:   "Switch to an existing worktree",
+	Long: `Switch to the worktree of a branch that already has one.
+
+Without a branch, pick one of the worktrees of the repository, the main one
+included: the ones you visited most recently first, then the rest by branch.
+With the shell integration your shell changes into the worktree; otherwise
+wt prints the 'cd' line to run.
+
+A branch without a worktree is not created unless asked: wt switch fails and
+suggests 'wt checkout'. With -c/--create <branch>, wt switch creates the
+branch from --base (default: main/master) in a new worktree and switches to
+it, exactly as 'wt create' does. -C <branch> does the same, but an existing
+branch is reset to the base first; since that moves the branch, wt asks
+before doing so (--yes skips the question).
+
+Examples:
+  wt switch                        # Pick a worktree
+  wt sw feature-x                  # Jump to the worktree of feature-x
+  wt switch -c feature-y           # Create feature-y and switch to it
+  wt switch -c fix --base v1.2     # Start fix from v1.2
+  wt switch -C scratch             # Start scratch over from main/master`,
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		create, _ := cmd.Flags().GetString("create")
+		forceCreate, _ := cmd.Flags().GetString("force-create")
+		base, _ := cmd.Flags().GetString("base")
+		if create != "" || forceCreate != "" {
+			if len(args) > 0 {
+				return fmt.Errorf("pass the branch to -c/-C, not as an argument")
+			}
+			if forceCreate != "" {
+				return resetWorktree(cmd, forceCreate, base)
+			}
+			return createWorktree(cmd, create, base)
+		}
+		if base != "" {
+			return fmt.Errorf("--base is only used with -c/--create or -C")
+		}
+		worktrees, err := listWorktrees("")
+		if err != nil {
+			return err
+		}
+		var target Worktree
+		if len(args) == 1 {
+			if target, err = findSwitchTarget(worktrees, args[0]); err != nil {
+				return err
+			}
+		} else {
+			repo, _ := getRepoName()
+			history, _ := loadHistory()
+			targets, stale := recentFirst(repo, history, switchTargets(worktrees))
+			pruneHistory(stale)
+			if len(targets) == 0 {
+				return fmt.Errorf("no worktrees to switch to\nUse 'wt checkout' to create one")
+			}
+			idx, err := promptFor(cmd).Select("Select worktree to switch to", switchLabels(targets), SelectOptions{})
+			if err != nil {
+				return err
+			}
+			target = targets[idx]
+		}
+		infof("✓ Switching to %s: %s\n", target.Branch, displayPath(target.Path))
+		printCDMarker(target.Path)
+		return nil
+	},
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSwitchTargets(t *testing.T) {
+	worktrees := []Worktree{
+		{Path: "/src/api", Branch: "main"},
+		{Path: "/trees/api/feature-x", Branch: "feature-x"},
+		{Path: "/trees/api/gone", Branch: "gone", Prunable: true},
+		{Path: "/trees/api/snapshot", Head: "abc"},
+	}
+	var got []string
+	for _, wt := range switchTargets(worktrees) {
+		got = append(got, wt.Branch)
+	}
+	if want := []string{"main", "feature-x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("switchTargets() = %q, want %q", got, want)
+	}
+
+	if wt, err := findSwitchTarget(worktrees, "feature-x"); err != nil || wt.Path != "/trees/api/feature-x" {
+		t.Errorf("findSwitchTarget(feature-x) = %+v, %v", wt, err)
+	}
+	if _, err := findSwitchTarget(worktrees, "other"); err == nil || !strings.Contains(err.Error(), "Use 'wt checkout other' to create one") {
+		t.Errorf("findSwitchTarget(no worktree) = %v, want a hint at wt checkout", err)
+	}
+	if _, err := findSwitchTarget(worktrees, "gone"); err == nil || !strings.Contains(err.Error(), "was deleted") {
+		t.Errorf("findSwitchTarget(deleted) = %v, want it reported as deleted", err)
+	}
+}
+
+func TestRecentFirst(t *testing.T) {
+	targets := []Worktree{
+		{Path: "/src/api", Branch: "main"},
+		{Path: "/trees/api/zeta", Branch: "zeta"},
+		{Path: "/trees/api/alpha", Branch: "alpha"},
+		{Path: "/trees/api/feature-x", Branch: "feature-x"},
+	}
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	history := []visit{
+		{Repo: "api", Path: "/trees/api/gone", VisitedAt: now},
+		{Repo: "api", Path: "/trees/api/feature-x", VisitedAt: now.Add(-time.Hour)},
+		{Repo: "web", Path: "/trees/web/alpha", VisitedAt: now},
+		{Repo: "api", Path: "/trees/api/zeta", VisitedAt: now.Add(-time.Minute)},
+	}
+	ordered, stale := recentFirst("api", history, targets)
+	var got []string
+	for _, wt := range ordered {
+		got = append(got, wt.Branch)
+	}
+	if want := []string{"zeta", "feature-x", "alpha", "main"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recentFirst() = %q, want %q", got, want)
+	}
+	if len(stale) != 1 || stale[0].Path != "/trees/api/gone" {
+		t.Errorf("recentFirst() stale = %+v, want the gone worktree", stale)
+	}
+}
+
+func TestSwitchLabels(t *testing.T) {
+	targets := []Worktree{{Path: filepath.FromSlash("/src/api"), Branch: "main"}, {Path: filepath.FromSlash("/trees/api/feature-x"), Branch: "feature-x"}}
+	want := []string{
+		"main       " + filepath.FromSlash("/src/api"),
+		"feature-x  " + filepath.FromSlash("/trees/api/feature-x"),
+	}
+	if got := switchLabels(targets); !reflect.DeepEqual(got, want) {
+		t.Errorf("switchLabels() = %q, want %q", got, want)
+	}
+}
+
+func TestSwitchCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	featurePath := filepath.Join(tmpDir, "feature-x")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feature-x", featurePath)
+	runGitCommand(t, repoDir, "branch", "no-worktree")
+	t.Chdir(repoDir)
+	t.Setenv("WT_STATE_DIR", t.TempDir())
+
+	output, err := runCapturing(t, switchCmd, "feature-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "TREE_ME_CD:"+featurePath) {
+		t.Errorf("wt switch feature-x printed %q, want a cd marker for %s", output, featurePath)
+	}
+
+	worktrees, _ := listWorktrees("")
+	output, err = runCapturing(t, switchCmd, "no-worktree")
+	if err == nil {
+		t.Error("wt switch of a branch without a worktree should fail")
+	}
+	if strings.Contains(output, "TREE_ME_CD:") {
+		t.Errorf("wt switch of a branch without a worktree printed a cd marker: %q", output)
+	}
+	if after, _ := listWorktrees(""); len(after) != len(worktrees) {
+		t.Errorf("wt switch created a worktree: %d worktrees, want %d", len(after), len(worktrees))
+	}
+}
+
+// setSwitchFlags sets flags of switchCmd for one test.
+func setSwitchFlags(t *testing.T, flags map[string]string) {
+	t.Helper()
+	for name, value := range flags {
+		f := switchCmd.Flags().Lookup(name)
+		if err := switchCmd.Flags().Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+	}
+}
+
+func TestSwitchCreate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	runGitCommand(t, repoDir, "commit", "-q", "--allow-empty", "-m", "after feature-x")
+	mainHead := gitOutput(t, repoDir, "rev-parse", "main")
+	featureHead := gitOutput(t, repoDir, "rev-parse", "feature-x")
+
+	t.Run("create", func(t *testing.T) {
+		setSwitchFlags(t, map[string]string{"create": "feature-y"})
+		output, err := runCapturing(t, switchCmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(worktreeRoot, "repo", "feature-y")
+		if !strings.Contains(output, "TREE_ME_CD:"+path) {
+			t.Errorf("wt switch -c feature-y printed %q, want a cd marker for %s", output, path)
+		}
+		if got := gitOutput(t, repoDir, "rev-parse", "feature-y"); got != mainHead {
+			t.Errorf("feature-y = %s, want it to start at main (%s)", got, mainHead)
+		}
+	})
+
+	t.Run("create with base", func(t *testing.T) {
+		setSwitchFlags(t, map[string]string{"create": "from-x", "base": "feature-x"})
+		if _, err := runCapturing(t, switchCmd); err != nil {
+			t.Fatal(err)
+		}
+		if got := gitOutput(t, repoDir, "rev-parse", "from-x"); got != featureHead {
+			t.Errorf("from-x = %s, want it to start at feature-x (%s)", got, featureHead)
+		}
+	})
+
+	t.Run("branch argument", func(t *testing.T) {
+		setSwitchFlags(t, map[string]string{"create": "other"})
+		if _, err := runCapturing(t, switchCmd, "feature-x"); err == nil {
+			t.Error("wt switch -c other feature-x should fail")
+		}
+	})
+
+	t.Run("force-create refused", func(t *testing.T) {
+		stubConfirm(t, false, &Config{})
+		setSwitchFlags(t, map[string]string{"force-create": "feature-x"})
+		output, err := runCapturing(t, switchCmd)
+		if err == nil || !strings.Contains(err.Error(), "pass --yes") {
+			t.Fatalf("wt switch -C feature-x without a terminal = %v, want a refusal", err)
+		}
+		if strings.Contains(output, "TREE_ME_CD:") {
+			t.Errorf("refused wt switch -C printed a cd marker: %q", output)
+		}
+		if got := gitOutput(t, repoDir, "rev-parse", "feature-x"); got != featureHead {
+			t.Errorf("refused wt switch -C moved feature-x to %s", got)
+		}
+		if _, exists := worktreeExists("feature-x"); exists {
+			t.Error("refused wt switch -C created a worktree")
+		}
+	})
+
+	t.Run("force-create confirmed", func(t *testing.T) {
+		stubConfirm(t, false, &Config{})
+		setSwitchFlags(t, map[string]string{"force-create": "feature-x", "yes": "true"})
+		if _, err := runCapturing(t, switchCmd); err != nil {
+			t.Fatal(err)
+		}
+		if got := gitOutput(t, repoDir, "rev-parse", "feature-x"); got != mainHead {
+			t.Errorf("feature-x = %s, want it reset to main (%s)", got, mainHead)
+		}
+		if _, exists := worktreeExists("feature-x"); !exists {
+			t.Error("wt switch -C --yes did not create the worktree")
+		}
+		if isBranchOwned(repoDir, "feature-x", "") {
+			t.Error("wt switch -C marked the existing feature-x as created by wt")
+		}
+	})
+
+	t.Run("force-create new branch", func(t *testing.T) {
+		setSwitchFlags(t, map[string]string{"force-create": "fresh"})
+		if _, err := runCapturing(t, switchCmd); err != nil {
+			t.Fatal(err)
+		}
+		if !isBranchOwned(repoDir, "fresh", "") {
+			t.Error("wt switch -C did not mark the branch it created as created by wt")
+		}
+	})
+}
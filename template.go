@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// WorktreeTemplate is a named bundle of settings for new worktrees, chosen
+// with --template. What it sets is merged over the rest of the config, and
+// explicit flags win over both.
+type WorktreeTemplate struct {
+	// Base is the branch `wt create` starts new branches from, as --base.
+	Base string `yaml:"base"`
+	// Direnv replaces the direnv setup of the config; an empty one turns it
+	// off.
+	Direnv *DirenvConfig `yaml:"direnv"`
+	// PRTitle replaces the prTitle of the config for --publish.
+	PRTitle string `yaml:"prTitle"`
+	// Env adds to and overrides the env of the config.
+	Env map[string]string `yaml:"env"`
+}
+
+// templateNames returns the names of the configured templates, sorted.
+func templateNames(cfg *Config) []string {
+	names := make([]string, 0, len(cfg.Templates))
+	for name := range cfg.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupTemplate returns the template called name, or an error suggesting
+// the closest configured one.
+func lookupTemplate(cfg *Config, name string) (WorktreeTemplate, error) {
+	if tmpl, ok := cfg.Templates[name]; ok {
+		return tmpl, nil
+	}
+	names := templateNames(cfg)
+	if len(names) == 0 {
+		return WorktreeTemplate{}, fmt.Errorf("unknown template %q: no templates are configured in %s", name, configFile())
+	}
+	msg := fmt.Sprintf("unknown template %q", name)
+	if guess := closestKey(name, names); guess != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", guess)
+	}
+	return WorktreeTemplate{}, fmt.Errorf("%s; configured templates: %s", msg, strings.Join(names, ", "))
+}
+
+// applyTemplate merges the template chosen with --template over the config
+// of this run and returns it. Without --template it returns the zero
+// template, which changes nothing.
+func applyTemplate(cmd *cobra.Command) (WorktreeTemplate, error) {
+	name, _ := cmd.Flags().GetString("template")
+	if name == "" {
+		return WorktreeTemplate{}, nil
+	}
+	cfg := *getConfig()
+	tmpl, err := lookupTemplate(&cfg, name)
+	if err != nil {
+		return tmpl, err
+	}
+	if tmpl.Direnv != nil {
+		cfg.Direnv = *tmpl.Direnv
+	}
+	if tmpl.PRTitle != "" {
+		cfg.PRTitle = tmpl.PRTitle
+	}
+	if len(tmpl.Env) > 0 {
+		env := make(map[string]string, len(cfg.Env)+len(tmpl.Env))
+		for name, value := range cfg.Env {
+			env[name] = value
+		}
+		for name, value := range tmpl.Env {
+			env[name] = value
+		}
+		cfg.Env = env
+	}
+	loadedConfig = &cfg
+	return tmpl, nil
+}
+
+// completeTemplates offers the names of the configured templates.
+func completeTemplates(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return templateNames(getConfig()), cobra.ShellCompDirectiveNoFileComp
+}
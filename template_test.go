@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestLookupTemplate(t *testing.T) {
+	cfg := &Config{Templates: map[string]WorktreeTemplate{
+		"frontend": {Base: "develop"},
+		"docs":     {},
+	}}
+	if tmpl, err := lookupTemplate(cfg, "frontend"); err != nil || tmpl.Base != "develop" {
+		t.Errorf("lookupTemplate(frontend) = %+v, %v", tmpl, err)
+	}
+	_, err := lookupTemplate(cfg, "frontnd")
+	if err == nil || !strings.Contains(err.Error(), `did you mean "frontend"?`) || !strings.Contains(err.Error(), "docs, frontend") {
+		t.Errorf("lookupTemplate(frontnd) = %v, want a suggestion and the configured templates", err)
+	}
+	if _, err := lookupTemplate(&Config{}, "docs"); err == nil || !strings.Contains(err.Error(), "no templates are configured") {
+		t.Errorf("lookupTemplate() without templates = %v", err)
+	}
+}
+
+func TestApplyTemplate(t *testing.T) {
+	original := loadedConfig
+	t.Cleanup(func() { loadedConfig = original })
+	base := &Config{
+		AskBase: true,
+		Direnv:  DirenvConfig{Template: ".envrc.wt", Allow: true},
+		Templates: map[string]WorktreeTemplate{
+			"frontend": {Base: "develop"},
+			"docs":     {Direnv: &DirenvConfig{}},
+		},
+	}
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{Use: "create"}
+		cmd.Flags().String("template", "", "")
+		if err := cmd.Flags().Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+
+	loadedConfig = base
+	tmpl, err := applyTemplate(newCmd("--template", "frontend"))
+	if err != nil || tmpl.Base != "develop" {
+		t.Fatalf("applyTemplate(frontend) = %+v, %v", tmpl, err)
+	}
+	// What the template leaves out comes from the config.
+	if getConfig().Direnv != base.Direnv || !getConfig().AskBase {
+		t.Errorf("config after applying frontend = %+v, want the config's direnv and askBase", getConfig())
+	}
+
+	loadedConfig = base
+	if _, err := applyTemplate(newCmd("--template", "docs")); err != nil {
+		t.Fatal(err)
+	}
+	if getConfig().Direnv != (DirenvConfig{}) {
+		t.Errorf("direnv after applying docs = %+v, want it off", getConfig().Direnv)
+	}
+	if base.Direnv.Template == "" {
+		t.Error("applyTemplate() changed the loaded config in place")
+	}
+
+	loadedConfig = base
+	if tmpl, err := applyTemplate(newCmd()); err != nil || !reflect.DeepEqual(tmpl, WorktreeTemplate{}) || getConfig() != base {
+		t.Errorf("applyTemplate() without --template = %+v, %v; want no change", tmpl, err)
+	}
+}
+
+func TestCreateWithTemplate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	runGitCommand(t, repoDir, "branch", "develop")
+	runGitCommand(t, repoDir, "commit", "-q", "--allow-empty", "-m", "after develop")
+	original := loadedConfig
+	t.Cleanup(func() { loadedConfig = original })
+	loadedConfig = &Config{Templates: map[string]WorktreeTemplate{"frontend": {Base: "develop"}}}
+
+	if err := createCmd.Flags().Set("template", "frontend"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = createCmd.Flags().Set("template", "") })
+	if err := createCmd.RunE(createCmd, []string{"login-fix"}); err != nil {
+		t.Fatalf("create --template frontend: %v", err)
+	}
+	if got, want := gitOutput(t, repoDir, "rev-parse", "login-fix"), gitOutput(t, repoDir, "rev-parse", "develop"); got != want {
+		t.Errorf("login-fix starts at %s, want the template's base develop (%s)", got, want)
+	}
+
+	// An explicit --base wins over the template.
+	if err := createCmd.Flags().Set("base", "main"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = createCmd.Flags().Set("base", "") })
+	if err := createCmd.RunE(createCmd, []string{"from-main"}); err != nil {
+		t.Fatalf("create --template frontend --base main: %v", err)
+	}
+	if got, want := gitOutput(t, repoDir, "rev-parse", "from-main"), gitOutput(t, repoDir, "rev-parse", "main"); got != want {
+		t.Errorf("from-main starts at %s, want --base main (%s)", got, want)
+	}
+
+	if err := createCmd.Flags().Set("template", "frontnd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := createCmd.RunE(createCmd, []string{"other"}); err == nil || !strings.Contains(err.Error(), `did you mean "frontend"?`) {
+		t.Errorf("create --template frontnd = %v, want a suggestion", err)
+	}
+}
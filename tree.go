@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// treeNode is a repository, a branch prefix group (e.g. "feature/") or a
+// worktree in the output of `wt list --tree`.
+type treeNode struct {
+	Name     string
+	Info     *worktreeInfo // nil for the repository and for groups
+	Children []*treeNode
+}
+
+// buildWorktreeTree groups the worktrees of repo by the segments of their
+// branch names. The main worktree is pinned first; everything else is sorted
+// by name.
+func buildWorktreeTree(repo string, infos []worktreeInfo) *treeNode {
+	root := &treeNode{Name: repo}
+	var main *treeNode
+	for i := range infos {
+		info := &infos[i]
+		if info.Main {
+			main = &treeNode{Name: treeLeafName(info), Info: info}
+			continue
+		}
+		node := root
+		segments := []string{treeLeafName(info)}
+		if info.Branch != "" {
+			segments = strings.Split(info.Branch, "/")
+		}
+		for _, group := range segments[:len(segments)-1] {
+			node = node.child(group + "/")
+		}
+		node.Children = append(node.Children, &treeNode{Name: segments[len(segments)-1], Info: info})
+	}
+	root.sort()
+	if main != nil {
+		root.Children = append([]*treeNode{main}, root.Children...)
+	}
+	return root
+}
+
+// treeLeafName names a worktree after its branch, or its directory when it
+// has no branch.
+func treeLeafName(info *worktreeInfo) string {
+	switch {
+	case info.Branch != "":
+		return info.Branch
+	case info.Bare:
+		return "(bare)"
+	default:
+		return filepath.Base(info.Path) + " (detached HEAD)"
+	}
+}
+
+// child returns the group named name, creating it if needed.
+func (n *treeNode) child(name string) *treeNode {
+	for _, c := range n.Children {
+		if c.Info == nil && c.Name == name {
+			return c
+		}
+	}
+	c := &treeNode{Name: name}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+func (n *treeNode) sort() {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// treeGlyphs renders the status of a worktree: ✓ when clean, ● and the file
+// count when dirty, followed by its flags.
+func treeGlyphs(info *worktreeInfo, color bool) string {
+	var parts []string
+	if info.Main {
+		parts = append(parts, colorize(color, ansiDim, "(main worktree)"))
+	}
+	switch {
+	case info.DirtyFiles != nil && *info.DirtyFiles > 0:
+		parts = append(parts, colorize(color, ansiYellow, fmt.Sprintf("● %d", *info.DirtyFiles)))
+	case info.Dirty != nil:
+		parts = append(parts, colorize(color, ansiGreen, "✓"))
+	}
+	if info.Locked {
+		parts = append(parts, colorize(color, ansiDim, "locked"))
+	}
+	if info.Offline {
+		parts = append(parts, colorize(color, ansiDim, "offline"))
+	} else if info.Prunable {
+		parts = append(parts, colorize(color, ansiRed, "prunable"))
+	}
+	if info.Pinned {
+		parts = append(parts, "📌")
+	}
+	return strings.Join(parts, " ")
+}
+
+// printWorktreeTree draws root with box-drawing characters.
+func printWorktreeTree(w io.Writer, root *treeNode, color bool) {
+	fmt.Fprintln(w, colorize(color, ansiBold, root.Name))
+	printTreeChildren(w, root, "", color)
+}
+
+func printTreeChildren(w io.Writer, n *treeNode, indent string, color bool) {
+	for i, c := range n.Children {
+		branch, next := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, next = "└── ", "    "
+		}
+		line := indent + branch + c.Name
+		if c.Info == nil {
+			line = indent + branch + colorize(color, ansiBold, c.Name)
+		} else if glyphs := treeGlyphs(c.Info, color); glyphs != "" {
+			line += " " + glyphs
+		}
+		fmt.Fprintln(w, line)
+		printTreeChildren(w, c, indent+next, color)
+	}
+}
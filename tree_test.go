@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func treeTestInfos() []worktreeInfo {
+	clean, dirty := false, true
+	zero, two := 0, 2
+	return []worktreeInfo{
+		{Path: "/src/api", Branch: "main", Main: true, Dirty: &clean, DirtyFiles: &zero},
+		{Path: "/trees/api/zeta", Branch: "zeta", Dirty: &clean, DirtyFiles: &zero},
+		{Path: "/trees/api/feature/login", Branch: "feature/login", Dirty: &dirty, DirtyFiles: &two},
+		{Path: "/trees/api/bugfix/typo", Branch: "bugfix/typo", Locked: true},
+		{Path: "/trees/api/feature/auth/oauth", Branch: "feature/auth/oauth", Dirty: &clean, DirtyFiles: &zero},
+		{Path: "/trees/api/review", Detached: true, Prunable: true},
+	}
+}
+
+func TestBuildWorktreeTree(t *testing.T) {
+	root := buildWorktreeTree("api", treeTestInfos())
+	var names []string
+	for _, c := range root.Children {
+		names = append(names, c.Name)
+	}
+	want := "main bugfix/ feature/ review (detached HEAD) zeta"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("top-level nodes = %q, want %q", got, want)
+	}
+}
+
+func TestPrintWorktreeTree(t *testing.T) {
+	var buf bytes.Buffer
+	printWorktreeTree(&buf, buildWorktreeTree("api", treeTestInfos()), false)
+	checkGolden(t, "list_tree.golden", buf.String())
+}
+
+func TestPrintWorktreeTreeColor(t *testing.T) {
+	var plain, colored bytes.Buffer
+	printWorktreeTree(&plain, buildWorktreeTree("api", treeTestInfos()), false)
+	printWorktreeTree(&colored, buildWorktreeTree("api", treeTestInfos()), true)
+	if strings.Contains(plain.String(), "\033[") {
+		t.Error("uncolored tree contains escape sequences")
+	}
+	if !strings.Contains(colored.String(), ansiYellow+"● 2"+ansiReset) {
+		t.Errorf("colored tree does not highlight dirty worktrees:\n%q", colored.String())
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	if colorEnabled(&bytes.Buffer{}) {
+		t.Error("colorEnabled() should be false for non-terminals")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled(nil) {
+		t.Error("colorEnabled() should honor NO_COLOR")
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Before wt removes a worktree it records what `wt undo` needs to bring it
+// back in undo.json in the state directory: the branch and its commit, the
+// uncommitted changes to tracked files (a commit made with `git stash
+// create`), the untracked files (a commit of a scratch index) and the git
+// config sections the removal drops. The commits are kept in
+// refs/wt/undo/<id>/ so that gc leaves them alone while the entry exists.
+
+// maxUndoEntries caps the number of operations kept in the undo log.
+const maxUndoEntries = 10
+
+// undoEntry is one destructive operation that `wt undo` can revert.
+type undoEntry struct {
+	ID string `json:"id"`
+	// Op is what was done; only "remove" is recorded so far.
+	Op string `json:"op"`
+	// Repo is the main worktree of the repository.
+	Repo     string `json:"repo"`
+	Branch   string `json:"branch"`
+	Head     string `json:"head"`
+	Upstream string `json:"upstream,omitempty"`
+	Path     string `json:"path"`
+	// Changes is the `git stash create` commit of the changes to tracked
+	// files, if there were any.
+	Changes string `json:"changes,omitempty"`
+	// Untracked is a commit of the worktree with its untracked files, and
+	// UntrackedFiles are the files to restore from it.
+	Untracked      string          `json:"untracked,omitempty"`
+	UntrackedFiles []string        `json:"untrackedFiles,omitempty"`
+	Config         []configSection `json:"config,omitempty"`
+	// Backup is where the ignored files matching preRemoveBackup were
+	// copied; wt undo does not restore them.
+	Backup     string    `json:"backup,omitempty"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// configSection is a git config section as it was before the operation.
+type configSection struct {
+	Name    string        `json:"name"`
+	Entries []configEntry `json:"entries"`
+}
+
+type configEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// undoIDLayout names entries after the time they were recorded.
+const undoIDLayout = "20060102-150405.000000000"
+
+func undoFile() string {
+	return filepath.Join(stateDir(), "undo.json")
+}
+
+// undoRef is the ref keeping the commit name of entry id reachable.
+func undoRef(id, name string) string {
+	return "refs/wt/undo/" + id + "/" + name
+}
+
+func loadUndoLog() ([]undoEntry, error) {
+	data, err := os.ReadFile(undoFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []undoEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", undoFile(), err)
+	}
+	return entries, nil
+}
+
+// saveUndoLog writes entries, newest first, dropping the refs of the ones
+// beyond maxUndoEntries.
+func saveUndoLog(entries []undoEntry) error {
+	if len(entries) > maxUndoEntries {
+		for _, e := range entries[maxUndoEntries:] {
+			dropUndoRefs(e)
+		}
+		entries = entries[:maxUndoEntries]
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir(), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(undoFile(), append(data, '\n'), 0o644)
+}
+
+// pushUndoEntry adds e to the front of the undo log.
+func pushUndoEntry(e undoEntry) error {
+	entries, err := loadUndoLog()
+	if err != nil {
+		return err
+	}
+	return saveUndoLog(append([]undoEntry{e}, entries...))
+}
+
+// latestUndoEntry returns the index of the newest entry of the repository
+// with main worktree repo, or -1.
+func latestUndoEntry(entries []undoEntry, repo string) int {
+	for i, e := range entries {
+		if sameDir(e.Repo, repo) {
+			return i
+		}
+	}
+	return -1
+}
+
+// dropUndoRefs deletes the refs keeping the commits of e.
+func dropUndoRefs(e undoEntry) {
+	for _, name := range []string{"head", "changes", "untracked"} {
+		_ = gitIn(e.Repo, "update-ref", "-d", undoRef(e.ID, name)).Run()
+	}
+}
+
+// parseConfigEntries parses `git config -z --get-regexp` output.
+func parseConfigEntries(output string) []configEntry {
+	var entries []configEntry
+	for _, entry := range strings.Split(output, "\x00") {
+		key, value, ok := strings.Cut(entry, "\n")
+		if !ok {
+			continue
+		}
+		entries = append(entries, configEntry{Key: key, Value: value})
+	}
+	return entries
+}
+
+// readConfigSection returns the entries of the config section name, e.g.
+// branch.feature-x, of the repository containing dir.
+func readConfigSection(dir, name string) []configEntry {
+	output, err := gitIn(dir, "config", "-z", "--get-regexp", `^`+regexp.QuoteMeta(name)+`\.`).Output()
+	if err != nil {
+		return nil
+	}
+	return parseConfigEntries(string(output))
+}
+
+// saveUntracked commits the worktree at path with its untracked files
+// through a scratch index, leaving the real index alone, and returns the
+// commit and the untracked files.
+func saveUntracked(path, branch string) (string, []string, error) {
+	output, err := gitIn(path, "ls-files", "-z", "--others", "--exclude-standard").Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+	var files []string
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return "", nil, nil
+	}
+
+	index, err := os.CreateTemp("", "wt-undo-index-")
+	if err != nil {
+		return "", nil, err
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	git := func(args ...string) (string, error) {
+		c := gitIn(path, args...)
+		c.Env = append(os.Environ(), "GIT_INDEX_FILE="+index.Name())
+		output, err := c.CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %s", args[0], firstLine(string(output)))
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+	if _, err := git("read-tree", "HEAD"); err != nil {
+		return "", nil, err
+	}
+	if _, err := git("add", "-A"); err != nil {
+		return "", nil, err
+	}
+	tree, err := git("write-tree")
+	if err != nil {
+		return "", nil, err
+	}
+	commit, err := git("commit-tree", tree, "-p", "HEAD", "-m", "wt undo: untracked files of "+branch)
+	if err != nil {
+		return "", nil, err
+	}
+	return commit, files, nil
+}
+
+// recordRemoval captures what `wt undo` needs to restore the worktree of
+// plan. Its commits are kept by refs, which dropUndoRefs deletes when the
+// removal does not happen.
+func recordRemoval(plan removalPlan, now time.Time) (undoEntry, error) {
+	e := undoEntry{
+		ID:         now.UTC().Format(undoIDLayout),
+		Op:         "remove",
+		Repo:       plan.mainPath,
+		Branch:     plan.Branch,
+		Path:       filepath.Clean(plan.Path),
+		RecordedAt: now.UTC(),
+	}
+	output, err := gitIn(plan.Path, "rev-parse", "--verify", "HEAD").Output()
+	if err != nil {
+		return e, fmt.Errorf("failed to resolve HEAD of %s: %w", plan.Path, err)
+	}
+	e.Head = strings.TrimSpace(string(output))
+	if output, err := gitIn(plan.Path, "rev-parse", "--abbrev-ref", plan.Branch+"@{upstream}").Output(); err == nil {
+		e.Upstream = strings.TrimSpace(string(output))
+	}
+	if plan.ModifiedFiles > 0 {
+		output, err := gitIn(plan.Path, "stash", "create", "wt undo: changes of "+plan.Branch).Output()
+		if err != nil {
+			return e, fmt.Errorf("failed to save the changes in %s: %w", plan.Path, err)
+		}
+		e.Changes = strings.TrimSpace(string(output))
+	}
+	if plan.UntrackedFiles > 0 {
+		if e.Untracked, e.UntrackedFiles, err = saveUntracked(plan.Path, plan.Branch); err != nil {
+			return e, fmt.Errorf("failed to save the untracked files in %s: %w", plan.Path, err)
+		}
+	}
+	for name, commit := range map[string]string{"head": e.Head, "changes": e.Changes, "untracked": e.Untracked} {
+		if commit == "" {
+			continue
+		}
+		if output, err := gitIn(e.Repo, "update-ref", undoRef(e.ID, name), commit).CombinedOutput(); err != nil {
+			dropUndoRefs(e)
+			return e, fmt.Errorf("failed to keep %s: %s", commit, firstLine(string(output)))
+		}
+	}
+
+	sections := []string{"branch." + plan.Branch, worktreeMetaSection + "." + e.Path}
+	if plan.ReviewCleanup != nil {
+		sections = append(sections, "wt-review."+plan.ReviewCleanup.Review)
+	}
+	for _, name := range sections {
+		if entries := readConfigSection(e.Repo, name); len(entries) > 0 {
+			e.Config = append(e.Config, configSection{Name: name, Entries: entries})
+		}
+	}
+	if plan.Backup != nil {
+		e.Backup = plan.Backup.Dir
+	}
+	return e, nil
+}
+
+// describe is the one-line summary of e for --list and wt undo.
+func (e undoEntry) describe() string {
+	return fmt.Sprintf("%s %s (%s) at %s, %s", e.Op, e.Branch, displayPath(e.Path), shortSHA(e.Head), e.RecordedAt.Local().Format("2006-01-02 15:04:05"))
+}
+
+// undoRemoval brings back the worktree of e. Restoring the branch and the
+// worktree must succeed; the changes and config are restored step by step,
+// and the steps say what could not be.
+func undoRemoval(e undoEntry) ([]cleanupStep, error) {
+	var steps []cleanupStep
+	if _, err := os.Lstat(e.Path); err == nil {
+		return nil, fmt.Errorf("%s exists; move it away to restore the worktree of %s there", displayPath(e.Path), e.Branch)
+	}
+
+	branchRef := "refs/heads/" + e.Branch
+	if output, err := gitIn(e.Repo, "rev-parse", "--verify", "--quiet", branchRef).Output(); err != nil {
+		if output, err := gitIn(e.Repo, "branch", "--no-track", e.Branch, e.Head).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("failed to recreate branch %s at %s: %s", e.Branch, shortSHA(e.Head), firstLine(string(output)))
+		}
+		steps = append(steps, cleanupStep{Done: fmt.Sprintf("Recreated branch %s at %s", e.Branch, shortSHA(e.Head))})
+	} else if head := strings.TrimSpace(string(output)); head != e.Head {
+		steps = append(steps, cleanupStep{
+			Action: "reset branch " + e.Branch + " to " + shortSHA(e.Head),
+			Err:    fmt.Errorf("it has moved to %s since; kept it there", shortSHA(head)),
+		})
+	}
+
+	if output, err := gitIn(e.Repo, "worktree", "add", e.Path, e.Branch).CombinedOutput(); err != nil {
+		return steps, fmt.Errorf("failed to add the worktree of %s at %s: %s", e.Branch, displayPath(e.Path), firstLine(string(output)))
+	}
+	steps = append(steps, cleanupStep{Done: "Added worktree " + displayPath(e.Path)})
+
+	if e.Changes != "" {
+		step := cleanupStep{Action: "restore the uncommitted changes", Done: "Restored the uncommitted changes"}
+		if output, err := gitIn(e.Path, "stash", "apply", "--index", e.Changes).CombinedOutput(); err != nil {
+			step.Err = fmt.Errorf("%s; they are kept in %s", firstLine(string(output)), undoRef(e.ID, "changes"))
+		}
+		steps = append(steps, step)
+	}
+	if e.Untracked != "" {
+		step := cleanupStep{
+			Action: "restore the untracked files",
+			Done:   fmt.Sprintf("Restored %d untracked file(s)", len(e.UntrackedFiles)),
+		}
+		c := gitIn(e.Path, "restore", "--source="+e.Untracked, "--worktree", "--pathspec-from-file=-", "--pathspec-file-nul")
+		c.Stdin = strings.NewReader(strings.Join(e.UntrackedFiles, "\x00"))
+		if output, err := c.CombinedOutput(); err != nil {
+			step.Err = fmt.Errorf("%s; they are kept in %s", firstLine(string(output)), undoRef(e.ID, "untracked"))
+		}
+		steps = append(steps, step)
+	}
+
+	for _, section := range e.Config {
+		if len(readConfigSection(e.Repo, section.Name)) > 0 {
+			continue
+		}
+		step := cleanupStep{Action: "restore config " + section.Name, Done: "Restored config " + section.Name}
+		for _, entry := range section.Entries {
+			if output, err := gitIn(e.Repo, "config", "--add", entry.Key, entry.Value).CombinedOutput(); err != nil {
+				step.Err = fmt.Errorf("%s: %s", entry.Key, firstLine(string(output)))
+				break
+			}
+		}
+		steps = append(steps, step)
+	}
+	if e.Upstream != "" {
+		step := cleanupStep{Action: "restore upstream " + e.Upstream, Done: "Tracking " + e.Upstream}
+		if output, err := gitIn(e.Path, "rev-parse", "--abbrev-ref", e.Branch+"@{upstream}").Output(); err != nil || strings.TrimSpace(string(output)) != e.Upstream {
+			if output, err := gitIn(e.Path, "branch", "--set-upstream-to="+e.Upstream, e.Branch).CombinedOutput(); err != nil {
+				step.Err = fmt.Errorf("%s", firstLine(string(output)))
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Undo the last removal of a worktree",
+	Long: `Bring back the worktree removed last in this repository, e.g. after an
+accidental 'wt rm feature-x --delete-branch --force'.
+
+Before removing a worktree, wt records its branch and commit, its upstream,
+its uncommitted changes and untracked files, and its metadata, keeping the
+last 10 removals across repositories. 'wt undo' recreates the branch at
+the recorded commit if it was deleted, adds the worktree at its old path,
+restores the changes and untracked files, and restores the metadata and
+the review if they were dropped. It then lists anything it
+could not restore: a branch that moved on since is left where it is, and
+git-ignored files are not recorded (the preRemoveBackup copies are).
+
+Run it again to undo the removal before that. --list shows what is recorded.
+
+Examples:
+  wt undo           # Restore the worktree removed last
+  wt undo --list    # Show the recorded removals of this repository`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := mainWorktreePath()
+		if err != nil {
+			return err
+		}
+		entries, err := loadUndoLog()
+		if err != nil {
+			return err
+		}
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			found := false
+			for _, e := range entries {
+				if sameDir(e.Repo, repo) {
+					fmt.Println(e.describe())
+					found = true
+				}
+			}
+			if !found {
+				infoln("Nothing to undo")
+			}
+			return nil
+		}
+
+		i := latestUndoEntry(entries, repo)
+		if i < 0 {
+			return fmt.Errorf("nothing to undo in this repository")
+		}
+		e := entries[i]
+		infof("Undoing %s\n", e.describe())
+		steps, err := undoRemoval(e)
+		printCleanupSummary(os.Stderr, steps)
+		if err != nil {
+			return err
+		}
+
+		// Refs named in a failed step keep what it could not restore.
+		failed := slices.ContainsFunc(steps, func(s cleanupStep) bool { return s.Err != nil })
+		if !failed {
+			dropUndoRefs(e)
+		}
+		if err := saveUndoLog(append(entries[:i:i], entries[i+1:]...)); err != nil {
+			return err
+		}
+		infof("✓ Restored %s: %s\n", e.Branch, displayPath(e.Path))
+		if e.Backup != "" {
+			infof("  Ignored files are not restored; copy them back from %s\n", displayPath(e.Backup))
+		}
+		if failed {
+			return exitWithCode(cmd, 1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	undoCmd.Flags().Bool("list", false, "List the recorded removals of this repository, newest first")
+}
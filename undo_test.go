@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfigEntries(t *testing.T) {
+	output := "branch.feature-x.remote\norigin\x00branch.feature-x.merge\nrefs/heads/feature-x\x00branch.feature-x.description\nline one\nline two\x00"
+	want := []configEntry{
+		{Key: "branch.feature-x.remote", Value: "origin"},
+		{Key: "branch.feature-x.merge", Value: "refs/heads/feature-x"},
+		{Key: "branch.feature-x.description", Value: "line one\nline two"},
+	}
+	if got := parseConfigEntries(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfigEntries() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUndoLog(t *testing.T) {
+	t.Setenv("WT_STATE_DIR", t.TempDir())
+	repo := t.TempDir()
+	for i := range maxUndoEntries + 2 {
+		if err := pushUndoEntry(undoEntry{ID: strings.Repeat("x", i+1), Repo: repo}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pushUndoEntry(undoEntry{ID: "other", Repo: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := loadUndoLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxUndoEntries || entries[0].ID != "other" || entries[1].ID != strings.Repeat("x", maxUndoEntries+2) {
+		t.Fatalf("undo log = %+v, want the newest %d entries first", entries, maxUndoEntries)
+	}
+	if i := latestUndoEntry(entries, repo); i != 1 {
+		t.Errorf("latestUndoEntry() = %d, want 1", i)
+	}
+	if i := latestUndoEntry(entries, t.TempDir()); i != -1 {
+		t.Errorf("latestUndoEntry(unknown repo) = %d, want -1", i)
+	}
+}
+
+func TestUndoRemoval(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	path := filepath.Join(worktreeRoot, "repo", "feature-x")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", path, "feature-x")
+	runGitCommand(t, repoDir, "config", "branch.feature-x.description", "the login page")
+	setWorktreeTime(path, "createdAt", time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	writeTree(t, path, map[string]int{"staged.txt": 1, "dir/untracked.txt": 2})
+	runGitCommand(t, path, "add", "staged.txt")
+	writeTree(t, path, map[string]int{"staged.txt": 3})
+	stubConfirm(t, false, &Config{})
+
+	cmd := newFlagTestCmd(t, removeFlags, "--force", "--delete-branch", "--include-unowned")
+	plan, err := planRemoval(cmd, "feature-x", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := carryOutRemoval(cmd, plan); err != nil {
+		t.Fatal(err)
+	}
+	if gitIn(repoDir, "rev-parse", "--verify", "--quiet", "refs/heads/feature-x").Run() == nil {
+		t.Fatal("carryOutRemoval() kept the branch")
+	}
+
+	entries, err := loadUndoLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := latestUndoEntry(entries, repoDir)
+	if i < 0 {
+		t.Fatalf("undo log = %+v, want the removal of feature-x", entries)
+	}
+	e := entries[i]
+	if e.Branch != "feature-x" || e.Changes == "" || !reflect.DeepEqual(e.UntrackedFiles, []string{"dir/untracked.txt"}) {
+		t.Errorf("undo entry = %+v, want the branch, its changes and untracked files", e)
+	}
+
+	steps, err := undoRemoval(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range steps {
+		if step.Err != nil {
+			t.Errorf("could not %s: %v", step.Action, step.Err)
+		}
+	}
+	if got := gitOutput(t, path, "status", "--porcelain"); got != "AM staged.txt\n?? dir/\n" {
+		t.Errorf("status after undo = %q, want the staged, modified and untracked files back", got)
+	}
+	if data, err := os.ReadFile(filepath.Join(path, "staged.txt")); err != nil || len(data) != 3 {
+		t.Errorf("staged.txt after undo = %q, %v; want the unstaged content", data, err)
+	}
+	if got := gitOutput(t, repoDir, "config", "branch.feature-x.description"); got != "the login page\n" {
+		t.Errorf("branch description after undo = %q", got)
+	}
+	if times := loadWorktreeTimes(repoDir)[filepath.Clean(path)]; times.CreatedAt.IsZero() {
+		t.Error("undo did not restore the worktree metadata")
+	}
+
+	// The worktree is back, so the entry cannot be replayed again.
+	if _, err := undoRemoval(e); err == nil || !strings.Contains(err.Error(), "exists") {
+		t.Errorf("second undoRemoval() = %v, want an error about the existing path", err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// `wt shellenv --verify` prints the shell integration followed by a self-test
+// that, when sourced, runs the wrapper on `wt __emit-test-marker` and checks
+// that the shell changed into the directory of the marker. It then changes
+// back and prints PASS or FAIL with what it found.
+
+// posixVerifyScript is the self-test for bash and zsh.
+const posixVerifyScript = `
+# wt shell integration self-test, from 'wt shellenv --verify'
+_wt_verify() {
+    local shell_name kind prev log_file marker now expected result=PASS
+    if [ -n "$ZSH_VERSION" ]; then
+        shell_name="zsh $ZSH_VERSION"
+    elif [ -n "$BASH_VERSION" ]; then
+        shell_name="bash $BASH_VERSION"
+    else
+        shell_name="unknown (only bash and zsh are supported)"
+    fi
+    case "$(type wt 2>/dev/null)" in
+        *function*) kind="a shell function wrapping the binary" ;;
+        "") kind="not found"; result=FAIL ;;
+        *) kind="the binary itself, not the wrapper function"; result=FAIL ;;
+    esac
+
+    prev=$PWD
+    log_file=$(mktemp -t wt-verify.XXXXXX)
+    wt __emit-test-marker > "$log_file" 2>&1
+    marker=$(grep '^TREE_ME_CD:' "$log_file" | tail -1 | cut -d: -f2-)
+    marker=${marker%$'\r'}
+    now=$(pwd -P)
+    cd -- "$prev" || true
+
+    expected=""
+    if [ -z "$marker" ]; then
+        result=FAIL
+    else
+        expected=$(cd -- "$marker" 2>/dev/null && pwd -P)
+        [ "$now" = "$expected" ] || result=FAIL
+        rmdir -- "$marker" 2>/dev/null
+    fi
+
+    echo "wt shell integration: $result"
+    echo "  shell:        $shell_name"
+    echo "  wt is:        $kind"
+    if [ -n "$marker" ]; then
+        echo "  marker:       captured ($marker)"
+    else
+        echo "  marker:       not captured; output of 'wt __emit-test-marker':"
+        sed 's/^/    /' "$log_file"
+    fi
+    if [ -n "$expected" ] && [ "$now" = "$expected" ]; then
+        echo "  changed dir:  yes (and back to $prev)"
+    else
+        echo "  changed dir:  no (stayed in $now)"
+    fi
+    rm -f "$log_file"
+    [ "$result" = PASS ]
+}
+_wt_verify
+`
+
+// powershellVerifyScript is the self-test for PowerShell.
+const powershellVerifyScript = `
+# wt shell integration self-test, from 'wt shellenv --verify'
+& {
+    $result = 'PASS'
+    $shellName = "PowerShell $($PSVersionTable.PSVersion)"
+    $command = Get-Command wt -ErrorAction SilentlyContinue | Select-Object -First 1
+    if (-not $command) {
+        $kind = 'not found'; $result = 'FAIL'
+    } elseif ($command.CommandType -eq 'Function') {
+        $kind = 'a function wrapping the binary'
+    } else {
+        $kind = 'the binary itself, not the wrapper function'; $result = 'FAIL'
+    }
+
+    $prev = Get-Location
+    $output = wt __emit-test-marker 2>&1
+    $marker = $output | Select-String -Pattern '^TREE_ME_CD:' | ForEach-Object { $_.Line.Substring(11) } | Select-Object -Last 1
+    $now = (Get-Location).ProviderPath
+    Set-Location -LiteralPath $prev
+
+    $changed = $false
+    if (-not $marker) {
+        $result = 'FAIL'
+    } else {
+        $changed = $now -eq (Resolve-Path -LiteralPath $marker).ProviderPath
+        if (-not $changed) { $result = 'FAIL' }
+        Remove-Item -LiteralPath $marker -ErrorAction SilentlyContinue
+    }
+
+    "wt shell integration: $result"
+    "  shell:        $shellName"
+    "  wt is:        $kind"
+    if ($marker) {
+        "  marker:       captured ($marker)"
+    } else {
+        "  marker:       not captured; output of 'wt __emit-test-marker':"
+        $output | ForEach-Object { "    $_" }
+    }
+    if ($changed) {
+        "  changed dir:  yes (and back to $prev)"
+    } else {
+        "  changed dir:  no (stayed in $now)"
+    }
+}
+`
+
+// verifyIntegration is the `wt shellenv --verify` output: the integration
+// followed by its self-test.
+func verifyIntegration(powershell bool, binary string) string {
+	if powershell {
+		return shellIntegration(true, binary) + powershellVerifyScript
+	}
+	return shellIntegration(false, binary) + posixVerifyScript
+}
+
+// emitTestMarker creates an empty temporary directory for the self-test to
+// change into and returns its marker line.
+func emitTestMarker() (string, error) {
+	dir, err := os.MkdirTemp("", "wt-verify-")
+	if err != nil {
+		return "", err
+	}
+	return "TREE_ME_CD:" + markerPath(dir, os.Getenv("WT_PATH_STYLE")), nil
+}
+
+var emitTestMarkerCmd = &cobra.Command{
+	Use:    "__emit-test-marker",
+	Short:  "Print a cd marker for a new temporary directory (for 'wt shellenv --verify')",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		line, err := emitTestMarker()
+		if err != nil {
+			return fmt.Errorf("failed to create a directory to change into: %w", err)
+		}
+		fmt.Println(line)
+		return nil
+	},
+}
+
+// shellenvVerifyHint is how doctor suggests checking the integration.
+func shellenvVerifyHint(powershell bool) string {
+	if powershell {
+		return "(& wt shellenv --verify) | Out-String | Invoke-Expression"
+	}
+	return "source <(wt shellenv --verify)"
+}
+
+func init() {
+	shellenvCmd.Flags().Bool("verify", false, "Print the integration followed by a self-test of the auto-cd round trip, to source")
+	rootCmd.AddCommand(emitTestMarkerCmd)
+}
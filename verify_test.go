@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestEmitTestMarker(t *testing.T) {
+	t.Setenv("WT_PATH_STYLE", "")
+	line, err := emitTestMarker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, ok := strings.CutPrefix(line, "TREE_ME_CD:")
+	if !ok {
+		t.Fatalf("emitTestMarker() = %q, want a TREE_ME_CD marker", line)
+	}
+	defer os.Remove(dir)
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("marker directory %s: %v, %v; want an empty directory", dir, entries, err)
+	}
+}
+
+func TestVerifyIntegration(t *testing.T) {
+	for _, powershell := range []bool{false, true} {
+		script := verifyIntegration(powershell, "wt")
+		if !strings.HasPrefix(script, shellIntegration(powershell, "wt")) {
+			t.Errorf("verifyIntegration(%v) does not define the wrapper first", powershell)
+		}
+		for _, want := range []string{"wt __emit-test-marker", "wt shell integration: $result"} {
+			if !strings.Contains(script, want) {
+				t.Errorf("verifyIntegration(%v) is missing %q", powershell, want)
+			}
+		}
+	}
+}
+
+func TestE2EShellenvVerify(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping e2e test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("the bash self-test is covered on unix")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+
+	tmpDir := t.TempDir()
+	binDir := filepath.Join(tmpDir, "bin")
+	if err := os.Mkdir(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	buildWtBinary(t, binDir)
+	start := filepath.Join(tmpDir, "start")
+	if err := os.Mkdir(start, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	bash := func(script string) (string, error) {
+		t.Helper()
+		cmd := exec.Command("bash", "-c", script)
+		cmd.Dir = start
+		cmd.Env = append(os.Environ(), "PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"), "WT_SHELL_INTEGRATION=")
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	out, err := bash(`source <(wt shellenv --verify) && echo "cwd=$PWD"`)
+	if err != nil {
+		t.Fatalf("self-test failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{"wt shell integration: PASS", "wt is:        a shell function", "marker:       captured", "changed dir:  yes", "cwd=" + start} {
+		if !strings.Contains(out, want) {
+			t.Errorf("self-test output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Without the wrapper, the binary runs and the shell stays put.
+	out, err = bash(posixVerifyScript)
+	if err == nil {
+		t.Errorf("self-test without the wrapper passed:\n%s", out)
+	}
+	for _, want := range []string{"wt shell integration: FAIL", "the binary itself", "changed dir:  no"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("self-test without the wrapper: output missing %q:\n%s", want, out)
+		}
+	}
+}
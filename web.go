@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// repoWebURL returns the https page of the repository behind a git remote
+// URL in scp-like (git@host:org/repo.git) or URL form.
+func repoWebURL(remoteURL string) (string, error) {
+	host := remoteHost(remoteURL)
+	var path string
+	if _, rest, ok := strings.Cut(remoteURL, "://"); ok {
+		_, path, _ = strings.Cut(rest, "/")
+	} else if _, rest, ok := strings.Cut(remoteURL, ":"); ok {
+		path = rest
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" {
+		return "", fmt.Errorf("cannot derive a web URL from remote %q", remoteURL)
+	}
+	return "https://" + host + "/" + path, nil
+}
+
+// guessRemoteType tells GitLab hosts from GitHub ones by name.
+func guessRemoteType(host string) RemoteType {
+	if strings.Contains(host, "gitlab") {
+		return RemoteGitLab
+	}
+	return RemoteGitHub
+}
+
+// escapeBranch escapes a branch name for use in a URL path, keeping slashes.
+func escapeBranch(branch string) string {
+	segments := strings.Split(branch, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// reviewWebURL returns the page of PR/MR number below the repository page.
+func reviewWebURL(repoURL string, remoteType RemoteType, number string) string {
+	if remoteType == RemoteGitLab {
+		return repoURL + "/-/merge_requests/" + number
+	}
+	return repoURL + "/pull/" + number
+}
+
+// branchWebURL returns the page comparing branch with the default branch, or
+// the tree of the default branch itself.
+func branchWebURL(repoURL string, remoteType RemoteType, defaultBranch, branch string) string {
+	prefix := repoURL
+	if remoteType == RemoteGitLab {
+		prefix += "/-"
+	}
+	if branch == "" || branch == defaultBranch {
+		return prefix + "/tree/" + escapeBranch(defaultBranch)
+	}
+	return prefix + "/compare/" + escapeBranch(defaultBranch) + "..." + escapeBranch(branch)
+}
+
+var reviewBranchRegex = regexp.MustCompile(`^(pr|mr)-([0-9]+)$`)
+
+// parseReview splits a review like "pr-512" into its forge and number.
+func parseReview(review string) (RemoteType, string, bool) {
+	matches := reviewBranchRegex.FindStringSubmatch(review)
+	if matches == nil {
+		return RemoteUnknown, "", false
+	}
+	if matches[1] == "mr" {
+		return RemoteGitLab, matches[2], true
+	}
+	return RemoteGitHub, matches[2], true
+}
+
+// queryReviewURL asks the forge for the URL of the open PR/MR of branch.
+func queryReviewURL(remoteType RemoteType, branch string) (string, error) {
+	if requireReviewCLI(remoteType) != nil {
+		return "", fmt.Errorf("%s not installed", forgeCLI(remoteType))
+	}
+	if remoteType == RemoteGitLab {
+		output, err := runForgeCLI(remoteType, "mr", "view", branch, "-F", "json")
+		if err != nil {
+			return "", err
+		}
+		var mr struct {
+			WebURL string `json:"web_url"`
+		}
+		if err := json.Unmarshal(output, &mr); err != nil || mr.WebURL == "" {
+			return "", fmt.Errorf("no MR URL in glab output")
+		}
+		return mr.WebURL, nil
+	}
+	output, err := runForgeCLI(remoteType, "pr", "view", branch, "--json", "url", "--jq", ".url")
+	if err != nil {
+		return "", err
+	}
+	if u := strings.TrimSpace(string(output)); u != "" {
+		return u, nil
+	}
+	return "", fmt.Errorf("no PR URL in gh output")
+}
+
+// originWebURL returns the repository page of the origin remote and the
+// remote URL itself.
+func originWebURL() (string, string, error) {
+	output, err := repoGit("remote", "get-url", "origin").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("no origin remote to open")
+	}
+	remoteURL := strings.TrimSpace(string(output))
+	repoURL, err := repoWebURL(remoteURL)
+	return repoURL, remoteURL, err
+}
+
+// currentWebURL resolves the page for the current worktree: its PR/MR when
+// one is known from the branch name, the review metadata or the forge, and
+// the compare page of the branch otherwise, explained by the returned note.
+// remoteType is RemoteUnknown to guess the forge from the origin host.
+func currentWebURL(remoteType RemoteType) (string, string, error) {
+	repoURL, remoteURL, err := originWebURL()
+	if err != nil {
+		return "", "", err
+	}
+	if remoteType == RemoteUnknown {
+		remoteType = guessRemoteType(remoteHost(remoteURL))
+	}
+
+	output, _ := gitCommand("symbolic-ref", "--short", "-q", "HEAD").Output()
+	branch := strings.TrimSpace(string(output))
+	if branch == "" {
+		return repoURL, "", nil
+	}
+
+	review := branch
+	if _, _, ok := parseReview(review); !ok {
+		review = lookupReview("", branch)
+	}
+	if reviewType, number, ok := parseReview(review); ok {
+		return reviewWebURL(repoURL, reviewType, number), "", nil
+	}
+	if u, err := queryReviewURL(remoteType, branch); err == nil {
+		return u, "", nil
+	}
+	defaultBranch := getDefaultBase()
+	if branch == defaultBranch {
+		return branchWebURL(repoURL, remoteType, defaultBranch, branch), "", nil
+	}
+	note := fmt.Sprintf("No open PR/MR for %s; opening the compare page", branch)
+	return branchWebURL(repoURL, remoteType, defaultBranch, branch), note, nil
+}
+
+// browserCommand returns the command opening url in the platform browser. No
+// shell is involved, so the URL is never interpreted.
+func browserCommand(goos, url string) *exec.Cmd {
+	switch goos {
+	case "darwin":
+		return exec.Command("open", url)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		return exec.Command("xdg-open", url)
+	}
+}
+
+// openWeb opens the page of the current worktree, or of PR/MR number when
+// given, in the browser. If no browser can be started the URL is printed.
+func openWeb(remoteType RemoteType, number string) error {
+	if err := requireRemote(); err != nil {
+		return err
+	}
+	var pageURL string
+	if number != "" {
+		repoURL, _, err := originWebURL()
+		if err != nil {
+			return err
+		}
+		pageURL = reviewWebURL(repoURL, remoteType, number)
+	} else {
+		u, note, err := currentWebURL(remoteType)
+		if err != nil {
+			return err
+		}
+		if note != "" {
+			infoln(note)
+		}
+		pageURL = u
+	}
+
+	fmt.Println(pageURL)
+	cmd := browserCommand(runtime.GOOS, pageURL)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not start a browser: %v\n", err)
+		return nil
+	}
+	_ = cmd.Process.Release()
+	return nil
+}
+
+var openCmd = &cobra.Command{
+	Use:   "open (--web | --reveal [path] | --editor [path])",
+	Short: "Open the PR/MR or branch page of the current worktree",
+	Long: `Open the forge page of the current worktree in the browser, show the
+worktree in the file manager, or open it in the editor.
+
+With --web the PR/MR of the current branch is opened: pr-<n>/mr-<n> branches
+and branches recorded by 'wt pr'/'wt mr' are resolved locally, other branches
+are looked up with gh/glab. When the branch has no PR/MR, its compare page is
+opened instead. The URL is always printed.
+
+With --reveal the current worktree, or the file or directory given, is shown
+in Finder (open -R), Explorer (explorer /select,) or the desktop's file
+manager (xdg-open). Set revealCommand in the config to use another command.
+
+With --editor the current worktree, or the file or directory given, is opened
+in $VISUAL or $EDITOR. The editor is started detached, so closing the terminal
+does not close it, except for editors that run in the terminal, such as vim.
+With --wait wt waits until the editor is closed and exits with its status, for
+scripts such as commit message helpers: editors that return at once (code,
+subl, zed, ...) get their wait flag, which editorWaitFlags in the config can
+set for others.
+
+Examples:
+  wt open --web                   # Open the PR/MR or compare page of this branch
+  wt open --reveal                # Show this worktree in the file manager
+  wt open --reveal src/main.go    # Select a file in the file manager
+  wt open --editor --wait NOTES   # Edit a file and wait until it is closed`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		web, _ := cmd.Flags().GetBool("web")
+		reveal, _ := cmd.Flags().GetBool("reveal")
+		editor, _ := cmd.Flags().GetBool("editor")
+		wait, _ := cmd.Flags().GetBool("wait")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		if wait && !editor {
+			return fmt.Errorf("--wait can only be used with --editor")
+		}
+		if editor {
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			}
+			target, err := resolveRevealTarget(path)
+			if err != nil {
+				return err
+			}
+			return openInEditor(cmd, target.Path, wait, verbose)
+		}
+		if reveal {
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return revealInFileManager(path, verbose)
+		}
+		if len(args) > 0 {
+			return fmt.Errorf("a path can only be given with --reveal or --editor")
+		}
+		if !web {
+			return fmt.Errorf("nothing to open; pass --web to open the forge page, --reveal to show the worktree or --editor to edit it")
+		}
+		return openWeb(RemoteUnknown, "")
+	},
+}
+
+func init() {
+	openCmd.Flags().Bool("web", false, "Open the PR/MR or compare page in the browser")
+	openCmd.Flags().Bool("reveal", false, "Show the worktree, or the given path, in the file manager")
+	openCmd.Flags().Bool("editor", false, "Open the worktree, or the given path, in $VISUAL or $EDITOR")
+	openCmd.Flags().Bool("wait", false, "With --editor: wait until the editor is closed and exit with its status")
+	openCmd.Flags().BoolP("verbose", "v", false, "Print the command that is launched")
+	openCmd.MarkFlagsMutuallyExclusive("web", "reveal", "editor")
+	prCmd.Flags().Bool("web", false, "Open the PR (default: of the current branch) in the browser instead of checking it out")
+	mrCmd.Flags().Bool("web", false, "Open the MR (default: of the current branch) in the browser instead of checking it out")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepoWebURL(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   string
+	}{
+		{"https://github.com/org/repo.git", "https://github.com/org/repo"},
+		{"https://github.com/org/repo", "https://github.com/org/repo"},
+		{"git@github.com:org/repo.git", "https://github.com/org/repo"},
+		{"ssh://git@gitlab.example.com:2222/group/sub/repo.git", "https://gitlab.example.com/group/sub/repo"},
+		{"https://user@gitlab.com/group/repo.git/", "https://gitlab.com/group/repo"},
+	}
+	for _, tt := range tests {
+		got, err := repoWebURL(tt.remote)
+		if err != nil || got != tt.want {
+			t.Errorf("repoWebURL(%q) = %q, %v; want %q", tt.remote, got, err, tt.want)
+		}
+	}
+	if _, err := repoWebURL("/srv/git/repo.git"); err == nil {
+		t.Error("repoWebURL() should reject local paths")
+	}
+}
+
+func TestReviewWebURL(t *testing.T) {
+	if got := reviewWebURL("https://github.com/org/repo", RemoteGitHub, "12"); got != "https://github.com/org/repo/pull/12" {
+		t.Errorf("GitHub review URL = %q", got)
+	}
+	if got := reviewWebURL("https://gitlab.com/group/repo", RemoteGitLab, "7"); got != "https://gitlab.com/group/repo/-/merge_requests/7" {
+		t.Errorf("GitLab review URL = %q", got)
+	}
+}
+
+func TestBranchWebURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteType RemoteType
+		branch     string
+		want       string
+	}{
+		{"GitHub compare", RemoteGitHub, "feature/login", "https://host/o/r/compare/main...feature/login"},
+		{"GitLab compare", RemoteGitLab, "feature/login", "https://host/o/r/-/compare/main...feature/login"},
+		{"GitHub default branch", RemoteGitHub, "main", "https://host/o/r/tree/main"},
+		{"GitLab default branch", RemoteGitLab, "main", "https://host/o/r/-/tree/main"},
+		{"escaped", RemoteGitHub, "fix/#12 café", "https://host/o/r/compare/main...fix/%2312%20caf%C3%A9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := branchWebURL("https://host/o/r", tt.remoteType, "main", tt.branch); got != tt.want {
+				t.Errorf("branchWebURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReview(t *testing.T) {
+	tests := []struct {
+		review     string
+		remoteType RemoteType
+		number     string
+		ok         bool
+	}{
+		{"pr-512", RemoteGitHub, "512", true},
+		{"mr-7", RemoteGitLab, "7", true},
+		{"pr-x", RemoteUnknown, "", false},
+		{"feature/pr-1", RemoteUnknown, "", false},
+	}
+	for _, tt := range tests {
+		remoteType, number, ok := parseReview(tt.review)
+		if remoteType != tt.remoteType || number != tt.number || ok != tt.ok {
+			t.Errorf("parseReview(%q) = %v, %q, %v", tt.review, remoteType, number, ok)
+		}
+	}
+}
+
+func TestBrowserCommand(t *testing.T) {
+	const u = "https://github.com/o/r/pull/1?a=1&b=2"
+	tests := map[string][]string{
+		"linux":   {"xdg-open", u},
+		"darwin":  {"open", u},
+		"windows": {"rundll32", "url.dll,FileProtocolHandler", u},
+	}
+	for goos, want := range tests {
+		if got := browserCommand(goos, u).Args; !reflect.DeepEqual(got, want) {
+			t.Errorf("browserCommand(%s) = %v, want %v", goos, got, want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// The env map of the config sets variables for the programs wt starts in a
+// worktree on the user's behalf (the editor, the file manager, the commands
+// of `wt bisect --run` and `wt exec`), e.g. a DATABASE_URL per branch. Each
+// value is a Go template rendered for the worktree. git commands wt runs for
+// itself never get them.
+
+var slugInvalidRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// branchSlug reduces branch to lowercase ASCII letters, digits and
+// underscores, for names such as databases: feature/Add-Login becomes
+// feature_add_login.
+func branchSlug(branch string) string {
+	return strings.Trim(slugInvalidRegex.ReplaceAllString(strings.ToLower(branch), "_"), "_")
+}
+
+// worktreeEnvVars are what env values can refer to: the variables of .envrc
+// templates and {{.WT_BRANCH_SLUG}}.
+func worktreeEnvVars(repo, branch, path string) map[string]string {
+	vars := direnvVars(repo, branch, path)
+	vars["WT_BRANCH_SLUG"] = branchSlug(branch)
+	return vars
+}
+
+// parseEnvTemplate parses the env value of name.
+func parseEnvTemplate(name, value string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env value of %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// renderWorktreeEnv renders the values of env with vars.
+func renderWorktreeEnv(env map[string]string, vars map[string]string) (map[string]string, error) {
+	rendered := make(map[string]string, len(env))
+	for name, value := range env {
+		tmpl, err := parseEnvTemplate(name, value)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, vars); err != nil {
+			return nil, fmt.Errorf("invalid env value of %s: %w", name, err)
+		}
+		rendered[name] = b.String()
+	}
+	return rendered, nil
+}
+
+// envNameEqual compares variable names as the OS does: case-insensitively
+// on Windows.
+func envNameEqual(goos, a, b string) bool {
+	if goos == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// mergeEnviron returns environ with the variables of extra set, replacing
+// variables of the same name: the config wins. It also returns the names
+// that were replaced, sorted.
+func mergeEnviron(goos string, environ []string, extra map[string]string) ([]string, []string) {
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := make([]string, 0, len(environ)+len(extra))
+	var overridden []string
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		replaced := false
+		for _, name := range names {
+			if envNameEqual(goos, key, name) {
+				replaced = true
+				overridden = append(overridden, name)
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, kv)
+		}
+	}
+	for _, name := range names {
+		merged = append(merged, name+"="+extra[name])
+	}
+	sort.Strings(overridden)
+	return merged, overridden
+}
+
+// worktreeEnviron returns the environment for a program started in the
+// worktree holding dir, or nil, meaning wt's own environment, when the config
+// sets no env. With verbose it notes variables of the environment that the
+// config overrides.
+func worktreeEnviron(dir string, verbose bool) ([]string, error) {
+	env := getConfig().Env
+	if len(env) == 0 {
+		return nil, nil
+	}
+	output, err := gitIn(dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s is not inside a worktree", dir)
+	}
+	path := filepath.Clean(strings.TrimSpace(string(output)))
+	output, _ = gitIn(path, "symbolic-ref", "--short", "-q", "HEAD").Output()
+	branch := strings.TrimSpace(string(output))
+	repo, err := getRepoName()
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := renderWorktreeEnv(env, worktreeEnvVars(repo, branch, path))
+	if err != nil {
+		return nil, err
+	}
+	merged, overridden := mergeEnviron(runtime.GOOS, os.Environ(), rendered)
+	if verbose {
+		for _, name := range overridden {
+			fmt.Fprintf(os.Stderr, "note: %s from the env config overrides the environment\n", name)
+		}
+	}
+	return merged, nil
+}
+
+// setWorktreeEnv gives c, a program started in the worktree holding dir, the
+// env of the config.
+func setWorktreeEnv(c *exec.Cmd, dir string, verbose bool) error {
+	environ, err := worktreeEnviron(dir, verbose)
+	if err != nil {
+		return err
+	}
+	if environ != nil {
+		c.Env = environ
+	}
+	return nil
+}
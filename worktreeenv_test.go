@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBranchSlug(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"main", "main"},
+		{"feature/Add-Login", "feature_add_login"},
+		{"pr-123", "pr_123"},
+		{"fix//v2.1--", "fix_v2_1"},
+		{"über", "ber"},
+	}
+	for _, tt := range tests {
+		if got := branchSlug(tt.branch); got != tt.want {
+			t.Errorf("branchSlug(%q) = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestRenderWorktreeEnv(t *testing.T) {
+	vars := worktreeEnvVars("app", "feature/login", "/w/app/feature/login")
+	got, err := renderWorktreeEnv(map[string]string{
+		"DATABASE_URL": "postgres://localhost/app_{{.WT_BRANCH_SLUG}}",
+		"STATIC":       "1",
+		"ROOT":         "{{.WT_REPO_DIR}}/tmp",
+	}, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DATABASE_URL": "postgres://localhost/app_feature_login",
+		"STATIC":       "1",
+		"ROOT":         "/w/app/feature/login/tmp",
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %q, want %q", name, got[name], value)
+		}
+	}
+
+	if _, err := renderWorktreeEnv(map[string]string{"X": "{{.WT_TICKET}}"}, vars); err == nil || !strings.Contains(err.Error(), "X") {
+		t.Errorf("renderWorktreeEnv() with an unknown variable = %v, want an error naming X", err)
+	}
+	if _, err := renderWorktreeEnv(map[string]string{"X": "{{.WT_BRANCH"}, vars); err == nil {
+		t.Error("renderWorktreeEnv() with a broken template succeeded")
+	}
+}
+
+func TestMergeEnviron(t *testing.T) {
+	environ := []string{"PATH=/bin", "DATABASE_URL=postgres://localhost/app", "Home=/h"}
+	extra := map[string]string{"DATABASE_URL": "postgres://localhost/app_x", "HOME": "/x", "NEW": "1"}
+
+	merged, overridden := mergeEnviron("linux", environ, extra)
+	want := []string{"PATH=/bin", "Home=/h", "DATABASE_URL=postgres://localhost/app_x", "HOME=/x", "NEW=1"}
+	if !slices.Equal(merged, want) {
+		t.Errorf("mergeEnviron(linux) = %q, want %q", merged, want)
+	}
+	if !slices.Equal(overridden, []string{"DATABASE_URL"}) {
+		t.Errorf("overridden on linux = %q, want DATABASE_URL", overridden)
+	}
+
+	merged, overridden = mergeEnviron("windows", environ, extra)
+	want = []string{"PATH=/bin", "DATABASE_URL=postgres://localhost/app_x", "HOME=/x", "NEW=1"}
+	if !slices.Equal(merged, want) {
+		t.Errorf("mergeEnviron(windows) = %q, want %q", merged, want)
+	}
+	if !slices.Equal(overridden, []string{"DATABASE_URL", "HOME"}) {
+		t.Errorf("overridden on windows = %q, want names compared without case", overridden)
+	}
+}
+
+func TestConfigCheckEnv(t *testing.T) {
+	cfg := &Config{Env: map[string]string{"DATABASE_URL": "app_{{.WT_BRANCH_SLUG"}}
+	if err := cfg.check("config.yaml"); err == nil || !strings.Contains(err.Error(), "DATABASE_URL") {
+		t.Errorf("check() with a broken env template = %v, want an error naming the variable", err)
+	}
+}
+
+func TestWorktreeEnviron(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping git fixture test in short mode")
+	}
+
+	repoDir := setupInteractiveRepo(t)
+	original := loadedConfig
+	t.Cleanup(func() { loadedConfig = original })
+	loadedConfig = &Config{}
+	if environ, err := worktreeEnviron(repoDir, false); err != nil || environ != nil {
+		t.Errorf("worktreeEnviron() without env = %q, %v; want wt's own environment", environ, err)
+	}
+
+	t.Setenv("DATABASE_URL", "postgres://localhost/app")
+	loadedConfig = &Config{Env: map[string]string{"DATABASE_URL": "postgres://localhost/app_{{.WT_BRANCH_SLUG}}"}}
+	repo, err := getRepoName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := newManager(repo).Checkout("feature-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subdir := filepath.Join(path, "sub")
+	if err := os.MkdirAll(subdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	environ, err := worktreeEnviron(subdir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(environ, "DATABASE_URL=postgres://localhost/app_feature_x") {
+		t.Errorf("environment of feature-x lacks its DATABASE_URL: %q", environ)
+	}
+	if slices.Contains(environ, "DATABASE_URL=postgres://localhost/app") {
+		t.Error("the caller's DATABASE_URL was kept next to the config's")
+	}
+	// Only the child gets the variables, not wt and its git commands.
+	if got := os.Getenv("DATABASE_URL"); got != "postgres://localhost/app" {
+		t.Errorf("DATABASE_URL of wt itself = %q, want it untouched", got)
+	}
+}
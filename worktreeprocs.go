@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Removing a worktree that a shell in another terminal has as its current
+// directory leaves that shell in a deleted directory, where the next git
+// command fails confusingly. Before removing, wt looks for such processes on
+// a best-effort basis: /proc on Linux, lsof on macOS, nothing elsewhere.
+// Processes it may not inspect are skipped silently.
+
+// lsofTimeout bounds how long lsof may search on macOS.
+const lsofTimeout = 2 * time.Second
+
+// processesInWorktree returns the PIDs of processes other than wt and the
+// processes that started it whose current directory lies in path, sorted.
+func processesInWorktree(path string) []int {
+	path = resolvePath(path)
+	var pids []int
+	switch runtime.GOOS {
+	case "linux":
+		pids = scanProcCwds("/proc", path)
+	case "darwin":
+		pids = lsofCwds(path)
+	default:
+		return nil
+	}
+	ancestors := procAncestors("/proc", os.Getpid())
+	ancestors[os.Getppid()] = true
+	var others []int
+	for _, pid := range pids {
+		if !ancestors[pid] {
+			others = append(others, pid)
+		}
+	}
+	return others
+}
+
+// scanProcCwds returns the PIDs below procDir, laid out like /proc, whose cwd
+// link points into path, sorted. Links that cannot be read are skipped.
+func scanProcCwds(procDir, path string) []int {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		cwd, err := os.Readlink(filepath.Join(procDir, entry.Name(), "cwd"))
+		// A process in a directory that is already gone is no longer in the
+		// way.
+		if err != nil || strings.HasSuffix(cwd, " (deleted)") {
+			continue
+		}
+		if isWithin(cwd, path) {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// procAncestors returns pid and the processes above it, following the parent
+// PIDs in the stat files below procDir. It returns only pid where they cannot
+// be read.
+func procAncestors(procDir string, pid int) map[int]bool {
+	ancestors := map[int]bool{pid: true}
+	for pid > 1 {
+		data, err := os.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "stat"))
+		if err != nil {
+			break
+		}
+		// The command name in parentheses may contain spaces; the state and
+		// the parent PID follow its closing parenthesis.
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) < 2 {
+			break
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil || ancestors[ppid] {
+			break
+		}
+		ancestors[ppid] = true
+		pid = ppid
+	}
+	return ancestors
+}
+
+// lsofCwds asks lsof for the processes whose cwd lies in path, giving up
+// after lsofTimeout.
+func lsofCwds(path string) []int {
+	ctx, cancel := context.WithTimeout(context.Background(), lsofTimeout)
+	defer cancel()
+	// lsof exits with status 1 when it finds nothing.
+	output, _ := exec.CommandContext(ctx, "lsof", "-a", "-d", "cwd", "-F", "p", "+D", path).Output()
+	return parseLsofPIDs(string(output))
+}
+
+// parseLsofPIDs returns the PIDs of `lsof -F p` output, sorted.
+func parseLsofPIDs(output string) []int {
+	var pids []int
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "p") {
+			continue
+		}
+		if pid, err := strconv.Atoi(line[1:]); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// describeProcesses says which processes appear to use a worktree.
+func describeProcesses(pids []int) string {
+	ids := make([]string, len(pids))
+	for i, pid := range pids {
+		ids[i] = strconv.Itoa(pid)
+	}
+	if len(pids) == 1 {
+		return "1 other process appears to be using this worktree (PID " + ids[0] + ")"
+	}
+	return strconv.Itoa(len(pids)) + " other processes appear to be using this worktree (PIDs " + strings.Join(ids, ", ") + ")"
+}
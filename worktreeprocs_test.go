@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeProcEntry adds a process to a fabricated /proc: its cwd link, unless
+// cwd is empty, and its stat file with ppid.
+func writeProcEntry(t *testing.T, procDir, pid, cwd, stat string) {
+	t.Helper()
+	dir := filepath.Join(procDir, pid)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if cwd != "" {
+		if err := os.Symlink(cwd, filepath.Join(dir, "cwd")); err != nil {
+			t.Skipf("cannot create symlinks: %v", err)
+		}
+	}
+	if stat != "" {
+		if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanProcCwds(t *testing.T) {
+	procDir := t.TempDir()
+	worktreePath := filepath.FromSlash("/trees/api/feature-x")
+	writeProcEntry(t, procDir, "100", filepath.FromSlash("/trees/api/feature-x"), "")
+	writeProcEntry(t, procDir, "20", filepath.FromSlash("/trees/api/feature-x/pkg/sub"), "")
+	writeProcEntry(t, procDir, "300", filepath.FromSlash("/trees/api/feature-xy"), "")
+	writeProcEntry(t, procDir, "400", filepath.FromSlash("/trees/api/main"), "")
+	writeProcEntry(t, procDir, "500", filepath.FromSlash("/trees/api/feature-x")+" (deleted)", "")
+	// A process whose cwd may not be read, and entries that are not processes.
+	writeProcEntry(t, procDir, "600", "", "")
+	writeProcEntry(t, procDir, "self", filepath.FromSlash("/trees/api/feature-x"), "")
+	if err := os.WriteFile(filepath.Join(procDir, "uptime"), []byte("1 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := scanProcCwds(procDir, worktreePath), []int{20, 100}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scanProcCwds() = %v, want %v", got, want)
+	}
+	if got := scanProcCwds(filepath.Join(procDir, "missing"), worktreePath); got != nil {
+		t.Errorf("scanProcCwds(missing dir) = %v, want nil", got)
+	}
+}
+
+func TestProcAncestors(t *testing.T) {
+	procDir := t.TempDir()
+	writeProcEntry(t, procDir, "40", "", "40 (wt) R 30 40 30 0")
+	writeProcEntry(t, procDir, "30", "", "30 (bash (login) x) S 20 30 30 0")
+	writeProcEntry(t, procDir, "20", "", "20 (tmux: server) S 1 20 20 0")
+
+	want := map[int]bool{40: true, 30: true, 20: true, 1: true}
+	if got := procAncestors(procDir, 40); !reflect.DeepEqual(got, want) {
+		t.Errorf("procAncestors(40) = %v, want %v", got, want)
+	}
+	if got := procAncestors(procDir, 99); !reflect.DeepEqual(got, map[int]bool{99: true}) {
+		t.Errorf("procAncestors(unknown) = %v, want only the pid itself", got)
+	}
+}
+
+func TestParseLsofPIDs(t *testing.T) {
+	output := "p812\nfcwd\np97\nfcwd\n"
+	if got, want := parseLsofPIDs(output), []int{97, 812}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLsofPIDs() = %v, want %v", got, want)
+	}
+	if got := parseLsofPIDs(""); got != nil {
+		t.Errorf("parseLsofPIDs(\"\") = %v, want nil", got)
+	}
+}
+
+func TestDescribeProcesses(t *testing.T) {
+	if got := describeProcesses([]int{42}); got != "1 other process appears to be using this worktree (PID 42)" {
+		t.Errorf("describeProcesses(one) = %q", got)
+	}
+	if got := describeProcesses([]int{42, 97}); !strings.HasPrefix(got, "2 other processes appear") || !strings.HasSuffix(got, "(PIDs 42, 97)") {
+		t.Errorf("describeProcesses(two) = %q", got)
+	}
+}
+
+func TestRemovalPlanProcesses(t *testing.T) {
+	plan := removalPlan{Branch: "feature-x", Path: filepath.FromSlash("/trees/api/feature-x"), Processes: []int{42}}
+	want := "  while 1 other process appears to be using this worktree (PID 42)"
+	if got := plan.lines()[1]; got != want {
+		t.Errorf("lines()[1] = %q, want %q", got, want)
+	}
+
+	// --yes alone does not remove a worktree in use without a terminal.
+	cmd := newFlagTestCmd(t, removeFlags)
+	cmd.Flags().Bool("yes", true, "")
+	stubConfirm(t, false, &Config{})
+	if err := plan.confirmProcesses(cmd); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("confirmProcesses(--yes) = %v, want an error suggesting --force", err)
+	}
+	plan.Force = true
+	if err := plan.confirmProcesses(cmd); err != nil {
+		t.Errorf("confirmProcesses(--yes --force) = %v", err)
+	}
+}